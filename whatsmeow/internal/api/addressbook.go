@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// Largest CSV accepted by contact imports
+const maxContactImportBytes = 10 << 20
+
+// ============================================
+// Address Book Handlers
+// ============================================
+
+// ImportContactsRequest is a contact list to import, given as JSON or as CSV
+// with a header row
+type ImportContactsRequest struct {
+	Contacts []whatsapp.AddressBookEntry `json:"contacts,omitempty"`
+	CSV      string                      `json:"csv,omitempty"`     // phone,name,... one contact per line
+	Replace  bool                        `json:"replace,omitempty"` // Removes the contacts left out
+}
+
+// ExportContacts downloads the contact store merged with the address book as
+// JSON or CSV
+func (h *Handlers) ExportContacts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = whatsapp.ExportCSV
+	}
+
+	export, err := h.manager.ExportContacts(instanceID, format)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.FileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Data)
+}
+
+// ImportContacts adds a contact list to the address book. The body is JSON,
+// or a raw CSV file with Content-Type text/csv (replace=true in the query).
+func (h *Handlers) ImportContacts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req ImportContactsRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxContactImportBytes+1))
+		if err != nil || len(data) > maxContactImportBytes {
+			errorResponse(w, http.StatusBadRequest, "CSV must be at most 10 MB")
+			return
+		}
+		req.CSV = string(data)
+		req.Replace = r.URL.Query().Get("replace") == "true"
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	contacts := req.Contacts
+	if req.CSV != "" {
+		parsed, err := whatsapp.ParseContactsCSV(req.CSV)
+		if err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+		contacts = append(contacts, parsed...)
+	}
+	if len(contacts) == 0 && !req.Replace {
+		errorResponse(w, http.StatusBadRequest, "contacts or csv is required")
+		return
+	}
+
+	result, err := h.manager.ImportContacts(instanceID, contacts, req.Replace)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	log.Info().
+		Str("instanceId", instanceID).
+		Int("imported", result.Imported).
+		Int("updated", result.Updated).
+		Int("skipped", len(result.Skipped)).
+		Msg("Imported contacts")
+
+	successResponse(w, result)
+}
+
+// GetAddressBook lists the address book of an instance
+func (h *Handlers) GetAddressBook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	entries, err := h.manager.GetAddressBook(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, entries)
+}
+
+// DeleteAddressBookEntry removes a contact from the address book
+func (h *Handlers) DeleteAddressBookEntry(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	phone := vars["phone"]
+
+	if err := h.manager.DeleteAddressBookEntry(instanceID, phone); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Contact removed from address book",
+	})
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Admin Handlers
+// ============================================
+
+// RequireAdmin allows a request only with the admin token, sent as
+// "Authorization: Bearer <token>" or ?token= (browsers can't set headers on
+// WebSockets). Without a configured token admin-only routes are disabled.
+func (h *Handlers) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.adminToken == "" {
+			codedErrorResponse(w, http.StatusForbidden, CodeForbidden, "Admin token not configured (WHATSMEOW_ADMIN_TOKEN)")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			codedErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "Invalid admin token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// SetAdminToken sets the token required by admin-only routes
+func (h *Handlers) SetAdminToken(token string) {
+	h.adminToken = token
+}
+
+// SetClusterSecret sets the secret cluster nodes send on proxied requests
+func (h *Handlers) SetClusterSecret(secret string) {
+	h.clusterSecret = secret
+}
+
+// requestToken returns the token sent as "Authorization: Bearer <token>",
+// X-Instance-Token or ?token=
+func requestToken(r *http.Request) string {
+	if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+		return token
+	}
+	if token := r.Header.Get("X-Instance-Token"); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// RequireInstanceToken allows a request for an instance only with that
+// instance's API token or the admin token. Deployments without an admin token
+// keep instances that were never issued a token open.
+func (h *Handlers) RequireInstanceToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		instanceID := vars["instanceId"]
+		if instanceID == "" {
+			instanceID = vars["id"]
+		}
+
+		if !h.instanceTokenValid(instanceID, requestToken(r)) {
+			codedErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "Invalid instance token")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// instanceTokenValid reports whether token grants access to an instance: its
+// own API token, the admin token, an API key of the tenant owning it, or none
+// when neither is configured. The events of all instances (AllInstances)
+// always take the admin token.
+func (h *Handlers) instanceTokenValid(instanceID, token string) bool {
+	if instanceID == whatsapp.AllInstances {
+		return h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+	}
+	if tenantID, ok := h.manager.TenantForKey(token); ok {
+		return h.manager.InstanceTenant(instanceID) == tenantID
+	}
+
+	switch {
+	case h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1:
+		return true
+	case h.manager.CheckInstanceToken(instanceID, token):
+		return true
+	default:
+		return h.adminToken == "" && !h.manager.HasInstanceToken(instanceID)
+	}
+}
+
+// IssueInstanceToken creates a new API token for an instance, invalidating
+// the previous one
+func (h *Handlers) IssueInstanceToken(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["id"]
+
+	token, err := h.manager.IssueInstanceToken(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"instanceId": instanceID,
+		"token":      token,
+	})
+}
+
+// RevokeInstanceToken removes the API token of an instance
+func (h *Handlers) RevokeInstanceToken(w http.ResponseWriter, r *http.Request) {
+	h.manager.RevokeInstanceToken(mux.Vars(r)["id"])
+
+	successResponse(w, map[string]string{
+		"message": "Instance token revoked",
+	})
+}
+
+// GetDefaults returns the settings applied to newly created instances
+func (h *Handlers) GetDefaults(w http.ResponseWriter, r *http.Request) {
+	successResponse(w, h.manager.GetDefaults())
+}
+
+// SetDefaults replaces the settings applied to newly created instances
+func (h *Handlers) SetDefaults(w http.ResponseWriter, r *http.Request) {
+	var req whatsapp.InstanceDefaults
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.manager.SetDefaults(req); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, h.manager.GetDefaults())
+}
+
+// GetDeletedInstances lists logged out instances awaiting purge
+func (h *Handlers) GetDeletedInstances(w http.ResponseWriter, r *http.Request) {
+	successResponse(w, h.manager.GetDeletedInstances())
+}
+
+// GetOverview summarizes the health of every instance for operators
+func (h *Handlers) GetOverview(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.manager.Overview()
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, overview)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Audit Log
+// ============================================
+
+// Largest request body read for the audit target
+const maxAuditBody = 1 << 20
+
+// AuditRequests records every state-changing call (POST, PUT, PATCH and
+// DELETE) handled by this node in the audit log: who made it, the instance,
+// the route, the chat it acted on and the response status. Reads and GraphQL
+// queries aren't recorded.
+func (h *Handlers) AuditRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if unversionedPath(r) == "/graphql" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		instanceID, target := auditSubject(r)
+		action := r.Method + " " + routeTemplate(r)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		h.manager.RecordAudit(whatsapp.AuditEntry{
+			Actor:      h.auditActor(instanceID, tenantKey(r)),
+			InstanceID: instanceID,
+			Action:     action,
+			Target:     target,
+			Status:     rec.status,
+		})
+	})
+}
+
+// auditActor names who made a call without storing the token itself
+func (h *Handlers) auditActor(instanceID, token string) string {
+	switch {
+	case token == "":
+		return "anonymous"
+	case h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1:
+		return "admin"
+	case instanceID != "" && h.manager.CheckInstanceToken(instanceID, token):
+		return "instance:" + instanceID
+	default:
+		if tenantID, ok := h.manager.TenantForKey(token); ok {
+			return "tenant:" + tenantID
+		}
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:6])
+	}
+}
+
+// auditSubject returns the instance and the chat a call acts on, from the
+// route variables or the JSON body. The body is put back for the handler.
+func auditSubject(r *http.Request) (instanceID, target string) {
+	vars := mux.Vars(r)
+	for _, key := range []string{"instanceId", "id", "instanceName"} {
+		if vars[key] != "" {
+			instanceID = vars[key]
+			break
+		}
+	}
+	for _, key := range []string{"jid", "phone"} {
+		if vars[key] != "" {
+			target = vars[key]
+			break
+		}
+	}
+
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return instanceID, target
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+	if err != nil {
+		return instanceID, target
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	var req struct {
+		InstanceID string `json:"instanceId"`
+		To         string `json:"to"`
+		JID        string `json:"jid"`
+		ChatID     string `json:"chatId"`
+		GroupID    string `json:"groupId"`
+		Number     string `json:"number"` // Evolution API compatible routes
+	}
+	// Malformed bodies are left for the handler to reject
+	json.Unmarshal(body, &req)
+	if instanceID == "" {
+		instanceID = req.InstanceID
+	}
+	if target == "" {
+		for _, value := range []string{req.To, req.JID, req.ChatID, req.GroupID, req.Number} {
+			if value != "" {
+				target = value
+				break
+			}
+		}
+	}
+	return instanceID, target
+}
+
+// GetAuditLog lists recorded state-changing API calls, newest first
+func (h *Handlers) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := whatsapp.AuditQuery{
+		InstanceID: q.Get("instanceId"),
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		Target:     q.Get("target"),
+		Result:     q.Get("result"),
+	}
+
+	for name, dst := range map[string]*int64{"since": &query.Since, "until": &query.Until, "before": &query.Before} {
+		if value := q.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 0 {
+				errorResponse(w, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dst = parsed
+		}
+	}
+	if value := q.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		query.Limit = parsed
+	}
+
+	entries, err := h.manager.AuditLog(r.Context(), query)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, entries)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Auto-reply Handlers
+// ============================================
+
+// GetAutoReplies lists the auto-reply rules of an instance
+func (h *Handlers) GetAutoReplies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	rules, err := h.manager.GetAutoReplies(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, rules)
+}
+
+// CreateAutoReply adds an auto-reply rule
+func (h *Handlers) CreateAutoReply(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req whatsapp.AutoReply
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.manager.CreateAutoReply(instanceID, req)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, rule)
+}
+
+// UpdateAutoReply replaces an auto-reply rule
+func (h *Handlers) UpdateAutoReply(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	ruleID := vars["ruleId"]
+
+	var req whatsapp.AutoReply
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rule, err := h.manager.UpdateAutoReply(instanceID, ruleID, req)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, rule)
+}
+
+// DeleteAutoReply removes an auto-reply rule
+func (h *Handlers) DeleteAutoReply(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	ruleID := vars["ruleId"]
+
+	if err := h.manager.DeleteAutoReply(instanceID, ruleID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Auto-reply deleted",
+	})
+}
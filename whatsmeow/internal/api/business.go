@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Business Profile Handlers
+// ============================================
+
+// GetBusinessProfile gets the business profile of an instance's account
+func (h *Handlers) GetBusinessProfile(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["id"]
+
+	profile, err := h.manager.GetBusinessProfile(instanceID)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to get business profile")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, profile)
+}
+
+// UpdateBusinessProfile updates the business profile of an instance's account
+func (h *Handlers) UpdateBusinessProfile(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["id"]
+
+	var req whatsapp.BusinessProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	profile, err := h.manager.UpdateBusinessProfile(instanceID, req)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to update business profile")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, profile)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================
+// Call Handlers
+// ============================================
+
+// RejectCallRequest represents a request to reject a ringing call
+type RejectCallRequest struct {
+	CallID string `json:"callId"`
+}
+
+// GetCallLog returns the incoming call history of an instance
+func (h *Handlers) GetCallLog(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	calls, err := h.manager.GetCallLog(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, calls)
+}
+
+// RejectCall rejects an incoming call that is still ringing
+func (h *Handlers) RejectCall(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req RejectCallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.CallID == "" {
+		errorResponse(w, http.StatusBadRequest, "callId is required")
+		return
+	}
+
+	call, err := h.manager.RejectCall(instanceID, req.CallID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, call)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Campaign Handlers
+// ============================================
+
+// CreateCampaignRequest is a campaign with its recipient list, given as JSON,
+// as CSV with a header row or taken from the address book
+type CreateCampaignRequest struct {
+	whatsapp.Campaign
+	Recipients  []whatsapp.CampaignRecipient `json:"recipients,omitempty"`
+	CSV         string                       `json:"csv,omitempty"`         // number,name,... one recipient per line
+	AddressBook bool                         `json:"addressBook,omitempty"` // Sends to every address book contact
+}
+
+// CreateCampaign stores a campaign and starts sending it
+func (h *Handlers) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req CreateCampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	recipients := req.Recipients
+	if req.CSV != "" {
+		parsed, err := whatsapp.ParseCampaignCSV(req.CSV)
+		if err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+		recipients = append(recipients, parsed...)
+	}
+	if req.AddressBook {
+		entries, err := h.manager.GetAddressBook(instanceID)
+		if err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+		// Their names and fields are filled in when sending
+		for _, entry := range entries {
+			recipients = append(recipients, whatsapp.CampaignRecipient{Number: entry.Phone})
+		}
+	}
+
+	campaign, err := h.manager.CreateCampaign(instanceID, req.Campaign, recipients)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaign)
+}
+
+// GetCampaigns lists the campaigns of an instance
+func (h *Handlers) GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	campaigns, err := h.manager.GetCampaigns(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaigns)
+}
+
+// GetCampaign returns a campaign with its recipient counts
+func (h *Handlers) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	campaignID := vars["campaignId"]
+
+	campaign, err := h.manager.GetCampaign(instanceID, campaignID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaign)
+}
+
+// GetCampaignRecipients lists the recipients of a campaign and their state
+func (h *Handlers) GetCampaignRecipients(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	campaignID := vars["campaignId"]
+	query := r.URL.Query()
+
+	var limit, offset int
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	if o := query.Get("offset"); o != "" {
+		parsed, err := strconv.Atoi(o)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid offset")
+			return
+		}
+		offset = parsed
+	}
+
+	recipients, err := h.manager.GetCampaignRecipients(instanceID, campaignID, query.Get("status"), limit, offset)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, recipients)
+}
+
+// PauseCampaign stops sending a campaign until it is resumed
+func (h *Handlers) PauseCampaign(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	campaign, err := h.manager.PauseCampaign(vars["instanceId"], vars["campaignId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaign)
+}
+
+// ResumeCampaign continues sending a paused campaign
+func (h *Handlers) ResumeCampaign(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	campaign, err := h.manager.ResumeCampaign(vars["instanceId"], vars["campaignId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaign)
+}
+
+// CancelCampaign stops a campaign for good
+func (h *Handlers) CancelCampaign(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	campaign, err := h.manager.CancelCampaign(vars["instanceId"], vars["campaignId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, campaign)
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// WhatsApp Cloud API Compatibility
+// ============================================
+
+// POST /v1/{instanceId}/messages accepts the message payloads of the Meta
+// Graph (Cloud) API and answers in its response shapes, so a client written
+// for it only needs a new base URL, with the instance ID in place of the
+// phone number ID and the instance token as access token.
+
+// Graph API error codes returned by the Cloud API compatible endpoint
+const (
+	cloudCodeInvalidParameter = 100
+	cloudCodeAccessToken      = 190
+	cloudCodeRateLimit        = 130429
+	cloudCodeGeneric          = 131000
+	cloudCodeUndeliverable    = 131026
+)
+
+// SetCloudTemplates sets the bodies of the templates template messages are
+// rendered from, keyed by template name. Positional parameters fill {{1}},
+// {{2}}...; named ones fill {{name}}.
+func (h *Handlers) SetCloudTemplates(templates map[string]string) {
+	h.cloudTemplates = templates
+}
+
+// cloudError writes an error in the shape the Graph API uses
+func cloudError(w http.ResponseWriter, status int, message string) {
+	code := cloudCodeGeneric
+	switch status {
+	case http.StatusBadRequest, http.StatusNotFound:
+		code = cloudCodeInvalidParameter
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = cloudCodeAccessToken
+	case http.StatusUnprocessableEntity:
+		code = cloudCodeUndeliverable
+	case http.StatusTooManyRequests:
+		code = cloudCodeRateLimit
+	}
+
+	jsonResponse(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "OAuthException",
+			"code":    code,
+			"error_data": map[string]interface{}{
+				"messaging_product": "whatsapp",
+				"details":           message,
+			},
+		},
+	})
+}
+
+// cloudManagerError writes a manager error in the Graph API shape
+func cloudManagerError(w http.ResponseWriter, err error) {
+	status, _ := managerErrorStatus(err)
+	cloudError(w, status, err.Error())
+}
+
+// CloudMedia is an image, video, audio, document or sticker object. Only
+// media given by link can be sent; ids of media uploaded to Meta can't.
+type CloudMedia struct {
+	ID       string `json:"id,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// CloudInteractive is the interactive object of button and list messages
+type CloudInteractive struct {
+	Type   string `json:"type"` // button or list
+	Header *struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"header,omitempty"`
+	Body *struct {
+		Text string `json:"text"`
+	} `json:"body,omitempty"`
+	Footer *struct {
+		Text string `json:"text"`
+	} `json:"footer,omitempty"`
+	Action struct {
+		Button  string `json:"button,omitempty"` // List menu button
+		Buttons []struct {
+			Type  string `json:"type"`
+			Reply struct {
+				ID    string `json:"id"`
+				Title string `json:"title"`
+			} `json:"reply"`
+		} `json:"buttons,omitempty"`
+		Sections []struct {
+			Title string `json:"title"`
+			Rows  []struct {
+				ID          string `json:"id"`
+				Title       string `json:"title"`
+				Description string `json:"description,omitempty"`
+			} `json:"rows"`
+		} `json:"sections,omitempty"`
+	} `json:"action"`
+}
+
+// CloudTemplateParameter is a parameter of a template component
+type CloudTemplateParameter struct {
+	Type          string `json:"type"` // text, currency, date_time, image, video or document
+	ParameterName string `json:"parameter_name,omitempty"`
+	Text          string `json:"text,omitempty"`
+	Currency      *struct {
+		FallbackValue string `json:"fallback_value"`
+	} `json:"currency,omitempty"`
+	DateTime *struct {
+		FallbackValue string `json:"fallback_value"`
+	} `json:"date_time,omitempty"`
+	Image    *CloudMedia `json:"image,omitempty"`
+	Video    *CloudMedia `json:"video,omitempty"`
+	Document *CloudMedia `json:"document,omitempty"`
+}
+
+// value returns the text a parameter fills its placeholder with
+func (p CloudTemplateParameter) value() string {
+	switch {
+	case p.Currency != nil:
+		return p.Currency.FallbackValue
+	case p.DateTime != nil:
+		return p.DateTime.FallbackValue
+	default:
+		return p.Text
+	}
+}
+
+// CloudTemplate is the template object of template messages
+type CloudTemplate struct {
+	Name     string `json:"name"`
+	Language struct {
+		Code string `json:"code"`
+	} `json:"language"`
+	Components []struct {
+		Type       string                   `json:"type"` // header, body or button
+		Parameters []CloudTemplateParameter `json:"parameters"`
+	} `json:"components,omitempty"`
+}
+
+// CloudSendMessageRequest is a Cloud API message; type selects which of the
+// objects is sent
+type CloudSendMessageRequest struct {
+	MessagingProduct string `json:"messaging_product"`
+	RecipientType    string `json:"recipient_type,omitempty"`
+	To               string `json:"to"`
+	Type             string `json:"type"`
+	Text             *struct {
+		Body       string `json:"body"`
+		PreviewURL bool   `json:"preview_url,omitempty"`
+	} `json:"text,omitempty"`
+	Image    *CloudMedia `json:"image,omitempty"`
+	Video    *CloudMedia `json:"video,omitempty"`
+	Audio    *CloudMedia `json:"audio,omitempty"`
+	Document *CloudMedia `json:"document,omitempty"`
+	Sticker  *CloudMedia `json:"sticker,omitempty"`
+	Location *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+		Name      string  `json:"name,omitempty"`
+		Address   string  `json:"address,omitempty"`
+	} `json:"location,omitempty"`
+	Reaction *struct {
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	} `json:"reaction,omitempty"`
+	Interactive *CloudInteractive `json:"interactive,omitempty"`
+	Template    *CloudTemplate    `json:"template,omitempty"`
+}
+
+// media returns the media object of a media message type
+func (req *CloudSendMessageRequest) media() *CloudMedia {
+	switch req.Type {
+	case "image":
+		return req.Image
+	case "video":
+		return req.Video
+	case "audio":
+		return req.Audio
+	case "document":
+		return req.Document
+	case "sticker":
+		return req.Sticker
+	}
+	return nil
+}
+
+// CloudSendMessage sends a Cloud API message payload
+func (h *Handlers) CloudSendMessage(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceId"]
+	if !h.instanceTokenValid(instanceID, requestToken(r)) {
+		cloudError(w, http.StatusUnauthorized, "Invalid OAuth access token")
+		return
+	}
+
+	var req CloudSendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cloudError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.MessagingProduct != "" && req.MessagingProduct != "whatsapp" {
+		cloudError(w, http.StatusBadRequest, "messaging_product must be whatsapp")
+		return
+	}
+	if req.Type == "" {
+		req.Type = "text"
+	}
+
+	to := cleanPhoneNumber(req.To)
+	if to == "" {
+		cloudError(w, http.StatusBadRequest, "to is required")
+		return
+	}
+
+	messageID, err := h.sendCloudMessage(r, instanceID, to, &req)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("type", req.Type).Msg("Failed to send message (Cloud API)")
+		cloudManagerError(w, err)
+		return
+	}
+
+	messages := []map[string]string{}
+	if messageID != "" {
+		messages = append(messages, map[string]string{"id": messageID})
+	}
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": req.To, "wa_id": to}},
+		"messages":          messages,
+	})
+}
+
+// sendCloudMessage sends the object req.Type selects and returns the ID of
+// the message sent, which reactions don't have
+func (h *Handlers) sendCloudMessage(r *http.Request, instanceID, to string, req *CloudSendMessageRequest) (string, error) {
+	switch req.Type {
+	case "text":
+		if req.Text == nil || req.Text.Body == "" {
+			return "", fmt.Errorf("%w: text.body is required", whatsapp.ErrInvalidInput)
+		}
+		// Like the Cloud API, URLs are only previewed when asked to
+		preview := whatsapp.PreviewOptions{Disabled: !req.Text.PreviewURL}
+		return h.manager.SendTextMessage(sendContext(r), instanceID, to, req.Text.Body, preview)
+
+	case "image", "video", "audio", "document", "sticker":
+		return h.sendCloudMedia(r, instanceID, to, req.Type, req.media(), "")
+
+	case "location":
+		if req.Location == nil {
+			return "", fmt.Errorf("%w: location is required", whatsapp.ErrInvalidInput)
+		}
+		return h.manager.SendLocationMessage(instanceID, to, whatsapp.Location{
+			Latitude:  req.Location.Latitude,
+			Longitude: req.Location.Longitude,
+			Name:      req.Location.Name,
+			Address:   req.Location.Address,
+		})
+
+	case "reaction":
+		if req.Reaction == nil || req.Reaction.MessageID == "" {
+			return "", fmt.Errorf("%w: reaction.message_id is required", whatsapp.ErrInvalidInput)
+		}
+		return "", h.manager.ReactToMessage(instanceID, to, req.Reaction.MessageID, req.Reaction.Emoji, "")
+
+	case "interactive":
+		return h.sendCloudInteractive(instanceID, to, req.Interactive)
+
+	case "template":
+		return h.sendCloudTemplate(r, instanceID, to, req.Template)
+
+	default:
+		return "", fmt.Errorf("%w: unsupported message type %q", whatsapp.ErrInvalidInput, req.Type)
+	}
+}
+
+// sendCloudMedia sends a media object; caption, when set, replaces the
+// object's own
+func (h *Handlers) sendCloudMedia(r *http.Request, instanceID, to, mediaType string, media *CloudMedia, caption string) (string, error) {
+	if media == nil {
+		return "", fmt.Errorf("%w: %s is required", whatsapp.ErrInvalidInput, mediaType)
+	}
+	if media.Link == "" {
+		if media.ID != "" {
+			return "", fmt.Errorf("%w: media ids aren't supported, send the media by link", whatsapp.ErrInvalidInput)
+		}
+		return "", fmt.Errorf("%w: %s.link is required", whatsapp.ErrInvalidInput, mediaType)
+	}
+	if caption == "" {
+		caption = media.Caption
+	}
+
+	return h.manager.SendMediaMessage(sendContext(r), instanceID, to, media.Link, caption, mediaType, whatsapp.MediaOptions{
+		FileName: media.Filename,
+	})
+}
+
+// sendCloudInteractive sends a reply button or list message
+func (h *Handlers) sendCloudInteractive(instanceID, to string, interactive *CloudInteractive) (string, error) {
+	if interactive == nil {
+		return "", fmt.Errorf("%w: interactive is required", whatsapp.ErrInvalidInput)
+	}
+
+	var header, body, footer string
+	if interactive.Header != nil {
+		if interactive.Header.Type != "" && interactive.Header.Type != "text" {
+			return "", fmt.Errorf("%w: only text interactive headers are supported", whatsapp.ErrInvalidInput)
+		}
+		header = interactive.Header.Text
+	}
+	if interactive.Body != nil {
+		body = interactive.Body.Text
+	}
+	if interactive.Footer != nil {
+		footer = interactive.Footer.Text
+	}
+
+	switch interactive.Type {
+	case "button":
+		buttons := make([]whatsapp.Button, len(interactive.Action.Buttons))
+		for i, button := range interactive.Action.Buttons {
+			buttons[i] = whatsapp.Button{ID: button.Reply.ID, Text: button.Reply.Title}
+		}
+		return h.manager.SendButtonsMessage(instanceID, to, body, footer, header, buttons)
+
+	case "list":
+		sections := make([]whatsapp.ListSection, len(interactive.Action.Sections))
+		for i, section := range interactive.Action.Sections {
+			rows := make([]whatsapp.ListRow, len(section.Rows))
+			for j, row := range section.Rows {
+				rows[j] = whatsapp.ListRow{ID: row.ID, Title: row.Title, Description: row.Description}
+			}
+			sections[i] = whatsapp.ListSection{Title: section.Title, Rows: rows}
+		}
+		return h.manager.SendListMessage(instanceID, to, header, body, interactive.Action.Button, footer, sections)
+
+	default:
+		return "", fmt.Errorf("%w: unsupported interactive type %q", whatsapp.ErrInvalidInput, interactive.Type)
+	}
+}
+
+// sendCloudTemplate renders a configured template with the body parameters
+// and sends it as text, or as the caption of the header media when there is
+// one. Button components are ignored.
+func (h *Handlers) sendCloudTemplate(r *http.Request, instanceID, to string, template *CloudTemplate) (string, error) {
+	if template == nil || template.Name == "" {
+		return "", fmt.Errorf("%w: template.name is required", whatsapp.ErrInvalidInput)
+	}
+	text, ok := h.cloudTemplates[template.Name]
+	if !ok {
+		return "", fmt.Errorf("%w: template %s is not configured", whatsapp.ErrInvalidInput, template.Name)
+	}
+
+	vars := make(map[string]string)
+	var mediaType string
+	var media *CloudMedia
+	for _, component := range template.Components {
+		switch component.Type {
+		case "body":
+			for i, param := range component.Parameters {
+				vars[strconv.Itoa(i+1)] = param.value()
+				if param.ParameterName != "" {
+					vars[param.ParameterName] = param.value()
+				}
+			}
+		case "header":
+			for _, param := range component.Parameters {
+				switch {
+				case param.Image != nil:
+					mediaType, media = "image", param.Image
+				case param.Video != nil:
+					mediaType, media = "video", param.Video
+				case param.Document != nil:
+					mediaType, media = "document", param.Document
+				}
+			}
+		}
+	}
+
+	text = h.manager.RenderTemplate(instanceID, to, text, vars)
+	if media != nil {
+		return h.sendCloudMedia(r, instanceID, to, mediaType, media, text)
+	}
+	return h.manager.SendTextMessage(sendContext(r), instanceID, to, text, whatsapp.PreviewOptions{Disabled: true})
+}
+
+// isCloudSendPath reports whether an unversioned path is the Cloud API
+// compatible send, /{instanceId}/messages
+func isCloudSendPath(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	return len(parts) == 2 && parts[1] == "messages"
+}
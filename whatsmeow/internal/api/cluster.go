@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Cluster Proxy
+// ============================================
+
+// Header set on requests proxied to the owning node, so they are never
+// forwarded a second time
+const forwardedHeader = "X-Whatsmeow-Forwarded-By"
+
+// Header carrying the cluster secret on proxied requests. Without it the
+// forwarded header is ignored.
+const forwardSecretHeader = "X-Whatsmeow-Cluster-Secret"
+
+// Largest JSON body read to find the instance a request targets. Larger
+// bodies are refused, as the instance they name couldn't be checked. The body
+// is read once per request, by the first middleware that needs the instance.
+const maxInstanceIDBody = 64 << 20
+
+type instanceIDContextKey struct{}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// Timeout of the ownership lookup made before handling a request
+const ownerLookupTimeout = 5 * time.Second
+
+// OwnerLookup reports which node owns an instance
+type OwnerLookup interface {
+	NodeID() string
+	// Owner returns the owning node and its URL, both empty when unowned
+	Owner(ctx context.Context, instanceID string) (nodeID, nodeURL string, err error)
+}
+
+// ClusterProxy forwards requests for instances owned by another node to that
+// node. Requests for unowned instances are handled locally, which makes this
+// node their owner once the instance is created. The cluster secret proves
+// to the owning node that a peer proxied the request.
+func ClusterProxy(owners OwnerLookup, secret string) mux.MiddlewareFunc {
+	var proxies sync.Map // node URL -> *httputil.ReverseProxy
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if forwardedByPeer(r, secret) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Header.Del(forwardedHeader)
+			r.Header.Del(forwardSecretHeader)
+
+			// Shares the instance found by the rate limiter, or finds it for
+			// the middlewares after this one
+			r, instanceID, err := withInstanceID(r)
+			if err != nil {
+				bodyErrorResponse(w, err)
+				return
+			}
+			if instanceID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), ownerLookupTimeout)
+			nodeID, nodeURL, err := owners.Owner(ctx, instanceID)
+			cancel()
+			if err != nil {
+				log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to look up instance owner")
+				codedErrorResponse(w, http.StatusServiceUnavailable, CodeClusterUnavailable, "Failed to look up instance owner")
+				return
+			}
+			if nodeID == "" || nodeID == owners.NodeID() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			proxy, ok := proxies.Load(nodeURL)
+			if !ok {
+				target, err := url.Parse(nodeURL)
+				if err != nil {
+					log.Error().Err(err).Str("node", nodeID).Str("url", nodeURL).Msg("Invalid node URL")
+					codedErrorResponse(w, http.StatusServiceUnavailable, CodeClusterUnavailable, "Owning node is unreachable")
+					return
+				}
+				proxy, _ = proxies.LoadOrStore(nodeURL, newNodeProxy(nodeID, target))
+			}
+
+			r.Header.Set(forwardedHeader, owners.NodeID())
+			r.Header.Set(forwardSecretHeader, secret)
+			proxy.(*httputil.ReverseProxy).ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedByPeer reports whether another node proxied a request, which it
+// proves with the cluster secret
+func forwardedByPeer(r *http.Request, secret string) bool {
+	return secret != "" && r.Header.Get(forwardedHeader) != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get(forwardSecretHeader)), []byte(secret)) == 1
+}
+
+// newNodeProxy creates a reverse proxy to another node. It also carries
+// WebSocket upgrades and streams SSE responses as they are written.
+func newNodeProxy(nodeID string, target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Error().Err(err).Str("node", nodeID).Str("path", r.URL.Path).Msg("Failed to proxy request to owning node")
+		codedErrorResponse(w, http.StatusBadGateway, CodeClusterUnavailable, "Owning node is unreachable")
+	}
+	return proxy
+}
+
+// withInstanceID returns the instance a request targets and the request
+// carrying it in its context, so the middlewares and handlers after it don't
+// read the body again
+func withInstanceID(r *http.Request) (*http.Request, string, error) {
+	if id, ok := r.Context().Value(instanceIDContextKey{}).(string); ok {
+		return r, id, nil
+	}
+	id, err := readInstanceID(r)
+	if err != nil {
+		return r, "", err
+	}
+	return r.WithContext(context.WithValue(r.Context(), instanceIDContextKey{}, id)), id, nil
+}
+
+// requestInstanceID returns the instance a request targets, as found by an
+// earlier middleware or else read from the request
+func requestInstanceID(r *http.Request) (string, error) {
+	_, id, err := withInstanceID(r)
+	return id, err
+}
+
+// readInstanceID finds the instance a request targets, from the route or
+// from the instanceId field of a JSON body of up to maxInstanceIDBody bytes.
+// Bodies of other types aren't read. The body is restored for the handler.
+func readInstanceID(r *http.Request) (string, error) {
+	vars := mux.Vars(r)
+	if id := vars["instanceId"]; id != "" {
+		return id, nil
+	}
+	if id := vars["id"]; id != "" {
+		return id, nil
+	}
+	if id := vars["instanceName"]; id != "" {
+		return id, nil // Evolution API compatible routes
+	}
+	if token := vars["mediaToken"]; token != "" {
+		return whatsapp.MediaTokenInstance(token), nil
+	}
+	if r.Body == nil || r.Method == http.MethodGet || !isJSONRequest(r) {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInstanceIDBody+1))
+	if err != nil {
+		return "", err
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if len(body) > maxInstanceIDBody {
+		return "", errBodyTooLarge
+	}
+
+	var req struct {
+		InstanceID string `json:"instanceId"`
+	}
+	// Malformed bodies are left for the handler to reject
+	json.Unmarshal(body, &req)
+	return req.InstanceID, nil
+}
+
+// isJSONRequest reports whether a request declares a JSON body
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// bodyErrorResponse answers a request whose body requestInstanceID couldn't read
+func bodyErrorResponse(w http.ResponseWriter, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		codedErrorResponse(w, http.StatusRequestEntityTooLarge, CodeInvalidRequest, "Request body too large")
+		return
+	}
+	errorResponse(w, http.StatusBadRequest, "Failed to read request body")
+}
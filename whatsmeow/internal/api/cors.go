@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig selects which browser origins may call the API
+type CORSConfig struct {
+	AllowedOrigins   []string // Exact origins, "https://*.example.com" patterns or "*" for any
+	AllowCredentials bool     // Let browsers send cookies and Authorization headers
+	MaxAge           time.Duration
+}
+
+const (
+	corsMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsHeaders = "Content-Type, Authorization, X-Instance-Token, apikey, Last-Event-ID"
+)
+
+// allowsOrigin reports whether a browser origin is in the allowlist
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		// "https://*.example.com" matches any subdomain, not the bare domain
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard reports whether every origin is allowed
+func (c CORSConfig) wildcard() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS answers preflight requests and adds CORS headers for allowed origins.
+// Requests from other origins get no CORS headers, so browsers block them.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	wildcard := cfg.wildcard() && !cfg.AllowCredentials
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			header := w.Header()
+
+			switch {
+			case wildcard:
+				header.Set("Access-Control-Allow-Origin", "*")
+			case origin != "" && cfg.allowsOrigin(origin):
+				// Browsers reject "*" with credentials, so the origin is echoed
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Add("Vary", "Origin")
+				if cfg.AllowCredentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+			default:
+				if origin != "" {
+					header.Add("Vary", "Origin")
+				}
+				if r.Method == http.MethodOptions {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header.Set("Access-Control-Allow-Methods", corsMethods)
+			header.Set("Access-Control-Allow-Headers", corsHeaders)
+			if cfg.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SetCORS applies the CORS allowlist to WebSocket upgrades, which browsers
+// don't preflight. Connections without an Origin header (non-browser clients)
+// and from the API's own origin are always accepted.
+func (h *Handlers) SetCORS(cfg CORSConfig) {
+	h.upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || cfg.allowsOrigin(origin) {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================
+// Dialogflow Handlers
+// ============================================
+
+// GetDialogflowSessions lists the Dialogflow sessions of an instance
+func (h *Handlers) GetDialogflowSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	sessions, err := h.manager.GetDialogflowSessions(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, sessions)
+}
+
+// EndDialogflowSession ends the Dialogflow session of a chat
+func (h *Handlers) EndDialogflowSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	chatID := vars["jid"]
+
+	if err := h.manager.EndDialogflowSession(instanceID, chatID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Dialogflow session ended",
+	})
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// Machine-readable error codes returned in the "code" field of error responses
+const (
+	CodeInvalidRequest      = "INVALID_REQUEST"
+	CodeNotFound            = "NOT_FOUND"
+	CodeUnauthorized        = "UNAUTHORIZED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeInternalError       = "INTERNAL_ERROR"
+	CodeInstanceNotFound    = "INSTANCE_NOT_FOUND"
+	CodeInstanceExists      = "INSTANCE_EXISTS"
+	CodeInstanceDeleted     = "INSTANCE_DELETED"
+	CodeNotConnected        = "NOT_CONNECTED"
+	CodeAlreadyConnected    = "ALREADY_CONNECTED"
+	CodeNotOnWhatsApp       = "NOT_ON_WHATSAPP"
+	CodeInvalidJID          = "INVALID_JID"
+	CodeInvalidPhoneNumber  = "INVALID_PHONE_NUMBER"
+	CodeMediaNotFound       = "MEDIA_NOT_FOUND"
+	CodeMessageNotFound     = "MESSAGE_NOT_FOUND"
+	CodeGroupNotFound       = "GROUP_NOT_FOUND"
+	CodeNotGroupAdmin       = "NOT_GROUP_ADMIN"
+	CodeMediaDownloadFailed = "MEDIA_DOWNLOAD_FAILED"
+	CodeMediaUploadFailed   = "MEDIA_UPLOAD_FAILED"
+	CodeSendFailed          = "SEND_FAILED"
+	CodeClusterUnavailable  = "CLUSTER_UNAVAILABLE"
+	CodeInvalidMediaToken   = "INVALID_MEDIA_TOKEN"
+	CodeAutoReplyNotFound   = "AUTO_REPLY_NOT_FOUND"
+	CodeCampaignNotFound    = "CAMPAIGN_NOT_FOUND"
+	CodeCampaignFinished    = "CAMPAIGN_FINISHED"
+	CodeContactNotFound     = "CONTACT_NOT_FOUND"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeTenantNotFound      = "TENANT_NOT_FOUND"
+	CodeQuotaExceeded       = "QUOTA_EXCEEDED"
+)
+
+// managerErrors maps manager sentinel errors to HTTP status and error code
+var managerErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{whatsapp.ErrInstanceNotFound, http.StatusNotFound, CodeInstanceNotFound},
+	{whatsapp.ErrInstanceExists, http.StatusConflict, CodeInstanceExists},
+	{whatsapp.ErrInstanceDeleted, http.StatusGone, CodeInstanceDeleted},
+	{whatsapp.ErrNotConnected, http.StatusConflict, CodeNotConnected},
+	{whatsapp.ErrAlreadyConnected, http.StatusConflict, CodeAlreadyConnected},
+	{whatsapp.ErrNotOnWhatsApp, http.StatusUnprocessableEntity, CodeNotOnWhatsApp},
+	{whatsapp.ErrInvalidJID, http.StatusBadRequest, CodeInvalidJID},
+	{whatsapp.ErrInvalidPhoneNumber, http.StatusBadRequest, CodeInvalidPhoneNumber},
+	{whatsapp.ErrInvalidInput, http.StatusBadRequest, CodeInvalidRequest},
+	{whatsapp.ErrMediaNotFound, http.StatusNotFound, CodeMediaNotFound},
+	{whatsapp.ErrInvalidMediaToken, http.StatusForbidden, CodeInvalidMediaToken},
+	{whatsapp.ErrMessageNotFound, http.StatusNotFound, CodeMessageNotFound},
+	{whatsapp.ErrGroupNotFound, http.StatusNotFound, CodeGroupNotFound},
+	{whatsapp.ErrNotGroupAdmin, http.StatusForbidden, CodeNotGroupAdmin},
+	{whatsapp.ErrAutoReplyNotFound, http.StatusNotFound, CodeAutoReplyNotFound},
+	{whatsapp.ErrCampaignNotFound, http.StatusNotFound, CodeCampaignNotFound},
+	{whatsapp.ErrCampaignFinished, http.StatusConflict, CodeCampaignFinished},
+	{whatsapp.ErrContactNotFound, http.StatusNotFound, CodeContactNotFound},
+	{whatsapp.ErrTenantNotFound, http.StatusNotFound, CodeTenantNotFound},
+	{whatsapp.ErrQuotaExceeded, http.StatusTooManyRequests, CodeQuotaExceeded},
+	{whatsapp.ErrClusterUnavailable, http.StatusServiceUnavailable, CodeClusterUnavailable},
+	{whatsapp.ErrMediaDownloadFailed, http.StatusBadGateway, CodeMediaDownloadFailed},
+	{whatsapp.ErrMediaUploadFailed, http.StatusBadGateway, CodeMediaUploadFailed},
+	{whatsapp.ErrSendFailed, http.StatusBadGateway, CodeSendFailed},
+}
+
+// codeForStatus returns the generic error code for an HTTP status
+func codeForStatus(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status >= 400 && status < 500:
+		return CodeInvalidRequest
+	default:
+		return CodeInternalError
+	}
+}
+
+// managerErrorResponse writes an error returned by the manager, mapping it to
+// the matching status and code instead of a blanket 500
+func managerErrorResponse(w http.ResponseWriter, err error) {
+	status, code := managerErrorStatus(err)
+	codedErrorResponse(w, status, code, err.Error())
+}
+
+// managerErrorStatus returns the HTTP status and error code of a manager error
+func managerErrorStatus(err error) (int, string) {
+	for _, m := range managerErrors {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, CodeInternalError
+}
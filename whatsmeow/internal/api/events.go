@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Event Polling Handlers
+// ============================================
+
+const (
+	defaultPollTimeout = 25 * time.Second
+	// Must stay below the server WriteTimeout
+	maxPollTimeout   = 25 * time.Second
+	defaultPollLimit = 100
+	maxPollLimit     = 1000
+
+	// Interval of SSE keep-alive comments, short enough for common proxy idle timeouts
+	sseHeartbeat = 15 * time.Second
+)
+
+// eventStream writes the live events of a subscription, reading events back
+// from the journal when resuming from an ID or after the channel overflowed
+type eventStream struct {
+	manager    *whatsapp.Manager
+	ctx        context.Context
+	instanceID string
+	lastSent   int64                // ID of the last event written (or skipped by the filter)
+	filter     whatsapp.EventFilter // Event types written (nil = all)
+	write      func(whatsapp.Event) error
+}
+
+// send writes an event if the filter allows it
+func (s *eventStream) send(event whatsapp.Event) error {
+	if !s.filter.Allows(event.Type) {
+		return nil
+	}
+	return s.write(event)
+}
+
+// replay writes the journaled events after lastSent
+func (s *eventStream) replay() error {
+	for {
+		missed, err := s.manager.EventsAfter(s.ctx, s.instanceID, s.lastSent, maxPollLimit)
+		if err != nil {
+			return err
+		}
+		for _, event := range missed {
+			if err := s.send(event); err != nil {
+				return err
+			}
+			s.lastSent = event.ID
+		}
+		if len(missed) < maxPollLimit {
+			return nil
+		}
+	}
+}
+
+// deliver writes an event received from ch, skipping events already replayed
+func (s *eventStream) deliver(event whatsapp.Event, ch chan whatsapp.Event) error {
+	// A full channel means events may have been dropped; read them back
+	// from the journal instead (other overflow policies accept the loss)
+	if len(ch) == cap(ch)-1 && event.ID != 0 && s.manager.EventOverflowPolicy() == whatsapp.OverflowJournal {
+		for len(ch) > 0 {
+			<-ch
+		}
+		if s.lastSent == 0 {
+			s.lastSent = event.ID - 1
+		}
+		return s.replay()
+	}
+
+	// An instance's events arrive in sequence order, so any at or below
+	// lastSent were replayed already
+	if event.ID != 0 && event.ID <= s.lastSent {
+		return nil
+	}
+	if err := s.send(event); err != nil {
+		return err
+	}
+	if event.ID != 0 {
+		s.lastSent = event.ID
+	}
+	return nil
+}
+
+// PollEvents implements HTTP long polling over the event journal, as an
+// alternative to the WebSocket for consumers that can't hold sockets open
+func (h *Handlers) PollEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	query := r.URL.Query()
+
+	var cursor int64
+	if c := query.Get("cursor"); c != "" {
+		parsed, err := strconv.ParseInt(c, 10, 64)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		cursor = parsed
+	}
+
+	timeout := defaultPollTimeout
+	if t := query.Get("timeout"); t != "" {
+		parsed, err := parseTimeout(t)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid timeout (e.g. 25s)")
+			return
+		}
+		timeout = parsed
+	}
+	if timeout > maxPollTimeout {
+		timeout = maxPollTimeout
+	}
+
+	limit := defaultPollLimit
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxPollLimit {
+		limit = maxPollLimit
+	}
+
+	filter := whatsapp.ParseEventFilter(query.Get("events"))
+
+	// Answer right away on shutdown instead of holding the server open
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-h.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	events, err := h.manager.PollEvents(ctx, instanceID, cursor, limit, timeout)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to poll events")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	// Advance cursor to the last read event, or keep the current one. Events
+	// skipped by the filter count too, so the response may be empty.
+	nextCursor := cursor
+	if len(events) > 0 {
+		nextCursor = events[len(events)-1].ID
+	}
+	hasMore := len(events) == limit
+
+	if filter != nil {
+		allowed := make([]whatsapp.Event, 0, len(events))
+		for _, event := range events {
+			if filter.Allows(event.Type) {
+				allowed = append(allowed, event)
+			}
+		}
+		events = allowed
+	}
+
+	successResponse(w, map[string]interface{}{
+		"events":  events,
+		"cursor":  strconv.FormatInt(nextCursor, 10),
+		"hasMore": hasMore,
+	})
+}
+
+// StreamEvents streams events as Server-Sent Events, for consumers behind
+// proxies that break WebSockets. Resumes after the Last-Event-ID header (or
+// ?lastEventId=) sent by reconnecting clients.
+func (h *Handlers) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	var cursor int64
+	if lastEventID != "" {
+		parsed, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		cursor = parsed
+	}
+
+	rc := http.NewResponseController(w)
+
+	eventChan := h.manager.SubscribeStream(instanceID, "sse "+r.RemoteAddr)
+	defer h.manager.Unsubscribe(instanceID, eventChan)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+
+	// The stream outlives the server WriteTimeout, so extend it on every write
+	send := func(format string, args ...interface{}) error {
+		rc.SetWriteDeadline(time.Now().Add(2 * sseHeartbeat))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	stream := &eventStream{
+		manager:    h.manager,
+		ctx:        r.Context(),
+		instanceID: instanceID,
+		lastSent:   cursor,
+		filter:     whatsapp.ParseEventFilter(r.URL.Query().Get("events")),
+		write: func(event whatsapp.Event) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if event.ID == 0 {
+				return send("event: %s\ndata: %s\n\n", event.Type, data)
+			}
+			return send("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		},
+	}
+
+	// Send initial status, like the WebSocket
+	status, info := h.manager.GetStatus(instanceID)
+	_, qrBase64 := h.manager.GetQRCode(instanceID)
+	err := stream.send(whatsapp.Event{
+		Type:       "status",
+		InstanceID: instanceID,
+		Data: map[string]interface{}{
+			"status":   status,
+			"waNumber": info["waNumber"],
+			"waName":   info["waName"],
+			"qrCode":   qrBase64,
+		},
+	})
+	if err == nil && cursor > 0 {
+		err = stream.replay()
+	}
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to start SSE stream")
+		return
+	}
+
+	log.Info().Str("instanceId", instanceID).Int64("lastEventId", cursor).Msg("SSE client connected")
+
+	ticker := time.NewTicker(sseHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				return // Disconnected for falling behind; EventSource reconnects with Last-Event-ID
+			}
+			if err := stream.deliver(event, eventChan); err != nil {
+				log.Error().Err(err).Msg("Failed to write to SSE stream")
+				return
+			}
+
+		case <-ticker.C:
+			if err := send(": ping\n\n"); err != nil {
+				return
+			}
+
+		case <-r.Context().Done():
+			log.Info().Str("instanceId", instanceID).Msg("SSE client disconnected")
+			return
+
+		case <-h.closing:
+			return
+		}
+	}
+}
+
+// ReplayWebhookRequest represents a request to redeliver events to the webhook
+type ReplayWebhookRequest struct {
+	LastEventID int64 `json:"lastEventId"` // Events after this ID are delivered again
+}
+
+// ReplayWebhook makes the webhook worker resume from lastEventId, so events
+// missed while the receiver was down are delivered again (journal retention is 24h)
+func (h *Handlers) ReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req ReplayWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.LastEventID < 0 {
+		errorResponse(w, http.StatusBadRequest, "lastEventId must be >= 0")
+		return
+	}
+
+	if err := h.manager.ReplayWebhook(instanceID, req.LastEventID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"status":      "replaying",
+		"lastEventId": req.LastEventID,
+	})
+}
+
+// parseTimeout accepts a Go duration ("25s") or a plain number of seconds ("25")
+func parseTimeout(value string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, strconv.ErrRange
+		}
+		return time.Duration(secs) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, strconv.ErrRange
+	}
+	return d, nil
+}
@@ -0,0 +1,729 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Evolution API Compatibility
+// ============================================
+
+// Routes under /evolution accept the requests of Evolution API v2 (and the
+// v1 shapes where they differ) and answer in its response shapes, so clients
+// written for it only need a new base URL. Instances are addressed by
+// instanceName, which is the instance ID here.
+
+// Integration reported for every instance
+const evolutionIntegration = "WHATSAPP-BAILEYS"
+
+// How long instance/create and instance/connect wait for the first QR code
+const evolutionQRWait = 5 * time.Second
+
+// evolutionError writes an error in the shape Evolution API uses
+func evolutionError(w http.ResponseWriter, status int, message string) {
+	jsonResponse(w, status, map[string]interface{}{
+		"status":   status,
+		"error":    http.StatusText(status),
+		"response": map[string]interface{}{"message": []string{message}},
+	})
+}
+
+// evolutionManagerError writes a manager error in the Evolution API shape
+func evolutionManagerError(w http.ResponseWriter, err error) {
+	status, _ := managerErrorStatus(err)
+	evolutionError(w, status, err.Error())
+}
+
+// EvolutionAuth checks the apikey header (or any token requestToken accepts)
+// like Evolution API does: instance routes take the instance's token or the
+// admin token, the others only the admin token. Tenant API keys reach their
+// own instances and the routes that create and list instances. Without an
+// admin token, routes of instances that were never issued a token are open.
+func (h *Handlers) EvolutionAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("apikey")
+		if token == "" {
+			token = requestToken(r)
+		}
+
+		instanceName := mux.Vars(r)["instanceName"]
+		if instanceName == "" {
+			instanceName = r.URL.Query().Get("instanceName")
+		}
+
+		_, isTenant := h.manager.TenantForKey(token)
+
+		allowed := false
+		switch {
+		case instanceName != "":
+			allowed = h.instanceTokenValid(instanceName, token)
+		case h.adminToken == "", isTenant:
+			allowed = true
+		default:
+			allowed = subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+		}
+		if !allowed {
+			evolutionError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// evolutionState maps an instance status to an Evolution API connection state
+func evolutionState(status string) string {
+	switch status {
+	case "connected":
+		return "open"
+	case "qr", "connecting", "pairing":
+		return "connecting"
+	default:
+		return "close"
+	}
+}
+
+// evolutionJID turns a phone number into its JID, leaving JIDs alone
+func evolutionJID(number string) string {
+	if number == "" || strings.Contains(number, "@") {
+		return number
+	}
+	return number + "@s.whatsapp.net"
+}
+
+// EvolutionWebhook is the webhook configuration of Evolution API
+type EvolutionWebhook struct {
+	Enabled  *bool    `json:"enabled,omitempty"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events,omitempty"` // Evolution event names, e.g. MESSAGES_UPSERT
+	ByEvents bool     `json:"byEvents,omitempty"`
+	Base64   bool     `json:"base64,omitempty"`
+}
+
+// applyEvolutionWebhook points the webhook of an instance at url, delivering
+// Evolution API payloads
+func (h *Handlers) applyEvolutionWebhook(instanceID string, webhook EvolutionWebhook) error {
+	webhookURL := webhook.URL
+	if webhook.Enabled != nil && !*webhook.Enabled {
+		webhookURL = ""
+	}
+	if err := h.manager.SetWebhook(instanceID, webhookURL, ""); err != nil {
+		return err
+	}
+	if err := h.manager.SetWebhookFormat(instanceID, whatsapp.WebhookFormatEvolution); err != nil {
+		return err
+	}
+	return h.manager.SetWebhookEvents(instanceID, whatsapp.EvolutionEventTypes(webhook.Events))
+}
+
+// evolutionQRCode connects an instance and waits briefly for its QR code,
+// or requests a pairing code when a number is given
+func (h *Handlers) evolutionQRCode(r *http.Request, instanceID, number string) (map[string]interface{}, error) {
+	if number != "" {
+		code, err := h.manager.ConnectWithPairingCode(instanceID, cleanPhoneNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"pairingCode": code, "code": nil, "base64": nil, "count": 1}, nil
+	}
+
+	instance, err := h.manager.Connect(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	h.manager.WaitForStatus(r.Context(), instanceID, []string{"qr", "connected"}, evolutionQRWait)
+
+	instance.RLock()
+	status, code, qrBase64 := instance.Status, instance.QRCode, instance.QRCodeBase64
+	instance.RUnlock()
+	if status == "connected" {
+		return nil, nil
+	}
+	return map[string]interface{}{"pairingCode": nil, "code": code, "base64": qrBase64, "count": 1}, nil
+}
+
+// EvolutionCreateInstanceRequest is the body of instance/create
+type EvolutionCreateInstanceRequest struct {
+	InstanceName string `json:"instanceName"`
+	Token        string `json:"token,omitempty"`  // API token of the instance; one is generated when empty
+	QRCode       bool   `json:"qrcode,omitempty"` // Connect right away and return the QR code
+	Number       string `json:"number,omitempty"` // Pair with a code for this number instead of a QR code
+	Integration  string `json:"integration,omitempty"`
+
+	// Settings left out keep the instance defaults
+	RejectCall   *bool `json:"rejectCall,omitempty"`
+	GroupsIgnore *bool `json:"groupsIgnore,omitempty"`
+	AlwaysOnline *bool `json:"alwaysOnline,omitempty"`
+	ReadMessages *bool `json:"readMessages,omitempty"`
+
+	// v2 sends an object; v1 sends the URL with the events beside it
+	Webhook json.RawMessage `json:"webhook,omitempty"`
+	Events  []string        `json:"events,omitempty"`
+}
+
+// EvolutionCreateInstance creates an instance, optionally connecting it
+func (h *Handlers) EvolutionCreateInstance(w http.ResponseWriter, r *http.Request) {
+	var req EvolutionCreateInstanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.InstanceName == "" {
+		evolutionError(w, http.StatusBadRequest, "instanceName is required")
+		return
+	}
+	if req.Integration != "" && req.Integration != evolutionIntegration {
+		evolutionError(w, http.StatusBadRequest, "Only the "+evolutionIntegration+" integration is supported")
+		return
+	}
+
+	var webhook EvolutionWebhook
+	if len(req.Webhook) > 0 && string(req.Webhook) != "null" {
+		if err := json.Unmarshal(req.Webhook, &webhook); err != nil {
+			if err := json.Unmarshal(req.Webhook, &webhook.URL); err != nil {
+				evolutionError(w, http.StatusBadRequest, "webhook must be a URL or an object")
+				return
+			}
+			webhook.Events = req.Events
+		}
+	}
+
+	instanceID := req.InstanceName
+	log.Info().Str("instanceId", instanceID).Msg("Creating instance (Evolution API)")
+
+	// Checked and created at once, so concurrent requests can't both take the name
+	tenantID, _ := requestTenant(r.Context())
+	if _, err := h.manager.CreateInstance(tenantID, instanceID); err != nil {
+		if errors.Is(err, whatsapp.ErrInstanceExists) || errors.Is(err, whatsapp.ErrInstanceDeleted) {
+			evolutionError(w, http.StatusForbidden, `This name "`+instanceID+`" is already in use.`)
+			return
+		}
+		evolutionManagerError(w, err)
+		return
+	}
+	if req.RejectCall != nil {
+		h.manager.SetRejectCalls(instanceID, *req.RejectCall)
+	}
+	if req.GroupsIgnore != nil {
+		h.manager.SetIgnoreGroups(instanceID, *req.GroupsIgnore)
+	}
+	if req.AlwaysOnline != nil {
+		h.manager.SetAlwaysOnline(instanceID, *req.AlwaysOnline)
+	}
+	if req.ReadMessages != nil {
+		h.manager.SetReadMessages(instanceID, *req.ReadMessages)
+	}
+
+	if webhook.URL != "" {
+		if err := h.applyEvolutionWebhook(instanceID, webhook); err != nil {
+			evolutionManagerError(w, err)
+			return
+		}
+	}
+
+	token := req.Token
+	var err error
+	if token != "" {
+		err = h.manager.SetInstanceToken(instanceID, token)
+	} else {
+		token, err = h.manager.IssueInstanceToken(instanceID)
+	}
+	if err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	settings := h.manager.GetSettings(instanceID)
+	resp := map[string]interface{}{
+		"instance": map[string]interface{}{
+			"instanceName": instanceID,
+			"instanceId":   instanceID,
+			"integration":  evolutionIntegration,
+			"status":       "created",
+		},
+		"hash": token,
+		"settings": map[string]interface{}{
+			"rejectCall":   settings["rejectCalls"],
+			"groupsIgnore": settings["ignoreGroups"],
+			"alwaysOnline": settings["alwaysOnline"],
+			"readMessages": settings["readMessages"],
+		},
+	}
+	if webhook.URL != "" {
+		resp["webhook"] = map[string]interface{}{
+			"webhookUrl": webhook.URL,
+			"events":     whatsapp.EvolutionEventNames(whatsapp.EvolutionEventTypes(webhook.Events)),
+		}
+	}
+
+	if req.QRCode || req.Number != "" {
+		qrcode, err := h.evolutionQRCode(r, instanceID, req.Number)
+		if err != nil {
+			evolutionManagerError(w, err)
+			return
+		}
+		resp["qrcode"] = qrcode
+		resp["instance"].(map[string]interface{})["status"] = "connecting"
+	}
+
+	jsonResponse(w, http.StatusCreated, resp)
+}
+
+// EvolutionConnect connects an instance and returns its QR code, or a
+// pairing code with ?number=
+func (h *Handlers) EvolutionConnect(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	qrcode, err := h.evolutionQRCode(r, instanceID, r.URL.Query().Get("number"))
+	if err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+	if qrcode == nil {
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"instance": map[string]string{"instanceName": instanceID, "state": "open"},
+		})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, qrcode)
+}
+
+// EvolutionConnectionState returns the connection state of an instance
+func (h *Handlers) EvolutionConnectionState(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	status, _ := h.manager.GetStatus(instanceID)
+	if status == "not_found" || status == "deleted" {
+		evolutionError(w, http.StatusNotFound, `The "`+instanceID+`" instance does not exist`)
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"instance": map[string]string{"instanceName": instanceID, "state": evolutionState(status)},
+	})
+}
+
+// EvolutionFetchInstances lists the instances, or one with ?instanceName=
+func (h *Handlers) EvolutionFetchInstances(w http.ResponseWriter, r *http.Request) {
+	instances, err := h.manager.InstancesHealth()
+	if err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	only := r.URL.Query().Get("instanceName")
+	result := make([]map[string]interface{}, 0, len(instances))
+	for _, inst := range instances {
+		if (only != "" && inst.InstanceID != only) || !h.tenantCanSee(r.Context(), inst.InstanceID) {
+			continue
+		}
+		_, info := h.manager.GetStatus(inst.InstanceID)
+		var ownerJID interface{}
+		if info["waNumber"] != "" {
+			ownerJID = evolutionJID(info["waNumber"])
+		}
+		result = append(result, map[string]interface{}{
+			"id":               inst.InstanceID,
+			"name":             inst.InstanceID,
+			"connectionStatus": evolutionState(inst.Status),
+			"ownerJid":         ownerJID,
+			"profileName":      info["waName"],
+			"integration":      evolutionIntegration,
+		})
+	}
+
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// evolutionSuccess answers instance operations the way Evolution API does
+func evolutionSuccess(w http.ResponseWriter, message string) {
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"status":   "SUCCESS",
+		"error":    false,
+		"response": map[string]string{"message": message},
+	})
+}
+
+// EvolutionLogout logs an instance out, keeping it for the soft delete grace period
+func (h *Handlers) EvolutionLogout(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	if err := h.manager.Logout(instanceID); err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSuccess(w, "Instance logged out")
+}
+
+// EvolutionDeleteInstance logs an instance out and removes it right away
+func (h *Handlers) EvolutionDeleteInstance(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	if err := h.manager.DeleteInstance(instanceID); err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSuccess(w, "Instance deleted")
+}
+
+// evolutionSent answers a send with the key of the new message
+func evolutionSent(w http.ResponseWriter, number, messageID string, message map[string]interface{}) {
+	jsonResponse(w, http.StatusCreated, map[string]interface{}{
+		"key":              whatsapp.EvolutionKey(number, true, messageID, ""),
+		"message":          message,
+		"messageTimestamp": time.Now().Unix(),
+		"status":           "PENDING",
+	})
+}
+
+// EvolutionSendTextRequest is the body of message/sendText
+type EvolutionSendTextRequest struct {
+	Number      string `json:"number"`
+	Text        string `json:"text"`
+	LinkPreview *bool  `json:"linkPreview,omitempty"`
+
+	// v1 shape
+	TextMessage *struct {
+		Text string `json:"text"`
+	} `json:"textMessage,omitempty"`
+}
+
+// EvolutionSendText sends a text message
+func (h *Handlers) EvolutionSendText(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSendTextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.TextMessage != nil && req.Text == "" {
+		req.Text = req.TextMessage.Text
+	}
+	if req.Number == "" || req.Text == "" {
+		evolutionError(w, http.StatusBadRequest, "number and text are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.Number)
+	preview := whatsapp.PreviewOptions{Disabled: req.LinkPreview != nil && !*req.LinkPreview}
+	messageID, err := h.manager.SendTextMessage(sendContext(r), instanceID, to, req.Text, preview)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to send message (Evolution API)")
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSent(w, to, messageID, map[string]interface{}{"conversation": req.Text})
+}
+
+// EvolutionSendMediaRequest is the body of message/sendMedia
+type EvolutionSendMediaRequest struct {
+	Number    string `json:"number"`
+	MediaType string `json:"mediatype"` // image, video, audio or document
+	Mimetype  string `json:"mimetype,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	Media     string `json:"media"` // URL or base64
+	FileName  string `json:"fileName,omitempty"`
+
+	// v1 shape
+	MediaMessage *EvolutionSendMediaRequest `json:"mediaMessage,omitempty"`
+}
+
+// evolutionMediaURL turns bare base64 media into a data URI SendMediaMessage reads
+func evolutionMediaURL(media, mimetype string) string {
+	if strings.HasPrefix(media, "http://") || strings.HasPrefix(media, "https://") || strings.HasPrefix(media, "data:") {
+		return media
+	}
+	if mimetype == "" {
+		mimetype = "application/octet-stream"
+	}
+	return "data:" + mimetype + ";base64," + media
+}
+
+// EvolutionSendMedia sends an image, video, audio or document
+func (h *Handlers) EvolutionSendMedia(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSendMediaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if m := req.MediaMessage; m != nil {
+		number := req.Number
+		req = *m
+		req.Number = number
+	}
+	if req.Number == "" || req.Media == "" {
+		evolutionError(w, http.StatusBadRequest, "number and media are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.Number)
+	messageID, err := h.manager.SendMediaMessage(sendContext(r), instanceID, to, evolutionMediaURL(req.Media, req.Mimetype), req.Caption, req.MediaType, whatsapp.MediaOptions{
+		FileName: req.FileName,
+		Mimetype: req.Mimetype,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to send media message (Evolution API)")
+		evolutionManagerError(w, err)
+		return
+	}
+
+	mediaType := req.MediaType
+	if mediaType == "" {
+		mediaType = "document"
+	}
+	message := map[string]interface{}{"mimetype": req.Mimetype}
+	if req.Caption != "" {
+		message["caption"] = req.Caption
+	}
+	evolutionSent(w, to, messageID, map[string]interface{}{mediaType + "Message": message})
+}
+
+// EvolutionSendAudioRequest is the body of message/sendWhatsAppAudio
+type EvolutionSendAudioRequest struct {
+	Number string `json:"number"`
+	Audio  string `json:"audio"` // URL or base64
+
+	// v1 shape
+	AudioMessage *struct {
+		Audio string `json:"audio"`
+	} `json:"audioMessage,omitempty"`
+}
+
+// EvolutionSendAudio sends an audio message
+func (h *Handlers) EvolutionSendAudio(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSendAudioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.AudioMessage != nil && req.Audio == "" {
+		req.Audio = req.AudioMessage.Audio
+	}
+	if req.Number == "" || req.Audio == "" {
+		evolutionError(w, http.StatusBadRequest, "number and audio are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.Number)
+	messageID, err := h.manager.SendMediaMessage(sendContext(r), instanceID, to, evolutionMediaURL(req.Audio, "audio/ogg"), "", "audio", whatsapp.MediaOptions{})
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to send audio message (Evolution API)")
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSent(w, to, messageID, map[string]interface{}{"audioMessage": map[string]interface{}{}})
+}
+
+// EvolutionSendLocationRequest is the body of message/sendLocation
+type EvolutionSendLocationRequest struct {
+	Number    string  `json:"number"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// v1 shape
+	LocationMessage *EvolutionSendLocationRequest `json:"locationMessage,omitempty"`
+}
+
+// EvolutionSendLocation sends a location
+func (h *Handlers) EvolutionSendLocation(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSendLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if m := req.LocationMessage; m != nil {
+		number := req.Number
+		req = *m
+		req.Number = number
+	}
+	if req.Number == "" {
+		evolutionError(w, http.StatusBadRequest, "number is required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.Number)
+	messageID, err := h.manager.SendLocationMessage(instanceID, to, whatsapp.Location{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Name:      req.Name,
+		Address:   req.Address,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to send location message (Evolution API)")
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSent(w, to, messageID, map[string]interface{}{
+		"locationMessage": map[string]interface{}{
+			"degreesLatitude":  req.Latitude,
+			"degreesLongitude": req.Longitude,
+			"name":             req.Name,
+			"address":          req.Address,
+		},
+	})
+}
+
+// EvolutionMessageKey references a message the Baileys way
+type EvolutionMessageKey struct {
+	RemoteJID   string `json:"remoteJid"`
+	FromMe      bool   `json:"fromMe"`
+	ID          string `json:"id"`
+	Participant string `json:"participant,omitempty"`
+}
+
+// EvolutionSendReactionRequest is the body of message/sendReaction
+type EvolutionSendReactionRequest struct {
+	Key      EvolutionMessageKey `json:"key"`
+	Reaction string              `json:"reaction"` // Empty removes the reaction
+
+	// v1 shape
+	ReactionMessage *EvolutionSendReactionRequest `json:"reactionMessage,omitempty"`
+}
+
+// EvolutionSendReaction reacts to a message
+func (h *Handlers) EvolutionSendReaction(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSendReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ReactionMessage != nil {
+		req = *req.ReactionMessage
+	}
+	if req.Key.RemoteJID == "" || req.Key.ID == "" {
+		evolutionError(w, http.StatusBadRequest, "key.remoteJid and key.id are required")
+		return
+	}
+
+	chatID := cleanPhoneNumber(req.Key.RemoteJID)
+	if err := h.manager.ReactToMessage(instanceID, chatID, req.Key.ID, req.Reaction, req.Key.Participant); err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to send reaction (Evolution API)")
+		evolutionManagerError(w, err)
+		return
+	}
+
+	evolutionSent(w, chatID, "", map[string]interface{}{
+		"reactionMessage": map[string]interface{}{"key": req.Key, "text": req.Reaction},
+	})
+}
+
+// EvolutionNumbersRequest is the body of chat/whatsappNumbers
+type EvolutionNumbersRequest struct {
+	Numbers []string `json:"numbers"`
+}
+
+// EvolutionWhatsAppNumbers checks which numbers have WhatsApp
+func (h *Handlers) EvolutionWhatsAppNumbers(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionNumbersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Numbers) == 0 {
+		evolutionError(w, http.StatusBadRequest, "numbers is required")
+		return
+	}
+
+	results, err := h.manager.CheckNumbers(r.Context(), instanceID, req.Numbers)
+	if err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	resp := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		resp[i] = map[string]interface{}{
+			"exists": result.IsOnWhatsApp,
+			"jid":    result.JID,
+			"number": result.Number,
+		}
+	}
+	jsonResponse(w, http.StatusOK, resp)
+}
+
+// EvolutionSetWebhookRequest is the body of webhook/set: v2 nests the
+// configuration under "webhook", v1 sends it at the top level
+type EvolutionSetWebhookRequest struct {
+	EvolutionWebhook
+	Webhook *EvolutionWebhook `json:"webhook,omitempty"`
+}
+
+// EvolutionSetWebhook configures the webhook of an instance, delivering
+// Evolution API payloads
+func (h *Handlers) EvolutionSetWebhook(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceName"]
+
+	var req EvolutionSetWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		evolutionError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	webhook := req.EvolutionWebhook
+	if req.Webhook != nil {
+		webhook = *req.Webhook
+	}
+	if webhook.URL == "" && (webhook.Enabled == nil || *webhook.Enabled) {
+		evolutionError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	if err := h.applyEvolutionWebhook(instanceID, webhook); err != nil {
+		evolutionManagerError(w, err)
+		return
+	}
+
+	h.writeEvolutionWebhook(w, instanceID)
+}
+
+// EvolutionFindWebhook returns the webhook of an instance
+func (h *Handlers) EvolutionFindWebhook(w http.ResponseWriter, r *http.Request) {
+	h.writeEvolutionWebhook(w, mux.Vars(r)["instanceName"])
+}
+
+func (h *Handlers) writeEvolutionWebhook(w http.ResponseWriter, instanceID string) {
+	if status, _ := h.manager.GetStatus(instanceID); status == "not_found" {
+		evolutionManagerError(w, whatsapp.ErrInstanceNotFound)
+		return
+	}
+
+	settings := h.manager.GetSettings(instanceID)
+	webhookURL, _ := settings["webhookUrl"].(string)
+	eventTypes, _ := settings["webhookEvents"].([]string)
+
+	jsonResponse(w, http.StatusOK, map[string]interface{}{
+		"enabled":         webhookURL != "",
+		"url":             webhookURL,
+		"events":          whatsapp.EvolutionEventNames(eventTypes),
+		"webhookByEvents": false,
+		"webhookBase64":   false,
+	})
+}
@@ -0,0 +1,556 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// GraphQL Handlers
+// ============================================
+
+// graphQLSchema is read-only: chats, messages and contacts from the stores,
+// plus a subscription for live events
+const graphQLSchema = `
+"Unix timestamps and sizes that may exceed 32 bits"
+scalar Long
+
+"Arbitrary JSON (event payloads)"
+scalar JSON
+
+type Query {
+  "An instance and its connection state"
+  instance(id: ID!): Instance
+  "Chats ordered by last activity"
+  chats(instanceId: ID!, unread: Boolean = false, first: Int = 0): [Chat!]!
+  "Stored messages of a chat, newest first"
+  messages(instanceId: ID!, chatId: ID!, first: Int = 20, after: String): MessageConnection!
+  "Contacts synced from the phone"
+  contacts(instanceId: ID!): [Contact!]!
+}
+
+type Subscription {
+  "Live events of an instance, optionally only some types (\"message\", \"call*\")"
+  events(instanceId: ID!, types: [String!]): Event!
+}
+
+type Instance {
+  id: ID!
+  status: String!
+  connected: Boolean!
+  waNumber: String
+  waName: String
+}
+
+type Chat {
+  id: ID!
+  name: String!
+  isGroup: Boolean!
+  pushName: String
+  lastMessage: ChatLastMessage
+  lastActivity: Long!
+  unreadCount: Int!
+  markedUnread: Boolean!
+}
+
+type ChatLastMessage {
+  id: ID!
+  from: String!
+  preview: String
+  type: String!
+  fromMe: Boolean!
+  timestamp: Long!
+}
+
+type MessageConnection {
+  edges: [MessageEdge!]!
+  pageInfo: PageInfo!
+}
+
+type MessageEdge {
+  cursor: String!
+  node: Message!
+}
+
+type PageInfo {
+  hasNextPage: Boolean!
+  endCursor: String
+}
+
+type Message {
+  id: ID!
+  from: String!
+  to: String!
+  body: String!
+  type: String!
+  timestamp: Long!
+  fromMe: Boolean!
+  isGroup: Boolean!
+  pushName: String
+  resolvedPhone: String
+  mediaUrl: String
+  mediaPending: Boolean!
+  mimetype: String
+  caption: String
+  fileName: String
+  fileLength: Long
+  starred: Boolean!
+  revoked: Boolean!
+  revokedAt: Long
+  viewOnce: Boolean!
+  edits: [MessageEdit!]!
+  reactions: [MessageReaction!]!
+  tags: [String!]!
+  location: Location
+  poll: Poll
+}
+
+type MessageEdit {
+  body: String!
+  editedAt: Long!
+}
+
+type MessageReaction {
+  sender: String!
+  emoji: String!
+  fromMe: Boolean!
+  timestamp: Long!
+}
+
+type Location {
+  latitude: Float!
+  longitude: Float!
+  name: String
+  address: String
+  live: Boolean!
+}
+
+type Poll {
+  question: String!
+  options: [String!]!
+  selectableCount: Int!
+}
+
+type Contact {
+  jid: ID!
+  name: String
+  pushName: String
+  phone: String
+}
+
+type Event {
+  "Journal sequence number"
+  id: Long
+  type: String!
+  instanceId: ID!
+  timestamp: Long!
+  data: JSON
+}
+`
+
+// Messages returned per page at most
+const maxGraphQLPage = 100
+
+// newGraphQL loads the schema and binds the query resolvers to the manager
+func (h *Handlers) newGraphQL() *gqlExecutor {
+	schema, err := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphql", Input: graphQLSchema})
+	if err != nil {
+		panic(err)
+	}
+
+	return &gqlExecutor{
+		schema: schema,
+		resolvers: map[string]gqlResolver{
+			"instance": h.resolveInstance,
+			"chats":    h.resolveChats,
+			"messages": h.resolveMessages,
+			"contacts": h.resolveContacts,
+		},
+	}
+}
+
+func (h *Handlers) resolveInstance(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	instanceID, _ := args["id"].(string)
+	status, info := h.manager.GetStatus(instanceID)
+	if status == "not_found" || !h.tenantCanSee(ctx, instanceID) {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"id":        instanceID,
+		"status":    status,
+		"connected": status == "connected",
+		"waNumber":  info["waNumber"],
+		"waName":    info["waName"],
+	}, nil
+}
+
+func (h *Handlers) resolveChats(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	instanceID, _ := args["instanceId"].(string)
+	unread, _ := args["unread"].(bool)
+	if !h.tenantCanSee(ctx, instanceID) {
+		return nil, whatsapp.ErrInstanceNotFound
+	}
+	return h.manager.GetChats(instanceID, unread, gqlInt(args["first"]))
+}
+
+// resolveMessages pages through a chat from the newest message back. Cursors
+// are opaque: the base64 of the message ID.
+func (h *Handlers) resolveMessages(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	instanceID, _ := args["instanceId"].(string)
+	chatID, _ := args["chatId"].(string)
+	first := gqlInt(args["first"])
+	after, _ := args["after"].(string)
+	if first <= 0 || first > maxGraphQLPage {
+		first = maxGraphQLPage
+	}
+
+	if _, ok := h.manager.GetInstance(instanceID); !ok || !h.tenantCanSee(ctx, instanceID) {
+		return nil, whatsapp.ErrInstanceNotFound
+	}
+	messages, err := h.manager.GetChatMessages(instanceID, chatID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index of the newest message to return
+	start := len(messages) - 1
+	if after != "" {
+		afterID, err := base64.RawURLEncoding.DecodeString(after)
+		if err != nil {
+			return nil, gqlerror.Errorf("invalid cursor")
+		}
+		start = -1
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].ID == string(afterID) {
+				start = i - 1
+				break
+			}
+		}
+	}
+
+	edges := make([]map[string]interface{}, 0, first)
+	var endCursor interface{}
+	i := start
+	for ; i >= 0 && len(edges) < first; i-- {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(messages[i].ID))
+		edges = append(edges, map[string]interface{}{
+			"cursor": cursor,
+			"node":   messages[i],
+		})
+		endCursor = cursor
+	}
+
+	return map[string]interface{}{
+		"edges": edges,
+		"pageInfo": map[string]interface{}{
+			"hasNextPage": i >= 0,
+			"endCursor":   endCursor,
+		},
+	}, nil
+}
+
+// gqlInt reads an Int argument, given as a literal or a JSON variable
+func gqlInt(value interface{}) int {
+	switch n := value.(type) {
+	case int64:
+		return int(n)
+	case int:
+		return n
+	case float64:
+		return int(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	}
+	return 0
+}
+
+func (h *Handlers) resolveContacts(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+	instanceID, _ := args["instanceId"].(string)
+	if !h.tenantCanSee(ctx, instanceID) {
+		return nil, whatsapp.ErrInstanceNotFound
+	}
+	return h.manager.GetContacts(instanceID)
+}
+
+// GraphQL runs queries sent as POST JSON or GET ?query=, and upgrades GET
+// requests to a WebSocket for subscriptions (graphql-transport-ws protocol)
+func (h *Handlers) GraphQL(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		h.graphQLWebSocket(w, r)
+		return
+	}
+
+	var req gqlRequest
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if vars := query.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				errorResponse(w, http.StatusBadRequest, "Invalid variables")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	op, errs := h.graphql.prepare(req)
+	if len(errs) > 0 {
+		jsonResponse(w, http.StatusBadRequest, gqlResponse{Errors: errs})
+		return
+	}
+	if op.op.Operation != ast.Query {
+		jsonResponse(w, http.StatusBadRequest, gqlResponse{Errors: gqlerror.List{gqlerror.Errorf("subscriptions need a WebSocket connection")}})
+		return
+	}
+
+	jsonResponse(w, http.StatusOK, h.graphql.execute(r.Context(), op))
+}
+
+// gqlMessage is a graphql-transport-ws protocol message
+type gqlMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// graphql-transport-ws close codes
+const (
+	gqlCloseBadRequest    = 4400
+	gqlCloseUnauthorized  = 4401
+	gqlCloseInitTimeout   = 4408
+	gqlCloseDuplicateID   = 4409
+	gqlCloseTooManyInits  = 4429
+	gqlConnectionInitWait = 10 * time.Second
+)
+
+// graphQLWebSocket serves the graphql-transport-ws protocol: queries and
+// event subscriptions multiplexed over one connection
+func (h *Handlers) graphQLWebSocket(w http.ResponseWriter, r *http.Request) {
+	header := http.Header{}
+	if websocket.Subprotocols(r) != nil {
+		header.Set("Sec-WebSocket-Protocol", "graphql-transport-ws")
+	}
+	conn, err := h.upgrader.Upgrade(w, r, header)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade GraphQL WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var writeMu sync.Mutex
+	send := func(msg gqlMessage) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(msg)
+	}
+	closeWith := func(code int, reason string) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	}
+
+	// Token for subscriptions, from the upgrade request or connection_init
+	token := requestToken(r)
+	initialized := false
+	subscriptions := make(map[string]context.CancelFunc)
+	var subsMu sync.Mutex
+	finished := func(id string) {
+		subsMu.Lock()
+		delete(subscriptions, id)
+		subsMu.Unlock()
+	}
+
+	conn.SetReadDeadline(time.Now().Add(gqlConnectionInitWait))
+	go func() {
+		select {
+		case <-h.closing:
+			closeWith(websocket.CloseGoingAway, "server shutting down")
+			conn.Close()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		var msg gqlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if !initialized {
+				closeWith(gqlCloseInitTimeout, "Connection initialisation timeout")
+			}
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			if initialized {
+				closeWith(gqlCloseTooManyInits, "Too many initialisation requests")
+				return
+			}
+			var payload struct {
+				Token         string `json:"token"`
+				Authorization string `json:"Authorization"`
+			}
+			json.Unmarshal(msg.Payload, &payload)
+			if payload.Token != "" {
+				token = payload.Token
+			} else if payload.Authorization != "" {
+				token = strings.TrimPrefix(payload.Authorization, "Bearer ")
+			}
+			initialized = true
+			conn.SetReadDeadline(time.Time{})
+			send(gqlMessage{Type: "connection_ack"})
+
+		case "ping":
+			send(gqlMessage{Type: "pong"})
+
+		case "pong":
+
+		case "subscribe":
+			if !initialized {
+				closeWith(gqlCloseUnauthorized, "Unauthorized")
+				return
+			}
+			var req gqlRequest
+			if err := json.Unmarshal(msg.Payload, &req); err != nil || msg.ID == "" {
+				closeWith(gqlCloseBadRequest, "Invalid subscribe message")
+				return
+			}
+
+			subsMu.Lock()
+			_, duplicate := subscriptions[msg.ID]
+			subCtx, subCancel := context.WithCancel(ctx)
+			if !duplicate {
+				subscriptions[msg.ID] = subCancel
+			}
+			subsMu.Unlock()
+			if duplicate {
+				subCancel()
+				closeWith(gqlCloseDuplicateID, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+
+			go func(id string) {
+				defer finished(id)
+				defer subCancel()
+				h.runGraphQLOperation(subCtx, id, req, token, r.RemoteAddr, send)
+			}(msg.ID)
+
+		case "complete":
+			subsMu.Lock()
+			if stop, ok := subscriptions[msg.ID]; ok {
+				stop()
+				delete(subscriptions, msg.ID)
+			}
+			subsMu.Unlock()
+
+		default:
+			closeWith(gqlCloseBadRequest, "Unknown message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// runGraphQLOperation answers one subscribe message: a query gets a single
+// result, a subscription streams events until ctx ends
+func (h *Handlers) runGraphQLOperation(ctx context.Context, id string, req gqlRequest, token, remote string, send func(gqlMessage) error) {
+	sendErrors := func(errs gqlerror.List) {
+		payload, _ := json.Marshal(errs)
+		send(gqlMessage{Type: "error", ID: id, Payload: payload})
+	}
+	sendResult := func(result gqlResponse) error {
+		payload, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		return send(gqlMessage{Type: "next", ID: id, Payload: payload})
+	}
+
+	op, errs := h.graphql.prepare(req)
+	if len(errs) > 0 {
+		sendErrors(errs)
+		return
+	}
+
+	if op.op.Operation != ast.Subscription {
+		if sendResult(h.graphql.execute(ctx, op)) == nil {
+			send(gqlMessage{Type: "complete", ID: id})
+		}
+		return
+	}
+
+	fields := h.graphql.collectFields(op, op.op.SelectionSet)
+	if len(fields) != 1 {
+		sendErrors(gqlerror.List{gqlerror.Errorf("subscriptions must select exactly one field")})
+		return
+	}
+	field := fields[0]
+	key := field.Alias
+	if key == "" {
+		key = field.Name
+	}
+
+	args := field.ArgumentMap(op.vars)
+	instanceID, _ := args["instanceId"].(string)
+	if !h.instanceTokenValid(instanceID, token) {
+		sendErrors(gqlerror.List{&gqlerror.Error{Message: "Invalid instance token", Extensions: map[string]interface{}{"code": CodeUnauthorized}}})
+		return
+	}
+
+	var types []string
+	if list, ok := args["types"].([]interface{}); ok {
+		for _, t := range list {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	filter := whatsapp.NewEventFilter(types)
+
+	eventChan := h.manager.SubscribeStream(instanceID, "graphql "+remote)
+	defer h.manager.Unsubscribe(instanceID, eventChan)
+
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				sendErrors(gqlerror.List{gqlerror.Errorf("subscriber too slow, resubscribe")})
+				return
+			}
+			if !filter.Allows(event.Type) {
+				continue
+			}
+			data := newGQLObject()
+			projected, err := h.graphql.project(op, field.SelectionSet, field.Definition.Type, event)
+			if err != nil {
+				log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to project GraphQL event")
+				continue
+			}
+			data.set(key, projected)
+			if err := sendResult(gqlResponse{Data: data}); err != nil {
+				return
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// ============================================
+// GraphQL Executor
+// ============================================
+
+// gqlResolver resolves a root field from its arguments. It returns plain Go
+// values (the manager's types); their JSON encoding is projected onto the
+// selection set, so GraphQL field names follow the JSON tags.
+type gqlResolver func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+
+// gqlExecutor runs operations validated against a schema
+type gqlExecutor struct {
+	schema    *ast.Schema
+	resolvers map[string]gqlResolver // Query field -> resolver
+}
+
+// gqlRequest is a GraphQL request, over HTTP or in a WebSocket subscribe message
+type gqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// gqlResponse is the result of an operation
+type gqlResponse struct {
+	Data   interface{}   `json:"data,omitempty"` // Absent when the request is rejected before execution
+	Errors gqlerror.List `json:"errors,omitempty"`
+}
+
+// gqlOperation is a parsed and validated operation, ready to run
+type gqlOperation struct {
+	doc  *ast.QueryDocument
+	op   *ast.OperationDefinition
+	vars map[string]interface{}
+}
+
+// prepare parses and validates a request and coerces its variables
+func (e *gqlExecutor) prepare(req gqlRequest) (*gqlOperation, gqlerror.List) {
+	doc, errs := gqlparser.LoadQuery(e.schema, req.Query)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	op := doc.Operations.ForName(req.OperationName)
+	if op == nil {
+		return nil, gqlerror.List{gqlerror.Errorf("operation %q not found", req.OperationName)}
+	}
+	vars, err := validator.VariableValues(e.schema, op, req.Variables)
+	if err != nil {
+		if gqlErr, ok := err.(*gqlerror.Error); ok {
+			return nil, gqlerror.List{gqlErr}
+		}
+		return nil, gqlerror.List{gqlerror.Wrap(err)}
+	}
+	return &gqlOperation{doc: doc, op: op, vars: vars}, nil
+}
+
+// execute runs a query operation, resolving each root field
+func (e *gqlExecutor) execute(ctx context.Context, op *gqlOperation) gqlResponse {
+	var errs gqlerror.List
+	data := newGQLObject()
+
+	for _, field := range e.collectFields(op, op.op.SelectionSet) {
+		key := field.Alias
+		if key == "" {
+			key = field.Name
+		}
+
+		var value interface{}
+		var err error
+		switch field.Name {
+		case "__typename":
+			value = e.schema.Query.Name
+		case "__schema":
+			value = e.introspectSchema()
+		case "__type":
+			name, _ := field.ArgumentMap(op.vars)["name"].(string)
+			if def := e.schema.Types[name]; def != nil {
+				value = e.introspectType(def)
+			}
+		default:
+			resolve := e.resolvers[field.Name]
+			if resolve == nil {
+				err = fmt.Errorf("no resolver for %s", field.Name)
+				break
+			}
+			value, err = resolve(ctx, field.ArgumentMap(op.vars))
+		}
+		if err != nil {
+			errs = append(errs, gqlFieldError(err, key))
+			data.set(key, nil)
+			continue
+		}
+
+		projected, err := e.project(op, field.SelectionSet, field.Definition.Type, value)
+		if err != nil {
+			errs = append(errs, gqlFieldError(err, key))
+		}
+		data.set(key, projected)
+	}
+
+	return gqlResponse{Data: data, Errors: errs}
+}
+
+// gqlFieldError turns a resolver error into a GraphQL error, carrying the
+// same machine-readable code as REST error responses
+func gqlFieldError(err error, path string) *gqlerror.Error {
+	gqlErr := gqlerror.WrapPath(ast.Path{ast.PathName(path)}, err)
+	gqlErr.Message = err.Error()
+	_, code := managerErrorStatus(err)
+	gqlErr.Extensions = map[string]interface{}{"code": code}
+	return gqlErr
+}
+
+// collectFields flattens fragments and applies @skip/@include
+func (e *gqlExecutor) collectFields(op *gqlOperation, set ast.SelectionSet) []*ast.Field {
+	var fields []*ast.Field
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if e.included(op, sel.Directives) {
+				fields = append(fields, sel)
+			}
+		case *ast.InlineFragment:
+			if e.included(op, sel.Directives) {
+				fields = append(fields, e.collectFields(op, sel.SelectionSet)...)
+			}
+		case *ast.FragmentSpread:
+			if e.included(op, sel.Directives) && sel.Definition != nil {
+				fields = append(fields, e.collectFields(op, sel.Definition.SelectionSet)...)
+			}
+		}
+	}
+	return fields
+}
+
+func (e *gqlExecutor) included(op *gqlOperation, directives ast.DirectiveList) bool {
+	if d := directives.ForName("skip"); d != nil {
+		if skip, _ := d.ArgumentMap(op.vars)["if"].(bool); skip {
+			return false
+		}
+	}
+	if d := directives.ForName("include"); d != nil {
+		if include, _ := d.ArgumentMap(op.vars)["if"].(bool); !include {
+			return false
+		}
+	}
+	return true
+}
+
+// project shapes a resolved value after the selection set. Go values are
+// first converted to their JSON form; values may also be funcs, resolved only
+// when selected (introspection types refer to each other).
+func (e *gqlExecutor) project(op *gqlOperation, set ast.SelectionSet, typ *ast.Type, value interface{}) (interface{}, error) {
+	if lazy, ok := value.(func() interface{}); ok {
+		value = lazy()
+	}
+	if value != nil {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, []map[string]interface{}, string, bool, json.Number, nil:
+		default:
+			generic, err := toGeneric(value)
+			if err != nil {
+				return nil, err
+			}
+			value = generic
+		}
+	}
+
+	if value == nil {
+		// Fields left out by omitempty hold their zero value
+		if typ.NonNull {
+			return gqlZero(typ), nil
+		}
+		return nil, nil
+	}
+
+	if typ.Elem != nil {
+		var items []interface{}
+		switch list := value.(type) {
+		case []interface{}:
+			items = list
+		case []map[string]interface{}:
+			for _, item := range list {
+				items = append(items, item)
+			}
+		default:
+			return nil, fmt.Errorf("expected a list for %s", typ.String())
+		}
+		result := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			projected, err := e.project(op, set, typ.Elem, item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, projected)
+		}
+		return result, nil
+	}
+
+	def := e.schema.Types[typ.Name()]
+	if def == nil || def.IsLeafType() {
+		return value, nil
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object for %s", typ.Name())
+	}
+	result := newGQLObject()
+	for _, field := range e.collectFields(op, set) {
+		key := field.Alias
+		if key == "" {
+			key = field.Name
+		}
+		if field.Name == "__typename" {
+			result.set(key, def.Name)
+			continue
+		}
+		projected, err := e.project(op, field.SelectionSet, field.Definition.Type, object[field.Name])
+		if err != nil {
+			return nil, err
+		}
+		result.set(key, projected)
+	}
+	return result, nil
+}
+
+// toGeneric converts a Go value to maps, slices and JSON numbers
+func toGeneric(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// gqlZero returns the value of a non-null field missing from the JSON form
+func gqlZero(typ *ast.Type) interface{} {
+	if typ.Elem != nil {
+		return []interface{}{}
+	}
+	switch typ.Name() {
+	case "Boolean":
+		return false
+	case "Int", "Float", "Long":
+		return json.Number("0")
+	case "String", "ID":
+		return ""
+	}
+	return nil
+}
+
+// gqlObject keeps the fields of a result in selection order, as the spec
+// requires (Go maps are encoded in key order)
+type gqlObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newGQLObject() *gqlObject {
+	return &gqlObject{values: make(map[string]interface{})}
+}
+
+func (o *gqlObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// ============================================
+// Introspection
+// ============================================
+
+// introspectSchema describes the schema for __schema queries (GraphiQL,
+// code generators)
+func (e *gqlExecutor) introspectSchema() map[string]interface{} {
+	schema := map[string]interface{}{
+		"description":  e.schema.Description,
+		"queryType":    e.introspectType(e.schema.Query),
+		"mutationType": nil,
+		"types": func() interface{} {
+			names := make([]string, 0, len(e.schema.Types))
+			for name := range e.schema.Types {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			types := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				types = append(types, e.introspectType(e.schema.Types[name]))
+			}
+			return types
+		},
+		"directives": func() interface{} {
+			names := make([]string, 0, len(e.schema.Directives))
+			for name := range e.schema.Directives {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			directives := make([]interface{}, 0, len(names))
+			for _, name := range names {
+				d := e.schema.Directives[name]
+				locations := make([]interface{}, 0, len(d.Locations))
+				for _, location := range d.Locations {
+					locations = append(locations, string(location))
+				}
+				directives = append(directives, map[string]interface{}{
+					"name":         d.Name,
+					"description":  d.Description,
+					"locations":    locations,
+					"args":         e.introspectArgs(d.Arguments),
+					"isRepeatable": d.IsRepeatable,
+				})
+			}
+			return directives
+		},
+	}
+	if e.schema.Subscription != nil {
+		schema["subscriptionType"] = e.introspectType(e.schema.Subscription)
+	}
+	return schema
+}
+
+// introspectType describes a named type; nested parts are resolved lazily
+func (e *gqlExecutor) introspectType(def *ast.Definition) map[string]interface{} {
+	typ := map[string]interface{}{
+		"kind":        string(def.Kind),
+		"name":        def.Name,
+		"description": def.Description,
+	}
+
+	switch def.Kind {
+	case ast.Object, ast.Interface:
+		typ["fields"] = func() interface{} {
+			fields := make([]interface{}, 0, len(def.Fields))
+			for _, field := range def.Fields {
+				if strings.HasPrefix(field.Name, "__") {
+					continue
+				}
+				fields = append(fields, map[string]interface{}{
+					"name":              field.Name,
+					"description":       field.Description,
+					"args":              e.introspectArgs(field.Arguments),
+					"type":              e.introspectTypeRef(field.Type),
+					"isDeprecated":      field.Directives.ForName("deprecated") != nil,
+					"deprecationReason": nil,
+				})
+			}
+			return fields
+		}
+		typ["interfaces"] = func() interface{} {
+			interfaces := make([]interface{}, 0, len(def.Interfaces))
+			for _, name := range def.Interfaces {
+				interfaces = append(interfaces, e.introspectType(e.schema.Types[name]))
+			}
+			return interfaces
+		}
+	case ast.InputObject:
+		typ["inputFields"] = func() interface{} {
+			fields := make([]interface{}, 0, len(def.Fields))
+			for _, field := range def.Fields {
+				fields = append(fields, e.introspectInputValue(field.Name, field.Description, field.Type, field.DefaultValue))
+			}
+			return fields
+		}
+	case ast.Enum:
+		typ["enumValues"] = func() interface{} {
+			values := make([]interface{}, 0, len(def.EnumValues))
+			for _, value := range def.EnumValues {
+				values = append(values, map[string]interface{}{
+					"name":              value.Name,
+					"description":       value.Description,
+					"isDeprecated":      value.Directives.ForName("deprecated") != nil,
+					"deprecationReason": nil,
+				})
+			}
+			return values
+		}
+	}
+	if def.IsAbstractType() {
+		typ["possibleTypes"] = func() interface{} {
+			possible := make([]interface{}, 0)
+			for _, t := range e.schema.GetPossibleTypes(def) {
+				possible = append(possible, e.introspectType(t))
+			}
+			return possible
+		}
+	}
+	return typ
+}
+
+// introspectTypeRef describes a field type, wrapping NON_NULL and LIST
+func (e *gqlExecutor) introspectTypeRef(t *ast.Type) interface{} {
+	if t.NonNull {
+		inner := *t
+		inner.NonNull = false
+		return map[string]interface{}{"kind": "NON_NULL", "ofType": e.introspectTypeRef(&inner)}
+	}
+	if t.Elem != nil {
+		return map[string]interface{}{"kind": "LIST", "ofType": e.introspectTypeRef(t.Elem)}
+	}
+	return func() interface{} { return e.introspectType(e.schema.Types[t.NamedType]) }
+}
+
+func (e *gqlExecutor) introspectArgs(args ast.ArgumentDefinitionList) []interface{} {
+	result := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		result = append(result, e.introspectInputValue(arg.Name, arg.Description, arg.Type, arg.DefaultValue))
+	}
+	return result
+}
+
+func (e *gqlExecutor) introspectInputValue(name, description string, t *ast.Type, defaultValue *ast.Value) map[string]interface{} {
+	value := map[string]interface{}{
+		"name":         name,
+		"description":  description,
+		"type":         e.introspectTypeRef(t),
+		"defaultValue": nil,
+	}
+	if defaultValue != nil {
+		value["defaultValue"] = defaultValue.String()
+	}
+	return value
+}
@@ -1,36 +1,79 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"github.com/vmihailenco/msgpack/v5"
 
 	"whatsmeow-service/internal/whatsapp"
 )
 
 // Handlers contains HTTP handlers
 type Handlers struct {
-	manager  *whatsapp.Manager
-	upgrader websocket.Upgrader
+	manager    *whatsapp.Manager
+	upgrader   websocket.Upgrader
+	adminToken string // Required by admin-only routes; empty disables them
+	graphql    *gqlExecutor
+
+	// Shared by the cluster nodes to trust each other's proxied requests
+	clusterSecret string
+
+	// Template bodies of Cloud API template messages, by name
+	cloudTemplates map[string]string
+
+	// Closed on shutdown to end WebSocket, SSE and long-poll connections
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
 // NewHandlers creates new handlers
 func NewHandlers(manager *whatsapp.Manager) *Handlers {
-	return &Handlers{
+	h := &Handlers{
 		manager: manager,
+		closing: make(chan struct{}),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins
 			},
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			// Negotiate permessage-deflate with clients that support it
+			EnableCompression: true,
 		},
 	}
+	h.graphql = h.newGraphQL()
+	return h
+}
+
+// CloseStreams ends the open event streams so the server can shut down
+// without waiting for them. Meant for http.Server.RegisterOnShutdown.
+func (h *Handlers) CloseStreams() {
+	h.closeOnce.Do(func() { close(h.closing) })
+}
+
+// writeWSClose tells a WebSocket client the server is going away
+func writeWSClose(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// writeWSCloseSlow tells a WebSocket client it was dropped for falling behind
+func writeWSCloseSlow(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "consumer too slow")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
 }
 
 // Response helpers
@@ -41,12 +84,23 @@ func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 }
 
 func errorResponse(w http.ResponseWriter, status int, message string) {
+	codedErrorResponse(w, status, codeForStatus(status), message)
+}
+
+func codedErrorResponse(w http.ResponseWriter, status int, code, message string) {
 	jsonResponse(w, status, map[string]interface{}{
 		"success": false,
 		"error":   message,
+		"code":    code,
 	})
 }
 
+// sendContext carries the request's trace into a send without its
+// cancellation, so a client hanging up doesn't abort a message half sent
+func sendContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
+
 func successResponse(w http.ResponseWriter, data interface{}) {
 	jsonResponse(w, http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -58,19 +112,31 @@ func successResponse(w http.ResponseWriter, data interface{}) {
 // Instance Handlers
 // ============================================
 
+// Bounds for the waitFor long-poll on connect
+const (
+	defaultConnectWait = 2 * time.Second
+	maxConnectWait     = 60 * time.Second
+)
+
+// ConnectRequest represents the optional connect request body
+type ConnectRequest struct {
+	ProxyHost     string `json:"proxyHost,omitempty"`
+	ProxyPort     string `json:"proxyPort,omitempty"`
+	ProxyUsername string `json:"proxyUsername,omitempty"`
+	ProxyPassword string `json:"proxyPassword,omitempty"`
+	ProxyProtocol string `json:"proxyProtocol,omitempty"`
+
+	// Identity of the linked device, used if the instance pairs in this connect
+	Device *whatsapp.DeviceIdentity `json:"device,omitempty"`
+}
+
 // ConnectInstance connects an instance to WhatsApp
 func (h *Handlers) ConnectInstance(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["id"]
 
 	// Parse optional proxy configuration from request body
-	var req struct {
-		ProxyHost     string `json:"proxyHost,omitempty"`
-		ProxyPort     string `json:"proxyPort,omitempty"`
-		ProxyUsername string `json:"proxyUsername,omitempty"`
-		ProxyPassword string `json:"proxyPassword,omitempty"`
-		ProxyProtocol string `json:"proxyProtocol,omitempty"`
-	}
+	var req ConnectRequest
 	// Decode body if present (ignore errors for backward compatibility)
 	json.NewDecoder(r.Body).Decode(&req)
 
@@ -82,15 +148,56 @@ func (h *Handlers) ConnectInstance(w http.ResponseWriter, r *http.Request) {
 		h.manager.SetProxy(instanceID, req.ProxyHost, req.ProxyPort, req.ProxyUsername, req.ProxyPassword, req.ProxyProtocol)
 	}
 
+	// The identity must be in place before a new instance starts pairing
+	if req.Device != nil {
+		if _, err := h.manager.GetOrCreateInstance(instanceID); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+		if err := h.manager.SetDeviceIdentity(instanceID, *req.Device); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+
 	instance, err := h.manager.Connect(instanceID)
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to connect")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
+		return
+	}
+
+	// Wait until the requested state is reached (or timeout) so the response
+	// deterministically contains the QR code or the connection info
+	waitFor := r.URL.Query().Get("waitFor")
+	timeout := defaultConnectWait
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		parsed, err := parseTimeout(t)
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid timeout (e.g. 30s)")
+			return
+		}
+		timeout = parsed
+	}
+	if timeout > maxConnectWait {
+		timeout = maxConnectWait
+	}
+
+	var statuses []string
+	switch waitFor {
+	case "", "qr":
+		// A connected instance needs no QR code
+		statuses = []string{"qr", "connected"}
+	case "connected":
+		statuses = []string{"connected"}
+	default:
+		errorResponse(w, http.StatusBadRequest, "waitFor must be qr or connected")
 		return
 	}
 
-	// Wait a bit for QR code or connection
-	time.Sleep(2 * time.Second)
+	// Make sure the server doesn't cut the response while we wait
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + 10*time.Second))
+	h.manager.WaitForStatus(r.Context(), instanceID, statuses, timeout)
 
 	instance.RLock()
 	status := instance.Status
@@ -140,7 +247,7 @@ func (h *Handlers) ConnectWithCode(w http.ResponseWriter, r *http.Request) {
 	code, err := h.manager.ConnectWithPairingCode(instanceID, phoneNumber)
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to get pairing code")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -160,7 +267,7 @@ func (h *Handlers) DisconnectInstance(w http.ResponseWriter, r *http.Request) {
 
 	err := h.manager.Disconnect(instanceID)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -178,7 +285,7 @@ func (h *Handlers) LogoutInstance(w http.ResponseWriter, r *http.Request) {
 
 	err := h.manager.Logout(instanceID)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -187,6 +294,40 @@ func (h *Handlers) LogoutInstance(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// RestoreInstance undoes the soft delete of a logged out instance
+func (h *Handlers) RestoreInstance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["id"]
+
+	log.Info().Str("instanceId", instanceID).Msg("Restoring instance")
+
+	if err := h.manager.RestoreInstance(instanceID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Instance restored, connect to pair again",
+	})
+}
+
+// PurgeInstance permanently removes a soft-deleted instance and its history
+func (h *Handlers) PurgeInstance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["id"]
+
+	log.Info().Str("instanceId", instanceID).Msg("Purging instance")
+
+	if err := h.manager.PurgeInstance(instanceID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Instance purged",
+	})
+}
+
 // GetInstanceStatus gets instance status
 func (h *Handlers) GetInstanceStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -204,23 +345,175 @@ func (h *Handlers) GetInstanceStatus(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetInstanceStats returns the usage counters of an instance, with the daily
+// history of the last ?days=N days
+func (h *Handlers) GetInstanceStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["id"]
+
+	var days int
+	if d := r.URL.Query().Get("days"); d != "" {
+		parsed, err := strconv.Atoi(d)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid days")
+			return
+		}
+		days = parsed
+	}
+
+	stats, err := h.manager.GetInstanceStats(instanceID, days)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, stats)
+}
+
+// SetSettingsRequest represents instance settings update; omitted fields are left unchanged
+type SetSettingsRequest struct {
+	RejectCalls       *bool `json:"rejectCalls,omitempty"`
+	AlwaysOnline      *bool `json:"alwaysOnline,omitempty"`
+	IgnoreGroups      *bool `json:"ignoreGroups,omitempty"`
+	ReadMessages      *bool `json:"readMessages,omitempty"`
+	SendReadReceipts  *bool `json:"sendReadReceipts,omitempty"` // false marks messages read without blue ticks
+	SkipVideoDownload *bool `json:"skipVideoDownload,omitempty"`
+	SkipViewOnceMedia *bool `json:"skipViewOnceMedia,omitempty"` // Discard media of incoming view-once messages
+
+	// How incoming media is downloaded: mode eager/lazy/off, maxBytes cap, types allowlist
+	MediaPolicy *whatsapp.MediaPolicy `json:"mediaPolicy,omitempty"`
+
+	// How the linked device appears in WhatsApp (deviceName, platformType, os); applied on the next pairing
+	Device *whatsapp.DeviceIdentity `json:"device,omitempty"`
+
+	// Events are POSTed to webhookUrl with an X-Signature HMAC-SHA256 header ("" disables).
+	// A random webhookSecret is generated when none is set.
+	WebhookURL    *string `json:"webhookUrl,omitempty"`
+	WebhookSecret *string `json:"webhookSecret,omitempty"`
+	// Event types delivered to the webhook, e.g. ["message", "call*"]; [] delivers all
+	WebhookEvents []string `json:"webhookEvents,omitempty"`
+	// Payload format of the webhook: native or evolution (Evolution API event names and shapes)
+	WebhookFormat *string `json:"webhookFormat,omitempty"`
+
+	// Rules applied in order to incoming messages: drop, tag, read or route to a webhook; [] removes all
+	FilterRules []whatsapp.FilterRule `json:"filterRules,omitempty"`
+
+	// Reply sent once per contact per window to messages received outside business hours
+	AwayMessage *whatsapp.AwayMessage `json:"awayMessage,omitempty"`
+
+	// Answers incoming messages with completions from an OpenAI-compatible API (baseUrl, apiKey, model, systemPrompt...)
+	AIAgent *whatsapp.AIAgent `json:"aiAgent,omitempty"`
+
+	// Runs a Typebot flow per chat (baseUrl, publicId, sessionMinutes, resetKeyword, choicePrompt...)
+	Typebot *whatsapp.TypebotConfig `json:"typebot,omitempty"`
+
+	// Connects each chat to a Dialogflow CX agent (projectId, location, agentId, credentials...)
+	Dialogflow *whatsapp.DialogflowConfig `json:"dialogflow,omitempty"`
+
+	// Message sent after a missed or auto-rejected call ("" disables)
+	CallFollowUpMessage         *string `json:"callFollowUpMessage,omitempty"`
+	CallFollowUpCooldownMinutes *int    `json:"callFollowUpCooldownMinutes,omitempty"` // Per contact, defaults to 60
+
+	// Number allowed to send owner commands (!status, !pause...) over WhatsApp ("" disables)
+	OwnerNumber *string `json:"ownerNumber,omitempty"`
+	Paused      *bool   `json:"paused,omitempty"`
+
+	// Level of this instance's whatsmeow client logs: trace, debug, info, warn, error, disabled ("" uses the service default)
+	ClientLogLevel *string `json:"clientLogLevel,omitempty"`
+}
+
 // SetSettings updates instance settings
 func (h *Handlers) SetSettings(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["id"]
 
-	var req struct {
-		RejectCalls       *bool `json:"rejectCalls,omitempty"`
-		AlwaysOnline      *bool `json:"alwaysOnline,omitempty"`
-		IgnoreGroups      *bool `json:"ignoreGroups,omitempty"`
-		ReadMessages      *bool `json:"readMessages,omitempty"`
-		SkipVideoDownload *bool `json:"skipVideoDownload,omitempty"`
-	}
+	var req SetSettingsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
+	if req.MediaPolicy != nil {
+		if err := h.manager.SetMediaPolicy(instanceID, *req.MediaPolicy); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.Device != nil {
+		if err := h.manager.SetDeviceIdentity(instanceID, *req.Device); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.WebhookURL != nil || req.WebhookSecret != nil {
+		current := h.manager.GetSettings(instanceID)
+		webhookURL, _ := current["webhookUrl"].(string)
+		if req.WebhookURL != nil {
+			webhookURL = *req.WebhookURL
+		}
+		secret := ""
+		if req.WebhookSecret != nil {
+			secret = *req.WebhookSecret
+		}
+		if err := h.manager.SetWebhook(instanceID, webhookURL, secret); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.WebhookEvents != nil {
+		if err := h.manager.SetWebhookEvents(instanceID, req.WebhookEvents); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.WebhookFormat != nil {
+		if err := h.manager.SetWebhookFormat(instanceID, *req.WebhookFormat); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.FilterRules != nil {
+		if err := h.manager.SetFilterRules(instanceID, req.FilterRules); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.AwayMessage != nil {
+		if err := h.manager.SetAwayMessage(instanceID, *req.AwayMessage); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.AIAgent != nil {
+		if err := h.manager.SetAIAgent(instanceID, *req.AIAgent); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.Typebot != nil {
+		if err := h.manager.SetTypebot(instanceID, *req.Typebot); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.Dialogflow != nil {
+		if err := h.manager.SetDialogflow(instanceID, *req.Dialogflow); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.OwnerNumber != nil {
+		if err := h.manager.SetOwnerNumber(instanceID, *req.OwnerNumber); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
+	if req.ClientLogLevel != nil {
+		if err := h.manager.SetInstanceLogLevel(instanceID, *req.ClientLogLevel); err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+	}
 	if req.RejectCalls != nil {
 		h.manager.SetRejectCalls(instanceID, *req.RejectCalls)
 	}
@@ -233,11 +526,50 @@ func (h *Handlers) SetSettings(w http.ResponseWriter, r *http.Request) {
 	if req.ReadMessages != nil {
 		h.manager.SetReadMessages(instanceID, *req.ReadMessages)
 	}
+	if req.SendReadReceipts != nil {
+		h.manager.SetSendReadReceipts(instanceID, *req.SendReadReceipts)
+	}
 	if req.SkipVideoDownload != nil {
 		h.manager.SetSkipVideoDownload(instanceID, *req.SkipVideoDownload)
 	}
+	if req.SkipViewOnceMedia != nil {
+		h.manager.SetSkipViewOnceMedia(instanceID, *req.SkipViewOnceMedia)
+	}
+	if req.Paused != nil {
+		h.manager.SetPaused(instanceID, *req.Paused)
+	}
+	if req.CallFollowUpMessage != nil || req.CallFollowUpCooldownMinutes != nil {
+		current := h.manager.GetSettings(instanceID)
+		message, _ := current["callFollowUpMessage"].(string)
+		cooldownMinutes, _ := current["callFollowUpCooldownMinutes"].(int)
+		if req.CallFollowUpMessage != nil {
+			message = *req.CallFollowUpMessage
+		}
+		if req.CallFollowUpCooldownMinutes != nil {
+			if *req.CallFollowUpCooldownMinutes < 0 {
+				errorResponse(w, http.StatusBadRequest, "callFollowUpCooldownMinutes must be >= 0")
+				return
+			}
+			cooldownMinutes = *req.CallFollowUpCooldownMinutes
+		}
+		h.manager.SetCallFollowUp(instanceID, message, time.Duration(cooldownMinutes)*time.Minute)
+	}
 
-	successResponse(w, h.manager.GetSettings(instanceID))
+	settings := h.manager.GetSettings(instanceID)
+	if req.WebhookURL != nil || req.WebhookSecret != nil {
+		// Shown only when the webhook is set, to configure the receiver
+		settings["webhookSecret"] = h.manager.WebhookSecret(instanceID)
+	}
+	successResponse(w, settings)
+}
+
+// SetProxyRequest represents proxy configuration request
+type SetProxyRequest struct {
+	ProxyHost     string `json:"proxyHost"`
+	ProxyPort     string `json:"proxyPort"`
+	ProxyUsername string `json:"proxyUsername"`
+	ProxyPassword string `json:"proxyPassword"`
+	ProxyProtocol string `json:"proxyProtocol"` // http, https, socks4, socks5
 }
 
 // SetProxy updates instance proxy configuration
@@ -245,13 +577,7 @@ func (h *Handlers) SetProxy(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["id"]
 
-	var req struct {
-		ProxyHost     string `json:"proxyHost"`
-		ProxyPort     string `json:"proxyPort"`
-		ProxyUsername string `json:"proxyUsername"`
-		ProxyPassword string `json:"proxyPassword"`
-		ProxyProtocol string `json:"proxyProtocol"`
-	}
+	var req SetProxyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
@@ -259,7 +585,7 @@ func (h *Handlers) SetProxy(w http.ResponseWriter, r *http.Request) {
 
 	err := h.manager.SetProxy(instanceID, req.ProxyHost, req.ProxyPort, req.ProxyUsername, req.ProxyPassword, req.ProxyProtocol)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -273,7 +599,7 @@ func (h *Handlers) CheckProxyIP(w http.ResponseWriter, r *http.Request) {
 
 	ip, err := h.manager.CheckProxyIP(instanceID)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -305,15 +631,113 @@ func (h *Handlers) GetQRCode(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetQRCodePNG returns the current QR code as a PNG image
+func (h *Handlers) GetQRCodePNG(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["id"]
+
+	png := h.manager.GetQRCodePNG(instanceID)
+	if len(png) == 0 {
+		errorResponse(w, http.StatusNotFound, "QR code not available. Try connecting first.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
+
 // ============================================
 // Message Handlers
 // ============================================
 
+// renderTemplate fills the {{var}} placeholders of a text the request asked to
+// render, by sending variables or template: true. Other texts are sent as is,
+// braces included.
+func (h *Handlers) renderTemplate(instanceID, to, text string, vars map[string]string, template bool) string {
+	if len(vars) == 0 && !template {
+		return text
+	}
+	return h.manager.RenderTemplate(instanceID, to, text, vars)
+}
+
+// renderMediaURL fills the {{var}} placeholders of a media URL like renderTemplate
+func (h *Handlers) renderMediaURL(instanceID, to, mediaURL string, vars map[string]string, template bool) string {
+	if len(vars) == 0 && !template {
+		return mediaURL
+	}
+	return h.manager.RenderMediaURL(instanceID, to, mediaURL, vars)
+}
+
 // SendTextRequest represents text message request
 type SendTextRequest struct {
 	InstanceID string `json:"instanceId"`
 	To         string `json:"to"`
 	Text       string `json:"text"`
+	TTLSeconds int64  `json:"ttlSeconds,omitempty"` // Queue while disconnected for up to this long
+	NotAfter   int64  `json:"notAfter,omitempty"`   // Or until this unix time
+
+	// Values of the {{var}} placeholders of the text; name, firstName and number default to the contact's.
+	// The text is rendered only with variables or template set.
+	Variables map[string]string `json:"variables,omitempty"`
+	Template  bool              `json:"template,omitempty"`
+
+	// Group mentions (to is a group JID): participants, every participant or the admins
+	Mentions      []string `json:"mentions,omitempty"`
+	MentionAll    bool     `json:"mentionAll,omitempty"`
+	MentionAdmins bool     `json:"mentionAdmins,omitempty"`
+
+	LinkPreviewRequest
+}
+
+// LinkPreviewRequest controls the preview of the first URL of a text
+type LinkPreviewRequest struct {
+	LinkPreview        *bool  `json:"linkPreview,omitempty"`  // Defaults to true; false sends without a preview
+	PreviewTitle       string `json:"previewTitle,omitempty"` // Custom preview instead of the page's
+	PreviewDescription string `json:"previewDescription,omitempty"`
+	PreviewImageURL    string `json:"previewImageUrl,omitempty"`
+}
+
+// options converts the request fields to the manager's preview options
+func (p LinkPreviewRequest) options() whatsapp.PreviewOptions {
+	return whatsapp.PreviewOptions{
+		Disabled:    p.LinkPreview != nil && !*p.LinkPreview,
+		Title:       p.PreviewTitle,
+		Description: p.PreviewDescription,
+		ImageURL:    p.PreviewImageURL,
+	}
+}
+
+// parseDeadline resolves the optional delivery deadline of a send request.
+// Returns zero when the message shouldn't be queued.
+func parseDeadline(ttlSeconds, notAfter int64) (int64, error) {
+	if ttlSeconds < 0 || notAfter < 0 {
+		return 0, errors.New("ttlSeconds and notAfter must be positive")
+	}
+	if ttlSeconds > 0 {
+		deadline := time.Now().Unix() + ttlSeconds
+		if notAfter == 0 || deadline < notAfter {
+			notAfter = deadline
+		}
+	}
+	if notAfter != 0 && notAfter <= time.Now().Unix() {
+		return 0, errors.New("notAfter is in the past")
+	}
+	return notAfter, nil
+}
+
+// queuedResponse answers a send that was queued until the instance reconnects
+func queuedResponse(w http.ResponseWriter, queued *whatsapp.QueuedMessage) {
+	jsonResponse(w, http.StatusAccepted, map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"queueId":  queued.ID,
+			"to":       queued.To,
+			"status":   "queued",
+			"notAfter": queued.NotAfter,
+		},
+	})
 }
 
 // SendTextMessage sends a text message
@@ -330,18 +754,51 @@ func (h *Handlers) SendTextMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasSuffix(req.To, "@g.us") {
+		h.sendGroupText(w, r, &req)
+		return
+	}
+	if len(req.Mentions) > 0 || req.MentionAll || req.MentionAdmins {
+		errorResponse(w, http.StatusBadRequest, "mentions are only supported in groups")
+		return
+	}
+
+	notAfter, err := parseDeadline(req.TTLSeconds, req.NotAfter)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Clean phone number
 	to := cleanPhoneNumber(req.To)
+	text := h.renderTemplate(req.InstanceID, to, req.Text, req.Variables, req.Template)
 
 	log.Info().
 		Str("instanceId", req.InstanceID).
 		Str("to", to).
 		Msg("Sending text message")
 
-	msgID, err := h.manager.SendTextMessage(req.InstanceID, to, req.Text)
+	preview := req.options()
+	msgID, err := h.manager.SendTextMessage(sendContext(r), req.InstanceID, to, text, preview)
+	if notAfter != 0 && errors.Is(err, whatsapp.ErrNotConnected) {
+		queued, qErr := h.manager.QueueMessage(whatsapp.QueuedMessage{
+			InstanceID: req.InstanceID,
+			To:         to,
+			Type:       "text",
+			Text:       text,
+			Preview:    &preview,
+			NotAfter:   notAfter,
+		})
+		if qErr != nil {
+			managerErrorResponse(w, qErr)
+			return
+		}
+		queuedResponse(w, queued)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to send message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -352,129 +809,397 @@ func (h *Handlers) SendTextMessage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// SendMediaRequest represents media message request
-type SendMediaRequest struct {
-	InstanceID string `json:"instanceId"`
-	To         string `json:"to"`
-	MediaURL   string `json:"mediaUrl"`
-	Caption    string `json:"caption,omitempty"`
-	MediaType  string `json:"mediaType,omitempty"` // image, video, audio, document
-}
-
-// SendMediaMessage sends media message
-func (h *Handlers) SendMediaMessage(w http.ResponseWriter, r *http.Request) {
-	var req SendMediaRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		errorResponse(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	if req.InstanceID == "" || req.To == "" || req.MediaURL == "" {
-		errorResponse(w, http.StatusBadRequest, "instanceId, to, and mediaUrl are required")
+// sendGroupText sends a text message to a group, with its mentions. Group
+// messages aren't queued while disconnected.
+func (h *Handlers) sendGroupText(w http.ResponseWriter, r *http.Request, req *SendTextRequest) {
+	if req.TTLSeconds != 0 || req.NotAfter != 0 {
+		errorResponse(w, http.StatusBadRequest, "group messages can't be queued")
 		return
 	}
 
-	// Clean phone number
-	to := cleanPhoneNumber(req.To)
-	mediaType := req.MediaType
+	group := strings.TrimSpace(req.To)
+	text := h.renderTemplate(req.InstanceID, group, req.Text, req.Variables, req.Template)
 
 	log.Info().
 		Str("instanceId", req.InstanceID).
-		Str("to", to).
-		Str("mediaType", mediaType).
-		Msg("Sending media message")
-
-	msgID, err := h.manager.SendMediaMessage(req.InstanceID, to, req.MediaURL, req.Caption, mediaType)
+		Str("group", group).
+		Bool("mentionAll", req.MentionAll).
+		Msg("Sending group text message")
+
+	msgID, err := h.manager.SendGroupTextMessage(sendContext(r), req.InstanceID, group, text, whatsapp.MentionOptions{
+		Mentions: req.Mentions,
+		All:      req.MentionAll,
+		Admins:   req.MentionAdmins,
+	}, req.options())
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send media message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		log.Error().Err(err).Msg("Failed to send group message")
+		managerErrorResponse(w, err)
 		return
 	}
 
 	successResponse(w, map[string]interface{}{
 		"messageId": msgID,
-		"to":        to,
+		"to":        group,
 		"status":    "sent",
 	})
 }
 
-// SendPresenceRequest represents presence request
-type SendPresenceRequest struct {
-	InstanceID string `json:"instanceId"`
-	To         string `json:"to"`
-	Presence   string `json:"presence"` // composing, recording, paused
+// SendToJIDRequest sends text or media to a chat given by its full JID
+type SendToJIDRequest struct {
+	InstanceID  string `json:"instanceId"`
+	JID         string `json:"jid"` // @s.whatsapp.net, @lid, @g.us or @newsletter
+	Text        string `json:"text,omitempty"`
+	MediaURL    string `json:"mediaUrl,omitempty"`
+	Caption     string `json:"caption,omitempty"`
+	MediaType   string `json:"mediaType,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	Mimetype    string `json:"mimetype,omitempty"`
+	GIFPlayback bool   `json:"gifPlayback,omitempty"`
+	ViewOnce    bool   `json:"viewOnce,omitempty"`
+
+	ImageCompressionRequest
+	LinkPreviewRequest
 }
 
-// SendPresence sends chat presence
-func (h *Handlers) SendPresence(w http.ResponseWriter, r *http.Request) {
-	var req SendPresenceRequest
+// SendToJID sends a text or media message to a JID as given. Unlike
+// /message/text and /message/media, the destination isn't cleaned as a phone
+// number, so group, LID and channel JIDs keep their server.
+func (h *Handlers) SendToJID(w http.ResponseWriter, r *http.Request) {
+	var req SendToJIDRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.InstanceID == "" || req.To == "" || req.Presence == "" {
-		errorResponse(w, http.StatusBadRequest, "instanceId, to, and presence are required")
+	if req.InstanceID == "" || req.JID == "" || (req.Text == "" && req.MediaURL == "") {
+		errorResponse(w, http.StatusBadRequest, "instanceId, jid, and text or mediaUrl are required")
 		return
 	}
 
-	// Clean phone number
-	to := cleanPhoneNumber(req.To)
-
 	log.Info().
 		Str("instanceId", req.InstanceID).
-		Str("to", to).
-		Str("presence", req.Presence).
-		Msg("Sending presence")
-
-	err := h.manager.SendPresence(req.InstanceID, to, req.Presence)
+		Str("jid", req.JID).
+		Msg("Sending message to JID")
+
+	var msgID string
+	var err error
+	if req.MediaURL != "" {
+		msgID, err = h.manager.SendMediaToJID(sendContext(r), req.InstanceID, req.JID, req.MediaURL, req.Caption, req.MediaType, whatsapp.MediaOptions{
+			FileName:     req.FileName,
+			Mimetype:     req.Mimetype,
+			GIFPlayback:  req.GIFPlayback,
+			ViewOnce:     req.ViewOnce,
+			MaxDimension: req.MaxDimension,
+			Quality:      req.Quality,
+		})
+	} else {
+		msgID, err = h.manager.SendTextToJID(sendContext(r), req.InstanceID, req.JID, req.Text, req.options())
+	}
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send presence")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		log.Error().Err(err).Msg("Failed to send message to JID")
+		managerErrorResponse(w, err)
 		return
 	}
 
-	successResponse(w, map[string]string{
-		"status": "success",
+	successResponse(w, map[string]interface{}{
+		"messageId": msgID,
+		"to":        req.JID,
+		"status":    "sent",
 	})
 }
 
-// SendLocationRequest represents location message request
-type SendLocationRequest struct {
-	InstanceID  string  `json:"instanceId"`
-	To          string  `json:"to"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
-	Description string  `json:"description,omitempty"`
+// SendMediaRequest represents media message request
+type SendMediaRequest struct {
+	InstanceID  string `json:"instanceId"`
+	To          string `json:"to"`
+	MediaURL    string `json:"mediaUrl"`
+	Caption     string `json:"caption,omitempty"`
+	MediaType   string `json:"mediaType,omitempty"`   // image, video, audio, document
+	FileName    string `json:"fileName,omitempty"`    // Document name (defaults to the URL's)
+	Mimetype    string `json:"mimetype,omitempty"`    // Overrides the detected mime type
+	GIFPlayback bool   `json:"gifPlayback,omitempty"` // Play an MP4 video as a looping GIF
+	ViewOnce    bool   `json:"viewOnce,omitempty"`    // Image, video or audio that can only be opened once
+	TTLSeconds  int64  `json:"ttlSeconds,omitempty"`
+	NotAfter    int64  `json:"notAfter,omitempty"`
+
+	// Values of the {{var}} placeholders of the caption and mediaUrl, rendered only with variables or template set
+	Variables map[string]string `json:"variables,omitempty"`
+	Template  bool              `json:"template,omitempty"`
+
+	ImageCompressionRequest
 }
 
-// SendLocationMessage sends location message
-func (h *Handlers) SendLocationMessage(w http.ResponseWriter, r *http.Request) {
-	var req SendLocationRequest
+// ImageCompressionRequest downscales and recompresses a sent image, over the
+// server setting
+type ImageCompressionRequest struct {
+	MaxDimension int `json:"maxDimension,omitempty"` // Longest edge in pixels
+	Quality      int `json:"quality,omitempty"`      // JPEG quality 1-100
+}
+
+// SendMediaMessage sends media message
+func (h *Handlers) SendMediaMessage(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		h.sendMediaUpload(w, r)
+		return
+	}
+
+	var req SendMediaRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.InstanceID == "" || req.To == "" {
-		errorResponse(w, http.StatusBadRequest, "instanceId and to are required")
+	if req.InstanceID == "" || req.To == "" || req.MediaURL == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, and mediaUrl are required")
+		return
+	}
+
+	notAfter, err := parseDeadline(req.TTLSeconds, req.NotAfter)
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Clean phone number
 	to := cleanPhoneNumber(req.To)
+	mediaType := req.MediaType
+	caption := h.renderTemplate(req.InstanceID, to, req.Caption, req.Variables, req.Template)
 
 	log.Info().
 		Str("instanceId", req.InstanceID).
 		Str("to", to).
-		Float64("lat", req.Latitude).
+		Str("mediaType", mediaType).
+		Msg("Sending media message")
+
+	mediaURL := h.renderMediaURL(req.InstanceID, to, req.MediaURL, req.Variables, req.Template)
+	msgID, err := h.manager.SendMediaMessage(sendContext(r), req.InstanceID, to, mediaURL, caption, mediaType, whatsapp.MediaOptions{
+		FileName:     req.FileName,
+		Mimetype:     req.Mimetype,
+		GIFPlayback:  req.GIFPlayback,
+		ViewOnce:     req.ViewOnce,
+		MaxDimension: req.MaxDimension,
+		Quality:      req.Quality,
+	})
+	if notAfter != 0 && errors.Is(err, whatsapp.ErrNotConnected) {
+		queued, qErr := h.manager.QueueMessage(whatsapp.QueuedMessage{
+			InstanceID:   req.InstanceID,
+			To:           to,
+			Type:         "media",
+			MediaURL:     req.MediaURL,
+			Caption:      caption,
+			MediaType:    mediaType,
+			FileName:     req.FileName,
+			Mimetype:     req.Mimetype,
+			GIFPlayback:  req.GIFPlayback,
+			ViewOnce:     req.ViewOnce,
+			MaxDimension: req.MaxDimension,
+			Quality:      req.Quality,
+			NotAfter:     notAfter,
+			// The media URL is rendered when the message goes out
+			Variables: req.Variables,
+			Template:  req.Template,
+		})
+		if qErr != nil {
+			managerErrorResponse(w, qErr)
+			return
+		}
+		queuedResponse(w, queued)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send media message")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"messageId": msgID,
+		"to":        to,
+		"status":    "sent",
+	})
+}
+
+// sendMediaUpload sends media uploaded as multipart/form-data. Parts are read
+// in order and the file is streamed straight to WhatsApp, so the instanceId,
+// to, caption and mediaType fields must come before the "file" part.
+func (h *Handlers) sendMediaUpload(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid multipart body")
+		return
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			errorResponse(w, http.StatusBadRequest, "file is required")
+			return
+		}
+		if err != nil {
+			errorResponse(w, http.StatusBadRequest, "Invalid multipart body")
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 64*1024))
+			if err != nil {
+				errorResponse(w, http.StatusBadRequest, "Invalid multipart body")
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if fields["instanceId"] == "" || fields["to"] == "" {
+			errorResponse(w, http.StatusBadRequest, "instanceId and to are required and must be sent before file")
+			return
+		}
+		if !h.scopeInstance(w, r, fields["instanceId"]) {
+			return
+		}
+
+		to := cleanPhoneNumber(fields["to"])
+
+		log.Info().
+			Str("instanceId", fields["instanceId"]).
+			Str("to", to).
+			Str("mediaType", fields["mediaType"]).
+			Str("fileName", part.FileName()).
+			Msg("Sending uploaded media message")
+
+		opts := whatsapp.MediaOptions{
+			FileName:    fields["fileName"],
+			Mimetype:    fields["mimetype"],
+			GIFPlayback: fields["gifPlayback"] == "true",
+			ViewOnce:    fields["viewOnce"] == "true",
+		}
+		if opts.FileName == "" {
+			opts.FileName = part.FileName()
+		}
+		if opts.Mimetype == "" {
+			opts.Mimetype = part.Header.Get("Content-Type")
+		}
+
+		// Variables come as a JSON object field
+		var variables map[string]string
+		if fields["variables"] != "" {
+			if err := json.Unmarshal([]byte(fields["variables"]), &variables); err != nil {
+				errorResponse(w, http.StatusBadRequest, "variables must be a JSON object of strings")
+				return
+			}
+		}
+		caption := h.renderTemplate(fields["instanceId"], to, fields["caption"], variables, fields["template"] == "true")
+
+		msgID, err := h.manager.SendMediaReader(sendContext(r), fields["instanceId"], to, part, caption, fields["mediaType"], opts)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to send uploaded media message")
+			managerErrorResponse(w, err)
+			return
+		}
+
+		successResponse(w, map[string]interface{}{
+			"messageId": msgID,
+			"to":        to,
+			"status":    "sent",
+		})
+		return
+	}
+}
+
+// SendPresenceRequest represents presence request
+type SendPresenceRequest struct {
+	InstanceID string `json:"instanceId"`
+	To         string `json:"to"`
+	Presence   string `json:"presence"` // composing, recording, paused
+}
+
+// SendPresence sends chat presence
+func (h *Handlers) SendPresence(w http.ResponseWriter, r *http.Request) {
+	var req SendPresenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" || req.Presence == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, and presence are required")
+		return
+	}
+
+	// Clean phone number
+	to := cleanPhoneNumber(req.To)
+
+	log.Info().
+		Str("instanceId", req.InstanceID).
+		Str("to", to).
+		Str("presence", req.Presence).
+		Msg("Sending presence")
+
+	err := h.manager.SendPresence(req.InstanceID, to, req.Presence)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send presence")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"status": "success",
+	})
+}
+
+// SendLocationRequest represents location message request
+type SendLocationRequest struct {
+	InstanceID   string  `json:"instanceId"`
+	To           string  `json:"to"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Name         string  `json:"name,omitempty"`
+	Address      string  `json:"address,omitempty"`
+	URL          string  `json:"url,omitempty"`          // Link of the place
+	ThumbnailURL string  `json:"thumbnailUrl,omitempty"` // JPEG preview of the place
+	Description  string  `json:"description,omitempty"`  // Deprecated: use name
+}
+
+// SendLocationMessage sends location message
+func (h *Handlers) SendLocationMessage(w http.ResponseWriter, r *http.Request) {
+	var req SendLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and to are required")
+		return
+	}
+
+	// Clean phone number
+	to := cleanPhoneNumber(req.To)
+
+	log.Info().
+		Str("instanceId", req.InstanceID).
+		Str("to", to).
+		Float64("lat", req.Latitude).
 		Float64("long", req.Longitude).
 		Msg("Sending location message")
 
-	messageID, err := h.manager.SendLocationMessage(req.InstanceID, to, req.Latitude, req.Longitude, req.Description)
+	name := req.Name
+	if name == "" {
+		name = req.Description
+	}
+
+	messageID, err := h.manager.SendLocationMessage(req.InstanceID, to, whatsapp.Location{
+		Latitude:     req.Latitude,
+		Longitude:    req.Longitude,
+		Name:         name,
+		Address:      req.Address,
+		URL:          req.URL,
+		ThumbnailURL: req.ThumbnailURL,
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to send location message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -484,6 +1209,49 @@ func (h *Handlers) SendLocationMessage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetMessageStatus returns the delivery timeline of a sent message
+func (h *Handlers) GetMessageStatus(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	messageID := vars["messageId"]
+
+	status, err := h.manager.GetMessageStatus(r.Context(), instanceID, messageID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, status)
+}
+
+// GetMessage returns a stored message and, for sent messages, its delivery timeline
+func (h *Handlers) GetMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	messageID := vars["messageId"]
+
+	lookup, err := h.manager.GetMessage(r.Context(), instanceID, messageID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, lookup)
+}
+
+// GetMessageReactions lists the current reactions to a stored message
+func (h *Handlers) GetMessageReactions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	reactions, err := h.manager.GetMessageReactions(vars["instanceId"], vars["messageId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, reactions)
+}
+
 // ============================================
 // Contact & Group Handlers
 // ============================================
@@ -495,135 +1263,552 @@ func (h *Handlers) GetContacts(w http.ResponseWriter, r *http.Request) {
 
 	contacts, err := h.manager.GetContacts(instanceID)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
 	successResponse(w, contacts)
 }
 
+// CheckNumberRequest represents number check request
+type CheckNumberRequest struct {
+	Number  string   `json:"number"`
+	Numbers []string `json:"numbers"` // Checks several numbers at once, returning a list
+}
+
 // CheckNumber checks if number is on WhatsApp
 func (h *Handlers) CheckNumber(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["instanceId"]
 
-	var req struct {
-		Number string `json:"number"`
-	}
+	var req CheckNumberRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	result, err := h.manager.CheckNumber(instanceID, req.Number)
+	if len(req.Numbers) > 0 {
+		results, err := h.manager.CheckNumbers(r.Context(), instanceID, req.Numbers)
+		if err != nil {
+			managerErrorResponse(w, err)
+			return
+		}
+		successResponse(w, results)
+		return
+	}
+
+	result, err := h.manager.CheckNumber(r.Context(), instanceID, req.Number)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
 	successResponse(w, result)
 }
 
-// GetChats gets chats/conversations for instance
+// SubscribePresenceRequest represents presence subscription request
+type SubscribePresenceRequest struct {
+	Numbers []string `json:"numbers"`
+}
+
+// SubscribePresence subscribes to online/offline updates of contacts
+func (h *Handlers) SubscribePresence(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req SubscribePresenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Numbers) == 0 {
+		errorResponse(w, http.StatusBadRequest, "at least 1 number is required")
+		return
+	}
+
+	subscribed, err := h.manager.SubscribePresence(instanceID, req.Numbers)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to subscribe to presence")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"subscribed": subscribed,
+	})
+}
+
+// GetContactPresence returns the last known presence of a contact
+func (h *Handlers) GetContactPresence(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	jid := vars["jid"]
+
+	presence, err := h.manager.GetContactPresence(instanceID, jid)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, presence)
+}
+
+// GetChats lists chats with their last message and unread counter
 func (h *Handlers) GetChats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["instanceId"]
+	query := r.URL.Query()
 
-	chats, err := h.manager.GetChats(instanceID)
+	var limit int
+	if l := query.Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	chats, err := h.manager.GetChats(instanceID, query.Get("unread") == "true", limit)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
 	successResponse(w, chats)
 }
 
+// ClearChat removes the stored messages of a chat (?remote=clear|delete also
+// clears or deletes it on the phone, ?keepStarred=true keeps starred messages)
+func (h *Handlers) ClearChat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	chatID := vars["jid"]
+	query := r.URL.Query()
+
+	remote := query.Get("remote")
+	if !whatsapp.ValidChatRemote(remote) {
+		errorResponse(w, http.StatusBadRequest, "remote must be clear or delete")
+		return
+	}
+	keepStarred := query.Get("keepStarred") == "true"
+
+	removed, err := h.manager.ClearChat(instanceID, chatID, remote, keepStarred)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("chatId", chatID).Msg("Failed to clear chat")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"chatId":  chatID,
+		"removed": removed,
+		"remote":  remote,
+	})
+}
+
+// ChatAIRequest turns the AI agent on or off for a chat
+type ChatAIRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetChatAI turns the AI agent on or off for a chat
+func (h *Handlers) SetChatAI(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	chatID := vars["jid"]
+
+	var req ChatAIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Enabled == nil {
+		errorResponse(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+
+	if err := h.manager.SetChatAIEnabled(instanceID, chatID, *req.Enabled); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"chatId":  chatID,
+		"enabled": *req.Enabled,
+	})
+}
+
+// DisappearingTimerRequest sets the disappearing messages timer of a chat
+type DisappearingTimerRequest struct {
+	Timer string `json:"timer"` // off, 24h, 7d or 90d
+}
+
+// SetDisappearingTimer sets the disappearing messages timer of a group or 1:1 chat
+func (h *Handlers) SetDisappearingTimer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	chatID := vars["jid"]
+
+	var req DisappearingTimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Timer == "" {
+		errorResponse(w, http.StatusBadRequest, "timer is required")
+		return
+	}
+
+	timer, err := h.manager.SetDisappearingTimer(instanceID, chatID, req.Timer)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to set disappearing timer")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"chatId":         chatID,
+		"ephemeralTimer": timer,
+	})
+}
+
 // GetGroups gets groups for instance
 func (h *Handlers) GetGroups(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["instanceId"]
 
-	groups, err := h.manager.GetGroups(instanceID)
+	groups, err := h.manager.GetGroups(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, groups)
+}
+
+// GetGroupInfo gets a group with its participants and settings
+func (h *Handlers) GetGroupInfo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	group, err := h.manager.GetGroupInfo(vars["instanceId"], vars["jid"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, group)
+}
+
+// SetGroupSettingsRequest changes group settings; omitted fields are kept
+type SetGroupSettingsRequest struct {
+	JoinApprovalRequired *bool   `json:"joinApprovalRequired,omitempty"`
+	MemberAddMode        *string `json:"memberAddMode,omitempty"` // admins or all
+}
+
+// SetGroupSettings changes a group's join approval and member add mode
+func (h *Handlers) SetGroupSettings(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	var req SetGroupSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.JoinApprovalRequired == nil && req.MemberAddMode == nil {
+		errorResponse(w, http.StatusBadRequest, "joinApprovalRequired or memberAddMode is required")
+		return
+	}
+
+	group, err := h.manager.SetGroupSettings(vars["instanceId"], vars["jid"], whatsapp.GroupSettings{
+		JoinApprovalRequired: req.JoinApprovalRequired,
+		MemberAddMode:        req.MemberAddMode,
+	})
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, group)
+}
+
+// GetChatMessagesRequest represents chat messages request
+type GetChatMessagesRequest struct {
+	ChatID string `json:"chatId"`
+	Limit  int    `json:"limit,omitempty"` // Defaults to 50
+}
+
+// GetChatMessages gets messages from a specific chat
+func (h *Handlers) GetChatMessages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req GetChatMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+
+	messages, err := h.manager.GetChatMessages(instanceID, req.ChatID, req.Limit)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, messages)
+}
+
+// ExportChat downloads the stored history of a chat as JSON, CSV or TXT,
+// or as a ZIP with the media when media=true
+func (h *Handlers) ExportChat(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	query := r.URL.Query()
+
+	chatID := query.Get("chatId")
+	if chatID == "" {
+		errorResponse(w, http.StatusBadRequest, "chatId is required")
+		return
+	}
+	if !strings.Contains(chatID, "@") {
+		chatID = cleanPhoneNumber(chatID) + "@s.whatsapp.net"
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = whatsapp.ExportJSON
+	}
+	includeMedia := query.Get("media") == "true"
+
+	export, err := h.manager.ExportChat(instanceID, chatID, format, includeMedia)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	log.Info().
+		Str("instanceId", instanceID).
+		Str("chatId", chatID).
+		Str("format", format).
+		Bool("media", includeMedia).
+		Int("bytes", len(export.Data)).
+		Msg("Exported chat")
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+export.FileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(export.Data)
+}
+
+// ============================================
+// Poll, Edit, React, Delete Handlers
+// ============================================
+
+// SendPollRequest represents poll message request
+type SendPollRequest struct {
+	InstanceID      string   `json:"instanceId"`
+	To              string   `json:"to"`
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	SelectableCount int      `json:"selectableCount,omitempty"`
+}
+
+// SendPollMessage sends a poll message
+func (h *Handlers) SendPollMessage(w http.ResponseWriter, r *http.Request) {
+	var req SendPollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" || req.Question == "" || len(req.Options) < 2 {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, question, and at least 2 options are required")
+		return
+	}
+
+	// Default selectable count is 1 (single choice)
+	selectableCount := req.SelectableCount
+	if selectableCount <= 0 {
+		selectableCount = 1
+	}
+
+	to := cleanPhoneNumber(req.To)
+
+	log.Info().
+		Str("instanceId", req.InstanceID).
+		Str("to", to).
+		Str("question", req.Question).
+		Int("options", len(req.Options)).
+		Msg("Sending poll message")
+
+	messageID, err := h.manager.SendPollMessage(req.InstanceID, to, req.Question, req.Options, selectableCount)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send poll message")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"status":    "success",
+		"messageId": messageID,
+	})
+}
+
+// VotePollRequest represents poll vote request
+type VotePollRequest struct {
+	InstanceID string   `json:"instanceId"`
+	PollID     string   `json:"pollId"`  // Message ID of the received poll
+	Options    []string `json:"options"` // Option names; empty removes the vote
+}
+
+// VotePoll votes on a poll received by the instance
+func (h *Handlers) VotePoll(w http.ResponseWriter, r *http.Request) {
+	var req VotePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.PollID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and pollId are required")
+		return
+	}
+
+	messageID, err := h.manager.VotePoll(req.InstanceID, req.PollID, req.Options)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to vote on poll")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"status":    "success",
+		"messageId": messageID,
+	})
+}
+
+// SendButtonsRequest represents buttons message request
+type SendButtonsRequest struct {
+	InstanceID string            `json:"instanceId"`
+	To         string            `json:"to"`
+	Text       string            `json:"text"`
+	Footer     string            `json:"footer,omitempty"`
+	Header     string            `json:"header,omitempty"`
+	Buttons    []whatsapp.Button `json:"buttons"`
+}
+
+// SendButtonsMessage sends a message with quick reply buttons
+func (h *Handlers) SendButtonsMessage(w http.ResponseWriter, r *http.Request) {
+	var req SendButtonsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" || req.Text == "" || len(req.Buttons) == 0 {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, text, and at least 1 button are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.To)
+
+	messageID, err := h.manager.SendButtonsMessage(req.InstanceID, to, req.Text, req.Footer, req.Header, req.Buttons)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		log.Error().Err(err).Msg("Failed to send buttons message")
+		managerErrorResponse(w, err)
 		return
 	}
 
-	successResponse(w, groups)
+	successResponse(w, map[string]interface{}{
+		"status":    "success",
+		"messageId": messageID,
+	})
 }
 
-// GetChatMessages gets messages from a specific chat
-func (h *Handlers) GetChatMessages(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	instanceID := vars["instanceId"]
+// SendListRequest represents list message request
+type SendListRequest struct {
+	InstanceID  string                 `json:"instanceId"`
+	To          string                 `json:"to"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	ButtonText  string                 `json:"buttonText"`
+	Footer      string                 `json:"footer,omitempty"`
+	Sections    []whatsapp.ListSection `json:"sections"`
+}
 
-	var req struct {
-		ChatID string `json:"chatId"`
-		Limit  int    `json:"limit"`
-	}
+// SendListMessage sends a single-select list message
+func (h *Handlers) SendListMessage(w http.ResponseWriter, r *http.Request) {
+	var req SendListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.Limit <= 0 {
-		req.Limit = 50
+	if req.InstanceID == "" || req.To == "" || req.Description == "" || req.ButtonText == "" || len(req.Sections) == 0 {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, description, buttonText, and at least 1 section are required")
+		return
 	}
 
-	messages, err := h.manager.GetChatMessages(instanceID, req.ChatID, req.Limit)
+	to := cleanPhoneNumber(req.To)
+
+	messageID, err := h.manager.SendListMessage(req.InstanceID, to, req.Title, req.Description, req.ButtonText, req.Footer, req.Sections)
 	if err != nil {
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		log.Error().Err(err).Msg("Failed to send list message")
+		managerErrorResponse(w, err)
 		return
 	}
 
-	successResponse(w, messages)
+	successResponse(w, map[string]interface{}{
+		"status":    "success",
+		"messageId": messageID,
+	})
 }
 
-// ============================================
-// Poll, Edit, React, Delete Handlers
-// ============================================
-
-// SendPollRequest represents poll message request
-type SendPollRequest struct {
-	InstanceID      string   `json:"instanceId"`
-	To              string   `json:"to"`
-	Question        string   `json:"question"`
-	Options         []string `json:"options"`
-	SelectableCount int      `json:"selectableCount,omitempty"`
+// SendStatusRequest represents status (story) publish request
+type SendStatusRequest struct {
+	InstanceID      string `json:"instanceId"`
+	Type            string `json:"type"` // text, image, video
+	Text            string `json:"text,omitempty"`
+	MediaURL        string `json:"mediaUrl,omitempty"`
+	Caption         string `json:"caption,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"` // #RRGGBB
+	TextColor       string `json:"textColor,omitempty"`       // #RRGGBB
+	Font            int    `json:"font,omitempty"`
 }
 
-// SendPollMessage sends a poll message
-func (h *Handlers) SendPollMessage(w http.ResponseWriter, r *http.Request) {
-	var req SendPollRequest
+// SendStatus publishes a text, image or video status
+func (h *Handlers) SendStatus(w http.ResponseWriter, r *http.Request) {
+	var req SendStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		errorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	if req.InstanceID == "" || req.To == "" || req.Question == "" || len(req.Options) < 2 {
-		errorResponse(w, http.StatusBadRequest, "instanceId, to, question, and at least 2 options are required")
+	if req.InstanceID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId is required")
 		return
 	}
-
-	// Default selectable count is 1 (single choice)
-	selectableCount := req.SelectableCount
-	if selectableCount <= 0 {
-		selectableCount = 1
+	if req.Type == "" {
+		req.Type = "text"
 	}
 
-	to := cleanPhoneNumber(req.To)
-
-	log.Info().
-		Str("instanceId", req.InstanceID).
-		Str("to", to).
-		Str("question", req.Question).
-		Int("options", len(req.Options)).
-		Msg("Sending poll message")
-
-	messageID, err := h.manager.SendPollMessage(req.InstanceID, to, req.Question, req.Options, selectableCount)
+	messageID, err := h.manager.SendStatus(req.InstanceID, whatsapp.StatusPost{
+		Type:            req.Type,
+		Text:            req.Text,
+		MediaURL:        req.MediaURL,
+		Caption:         req.Caption,
+		BackgroundColor: req.BackgroundColor,
+		TextColor:       req.TextColor,
+		Font:            req.Font,
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to send poll message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		log.Error().Err(err).Msg("Failed to publish status")
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -639,6 +1824,8 @@ type EditMessageRequest struct {
 	ChatID     string `json:"chatId"`
 	MessageID  string `json:"messageId"`
 	NewText    string `json:"newText"`
+	Mode       string `json:"mode,omitempty"`      // text (default) or mediaCaption
+	MediaType  string `json:"mediaType,omitempty"` // image, video or document; only for media missing from the store
 }
 
 // EditMessage edits a previously sent message
@@ -662,10 +1849,10 @@ func (h *Handlers) EditMessage(w http.ResponseWriter, r *http.Request) {
 		Str("messageId", req.MessageID).
 		Msg("Editing message")
 
-	newMsgID, err := h.manager.EditMessage(req.InstanceID, chatID, req.MessageID, req.NewText)
+	newMsgID, err := h.manager.EditMessage(req.InstanceID, chatID, req.MessageID, req.NewText, req.Mode, req.MediaType)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to edit message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -677,10 +1864,11 @@ func (h *Handlers) EditMessage(w http.ResponseWriter, r *http.Request) {
 
 // ReactMessageRequest represents reaction request
 type ReactMessageRequest struct {
-	InstanceID string `json:"instanceId"`
-	ChatID     string `json:"chatId"`
-	MessageID  string `json:"messageId"`
-	Reaction   string `json:"reaction"`
+	InstanceID  string `json:"instanceId"`
+	ChatID      string `json:"chatId"`
+	MessageID   string `json:"messageId"`
+	Reaction    string `json:"reaction"`
+	Participant string `json:"participant,omitempty"` // Sender of the message in groups
 }
 
 // ReactToMessage sends a reaction to a message
@@ -705,10 +1893,10 @@ func (h *Handlers) ReactToMessage(w http.ResponseWriter, r *http.Request) {
 		Str("reaction", req.Reaction).
 		Msg("Sending reaction")
 
-	err := h.manager.ReactToMessage(req.InstanceID, chatID, req.MessageID, req.Reaction)
+	err := h.manager.ReactToMessage(req.InstanceID, chatID, req.MessageID, req.Reaction, req.Participant)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to send reaction")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -758,7 +1946,7 @@ func (h *Handlers) MarkChatAsRead(w http.ResponseWriter, r *http.Request) {
 	err := h.manager.MarkChatAsRead(req.InstanceID, chatID, messageIDs)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to mark chat as read")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -767,12 +1955,132 @@ func (h *Handlers) MarkChatAsRead(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// MarkChatAsUnreadRequest represents mark chat as unread request
+type MarkChatAsUnreadRequest struct {
+	InstanceID string `json:"instanceId"`
+	ChatID     string `json:"chatId"`
+}
+
+// MarkChatAsUnread flags a chat as unread on the phone
+func (h *Handlers) MarkChatAsUnread(w http.ResponseWriter, r *http.Request) {
+	var req MarkChatAsUnreadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.ChatID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and chatId are required")
+		return
+	}
+
+	if err := h.manager.MarkChatAsUnread(req.InstanceID, req.ChatID); err != nil {
+		log.Error().Err(err).Msg("Failed to mark chat as unread")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"chatId": req.ChatID,
+		"unread": true,
+	})
+}
+
+// StarMessageRequest represents star message request
+type StarMessageRequest struct {
+	InstanceID string `json:"instanceId"`
+	ChatID     string `json:"chatId"`
+	MessageID  string `json:"messageId"`
+	Starred    *bool  `json:"starred,omitempty"` // Defaults to true; false unstars
+	FromMe     bool   `json:"fromMe,omitempty"`  // Only used for messages missing from the store
+}
+
+// StarMessage stars or unstars a message
+func (h *Handlers) StarMessage(w http.ResponseWriter, r *http.Request) {
+	var req StarMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.ChatID == "" || req.MessageID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId, chatId and messageId are required")
+		return
+	}
+
+	starred := req.Starred == nil || *req.Starred
+	if err := h.manager.StarMessage(req.InstanceID, req.ChatID, req.MessageID, req.FromMe, starred); err != nil {
+		log.Error().Err(err).Msg("Failed to star message")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"messageId": req.MessageID,
+		"starred":   starred,
+	})
+}
+
+// GetStarredMessages lists starred stored messages, optionally of one chat (?chatId=)
+func (h *Handlers) GetStarredMessages(w http.ResponseWriter, r *http.Request) {
+	instanceID := mux.Vars(r)["instanceId"]
+
+	chatID := r.URL.Query().Get("chatId")
+	if chatID != "" && !strings.Contains(chatID, "@") {
+		chatID = cleanPhoneNumber(chatID) + "@s.whatsapp.net"
+	}
+
+	messages, err := h.manager.GetStarredMessages(instanceID, chatID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, messages)
+}
+
+// PinMessageRequest represents pin message request
+type PinMessageRequest struct {
+	InstanceID string `json:"instanceId"`
+	ChatID     string `json:"chatId"`
+	MessageID  string `json:"messageId"`
+	Pin        *bool  `json:"pin,omitempty"`      // Defaults to true; false unpins
+	Duration   string `json:"duration,omitempty"` // 24h, 7d (default) or 30d
+}
+
+// PinMessage pins or unpins a message in a chat
+func (h *Handlers) PinMessage(w http.ResponseWriter, r *http.Request) {
+	var req PinMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.ChatID == "" || req.MessageID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId, chatId and messageId are required")
+		return
+	}
+
+	pin := req.Pin == nil || *req.Pin
+	if err := h.manager.PinMessage(req.InstanceID, req.ChatID, req.MessageID, pin, req.Duration); err != nil {
+		log.Error().Err(err).Msg("Failed to pin message")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"messageId": req.MessageID,
+		"pinned":    pin,
+	})
+}
+
 // DeleteMessageRequest represents delete message request
 type DeleteMessageRequest struct {
 	InstanceID  string `json:"instanceId"`
 	ChatID      string `json:"chatId"`
 	MessageID   string `json:"messageId"`
 	ForEveryone bool   `json:"forEveryone"`
+	FromMe      bool   `json:"fromMe,omitempty"` // Only used for messages missing from the store
 }
 
 // DeleteMessage deletes a message
@@ -797,10 +2105,10 @@ func (h *Handlers) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		Bool("forEveryone", req.ForEveryone).
 		Msg("Deleting message")
 
-	err := h.manager.DeleteMessage(req.InstanceID, chatID, req.MessageID, req.ForEveryone)
+	err := h.manager.DeleteMessage(req.InstanceID, chatID, req.MessageID, req.ForEveryone, req.FromMe)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to delete message")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -813,11 +2121,50 @@ func (h *Handlers) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 // WebSocket Handler
 // ============================================
 
-// WebSocketHandler handles WebSocket connections for real-time events
+// writeWSEvent writes an event as a JSON text frame, or as a MessagePack
+// binary frame (using the same field names) when binary is set
+func writeWSEvent(conn *websocket.Conn, binary bool, event interface{}) error {
+	if !binary {
+		return conn.WriteJSON(event)
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetOmitEmpty(true)
+	if err := enc.Encode(event); err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+// WebSocketHandler handles WebSocket connections for real-time events.
+// Pass ?format=msgpack to receive events as MessagePack binary frames, and
+// ?lastEventId=N to first receive the journaled events published after N.
 func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	instanceID := vars["instanceId"]
 
+	var binary bool
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "msgpack":
+		binary = true
+	default:
+		errorResponse(w, http.StatusBadRequest, "Invalid format (json or msgpack)")
+		return
+	}
+
+	var lastEventID int64
+	if id := r.URL.Query().Get("lastEventId"); id != "" {
+		parsed, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid lastEventId")
+			return
+		}
+		lastEventID = parsed
+	}
+
 	// Upgrade to WebSocket
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -826,27 +2173,46 @@ func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	log.Info().Str("instanceId", instanceID).Msg("WebSocket connected")
+	log.Info().Str("instanceId", instanceID).Bool("msgpack", binary).Msg("WebSocket connected")
 
 	// Subscribe to events
-	eventChan := h.manager.Subscribe(instanceID)
+	eventChan := h.manager.SubscribeStream(instanceID, "websocket "+r.RemoteAddr)
 	defer h.manager.Unsubscribe(instanceID, eventChan)
 
-	// Send initial status
-	status, info := h.manager.GetStatus(instanceID)
-	_, qrBase64 := h.manager.GetQRCode(instanceID)
-
-	initialEvent := map[string]interface{}{
-		"type":       "status",
-		"instanceId": instanceID,
-		"data": map[string]interface{}{
-			"status":   status,
-			"waNumber": info["waNumber"],
-			"waName":   info["waName"],
-			"qrCode":   qrBase64,
+	stream := &eventStream{
+		manager:    h.manager,
+		ctx:        r.Context(),
+		instanceID: instanceID,
+		lastSent:   lastEventID,
+		filter:     whatsapp.ParseEventFilter(r.URL.Query().Get("events")),
+		write: func(event whatsapp.Event) error {
+			return writeWSEvent(conn, binary, event)
 		},
 	}
-	conn.WriteJSON(initialEvent)
+
+	// Send initial status
+	if stream.filter.Allows("status") {
+		status, info := h.manager.GetStatus(instanceID)
+		_, qrBase64 := h.manager.GetQRCode(instanceID)
+
+		initialEvent := map[string]interface{}{
+			"type":       "status",
+			"instanceId": instanceID,
+			"data": map[string]interface{}{
+				"status":   status,
+				"waNumber": info["waNumber"],
+				"waName":   info["waName"],
+				"qrCode":   qrBase64,
+			},
+		}
+		writeWSEvent(conn, binary, initialEvent)
+	}
+	if lastEventID > 0 {
+		if err := stream.replay(); err != nil {
+			log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to replay events to WebSocket")
+			return
+		}
+	}
 
 	// Handle ping/pong
 	conn.SetPongHandler(func(string) error {
@@ -873,8 +2239,12 @@ func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	// Event loop
 	for {
 		select {
-		case event := <-eventChan:
-			if err := conn.WriteJSON(event); err != nil {
+		case event, ok := <-eventChan:
+			if !ok {
+				writeWSCloseSlow(conn)
+				return
+			}
+			if err := stream.deliver(event, eventChan); err != nil {
 				log.Error().Err(err).Msg("Failed to write to WebSocket")
 				return
 			}
@@ -887,6 +2257,86 @@ func (h *Handlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 		case <-done:
 			log.Info().Str("instanceId", instanceID).Msg("WebSocket disconnected")
 			return
+
+		case <-h.closing:
+			writeWSClose(conn)
+			return
+		}
+	}
+}
+
+// WebSocketAll streams the events of every instance over one connection, for
+// dashboards orchestrating many instances (admin only). Events carry instanceId.
+func (h *Handlers) WebSocketAll(w http.ResponseWriter, r *http.Request) {
+	var binary bool
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "msgpack":
+		binary = true
+	default:
+		errorResponse(w, http.StatusBadRequest, "Invalid format (json or msgpack)")
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade WebSocket")
+		return
+	}
+	defer conn.Close()
+
+	log.Info().Bool("msgpack", binary).Msg("Firehose WebSocket connected")
+
+	filter := whatsapp.ParseEventFilter(r.URL.Query().Get("events"))
+
+	eventChan := h.manager.SubscribeStream(whatsapp.AllInstances, "firehose "+r.RemoteAddr)
+	defer h.manager.Unsubscribe(whatsapp.AllInstances, eventChan)
+
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-eventChan:
+			if !ok {
+				writeWSCloseSlow(conn)
+				return
+			}
+			if !filter.Allows(event.Type) {
+				continue
+			}
+			if err := writeWSEvent(conn, binary, event); err != nil {
+				log.Error().Err(err).Msg("Failed to write to firehose WebSocket")
+				return
+			}
+
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-done:
+			log.Info().Msg("Firehose WebSocket disconnected")
+			return
+
+		case <-h.closing:
+			writeWSClose(conn)
+			return
 		}
 	}
 }
@@ -911,13 +2361,73 @@ func (h *Handlers) GetContactInfo(w http.ResponseWriter, r *http.Request) {
 	contactInfo, err := h.manager.GetContactInfo(instanceID, jid)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get contact info")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
 	successResponse(w, contactInfo)
 }
 
+// ResolveContactsRequest represents a bulk contact resolution request
+type ResolveContactsRequest struct {
+	JIDs []string `json:"jids"`
+}
+
+// ResolveContacts resolves several JIDs (LID or phone number) in one request
+func (h *Handlers) ResolveContacts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req ResolveContactsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.JIDs) == 0 {
+		errorResponse(w, http.StatusBadRequest, "at least 1 jid is required")
+		return
+	}
+
+	contacts, err := h.manager.ResolveContacts(instanceID, req.JIDs)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, contacts)
+}
+
+// GetUserInfoRequest represents a user info request
+type GetUserInfoRequest struct {
+	JIDs []string `json:"jids"` // Numbers or JIDs
+}
+
+// GetUserInfo returns the public profile of several users
+func (h *Handlers) GetUserInfo(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req GetUserInfoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.JIDs) == 0 {
+		errorResponse(w, http.StatusBadRequest, "at least 1 jid is required")
+		return
+	}
+
+	users, err := h.manager.GetUserInfo(instanceID, req.JIDs)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, users)
+}
+
 // ============================================
 // Media Download Handler
 // ============================================
@@ -983,10 +2493,10 @@ func (h *Handlers) DownloadMedia(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Download the media
-	data, mimetype, err := h.manager.DownloadMedia(req.InstanceID, mediaInfo)
+	data, mimetype, err := h.manager.DownloadMedia(r.Context(), req.InstanceID, mediaInfo)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to download media")
-		errorResponse(w, http.StatusInternalServerError, err.Error())
+		managerErrorResponse(w, err)
 		return
 	}
 
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// ============================================
+// Health Handlers
+// ============================================
+
+// HealthReady answers 200 when the service can take traffic and 503 otherwise,
+// with the outcome of each check. /health stays a plain liveness probe.
+func (h *Handlers) HealthReady(w http.ResponseWriter, r *http.Request) {
+	ready, checks := h.manager.Ready(r.Context())
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	jsonResponse(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// HealthInstances returns how many instances are connected, with the state and
+// last activity of each one for the admin token and tenant keys
+func (h *Handlers) HealthInstances(w http.ResponseWriter, r *http.Request) {
+	instances, err := h.manager.InstancesHealth()
+	if err != nil {
+		jsonResponse(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "not_ready",
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	// Tenant API keys only see their own instances
+	visible := instances[:0]
+	connected := 0
+	for _, inst := range instances {
+		if !h.tenantCanSee(r.Context(), inst.InstanceID) {
+			continue
+		}
+		visible = append(visible, inst)
+		if inst.Connected {
+			connected++
+		}
+	}
+	instances = visible
+
+	result := map[string]interface{}{
+		"total":     len(instances),
+		"connected": connected,
+	}
+	// Anonymous probes only get the counts, not instance IDs and activity
+	if _, ok := requestTenant(r.Context()); ok || h.isAdminRequest(r) {
+		result["instances"] = instances
+	}
+	jsonResponse(w, http.StatusOK, result)
+}
+
+// isAdminRequest reports whether a request carries the admin token
+func (h *Handlers) isAdminRequest(r *http.Request) bool {
+	token := tenantKey(r)
+	return h.adminToken != "" && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Live Location Handlers
+// ============================================
+
+// StartLiveLocationRequest represents a request to start sharing live location
+type StartLiveLocationRequest struct {
+	InstanceID      string  `json:"instanceId"`
+	To              string  `json:"to"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+	Accuracy        uint32  `json:"accuracy,omitempty"` // Meters
+	Speed           float32 `json:"speed,omitempty"`    // Meters per second
+	Heading         uint32  `json:"heading,omitempty"`  // Degrees clockwise from north
+	Caption         string  `json:"caption,omitempty"`
+	DurationSeconds int     `json:"durationSeconds,omitempty"` // Defaults to 15 minutes, max 8 hours
+}
+
+// UpdateLiveLocationRequest represents a new position of a live location session
+type UpdateLiveLocationRequest struct {
+	InstanceID string  `json:"instanceId"`
+	SessionID  string  `json:"sessionId"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Accuracy   uint32  `json:"accuracy,omitempty"`
+	Speed      float32 `json:"speed,omitempty"`
+	Heading    uint32  `json:"heading,omitempty"`
+}
+
+// StopLiveLocationRequest represents a request to stop a live location session
+type StopLiveLocationRequest struct {
+	InstanceID string `json:"instanceId"`
+	SessionID  string `json:"sessionId"`
+}
+
+// StartLiveLocation starts sharing live location with a contact
+func (h *Handlers) StartLiveLocation(w http.ResponseWriter, r *http.Request) {
+	var req StartLiveLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and to are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.To)
+
+	log.Info().
+		Str("instanceId", req.InstanceID).
+		Str("to", to).
+		Int("durationSeconds", req.DurationSeconds).
+		Msg("Starting live location")
+
+	session, err := h.manager.StartLiveLocation(req.InstanceID, to, req.Caption, time.Duration(req.DurationSeconds)*time.Second, whatsapp.LiveLocationUpdate{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Accuracy:  req.Accuracy,
+		Speed:     req.Speed,
+		Heading:   req.Heading,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to start live location")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, session)
+}
+
+// UpdateLiveLocation sends a new position for an active live location session
+func (h *Handlers) UpdateLiveLocation(w http.ResponseWriter, r *http.Request) {
+	var req UpdateLiveLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.SessionID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and sessionId are required")
+		return
+	}
+
+	session, err := h.manager.UpdateLiveLocation(req.InstanceID, req.SessionID, whatsapp.LiveLocationUpdate{
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Accuracy:  req.Accuracy,
+		Speed:     req.Speed,
+		Heading:   req.Heading,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("sessionId", req.SessionID).Msg("Failed to update live location")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, session)
+}
+
+// StopLiveLocation stops a live location session
+func (h *Handlers) StopLiveLocation(w http.ResponseWriter, r *http.Request) {
+	var req StopLiveLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.SessionID == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId and sessionId are required")
+		return
+	}
+
+	if err := h.manager.StopLiveLocation(req.InstanceID, req.SessionID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"sessionId": req.SessionID,
+		"status":    "stopped",
+	})
+}
+
+// GetLiveLocations lists the active live location sessions of an instance
+func (h *Handlers) GetLiveLocations(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	sessions, err := h.manager.GetLiveLocations(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, sessions)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Stored Media Handlers
+// ============================================
+
+// GetMediaThumbnail returns a resized JPEG thumbnail of a stored image or video
+func (h *Handlers) GetMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	mediaID := vars["mediaId"]
+
+	size := whatsapp.DefaultThumbnailSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil || parsed <= 0 || parsed > whatsapp.MaxThumbnailSize {
+			errorResponse(w, http.StatusBadRequest, "Invalid size (1-"+strconv.Itoa(whatsapp.MaxThumbnailSize)+")")
+			return
+		}
+		size = parsed
+	}
+
+	thumb, err := h.manager.GetThumbnail(instanceID, mediaID, size)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("mediaId", mediaID).Msg("Failed to get thumbnail")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(thumb)
+}
+
+// GetMessageMedia returns the media of a stored message, downloading it from
+// WhatsApp on demand when the media policy didn't download it with the message
+func (h *Handlers) GetMessageMedia(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	messageID := vars["messageId"]
+
+	media, err := h.manager.FetchMessageMedia(r.Context(), instanceID, messageID)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("messageId", messageID).Msg("Failed to fetch message media")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	writeMedia(w, media)
+}
+
+// FetchMedia serves the media a signed URL from a message event grants access to
+func (h *Handlers) FetchMedia(w http.ResponseWriter, r *http.Request) {
+	media, err := h.manager.FetchMediaByToken(r.Context(), mux.Vars(r)["mediaToken"])
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch media by token")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	writeMedia(w, media)
+}
+
+// writeMedia writes downloaded media as the response body
+func writeMedia(w http.ResponseWriter, media *whatsapp.MessageMedia) {
+	contentType := media.Mimetype
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(media.Data)))
+	if media.FileName != "" {
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": media.FileName}))
+	}
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(media.Data)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Channel (Newsletter) Handlers
+// ============================================
+
+// NewsletterRequest identifies a channel by JID (123@newsletter) or invite link
+type NewsletterRequest struct {
+	Newsletter string `json:"newsletter"`
+}
+
+// PublishNewsletterRequest represents a channel post request
+type PublishNewsletterRequest struct {
+	Text string `json:"text"`
+}
+
+// GetNewsletters lists the channels followed by an instance
+func (h *Handlers) GetNewsletters(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	newsletters, err := h.manager.GetNewsletters(instanceID)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to get channels")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, newsletters)
+}
+
+// FollowNewsletter follows a channel
+func (h *Handlers) FollowNewsletter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req NewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Newsletter == "" {
+		errorResponse(w, http.StatusBadRequest, "newsletter is required")
+		return
+	}
+
+	info, err := h.manager.FollowNewsletter(instanceID, req.Newsletter)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to follow channel")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, info)
+}
+
+// UnfollowNewsletter stops following a channel
+func (h *Handlers) UnfollowNewsletter(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	var req NewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Newsletter == "" {
+		errorResponse(w, http.StatusBadRequest, "newsletter is required")
+		return
+	}
+
+	if err := h.manager.UnfollowNewsletter(instanceID, req.Newsletter); err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to unfollow channel")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{"status": "unfollowed"})
+}
+
+// GetNewsletterMessages returns posts of a channel
+func (h *Handlers) GetNewsletterMessages(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	newsletter := vars["jid"]
+	query := r.URL.Query()
+
+	count := whatsapp.DefaultNewsletterMessages
+	if c := query.Get("count"); c != "" {
+		parsed, err := strconv.Atoi(c)
+		if err != nil || parsed <= 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid count")
+			return
+		}
+		count = min(parsed, whatsapp.MaxNewsletterMessages)
+	}
+
+	var before int
+	if b := query.Get("before"); b != "" {
+		parsed, err := strconv.Atoi(b)
+		if err != nil || parsed < 0 {
+			errorResponse(w, http.StatusBadRequest, "Invalid before")
+			return
+		}
+		before = parsed
+	}
+
+	posts, err := h.manager.GetNewsletterMessages(instanceID, newsletter, count, before)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("newsletter", newsletter).Msg("Failed to get channel messages")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, posts)
+}
+
+// PublishNewsletterPost publishes a post in an owned channel
+func (h *Handlers) PublishNewsletterPost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	newsletter := vars["jid"]
+
+	var req PublishNewsletterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Text == "" {
+		errorResponse(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	messageID, err := h.manager.PublishNewsletterPost(instanceID, newsletter, req.Text)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("newsletter", newsletter).Msg("Failed to publish channel post")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"status":    "success",
+		"messageId": messageID,
+	})
+}
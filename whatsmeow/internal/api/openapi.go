@@ -0,0 +1,456 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// OpenAPI Documentation
+// ============================================
+
+// routeDoc describes a route for the OpenAPI document. Request and Response
+// hold zero values of the Go types so schemas are generated from the structs.
+type routeDoc struct {
+	Summary  string
+	Tag      string
+	Query    []string
+	Request  interface{}
+	Response interface{}
+	Produces string // Non-JSON response content type
+}
+
+// routeDocs is keyed by "METHOD /path/template" as registered on the router,
+// without the API version prefix
+var routeDocs = map[string]routeDoc{
+	"GET /health":           {Summary: "Service health check", Tag: "Health"},
+	"GET /health/ready":     {Summary: "Readiness probe (503 when a check fails)", Tag: "Health"},
+	"GET /health/instances": {Summary: "Instance counts, with each instance's state and last activity for admin and tenant keys", Tag: "Health", Response: []whatsapp.InstanceHealth{}},
+
+	"GET /admin/defaults":          {Summary: "Get settings applied to new instances, secrets redacted (requires admin token)", Tag: "Admin", Response: whatsapp.InstanceDefaults{}},
+	"POST /admin/defaults":         {Summary: "Replace settings applied to new instances (requires admin token)", Tag: "Admin", Request: whatsapp.InstanceDefaults{}, Response: whatsapp.InstanceDefaults{}},
+	"GET /admin/deleted-instances": {Summary: "List soft-deleted instances awaiting purge (requires admin token)", Tag: "Admin", Response: []whatsapp.DeletedInstance{}},
+	"GET /admin/overview":          {Summary: "Health of every instance (requires admin token)", Tag: "Admin", Response: whatsapp.AdminOverview{}},
+	"GET /admin/audit":             {Summary: "Audit log of state-changing API calls, newest first (requires admin token)", Tag: "Admin", Query: []string{"instanceId", "actor", "action", "target", "result", "since", "until", "before", "limit"}, Response: []whatsapp.AuditEntry{}},
+
+	"GET /admin/tenants":                            {Summary: "List tenants with their instances and key IDs (requires admin token)", Tag: "Admin", Response: []whatsapp.Tenant{}},
+	"POST /admin/tenants":                           {Summary: "Create a tenant and its first API key, shown once (requires admin token)", Tag: "Admin", Request: TenantRequest{}},
+	"GET /admin/tenants/{tenantId}":                 {Summary: "Get a tenant (requires admin token)", Tag: "Admin", Response: whatsapp.Tenant{}},
+	"PUT /admin/tenants/{tenantId}":                 {Summary: "Update a tenant's name and quotas (requires admin token)", Tag: "Admin", Request: TenantRequest{}, Response: whatsapp.Tenant{}},
+	"DELETE /admin/tenants/{tenantId}":              {Summary: "Delete a tenant and its keys, keeping its instances (requires admin token)", Tag: "Admin"},
+	"GET /admin/tenants/{tenantId}/usage":           {Summary: "Tenant instances and messages sent today against its quotas (requires admin token)", Tag: "Admin", Response: whatsapp.TenantUsage{}},
+	"POST /admin/tenants/{tenantId}/keys":           {Summary: "Issue another API key for a tenant, shown once (requires admin token)", Tag: "Admin"},
+	"DELETE /admin/tenants/{tenantId}/keys/{keyId}": {Summary: "Revoke a tenant API key (requires admin token)", Tag: "Admin"},
+	"GET /tenant/usage":                             {Summary: "Usage and quotas of the tenant owning the API key", Tag: "Admin", Response: whatsapp.TenantUsage{}},
+
+	"POST /instance/{id}/connect":          {Summary: "Connect instance (QR code flow)", Tag: "Instance", Query: []string{"waitFor", "timeout"}, Request: ConnectRequest{}},
+	"POST /instance/{id}/connect-code":     {Summary: "Connect instance with pairing code", Tag: "Instance", Request: ConnectWithCodeRequest{}},
+	"POST /instance/{id}/disconnect":       {Summary: "Disconnect instance", Tag: "Instance"},
+	"POST /instance/{id}/logout":           {Summary: "Log out and soft-delete the instance", Tag: "Instance"},
+	"POST /instance/{id}/restore":          {Summary: "Restore a soft-deleted instance with its settings, proxy and events (messages and media are kept in memory only until a restart)", Tag: "Instance"},
+	"POST /instance/{id}/purge":            {Summary: "Permanently remove a soft-deleted instance", Tag: "Instance"},
+	"POST /instance/{id}/token":            {Summary: "Issue the instance's API token, replacing the previous one (admin token)", Tag: "Instance"},
+	"DELETE /instance/{id}/token":          {Summary: "Revoke the instance's API token (admin token)", Tag: "Instance"},
+	"GET /instance/{id}/status":            {Summary: "Get connection status", Tag: "Instance"},
+	"GET /instance/{id}/stats":             {Summary: "Usage counters for today, this month and all time", Tag: "Instance", Query: []string{"days"}, Response: whatsapp.InstanceStats{}},
+	"POST /instance/{id}/settings":         {Summary: "Update instance settings", Tag: "Instance", Request: SetSettingsRequest{}, Response: map[string]interface{}{}},
+	"POST /instance/{id}/proxy":            {Summary: "Configure instance proxy", Tag: "Instance", Request: SetProxyRequest{}, Response: map[string]string{}},
+	"GET /instance/{id}/business-profile":  {Summary: "Get business profile", Tag: "Instance", Response: whatsapp.BusinessProfile{}},
+	"POST /instance/{id}/business-profile": {Summary: "Update business profile", Tag: "Instance", Request: whatsapp.BusinessProfileUpdate{}, Response: whatsapp.BusinessProfile{}},
+	"GET /instance/{id}/proxy/check":       {Summary: "Check external IP through the proxy", Tag: "Instance"},
+	"GET /instance/{id}/qr":                {Summary: "Get current QR code (base64)", Tag: "Instance"},
+	"GET /instance/{id}/qr.png":            {Summary: "Get current QR code as PNG", Tag: "Instance", Produces: "image/png"},
+
+	"POST /message/text":                              {Summary: "Send text message", Tag: "Messages", Request: SendTextRequest{}},
+	"POST /message/media":                             {Summary: "Send media message (JSON with mediaUrl, or multipart/form-data upload)", Tag: "Messages", Request: SendMediaRequest{}},
+	"POST /message/send":                              {Summary: "Send text or media to a full JID (user, LID, group or channel)", Tag: "Messages", Request: SendToJIDRequest{}},
+	"POST /message/sticker":                           {Summary: "Convert an image, GIF or video to a sticker with pack metadata and send it", Tag: "Messages", Request: SendStickerRequest{}},
+	"POST /message/presence":                          {Summary: "Send chat presence (typing/recording)", Tag: "Messages", Request: SendPresenceRequest{}},
+	"POST /message/location":                          {Summary: "Send location message", Tag: "Messages", Request: SendLocationRequest{}},
+	"POST /message/live-location":                     {Summary: "Start sharing live location", Tag: "Messages", Request: StartLiveLocationRequest{}, Response: whatsapp.LiveLocationSession{}},
+	"POST /message/live-location/update":              {Summary: "Send a new position of a live location session", Tag: "Messages", Request: UpdateLiveLocationRequest{}, Response: whatsapp.LiveLocationSession{}},
+	"POST /message/live-location/stop":                {Summary: "Stop a live location session", Tag: "Messages", Request: StopLiveLocationRequest{}},
+	"GET /message/{instanceId}/live-location":         {Summary: "List active live location sessions", Tag: "Messages", Response: []whatsapp.LiveLocationSession{}},
+	"POST /message/poll":                              {Summary: "Send poll message", Tag: "Messages", Request: SendPollRequest{}},
+	"POST /message/poll/vote":                         {Summary: "Vote on a received poll", Tag: "Messages", Request: VotePollRequest{}},
+	"POST /message/buttons":                           {Summary: "Send quick reply buttons message", Tag: "Messages", Request: SendButtonsRequest{}},
+	"POST /message/list":                              {Summary: "Send list message", Tag: "Messages", Request: SendListRequest{}},
+	"POST /message/status":                            {Summary: "Publish a text, image or video status", Tag: "Messages", Request: SendStatusRequest{}},
+	"POST /message/edit":                              {Summary: "Edit a sent message", Tag: "Messages", Request: EditMessageRequest{}},
+	"POST /message/react":                             {Summary: "React to a message", Tag: "Messages", Request: ReactMessageRequest{}},
+	"POST /message/read":                              {Summary: "Mark messages as read", Tag: "Messages", Request: MarkChatAsReadRequest{}},
+	"POST /message/unread":                            {Summary: "Mark chat as unread", Tag: "Messages", Request: MarkChatAsUnreadRequest{}},
+	"POST /message/star":                              {Summary: "Star or unstar a message", Tag: "Messages", Request: StarMessageRequest{}},
+	"POST /message/pin":                               {Summary: "Pin or unpin a message in a chat", Tag: "Messages", Request: PinMessageRequest{}},
+	"GET /message/{instanceId}/starred":               {Summary: "List starred messages", Tag: "Messages", Query: []string{"chatId"}, Response: []whatsapp.MessageData{}},
+	"POST /message/delete":                            {Summary: "Delete a message", Tag: "Messages", Request: DeleteMessageRequest{}},
+	"POST /message/download":                          {Summary: "Download media from a message", Tag: "Messages", Request: DownloadMediaRequest{}},
+	"GET /message/{instanceId}/{messageId}/media":     {Summary: "Get (downloading on demand) the media of a stored message", Tag: "Messages", Produces: "application/octet-stream"},
+	"GET /message/{instanceId}/{messageId}/sticker":   {Summary: "Get a stored sticker as WebP or PNG", Tag: "Messages", Query: []string{"format"}, Produces: "image/webp"},
+	"GET /message/{instanceId}/{messageId}":           {Summary: "Get a message by ID with its delivery timeline", Tag: "Messages", Response: whatsapp.MessageLookup{}},
+	"GET /message/{instanceId}/{messageId}/status":    {Summary: "Get delivery status timeline of a sent message", Tag: "Messages", Response: whatsapp.MessageStatus{}},
+	"GET /message/{instanceId}/{messageId}/reactions": {Summary: "List the current reactions to a stored message", Tag: "Messages", Response: []whatsapp.MessageReaction{}},
+
+	"GET /contacts/{instanceId}":                          {Summary: "List contacts", Tag: "Contacts", Response: []whatsapp.ContactInfo{}},
+	"POST /contacts/{instanceId}/check":                   {Summary: "Check if one number (number) or several (numbers) are on WhatsApp", Tag: "Contacts", Request: CheckNumberRequest{}, Response: whatsapp.CheckNumberResult{}},
+	"GET /contacts/{instanceId}/export":                   {Summary: "Export the contact store merged with the address book as CSV or JSON", Tag: "Contacts", Query: []string{"format"}, Produces: "application/octet-stream"},
+	"POST /contacts/{instanceId}/import":                  {Summary: "Import contacts (JSON or CSV) into the address book", Tag: "Contacts", Request: ImportContactsRequest{}, Response: whatsapp.ContactImportResult{}},
+	"GET /contacts/{instanceId}/addressbook":              {Summary: "List the address book", Tag: "Contacts", Response: []whatsapp.AddressBookEntry{}},
+	"DELETE /contacts/{instanceId}/addressbook/{phone}":   {Summary: "Remove a contact from the address book", Tag: "Contacts", Response: map[string]string{}},
+	"POST /contacts/{instanceId}/resolve":                 {Summary: "Resolve several contacts (LID or phone) at once", Tag: "Contacts", Request: ResolveContactsRequest{}, Response: []whatsapp.ResolvedContactInfo{}},
+	"POST /contacts/{instanceId}/info":                    {Summary: "Get status text, verified business name, devices and picture ID of users", Tag: "Contacts", Request: GetUserInfoRequest{}, Response: []whatsapp.UserInfo{}},
+	"GET /contacts/{instanceId}/resolve/{jid}":            {Summary: "Resolve contact info (LID to phone)", Tag: "Contacts", Response: whatsapp.ResolvedContactInfo{}},
+	"POST /contacts/{instanceId}/presence/subscribe":      {Summary: "Subscribe to contacts' online status", Tag: "Contacts", Request: SubscribePresenceRequest{}},
+	"GET /contacts/{instanceId}/presence/{jid}":           {Summary: "Get last known online status of a contact", Tag: "Contacts", Response: whatsapp.ContactPresence{}},
+	"GET /chats/{instanceId}":                             {Summary: "List chats by last activity with last message preview and unread counter", Tag: "Chats", Query: []string{"unread", "limit"}, Response: []whatsapp.ChatInfo{}},
+	"POST /chats/{instanceId}/messages":                   {Summary: "Get stored messages of a chat", Tag: "Chats", Request: GetChatMessagesRequest{}, Response: []whatsapp.MessageData{}},
+	"GET /chats/{instanceId}/export":                      {Summary: "Export stored chat history as JSON, CSV, TXT or ZIP with media", Tag: "Chats", Query: []string{"chatId", "format", "media"}, Produces: "application/octet-stream"},
+	"DELETE /chats/{instanceId}/{jid}":                    {Summary: "Clear stored messages of a chat, optionally clearing or deleting it on the phone", Tag: "Chats", Query: []string{"remote", "keepStarred"}},
+	"POST /chats/{instanceId}/{jid}/ai":                   {Summary: "Turn the AI agent on or off for a chat", Tag: "Chats", Request: ChatAIRequest{}, Response: map[string]interface{}{}},
+	"PUT /chats/{instanceId}/{jid}/disappearing":          {Summary: "Set the disappearing messages timer of a chat", Tag: "Chats", Request: DisappearingTimerRequest{}},
+	"GET /media/fetch/{mediaToken}":                       {Summary: "Fetch media through a signed URL from a message event", Tag: "Media", Produces: "application/octet-stream"},
+	"GET /media/{instanceId}/{mediaId}/thumbnail":         {Summary: "Get a JPEG thumbnail of stored media", Tag: "Media", Query: []string{"size"}, Produces: "image/jpeg"},
+	"GET /calls/{instanceId}":                             {Summary: "Get incoming call log", Tag: "Calls", Response: []whatsapp.CallLogEntry{}},
+	"POST /calls/{instanceId}/reject":                     {Summary: "Reject a ringing call", Tag: "Calls", Request: RejectCallRequest{}, Response: whatsapp.CallLogEntry{}},
+	"GET /typebot/{instanceId}/sessions":                  {Summary: "List Typebot sessions", Tag: "Typebot", Response: []whatsapp.TypebotSession{}},
+	"DELETE /typebot/{instanceId}/sessions/{jid}":         {Summary: "End the Typebot session of a chat", Tag: "Typebot", Response: map[string]string{}},
+	"GET /dialogflow/{instanceId}/sessions":               {Summary: "List Dialogflow sessions", Tag: "Dialogflow", Response: []whatsapp.DialogflowSession{}},
+	"DELETE /dialogflow/{instanceId}/sessions/{jid}":      {Summary: "End the Dialogflow session of a chat", Tag: "Dialogflow", Response: map[string]string{}},
+	"GET /autoreply/{instanceId}":                         {Summary: "List auto-reply rules", Tag: "Auto-replies", Response: []whatsapp.AutoReply{}},
+	"POST /autoreply/{instanceId}":                        {Summary: "Create an auto-reply rule", Tag: "Auto-replies", Request: whatsapp.AutoReply{}, Response: whatsapp.AutoReply{}},
+	"PUT /autoreply/{instanceId}/{ruleId}":                {Summary: "Replace an auto-reply rule", Tag: "Auto-replies", Request: whatsapp.AutoReply{}, Response: whatsapp.AutoReply{}},
+	"DELETE /autoreply/{instanceId}/{ruleId}":             {Summary: "Delete an auto-reply rule", Tag: "Auto-replies", Response: map[string]string{}},
+	"GET /campaigns/{instanceId}":                         {Summary: "List campaigns with recipient counts", Tag: "Campaigns", Response: []whatsapp.Campaign{}},
+	"POST /campaigns/{instanceId}":                        {Summary: "Create a campaign and start sending it", Tag: "Campaigns", Request: CreateCampaignRequest{}, Response: whatsapp.Campaign{}},
+	"GET /campaigns/{instanceId}/{campaignId}":            {Summary: "Get a campaign with recipient counts", Tag: "Campaigns", Response: whatsapp.Campaign{}},
+	"GET /campaigns/{instanceId}/{campaignId}/recipients": {Summary: "List campaign recipients and their state", Tag: "Campaigns", Query: []string{"status", "limit", "offset"}, Response: []whatsapp.CampaignRecipient{}},
+	"POST /campaigns/{instanceId}/{campaignId}/pause":     {Summary: "Pause a campaign", Tag: "Campaigns", Response: whatsapp.Campaign{}},
+	"POST /campaigns/{instanceId}/{campaignId}/resume":    {Summary: "Resume a paused campaign", Tag: "Campaigns", Response: whatsapp.Campaign{}},
+	"POST /campaigns/{instanceId}/{campaignId}/cancel":    {Summary: "Cancel a campaign", Tag: "Campaigns", Response: whatsapp.Campaign{}},
+	"GET /groups/{instanceId}":                            {Summary: "List joined groups", Tag: "Groups", Response: []whatsapp.GroupInfo{}},
+	"GET /groups/{instanceId}/{jid}":                      {Summary: "Get group info with participants", Tag: "Groups", Response: whatsapp.GroupInfo{}},
+	"PUT /groups/{instanceId}/{jid}/settings":             {Summary: "Change join approval and member add mode", Tag: "Groups", Request: SetGroupSettingsRequest{}, Response: whatsapp.GroupInfo{}},
+	"GET /newsletters/{instanceId}":                       {Summary: "List followed channels", Tag: "Channels", Response: []whatsapp.NewsletterInfo{}},
+	"POST /newsletters/{instanceId}/follow":               {Summary: "Follow a channel by JID or invite link", Tag: "Channels", Request: NewsletterRequest{}, Response: whatsapp.NewsletterInfo{}},
+	"POST /newsletters/{instanceId}/unfollow":             {Summary: "Unfollow a channel", Tag: "Channels", Request: NewsletterRequest{}, Response: map[string]string{}},
+	"GET /newsletters/{instanceId}/{jid}/messages":        {Summary: "Get channel posts", Tag: "Channels", Query: []string{"count", "before"}, Response: []whatsapp.NewsletterPost{}},
+	"POST /newsletters/{instanceId}/{jid}/post":           {Summary: "Publish a post in an owned channel", Tag: "Channels", Request: PublishNewsletterRequest{}},
+
+	"GET /ws/all":                                              {Summary: "WebSocket stream of every instance's events (admin token)", Tag: "Events", Query: []string{"format", "token"}},
+	"GET /ws/{instanceId}":                                     {Summary: "WebSocket event stream (JSON or MessagePack frames)", Tag: "Events", Query: []string{"format", "lastEventId", "token"}},
+	"GET /events/{instanceId}/poll":                            {Summary: "Long-poll events from the journal", Tag: "Events", Query: []string{"cursor", "timeout", "limit"}, Response: []whatsapp.Event{}},
+	"GET /events/{instanceId}/sse":                             {Summary: "Server-Sent Events stream (resumes from Last-Event-ID)", Tag: "Events", Query: []string{"lastEventId"}, Produces: "text/event-stream"},
+	"POST /events/{instanceId}/webhook/replay":                 {Summary: "Redeliver journaled events to the webhook", Tag: "Events", Request: ReplayWebhookRequest{}, Response: map[string]interface{}{}},
+	"GET /graphql":                                             {Summary: "Run a GraphQL query (subscriptions upgrade to graphql-transport-ws)", Tag: "GraphQL", Query: []string{"query", "operationName", "variables"}, Response: gqlResponse{}},
+	"POST /graphql":                                            {Summary: "Run a GraphQL query", Tag: "GraphQL", Request: gqlRequest{}, Response: gqlResponse{}},
+	"POST /{instanceId}/messages":                              {Summary: "Send a WhatsApp Cloud API message", Tag: "Cloud API", Request: CloudSendMessageRequest{}},
+	"POST /evolution/instance/create":                          {Summary: "Create an instance (Evolution API)", Tag: "Evolution API", Request: EvolutionCreateInstanceRequest{}},
+	"GET /evolution/instance/connect/{instanceName}":           {Summary: "Connect and get the QR or pairing code (Evolution API)", Tag: "Evolution API", Query: []string{"number"}},
+	"GET /evolution/instance/connectionState/{instanceName}":   {Summary: "Connection state (Evolution API)", Tag: "Evolution API"},
+	"GET /evolution/instance/fetchInstances":                   {Summary: "List instances (Evolution API)", Tag: "Evolution API", Query: []string{"instanceName"}},
+	"DELETE /evolution/instance/logout/{instanceName}":         {Summary: "Log out an instance (Evolution API)", Tag: "Evolution API"},
+	"DELETE /evolution/instance/delete/{instanceName}":         {Summary: "Log out and remove an instance (Evolution API)", Tag: "Evolution API"},
+	"POST /evolution/message/sendText/{instanceName}":          {Summary: "Send a text message (Evolution API)", Tag: "Evolution API", Request: EvolutionSendTextRequest{}},
+	"POST /evolution/message/sendMedia/{instanceName}":         {Summary: "Send media (Evolution API)", Tag: "Evolution API", Request: EvolutionSendMediaRequest{}},
+	"POST /evolution/message/sendWhatsAppAudio/{instanceName}": {Summary: "Send an audio message (Evolution API)", Tag: "Evolution API", Request: EvolutionSendAudioRequest{}},
+	"POST /evolution/message/sendLocation/{instanceName}":      {Summary: "Send a location (Evolution API)", Tag: "Evolution API", Request: EvolutionSendLocationRequest{}},
+	"POST /evolution/message/sendReaction/{instanceName}":      {Summary: "React to a message (Evolution API)", Tag: "Evolution API", Request: EvolutionSendReactionRequest{}},
+	"POST /evolution/chat/whatsappNumbers/{instanceName}":      {Summary: "Check which numbers have WhatsApp (Evolution API)", Tag: "Evolution API", Request: EvolutionNumbersRequest{}},
+	"POST /evolution/webhook/set/{instanceName}":               {Summary: "Configure the webhook with Evolution API payloads", Tag: "Evolution API", Request: EvolutionSetWebhookRequest{}},
+	"GET /evolution/webhook/find/{instanceName}":               {Summary: "Webhook configuration (Evolution API)", Tag: "Evolution API"},
+}
+
+var pathParamRegex = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// openAPIBuilder accumulates component schemas while walking types
+type openAPIBuilder struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+// BuildOpenAPISpec generates an OpenAPI 3 document from the routes registered
+// on the router and the Go request/response types in routeDocs
+func BuildOpenAPISpec(router *mux.Router) map[string]interface{} {
+	b := &openAPIBuilder{
+		schemas: map[string]interface{}{},
+		names:   map[reflect.Type]string{},
+	}
+
+	b.schemas["ErrorResponse"] = map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"success": map[string]interface{}{"type": "boolean"},
+			"error":   map[string]interface{}{"type": "string"},
+			"code":    map[string]interface{}{"type": "string"},
+		},
+	}
+
+	paths := map[string]map[string]interface{}{}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+
+		for _, method := range methods {
+			_, path := splitAPIVersion(tpl)
+			doc, ok := routeDocs[method+" "+path]
+			if !ok {
+				doc = routeDoc{Summary: method + " " + tpl}
+			}
+			if paths[tpl] == nil {
+				paths[tpl] = map[string]interface{}{}
+			}
+			paths[tpl][strings.ToLower(method)] = b.operation(tpl, doc)
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Whatsmeow Service API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": b.schemas,
+		},
+	}
+}
+
+// operation builds the OpenAPI operation object for a route
+func (b *openAPIBuilder) operation(tpl string, doc routeDoc) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary": doc.Summary,
+	}
+	if doc.Tag != "" {
+		op["tags"] = []string{doc.Tag}
+	}
+
+	params := make([]interface{}, 0)
+	for _, match := range pathParamRegex.FindAllStringSubmatch(tpl, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, q := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name":   q,
+			"in":     "query",
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": b.schemaFor(reflect.TypeOf(doc.Request)),
+				},
+			},
+		}
+	}
+
+	var success map[string]interface{}
+	if doc.Produces != "" {
+		success = map[string]interface{}{
+			"description": "OK",
+			"content": map[string]interface{}{
+				doc.Produces: map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string", "format": "binary"},
+				},
+			},
+		}
+	} else {
+		data := map[string]interface{}{}
+		if doc.Response != nil {
+			data = b.schemaFor(reflect.TypeOf(doc.Response))
+		}
+		success = map[string]interface{}{
+			"description": "OK",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"success": map[string]interface{}{"type": "boolean"},
+							"data":    data,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	errorRef := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"},
+			},
+		},
+	}
+
+	op["responses"] = map[string]interface{}{
+		"200":     success,
+		"default": errorRef,
+	}
+
+	return op
+}
+
+// schemaFor returns the JSON schema of a Go type, registering named structs
+// as components and referencing them
+func (b *openAPIBuilder) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name, ok := b.names[t]
+		if !ok {
+			name = t.Name()
+			if _, taken := b.schemas[name]; taken {
+				// Same name in another package, prefix with the package name
+				pkg := path.Base(t.PkgPath())
+				name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+			}
+			b.names[t] = name
+			b.schemas[name] = map[string]interface{}{} // Placeholder for recursive types
+			b.schemas[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema builds an object schema from the exported, JSON-visible fields
+func (b *openAPIBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			// Embedded struct fields are encoded inline
+			embedded := b.structSchema(field.Type)
+			for name, prop := range embedded["properties"].(map[string]interface{}) {
+				props[name] = prop
+			}
+			if fields, ok := embedded["required"].([]string); ok {
+				required = append(required, fields...)
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		props[name] = b.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// OpenAPISpec serves the generated OpenAPI document for the given router
+func (h *Handlers) OpenAPISpec(router *mux.Router) http.HandlerFunc {
+	var once sync.Once
+	var spec map[string]interface{}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Routes are all registered before the server starts, build once
+		once.Do(func() {
+			spec = BuildOpenAPISpec(router)
+		})
+		jsonResponse(w, http.StatusOK, spec)
+	}
+}
+
+// swaggerUIPage renders Swagger UI from the CDN pointed at our spec
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <title>Whatsmeow Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/docs/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+// SwaggerUI serves the interactive API documentation
+func (h *Handlers) SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
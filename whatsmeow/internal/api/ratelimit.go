@@ -0,0 +1,164 @@
+package api
+
+import (
+	"crypto/subtle"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================
+// Rate Limiting
+// ============================================
+
+// Route classes limited separately
+const (
+	RouteClassSend  = "send"  // POST /message/* (and /evolution/message/*): each request sends through the WhatsApp session
+	RouteClassWrite = "write" // Other POST/PUT/DELETE requests
+	RouteClassRead  = "read"  // GET requests and GraphQL
+)
+
+// RateLimit is a token bucket: RPS requests per second on average, bursts of
+// up to Burst requests. An RPS of 0 disables the limit.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// Buckets untouched for this long are dropped
+const rateLimitIdle = 10 * time.Minute
+
+// bucket holds the tokens left for one client, instance and route class
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps the buckets of every client
+type rateLimiter struct {
+	limits map[string]RateLimit // route class -> limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+// take removes a token from the bucket of key, or returns how long until one
+// is available
+func (l *rateLimiter) take(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > rateLimitIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	burst := float64(max(limit.Burst, 1))
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.RPS)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.RPS * float64(time.Second))
+}
+
+// RateLimitRequests limits requests per API token (or client IP without a
+// valid one), instance and route class, answering 429 with Retry-After once a
+// bucket is empty. Health checks, docs and preflights are never limited.
+func (h *Handlers) RateLimitRequests(limits map[string]RateLimit) mux.MiddlewareFunc {
+	limiter := &rateLimiter{limits: limits, buckets: make(map[string]*bucket)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Requests forwarded by another cluster node were limited there
+			if r.Method == http.MethodOptions || forwardedByPeer(r, h.clusterSecret) ||
+				strings.HasPrefix(r.URL.Path, "/health") || strings.HasPrefix(r.URL.Path, "/docs") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			class := routeClass(r)
+			limit := limiter.limits[class]
+			if limit.RPS <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r, instanceID, err := withInstanceID(r)
+			if err != nil {
+				bodyErrorResponse(w, err)
+				return
+			}
+
+			key := h.rateLimitClient(r, instanceID) + "|" + instanceID + "|" + class
+			if ok, wait := limiter.take(key, limit, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				codedErrorResponse(w, http.StatusTooManyRequests, CodeRateLimited, "Too many requests, retry later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routeClass returns the rate limit class of a request
+func routeClass(r *http.Request) string {
+	path := unversionedPath(r)
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead, path == "/graphql":
+		return RouteClassRead // GraphQL only reads
+	case strings.HasPrefix(path, "/message/"), strings.HasPrefix(path, "/evolution/message/"), isCloudSendPath(path):
+		return RouteClassSend
+	default:
+		return RouteClassWrite
+	}
+}
+
+// rateLimitClient identifies who is calling: the bearer, instance or query
+// token when it's the admin token, a tenant key or the instance's token,
+// otherwise the client IP. Made-up tokens can't buy fresh buckets.
+func (h *Handlers) rateLimitClient(r *http.Request, instanceID string) string {
+	if token := tenantKey(r); h.knownToken(instanceID, token) {
+		return "token:" + token
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// knownToken reports whether a token is the admin token, a tenant key or the
+// token of an instance
+func (h *Handlers) knownToken(instanceID, token string) bool {
+	if token == "" {
+		return false
+	}
+	if h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1 {
+		return true
+	}
+	if _, ok := h.manager.TenantForKey(token); ok {
+		return true
+	}
+	return instanceID != "" && h.manager.CheckInstanceToken(instanceID, token)
+}
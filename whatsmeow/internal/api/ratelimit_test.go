@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+func TestRateLimiterRefill(t *testing.T) {
+	limiter := &rateLimiter{buckets: make(map[string]*bucket)}
+	limit := RateLimit{RPS: 2, Burst: 3}
+	start := time.Now()
+
+	steps := []struct {
+		after time.Duration // Since start
+		ok    bool
+		wait  time.Duration // Expected Retry-After when refused
+	}{
+		// A new bucket starts full
+		{0, true, 0},
+		{0, true, 0},
+		{0, true, 0},
+		{0, false, 500 * time.Millisecond},
+		// Half a token after 250ms at 2 RPS
+		{250 * time.Millisecond, false, 250 * time.Millisecond},
+		{500 * time.Millisecond, true, 0},
+		{500 * time.Millisecond, false, 500 * time.Millisecond},
+		// Refills stop at the burst however long the bucket sat
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, true, 0},
+		{time.Hour, false, 500 * time.Millisecond},
+	}
+
+	for i, step := range steps {
+		ok, wait := limiter.take("client", limit, start.Add(step.after))
+		if ok != step.ok {
+			t.Fatalf("step %d: allowed = %v, want %v", i, ok, step.ok)
+		}
+		if !ok && (wait < step.wait-time.Millisecond || wait > step.wait+time.Millisecond) {
+			t.Fatalf("step %d: wait = %v, want %v", i, wait, step.wait)
+		}
+	}
+}
+
+func TestRateLimitRequestsPerClass(t *testing.T) {
+	manager, err := whatsapp.NewManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Shutdown(context.Background())
+	h := NewHandlers(manager)
+
+	// Handlers still read the body, and see the instance the limiter found
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			InstanceID string `json:"instanceId"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		shared, _ := r.Context().Value(instanceIDContextKey{}).(string)
+		if req.InstanceID != "" && req.InstanceID != shared {
+			t.Errorf("handler read instance %q, middleware shared %q", req.InstanceID, shared)
+		}
+		successResponse(w, nil)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/instance/{id}/status", echo).Methods("GET")
+	router.HandleFunc("/instance/{id}/disconnect", echo).Methods("POST")
+	router.HandleFunc("/message/text", echo).Methods("POST")
+	router.Use(h.RateLimitRequests(map[string]RateLimit{
+		RouteClassSend:  {RPS: 0.001, Burst: 1},
+		RouteClassWrite: {RPS: 0.001, Burst: 2},
+		RouteClassRead:  {}, // Unlimited
+	}))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"send within burst", "POST", "/message/text", `{"instanceId":"a","to":"1"}`, http.StatusOK},
+		{"send over burst", "POST", "/message/text", `{"instanceId":"a","to":"1"}`, http.StatusTooManyRequests},
+		{"send to another instance has its own bucket", "POST", "/message/text", `{"to":"1","instanceId":"b"}`, http.StatusOK},
+		{"write has its own bucket", "POST", "/instance/a/disconnect", "", http.StatusOK},
+		{"write within burst", "POST", "/instance/a/disconnect", "", http.StatusOK},
+		{"write over burst", "POST", "/instance/a/disconnect", "", http.StatusTooManyRequests},
+		{"read is unlimited", "GET", "/instance/a/status", "", http.StatusOK},
+		{"read is unlimited again", "GET", "/instance/a/status", "", http.StatusOK},
+		{"read is still unlimited", "GET", "/instance/a/status", "", http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: got %d, want %d: %s", tc.name, rec.Code, tc.want, rec.Body)
+		}
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: 429 without Retry-After", tc.name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Sticker Handlers
+// ============================================
+
+// SendStickerRequest represents a request to send an image, GIF or video as a sticker
+type SendStickerRequest struct {
+	InstanceID string `json:"instanceId"`
+	To         string `json:"to"`
+	MediaURL   string `json:"mediaUrl"` // Image, GIF, video or WebP (URL or data URI)
+
+	whatsapp.StickerMetadata
+}
+
+// SendSticker converts media to a WebP sticker with pack metadata and sends it
+func (h *Handlers) SendSticker(w http.ResponseWriter, r *http.Request) {
+	var req SendStickerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.InstanceID == "" || req.To == "" || req.MediaURL == "" {
+		errorResponse(w, http.StatusBadRequest, "instanceId, to, and mediaUrl are required")
+		return
+	}
+
+	to := cleanPhoneNumber(req.To)
+
+	log.Info().
+		Str("instanceId", req.InstanceID).
+		Str("to", to).
+		Str("pack", req.PackName).
+		Msg("Sending sticker")
+
+	msgID, err := h.manager.SendSticker(sendContext(r), req.InstanceID, to, req.MediaURL, req.StickerMetadata)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to send sticker")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"messageId": msgID,
+		"to":        to,
+		"status":    "sent",
+	})
+}
+
+// GetSticker returns a stored sticker as WebP (default) or PNG (?format=png)
+func (h *Handlers) GetSticker(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	messageID := vars["messageId"]
+
+	media, err := h.manager.GetSticker(r.Context(), instanceID, messageID, r.URL.Query().Get("format"))
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("messageId", messageID).Msg("Failed to get sticker")
+		managerErrorResponse(w, err)
+		return
+	}
+
+	writeMedia(w, media)
+}
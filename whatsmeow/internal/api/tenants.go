@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+// ============================================
+// Tenants
+// ============================================
+
+type tenantContextKey struct{}
+
+// Routes that create the instance they name when it doesn't exist yet
+var tenantCreateRoutes = map[string]bool{
+	"/instance/{id}/connect":                     true,
+	"/instance/{id}/connect-code":                true,
+	"/evolution/instance/connect/{instanceName}": true,
+}
+
+// tenantKey returns the token of a request, including the Evolution API
+// apikey header
+func tenantKey(r *http.Request) string {
+	if token := r.Header.Get("apikey"); token != "" {
+		return token
+	}
+	return requestToken(r)
+}
+
+// requestTenant returns the tenant whose API key made a request
+func requestTenant(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok
+}
+
+// tenantCanSee reports whether a request may see an instance: always without
+// a tenant key, and only for the tenant's own instances with one
+func (h *Handlers) tenantCanSee(ctx context.Context, instanceID string) bool {
+	tenantID, ok := requestTenant(ctx)
+	return !ok || h.manager.InstanceTenant(instanceID) == tenantID
+}
+
+// Routes taking multipart bodies that name their instance in a form field,
+// which the handler scopes with scopeInstance once it reads it
+var tenantFormRoutes = map[string]bool{
+	"/message/media": true,
+}
+
+// isFormUpload reports whether a request is a multipart upload to a route
+// that scopes its instance itself
+func isFormUpload(r *http.Request) bool {
+	return tenantFormRoutes[routeTemplate(r)] && strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data")
+}
+
+// Routes that need no token even once tenants exist
+var tenantOpenRoutes = map[string]bool{
+	"/health":                   true,
+	"/health/ready":             true,
+	"/docs":                     true,
+	"/docs/openapi.json":        true,
+	"/media/fetch/{mediaToken}": true,
+}
+
+// TenantScope confines requests made with a tenant API key to the tenant's
+// instances: other instances answer as not found and routes that connect a
+// new instance claim it for the tenant within its instance quota. Once any
+// tenant exists, requests without a tenant key need the admin token or the
+// token of the instance they name.
+func (h *Handlers) TenantScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID, ok := h.manager.TenantForKey(tenantKey(r))
+		if !ok {
+			if h.manager.HasTenants() && r.Method != http.MethodOptions && !tenantOpenRoutes[routeTemplate(r)] && !isFormUpload(r) && !h.nonTenantAllowed(r) {
+				codedErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "An API key is required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tenantContextKey{}, tenantID)
+		if isFormUpload(r) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// Other bodies that aren't JSON aren't checked for the instance they name
+		if r.Method != http.MethodGet && r.ContentLength != 0 && !isJSONRequest(r) {
+			codedErrorResponse(w, http.StatusUnsupportedMediaType, CodeInvalidRequest, "Content-Type must be application/json")
+			return
+		}
+		r, instanceID, err := withInstanceID(r)
+		if err != nil {
+			bodyErrorResponse(w, err)
+			return
+		}
+		if instanceID != "" && h.manager.InstanceTenant(instanceID) != tenantID {
+			if !tenantCreateRoutes[routeTemplate(r)] {
+				managerErrorResponse(w, fmt.Errorf("%w: %s", whatsapp.ErrInstanceNotFound, instanceID))
+				return
+			}
+			if err := h.manager.ClaimInstance(tenantID, instanceID); err != nil {
+				managerErrorResponse(w, err)
+				return
+			}
+			// A connect that fails before creating the instance gives the claim back
+			defer h.manager.ReleaseClaim(tenantID, instanceID)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// nonTenantAllowed reports whether a request without a tenant key carries the
+// admin token or the token of the instance it names
+func (h *Handlers) nonTenantAllowed(r *http.Request) bool {
+	instanceID, _ := requestInstanceID(r)
+	return h.tokenAllows(r, instanceID)
+}
+
+// tokenAllows reports whether a request without a tenant key carries the admin
+// token or the token of instanceID
+func (h *Handlers) tokenAllows(r *http.Request, instanceID string) bool {
+	token := tenantKey(r)
+	if token == "" {
+		return false
+	}
+	if h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1 {
+		return true
+	}
+	return instanceID != "" && h.manager.CheckInstanceToken(instanceID, token)
+}
+
+// scopeInstance applies the TenantScope checks to an instance a handler read
+// from the request itself. It writes the error response and returns false
+// when the request may not use the instance.
+func (h *Handlers) scopeInstance(w http.ResponseWriter, r *http.Request, instanceID string) bool {
+	if _, ok := requestTenant(r.Context()); ok {
+		if !h.tenantCanSee(r.Context(), instanceID) {
+			managerErrorResponse(w, fmt.Errorf("%w: %s", whatsapp.ErrInstanceNotFound, instanceID))
+			return false
+		}
+		return true
+	}
+	if h.manager.HasTenants() && !h.tokenAllows(r, instanceID) {
+		codedErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "An API key is required")
+		return false
+	}
+	return true
+}
+
+// routeTemplate returns the path template of the matched route, without the
+// version prefix
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			_, template = splitAPIVersion(template)
+			return template
+		}
+	}
+	return unversionedPath(r)
+}
+
+// TenantRequest represents a request to create or update a tenant
+type TenantRequest struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	MaxInstances   int    `json:"maxInstances"`
+	MessagesPerDay int    `json:"messagesPerDay"`
+}
+
+// ListTenants lists every tenant with its instances and key IDs
+func (h *Handlers) ListTenants(w http.ResponseWriter, r *http.Request) {
+	successResponse(w, h.manager.Tenants())
+}
+
+// CreateTenant creates a tenant and returns its first API key, which isn't
+// shown again
+func (h *Handlers) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tenant, key, err := h.manager.CreateTenant(whatsapp.Tenant{
+		ID:             req.ID,
+		Name:           req.Name,
+		MaxInstances:   req.MaxInstances,
+		MessagesPerDay: req.MessagesPerDay,
+	})
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"tenant": tenant,
+		"apiKey": key,
+	})
+}
+
+// GetTenant returns a tenant
+func (h *Handlers) GetTenant(w http.ResponseWriter, r *http.Request) {
+	tenant, err := h.manager.GetTenant(mux.Vars(r)["tenantId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, tenant)
+}
+
+// UpdateTenant replaces the name and quotas of a tenant
+func (h *Handlers) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	var req TenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tenant, err := h.manager.UpdateTenant(mux.Vars(r)["tenantId"], whatsapp.Tenant{
+		Name:           req.Name,
+		MaxInstances:   req.MaxInstances,
+		MessagesPerDay: req.MessagesPerDay,
+	})
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, tenant)
+}
+
+// DeleteTenant removes a tenant and its keys, leaving its instances to the admin
+func (h *Handlers) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantId"]
+
+	if err := h.manager.DeleteTenant(tenantID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"tenantId": tenantID,
+		"status":   "deleted",
+	})
+}
+
+// IssueTenantKey adds an API key to a tenant, shown just once
+func (h *Handlers) IssueTenantKey(w http.ResponseWriter, r *http.Request) {
+	tenantID := mux.Vars(r)["tenantId"]
+
+	key, apiKey, err := h.manager.IssueTenantKey(tenantID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]interface{}{
+		"tenantId": tenantID,
+		"keyId":    key.ID,
+		"apiKey":   apiKey,
+	})
+}
+
+// RevokeTenantKey removes an API key of a tenant
+func (h *Handlers) RevokeTenantKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	if err := h.manager.RevokeTenantKey(vars["tenantId"], vars["keyId"]); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"tenantId": vars["tenantId"],
+		"keyId":    vars["keyId"],
+		"status":   "revoked",
+	})
+}
+
+// GetTenantUsage reports a tenant's instances and messages sent today against
+// its quotas
+func (h *Handlers) GetTenantUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.manager.GetTenantUsage(mux.Vars(r)["tenantId"])
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, usage)
+}
+
+// GetOwnTenantUsage reports the usage of the tenant whose API key made the request
+func (h *Handlers) GetOwnTenantUsage(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := requestTenant(r.Context())
+	if !ok {
+		codedErrorResponse(w, http.StatusUnauthorized, CodeUnauthorized, "A tenant API key is required")
+		return
+	}
+
+	usage, err := h.manager.GetTenantUsage(tenantID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, usage)
+}
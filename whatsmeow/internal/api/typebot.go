@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// ============================================
+// Typebot Handlers
+// ============================================
+
+// GetTypebotSessions lists the Typebot sessions of an instance
+func (h *Handlers) GetTypebotSessions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+
+	sessions, err := h.manager.GetTypebotSessions(instanceID)
+	if err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, sessions)
+}
+
+// EndTypebotSession ends the Typebot session of a chat
+func (h *Handlers) EndTypebotSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	instanceID := vars["instanceId"]
+	chatID := vars["jid"]
+
+	if err := h.manager.EndTypebotSession(instanceID, chatID); err != nil {
+		managerErrorResponse(w, err)
+		return
+	}
+
+	successResponse(w, map[string]string{
+		"message": "Typebot session ended",
+	})
+}
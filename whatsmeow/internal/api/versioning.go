@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ============================================
+// API Versioning
+// ============================================
+
+// Each API version has its own path prefix (/v1, /v2, ...) and keeps its
+// request and response shapes for as long as it is served: fields may be
+// added, but none is removed, renamed or changes meaning. A breaking change,
+// such as returning media as a URL instead of base64, ships as a new version
+// next to the old one.
+
+// LegacyAPIVersion is the version served by the unprefixed paths kept from
+// before versioning. It stays v1 when newer versions ship.
+const LegacyAPIVersion = "v1"
+
+// Header telling clients which API version produced the response
+const apiVersionHeader = "X-API-Version"
+
+// Paths served outside the versioned API
+var unversionedPrefixes = []string{"/health", "/docs"}
+
+// splitAPIVersion splits "/v1/instances" into "v1" and "/instances". Paths
+// without a version prefix return an empty version and the path unchanged.
+func splitAPIVersion(path string) (string, string) {
+	rest, ok := strings.CutPrefix(path, "/v")
+	if !ok {
+		return "", path
+	}
+	digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+	if digits == 0 || (len(rest) > digits && rest[digits] != '/') {
+		return "", path
+	}
+	return "v" + rest[:digits], rest[digits:]
+}
+
+// unversionedPath returns the request path without its version prefix
+func unversionedPath(r *http.Request) string {
+	_, path := splitAPIVersion(r.URL.Path)
+	return path
+}
+
+// VersionedPaths serves the legacy unprefixed paths as aliases of
+// LegacyAPIVersion and tags every API response with the version that
+// produced it
+func VersionedPaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range unversionedPrefixes {
+			if r.URL.Path == prefix || strings.HasPrefix(r.URL.Path, prefix+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		version, _ := splitAPIVersion(r.URL.Path)
+		if version == "" {
+			version = LegacyAPIVersion
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = "/" + version + r.URL.Path
+			if r.URL.RawPath != "" {
+				r2.URL.RawPath = "/" + version + r.URL.RawPath
+			}
+			r = r2
+		}
+
+		w.Header().Set(apiVersionHeader, version)
+		next.ServeHTTP(w, r)
+	})
+}
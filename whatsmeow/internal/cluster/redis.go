@@ -0,0 +1,326 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"whatsmeow-service/internal/whatsapp"
+)
+
+const (
+	keyPrefix     = "whatsmeow:"
+	eventsChannel = keyPrefix + "events"
+
+	// Ownership of a node that stops renewing it (crash, partition) expires
+	// after ownerTTL so another node can take the instance over
+	ownerTTL          = 30 * time.Second
+	heartbeatInterval = 10 * time.Second
+
+	// Timeout of the Redis calls made while handling a request or event
+	redisTimeout = 5 * time.Second
+
+	// Events waiting to be published; more are dropped while Redis is slow
+	publishQueueSize = 1024
+)
+
+// Redis records instance ownership in Redis and relays events between nodes
+// over pub/sub. It implements whatsapp.Cluster.
+type Redis struct {
+	client  *redis.Client
+	nodeID  string
+	nodeURL string
+
+	owned   map[string]bool
+	ownedMu sync.Mutex
+
+	// Events queued for the publish worker
+	publish chan envelope
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// envelope is the pub/sub message carrying an event between nodes
+type envelope struct {
+	Node  string         `json:"node"`
+	Event whatsapp.Event `json:"event"`
+}
+
+// NewRedis connects to Redis and registers this node under nodeID, reachable
+// by the other nodes at nodeURL
+func NewRedis(redisURL, nodeID, nodeURL string) (*Redis, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	r := &Redis{
+		client:  redis.NewClient(opts),
+		nodeID:  nodeID,
+		nodeURL: nodeURL,
+		owned:   make(map[string]bool),
+		publish: make(chan envelope, publishQueueSize),
+		stop:    make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		r.client.Close()
+		return nil, fmt.Errorf("failed to reach redis: %w", err)
+	}
+	if err := r.register(ctx); err != nil {
+		r.client.Close()
+		return nil, err
+	}
+
+	r.wg.Add(2)
+	go r.heartbeat()
+	go r.publishLoop()
+
+	log.Info().Str("node", nodeID).Str("url", nodeURL).Msg("Joined cluster")
+	return r, nil
+}
+
+// NodeID returns the ID of this node
+func (r *Redis) NodeID() string {
+	return r.nodeID
+}
+
+func ownerKey(instanceID string) string {
+	return keyPrefix + "owner:" + instanceID
+}
+
+func nodeKey(nodeID string) string {
+	return keyPrefix + "node:" + nodeID
+}
+
+// register publishes the URL of this node
+func (r *Redis) register(ctx context.Context) error {
+	if err := r.client.Set(ctx, nodeKey(r.nodeID), r.nodeURL, ownerTTL).Err(); err != nil {
+		return fmt.Errorf("failed to register node: %w", err)
+	}
+	return nil
+}
+
+// Claim records this node as the owner of an instance. It fails when Redis
+// can't be reached or a live node owns the instance.
+func (r *Redis) Claim(instanceID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.renew(ctx, instanceID); err != nil {
+		return fmt.Errorf("%w: %w", whatsapp.ErrClusterUnavailable, err)
+	}
+
+	r.ownedMu.Lock()
+	r.owned[instanceID] = true
+	r.ownedMu.Unlock()
+	return nil
+}
+
+// renew claims an unowned instance or extends this node's ownership of it
+func (r *Redis) renew(ctx context.Context, instanceID string) error {
+	key := ownerKey(instanceID)
+	ok, err := r.client.SetNX(ctx, key, r.nodeID, ownerTTL).Result()
+	if err != nil {
+		return fmt.Errorf("failed to claim instance: %w", err)
+	}
+	if ok {
+		return nil
+	}
+
+	owner, err := r.client.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to read instance owner: %w", err)
+	}
+	if owner == r.nodeID {
+		return r.client.Expire(ctx, key, ownerTTL).Err()
+	}
+	return fmt.Errorf("instance is owned by node %s", owner)
+}
+
+// Release gives up ownership of an instance, if this node holds it
+func (r *Redis) Release(instanceID string) {
+	r.ownedMu.Lock()
+	delete(r.owned, instanceID)
+	r.ownedMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	r.release(ctx, instanceID)
+}
+
+func (r *Redis) release(ctx context.Context, instanceID string) {
+	key := ownerKey(instanceID)
+	if owner, err := r.client.Get(ctx, key).Result(); err == nil && owner == r.nodeID {
+		r.client.Del(ctx, key)
+	}
+}
+
+// Owner returns the node owning an instance and its URL. Both are empty when
+// the instance has no live owner.
+func (r *Redis) Owner(ctx context.Context, instanceID string) (nodeID, nodeURL string, err error) {
+	nodeID, err = r.client.Get(ctx, ownerKey(instanceID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if nodeID == r.nodeID {
+		return nodeID, r.nodeURL, nil
+	}
+
+	nodeURL, err = r.client.Get(ctx, nodeKey(nodeID)).Result()
+	if errors.Is(err, redis.Nil) {
+		// The node stopped heartbeating; its ownership is about to expire
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return nodeID, nodeURL, nil
+}
+
+// Publish queues an event for the other nodes without waiting on Redis. The
+// event is dropped when the queue is full.
+func (r *Redis) Publish(evt whatsapp.Event) {
+	select {
+	case r.publish <- envelope{Node: r.nodeID, Event: evt}:
+	default:
+		log.Warn().Str("instanceId", evt.InstanceID).Str("type", evt.Type).Msg("Cluster event queue full, dropping event")
+	}
+}
+
+// publishLoop publishes queued events until Close, then the ones still queued
+func (r *Redis) publishLoop() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case env := <-r.publish:
+			r.send(env)
+
+		case <-r.stop:
+			for {
+				select {
+				case env := <-r.publish:
+					r.send(env)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send publishes an event on the events channel
+func (r *Redis) send(env envelope) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		log.Warn().Err(err).Str("type", env.Event.Type).Msg("Failed to encode cluster event")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := r.client.Publish(ctx, eventsChannel, data).Err(); err != nil {
+		log.Warn().Err(err).Str("instanceId", env.Event.InstanceID).Str("type", env.Event.Type).Msg("Failed to publish cluster event")
+	}
+}
+
+// Subscribe delivers the events published by the other nodes until Close
+func (r *Redis) Subscribe(deliver func(whatsapp.Event)) {
+	pubsub := r.client.Subscribe(context.Background(), eventsChannel)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var env envelope
+				if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+					log.Warn().Err(err).Msg("Invalid cluster event")
+					continue
+				}
+				if env.Node == r.nodeID {
+					continue
+				}
+				deliver(env.Event)
+
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// heartbeat keeps this node registered and renews ownership of its instances
+func (r *Redis) heartbeat() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+			if err := r.register(ctx); err != nil {
+				log.Warn().Err(err).Msg("Cluster heartbeat failed")
+			}
+
+			r.ownedMu.Lock()
+			ids := make([]string, 0, len(r.owned))
+			for id := range r.owned {
+				ids = append(ids, id)
+			}
+			r.ownedMu.Unlock()
+
+			for _, id := range ids {
+				if err := r.renew(ctx, id); err != nil {
+					log.Warn().Err(err).Str("instanceId", id).Msg("Failed to renew instance ownership")
+				}
+			}
+			cancel()
+
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Close leaves the cluster, releasing every instance owned by this node so
+// other nodes can take them over right away
+func (r *Redis) Close() error {
+	close(r.stop)
+	r.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	r.ownedMu.Lock()
+	for id := range r.owned {
+		r.release(ctx, id)
+	}
+	r.owned = make(map[string]bool)
+	r.ownedMu.Unlock()
+
+	r.client.Del(ctx, nodeKey(r.nodeID))
+	log.Info().Str("node", r.nodeID).Msg("Left cluster")
+	return r.client.Close()
+}
@@ -0,0 +1,472 @@
+// Package config loads the service configuration from an optional YAML file,
+// overridden by environment variables, and validates it at startup.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as "30s", "5m" or "168h"
+type Duration time.Duration
+
+// UnmarshalYAML parses a duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config is the whole service configuration. Each field can be set in the
+// YAML file (key in the yaml tag) and overridden by the env var in its env tag.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Storage   StorageConfig   `yaml:"storage"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Log       LogConfig       `yaml:"log"`
+	Instances InstancesConfig `yaml:"instances"`
+	Media     MediaConfig     `yaml:"media"`
+	Previews  PreviewsConfig  `yaml:"previews"`
+	Events    EventsConfig    `yaml:"events"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	RateLimit RateLimitConfig `yaml:"rateLimit"`
+	Cluster   ClusterConfig   `yaml:"cluster"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Compat    CompatConfig    `yaml:"compat"`
+}
+
+// ServerConfig is the HTTP server
+type ServerConfig struct {
+	Port            int      `yaml:"port" env:"WHATSMEOW_PORT"`
+	PublicURL       string   `yaml:"publicUrl" env:"WHATSMEOW_PUBLIC_URL"` // Base of the media links in events
+	ReadTimeout     Duration `yaml:"readTimeout" env:"WHATSMEOW_READ_TIMEOUT"`
+	WriteTimeout    Duration `yaml:"writeTimeout" env:"WHATSMEOW_WRITE_TIMEOUT"`
+	IdleTimeout     Duration `yaml:"idleTimeout" env:"WHATSMEOW_IDLE_TIMEOUT"`
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" env:"WHATSMEOW_SHUTDOWN_TIMEOUT"`
+
+	CORS CORSConfig `yaml:"cors"`
+}
+
+// CORSConfig is which browser origins may call the API
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins" env:"WHATSMEOW_CORS_ORIGINS"` // "*", exact origins or "https://*.example.com"
+	AllowCredentials bool     `yaml:"allowCredentials" env:"WHATSMEOW_CORS_CREDENTIALS"`
+	MaxAge           Duration `yaml:"maxAge" env:"WHATSMEOW_CORS_MAX_AGE"` // How long browsers cache preflights
+}
+
+// StorageConfig is where data is kept and how sessions are encrypted
+type StorageConfig struct {
+	DataDir   string `yaml:"dataDir" env:"WHATSMEOW_DATA_DIR"`
+	DBKey     string `yaml:"dbKey" env:"WHATSMEOW_DB_KEY"`          // Encrypts the session database at rest
+	DBKeyFile string `yaml:"dbKeyFile" env:"WHATSMEOW_DB_KEY_FILE"` // Same, read from a mounted secret (takes precedence)
+}
+
+// AuthConfig protects admin-only routes
+type AuthConfig struct {
+	AdminToken string `yaml:"adminToken" env:"WHATSMEOW_ADMIN_TOKEN"`
+}
+
+// LogConfig is the service and whatsmeow client logging
+type LogConfig struct {
+	Level        string `yaml:"level" env:"WHATSMEOW_LOG_LEVEL"`
+	Format       string `yaml:"format" env:"WHATSMEOW_LOG_FORMAT"` // console or json
+	ClientLevel  string `yaml:"clientLevel" env:"WHATSMEOW_CLIENT_LOG_LEVEL"`
+	RedactPhones bool   `yaml:"redactPhones" env:"WHATSMEOW_LOG_REDACT_PHONES"`
+}
+
+// InstancesConfig is the lifecycle of instances
+type InstancesConfig struct {
+	DeleteGrace   Duration `yaml:"deleteGrace" env:"WHATSMEOW_DELETE_GRACE"`
+	QRTimeout     Duration `yaml:"qrTimeout" env:"WHATSMEOW_QR_TIMEOUT"` // 0 disables
+	DefaultRegion string   `yaml:"defaultRegion" env:"WHATSMEOW_DEFAULT_REGION"`
+
+	// Largest group mentionAll may notify every participant of
+	MentionAllLimit int `yaml:"mentionAllLimit" env:"WHATSMEOW_MENTION_ALL_LIMIT"`
+}
+
+// MediaConfig is media downloads and links
+type MediaConfig struct {
+	Workers int      `yaml:"workers" env:"WHATSMEOW_MEDIA_WORKERS"`
+	URLKey  string   `yaml:"urlKey" env:"WHATSMEOW_MEDIA_URL_KEY"`
+	URLTTL  Duration `yaml:"urlTtl" env:"WHATSMEOW_MEDIA_URL_TTL"`
+
+	// Media policy of new instances when /admin/defaults doesn't set one
+	Policy MediaPolicyConfig `yaml:"policy"`
+
+	// Downscaling and recompression of sent images
+	Compression ImageCompressionConfig `yaml:"compression"`
+}
+
+// ImageCompressionConfig is how sent images are compressed when the request
+// doesn't say. Zero values send images untouched.
+type ImageCompressionConfig struct {
+	MaxDimension int `yaml:"maxDimension" env:"WHATSMEOW_IMAGE_MAX_DIMENSION"`
+	Quality      int `yaml:"quality" env:"WHATSMEOW_IMAGE_QUALITY"` // JPEG quality 1-100
+}
+
+// MediaPolicyConfig is how new instances download incoming media
+type MediaPolicyConfig struct {
+	Mode        string           `yaml:"mode" env:"WHATSMEOW_MEDIA_POLICY"` // eager, lazy or off
+	MaxBytes    int64            `yaml:"maxBytes" env:"WHATSMEOW_MEDIA_MAX_BYTES"`
+	Types       []string         `yaml:"types" env:"WHATSMEOW_MEDIA_TYPES"`
+	Limits      map[string]int64 `yaml:"limits"` // Largest media of each type handled at all. YAML only.
+	Concurrency int              `yaml:"concurrency" env:"WHATSMEOW_MEDIA_CONCURRENCY"`
+}
+
+// PreviewsConfig is what link previews and location thumbnails may fetch.
+// Their URLs come from message senders, so private addresses are blocked
+// unless allowed.
+type PreviewsConfig struct {
+	AllowPrivate bool     `yaml:"allowPrivate" env:"WHATSMEOW_PREVIEW_ALLOW_PRIVATE"`
+	DenyDomains  []string `yaml:"denyDomains" env:"WHATSMEOW_PREVIEW_DENY_DOMAINS"` // Also denies their subdomains
+	MaxRedirects int      `yaml:"maxRedirects" env:"WHATSMEOW_PREVIEW_MAX_REDIRECTS"`
+}
+
+// EventsConfig is event delivery to WebSocket and SSE consumers
+type EventsConfig struct {
+	OverflowPolicy string `yaml:"overflowPolicy" env:"WHATSMEOW_EVENT_OVERFLOW"` // journal, drop-oldest or disconnect
+}
+
+// WebhooksConfig is webhook delivery
+type WebhooksConfig struct {
+	Timeout     Duration   `yaml:"timeout" env:"WHATSMEOW_WEBHOOK_TIMEOUT"`
+	RetryDelays []Duration `yaml:"retryDelays" env:"WHATSMEOW_WEBHOOK_RETRY_DELAYS"` // One retry per delay
+}
+
+// RateLimitConfig limits requests per API token (or client IP) and instance,
+// separately for each route class. An RPS of 0 disables the class's limit.
+type RateLimitConfig struct {
+	SendRPS    float64 `yaml:"sendRps" env:"WHATSMEOW_RATE_LIMIT_SEND_RPS"` // POST /message/*
+	SendBurst  int     `yaml:"sendBurst" env:"WHATSMEOW_RATE_LIMIT_SEND_BURST"`
+	WriteRPS   float64 `yaml:"writeRps" env:"WHATSMEOW_RATE_LIMIT_WRITE_RPS"` // Other POST/PUT/DELETE
+	WriteBurst int     `yaml:"writeBurst" env:"WHATSMEOW_RATE_LIMIT_WRITE_BURST"`
+	ReadRPS    float64 `yaml:"readRps" env:"WHATSMEOW_RATE_LIMIT_READ_RPS"` // GET
+	ReadBurst  int     `yaml:"readBurst" env:"WHATSMEOW_RATE_LIMIT_READ_BURST"`
+}
+
+// ClusterConfig joins other nodes through Redis (disabled without redisUrl)
+type ClusterConfig struct {
+	RedisURL string `yaml:"redisUrl" env:"WHATSMEOW_REDIS_URL"`
+	NodeURL  string `yaml:"nodeUrl" env:"WHATSMEOW_NODE_URL"`
+	NodeID   string `yaml:"nodeId" env:"WHATSMEOW_NODE_ID"`        // Defaults to the hostname
+	Secret   string `yaml:"secret" env:"WHATSMEOW_CLUSTER_SECRET"` // Same on every node; proves a request was proxied by a peer
+}
+
+// TracingConfig exports traces to an OTLP/HTTP collector (disabled without an endpoint)
+type TracingConfig struct {
+	Endpoint       string            `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	TracesEndpoint string            `yaml:"tracesEndpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+	Headers        map[string]string `yaml:"headers" env:"OTEL_EXPORTER_OTLP_HEADERS"`
+	ServiceName    string            `yaml:"serviceName" env:"OTEL_SERVICE_NAME"`
+	SampleRatio    float64           `yaml:"sampleRatio" env:"OTEL_TRACES_SAMPLER_ARG"`
+}
+
+// CompatConfig enables routes compatible with other WhatsApp APIs
+type CompatConfig struct {
+	Evolution bool `yaml:"evolution" env:"WHATSMEOW_EVOLUTION_COMPAT"` // Evolution API routes under /evolution
+
+	// Bodies of the templates Cloud API template messages are rendered from,
+	// by template name. YAML only: bodies may contain commas.
+	CloudTemplates map[string]string `yaml:"cloudTemplates"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            8081,
+			ReadTimeout:     Duration(30 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(60 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+			CORS:            CORSConfig{AllowedOrigins: []string{}}, // Same origin only
+		},
+		Storage: StorageConfig{DataDir: "./data"},
+		Log:     LogConfig{Level: "info", Format: "console", ClientLevel: "info"},
+		Instances: InstancesConfig{
+			DeleteGrace:     Duration(7 * 24 * time.Hour),
+			QRTimeout:       Duration(5 * time.Minute),
+			MentionAllLimit: 256,
+		},
+		Media:    MediaConfig{Workers: 8, URLTTL: Duration(time.Hour)},
+		Previews: PreviewsConfig{MaxRedirects: 3},
+		Events:   EventsConfig{OverflowPolicy: "journal"},
+		Webhooks: WebhooksConfig{
+			Timeout:     Duration(10 * time.Second),
+			RetryDelays: []Duration{Duration(time.Second), Duration(5 * time.Second), Duration(30 * time.Second)},
+		},
+		RateLimit: RateLimitConfig{
+			SendRPS: 10, SendBurst: 30,
+			WriteRPS: 20, WriteBurst: 50,
+			ReadRPS: 50, ReadBurst: 100,
+		},
+		Tracing: TracingConfig{ServiceName: "whatsmeow", SampleRatio: 1},
+	}
+}
+
+// Load reads the YAML file at path (skipped when path is ""), applies the
+// environment on top and validates the result
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true) // A misspelled key is an error, not a silently ignored setting
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// applyEnv overrides every field that has an env tag with its variable, when set
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, info := v.Field(i), v.Type().Field(i)
+		if info.Type.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := info.Tag.Get("env")
+		raw, ok := os.LookupEnv(name)
+		if name == "" || !ok || raw == "" {
+			continue
+		}
+		if err := setFromString(field, raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromString parses an env value into a field of any type used by Config
+func setFromString(field reflect.Value, raw string) error {
+	switch field.Interface().(type) {
+	case Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 30s, 5m)", raw)
+		}
+		field.Set(reflect.ValueOf(Duration(d)))
+	case []Duration:
+		var delays []Duration
+		for _, part := range strings.Split(raw, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(part))
+			if err != nil {
+				return fmt.Errorf("%q is not a comma-separated list of durations", raw)
+			}
+			delays = append(delays, Duration(d))
+		}
+		field.Set(reflect.ValueOf(delays))
+	case []string:
+		var values []string
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	case map[string]string:
+		values := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("%q is not a list of key=value pairs", pair)
+			}
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		field.Set(reflect.ValueOf(values))
+	case string:
+		field.SetString(raw)
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		field.SetBool(b)
+	case int, int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		field.SetInt(n)
+	case float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server.port must be between 1 and 65535")
+	}
+	positive := []struct {
+		name string
+		d    Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+		{"media.urlTtl", c.Media.URLTTL},
+		{"webhooks.timeout", c.Webhooks.Timeout},
+	}
+	for _, setting := range positive {
+		if setting.d <= 0 {
+			fail("%s must be positive", setting.name)
+		}
+	}
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.Server.CORS.AllowCredentials {
+				fail("server.cors.allowCredentials requires explicit origins, not *")
+			}
+		} else if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			fail("server.cors.allowedOrigins: %q must be * or start with http:// or https://", origin)
+		} else if strings.HasSuffix(origin, "/") {
+			fail("server.cors.allowedOrigins: %q must not end with /", origin)
+		}
+	}
+	if c.Server.CORS.MaxAge < 0 {
+		fail("server.cors.maxAge must not be negative")
+	}
+	if c.Storage.DataDir == "" {
+		fail("storage.dataDir is required")
+	}
+
+	if !validLogLevel(c.Log.Level) {
+		fail("log.level must be trace, debug, info, warn, error or disabled")
+	}
+	if !validLogLevel(c.Log.ClientLevel) {
+		fail("log.clientLevel must be trace, debug, info, warn, error or disabled")
+	}
+	if format := strings.ToLower(c.Log.Format); format != "console" && format != "json" {
+		fail("log.format must be console or json")
+	}
+
+	if c.Instances.DeleteGrace < 0 {
+		fail("instances.deleteGrace must not be negative")
+	}
+	if c.Instances.QRTimeout < 0 {
+		fail("instances.qrTimeout must not be negative")
+	}
+	if c.Instances.MentionAllLimit <= 0 {
+		fail("instances.mentionAllLimit must be positive")
+	}
+
+	if c.Media.Workers <= 0 {
+		fail("media.workers must be positive")
+	}
+	switch c.Media.Policy.Mode {
+	case "", "eager", "lazy", "off":
+	default:
+		fail("media.policy.mode must be eager, lazy or off")
+	}
+	if c.Media.Policy.MaxBytes < 0 || c.Media.Policy.Concurrency < 0 {
+		fail("media.policy.maxBytes and media.policy.concurrency must not be negative")
+	}
+	for mediaType, limit := range c.Media.Policy.Limits {
+		if limit < 0 {
+			fail("media.policy.limits.%s must not be negative", mediaType)
+		}
+	}
+
+	if c.Media.Compression.MaxDimension < 0 {
+		fail("media.compression.maxDimension must not be negative")
+	}
+	if c.Media.Compression.Quality < 0 || c.Media.Compression.Quality > 100 {
+		fail("media.compression.quality must be between 0 and 100")
+	}
+
+	if c.Previews.MaxRedirects < 0 {
+		fail("previews.maxRedirects must not be negative")
+	}
+
+	switch c.Events.OverflowPolicy {
+	case "journal", "drop-oldest", "disconnect":
+	default:
+		fail("events.overflowPolicy must be journal, drop-oldest or disconnect")
+	}
+
+	for _, d := range c.Webhooks.RetryDelays {
+		if d < 0 {
+			fail("webhooks.retryDelays must not be negative")
+			break
+		}
+	}
+
+	rateLimits := []struct {
+		name  string
+		rps   float64
+		burst int
+	}{
+		{"send", c.RateLimit.SendRPS, c.RateLimit.SendBurst},
+		{"write", c.RateLimit.WriteRPS, c.RateLimit.WriteBurst},
+		{"read", c.RateLimit.ReadRPS, c.RateLimit.ReadBurst},
+	}
+	for _, limit := range rateLimits {
+		if limit.rps < 0 {
+			fail("rateLimit.%sRps must not be negative", limit.name)
+		}
+		if limit.rps > 0 && limit.burst < 1 {
+			fail("rateLimit.%sBurst must be at least 1", limit.name)
+		}
+	}
+
+	if c.Cluster.RedisURL != "" && c.Cluster.NodeURL == "" {
+		fail("cluster.nodeUrl is required when cluster.redisUrl is set")
+	}
+	if c.Cluster.RedisURL != "" && c.Cluster.Secret == "" {
+		fail("cluster.secret is required when cluster.redisUrl is set")
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sampleRatio must be between 0 and 1")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validLogLevel(level string) bool {
+	parsed, err := zerolog.ParseLevel(strings.ToLower(level))
+	return err == nil && parsed != zerolog.NoLevel
+}
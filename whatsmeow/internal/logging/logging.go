@@ -0,0 +1,82 @@
+// Package logging configures the service logger: level, console or JSON
+// output and optional masking of phone numbers.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Output formats
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Config selects how the service logs
+type Config struct {
+	Level        string // trace, debug, info, warn, error (default info)
+	Format       string // console (default) or json
+	RedactPhones bool   // Mask phone numbers and LIDs in every log line
+}
+
+// Setup replaces the global logger. The level is set on the logger rather
+// than globally, so loggers that need their own level (whatsmeow clients) can
+// still go below it.
+func Setup(out io.Writer, cfg Config) error {
+	level := zerolog.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := zerolog.ParseLevel(strings.ToLower(cfg.Level))
+		if err != nil || parsed == zerolog.NoLevel {
+			return fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+		level = parsed
+	}
+
+	if cfg.RedactPhones {
+		out = &redactWriter{out: out}
+	}
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatConsole:
+		out = zerolog.ConsoleWriter{Out: out}
+	case FormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q (console or json)", cfg.Format)
+	}
+
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = zerolog.New(out).Level(level).With().Timestamp().Logger()
+	return nil
+}
+
+// Digit runs as long as international phone numbers and LIDs (11 to 15
+// digits). Unix times in seconds (10 digits) and nanoseconds are left alone.
+var phonePattern = regexp.MustCompile(`\d{11,}`)
+
+// Redact masks phone numbers in s, keeping the first 4 and last 2 digits
+func Redact(s string) string {
+	return phonePattern.ReplaceAllStringFunc(s, func(number string) string {
+		if len(number) > 15 {
+			return number
+		}
+		return number[:4] + strings.Repeat("*", len(number)-6) + number[len(number)-2:]
+	})
+}
+
+// redactWriter masks phone numbers in log lines before writing them. The
+// console writer formats JSON lines, so redacting underneath it covers both.
+type redactWriter struct {
+	out io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write([]byte(Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,119 @@
+// Package tracing sets up OpenTelemetry to export spans to an OTLP/HTTP
+// collector and starts the spans of this service. Without a configured
+// endpoint the global tracer provider stays a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Export batching limits
+const (
+	exportQueueSize = 4096
+	exportBatchSize = 512
+	exportInterval  = 5 * time.Second
+	exportTimeout   = 10 * time.Second
+)
+
+// Instrumentation scope of the spans started here
+const scopeName = "whatsmeow-service"
+
+var provider *sdktrace.TracerProvider
+
+// Config selects where spans are exported
+type Config struct {
+	Endpoint       string            // Collector base URL; spans go to <endpoint>/v1/traces
+	TracesEndpoint string            // Full URL, used instead of Endpoint
+	Headers        map[string]string // Sent with each export, e.g. authentication
+	ServiceName    string
+	SampleRatio    float64 // Share of new traces that are recorded, 0 to 1
+}
+
+// Init installs a tracer provider exporting to the configured collector and
+// the W3C trace context propagator. It returns false when no endpoint is
+// configured.
+func Init(cfg Config) (bool, error) {
+	endpoint := cfg.TracesEndpoint
+	if endpoint == "" && cfg.Endpoint != "" {
+		endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return false, nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+		otlptracehttp.WithTimeout(exportTimeout),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+	if err != nil {
+		return false, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		// Spans are dropped when the queue is full rather than slowing requests down
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxQueueSize(exportQueueSize),
+			sdktrace.WithMaxExportBatchSize(exportBatchSize),
+			sdktrace.WithBatchTimeout(exportInterval),
+		),
+		// Parents decide for remote callers; new traces are sampled by trace ID,
+		// so every service sampling by ratio keeps the same traces
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn().Err(err).Msg("Failed to export traces")
+	}))
+	return true, nil
+}
+
+// Shutdown exports the spans still buffered, until ctx expires
+func Shutdown(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush traces")
+	}
+}
+
+// Start begins an internal span as a child of the span in ctx (or of a remote
+// parent extracted from request headers) and returns a context carrying it
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return StartKind(ctx, name, trace.SpanKindInternal, attrs...)
+}
+
+// StartKind is Start with an explicit span kind
+func StartKind(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(scopeName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// RecordError records err on the span and marks it failed (nil errors are ignored)
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
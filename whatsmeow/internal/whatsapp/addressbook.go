@@ -0,0 +1,240 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Maximum contacts per import
+const maxAddressBookImport = 50000
+
+// AddressBookEntry is a contact of the service-side address book of an
+// instance. Its name and fields fill template variables of sends and campaigns.
+type AddressBookEntry struct {
+	Phone     string            `json:"phone"`
+	Name      string            `json:"name,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"` // Extra columns, e.g. city or plan
+	UpdatedAt int64             `json:"updatedAt,omitempty"`
+}
+
+// ContactImportError is a contact of an import that was skipped
+type ContactImportError struct {
+	Index int    `json:"index"` // Position in the imported list (CSV data line - 2)
+	Phone string `json:"phone"`
+	Error string `json:"error"`
+}
+
+// ContactImportResult summarizes an address book import
+type ContactImportResult struct {
+	Imported int                  `json:"imported"` // New contacts
+	Updated  int                  `json:"updated"`  // Contacts already in the address book
+	Removed  int                  `json:"removed,omitempty"`
+	Skipped  []ContactImportError `json:"skipped,omitempty"`
+}
+
+// exportedContact is a contact as written in contact exports
+type exportedContact struct {
+	Phone        string            `json:"phone"`
+	JID          string            `json:"jid,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	PushName     string            `json:"pushName,omitempty"`
+	BusinessName string            `json:"businessName,omitempty"`
+	Fields       map[string]string `json:"fields,omitempty"`
+}
+
+// ParseContactsCSV reads address book entries from CSV with a header row: a
+// phone (or number) column, an optional name column and extra fields
+func ParseContactsCSV(data string) ([]AddressBookEntry, error) {
+	rows, err := ParseCampaignCSV(data)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]AddressBookEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := AddressBookEntry{Phone: row.Number, Fields: row.Variables}
+		for key, value := range row.Variables {
+			if strings.EqualFold(key, "name") {
+				entry.Name = value
+				delete(entry.Fields, key)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ImportContacts adds contacts to the address book of an instance, updating
+// the ones already there. With replace the contacts left out are removed.
+func (m *Manager) ImportContacts(instanceID string, entries []AddressBookEntry, replace bool) (*ContactImportResult, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	if len(entries) > maxAddressBookImport {
+		return nil, fmt.Errorf("%w: at most %d contacts per import", ErrInvalidInput, maxAddressBookImport)
+	}
+
+	result := &ContactImportResult{}
+	valid := make([]AddressBookEntry, 0, len(entries))
+	now := time.Now().Unix()
+	for i, entry := range entries {
+		phone, err := m.normalizePhone(entry.Phone)
+		if err != nil {
+			result.Skipped = append(result.Skipped, ContactImportError{Index: i, Phone: entry.Phone, Error: err.Error()})
+			continue
+		}
+		entry.Phone = phone
+		entry.Name = strings.TrimSpace(entry.Name)
+		entry.UpdatedAt = now
+		valid = append(valid, entry)
+	}
+
+	var err error
+	result.Imported, result.Updated, result.Removed, err = m.addressBookStore.PutAddressBook(instanceID, valid, replace)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAddressBook lists the address book of an instance by name
+func (m *Manager) GetAddressBook(instanceID string) ([]AddressBookEntry, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	return m.addressBookStore.AddressBook(instanceID)
+}
+
+// DeleteAddressBookEntry removes a contact from the address book
+func (m *Manager) DeleteAddressBookEntry(instanceID, phone string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+	normalized, err := m.normalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	deleted, err := m.addressBookStore.DeleteAddressBookEntry(instanceID, normalized)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s is not in the address book", ErrContactNotFound, phone)
+	}
+	return nil
+}
+
+// addressBookEntry returns the address book entry of a phone number, or nil
+func (m *Manager) addressBookEntry(instanceID, phone string) *AddressBookEntry {
+	entry, err := m.addressBookStore.AddressBookEntry(instanceID, phone)
+	if err != nil {
+		return nil
+	}
+	return entry
+}
+
+// ExportContacts renders the contact store of the instance merged with its
+// address book as JSON or CSV. The CSV can be imported back.
+func (m *Manager) ExportContacts(instanceID, format string) (*ChatExport, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+	if format != ExportJSON && format != ExportCSV {
+		return nil, fmt.Errorf("%w: format must be json or csv", ErrInvalidInput)
+	}
+
+	byPhone := make(map[string]*exportedContact)
+	contacts := make([]*exportedContact, 0)
+	contact := func(phone string) *exportedContact {
+		if c, ok := byPhone[phone]; ok {
+			return c
+		}
+		c := &exportedContact{Phone: phone}
+		byPhone[phone] = c
+		contacts = append(contacts, c)
+		return c
+	}
+
+	inst.mu.RLock()
+	client := inst.Client
+	inst.mu.RUnlock()
+	if client != nil && client.Store != nil && client.Store.Contacts != nil {
+		stored, err := client.Store.Contacts.GetAllContacts(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read contact store: %w", err)
+		}
+		for jid, info := range stored {
+			phone := jid.User
+			if jid.Server == types.HiddenUserServer {
+				if phone = m.lids.phone(jid.User); phone == "" {
+					continue // Unknown number behind a LID
+				}
+			} else if jid.Server != types.DefaultUserServer {
+				continue
+			}
+			c := contact(phone)
+			c.JID = phone + "@" + types.DefaultUserServer
+			c.Name = info.FullName
+			c.PushName = info.PushName
+			c.BusinessName = info.BusinessName
+		}
+	}
+
+	book, err := m.addressBookStore.AddressBook(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range book {
+		c := contact(entry.Phone)
+		if entry.Name != "" {
+			c.Name = entry.Name
+		}
+		c.Fields = entry.Fields
+	}
+
+	sort.Slice(contacts, func(i, j int) bool { return contacts[i].Phone < contacts[j].Phone })
+
+	name := fmt.Sprintf("contacts-%s-%s.%s", instanceID, time.Now().Format("20060102-150405"), format)
+	if format == ExportJSON {
+		data, err := json.MarshalIndent(contacts, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return &ChatExport{FileName: name, ContentType: "application/json", Data: data}, nil
+	}
+
+	var fields []string
+	for _, c := range contacts {
+		for key := range c.Fields {
+			if !slices.Contains(fields, key) {
+				fields = append(fields, key)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(append([]string{"phone", "name", "pushName", "businessName", "jid"}, fields...))
+	for _, c := range contacts {
+		row := []string{c.Phone, c.Name, c.PushName, c.BusinessName, c.JID}
+		for _, key := range fields {
+			row = append(row, c.Fields[key])
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return &ChatExport{FileName: name, ContentType: "text/csv; charset=utf-8", Data: buf.Bytes()}, nil
+}
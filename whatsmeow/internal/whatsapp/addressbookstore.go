@@ -0,0 +1,144 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const addressBookSchema = `
+	CREATE TABLE IF NOT EXISTS address_book (
+		instance_id TEXT    NOT NULL,
+		phone       TEXT    NOT NULL,
+		name        TEXT    NOT NULL,
+		fields      TEXT,
+		updated_at  INTEGER NOT NULL,
+		PRIMARY KEY (instance_id, phone)
+	);
+`
+
+// addressBookStore keeps the address books of instances in events.db
+type addressBookStore struct {
+	db *sql.DB
+}
+
+// PutAddressBook stores contacts in the address book of an instance. Returns
+// how many were new, updated and, with replace, removed.
+func (s *addressBookStore) PutAddressBook(instanceID string, entries []AddressBookEntry, replace bool) (int, int, int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+	}
+	defer tx.Rollback()
+
+	var removed int
+	if replace {
+		phones := make([]string, len(entries))
+		for i, entry := range entries {
+			phones[i] = entry.Phone
+		}
+		// The kept phones go through a temporary table to avoid the variable limit
+		if _, err := tx.Exec(`CREATE TEMP TABLE IF NOT EXISTS import_phones (phone TEXT PRIMARY KEY); DELETE FROM import_phones`); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+		}
+		for _, phone := range phones {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO import_phones (phone) VALUES (?)`, phone); err != nil {
+				return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+			}
+		}
+		res, err := tx.Exec(`DELETE FROM address_book WHERE instance_id = ? AND phone NOT IN (SELECT phone FROM import_phones)`, instanceID)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		removed = int(n)
+	}
+
+	var imported, updated int
+	for _, entry := range entries {
+		fields, _ := json.Marshal(entry.Fields)
+		var exists int
+		tx.QueryRow(`SELECT COUNT(*) FROM address_book WHERE instance_id = ? AND phone = ?`, instanceID, entry.Phone).Scan(&exists)
+		if _, err := tx.Exec(
+			`INSERT INTO address_book (instance_id, phone, name, fields, updated_at) VALUES (?, ?, ?, ?, ?)
+			 ON CONFLICT (instance_id, phone) DO UPDATE SET name = excluded.name, fields = excluded.fields, updated_at = excluded.updated_at`,
+			instanceID, entry.Phone, entry.Name, string(fields), entry.UpdatedAt,
+		); err != nil {
+			return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+		}
+		if exists > 0 {
+			updated++
+		} else {
+			imported++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to store address book: %w", err)
+	}
+	return imported, updated, removed, nil
+}
+
+// AddressBook lists the address book of an instance by name
+func (s *addressBookStore) AddressBook(instanceID string) ([]AddressBookEntry, error) {
+	rows, err := s.db.Query(`SELECT phone, name, fields, updated_at FROM address_book WHERE instance_id = ? ORDER BY name, phone`, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query address book: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]AddressBookEntry, 0)
+	for rows.Next() {
+		entry, err := scanAddressBookEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// AddressBookEntry returns the address book entry of a phone number, or nil
+func (s *addressBookStore) AddressBookEntry(instanceID, phone string) (*AddressBookEntry, error) {
+	row := s.db.QueryRow(`SELECT phone, name, fields, updated_at FROM address_book WHERE instance_id = ? AND phone = ?`, instanceID, phone)
+	entry, err := scanAddressBookEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return entry, err
+}
+
+func scanAddressBookEntry(row interface{ Scan(...any) error }) (*AddressBookEntry, error) {
+	var entry AddressBookEntry
+	var fields sql.NullString
+	if err := row.Scan(&entry.Phone, &entry.Name, &fields, &entry.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan address book entry: %w", err)
+	}
+	if fields.Valid && fields.String != "" {
+		json.Unmarshal([]byte(fields.String), &entry.Fields)
+	}
+	return &entry, nil
+}
+
+// DeleteAddressBookEntry removes a contact from the address book. Returns
+// false if it wasn't there.
+func (s *addressBookStore) DeleteAddressBookEntry(instanceID, phone string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM address_book WHERE instance_id = ? AND phone = ?`, instanceID, phone)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete address book entry: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// deleteInstance removes the address book of an instance
+func (s *addressBookStore) deleteInstance(instanceID string) error {
+	if _, err := s.db.Exec(`DELETE FROM address_book WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance address book: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// AI agent limits
+const (
+	aiTimeout                = 60 * time.Second
+	defaultAIContextMessages = 10
+	maxAIContextMessages     = 50
+)
+
+// aiChatTurns keeps the completions of each chat in order, so a reply is in
+// the context of the next message
+type aiChatTurns struct {
+	mu    sync.Mutex
+	chats map[string]*aiChatTurn // instanceID + "|" + chat -> turn
+}
+
+// aiChatTurn is held while a chat's completion runs; waiting counts the
+// messages holding or waiting for it
+type aiChatTurn struct {
+	mu      sync.Mutex
+	waiting int
+}
+
+func newAIChatTurns() *aiChatTurns {
+	return &aiChatTurns{chats: make(map[string]*aiChatTurn)}
+}
+
+// lock waits for the turn of a chat and returns its unlock
+func (t *aiChatTurns) lock(key string) func() {
+	t.mu.Lock()
+	turn, ok := t.chats[key]
+	if !ok {
+		turn = &aiChatTurn{}
+		t.chats[key] = turn
+	}
+	turn.waiting++
+	t.mu.Unlock()
+
+	turn.mu.Lock()
+	return func() {
+		turn.mu.Unlock()
+		t.mu.Lock()
+		if turn.waiting--; turn.waiting == 0 {
+			delete(t.chats, key)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// AIAgent answers incoming messages with completions from an OpenAI-compatible
+// chat completions API, given the recent messages of the chat as context
+type AIAgent struct {
+	Enabled      bool     `json:"enabled"`
+	BaseURL      string   `json:"baseUrl,omitempty"` // e.g. https://api.openai.com/v1
+	APIKey       string   `json:"apiKey,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	SystemPrompt string   `json:"systemPrompt,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"` // Provider default when unset
+	MaxTokens    int      `json:"maxTokens,omitempty"`   // Provider default when 0
+
+	// Stored messages of the chat sent as context, the new one included (default 10, max 50)
+	ContextMessages int `json:"contextMessages,omitempty"`
+
+	Groups        bool     `json:"groups,omitempty"`        // Also answers in groups
+	DisabledChats []string `json:"disabledChats,omitempty"` // Chats the agent stays out of
+}
+
+// aiMessage is a message of a chat completion request or response
+type aiMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type aiCompletionRequest struct {
+	Model       string      `json:"model"`
+	Messages    []aiMessage `json:"messages"`
+	Temperature *float64    `json:"temperature,omitempty"`
+	MaxTokens   int         `json:"max_tokens,omitempty"`
+}
+
+type aiCompletionResponse struct {
+	Choices []struct {
+		Message aiMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// validate checks an enabled agent has somewhere to send completions
+func (a AIAgent) validate() error {
+	if !a.Enabled {
+		return nil
+	}
+	// Replies are sent into the chat, so the agent mustn't reach internal services
+	if err := validatePublicURL("aiAgent.baseUrl", a.BaseURL); err != nil {
+		return err
+	}
+	if a.Model == "" {
+		return fmt.Errorf("%w: aiAgent.model is required", ErrInvalidInput)
+	}
+	if a.Temperature != nil && (*a.Temperature < 0 || *a.Temperature > 2) {
+		return fmt.Errorf("%w: aiAgent.temperature must be between 0 and 2", ErrInvalidInput)
+	}
+	if a.MaxTokens < 0 {
+		return fmt.Errorf("%w: aiAgent.maxTokens must be >= 0", ErrInvalidInput)
+	}
+	if a.ContextMessages < 0 || a.ContextMessages > maxAIContextMessages {
+		return fmt.Errorf("%w: aiAgent.contextMessages must be between 0 and %d", ErrInvalidInput, maxAIContextMessages)
+	}
+	return nil
+}
+
+// SetAIAgent configures the AI agent of an instance
+func (m *Manager) SetAIAgent(instanceID string, agent AIAgent) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	if err := agent.validate(); err != nil {
+		return err
+	}
+	agent.BaseURL = strings.TrimSuffix(agent.BaseURL, "/")
+
+	inst.mu.Lock()
+	agent.APIKey = keepSecret(agent.APIKey, inst.AIAgent.APIKey)
+	// The disabled chats are managed per chat and survive reconfiguring the agent
+	if agent.DisabledChats == nil {
+		agent.DisabledChats = inst.AIAgent.DisabledChats
+	}
+	inst.AIAgent = agent
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", agent.Enabled).Str("model", agent.Model).Msg("Updated AI agent")
+	return nil
+}
+
+// SetChatAIEnabled turns the AI agent on or off for a single chat
+func (m *Manager) SetChatAIEnabled(instanceID, chatID string, enabled bool) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	to, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(to, "@") {
+		to = to + "@s.whatsapp.net"
+	}
+	if _, err := types.ParseJID(to); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	chatID = m.canonicalChatID(to)
+
+	inst.mu.Lock()
+	disabled := slices.DeleteFunc(slices.Clone(inst.AIAgent.DisabledChats), func(c string) bool { return c == chatID })
+	if !enabled {
+		disabled = append(disabled, chatID)
+	}
+	inst.AIAgent.DisabledChats = disabled
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Str("chat", chatID).Bool("enabled", enabled).Msg("Updated AI agent for chat")
+	return nil
+}
+
+// maybeAIReply sends the message with its chat context to the AI agent and
+// answers with the completion. Reports whether the agent handles the chat.
+func (m *Manager) maybeAIReply(inst *Instance, chat types.JID, msg MessageData) bool {
+	inst.mu.RLock()
+	agent := inst.AIAgent
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if !agent.Enabled || client == nil || msg.FromMe || msg.IsGroup && !agent.Groups {
+		return false
+	}
+	chatID := m.canonicalChatID(chat.String())
+	if slices.Contains(agent.DisabledChats, chatID) {
+		return false
+	}
+	if msg.Body == "" && msg.Caption == "" {
+		return false
+	}
+
+	go func() {
+		// One completion at a time per chat; the context is read once the
+		// previous reply is stored
+		defer m.aiChats.lock(inst.ID + "|" + chatID)()
+
+		transport, err := m.publicTransport(inst)
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("AI agent completion failed")
+			return
+		}
+		defer transport.CloseIdleConnections()
+
+		client.SendChatPresence(context.Background(), chat.ToNonAD(), types.ChatPresenceComposing, types.ChatPresenceMediaText)
+		reply, err := completeAI(&http.Client{Transport: transport, Timeout: aiTimeout}, agent, m.aiContext(inst.ID, chatID, agent))
+		client.SendChatPresence(context.Background(), chat.ToNonAD(), types.ChatPresencePaused, types.ChatPresenceMediaText)
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("AI agent completion failed")
+			return
+		}
+		if reply == "" {
+			return
+		}
+
+		resp, err := m.sendTracked(context.Background(), inst.ID, client, chat.ToNonAD(), &waE2E.Message{
+			Conversation: proto.String(reply),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("Failed to send AI agent reply")
+			return
+		}
+
+		// Sent messages aren't echoed back, so the reply is stored for the next context
+		from := ""
+		if client.Store.ID != nil {
+			from = client.Store.ID.ToNonAD().String()
+		}
+		m.storeMessage(inst.ID, chatID, MessageData{
+			ID:        resp.ID,
+			From:      from,
+			To:        chatID,
+			Body:      reply,
+			Type:      "text",
+			Timestamp: resp.Timestamp.Unix(),
+			FromMe:    true,
+			IsGroup:   msg.IsGroup,
+		})
+
+		log.Info().Str("instanceId", inst.ID).Str("chat", chatID).Msg("AI agent reply sent")
+		m.publishEvent(Event{
+			Type:       "ai_reply",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"chat":      chatID,
+				"messageId": msg.ID,
+				"replyId":   resp.ID,
+				"text":      reply,
+			},
+		})
+	}()
+	return true
+}
+
+// aiContext returns the system prompt and the recent messages of a chat as
+// completion messages
+func (m *Manager) aiContext(instanceID, chatID string, agent AIAgent) []aiMessage {
+	limit := agent.ContextMessages
+	if limit == 0 {
+		limit = defaultAIContextMessages
+	}
+	history, _ := m.GetChatMessages(instanceID, chatID, limit)
+
+	messages := make([]aiMessage, 0, len(history)+1)
+	if agent.SystemPrompt != "" {
+		messages = append(messages, aiMessage{Role: "system", Content: agent.SystemPrompt})
+	}
+	for _, stored := range history {
+		text := stored.Body
+		if text == "" {
+			text = stored.Caption
+		}
+		if text == "" || stored.Revoked {
+			continue
+		}
+		role := "user"
+		if stored.FromMe {
+			role = "assistant"
+		} else if stored.IsGroup && stored.PushName != "" {
+			text = stored.PushName + ": " + text
+		}
+		messages = append(messages, aiMessage{Role: role, Content: text})
+	}
+	return messages
+}
+
+// completeAI requests a chat completion through httpClient and returns the
+// reply text
+func completeAI(httpClient *http.Client, agent AIAgent, messages []aiMessage) (string, error) {
+	body, err := json.Marshal(aiCompletionRequest{
+		Model:       agent.Model,
+		Messages:    messages,
+		Temperature: agent.Temperature,
+		MaxTokens:   agent.MaxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, agent.BaseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if agent.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+agent.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("completion endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var completion aiCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", fmt.Errorf("invalid completion response: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("completion response has no choices")
+	}
+	return strings.TrimSpace(completion.Choices[0].Message.Content), nil
+}
@@ -0,0 +1,86 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Audit entries older than this are pruned
+const auditRetention = 90 * 24 * time.Hour
+
+// Most audit entries returned per query
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// Outcome of an audited API call
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry is a state-changing API call: who made it, on which instance and
+// chat, and how it ended
+type AuditEntry struct {
+	ID         int64  `json:"id"`
+	Timestamp  int64  `json:"timestamp"`
+	Actor      string `json:"actor"` // admin, instance:<id>, tenant:<id>, token:<hash prefix> or anonymous
+	InstanceID string `json:"instanceId,omitempty"`
+	Action     string `json:"action"`           // Method and route, e.g. "POST /message/text"
+	Target     string `json:"target,omitempty"` // Recipient, chat or group the call acted on
+	Status     int    `json:"status"`           // HTTP status of the response
+	Result     string `json:"result"`           // success or failure
+}
+
+// AuditQuery filters the audit log. Entries come newest first; pass the
+// smallest ID of a page as Before to get the next one.
+type AuditQuery struct {
+	InstanceID string
+	Actor      string
+	Action     string // Exact match, or a prefix when it ends with *
+	Target     string
+	Result     string
+	Since      int64 // Unix time, inclusive
+	Until      int64 // Unix time, exclusive
+	Before     int64 // Entry ID
+	Limit      int
+}
+
+// RecordAudit stores an audit entry. Failures are logged, never returned, so
+// auditing can't break the call being audited.
+func (m *Manager) RecordAudit(entry AuditEntry) {
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().Unix()
+	}
+	if entry.Result == "" {
+		entry.Result = AuditSuccess
+		if entry.Status >= 400 {
+			entry.Result = AuditFailure
+		}
+	}
+	if err := m.auditStore.AppendAudit(entry); err != nil {
+		log.Error().Err(err).Str("instanceId", entry.InstanceID).Str("action", entry.Action).Msg("Failed to record audit entry")
+	}
+}
+
+// AuditLog returns the audit entries matching a query, newest first
+func (m *Manager) AuditLog(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	switch {
+	case query.Limit < 0:
+		return nil, fmt.Errorf("%w: limit must be >= 0", ErrInvalidInput)
+	case query.Limit == 0:
+		query.Limit = defaultAuditLimit
+	case query.Limit > maxAuditLimit:
+		query.Limit = maxAuditLimit
+	}
+	switch query.Result {
+	case "", AuditSuccess, AuditFailure:
+	default:
+		return nil, fmt.Errorf("%w: result must be success or failure", ErrInvalidInput)
+	}
+	return m.auditStore.Audit(ctx, query)
+}
@@ -0,0 +1,123 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const auditLogSchema = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp   INTEGER NOT NULL,
+		actor       TEXT    NOT NULL,
+		instance_id TEXT    NOT NULL,
+		action      TEXT    NOT NULL,
+		target      TEXT    NOT NULL,
+		status      INTEGER NOT NULL,
+		result      TEXT    NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_instance ON audit_log (instance_id, id);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log (timestamp);
+`
+
+// auditStore keeps the audit log in events.db. Entries outlive the instances
+// they name, until auditRetention.
+type auditStore struct {
+	db *sql.DB
+}
+
+// newAuditStore starts pruning entries older than auditRetention
+func newAuditStore(db *sql.DB) *auditStore {
+	s := &auditStore{db: db}
+	go s.pruneLoop()
+	return s
+}
+
+// AppendAudit stores an audit entry
+func (s *auditStore) AppendAudit(entry AuditEntry) error {
+	_, err := s.db.Exec(
+		`INSERT INTO audit_log (timestamp, actor, instance_id, action, target, status, result) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp, entry.Actor, entry.InstanceID, entry.Action, entry.Target, entry.Status, entry.Result,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store audit entry: %w", err)
+	}
+	return nil
+}
+
+// Audit returns the audit entries matching a query, newest first
+func (s *auditStore) Audit(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	where := []string{"1 = 1"}
+	var args []interface{}
+	filter := func(clause string, value interface{}) {
+		where = append(where, clause)
+		args = append(args, value)
+	}
+
+	if query.InstanceID != "" {
+		filter("instance_id = ?", query.InstanceID)
+	}
+	if query.Actor != "" {
+		filter("actor = ?", query.Actor)
+	}
+	if prefix, ok := strings.CutSuffix(query.Action, "*"); ok {
+		filter("substr(action, 1, ?) = ?", len(prefix))
+		args = append(args, prefix)
+	} else if query.Action != "" {
+		filter("action = ?", query.Action)
+	}
+	if query.Target != "" {
+		filter("target = ?", query.Target)
+	}
+	if query.Result != "" {
+		filter("result = ?", query.Result)
+	}
+	if query.Since > 0 {
+		filter("timestamp >= ?", query.Since)
+	}
+	if query.Until > 0 {
+		filter("timestamp < ?", query.Until)
+	}
+	if query.Before > 0 {
+		filter("id < ?", query.Before)
+	}
+	args = append(args, query.Limit)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, timestamp, actor, instance_id, action, target, status, result FROM audit_log
+		 WHERE `+strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Timestamp, &entry.Actor, &entry.InstanceID, &entry.Action, &entry.Target, &entry.Status, &entry.Result); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// pruneLoop periodically removes entries older than auditRetention
+func (s *auditStore) pruneLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-auditRetention).Unix()
+		if _, err := s.db.Exec(`DELETE FROM audit_log WHERE timestamp < ?`, cutoff); err != nil {
+			log.Warn().Err(err).Msg("Failed to prune audit log")
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// How an auto-reply rule matches the text of a message
+const (
+	AutoReplyExact    = "exact"    // The whole text, trimmed
+	AutoReplyContains = "contains" // Anywhere in the text
+	AutoReplyRegex    = "regex"    // Regular expression
+)
+
+// AutoReply answers incoming messages whose text matches a pattern with a
+// text or media message
+type AutoReply struct {
+	ID            string `json:"id"`
+	Match         string `json:"match"` // exact, contains or regex
+	Pattern       string `json:"pattern"`
+	CaseSensitive bool   `json:"caseSensitive,omitempty"`
+
+	Text      string `json:"text,omitempty"`      // Reply text, or the caption of a media reply
+	MediaURL  string `json:"mediaUrl,omitempty"`  // Replies with this media instead of plain text
+	MediaType string `json:"mediaType,omitempty"` // image, video, audio or document (detected when empty)
+
+	CooldownMinutes int            `json:"cooldownMinutes,omitempty"` // Minimum interval between replies per chat (0 = none)
+	Hours           *BusinessHours `json:"hours,omitempty"`           // Only replies inside (or outside) this window
+	Groups          bool           `json:"groups,omitempty"`          // Also replies in groups
+	Disabled        bool           `json:"disabled,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// BusinessHours is a daily time window. A start after the end spans midnight.
+type BusinessHours struct {
+	Start    string `json:"start"`              // HH:MM
+	End      string `json:"end"`                // HH:MM
+	Days     []int  `json:"days,omitempty"`     // Weekdays, 0 = Sunday (empty = every day)
+	Timezone string `json:"timezone,omitempty"` // IANA name (empty = server time)
+	Outside  bool   `json:"outside,omitempty"`  // Replies outside the window instead, e.g. "we're closed"
+
+	start, end int // Minutes since midnight
+	loc        *time.Location
+}
+
+// autoReplies holds the auto-reply rules of each instance, persisted to disk,
+// and when each rule and the away message last answered each chat
+type autoReplies struct {
+	mu       sync.Mutex
+	rules    map[string][]AutoReply      // instanceID -> rules, evaluated in order
+	lastSent map[string]map[string]int64 // instanceID -> chat + rule ID -> unix time
+	lastAway map[string]map[string]int64 // instanceID -> chat -> unix time
+	file     string
+}
+
+func newAutoReplies(file string) *autoReplies {
+	return &autoReplies{
+		rules:    make(map[string][]AutoReply),
+		lastSent: make(map[string]map[string]int64),
+		lastAway: make(map[string]map[string]int64),
+		file:     file,
+	}
+}
+
+// loadAutoReplies loads the auto-reply rules from file
+func (m *Manager) loadAutoReplies() {
+	data, err := os.ReadFile(m.autoReplies.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load auto-replies")
+		}
+		return
+	}
+
+	var rules map[string][]AutoReply
+	if err := json.Unmarshal(data, &rules); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal auto-replies")
+		return
+	}
+	for instanceID, list := range rules {
+		for i := range list {
+			if err := list[i].compile(); err != nil {
+				log.Warn().Err(err).Str("instanceId", instanceID).Str("ruleId", list[i].ID).Msg("Disabling invalid auto-reply")
+				list[i].Disabled = true
+			}
+		}
+		m.autoReplies.rules[instanceID] = list
+	}
+}
+
+// saveAutoReplies writes the rules to disk (caller must hold autoReplies.mu)
+func (m *Manager) saveAutoReplies() {
+	data, err := json.MarshalIndent(m.autoReplies.rules, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal auto-replies")
+		return
+	}
+	if err := os.WriteFile(m.autoReplies.file, data, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to save auto-replies")
+	}
+}
+
+// compile validates a rule and prepares its pattern and time window
+func (r *AutoReply) compile() error {
+	switch r.Match {
+	case AutoReplyExact, AutoReplyContains:
+		if strings.TrimSpace(r.Pattern) == "" {
+			return fmt.Errorf("%w: pattern is required", ErrInvalidInput)
+		}
+		r.re = nil
+	case AutoReplyRegex:
+		expr := r.Pattern
+		if !r.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%w: pattern: %v", ErrInvalidInput, err)
+		}
+		r.re = re
+	default:
+		return fmt.Errorf("%w: match must be exact, contains or regex", ErrInvalidInput)
+	}
+
+	if r.Text == "" && r.MediaURL == "" {
+		return fmt.Errorf("%w: text or mediaUrl is required", ErrInvalidInput)
+	}
+	if r.MediaURL != "" && !strings.HasPrefix(r.MediaURL, "http://") && !strings.HasPrefix(r.MediaURL, "https://") {
+		return fmt.Errorf("%w: mediaUrl must be an http(s) URL", ErrInvalidInput)
+	}
+	if r.CooldownMinutes < 0 {
+		return fmt.Errorf("%w: cooldownMinutes must be >= 0", ErrInvalidInput)
+	}
+	if r.Hours != nil {
+		if err := r.Hours.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile parses the window bounds and time zone
+func (h *BusinessHours) compile() error {
+	var err error
+	if h.start, err = parseClock(h.Start); err != nil {
+		return fmt.Errorf("%w: hours.start must be HH:MM", ErrInvalidInput)
+	}
+	if h.end, err = parseClock(h.End); err != nil {
+		return fmt.Errorf("%w: hours.end must be HH:MM", ErrInvalidInput)
+	}
+	for _, day := range h.Days {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("%w: hours.days must be 0 (Sunday) to 6", ErrInvalidInput)
+		}
+	}
+	h.loc = time.Local
+	if h.Timezone != "" {
+		if h.loc, err = time.LoadLocation(h.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %s", ErrInvalidInput, h.Timezone)
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of an HH:MM time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// allows reports whether a rule may reply at a given time
+func (h *BusinessHours) allows(now time.Time) bool {
+	now = now.In(h.loc)
+	minute := now.Hour()*60 + now.Minute()
+
+	open := len(h.Days) == 0 || slices.Contains(h.Days, int(now.Weekday()))
+	if open {
+		if h.start <= h.end {
+			open = minute >= h.start && minute < h.end
+		} else {
+			open = minute >= h.start || minute < h.end
+		}
+	}
+	return open != h.Outside
+}
+
+// matches reports whether the text of a message triggers the rule
+func (r *AutoReply) matches(text string) bool {
+	if r.re != nil {
+		return r.re.MatchString(text)
+	}
+	text, pattern := strings.TrimSpace(text), strings.TrimSpace(r.Pattern)
+	if !r.CaseSensitive {
+		text, pattern = strings.ToLower(text), strings.ToLower(pattern)
+	}
+	if r.Match == AutoReplyExact {
+		return text == pattern
+	}
+	return strings.Contains(text, pattern)
+}
+
+func newAutoReplyID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// GetAutoReplies lists the auto-reply rules of an instance in evaluation order
+func (m *Manager) GetAutoReplies(instanceID string) ([]AutoReply, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	m.autoReplies.mu.Lock()
+	defer m.autoReplies.mu.Unlock()
+	return append([]AutoReply{}, m.autoReplies.rules[instanceID]...), nil
+}
+
+// CreateAutoReply adds a rule after the existing ones, with a generated ID
+// unless one is given
+func (m *Manager) CreateAutoReply(instanceID string, rule AutoReply) (AutoReply, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return AutoReply{}, ErrInstanceNotFound
+	}
+	if err := rule.compile(); err != nil {
+		return AutoReply{}, err
+	}
+	if rule.ID == "" {
+		rule.ID = newAutoReplyID()
+	}
+
+	m.autoReplies.mu.Lock()
+	defer m.autoReplies.mu.Unlock()
+	for _, existing := range m.autoReplies.rules[instanceID] {
+		if existing.ID == rule.ID {
+			return AutoReply{}, fmt.Errorf("%w: auto-reply %s already exists", ErrInvalidInput, rule.ID)
+		}
+	}
+	m.autoReplies.rules[instanceID] = append(m.autoReplies.rules[instanceID], rule)
+	m.saveAutoReplies()
+
+	log.Info().Str("instanceId", instanceID).Str("ruleId", rule.ID).Str("match", rule.Match).Msg("Created auto-reply")
+	return rule, nil
+}
+
+// UpdateAutoReply replaces a rule, keeping its position
+func (m *Manager) UpdateAutoReply(instanceID, ruleID string, rule AutoReply) (AutoReply, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return AutoReply{}, ErrInstanceNotFound
+	}
+	rule.ID = ruleID
+	if err := rule.compile(); err != nil {
+		return AutoReply{}, err
+	}
+
+	m.autoReplies.mu.Lock()
+	defer m.autoReplies.mu.Unlock()
+	rules := m.autoReplies.rules[instanceID]
+	i := slices.IndexFunc(rules, func(r AutoReply) bool { return r.ID == ruleID })
+	if i < 0 {
+		return AutoReply{}, fmt.Errorf("%w: %s", ErrAutoReplyNotFound, ruleID)
+	}
+	rules[i] = rule
+	m.saveAutoReplies()
+
+	log.Info().Str("instanceId", instanceID).Str("ruleId", ruleID).Msg("Updated auto-reply")
+	return rule, nil
+}
+
+// DeleteAutoReply removes a rule
+func (m *Manager) DeleteAutoReply(instanceID, ruleID string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+
+	m.autoReplies.mu.Lock()
+	defer m.autoReplies.mu.Unlock()
+	rules := m.autoReplies.rules[instanceID]
+	i := slices.IndexFunc(rules, func(r AutoReply) bool { return r.ID == ruleID })
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrAutoReplyNotFound, ruleID)
+	}
+	m.autoReplies.rules[instanceID] = slices.Delete(rules, i, i+1)
+	if len(m.autoReplies.rules[instanceID]) == 0 {
+		delete(m.autoReplies.rules, instanceID)
+	}
+	for key := range m.autoReplies.lastSent[instanceID] {
+		if strings.HasSuffix(key, "|"+ruleID) {
+			delete(m.autoReplies.lastSent[instanceID], key)
+		}
+	}
+	m.saveAutoReplies()
+
+	log.Info().Str("instanceId", instanceID).Str("ruleId", ruleID).Msg("Deleted auto-reply")
+	return nil
+}
+
+// dropAutoReplies removes the rules and cooldowns of a purged instance
+func (m *Manager) dropAutoReplies(instanceID string) {
+	m.autoReplies.mu.Lock()
+	defer m.autoReplies.mu.Unlock()
+
+	delete(m.autoReplies.lastSent, instanceID)
+	delete(m.autoReplies.lastAway, instanceID)
+	if _, ok := m.autoReplies.rules[instanceID]; ok {
+		delete(m.autoReplies.rules, instanceID)
+		m.saveAutoReplies()
+	}
+}
+
+// maybeAutoReply answers an incoming message with the first enabled rule that
+// matches it, unless the rule is on cooldown for the chat or outside its hours.
+// Reports whether a rule matched, even if on cooldown.
+func (m *Manager) maybeAutoReply(inst *Instance, chat types.JID, msg MessageData) bool {
+	text := msg.Body
+	if text == "" {
+		text = msg.Caption
+	}
+	if text == "" || msg.FromMe {
+		return false
+	}
+
+	now := time.Now()
+	chatID := m.canonicalChatID(chat.String())
+
+	m.autoReplies.mu.Lock()
+	var rule *AutoReply
+	for i, r := range m.autoReplies.rules[inst.ID] {
+		if r.Disabled || msg.IsGroup && !r.Groups || !r.matches(text) {
+			continue
+		}
+		if r.Hours != nil && !r.Hours.allows(now) {
+			continue
+		}
+		rule = &m.autoReplies.rules[inst.ID][i]
+		break
+	}
+	if rule == nil {
+		m.autoReplies.mu.Unlock()
+		return false
+	}
+	reply := *rule
+
+	key := chatID + "|" + reply.ID
+	if last, ok := m.autoReplies.lastSent[inst.ID][key]; ok && reply.CooldownMinutes > 0 && now.Unix()-last < int64(reply.CooldownMinutes)*60 {
+		m.autoReplies.mu.Unlock()
+		log.Debug().Str("instanceId", inst.ID).Str("chat", chatID).Str("ruleId", reply.ID).Msg("Skipping auto-reply (cooldown)")
+		return true
+	}
+	if m.autoReplies.lastSent[inst.ID] == nil {
+		m.autoReplies.lastSent[inst.ID] = make(map[string]int64)
+	}
+	m.autoReplies.lastSent[inst.ID][key] = now.Unix()
+	m.autoReplies.mu.Unlock()
+
+	go func() {
+		var replyID string
+		var err error
+		if reply.MediaURL != "" {
+			replyID, err = m.sendMediaURL(context.Background(), inst, chat.ToNonAD(), reply.MediaURL, reply.Text, reply.MediaType, MediaOptions{})
+		} else {
+			resp, sendErr := m.sendTracked(context.Background(), inst.ID, inst.Client, chat.ToNonAD(), &waE2E.Message{
+				Conversation: proto.String(reply.Text),
+			})
+			if err = sendErr; err == nil {
+				replyID = resp.ID
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Str("ruleId", reply.ID).Msg("Failed to send auto-reply")
+			return
+		}
+
+		log.Info().Str("instanceId", inst.ID).Str("chat", chatID).Str("ruleId", reply.ID).Msg("Auto-reply sent")
+		m.publishEvent(Event{
+			Type:       "auto_reply",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"ruleId":    reply.ID,
+				"chat":      chatID,
+				"messageId": msg.ID,
+				"replyId":   replyID,
+			},
+		})
+	}()
+	return true
+}
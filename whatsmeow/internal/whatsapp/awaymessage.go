@@ -0,0 +1,159 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default interval between away messages to the same contact
+const defaultAwayWindow = 24 * time.Hour
+
+// AwayMessage replies to contacts who write outside business hours. The text
+// is a template (see RenderTemplate) that may also use {{start}} and {{end}}.
+type AwayMessage struct {
+	Enabled       bool          `json:"enabled"`
+	Text          string        `json:"text,omitempty"`
+	Hours         BusinessHours `json:"hours"`                   // Business hours; the reply goes out outside them
+	WindowMinutes int           `json:"windowMinutes,omitempty"` // Once per contact per window (default 1440)
+	ExcludeGroups bool          `json:"excludeGroups,omitempty"`
+	Exclude       []string      `json:"exclude,omitempty"` // Chat JIDs or numbers never answered
+}
+
+// compileAwayMessage validates an away message and normalizes its exclusion list
+func (m *Manager) compileAwayMessage(away AwayMessage) (AwayMessage, error) {
+	if !away.Enabled {
+		return away, nil
+	}
+	if strings.TrimSpace(away.Text) == "" {
+		return away, fmt.Errorf("%w: awayMessage.text is required", ErrInvalidInput)
+	}
+	if away.WindowMinutes < 0 {
+		return away, fmt.Errorf("%w: awayMessage.windowMinutes must be >= 0", ErrInvalidInput)
+	}
+	if away.Hours.Outside {
+		return away, fmt.Errorf("%w: awayMessage.hours are business hours; outside is not supported", ErrInvalidInput)
+	}
+	if err := away.Hours.compile(); err != nil {
+		return away, err
+	}
+
+	exclude := make([]string, 0, len(away.Exclude))
+	for _, chat := range away.Exclude {
+		jid, err := m.normalizeRecipient(chat)
+		if err != nil {
+			return away, fmt.Errorf("%w: awayMessage.exclude %s", ErrInvalidInput, chat)
+		}
+		if !strings.Contains(jid, "@") {
+			jid = jid + "@s.whatsapp.net"
+		}
+		exclude = append(exclude, jid)
+	}
+	away.Exclude = exclude
+	return away, nil
+}
+
+// SetAwayMessage configures the away message of an instance
+func (m *Manager) SetAwayMessage(instanceID string, away AwayMessage) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	away, err := m.compileAwayMessage(away)
+	if err != nil {
+		return err
+	}
+
+	inst.mu.Lock()
+	inst.AwayMessage = away
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", away.Enabled).Msg("Updated away message")
+	return nil
+}
+
+// maybeSendAwayMessage answers a message received outside business hours, at
+// most once per contact per window
+func (m *Manager) maybeSendAwayMessage(inst *Instance, chat types.JID, msg MessageData) {
+	inst.mu.RLock()
+	away := inst.AwayMessage
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if !away.Enabled || client == nil || msg.FromMe {
+		return
+	}
+	if msg.IsGroup && away.ExcludeGroups {
+		return
+	}
+	chatID := m.canonicalChatID(chat.String())
+	for _, excluded := range away.Exclude {
+		if m.canonicalChatID(excluded) == chatID {
+			return
+		}
+	}
+
+	now := time.Now()
+	if away.Hours.allows(now) {
+		return // Open for business
+	}
+
+	window := time.Duration(away.WindowMinutes) * time.Minute
+	if window <= 0 {
+		window = defaultAwayWindow
+	}
+
+	m.autoReplies.mu.Lock()
+	if last, ok := m.autoReplies.lastAway[inst.ID][chatID]; ok && now.Unix()-last < int64(window.Seconds()) {
+		m.autoReplies.mu.Unlock()
+		log.Debug().Str("instanceId", inst.ID).Str("chat", chatID).Msg("Skipping away message (already sent)")
+		return
+	}
+	if m.autoReplies.lastAway[inst.ID] == nil {
+		m.autoReplies.lastAway[inst.ID] = make(map[string]int64)
+	}
+	m.autoReplies.lastAway[inst.ID][chatID] = now.Unix()
+	m.autoReplies.mu.Unlock()
+
+	number := msg.ResolvedPhone
+	if number == "" {
+		number = jidUser(msg.From)
+	}
+	name := msg.PushName
+	if name == "" {
+		name = number
+	}
+	text := m.RenderTemplate(inst.ID, chat.String(), away.Text, map[string]string{
+		"name":   name,
+		"number": number,
+		"start":  away.Hours.Start,
+		"end":    away.Hours.End,
+	})
+
+	go func() {
+		resp, err := m.sendTracked(context.Background(), inst.ID, client, chat.ToNonAD(), &waE2E.Message{
+			Conversation: proto.String(text),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("Failed to send away message")
+			return
+		}
+
+		log.Info().Str("instanceId", inst.ID).Str("chat", chatID).Msg("Away message sent")
+		m.publishEvent(Event{
+			Type:       "away_message",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"chat":      chatID,
+				"messageId": msg.ID,
+				"replyId":   resp.ID,
+			},
+		})
+	}()
+}
@@ -0,0 +1,111 @@
+package whatsapp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Brazilian mobile numbers gained an extra leading 9 after the area code, but
+// accounts registered before the change are still known to the WhatsApp
+// servers without it. Both forms reach the same account, so we remember the
+// form the server reports and use it for every stored chat and event.
+
+// brazilianVariant returns the other form (with or without the extra 9) of a
+// Brazilian mobile number, or "" if the number isn't one
+func brazilianVariant(user string) string {
+	if !strings.HasPrefix(user, "55") {
+		return ""
+	}
+	switch len(user) {
+	case 13: // 55 + DDD + 9XXXXXXXX
+		if user[4] == '9' && user[5] >= '6' {
+			return user[:4] + user[5:]
+		}
+	case 12: // 55 + DDD + XXXXXXXX
+		if user[4] >= '6' {
+			return user[:4] + "9" + user[4:]
+		}
+	}
+	return ""
+}
+
+// rememberCanonicalJID records a server-resolved JID as the canonical form
+// of its Brazilian number, moving any messages stored under the other form
+func (m *Manager) rememberCanonicalJID(jid types.JID) {
+	if jid.Server != types.DefaultUserServer {
+		return
+	}
+	variant := brazilianVariant(jid.User)
+	if variant == "" {
+		return
+	}
+
+	m.canonicalMu.Lock()
+	known := m.canonicalUsers[variant] == jid.User && m.canonicalUsers[jid.User] == jid.User
+	m.canonicalUsers[jid.User] = jid.User
+	m.canonicalUsers[variant] = jid.User
+	m.canonicalMu.Unlock()
+
+	if known {
+		return
+	}
+
+	log.Debug().Str("variant", variant).Str("canonical", jid.User).Msg("Learned canonical Brazilian number")
+	m.mergeStoredChats(types.NewJID(variant, types.DefaultUserServer).String(), jid.ToNonAD().String())
+}
+
+// canonicalChatID maps a chat ID (JID or bare number) to the server-resolved
+// form of its Brazilian number, if one is known. Other IDs are returned as-is.
+func (m *Manager) canonicalChatID(chatID string) string {
+	user, server, hasServer := strings.Cut(chatID, "@")
+	if hasServer && server != types.DefaultUserServer {
+		return chatID
+	}
+	// Drop the device part (user:device@server)
+	user, _, _ = strings.Cut(user, ":")
+
+	m.canonicalMu.RLock()
+	canonical, ok := m.canonicalUsers[user]
+	m.canonicalMu.RUnlock()
+
+	if !ok {
+		return chatID
+	}
+	if hasServer {
+		return canonical + "@" + server
+	}
+	return canonical
+}
+
+// mergeStoredChats moves messages stored under one chat ID into another
+func (m *Manager) mergeStoredChats(fromChatID, toChatID string) {
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	for instanceID, chats := range m.messages {
+		moved, ok := chats[fromChatID]
+		if !ok {
+			continue
+		}
+		delete(chats, fromChatID)
+
+		msgs := append(chats[toChatID], moved...)
+		sort.SliceStable(msgs, func(i, j int) bool {
+			return msgs[i].Timestamp < msgs[j].Timestamp
+		})
+		if len(msgs) > maxStoredMessagesPerChat {
+			msgs = msgs[len(msgs)-maxStoredMessagesPerChat:]
+		}
+		chats[toChatID] = msgs
+
+		log.Info().
+			Str("instanceId", instanceID).
+			Str("from", fromChatID).
+			Str("to", toChatID).
+			Int("messages", len(moved)).
+			Msg("Merged chat stored under Brazilian number variant")
+	}
+}
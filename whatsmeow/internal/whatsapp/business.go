@@ -0,0 +1,219 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Business profile limits enforced by WhatsApp
+const (
+	maxBusinessDescription = 512
+	maxBusinessWebsites    = 2
+	businessIQTimeout      = 30 * time.Second
+)
+
+// BusinessProfile represents the profile of a WhatsApp Business account
+type BusinessProfile struct {
+	JID         string             `json:"jid"`
+	Description string             `json:"description,omitempty"`
+	Address     string             `json:"address,omitempty"`
+	Email       string             `json:"email,omitempty"`
+	Websites    []string           `json:"websites,omitempty"`
+	Categories  []BusinessCategory `json:"categories,omitempty"`
+}
+
+// BusinessCategory is a business category of the profile
+type BusinessCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// BusinessProfileUpdate holds the fields to change; nil fields are kept as they are
+type BusinessProfileUpdate struct {
+	Description *string  `json:"description,omitempty"`
+	Address     *string  `json:"address,omitempty"`
+	Email       *string  `json:"email,omitempty"`
+	Websites    []string `json:"websites,omitempty"`   // Replaces all websites (max 2)
+	Categories  []string `json:"categories,omitempty"` // Category IDs, replaces all categories
+}
+
+// validate checks the update against WhatsApp's limits
+func (u BusinessProfileUpdate) validate() error {
+	if u.Description != nil && len([]rune(*u.Description)) > maxBusinessDescription {
+		return fmt.Errorf("%w: description must be at most %d characters", ErrInvalidInput, maxBusinessDescription)
+	}
+	if u.Email != nil && *u.Email != "" && !strings.Contains(*u.Email, "@") {
+		return fmt.Errorf("%w: invalid email", ErrInvalidInput)
+	}
+	if len(u.Websites) > maxBusinessWebsites {
+		return fmt.Errorf("%w: at most %d websites are allowed", ErrInvalidInput, maxBusinessWebsites)
+	}
+	for _, website := range u.Websites {
+		parsed, err := url.Parse(website)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("%w: website %s must be an http(s) URL", ErrInvalidInput, website)
+		}
+	}
+	return nil
+}
+
+// GetBusinessProfile gets the business profile of the instance's own account
+func (m *Manager) GetBusinessProfile(instanceID string) (*BusinessProfile, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if client.Store.ID == nil {
+		return nil, ErrNotConnected
+	}
+	ownJID := client.Store.ID.ToNonAD()
+
+	// whatsmeow's GetBusinessProfile drops the description and websites, so query directly
+	resp, err := sendBusinessIQ(client, "get", waBinary.Node{
+		Tag:   "business_profile",
+		Attrs: waBinary.Attrs{"v": "244"},
+		Content: []waBinary.Node{{
+			Tag:   "profile",
+			Attrs: waBinary.Attrs{"jid": ownJID},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get business profile: %w", err)
+	}
+
+	profileNode, ok := resp.GetOptionalChildByTag("business_profile", "profile")
+	if !ok {
+		return nil, fmt.Errorf("%w: the account has no business profile", ErrInvalidInput)
+	}
+	return parseBusinessProfile(ownJID, &profileNode), nil
+}
+
+// UpdateBusinessProfile changes the business profile of the instance's own account
+func (m *Manager) UpdateBusinessProfile(instanceID string, update BusinessProfileUpdate) (*BusinessProfile, error) {
+	if err := update.validate(); err != nil {
+		return nil, err
+	}
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []waBinary.Node
+	for _, field := range []struct {
+		tag   string
+		value *string
+	}{
+		{"description", update.Description},
+		{"address", update.Address},
+		{"email", update.Email},
+	} {
+		if field.value != nil {
+			fields = append(fields, waBinary.Node{Tag: field.tag, Content: []byte(*field.value)})
+		}
+	}
+	for _, website := range update.Websites {
+		fields = append(fields, waBinary.Node{Tag: "website", Content: []byte(website)})
+	}
+	if update.Categories != nil {
+		categories := make([]waBinary.Node, 0, len(update.Categories))
+		for _, id := range update.Categories {
+			categories = append(categories, waBinary.Node{Tag: "category", Attrs: waBinary.Attrs{"id": id}})
+		}
+		fields = append(fields, waBinary.Node{Tag: "categories", Content: categories})
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+
+	_, err = sendBusinessIQ(client, "set", waBinary.Node{
+		Tag:     "business_profile",
+		Attrs:   waBinary.Attrs{"v": "3", "mutation_type": "delta"},
+		Content: fields,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update business profile: %w", err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Int("fields", len(fields)).Msg("Updated business profile")
+	return m.GetBusinessProfile(instanceID)
+}
+
+// parseBusinessProfile reads a <profile> node of a business_profile response
+func parseBusinessProfile(jid types.JID, node *waBinary.Node) *BusinessProfile {
+	profile := &BusinessProfile{JID: jid.String()}
+	text := func(child waBinary.Node) string {
+		content, _ := child.Content.([]byte)
+		return string(content)
+	}
+
+	for _, child := range node.GetChildren() {
+		switch child.Tag {
+		case "description":
+			profile.Description = text(child)
+		case "address":
+			profile.Address = text(child)
+		case "email":
+			profile.Email = text(child)
+		case "website":
+			profile.Websites = append(profile.Websites, text(child))
+		case "categories":
+			for _, category := range child.GetChildren() {
+				if category.Tag != "category" {
+					continue
+				}
+				profile.Categories = append(profile.Categories, BusinessCategory{
+					ID:   category.AttrGetter().String("id"),
+					Name: text(category),
+				})
+			}
+		}
+	}
+	return profile
+}
+
+// sendBusinessIQ sends a w:biz query and waits for its result. whatsmeow has no
+// public call for business profile edits, so the IQ is built by hand.
+func sendBusinessIQ(client *whatsmeow.Client, iqType string, content waBinary.Node) (*waBinary.Node, error) {
+	internals := client.DangerousInternals()
+	id := internals.GenerateRequestID()
+	respChan := internals.WaitResponse(id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), businessIQTimeout)
+	defer cancel()
+
+	err := internals.SendNode(ctx, waBinary.Node{
+		Tag: "iq",
+		Attrs: waBinary.Attrs{
+			"id":    id,
+			"xmlns": "w:biz",
+			"type":  iqType,
+			"to":    types.ServerJID,
+		},
+		Content: []waBinary.Node{content},
+	})
+	if err != nil {
+		internals.CancelResponse(id, respChan)
+		return nil, err
+	}
+
+	select {
+	case resp := <-respChan:
+		if resp.AttrGetter().OptionalString("type") == "error" {
+			errNode, _ := resp.GetOptionalChildByTag("error")
+			return nil, fmt.Errorf("server returned error %s: %s",
+				errNode.AttrGetter().OptionalString("code"), errNode.AttrGetter().OptionalString("text"))
+		}
+		return resp, nil
+	case <-ctx.Done():
+		internals.CancelResponse(id, respChan)
+		return nil, ctx.Err()
+	}
+}
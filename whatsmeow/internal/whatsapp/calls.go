@@ -0,0 +1,278 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Maximum number of call log entries kept per instance
+const maxCallLogEntries = 200
+
+// Default minimum interval between missed-call follow-ups to the same contact
+const defaultCallFollowUpCooldown = 60 * time.Minute
+
+// Call outcomes recorded in the call log
+const (
+	CallRinging           = "ringing"
+	CallMissed            = "missed"
+	CallRejected          = "rejected"
+	CallAnsweredElsewhere = "answered_elsewhere"
+	CallEnded             = "ended"
+)
+
+// CallLogEntry represents an incoming call and how it ended
+type CallLogEntry struct {
+	CallID       string `json:"callId"`
+	From         string `json:"from"`
+	IsVideo      bool   `json:"isVideo"`
+	Outcome      string `json:"outcome"`
+	Reason       string `json:"reason,omitempty"` // Termination reason reported by WhatsApp
+	OfferedAt    int64  `json:"offeredAt"`
+	EndedAt      int64  `json:"endedAt,omitempty"`
+	FollowUpSent bool   `json:"followUpSent,omitempty"`
+	RejectedByUs bool   `json:"rejectedByUs,omitempty"`
+}
+
+// callLog holds call history and follow-up rate limiting per instance
+type callLog struct {
+	mu           sync.Mutex
+	entries      map[string][]*CallLogEntry  // instanceID -> calls, oldest first
+	lastFollowUp map[string]map[string]int64 // instanceID -> contact -> unix time
+}
+
+func newCallLog() *callLog {
+	return &callLog{
+		entries:      make(map[string][]*CallLogEntry),
+		lastFollowUp: make(map[string]map[string]int64),
+	}
+}
+
+// find returns the entry for a call ID (caller must hold mu)
+func (c *callLog) find(instanceID, callID string) *CallLogEntry {
+	entries := c.entries[instanceID]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].CallID == callID {
+			return entries[i]
+		}
+	}
+	return nil
+}
+
+// isVideoCall reports whether a call offer node carries a video stream
+func isVideoCall(offer *waBinary.Node) bool {
+	if offer == nil {
+		return false
+	}
+	_, ok := offer.GetOptionalChildByTag("video")
+	return ok
+}
+
+// recordCallOffer adds a ringing call to the log
+func (m *Manager) recordCallOffer(instanceID string, meta types.BasicCallMeta, isVideo bool) {
+	offeredAt := meta.Timestamp.Unix()
+	if meta.Timestamp.IsZero() {
+		offeredAt = time.Now().Unix()
+	}
+
+	m.calls.mu.Lock()
+	defer m.calls.mu.Unlock()
+
+	entries := append(m.calls.entries[instanceID], &CallLogEntry{
+		CallID:    meta.CallID,
+		From:      meta.CallCreator.String(),
+		IsVideo:   isVideo,
+		Outcome:   CallRinging,
+		OfferedAt: offeredAt,
+	})
+	if len(entries) > maxCallLogEntries {
+		entries = entries[len(entries)-maxCallLogEntries:]
+	}
+	m.calls.entries[instanceID] = entries
+}
+
+// recordCallRejected marks a call as rejected by this instance
+func (m *Manager) recordCallRejected(inst *Instance, callID string) {
+	m.calls.mu.Lock()
+	entry := m.calls.find(inst.ID, callID)
+	rejected := false
+	if entry != nil && entry.Outcome == CallRinging {
+		entry.Outcome = CallRejected
+		entry.RejectedByUs = true
+		entry.EndedAt = time.Now().Unix()
+		rejected = true
+	}
+	m.calls.mu.Unlock()
+
+	// From the caller's point of view an auto-rejected call is a missed call
+	if rejected {
+		m.countUsage(inst.ID, statCallsRejected, 1)
+		m.maybeSendCallFollowUp(inst, entry)
+	}
+}
+
+// recordCallAccepted marks a call as answered on another device
+func (m *Manager) recordCallAccepted(instanceID, callID string) {
+	m.calls.mu.Lock()
+	defer m.calls.mu.Unlock()
+
+	if entry := m.calls.find(instanceID, callID); entry != nil && entry.Outcome == CallRinging {
+		entry.Outcome = CallAnsweredElsewhere
+	}
+}
+
+// recordCallTerminated closes a call; calls that were never answered are missed
+func (m *Manager) recordCallTerminated(inst *Instance, callID, reason string) {
+	m.calls.mu.Lock()
+	entry := m.calls.find(inst.ID, callID)
+	missed := false
+	if entry != nil {
+		entry.Reason = reason
+		if entry.EndedAt == 0 {
+			entry.EndedAt = time.Now().Unix()
+		}
+		switch entry.Outcome {
+		case CallRinging:
+			entry.Outcome = CallMissed
+			missed = true
+		case CallAnsweredElsewhere:
+			entry.Outcome = CallEnded
+		}
+	}
+	m.calls.mu.Unlock()
+
+	if missed {
+		m.maybeSendCallFollowUp(inst, entry)
+	}
+}
+
+// maybeSendCallFollowUp sends the configured follow-up message for a call that
+// wasn't answered, at most once per contact per cooldown window
+func (m *Manager) maybeSendCallFollowUp(inst *Instance, entry *CallLogEntry) {
+	inst.mu.RLock()
+	text := inst.CallFollowUpMessage
+	cooldown := inst.CallFollowUpCooldown
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if text == "" || client == nil {
+		return
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCallFollowUpCooldown
+	}
+
+	m.calls.mu.Lock()
+	now := time.Now().Unix()
+	if m.calls.lastFollowUp[inst.ID] == nil {
+		m.calls.lastFollowUp[inst.ID] = make(map[string]int64)
+	}
+	if last, ok := m.calls.lastFollowUp[inst.ID][entry.From]; ok && now-last < int64(cooldown.Seconds()) {
+		m.calls.mu.Unlock()
+		log.Debug().Str("instanceId", inst.ID).Str("from", entry.From).Msg("Skipping call follow-up (cooldown)")
+		return
+	}
+	m.calls.lastFollowUp[inst.ID][entry.From] = now
+	m.calls.mu.Unlock()
+
+	jid, err := types.ParseJID(entry.From)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Str("from", entry.From).Msg("Invalid caller JID for follow-up")
+		return
+	}
+
+	go func() {
+		_, err := client.SendMessage(context.Background(), jid.ToNonAD(), &waE2E.Message{
+			Conversation: proto.String(text),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("to", entry.From).Msg("Failed to send missed call follow-up")
+			return
+		}
+
+		m.calls.mu.Lock()
+		entry.FollowUpSent = true
+		m.calls.mu.Unlock()
+
+		log.Info().Str("instanceId", inst.ID).Str("to", entry.From).Str("callId", entry.CallID).Msg("Missed call follow-up sent")
+	}()
+}
+
+// RejectCall rejects an incoming call that is still ringing
+func (m *Manager) RejectCall(instanceID, callID string) (*CallLogEntry, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.calls.mu.Lock()
+	entry := m.calls.find(instanceID, callID)
+	var from string
+	ringing := entry != nil && entry.Outcome == CallRinging
+	if ringing {
+		from = entry.From
+	}
+	m.calls.mu.Unlock()
+
+	if !ringing {
+		return nil, fmt.Errorf("%w: no ringing call %s", ErrInvalidInput, callID)
+	}
+
+	caller, err := types.ParseJID(from)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJID, from)
+	}
+
+	if err := client.RejectCall(context.Background(), caller, callID); err != nil {
+		return nil, fmt.Errorf("failed to reject call: %w", err)
+	}
+	m.recordCallRejected(inst, callID)
+	log.Info().Str("instanceId", instanceID).Str("callId", callID).Str("from", from).Msg("Call rejected")
+
+	m.calls.mu.Lock()
+	result := *entry
+	m.calls.mu.Unlock()
+	return &result, nil
+}
+
+// GetCallLog returns the call history of an instance, newest first
+func (m *Manager) GetCallLog(instanceID string) ([]CallLogEntry, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	m.calls.mu.Lock()
+	defer m.calls.mu.Unlock()
+
+	entries := m.calls.entries[instanceID]
+	result := make([]CallLogEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		result = append(result, *entries[i])
+	}
+	return result, nil
+}
+
+// SetCallFollowUp configures the message sent after missed calls (empty disables it)
+func (m *Manager) SetCallFollowUp(instanceID, message string, cooldown time.Duration) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return
+	}
+	inst.mu.Lock()
+	inst.CallFollowUpMessage = message
+	inst.CallFollowUpCooldown = cooldown
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", message != "").Dur("cooldown", cooldown).Msg("Updated missed call follow-up setting")
+}
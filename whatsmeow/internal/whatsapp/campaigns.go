@@ -0,0 +1,560 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Campaign statuses
+const (
+	CampaignRunning   = "running"
+	CampaignPaused    = "paused"
+	CampaignCancelled = "cancelled"
+	CampaignCompleted = "completed"
+)
+
+// Campaign recipient statuses; delivered and read follow the receipts of the
+// sent message. A recipient stays sending when the outcome of its send couldn't
+// be recorded, and is never retried.
+const (
+	RecipientQueued    = "queued"
+	RecipientSending   = "sending"
+	RecipientSent      = "sent"
+	RecipientDelivered = "delivered"
+	RecipientRead      = "read"
+	RecipientFailed    = "failed"
+)
+
+// Campaign limits and pacing
+const (
+	maxCampaignRecipients   = 50000
+	defaultCampaignMinDelay = 5 * time.Second
+	defaultCampaignMaxDelay = 15 * time.Second
+	campaignIdleWait        = time.Minute // Retry interval while outside the window, over the cap or disconnected
+)
+
+// Campaign sends a message template to a list of recipients, paced by a send
+// window, an hourly cap and a warm-up schedule
+type Campaign struct {
+	ID         string `json:"id"`
+	InstanceID string `json:"instanceId"`
+	Name       string `json:"name,omitempty"`
+
+	// Text (or media caption) with {{var}} placeholders filled from the recipient variables
+	Template  string `json:"template,omitempty"`
+	MediaURL  string `json:"mediaUrl,omitempty"`
+	MediaType string `json:"mediaType,omitempty"` // image, video, audio or document
+
+	Window    *BusinessHours `json:"window,omitempty"`    // Only sends inside this window
+	HourlyCap int            `json:"hourlyCap,omitempty"` // Max sends per hour once warmed up (0 = no cap)
+	RampUp    []int          `json:"rampUp,omitempty"`    // Hourly caps for the first days, e.g. [20, 50, 100]
+
+	// Random pause between two sends (default 5 to 15 seconds)
+	MinDelaySeconds int `json:"minDelaySeconds,omitempty"`
+	MaxDelaySeconds int `json:"maxDelaySeconds,omitempty"`
+
+	StartAt int64 `json:"startAt,omitempty"` // Unix time of the first send (default now)
+
+	Status     string         `json:"status"`
+	CreatedAt  int64          `json:"createdAt"`
+	StartedAt  int64          `json:"startedAt,omitempty"` // First send; the warm-up counts days from here
+	FinishedAt int64          `json:"finishedAt,omitempty"`
+	Counts     map[string]int `json:"counts,omitempty"` // Recipients per status
+}
+
+// CampaignRecipient is a recipient of a campaign and its delivery state
+type CampaignRecipient struct {
+	Number    string            `json:"number"` // Phone number or JID
+	Variables map[string]string `json:"variables,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	MessageID string            `json:"messageId,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	SentAt    int64             `json:"sentAt,omitempty"`
+	UpdatedAt int64             `json:"updatedAt,omitempty"`
+
+	position int
+}
+
+// campaignRunners tracks the campaigns being sent
+type campaignRunners struct {
+	mu      sync.Mutex
+	running map[string]*campaignRun // campaignID -> run
+
+	// A run stopped mid-send finishes it before a new run of the same campaign picks the next recipient
+	sending map[string]*sync.Mutex // campaignID -> send lock
+}
+
+// campaignRun is a campaign being sent; closing stop ends it
+type campaignRun struct {
+	instanceID string
+	stop       chan struct{}
+}
+
+func newCampaignRunners() *campaignRunners {
+	return &campaignRunners{
+		running: make(map[string]*campaignRun),
+		sending: make(map[string]*sync.Mutex),
+	}
+}
+
+// ParseCampaignCSV reads recipients from CSV with a header row. The number
+// (or phone) column is the recipient; every other column is a variable.
+func ParseCampaignCSV(data string) ([]CampaignRecipient, error) {
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: csv header: %v", ErrInvalidInput, err)
+	}
+	numberCol := -1
+	for i, name := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if numberCol < 0 && (strings.EqualFold(header[i], "number") || strings.EqualFold(header[i], "phone")) {
+			numberCol = i
+		}
+	}
+	if numberCol < 0 {
+		return nil, fmt.Errorf("%w: csv needs a number column", ErrInvalidInput)
+	}
+
+	var recipients []CampaignRecipient
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: csv line %d: %v", ErrInvalidInput, line, err)
+		}
+		if numberCol >= len(record) || strings.TrimSpace(record[numberCol]) == "" {
+			continue
+		}
+		recipient := CampaignRecipient{Number: record[numberCol], Variables: make(map[string]string)}
+		for i, value := range record {
+			if i != numberCol && i < len(header) && header[i] != "" {
+				recipient.Variables[header[i]] = value
+			}
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// validate checks the campaign settings and compiles the send window
+func (c *Campaign) validate() error {
+	if strings.TrimSpace(c.Template) == "" && c.MediaURL == "" {
+		return fmt.Errorf("%w: template or mediaUrl is required", ErrInvalidInput)
+	}
+	if c.MediaURL != "" {
+		switch c.MediaType {
+		case "image", "video", "audio", "document":
+		default:
+			return fmt.Errorf("%w: mediaType must be image, video, audio or document", ErrInvalidInput)
+		}
+	}
+	if c.Window != nil {
+		if err := c.Window.compile(); err != nil {
+			return err
+		}
+	}
+	if c.HourlyCap < 0 {
+		return fmt.Errorf("%w: hourlyCap must be >= 0", ErrInvalidInput)
+	}
+	for _, limit := range c.RampUp {
+		if limit <= 0 {
+			return fmt.Errorf("%w: rampUp caps must be > 0", ErrInvalidInput)
+		}
+	}
+	if c.MinDelaySeconds < 0 || c.MaxDelaySeconds < 0 || c.MaxDelaySeconds > 0 && c.MinDelaySeconds > c.MaxDelaySeconds {
+		return fmt.Errorf("%w: delays must be >= 0 with minDelaySeconds <= maxDelaySeconds", ErrInvalidInput)
+	}
+	return nil
+}
+
+// hourlyCap returns the cap in force at a time: the warm-up cap of the day
+// while ramping up, HourlyCap after
+func (c *Campaign) hourlyCap(now time.Time) int {
+	if c.StartedAt == 0 {
+		if len(c.RampUp) > 0 {
+			return c.RampUp[0]
+		}
+		return c.HourlyCap
+	}
+	day := int(now.Sub(time.Unix(c.StartedAt, 0)) / (24 * time.Hour))
+	if day < len(c.RampUp) {
+		return c.RampUp[day]
+	}
+	return c.HourlyCap
+}
+
+// delay returns a random pause before the next send
+func (c *Campaign) delay() time.Duration {
+	lo, hi := defaultCampaignMinDelay, defaultCampaignMaxDelay
+	if c.MinDelaySeconds > 0 || c.MaxDelaySeconds > 0 {
+		lo = time.Duration(c.MinDelaySeconds) * time.Second
+		hi = max(time.Duration(c.MaxDelaySeconds)*time.Second, lo)
+	}
+	if hi == lo {
+		return lo
+	}
+	return lo + rand.N(hi-lo)
+}
+
+// CreateCampaign stores a campaign with its recipients and starts sending.
+// Recipients are normalized and duplicates dropped.
+func (m *Manager) CreateCampaign(instanceID string, campaign Campaign, recipients []CampaignRecipient) (*Campaign, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	if err := campaign.validate(); err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: recipients are required", ErrInvalidInput)
+	}
+	if len(recipients) > maxCampaignRecipients {
+		return nil, fmt.Errorf("%w: at most %d recipients per campaign", ErrInvalidInput, maxCampaignRecipients)
+	}
+
+	seen := make(map[string]bool, len(recipients))
+	unique := make([]CampaignRecipient, 0, len(recipients))
+	for i, recipient := range recipients {
+		to, err := m.normalizeRecipient(recipient.Number)
+		if err != nil {
+			return nil, fmt.Errorf("%w: recipient %d (%s)", err, i, recipient.Number)
+		}
+		if !strings.Contains(to, "@") {
+			to = to + "@s.whatsapp.net"
+		}
+		if _, err := types.ParseJID(to); err != nil {
+			return nil, fmt.Errorf("%w: recipient %d (%s)", ErrInvalidJID, i, recipient.Number)
+		}
+		if seen[to] {
+			continue
+		}
+		seen[to] = true
+		recipient.Number = to
+		unique = append(unique, recipient)
+	}
+
+	campaign.ID = newQueueID()
+	campaign.InstanceID = instanceID
+	campaign.Status = CampaignRunning
+	campaign.CreatedAt = time.Now().Unix()
+	campaign.StartedAt = 0
+	campaign.FinishedAt = 0
+	if err := m.campaignStore.CreateCampaign(campaign, unique); err != nil {
+		return nil, err
+	}
+	campaign.Counts = map[string]int{RecipientQueued: len(unique)}
+
+	log.Info().Str("instanceId", instanceID).Str("campaignId", campaign.ID).Int("recipients", len(unique)).Msg("Created campaign")
+	m.startCampaign(campaign)
+	return &campaign, nil
+}
+
+// GetCampaigns lists the campaigns of an instance, newest first
+func (m *Manager) GetCampaigns(instanceID string) ([]Campaign, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	return m.campaignStore.Campaigns(instanceID, "")
+}
+
+// GetCampaign returns a campaign with its recipient counts
+func (m *Manager) GetCampaign(instanceID, campaignID string) (*Campaign, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	return m.campaignStore.Campaign(instanceID, campaignID)
+}
+
+// GetCampaignRecipients lists the recipients of a campaign, optionally only
+// those in a status
+func (m *Manager) GetCampaignRecipients(instanceID, campaignID, status string, limit, offset int) ([]CampaignRecipient, error) {
+	if _, err := m.GetCampaign(instanceID, campaignID); err != nil {
+		return nil, err
+	}
+	return m.campaignStore.CampaignRecipients(campaignID, status, limit, offset)
+}
+
+// PauseCampaign stops sending a campaign until it is resumed
+func (m *Manager) PauseCampaign(instanceID, campaignID string) (*Campaign, error) {
+	return m.setCampaignStatus(instanceID, campaignID, CampaignPaused)
+}
+
+// ResumeCampaign continues sending a paused campaign
+func (m *Manager) ResumeCampaign(instanceID, campaignID string) (*Campaign, error) {
+	return m.setCampaignStatus(instanceID, campaignID, CampaignRunning)
+}
+
+// CancelCampaign stops a campaign for good; queued recipients are not sent
+func (m *Manager) CancelCampaign(instanceID, campaignID string) (*Campaign, error) {
+	return m.setCampaignStatus(instanceID, campaignID, CampaignCancelled)
+}
+
+// setCampaignStatus moves a campaign to a status, starting or stopping its run
+func (m *Manager) setCampaignStatus(instanceID, campaignID, status string) (*Campaign, error) {
+	campaign, err := m.GetCampaign(instanceID, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status == CampaignCompleted || campaign.Status == CampaignCancelled {
+		return nil, fmt.Errorf("%w: campaign is %s", ErrCampaignFinished, campaign.Status)
+	}
+	if campaign.Status == status {
+		return campaign, nil
+	}
+
+	m.stopCampaign(campaignID)
+	var finishedAt int64
+	if status == CampaignCancelled {
+		finishedAt = time.Now().Unix()
+	}
+	if err := m.campaignStore.UpdateCampaignStatus(campaignID, status, finishedAt); err != nil {
+		return nil, err
+	}
+	campaign.Status = status
+	campaign.FinishedAt = finishedAt
+	if status == CampaignRunning {
+		m.startCampaign(*campaign)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("campaignId", campaignID).Str("status", status).Msg("Updated campaign status")
+	m.publishCampaignStatus(campaign)
+	return campaign, nil
+}
+
+// publishCampaignStatus publishes a campaign_status event
+func (m *Manager) publishCampaignStatus(campaign *Campaign) {
+	m.publishEvent(Event{
+		Type:       "campaign_status",
+		InstanceID: campaign.InstanceID,
+		Data: map[string]interface{}{
+			"campaignId": campaign.ID,
+			"name":       campaign.Name,
+			"status":     campaign.Status,
+			"counts":     campaign.Counts,
+		},
+	})
+}
+
+// resumeCampaigns restarts the campaigns that were running at shutdown
+func (m *Manager) resumeCampaigns() {
+	campaigns, err := m.campaignStore.Campaigns("", CampaignRunning)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load running campaigns")
+		return
+	}
+	for _, campaign := range campaigns {
+		m.startCampaign(campaign)
+	}
+	if len(campaigns) > 0 {
+		log.Info().Int("count", len(campaigns)).Msg("Resumed campaigns")
+	}
+}
+
+// startCampaign sends a campaign in the background unless it already is
+func (m *Manager) startCampaign(campaign Campaign) {
+	m.campaigns.mu.Lock()
+	defer m.campaigns.mu.Unlock()
+	if _, ok := m.campaigns.running[campaign.ID]; ok {
+		return
+	}
+	run := &campaignRun{instanceID: campaign.InstanceID, stop: make(chan struct{})}
+	m.campaigns.running[campaign.ID] = run
+	if m.campaigns.sending[campaign.ID] == nil {
+		m.campaigns.sending[campaign.ID] = &sync.Mutex{}
+	}
+	go m.runCampaign(campaign, run, m.campaigns.sending[campaign.ID])
+}
+
+// stopCampaign ends the run of a campaign, if any
+func (m *Manager) stopCampaign(campaignID string) {
+	m.campaigns.mu.Lock()
+	defer m.campaigns.mu.Unlock()
+	if run, ok := m.campaigns.running[campaignID]; ok {
+		close(run.stop)
+		delete(m.campaigns.running, campaignID)
+	}
+}
+
+// dropCampaigns stops the campaigns of a purged instance
+func (m *Manager) dropCampaigns(instanceID string) {
+	m.campaigns.mu.Lock()
+	defer m.campaigns.mu.Unlock()
+	for id, run := range m.campaigns.running {
+		if run.instanceID == instanceID {
+			close(run.stop)
+			delete(m.campaigns.running, id)
+			delete(m.campaigns.sending, id)
+		}
+	}
+}
+
+// runCampaign sends a campaign one recipient at a time until it completes or
+// is stopped
+func (m *Manager) runCampaign(campaign Campaign, run *campaignRun, sending *sync.Mutex) {
+	for {
+		sending.Lock()
+		select {
+		case <-run.stop:
+			sending.Unlock()
+			return
+		default:
+		}
+		wait, done := m.campaignStep(&campaign)
+		sending.Unlock()
+		if done {
+			m.campaigns.mu.Lock()
+			if m.campaigns.running[campaign.ID] == run {
+				delete(m.campaigns.running, campaign.ID)
+				delete(m.campaigns.sending, campaign.ID)
+			}
+			m.campaigns.mu.Unlock()
+			return
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-run.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// campaignStep sends to the next recipient if the window, the cap and the
+// connection allow it. Returns how long to wait before the next step, or done
+// once no recipient is left.
+func (m *Manager) campaignStep(campaign *Campaign) (time.Duration, bool) {
+	now := time.Now()
+	if start := time.Unix(campaign.StartAt, 0); start.After(now) {
+		return min(start.Sub(now), campaignIdleWait), false
+	}
+
+	inst, ok := m.GetInstance(campaign.InstanceID)
+	if !ok {
+		return campaignIdleWait, false
+	}
+	inst.mu.RLock()
+	connected := inst.Status == "connected" && inst.Client != nil
+	inst.mu.RUnlock()
+	if !connected {
+		return campaignIdleWait, false
+	}
+
+	if campaign.Window != nil && !campaign.Window.allows(now) {
+		return campaignIdleWait, false
+	}
+	if limit := campaign.hourlyCap(now); limit > 0 {
+		sent, err := m.campaignStore.CampaignSentSince(campaign.ID, now.Add(-time.Hour).Unix())
+		if err != nil {
+			log.Error().Err(err).Str("campaignId", campaign.ID).Msg("Failed to count campaign sends")
+			return campaignIdleWait, false
+		}
+		if sent >= limit {
+			return campaignIdleWait, false
+		}
+	}
+
+	recipient, err := m.campaignStore.NextCampaignRecipient(campaign.ID)
+	if err != nil {
+		log.Error().Err(err).Str("campaignId", campaign.ID).Msg("Failed to load next campaign recipient")
+		return campaignIdleWait, false
+	}
+	if recipient == nil {
+		m.completeCampaign(campaign)
+		return 0, true
+	}
+
+	if campaign.StartedAt == 0 {
+		campaign.StartedAt = now.Unix()
+		if err := m.campaignStore.SetCampaignStarted(campaign.ID, campaign.StartedAt); err != nil {
+			log.Warn().Err(err).Str("campaignId", campaign.ID).Msg("Failed to record campaign start")
+		}
+	}
+
+	// Claimed before sending, so a send whose outcome can't be recorded is
+	// never picked again
+	if err := m.campaignStore.UpdateCampaignRecipient(campaign.ID, recipient.position, RecipientSending, "", "", 0); err != nil {
+		log.Error().Err(err).Str("campaignId", campaign.ID).Msg("Failed to claim campaign recipient")
+		return campaignIdleWait, false
+	}
+
+	messageID, err := m.sendCampaignMessage(inst, campaign, *recipient)
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		// The tenant's messages for the day are used up; retry the recipient later
+		if err := m.campaignStore.UpdateCampaignRecipient(campaign.ID, recipient.position, RecipientQueued, "", "", 0); err != nil {
+			log.Error().Err(err).Str("campaignId", campaign.ID).Str("to", recipient.Number).Msg("Failed to requeue campaign recipient, pausing campaign")
+			m.pauseFailedCampaign(campaign)
+			return 0, true
+		}
+		return campaignIdleWait, false
+	case err != nil:
+		log.Warn().Err(err).Str("campaignId", campaign.ID).Str("to", recipient.Number).Msg("Failed to send campaign message")
+		err = m.campaignStore.UpdateCampaignRecipient(campaign.ID, recipient.position, RecipientFailed, "", err.Error(), 0)
+	default:
+		err = m.campaignStore.UpdateCampaignRecipient(campaign.ID, recipient.position, RecipientSent, messageID, "", time.Now().Unix())
+	}
+	if err != nil {
+		// The recipient stays sending; pause rather than keep sending blind
+		log.Error().Err(err).Str("campaignId", campaign.ID).Str("to", recipient.Number).Msg("Failed to record campaign recipient, pausing campaign")
+		m.pauseFailedCampaign(campaign)
+		return 0, true
+	}
+	return campaign.delay(), false
+}
+
+// pauseFailedCampaign pauses a campaign whose run can't record its sends
+func (m *Manager) pauseFailedCampaign(campaign *Campaign) {
+	campaign.Status = CampaignPaused
+	if err := m.campaignStore.UpdateCampaignStatus(campaign.ID, CampaignPaused, 0); err != nil {
+		log.Error().Err(err).Str("campaignId", campaign.ID).Msg("Failed to pause campaign")
+	}
+	m.publishCampaignStatus(campaign)
+}
+
+// sendCampaignMessage sends the campaign message to a recipient
+func (m *Manager) sendCampaignMessage(inst *Instance, campaign *Campaign, recipient CampaignRecipient) (string, error) {
+	jid, err := types.ParseJID(recipient.Number)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	text := m.RenderTemplate(inst.ID, recipient.Number, campaign.Template, recipient.Variables)
+	if campaign.MediaURL != "" {
+		// The media may be generated per recipient, e.g. a certificate named after them
+		mediaURL := m.RenderMediaURL(inst.ID, recipient.Number, campaign.MediaURL, recipient.Variables)
+		return m.sendMediaURL(context.Background(), inst, jid, mediaURL, text, campaign.MediaType, MediaOptions{})
+	}
+	return m.sendPlainText(inst, jid, text)
+}
+
+// completeCampaign marks a campaign whose recipients were all sent as completed
+func (m *Manager) completeCampaign(campaign *Campaign) {
+	campaign.Status = CampaignCompleted
+	campaign.FinishedAt = time.Now().Unix()
+	if err := m.campaignStore.UpdateCampaignStatus(campaign.ID, CampaignCompleted, campaign.FinishedAt); err != nil {
+		log.Error().Err(err).Str("campaignId", campaign.ID).Msg("Failed to complete campaign")
+		return
+	}
+	if counts, err := m.campaignStore.CampaignCounts(campaign.ID); err == nil {
+		campaign.Counts = counts
+	}
+	log.Info().Str("instanceId", campaign.InstanceID).Str("campaignId", campaign.ID).Msg("Campaign completed")
+	m.publishCampaignStatus(campaign)
+}
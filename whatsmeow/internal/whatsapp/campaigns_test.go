@@ -0,0 +1,104 @@
+package whatsapp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCampaignHourlyCap(t *testing.T) {
+	started := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		rampUp    []int
+		hourlyCap int
+		startedAt time.Time // Zero when the campaign hasn't sent yet
+		now       time.Time
+		want      int
+	}{
+		{"not started uses the first warm-up cap", []int{20, 50}, 100, time.Time{}, started, 20},
+		{"not started without warm-up", nil, 100, time.Time{}, started, 100},
+		{"first day", []int{20, 50}, 100, started, started.Add(23 * time.Hour), 20},
+		{"second day", []int{20, 50}, 100, started, started.Add(24 * time.Hour), 50},
+		{"warmed up", []int{20, 50}, 100, started, started.Add(48 * time.Hour), 100},
+		{"days count from the first send, not midnight", []int{20, 50}, 100, started, started.Add(14 * time.Hour), 20},
+		{"warmed up without a cap", []int{20}, 0, started, started.Add(72 * time.Hour), 0},
+		{"no warm-up", nil, 100, started, started, 100},
+	}
+
+	for _, tc := range cases {
+		campaign := Campaign{RampUp: tc.rampUp, HourlyCap: tc.hourlyCap}
+		if !tc.startedAt.IsZero() {
+			campaign.StartedAt = tc.startedAt.Unix()
+		}
+		if got := campaign.hourlyCap(tc.now); got != tc.want {
+			t.Errorf("%s: hourlyCap = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCampaignWindow(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip("timezone database unavailable")
+	}
+	// Monday 2 March 2026
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 2, hour, minute, 0, 0, saoPaulo)
+	}
+
+	cases := []struct {
+		name   string
+		window BusinessHours
+		now    time.Time
+		want   bool
+	}{
+		{"inside", BusinessHours{Start: "09:00", End: "18:00"}, at(12, 0), true},
+		{"opens at start", BusinessHours{Start: "09:00", End: "18:00"}, at(9, 0), true},
+		{"closed at end", BusinessHours{Start: "09:00", End: "18:00"}, at(18, 0), false},
+		{"before start", BusinessHours{Start: "09:00", End: "18:00"}, at(8, 59), false},
+		{"overnight, late", BusinessHours{Start: "22:00", End: "06:00"}, at(23, 0), true},
+		{"overnight, early", BusinessHours{Start: "22:00", End: "06:00"}, at(5, 59), true},
+		{"overnight, daytime", BusinessHours{Start: "22:00", End: "06:00"}, at(12, 0), false},
+		{"weekday listed", BusinessHours{Start: "09:00", End: "18:00", Days: []int{1, 2, 3, 4, 5}}, at(12, 0), true},
+		{"weekday not listed", BusinessHours{Start: "09:00", End: "18:00", Days: []int{0, 6}}, at(12, 0), false},
+		{"checked in its timezone", BusinessHours{Start: "09:00", End: "18:00", Timezone: "America/Sao_Paulo"}, at(12, 0).UTC(), true},
+		{"other timezone", BusinessHours{Start: "09:00", End: "18:00", Timezone: "Asia/Tokyo"}, at(12, 0), false},
+	}
+
+	for _, tc := range cases {
+		if tc.window.Timezone == "" {
+			tc.window.Timezone = "America/Sao_Paulo"
+		}
+		campaign := Campaign{Template: "Oi", Window: &tc.window}
+		if err := campaign.validate(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := campaign.Window.allows(tc.now); got != tc.want {
+			t.Errorf("%s: allows(%s) = %v, want %v", tc.name, tc.now.In(saoPaulo).Format("Mon 15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestCampaignDelay(t *testing.T) {
+	cases := []struct {
+		name     string
+		min, max int // Seconds
+		lo, hi   time.Duration
+	}{
+		{"default", 0, 0, defaultCampaignMinDelay, defaultCampaignMaxDelay},
+		{"range", 2, 4, 2 * time.Second, 4 * time.Second},
+		{"fixed", 3, 3, 3 * time.Second, 3 * time.Second},
+		{"min only", 7, 0, 7 * time.Second, 7 * time.Second},
+		{"max only", 0, 2, 0, 2 * time.Second},
+	}
+
+	for _, tc := range cases {
+		campaign := Campaign{MinDelaySeconds: tc.min, MaxDelaySeconds: tc.max}
+		for range 100 {
+			if got := campaign.delay(); got < tc.lo || got > tc.hi {
+				t.Fatalf("%s: delay = %v, want between %v and %v", tc.name, got, tc.lo, tc.hi)
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const campaignSchema = `
+	CREATE TABLE IF NOT EXISTS campaigns (
+		id          TEXT    PRIMARY KEY,
+		instance_id TEXT    NOT NULL,
+		status      TEXT    NOT NULL,
+		config      TEXT    NOT NULL,
+		created_at  INTEGER NOT NULL,
+		started_at  INTEGER NOT NULL DEFAULT 0,
+		finished_at INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_campaigns_instance ON campaigns (instance_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS campaign_recipients (
+		campaign_id TEXT    NOT NULL,
+		position    INTEGER NOT NULL,
+		instance_id TEXT    NOT NULL,
+		recipient   TEXT    NOT NULL,
+		variables   TEXT,
+		status      TEXT    NOT NULL,
+		message_id  TEXT,
+		error       TEXT,
+		sent_at     INTEGER NOT NULL DEFAULT 0,
+		updated_at  INTEGER NOT NULL,
+		PRIMARY KEY (campaign_id, position)
+	);
+	CREATE INDEX IF NOT EXISTS idx_campaign_recipients_status ON campaign_recipients (campaign_id, status, position);
+	CREATE INDEX IF NOT EXISTS idx_campaign_recipients_message ON campaign_recipients (instance_id, message_id);
+`
+
+// campaignStore keeps campaigns and their recipients in events.db
+type campaignStore struct {
+	db *sql.DB
+}
+
+// CreateCampaign stores a campaign and its queued recipients
+func (s *campaignStore) CreateCampaign(campaign Campaign, recipients []CampaignRecipient) error {
+	config, err := json.Marshal(campaign)
+	if err != nil {
+		return fmt.Errorf("failed to marshal campaign: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to store campaign: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT INTO campaigns (id, instance_id, status, config, created_at) VALUES (?, ?, ?, ?, ?)`,
+		campaign.ID, campaign.InstanceID, campaign.Status, string(config), campaign.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to store campaign: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO campaign_recipients (campaign_id, position, instance_id, recipient, variables, status, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to store campaign recipients: %w", err)
+	}
+	defer stmt.Close()
+	for i, recipient := range recipients {
+		variables, _ := json.Marshal(recipient.Variables)
+		if _, err := stmt.Exec(campaign.ID, i, campaign.InstanceID, recipient.Number, string(variables), RecipientQueued, campaign.CreatedAt); err != nil {
+			return fmt.Errorf("failed to store campaign recipients: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store campaign: %w", err)
+	}
+	return nil
+}
+
+// Campaigns lists campaigns, newest first. Empty instanceID or status match any.
+func (s *campaignStore) Campaigns(instanceID, status string) ([]Campaign, error) {
+	rows, err := s.db.Query(
+		`SELECT id, status, config, created_at, started_at, finished_at FROM campaigns
+		 WHERE (? = '' OR instance_id = ?) AND (? = '' OR status = ?) ORDER BY created_at DESC`,
+		instanceID, instanceID, status, status,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+
+	campaigns := make([]Campaign, 0)
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		campaigns = append(campaigns, *campaign)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+
+	for i := range campaigns {
+		if campaigns[i].Counts, err = s.CampaignCounts(campaigns[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return campaigns, nil
+}
+
+// Campaign returns a campaign of an instance with its recipient counts
+func (s *campaignStore) Campaign(instanceID, campaignID string) (*Campaign, error) {
+	row := s.db.QueryRow(
+		`SELECT id, status, config, created_at, started_at, finished_at FROM campaigns WHERE id = ? AND instance_id = ?`,
+		campaignID, instanceID,
+	)
+	campaign, err := scanCampaign(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrCampaignNotFound, campaignID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Counts, err = s.CampaignCounts(campaignID); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// scanCampaign reads a campaign row; the settings come from the stored config
+// and the state from the columns
+func scanCampaign(row interface{ Scan(...any) error }) (*Campaign, error) {
+	var campaign Campaign
+	var id, status, config string
+	var createdAt, startedAt, finishedAt int64
+	if err := row.Scan(&id, &status, &config, &createdAt, &startedAt, &finishedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan campaign: %w", err)
+	}
+	if err := json.Unmarshal([]byte(config), &campaign); err != nil {
+		return nil, fmt.Errorf("failed to decode campaign %s: %w", id, err)
+	}
+	if campaign.Window != nil {
+		if err := campaign.Window.compile(); err != nil {
+			return nil, fmt.Errorf("failed to decode campaign %s: %w", id, err)
+		}
+	}
+	campaign.ID = id
+	campaign.Status = status
+	campaign.CreatedAt = createdAt
+	campaign.StartedAt = startedAt
+	campaign.FinishedAt = finishedAt
+	return &campaign, nil
+}
+
+// CampaignCounts returns the number of recipients of a campaign per status
+func (s *campaignStore) CampaignCounts(campaignID string) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM campaign_recipients WHERE campaign_id = ? GROUP BY status`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count campaign recipients: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to count campaign recipients: %w", err)
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+// CampaignRecipients lists recipients of a campaign in list order
+func (s *campaignStore) CampaignRecipients(campaignID, status string, limit, offset int) ([]CampaignRecipient, error) {
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	rows, err := s.db.Query(
+		`SELECT position, recipient, variables, status, message_id, error, sent_at, updated_at FROM campaign_recipients
+		 WHERE campaign_id = ? AND (? = '' OR status = ?) ORDER BY position LIMIT ? OFFSET ?`,
+		campaignID, status, status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := make([]CampaignRecipient, 0)
+	for rows.Next() {
+		recipient, err := scanCampaignRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *recipient)
+	}
+	return recipients, rows.Err()
+}
+
+// NextCampaignRecipient returns the first queued recipient of a campaign, or
+// nil when none is left
+func (s *campaignStore) NextCampaignRecipient(campaignID string) (*CampaignRecipient, error) {
+	row := s.db.QueryRow(
+		`SELECT position, recipient, variables, status, message_id, error, sent_at, updated_at FROM campaign_recipients
+		 WHERE campaign_id = ? AND status = ? ORDER BY position LIMIT 1`,
+		campaignID, RecipientQueued,
+	)
+	recipient, err := scanCampaignRecipient(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return recipient, err
+}
+
+func scanCampaignRecipient(row interface{ Scan(...any) error }) (*CampaignRecipient, error) {
+	var recipient CampaignRecipient
+	var variables, messageID, sendError sql.NullString
+	if err := row.Scan(&recipient.position, &recipient.Number, &variables, &recipient.Status, &messageID, &sendError, &recipient.SentAt, &recipient.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan campaign recipient: %w", err)
+	}
+	if variables.Valid && variables.String != "" {
+		json.Unmarshal([]byte(variables.String), &recipient.Variables)
+	}
+	recipient.MessageID = messageID.String
+	recipient.Error = sendError.String
+	return &recipient, nil
+}
+
+// CampaignSentSince counts the recipients of a campaign sent since a time
+func (s *campaignStore) CampaignSentSince(campaignID string, since int64) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM campaign_recipients WHERE campaign_id = ? AND sent_at >= ?`, campaignID, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count campaign sends: %w", err)
+	}
+	return count, nil
+}
+
+// UpdateCampaignRecipient records the outcome of a send
+func (s *campaignStore) UpdateCampaignRecipient(campaignID string, position int, status, messageID, sendError string, sentAt int64) error {
+	_, err := s.db.Exec(
+		`UPDATE campaign_recipients SET status = ?, message_id = ?, error = ?, sent_at = ?, updated_at = ? WHERE campaign_id = ? AND position = ?`,
+		status, messageID, sendError, sentAt, time.Now().Unix(), campaignID, position,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign recipient: %w", err)
+	}
+	return nil
+}
+
+// AdvanceCampaignRecipient moves the campaign recipient of a sent message to
+// delivered or read, never back
+func (s *campaignStore) AdvanceCampaignRecipient(instanceID, messageID, status string) error {
+	from := []any{RecipientSent}
+	if status == RecipientRead {
+		from = append(from, RecipientDelivered)
+	}
+	args := append([]any{status, time.Now().Unix(), instanceID, messageID}, from...)
+	_, err := s.db.Exec(
+		`UPDATE campaign_recipients SET status = ?, updated_at = ? WHERE instance_id = ? AND message_id = ? AND status IN (?`+strings.Repeat(", ?", len(from)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update campaign recipient: %w", err)
+	}
+	return nil
+}
+
+// UpdateCampaignStatus sets the status of a campaign
+func (s *campaignStore) UpdateCampaignStatus(campaignID, status string, finishedAt int64) error {
+	if _, err := s.db.Exec(`UPDATE campaigns SET status = ?, finished_at = ? WHERE id = ?`, status, finishedAt, campaignID); err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	return nil
+}
+
+// SetCampaignStarted records the first send of a campaign
+func (s *campaignStore) SetCampaignStarted(campaignID string, startedAt int64) error {
+	if _, err := s.db.Exec(`UPDATE campaigns SET started_at = ? WHERE id = ? AND started_at = 0`, startedAt, campaignID); err != nil {
+		return fmt.Errorf("failed to update campaign: %w", err)
+	}
+	return nil
+}
+
+// deleteInstance removes the campaigns of an instance
+func (s *campaignStore) deleteInstance(instanceID string) error {
+	if _, err := s.db.Exec(`DELETE FROM campaign_recipients WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance campaigns: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM campaigns WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance campaigns: %w", err)
+	}
+	return nil
+}
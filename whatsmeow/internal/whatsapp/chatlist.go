@@ -0,0 +1,215 @@
+package whatsapp
+
+import (
+	"context"
+	"sort"
+
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Longest last message preview returned in the chat list, in characters
+const chatPreviewLength = 100
+
+// chatState is the inbox state of a chat, kept next to its stored messages
+// and guarded by messagesMu
+type chatState struct {
+	Unread       int
+	MarkedUnread bool
+	Name         string // Chat name reported by history sync
+}
+
+// ChatLastMessage is a preview of the most recent message of a chat
+type ChatLastMessage struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	Preview   string `json:"preview,omitempty"`
+	Type      string `json:"type"`
+	FromMe    bool   `json:"fromMe"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// chatStateLocked returns the state of a chat, creating it if needed. The
+// caller must hold messagesMu.
+func (m *Manager) chatStateLocked(instanceID, chatID string) *chatState {
+	if m.chatStates[instanceID] == nil {
+		m.chatStates[instanceID] = make(map[string]*chatState)
+	}
+	state := m.chatStates[instanceID][chatID]
+	if state == nil {
+		state = &chatState{}
+		m.chatStates[instanceID][chatID] = state
+	}
+	return state
+}
+
+// updateChatState applies fn to the state of a chat and publishes
+// unread_count if the counter or the unread flag changed
+func (m *Manager) updateChatState(instanceID, chatID string, fn func(*chatState)) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	state := m.chatStateLocked(instanceID, chatID)
+	before := *state
+	fn(state)
+	after := *state
+	m.messagesMu.Unlock()
+
+	if before.Unread != after.Unread || before.MarkedUnread != after.MarkedUnread {
+		m.publishEvent(Event{
+			Type:       "unread_count",
+			InstanceID: instanceID,
+			Data: map[string]interface{}{
+				"chat":         chatID,
+				"unreadCount":  after.Unread,
+				"markedUnread": after.MarkedUnread,
+			},
+		})
+	}
+}
+
+// countChatMessage updates the unread counter for a new message: incoming
+// messages count as unread until the chat is read, replying reads it
+func (m *Manager) countChatMessage(instanceID, chatID string, fromMe bool) {
+	m.updateChatState(instanceID, chatID, func(state *chatState) {
+		if fromMe {
+			state.Unread = 0
+			state.MarkedUnread = false
+		} else {
+			state.Unread++
+		}
+	})
+}
+
+// markChatRead clears the unread counter of a chat
+func (m *Manager) markChatRead(instanceID, chatID string) {
+	m.updateChatState(instanceID, chatID, func(state *chatState) {
+		state.Unread = 0
+		state.MarkedUnread = false
+	})
+}
+
+// markChatUnread flags a chat as unread without touching its counter
+func (m *Manager) markChatUnread(instanceID, chatID string) {
+	m.updateChatState(instanceID, chatID, func(state *chatState) {
+		state.MarkedUnread = true
+	})
+}
+
+// syncChatState takes the unread counter and name of a chat from history
+// sync, which knows about messages read before the instance was linked. No
+// unread_count is published; history_sync tells clients to reload the list.
+func (m *Manager) syncChatState(instanceID string, conv *waHistorySync.Conversation) {
+	chatID := m.canonicalChatID(conv.GetID())
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	state := m.chatStateLocked(instanceID, chatID)
+	state.Unread = int(conv.GetUnreadCount())
+	state.MarkedUnread = conv.GetMarkedAsUnread()
+	if name := conv.GetName(); name != "" {
+		state.Name = name
+	}
+}
+
+// GetChats lists the chats with stored messages, most recent activity first,
+// with a preview of the last message and the unread counter. With unreadOnly
+// only chats with unread messages or flagged as unread are returned; limit
+// caps the result (0 = no limit).
+func (m *Manager) GetChats(instanceID string, unreadOnly bool, limit int) ([]ChatInfo, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	inst.mu.RLock()
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	m.messagesMu.RLock()
+	chats := make([]ChatInfo, 0, len(m.messages[instanceID]))
+	for chatID, msgs := range m.messages[instanceID] {
+		if len(msgs) == 0 {
+			continue
+		}
+
+		var state chatState
+		if s := m.chatStates[instanceID][chatID]; s != nil {
+			state = *s
+		}
+		if unreadOnly && state.Unread == 0 && !state.MarkedUnread {
+			continue
+		}
+
+		last := msgs[len(msgs)-1]
+		for _, msg := range msgs {
+			if msg.Timestamp > last.Timestamp {
+				last = msg
+			}
+		}
+
+		preview := last.Body
+		if preview == "" {
+			preview = last.Caption
+		}
+		if runes := []rune(preview); len(runes) > chatPreviewLength {
+			preview = string(runes[:chatPreviewLength]) + "…"
+		}
+
+		chats = append(chats, ChatInfo{
+			ID:      chatID,
+			Name:    state.Name,
+			IsGroup: last.IsGroup,
+			LastMessage: &ChatLastMessage{
+				ID:        last.ID,
+				From:      last.From,
+				Preview:   preview,
+				Type:      last.Type,
+				FromMe:    last.FromMe,
+				Timestamp: last.Timestamp,
+			},
+			LastActivity: last.Timestamp,
+			UnreadCount:  state.Unread,
+			MarkedUnread: state.MarkedUnread,
+		})
+	}
+	m.messagesMu.RUnlock()
+
+	sort.SliceStable(chats, func(i, j int) bool {
+		if chats[i].LastActivity != chats[j].LastActivity {
+			return chats[i].LastActivity > chats[j].LastActivity
+		}
+		return chats[i].ID < chats[j].ID
+	})
+	if limit > 0 && len(chats) > limit {
+		chats = chats[:limit]
+	}
+
+	// Names come from the contact store when history sync didn't provide one
+	for i := range chats {
+		jid, err := types.ParseJID(chats[i].ID)
+		if err != nil {
+			continue
+		}
+		if jid.Server == types.GroupServer {
+			chats[i].IsGroup = true
+		}
+		if client != nil && client.Store != nil && client.Store.Contacts != nil && !chats[i].IsGroup {
+			if contact, err := client.Store.Contacts.GetContact(context.Background(), jid); err == nil && contact.Found {
+				chats[i].PushName = contact.PushName
+				if chats[i].Name == "" {
+					chats[i].Name = contact.FullName
+				}
+				if chats[i].Name == "" {
+					chats[i].Name = contact.PushName
+				}
+			}
+		}
+		if chats[i].Name == "" {
+			chats[i].Name = jid.User
+		}
+	}
+
+	return chats, nil
+}
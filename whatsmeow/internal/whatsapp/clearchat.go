@@ -0,0 +1,156 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waSyncAction"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// What clearing a chat does on the phone and linked devices
+const (
+	ChatRemoteNone   = ""       // Only the messages stored by the service are removed
+	ChatRemoteClear  = "clear"  // The chat is emptied but stays in the chat list
+	ChatRemoteDelete = "delete" // The chat is removed from the chat list
+)
+
+// ValidChatRemote reports whether mode is a known chat clearing mode
+func ValidChatRemote(mode string) bool {
+	switch mode {
+	case ChatRemoteNone, ChatRemoteClear, ChatRemoteDelete:
+		return true
+	}
+	return false
+}
+
+// ClearChat removes the stored messages of a chat and returns how many were
+// removed. With a remote mode the chat is also cleared or deleted on the phone
+// through an app state patch. keepStarred keeps starred messages, locally and
+// on the phone when clearing.
+func (m *Manager) ClearChat(instanceID, chatID, remote string, keepStarred bool) (int, error) {
+	if !ValidChatRemote(remote) {
+		return 0, fmt.Errorf("%w: remote must be %q or %q", ErrInvalidInput, ChatRemoteClear, ChatRemoteDelete)
+	}
+
+	var chatJID types.JID
+	if remote != ChatRemoteNone {
+		inst, jid, err := m.resolveRecipient(instanceID, chatID)
+		if err != nil {
+			return 0, err
+		}
+		chatJID = jid
+
+		lastTimestamp, lastKey := m.lastMessageKey(instanceID, chatJID)
+		patch := appstate.BuildDeleteChat(chatJID, lastTimestamp, lastKey)
+		if remote == ChatRemoteClear {
+			patch = buildClearChat(chatJID, keepStarred, lastTimestamp, lastKey)
+		}
+
+		log.Info().Str("instanceId", instanceID).Str("chatJID", chatJID.String()).Str("remote", remote).Msg("Clearing chat")
+		if err := inst.Client.SendAppState(context.Background(), patch); err != nil {
+			return 0, fmt.Errorf("failed to clear chat: %w", err)
+		}
+	} else {
+		if _, ok := m.GetInstance(instanceID); !ok {
+			return 0, ErrInstanceNotFound
+		}
+		to, err := m.normalizeRecipient(chatID)
+		if err != nil {
+			return 0, err
+		}
+		if !strings.Contains(to, "@") {
+			to = to + "@s.whatsapp.net"
+		}
+		if chatJID, err = types.ParseJID(to); err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+		}
+	}
+
+	return m.clearStoredChat(instanceID, chatJID.String(), keepStarred, 0), nil
+}
+
+// buildClearChat builds the app state patch that empties a chat. whatsmeow
+// only ships a builder for deleting chats.
+func buildClearChat(target types.JID, keepStarred bool, lastTimestamp time.Time, lastKey *waCommon.MessageKey) appstate.PatchInfo {
+	deleteStarred := "1"
+	if keepStarred {
+		deleteStarred = "0"
+	}
+	if lastTimestamp.IsZero() {
+		lastTimestamp = time.Now()
+	}
+	messageRange := &waSyncAction.SyncActionMessageRange{
+		LastMessageTimestamp: proto.Int64(lastTimestamp.Unix()),
+	}
+	if lastKey != nil {
+		messageRange.Messages = []*waSyncAction.SyncActionMessage{{
+			Key:       lastKey,
+			Timestamp: proto.Int64(lastTimestamp.Unix()),
+		}}
+	}
+	return appstate.PatchInfo{
+		Type: appstate.WAPatchRegularHigh,
+		Mutations: []appstate.MutationInfo{{
+			Index:   []string{appstate.IndexClearChat, target.String(), deleteStarred, "0"},
+			Version: 6,
+			Value: &waSyncAction.SyncActionValue{
+				ClearChatAction: &waSyncAction.ClearChatAction{
+					MessageRange: messageRange,
+				},
+			},
+		}},
+	}
+}
+
+// handleChatCleared removes the stored messages of a chat cleared or deleted
+// on another device and publishes chat_cleared. Messages newer than the range
+// of the action are kept, so replayed actions don't wipe synced history.
+func (m *Manager) handleChatCleared(inst *Instance, chat types.JID, messageRange *waSyncAction.SyncActionMessageRange, deleted, fromFullSync bool) {
+	removed := m.clearStoredChat(inst.ID, chat.String(), false, messageRange.GetLastMessageTimestamp())
+
+	if fromFullSync {
+		return
+	}
+	m.publishEvent(Event{
+		Type:       "chat_cleared",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"chat":    chat.String(),
+			"deleted": deleted,
+			"removed": removed,
+		},
+	})
+}
+
+// clearStoredChat removes the stored messages of a chat up to the until
+// timestamp (0 = all), except starred ones with keepStarred. The unread state
+// goes with the chat once it's empty.
+func (m *Manager) clearStoredChat(instanceID, chatID string, keepStarred bool, until int64) int {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	kept := make([]MessageData, 0)
+	for _, msg := range msgs {
+		if keepStarred && msg.Starred || until > 0 && msg.Timestamp > until {
+			kept = append(kept, msg)
+		}
+	}
+
+	if len(kept) > 0 {
+		m.messages[instanceID][chatID] = kept
+	} else {
+		delete(m.messages[instanceID], chatID)
+		delete(m.chatStates[instanceID], chatID)
+	}
+	return len(msgs) - len(kept)
+}
@@ -1,29 +1,39 @@
 package whatsapp
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"image/jpeg"
 	"io"
+	"mime"
 	"net/http"
-	"net/url"
 	"os"
-	"regexp"
+	"path"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/skip2/go-qrcode"
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
+	waCommon "go.mau.fi/whatsmeow/proto/waCommon"
 	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/proto"
+	"whatsmeow-service/internal/tracing"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -36,6 +46,10 @@ type Instance struct {
 	Status       string
 	QRCode       string
 	QRCodeBase64 string
+	qrPNG        []byte
+	qrStop       chan struct{} // Closed to stop the QR rotation goroutine
+	qrGen        uint64        // Bumped whenever a rotation starts or stops; stale rotations don't touch the instance
+	qrIdle       *time.Timer   // Resets the instance if it isn't paired in time
 	PairingCode  string
 	WANumber     string
 	WAName       string
@@ -46,7 +60,49 @@ type Instance struct {
 	IgnoreGroups      bool // Don't process group messages
 	SyncHistory       bool // Request full history sync on connect
 	ReadMessages      bool // Auto mark messages as read
+	HideReadReceipts  bool // Mark messages read with read-self receipts, without blue ticks for the sender
 	SkipVideoDownload bool // Skip automatic video download to save memory
+	SkipViewOnceMedia bool // Don't download or keep the media of view-once messages
+
+	// How media of incoming messages is downloaded (eager, lazy or off)
+	MediaPolicy MediaPolicy
+
+	// How the linked device appears in WhatsApp; applied when pairing
+	Identity DeviceIdentity
+
+	// Events are POSTed here, signed with WebhookSecret ("" disables webhooks)
+	WebhookURL    string
+	WebhookSecret string
+	WebhookEvents []string // Event types delivered to the webhook (empty = all)
+	WebhookFormat string   // Payload format: native ("") or evolution
+
+	// Rules applied to incoming messages before they're stored or published
+	FilterRules []FilterRule
+
+	// Reply sent to contacts who write outside business hours
+	AwayMessage AwayMessage
+
+	// Answers incoming messages through an OpenAI-compatible API
+	AIAgent AIAgent
+
+	// Runs a Typebot flow per chat
+	Typebot TypebotConfig
+
+	// Connects each chat to a Dialogflow CX agent session
+	Dialogflow DialogflowConfig
+
+	// Missed call follow-up (empty message disables it)
+	CallFollowUpMessage  string
+	CallFollowUpCooldown time.Duration // Minimum interval between follow-ups per contact
+
+	// Level of the whatsmeow client logs ("" follows WHATSMEOW_CLIENT_LOG_LEVEL)
+	ClientLogLevel string
+	clientLog      *clientLogger
+
+	// Owner commands (!status, !pause...) accepted from this number ("" disables them)
+	OwnerNumber string
+	Paused      bool // Message events are not forwarded while paused
+	connectedAt time.Time
 
 	// Proxy configuration
 	ProxyHost     string
@@ -68,29 +124,183 @@ func (i *Instance) RUnlock() {
 	i.mu.RUnlock()
 }
 
+// copySettings gives fresh, which replaces old, the settings and proxy of old
+func copySettings(old, fresh *Instance) {
+	old.mu.RLock()
+	settings := old.settings()
+	proxy := ProxyConfig{
+		Host:     old.ProxyHost,
+		Port:     old.ProxyPort,
+		Username: old.ProxyUsername,
+		Password: old.ProxyPassword,
+		Protocol: old.ProxyProtocol,
+	}
+	old.mu.RUnlock()
+
+	fresh.mu.Lock()
+	fresh.setSettings(settings)
+	fresh.ProxyHost = proxy.Host
+	fresh.ProxyPort = proxy.Port
+	fresh.ProxyUsername = proxy.Username
+	fresh.ProxyPassword = proxy.Password
+	fresh.ProxyProtocol = proxy.Protocol
+	fresh.mu.Unlock()
+}
+
 // Manager manages multiple WhatsApp instances
 type Manager struct {
 	instances   map[string]*Instance
 	container   *sqlstore.Container
+	sessionDB   *encryptedSessionDB // Set when the session store is encrypted at rest
 	dataDir     string
 	mu          sync.RWMutex
-	eventSubs   map[string][]chan Event
+	eventSubs   map[string][]*eventSubscriber
 	eventSubsMu sync.RWMutex
+	journal     *EventJournal
+
+	// Stores sharing events.db with the journal
+	eventsDB         *sql.DB
+	messageStatuses  *messageStatusStore
+	campaignStore    *campaignStore
+	usageStore       *usageStore
+	addressBookStore *addressBookStore
+	auditStore       *auditStore
+
+	// An instance's events are journaled, delivered to subscribers and queued
+	// for its webhook one at a time, so all of them see sequence order
+	publishMu    sync.Mutex
+	publishLocks map[string]*sync.Mutex // instanceID -> publish lock
+
+	// What happens to stream subscribers that fall behind, and what they missed
+	eventOverflow string
+	eventDrops    *eventDrops
 
 	mapping     map[string]string // InstanceID -> JIDString
 	mappingFile string
 
+	// Settings applied to newly created instances
+	defaults     InstanceDefaults
+	defaultsFile string
+	defaultsMu   sync.RWMutex
+
 	// Message storage for each chat
 	messages   map[string]map[string][]MessageData // instanceID -> chatID -> messages
 	messagesMu sync.RWMutex
+	chatStates map[string]map[string]*chatState // instanceID -> chatID -> inbox state
+
+	// Persistent LID <-> phone number mapping
+	lids *lidMap
+
+	// Level of whatsmeow client logs for instances without their own
+	clientLogLevel atomic.Int32
+
+	// Region assumed for phone numbers without a country code ("" = none)
+	phoneRegion string
+
+	// Largest group a mention of all participants is sent to
+	mentionAllLimit int
+
+	// What link previews and location thumbnails may fetch
+	previewPolicy PreviewPolicy
+
+	// How outbound images are compressed when a send doesn't say
+	imageCompression ImageCompression
+
+	// Server-resolved form of Brazilian numbers (either variant -> canonical user)
+	canonicalUsers map[string]string
+	canonicalMu    sync.RWMutex
+
+	// Incoming call history
+	calls *callLog
+
+	// Auto-reply rules and per-chat cooldowns
+	autoReplies *autoReplies
+
+	// Typebot flow sessions per chat
+	typebots *typebotSessions
+
+	// Dialogflow sessions per chat and access tokens
+	dialogflow *dialogflowSessions
+
+	// Completions of the AI agent in progress per chat
+	aiChats *aiChatTurns
+
+	// Campaigns being sent
+	campaigns *campaignRunners
+
+	// Last known presence of subscribed contacts
+	presence *presenceStore
+
+	// Messages waiting for a disconnected instance, with a delivery deadline
+	outbox *outbox
+
+	// Live location broadcasts started through the API
+	liveLocations *liveLocations
+
+	// Per-instance webhook delivery queues
+	webhooks *webhooks
+
+	// Background media downloads
+	mediaDownloads *mediaDownloads
+
+	// Media policy of new instances when the defaults don't set one
+	fallbackMediaPolicy MediaPolicy
+
+	// Signed URLs for fetching media from message events
+	mediaURLs *mediaURLs
+
+	// Generated thumbnails keyed by instanceID/mediaID/size
+	thumbnails   map[string][]byte
+	thumbnailsMu sync.Mutex
+
+	// Readiness probe waiting on the event handling locks, if any
+	eventLoopProbe   chan struct{}
+	eventLoopProbeMu sync.Mutex
+
+	// Logged out instances whose history is kept until the grace period ends
+	deleted     map[string]*DeletedInstance
+	deletedFile string
+	deletedMu   sync.Mutex
+	deleteGrace time.Duration
+
+	// Proxy of each instance, applied before restored sessions connect
+	proxies     map[string]ProxyConfig
+	proxiesFile string
+	proxiesMu   sync.Mutex
+
+	// Settings of each instance, applied again on restart
+	settings     map[string]InstanceSettings
+	settingsFile string
+	settingsMu   sync.Mutex
+
+	// SHA-256 of the API token of each instance (required by its WebSocket)
+	tokens     map[string]string
+	tokensFile string
+	tokensMu   sync.Mutex
+
+	// Tenants, and the tenant owning each instance
+	tenants         map[string]*Tenant
+	instanceTenants map[string]string
+	tenantSends     map[string]*tenantSendCount
+	tenantsFile     string
+	tenantsMu       sync.Mutex
+
+	// Time an instance may show QR codes without pairing before it's reset (0 disables)
+	qrIdleTimeout time.Duration
+
+	// Instance ownership and event relay across nodes (nil when running alone)
+	cluster Cluster
 }
 
 // Event represents a WhatsApp event
 type Event struct {
+	ID         int64       `json:"id,omitempty"` // Journal sequence number
 	Type       string      `json:"type"`
 	InstanceID string      `json:"instanceId"`
 	Data       interface{} `json:"data"`
 	Timestamp  int64       `json:"timestamp"`
+
+	webhookURL string // Delivered here instead of the instance webhook
 }
 
 // MessageData represents message data
@@ -106,49 +316,159 @@ type MessageData struct {
 	PushName      string `json:"pushName,omitempty"`
 	ResolvedPhone string `json:"resolvedPhone,omitempty"`
 	// Media fields
-	MediaBase64 string `json:"mediaBase64,omitempty"`
-	Mimetype    string `json:"mimetype,omitempty"`
-	Caption     string `json:"caption,omitempty"`
-	FileName    string `json:"fileName,omitempty"`
+	MediaBase64  string            `json:"mediaBase64,omitempty"`
+	MediaPending bool              `json:"mediaPending,omitempty"` // Being downloaded; media_ready follows
+	MediaURL     string            `json:"mediaUrl,omitempty"`     // Signed, short-lived URL to fetch the media on demand
+	MediaSkipped string            `json:"mediaSkipped,omitempty"` // Why the media was left out by the media policy: disabled, type or size
+	Mimetype     string            `json:"mimetype,omitempty"`
+	Caption      string            `json:"caption,omitempty"`
+	FileName     string            `json:"fileName,omitempty"`
+	FileLength   uint64            `json:"fileLength,omitempty"`
+	Thumbnail    []byte            `json:"-"` // Embedded JPEG preview sent with the media
+	Starred      bool              `json:"starred,omitempty"`
+	Revoked      bool              `json:"revoked,omitempty"`   // Deleted for everyone; the content is gone
+	RevokedAt    int64             `json:"revokedAt,omitempty"` // When it was deleted
+	Edits        []MessageEdit     `json:"edits,omitempty"`     // Earlier versions, oldest first
+	Reactions    []MessageReaction `json:"reactions,omitempty"` // Current reaction of each user
+	Tags         []string          `json:"tags,omitempty"`      // Added by filter rules
+
+	// Downloadable reference for fetching media on demand
+	media whatsmeow.DownloadableMessage
+	// Button/list reply fields
+	Interactive *InteractiveResponse `json:"interactive,omitempty"`
+	// Static or live location fields
+	Location *LocationInfo `json:"location,omitempty"`
+	// Poll question and options
+	Poll *PollInfo `json:"poll,omitempty"`
+	// Media can only be opened once by the recipient
+	ViewOnce bool `json:"viewOnce,omitempty"`
 }
 
 // ResolvedContactInfo represents resolved contact information
 type ResolvedContactInfo struct {
 	OriginalJID   string `json:"originalJid"`
 	ResolvedPhone string `json:"resolvedPhone,omitempty"`
+	LID           string `json:"lid,omitempty"` // LID user, when known
 	PushName      string `json:"pushName,omitempty"`
 	FullName      string `json:"fullName,omitempty"`
 	IsLID         bool   `json:"isLid"`
 	Resolved      bool   `json:"resolved"`
+	Error         string `json:"error,omitempty"` // Set in bulk results for JIDs that couldn't be parsed
 }
 
-// NewManager creates a new WhatsApp manager
-func NewManager(dataDir string) (*Manager, error) {
+// NewManager creates a new WhatsApp manager. With a sessionKey the session
+// database is kept encrypted on disk.
+func NewManager(dataDir string, sessionKey []byte) (*Manager, error) {
 	// Create SQLite store for sessions
 	dbPath := fmt.Sprintf("%s/whatsmeow.db", dataDir)
-	dbLog := waLog.Stdout("Database", "WARN", true)
+	encryptedPath := dbPath + ".enc"
+	dbLog := waLog.Zerolog(log.Logger.With().Str("module", "Database").Logger().Level(zerolog.WarnLevel))
+
+	var container *sqlstore.Container
+	var sessionDB *encryptedSessionDB
+	if sessionKey != nil {
+		var err error
+		if sessionDB, err = openEncryptedSessionDB(encryptedPath, dbPath, sessionKey); err != nil {
+			return nil, fmt.Errorf("failed to open encrypted database: %w", err)
+		}
+		container = sqlstore.NewWithDB(sessionDB.db, "sqlite3", dbLog)
+		if err := container.Upgrade(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to create database: %w", err)
+		}
+	} else {
+		// Refuse to start over with an empty store next to the encrypted one
+		if _, err := os.Stat(encryptedPath); err == nil {
+			return nil, fmt.Errorf("%s is encrypted but no session key is configured", encryptedPath)
+		}
 
-	container, err := sqlstore.New(context.Background(), "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", dbPath), dbLog)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create database: %w", err)
+		var err error
+		container, err = sqlstore.New(context.Background(), "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", dbPath), dbLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create database: %w", err)
+		}
 	}
 
-	m := &Manager{
-		instances:   make(map[string]*Instance),
-		container:   container,
-		dataDir:     dataDir,
-		eventSubs:   make(map[string][]chan Event),
-		mapping:     make(map[string]string),
-		mappingFile: fmt.Sprintf("%s/instances.json", dataDir),
-		messages:    make(map[string]map[string][]MessageData),
+	// Open the event journal used for polling and replay, and the stores next to it
+	eventsDB, err := openEventsDB(fmt.Sprintf("%s/events.db", dataDir))
+	if err != nil {
+		return nil, err
 	}
+	journal := NewEventJournal(eventsDB)
 
-	// Load mapping
+	m := &Manager{
+		instances:        make(map[string]*Instance),
+		container:        container,
+		sessionDB:        sessionDB,
+		dataDir:          dataDir,
+		eventSubs:        make(map[string][]*eventSubscriber),
+		publishLocks:     make(map[string]*sync.Mutex),
+		eventOverflow:    OverflowJournal,
+		eventDrops:       newEventDrops(),
+		journal:          journal,
+		eventsDB:         eventsDB,
+		messageStatuses:  newMessageStatusStore(eventsDB),
+		campaignStore:    &campaignStore{db: eventsDB},
+		usageStore:       &usageStore{db: eventsDB},
+		addressBookStore: &addressBookStore{db: eventsDB},
+		auditStore:       newAuditStore(eventsDB),
+		lids:             newLIDMap(&lidStore{db: eventsDB}),
+		mapping:          make(map[string]string),
+		mappingFile:      fmt.Sprintf("%s/instances.json", dataDir),
+		defaultsFile:     fmt.Sprintf("%s/defaults.json", dataDir),
+		messages:         make(map[string]map[string][]MessageData),
+		chatStates:       make(map[string]map[string]*chatState),
+		canonicalUsers:   make(map[string]string),
+		calls:            newCallLog(),
+		autoReplies:      newAutoReplies(fmt.Sprintf("%s/autoreplies.json", dataDir)),
+		typebots:         newTypebotSessions(),
+		dialogflow:       newDialogflowSessions(),
+		aiChats:          newAIChatTurns(),
+		campaigns:        newCampaignRunners(),
+		presence:         newPresenceStore(),
+		outbox:           newOutbox(),
+		liveLocations:    newLiveLocations(),
+		mediaDownloads:   newMediaDownloads(defaultMediaWorkers),
+		webhooks:         newWebhooks(),
+		mediaURLs:        newMediaURLs(),
+		thumbnails:       make(map[string][]byte),
+		deleted:          make(map[string]*DeletedInstance),
+		deletedFile:      fmt.Sprintf("%s/deleted.json", dataDir),
+		proxies:          make(map[string]ProxyConfig),
+		proxiesFile:      fmt.Sprintf("%s/proxies.json", dataDir),
+		settings:         make(map[string]InstanceSettings),
+		settingsFile:     fmt.Sprintf("%s/settings.json", dataDir),
+		tokens:           make(map[string]string),
+		tokensFile:       fmt.Sprintf("%s/tokens.json", dataDir),
+		tenants:          make(map[string]*Tenant),
+		instanceTenants:  make(map[string]string),
+		tenantSends:      make(map[string]*tenantSendCount),
+		tenantsFile:      fmt.Sprintf("%s/tenants.json", dataDir),
+		deleteGrace:      defaultDeleteGrace,
+		qrIdleTimeout:    defaultQRIdleTimeout,
+		mentionAllLimit:  defaultMentionAllLimit,
+		previewPolicy:    PreviewPolicy{MaxRedirects: defaultPreviewRedirects},
+	}
+
+	m.clientLogLevel.Store(int32(zerolog.InfoLevel))
+
+	// Load mapping, defaults, soft-deleted instances, settings, proxies, tokens, tenants and auto-replies
 	m.loadMapping()
+	m.loadDefaults()
+	m.loadDeleted()
+	m.loadSettings()
+	m.loadProxies()
+	m.loadTokens()
+	m.loadTenants()
+	m.loadAutoReplies()
+	go m.purgeLoop()
+	go m.typebotSweepLoop()
 
 	// Restore sessions
 	m.restoreSessions()
 
+	// Campaigns wait for their instance to connect
+	m.resumeCampaigns()
+
 	return m, nil
 }
 
@@ -203,20 +523,23 @@ func (m *Manager) restoreSessions() {
 		}
 
 		// Recreate instance
-		clientLog := waLog.Stdout("Client-"+instanceID, "INFO", true)
+		clientLog := m.newClientLogger(instanceID)
 		client := whatsmeow.NewClient(device, clientLog)
 
 		instance := &Instance{
-			ID:     instanceID,
-			Client: client,
-			Device: device,
-			Status: "disconnected", // Will update on connect
+			ID:        instanceID,
+			Client:    client,
+			Device:    device,
+			Status:    "disconnected", // Will update on connect
+			clientLog: clientLog,
 		}
 
 		instance.WANumber = jid.User
 		instance.WAName = device.PushName
 
 		m.setupEventHandlers(instance)
+		m.applyStoredSettings(instance)
+		m.applyStoredProxy(instance)
 
 		if err := client.Connect(); err != nil {
 			log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to connect restored session")
@@ -231,11 +554,54 @@ func (m *Manager) restoreSessions() {
 
 // GetOrCreateInstance gets existing instance or creates new one
 func (m *Manager) GetOrCreateInstance(instanceID string) (*Instance, error) {
+	return m.getOrCreateInstance(instanceID, false)
+}
+
+// CreateInstance creates an instance under a name that isn't in use, failing
+// with ErrInstanceExists otherwise. With a tenant the instance is claimed for
+// it first, and the claim released again when the create fails.
+func (m *Manager) CreateInstance(tenantID, instanceID string) (*Instance, error) {
+	if tenantID != "" {
+		if err := m.claimInstanceFor(tenantID, instanceID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	inst, err := m.getOrCreateInstance(instanceID, true)
+	if err != nil && tenantID != "" {
+		m.releaseInstance(instanceID)
+	}
+	return inst, err
+}
+
+// getOrCreateInstance gets or creates an instance; exclusive fails with
+// ErrInstanceExists instead of returning one that exists
+func (m *Manager) getOrCreateInstance(instanceID string, exclusive bool) (*Instance, error) {
+	// Soft-deleted instances keep their ID reserved until restored or purged
+	if m.isDeleted(instanceID) {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceDeleted, instanceID)
+	}
+
+	if inst, ok := m.GetInstance(instanceID); ok {
+		if exclusive {
+			return nil, fmt.Errorf("%w: %s", ErrInstanceExists, instanceID)
+		}
+		return inst, nil
+	}
+
+	// Claimed before it exists, without holding m.mu over the Redis round trip
+	if err := m.claimInstance(instanceID); err != nil {
+		return nil, err
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Check if already exists in memory
+	// Created by another request meanwhile
 	if inst, ok := m.instances[instanceID]; ok {
+		if exclusive {
+			return nil, fmt.Errorf("%w: %s", ErrInstanceExists, instanceID)
+		}
 		return inst, nil
 	}
 
@@ -246,74 +612,75 @@ func (m *Manager) GetOrCreateInstance(instanceID string) (*Instance, error) {
 		// Try to load from store again just in case
 		jid, _ := types.ParseJID(jidStr)
 		if device, err := m.container.GetDevice(context.Background(), jid); err == nil && device != nil {
-			clientLog := waLog.Stdout("Client-"+instanceID, "INFO", true)
+			clientLog := m.newClientLogger(instanceID)
 			client := whatsmeow.NewClient(device, clientLog)
 			instance := &Instance{
-				ID:     instanceID,
-				Client: client,
-				Device: device,
-				Status: "disconnected",
+				ID:        instanceID,
+				Client:    client,
+				Device:    device,
+				Status:    "disconnected",
+				clientLog: clientLog,
 			}
 			m.setupEventHandlers(instance)
+			m.applyStoredSettings(instance)
+			m.applyStoredProxy(instance)
 			m.instances[instanceID] = instance
 			return instance, nil
 		}
 	}
 
+	instance := m.newInstance(instanceID)
+
+	// Apply operator defaults (settings, proxy) to the new instance
+	m.applyDefaults(instance)
+
+	m.instances[instanceID] = instance
+	return instance, nil
+}
+
+// newInstance creates an instance backed by a fresh, unpaired device
+func (m *Manager) newInstance(instanceID string) *Instance {
 	// Create new device
 	device := m.container.NewDevice()
 
-	// Always enforce Safari/Mac OS identity
-	device.Platform = "Mac OS X" // Changed from "Mac OS" to see if it fixes "Outros"
-	device.BusinessName = "Safari"
-
 	// Create client
-	clientLog := waLog.Stdout("Client-"+instanceID, "INFO", true)
+	clientLog := m.newClientLogger(instanceID)
 	client := whatsmeow.NewClient(device, clientLog)
 
 	instance := &Instance{
-		ID:     instanceID,
-		Client: client,
-		Device: device,
-		Status: "disconnected",
+		ID:        instanceID,
+		Client:    client,
+		Device:    device,
+		Status:    "disconnected",
+		clientLog: clientLog,
 	}
 
+	// Register with the instance's own device identity
+	m.installDeviceIdentity(instance)
+
 	// Setup event handlers
 	m.setupEventHandlers(instance)
 
-	m.instances[instanceID] = instance
-	return instance, nil
+	return instance
 }
 
 // setupEventHandlers sets up WhatsApp event handlers for an instance
 func (m *Manager) setupEventHandlers(inst *Instance) {
 	inst.Client.AddEventHandler(func(evt interface{}) {
+		if m.handleNewsletterEvent(inst, evt) {
+			return
+		}
+
 		switch v := evt.(type) {
 		case *events.QR:
-			// Generate QR code
-			qrCode := v.Codes[0]
-			inst.mu.Lock()
-			inst.Status = "qr"
-			inst.QRCode = qrCode
-
-			// Generate base64 QR image
-			png, err := qrcode.Encode(qrCode, qrcode.Medium, 256)
-			if err == nil {
-				inst.QRCodeBase64 = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
-			}
-			inst.mu.Unlock()
-
-			log.Info().Str("instanceId", inst.ID).Msg("QR code generated")
-			m.publishEvent(Event{
-				Type:       "qr",
-				InstanceID: inst.ID,
-				Data: map[string]string{
-					"qr":       qrCode,
-					"qrBase64": inst.QRCodeBase64,
-				},
-			})
+			log.Info().Str("instanceId", inst.ID).Int("codes", len(v.Codes)).Msg("QR codes received")
+			m.startQRRotation(inst, v.Codes)
+			m.startQRIdleTimer(inst)
 
 		case *events.PairSuccess:
+			m.stopQRRotation(inst)
+			m.stopQRIdleTimer(inst)
+
 			inst.mu.Lock()
 			inst.WANumber = v.ID.User
 			inst.mu.Unlock()
@@ -327,15 +694,24 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 			log.Info().Str("instanceId", inst.ID).Str("number", inst.WANumber).Msg("WhatsApp paired successfully")
 
 		case *events.Connected:
+			m.stopQRRotation(inst)
+			m.stopQRIdleTimer(inst)
+
 			inst.mu.Lock()
 			inst.Status = "connected"
 			inst.QRCode = ""
 			inst.QRCodeBase64 = ""
+			inst.qrPNG = nil
 			if inst.Client.Store.ID != nil {
 				inst.WANumber = inst.Client.Store.ID.User
 			}
 			inst.WAName = inst.Client.Store.PushName
+			reconnect := !inst.connectedAt.IsZero()
+			inst.connectedAt = time.Now()
 			inst.mu.Unlock()
+			if reconnect {
+				m.countUsage(inst.ID, statReconnects, 1)
+			}
 
 			log.Info().Str("instanceId", inst.ID).Str("number", inst.WANumber).Msg("WhatsApp connected")
 			m.publishEvent(Event{
@@ -347,7 +723,11 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 				},
 			})
 
+			go m.flushOutbox(inst.ID)
+
 		case *events.Disconnected:
+			m.stopQRRotation(inst)
+
 			inst.mu.Lock()
 			inst.Status = "disconnected"
 			inst.mu.Unlock()
@@ -360,6 +740,8 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 			})
 
 		case *events.LoggedOut:
+			m.stopQRRotation(inst)
+
 			inst.mu.Lock()
 			inst.Status = "disconnected"
 			inst.WANumber = ""
@@ -374,10 +756,13 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 			})
 
 		case *events.Message:
+			m.learnFromSource(v.Info.MessageSource)
+
 			// Check if we should ignore group messages
 			inst.mu.RLock()
 			ignoreGroups := inst.IgnoreGroups
 			readMessages := inst.ReadMessages
+			paused := inst.Paused
 			inst.mu.RUnlock()
 
 			if ignoreGroups && v.Info.IsGroup {
@@ -385,26 +770,124 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 				return
 			}
 
+			// Channel posts are surfaced separately and not stored as chats
+			if v.Info.Chat.Server == types.NewsletterServer {
+				m.publishNewsletterMessage(inst, v)
+				return
+			}
+
+			// Statuses posted by contacts are surfaced separately and not stored as chats
+			if v.Info.Chat == types.StatusBroadcastJID {
+				m.publishStatusUpdate(inst, v)
+				return
+			}
+
+			// Pins are surfaced separately and not stored as messages
+			if pin := v.Message.GetPinInChatMessage(); pin != nil {
+				if !paused {
+					m.publishPin(inst, v, pin)
+				}
+				return
+			}
+
+			// Poll votes are surfaced decrypted and not stored as messages
+			if v.Message.GetPollUpdateMessage() != nil {
+				if !paused {
+					m.publishPollVote(inst, v)
+				}
+				return
+			}
+
+			// Deletions for everyone and edits update the stored message and are not stored themselves
+			if protocol := v.Message.GetProtocolMessage(); protocol != nil {
+				switch protocol.GetType() {
+				case waE2E.ProtocolMessage_REVOKE:
+					m.handleRevoke(inst, v, protocol, paused)
+					return
+				case waE2E.ProtocolMessage_MESSAGE_EDIT:
+					m.handleEdit(inst, v, protocol, paused)
+					return
+				}
+			}
+
+			// Reactions are aggregated on the message they target
+			if reaction := v.Message.GetReactionMessage(); reaction != nil {
+				m.handleReaction(inst, v, reaction, paused)
+				return
+			}
+
+			// Commands from the owner are answered in chat, not forwarded
+			if m.handleOwnerCommand(inst, v) {
+				return
+			}
+
+			// Chats reported by the server carry the canonical number
+			if !v.Info.IsGroup {
+				m.rememberCanonicalJID(v.Info.Chat)
+			}
+
 			msgData := m.formatMessage(inst.ID, v)
+			msgData.To = m.canonicalChatID(msgData.To)
 			log.Debug().Str("instanceId", inst.ID).Str("from", msgData.From).Msg("Message received")
+
+			filter := m.filterMessage(inst, msgData)
+			if filter.drop {
+				log.Debug().Str("instanceId", inst.ID).Str("messageId", msgData.ID).Msg("Dropping message (filter rule)")
+				return
+			}
+			msgData.Tags = filter.tags
+
 			// Store the message
-			m.storeMessage(inst.ID, msgData.To, msgData)
+			if m.storeMessage(inst.ID, msgData.To, msgData) {
+				m.countChatMessage(inst.ID, msgData.To, msgData.FromMe)
+				// Messages sent from the phone count as sent; API sends are counted by trackSent
+				if msgData.FromMe {
+					m.countUsage(inst.ID, statMessagesSent, 1)
+					m.countTenantSend(inst.ID)
+				} else {
+					m.countUsage(inst.ID, statMessagesReceived, 1)
+					// Counted by declared size as the media arrives, however often it's downloaded
+					m.countUsage(inst.ID, statMediaBytesReceived, int64(msgData.FileLength))
+				}
+			}
+			msgData.MediaURL = m.mediaURL(inst.ID, msgData)
+
+			// Download media in the background once the message event is out
+			defer m.queueMediaDownload(inst, msgData)
 
 			// Auto mark as read if enabled
-			if readMessages && !v.Info.IsFromMe {
+			if (readMessages || filter.read) && !v.Info.IsFromMe {
+				m.markChatRead(inst.ID, msgData.To)
 				go func() {
-					err := inst.Client.MarkRead(context.Background(), []types.MessageID{v.Info.ID}, time.Now(), v.Info.Chat, v.Info.Sender)
+					err := m.markRead(inst, []types.MessageID{v.Info.ID}, v.Info.Chat, v.Info.Sender)
 					if err != nil {
 						log.Warn().Err(err).Msg("Failed to mark message as read")
 					}
 				}()
 			}
 
+			if paused {
+				log.Debug().Str("instanceId", inst.ID).Msg("Not forwarding message event (instance paused)")
+				return
+			}
+
 			m.publishEvent(Event{
 				Type:       "message",
 				InstanceID: inst.ID,
 				Data:       msgData,
+				webhookURL: filter.webhookURL,
 			})
+			// Keyword replies come first; a Typebot flow, Dialogflow or the AI agent make the away message unnecessary
+			if !m.maybeAutoReply(inst, v.Info.Chat, msgData) &&
+				!m.maybeTypebot(inst, v.Info.Chat, msgData) &&
+				!m.maybeDialogflow(inst, v.Info.Chat, msgData) &&
+				!m.maybeAIReply(inst, v.Info.Chat, msgData) {
+				m.maybeSendAwayMessage(inst, v.Info.Chat, msgData)
+			}
+
+			if msgData.Location != nil && msgData.Location.Live {
+				m.publishLiveLocation(inst, v, msgData.Location)
+			}
 
 		case *events.HistorySync:
 			// Process history sync to capture historical messages
@@ -430,6 +913,7 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 					msgData := m.formatMessageLite(inst.ID, parsedMsg)
 					m.storeMessage(inst.ID, chatJID, msgData)
 				}
+				m.syncChatState(inst.ID, conv)
 			}
 
 			m.publishEvent(Event{
@@ -440,7 +924,21 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 				},
 			})
 
+		case *events.Presence:
+			m.handlePresence(inst, v)
+
+		case *events.ChatPresence:
+			m.handleChatPresence(inst, v)
+
 		case *events.Receipt:
+			m.learnFromSource(v.MessageSource)
+			m.handleDeliveryReceipt(inst, v)
+
+			// Reading a chat on another device clears its unread counter
+			if v.IsFromMe && (v.Type == types.ReceiptTypeRead || v.Type == types.ReceiptTypeReadSelf) {
+				m.markChatRead(inst.ID, v.Chat.String())
+			}
+
 			m.publishEvent(Event{
 				Type:       "message_ack",
 				InstanceID: inst.ID,
@@ -451,17 +949,39 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 				},
 			})
 
+		case *events.Star:
+			m.handleStar(inst, v)
+
+		case *events.DeleteForMe:
+			m.handleDeleteForMe(inst, v)
+
+		case *events.ClearChat:
+			m.handleChatCleared(inst, v.JID, v.Action.GetMessageRange(), false, v.FromFullSync)
+
+		case *events.DeleteChat:
+			m.handleChatCleared(inst, v.JID, v.Action.GetMessageRange(), true, v.FromFullSync)
+
+		case *events.MarkChatAsRead:
+			if v.Action.GetRead() {
+				m.markChatRead(inst.ID, v.JID.String())
+			} else {
+				m.markChatUnread(inst.ID, v.JID.String())
+			}
+
 		case *events.CallOffer:
 			log.Info().Str("instanceId", inst.ID).Str("from", v.CallCreator.String()).Str("callId", v.CallID).Msg("Incoming call")
+			isVideo := isVideoCall(v.Data)
+			m.recordCallOffer(inst.ID, v.BasicCallMeta, isVideo)
 
 			// Publish call event
 			m.publishEvent(Event{
 				Type:       "call",
 				InstanceID: inst.ID,
 				Data: map[string]interface{}{
-					"from":   v.CallCreator.String(),
-					"callId": v.CallID,
-					"type":   "offer",
+					"from":    v.CallCreator.String(),
+					"callId":  v.CallID,
+					"type":    "offer",
+					"isVideo": isVideo,
 				},
 			})
 
@@ -483,9 +1003,35 @@ func (m *Manager) setupEventHandlers(inst *Instance) {
 						log.Error().Err(err).Str("callId", callID).Msg("Failed to reject call")
 					} else {
 						log.Info().Str("callId", callID).Msg("Call rejected successfully")
+						m.recordCallRejected(inst, callID)
 					}
 				}(v.CallCreator, v.CallID)
 			}
+
+		case *events.CallAccept:
+			log.Info().Str("instanceId", inst.ID).Str("callId", v.CallID).Msg("Call answered on another device")
+			m.recordCallAccepted(inst.ID, v.CallID)
+			m.publishEvent(Event{
+				Type:       "call_accept",
+				InstanceID: inst.ID,
+				Data: map[string]interface{}{
+					"from":   v.CallCreator.String(),
+					"callId": v.CallID,
+				},
+			})
+
+		case *events.CallTerminate:
+			log.Info().Str("instanceId", inst.ID).Str("callId", v.CallID).Str("reason", v.Reason).Msg("Call terminated")
+			m.recordCallTerminated(inst, v.CallID, v.Reason)
+			m.publishEvent(Event{
+				Type:       "call_terminate",
+				InstanceID: inst.ID,
+				Data: map[string]interface{}{
+					"from":   v.CallCreator.String(),
+					"callId": v.CallID,
+					"reason": v.Reason,
+				},
+			})
 		}
 	})
 }
@@ -498,14 +1044,28 @@ const maxAutoDownloadSize uint64 = 5 * 1024 * 1024 // 5MB
 func (m *Manager) formatMessage(instanceID string, msg *events.Message) MessageData {
 	var body string
 	var msgType string = "text"
-	var mediaBase64 string
 	var mimetype string
 	var caption string
 	var fileName string
+	var thumbnail []byte
+	var interactive *InteractiveResponse
+	var location *LocationInfo
+	var poll *PollInfo
+	var media whatsmeow.DownloadableMessage
+	var fileLength uint64
 
 	// Get instance for media download
 	inst, _ := m.GetInstance(instanceID)
 
+	// View-once media isn't downloaded when the instance is set not to keep it
+	skipViewOnce := false
+	if msg.IsViewOnce && inst != nil {
+		inst.mu.RLock()
+		skipViewOnce = inst.SkipViewOnceMedia
+		inst.mu.RUnlock()
+	}
+	download := inst != nil && inst.Client != nil && !skipViewOnce
+
 	// Check for different message types
 	if msg.Message.GetConversation() != "" {
 		body = msg.Message.GetConversation()
@@ -515,84 +1075,64 @@ func (m *Manager) formatMessage(instanceID string, msg *events.Message) MessageD
 		msgType = "image"
 		caption = imgMsg.GetCaption()
 		mimetype = imgMsg.GetMimetype()
+		thumbnail = imgMsg.GetJPEGThumbnail()
 		body = caption
-		// Download image
-		if inst != nil && inst.Client != nil {
-			data, err := inst.Client.Download(context.Background(), imgMsg)
-			if err != nil {
-				log.Warn().Err(err).Msg("Failed to download image")
-			} else {
-				mediaBase64 = base64.StdEncoding.EncodeToString(data)
-				log.Info().Str("instanceId", instanceID).Int("bytes", len(data)).Msg("Image downloaded successfully")
-			}
-		}
+		media, fileLength = imgMsg, imgMsg.GetFileLength()
 	} else if vidMsg := msg.Message.GetVideoMessage(); vidMsg != nil {
 		msgType = "video"
 		caption = vidMsg.GetCaption()
 		mimetype = vidMsg.GetMimetype()
+		thumbnail = vidMsg.GetJPEGThumbnail()
 		body = caption
-		// Download video only if SkipVideoDownload is false
-		if inst != nil && inst.Client != nil {
-			inst.mu.RLock()
-			skipVideo := inst.SkipVideoDownload
-			inst.mu.RUnlock()
-
-			if skipVideo {
-				log.Info().Str("instanceId", instanceID).Uint64("bytes", vidMsg.GetFileLength()).Msg("Skipping video download (SkipVideoDownload enabled)")
-			} else {
-				data, err := inst.Client.Download(context.Background(), vidMsg)
-				if err != nil {
-					log.Warn().Err(err).Msg("Failed to download video")
-				} else {
-					mediaBase64 = base64.StdEncoding.EncodeToString(data)
-					log.Info().Str("instanceId", instanceID).Int("bytes", len(data)).Msg("Video downloaded successfully")
-				}
-			}
-		}
+		media, fileLength = vidMsg, vidMsg.GetFileLength()
 	} else if audioMsg := msg.Message.GetAudioMessage(); audioMsg != nil {
 		msgType = "audio"
 		mimetype = audioMsg.GetMimetype()
-		// Download audio
-		if inst != nil && inst.Client != nil {
-			data, err := inst.Client.Download(context.Background(), audioMsg)
-			if err != nil {
-				log.Warn().Err(err).Msg("Failed to download audio")
-			} else {
-				mediaBase64 = base64.StdEncoding.EncodeToString(data)
-				log.Info().Str("instanceId", instanceID).Int("bytes", len(data)).Msg("Audio downloaded successfully")
-			}
-		}
+		media, fileLength = audioMsg, audioMsg.GetFileLength()
 	} else if docMsg := msg.Message.GetDocumentMessage(); docMsg != nil {
 		msgType = "document"
 		caption = docMsg.GetCaption()
 		mimetype = docMsg.GetMimetype()
 		fileName = docMsg.GetFileName()
+		thumbnail = docMsg.GetJPEGThumbnail()
 		body = caption
-		// Download document
-		if inst != nil && inst.Client != nil {
-			data, err := inst.Client.Download(context.Background(), docMsg)
-			if err != nil {
-				log.Warn().Err(err).Msg("Failed to download document")
-			} else {
-				mediaBase64 = base64.StdEncoding.EncodeToString(data)
-				log.Info().Str("instanceId", instanceID).Int("bytes", len(data)).Msg("Document downloaded successfully")
-			}
-		}
+		media, fileLength = docMsg, docMsg.GetFileLength()
 	} else if stickerMsg := msg.Message.GetStickerMessage(); stickerMsg != nil {
 		msgType = "sticker"
 		mimetype = stickerMsg.GetMimetype()
-		// Download sticker
-		if inst != nil && inst.Client != nil {
-			data, err := inst.Client.Download(context.Background(), stickerMsg)
-			if err != nil {
-				log.Warn().Err(err).Msg("Failed to download sticker")
-			} else {
-				mediaBase64 = base64.StdEncoding.EncodeToString(data)
-				log.Info().Str("instanceId", instanceID).Int("bytes", len(data)).Msg("Sticker downloaded successfully")
-			}
+		media, fileLength = stickerMsg, stickerMsg.GetFileLength()
+	} else if location = parseLocation(msg.Message); location != nil {
+		msgType = "location"
+		if location.Live {
+			msgType = "live_location"
+		}
+		body = location.Name
+	} else if interactive = parseInteractiveResponse(msg.Message); interactive != nil {
+		msgType = interactive.Type + "_response"
+		body = interactive.DisplayText
+	} else if poll = parsePoll(msg.Message); poll != nil {
+		msgType = "poll"
+		body = poll.Question
+	}
+
+	// The reference is kept so media can be downloaded in the background
+	// (per the instance media policy) or fetched on demand
+	mediaPending := false
+	mediaSkipped := ""
+	if media != nil {
+		if mediaSkipped = m.mediaSkipReason(inst, msgType, fileLength); mediaSkipped != "" {
+			media, thumbnail = nil, nil
+		} else if download {
+			mediaPending = m.downloadsEagerly(inst, msgType, fileLength)
+		} else {
+			media = nil
 		}
 	}
 
+	if skipViewOnce {
+		thumbnail = nil
+	}
+
 	senderJID := msg.Info.Sender.String()
 	resolvedPhone := ""
 
@@ -601,16 +1141,12 @@ func (m *Manager) formatMessage(instanceID string, msg *events.Message) MessageD
 		log.Info().Str("lid", senderJID).Msg("Processing message from LID contact - starting resolution")
 
 		if inst != nil && inst.Client != nil && inst.Client.Store != nil {
-			// 1. Try LIDs table
-			if inst.Client.Store.LIDs != nil {
-				lidJID := msg.Info.Sender
-				pnJID, err := inst.Client.Store.LIDs.GetPNForLID(context.Background(), lidJID)
-				if err == nil && pnJID.User != "" {
-					resolvedPhone = pnJID.User
-					log.Info().Str("lid", senderJID).Str("resolvedPhone", resolvedPhone).Msg("✅ Resolved LID via Store.LIDs")
-				} else {
-					log.Info().Str("lid", senderJID).Err(err).Msg("❌ Failed to resolve via Store.LIDs")
-				}
+			// 1. Try Store.LIDs and the persistent LID mapping
+			if phone := m.resolveLID(inst.Client, msg.Info.Sender); phone != "" {
+				resolvedPhone = phone
+				log.Info().Str("lid", senderJID).Str("resolvedPhone", resolvedPhone).Msg("✅ Resolved LID via LID mapping")
+			} else {
+				log.Info().Str("lid", senderJID).Msg("❌ Failed to resolve via LID mapping")
 			}
 
 			// 2. If failed, try Contacts table (sometimes they are linked there)
@@ -657,10 +1193,18 @@ func (m *Manager) formatMessage(instanceID string, msg *events.Message) MessageD
 		IsGroup:       msg.Info.IsGroup,
 		PushName:      msg.Info.PushName,
 		ResolvedPhone: resolvedPhone,
-		MediaBase64:   mediaBase64,
+		MediaPending:  mediaPending,
+		MediaSkipped:  mediaSkipped,
 		Mimetype:      mimetype,
 		Caption:       caption,
 		FileName:      fileName,
+		FileLength:    fileLength,
+		Thumbnail:     thumbnail,
+		Interactive:   interactive,
+		Location:      location,
+		Poll:          poll,
+		ViewOnce:      msg.IsViewOnce,
+		media:         media,
 	}
 }
 
@@ -672,6 +1216,12 @@ func (m *Manager) formatMessageLite(instanceID string, msg *events.Message) Mess
 	var mimetype string
 	var caption string
 	var fileName string
+	var thumbnail []byte
+	var interactive *InteractiveResponse
+	var location *LocationInfo
+	var poll *PollInfo
+	var media whatsmeow.DownloadableMessage
+	var fileLength uint64
 
 	// Check for different message types - but DON'T download media
 	if msg.Message.GetConversation() != "" {
@@ -682,64 +1232,130 @@ func (m *Manager) formatMessageLite(instanceID string, msg *events.Message) Mess
 		msgType = "image"
 		caption = imgMsg.GetCaption()
 		mimetype = imgMsg.GetMimetype()
+		thumbnail = imgMsg.GetJPEGThumbnail()
 		body = caption
-		// NO media download for history
+		// NO media download for history; it can be fetched on demand
+		media, fileLength = imgMsg, imgMsg.GetFileLength()
 	} else if vidMsg := msg.Message.GetVideoMessage(); vidMsg != nil {
 		msgType = "video"
 		caption = vidMsg.GetCaption()
 		mimetype = vidMsg.GetMimetype()
+		thumbnail = vidMsg.GetJPEGThumbnail()
 		body = caption
+		media, fileLength = vidMsg, vidMsg.GetFileLength()
 	} else if audioMsg := msg.Message.GetAudioMessage(); audioMsg != nil {
 		msgType = "audio"
 		mimetype = audioMsg.GetMimetype()
+		media, fileLength = audioMsg, audioMsg.GetFileLength()
 	} else if docMsg := msg.Message.GetDocumentMessage(); docMsg != nil {
 		msgType = "document"
 		caption = docMsg.GetCaption()
 		mimetype = docMsg.GetMimetype()
 		fileName = docMsg.GetFileName()
+		thumbnail = docMsg.GetJPEGThumbnail()
 		body = caption
+		media, fileLength = docMsg, docMsg.GetFileLength()
 	} else if stickerMsg := msg.Message.GetStickerMessage(); stickerMsg != nil {
 		msgType = "sticker"
 		mimetype = stickerMsg.GetMimetype()
+		media, fileLength = stickerMsg, stickerMsg.GetFileLength()
+	} else if location = parseLocation(msg.Message); location != nil {
+		msgType = "location"
+		if location.Live {
+			msgType = "live_location"
+		}
+		body = location.Name
+	} else if interactive = parseInteractiveResponse(msg.Message); interactive != nil {
+		msgType = interactive.Type + "_response"
+		body = interactive.DisplayText
+	} else if poll = parsePoll(msg.Message); poll != nil {
+		msgType = "poll"
+		body = poll.Question
+	}
+
+	// Media the policy leaves out isn't offered on demand either
+	mediaSkipped := ""
+	if media != nil {
+		inst, _ := m.GetInstance(instanceID)
+		if mediaSkipped = m.mediaSkipReason(inst, msgType, fileLength); mediaSkipped != "" {
+			media, thumbnail = nil, nil
+		}
 	}
 
 	return MessageData{
-		ID:        msg.Info.ID,
-		From:      msg.Info.Sender.String(),
-		To:        msg.Info.Chat.String(),
-		Body:      body,
-		Type:      msgType,
-		Timestamp: msg.Info.Timestamp.Unix(),
-		FromMe:    msg.Info.IsFromMe,
-		IsGroup:   msg.Info.IsGroup,
-		PushName:  msg.Info.PushName,
-		Mimetype:  mimetype,
-		Caption:   caption,
-		FileName:  fileName,
+		ID:           msg.Info.ID,
+		From:         msg.Info.Sender.String(),
+		To:           msg.Info.Chat.String(),
+		Body:         body,
+		Type:         msgType,
+		Timestamp:    msg.Info.Timestamp.Unix(),
+		FromMe:       msg.Info.IsFromMe,
+		IsGroup:      msg.Info.IsGroup,
+		PushName:     msg.Info.PushName,
+		MediaSkipped: mediaSkipped,
+		Mimetype:     mimetype,
+		Caption:      caption,
+		FileName:     fileName,
+		FileLength:   fileLength,
+		Thumbnail:    thumbnail,
+		Interactive:  interactive,
+		Location:     location,
+		Poll:         poll,
+		ViewOnce:     msg.IsViewOnce,
+		media:        media,
 		// MediaBase64 is intentionally empty - no download for history
 	}
 }
 
 // GetContactInfo attempts to get contact information and resolve LID if applicable
 func (m *Manager) GetContactInfo(instanceID, jidStr string) (*ResolvedContactInfo, error) {
-	inst, ok := m.GetInstance(instanceID)
-	if !ok {
-		return nil, fmt.Errorf("instance not found")
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
 	}
+	return m.resolveContact(client, jidStr)
+}
 
-	inst.mu.RLock()
-	status := inst.Status
-	client := inst.Client
-	inst.mu.RUnlock()
+// Most JIDs resolved by one ResolveContacts call
+const maxResolveContacts = 1000
 
-	if status != "connected" || client == nil {
-		return nil, fmt.Errorf("instance not connected")
+// ResolveContacts resolves several JIDs (LID or phone number) at once. A JID
+// that can't be parsed gets an error entry instead of failing the batch.
+func (m *Manager) ResolveContacts(instanceID string, jids []string) ([]ResolvedContactInfo, error) {
+	if len(jids) == 0 {
+		return nil, fmt.Errorf("%w: at least 1 jid is required", ErrInvalidInput)
+	}
+	if len(jids) > maxResolveContacts {
+		return nil, fmt.Errorf("%w: at most %d jids per request", ErrInvalidInput, maxResolveContacts)
+	}
+
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ResolvedContactInfo, 0, len(jids))
+	for _, jidStr := range jids {
+		info, err := m.resolveContact(client, jidStr)
+		if err != nil {
+			results = append(results, ResolvedContactInfo{
+				OriginalJID: jidStr,
+				IsLID:       strings.HasSuffix(jidStr, "@lid"),
+				Error:       err.Error(),
+			})
+			continue
+		}
+		results = append(results, *info)
 	}
+	return results, nil
+}
 
+// resolveContact looks up the names of a JID and its phone number or LID
+func (m *Manager) resolveContact(client *whatsmeow.Client, jidStr string) (*ResolvedContactInfo, error) {
 	// Parse JID
 	jid, err := types.ParseJID(jidStr)
 	if err != nil {
-		return nil, fmt.Errorf("invalid JID: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	result := &ResolvedContactInfo{
@@ -758,18 +1374,19 @@ func (m *Manager) GetContactInfo(instanceID, jidStr string) (*ResolvedContactInf
 	}
 
 	// If it's a LID, try to resolve to phone number
-	if result.IsLID && client.Store != nil && client.Store.LIDs != nil {
-		pnJID, err := client.Store.LIDs.GetPNForLID(context.Background(), jid)
-		if err == nil && pnJID.User != "" {
-			result.ResolvedPhone = pnJID.User
+	if result.IsLID {
+		if phone := m.resolveLID(client, jid); phone != "" {
+			result.LID = jid.User
+			result.ResolvedPhone = phone
 			result.Resolved = true
 			log.Info().Str("lid", jidStr).Str("phone", result.ResolvedPhone).Msg("Successfully resolved LID to phone")
 		} else {
 			log.Debug().Str("lid", jidStr).Msg("Could not resolve LID - WhatsApp privacy restriction")
 		}
-	} else if !result.IsLID {
+	} else {
 		// For regular JIDs, extract phone number directly
 		result.ResolvedPhone = jid.User
+		result.LID = m.lidForPhone(client, jid)
 		result.Resolved = true
 	}
 
@@ -819,6 +1436,34 @@ func (m *Manager) Connect(instanceID string) (*Instance, error) {
 	return inst, nil
 }
 
+// WaitForStatus blocks until the instance reaches one of the given statuses,
+// the timeout elapses or ctx is cancelled, and returns the last seen status
+func (m *Manager) WaitForStatus(ctx context.Context, instanceID string, statuses []string, timeout time.Duration) string {
+	ch := m.Subscribe(instanceID)
+	defer m.Unsubscribe(instanceID, ch)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		status, _ := m.GetStatus(instanceID)
+		for _, s := range statuses {
+			if status == s {
+				return status
+			}
+		}
+
+		select {
+		case <-ch:
+			// Status may have changed, check again
+		case <-timer.C:
+			return status
+		case <-ctx.Done():
+			return status
+		}
+	}
+}
+
 // ConnectWithPairingCode connects an instance using phone pairing code
 func (m *Manager) ConnectWithPairingCode(instanceID, phoneNumber string) (string, error) {
 	inst, err := m.GetOrCreateInstance(instanceID)
@@ -831,18 +1476,19 @@ func (m *Manager) ConnectWithPairingCode(instanceID, phoneNumber string) (string
 	inst.mu.Unlock()
 
 	if currentStatus == "connected" {
-		return "", fmt.Errorf("already connected")
+		return "", ErrAlreadyConnected
 	}
 
 	// Check if already has a session - pairing code only works for new connections
 	if inst.Client.Store.ID != nil {
-		return "", fmt.Errorf("already has a session, use QR code or disconnect first")
+		return "", fmt.Errorf("%w: already has a session, use QR code or disconnect first", ErrAlreadyConnected)
 	}
 
-	// Clean phone number - remove + and any spaces/dashes
-	phoneNumber = strings.TrimPrefix(phoneNumber, "+")
-	phoneNumber = strings.ReplaceAll(phoneNumber, " ", "")
-	phoneNumber = strings.ReplaceAll(phoneNumber, "-", "")
+	// Clean phone number - pairing needs the full international number
+	phoneNumber, err = m.normalizePhone(phoneNumber)
+	if err != nil {
+		return "", err
+	}
 
 	log.Info().Str("instanceId", instanceID).Str("phone", phoneNumber).Msg("Starting pairing code connection")
 
@@ -877,7 +1523,11 @@ func (m *Manager) ConnectWithPairingCode(instanceID, phoneNumber string) (string
 	log.Info().Str("instanceId", instanceID).Msg("Connected, requesting pairing code...")
 
 	// Request pairing code
-	code, err := inst.Client.PairPhone(context.Background(), phoneNumber, true, whatsmeow.PairClientChrome, "Chrome (Mac OS)")
+	inst.mu.RLock()
+	identity, _ := inst.Identity.normalize()
+	inst.mu.RUnlock()
+	clientType, displayName := identity.pairClient()
+	code, err := inst.Client.PairPhone(context.Background(), phoneNumber, true, clientType, displayName)
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Str("phone", phoneNumber).Msg("Failed to get pairing code")
 		inst.mu.Lock()
@@ -927,20 +1577,21 @@ func (m *Manager) GetPairingCode(instanceID string) string {
 func (m *Manager) MarkChatAsRead(instanceID, chatID string, messageIDs []string) error {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return fmt.Errorf("instance not found")
+		return ErrInstanceNotFound
 	}
 	inst.mu.RLock()
 	client := inst.Client
 	inst.mu.RUnlock()
 
 	if client == nil {
-		return fmt.Errorf("client not initialized")
+		return fmt.Errorf("%w: client not initialized", ErrNotConnected)
 	}
 
 	// Clean and parse chat JID
-	chatID = strings.TrimPrefix(chatID, "+")
-	chatID = strings.ReplaceAll(chatID, " ", "")
-	chatID = strings.ReplaceAll(chatID, "-", "")
+	chatID, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
 
 	if !strings.Contains(chatID, "@") {
 		chatID = chatID + "@s.whatsapp.net"
@@ -948,7 +1599,7 @@ func (m *Manager) MarkChatAsRead(instanceID, chatID string, messageIDs []string)
 
 	chatJID, err := types.ParseJID(chatID)
 	if err != nil {
-		return fmt.Errorf("invalid chat JID: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	// Convert string IDs to MessageID type
@@ -960,7 +1611,7 @@ func (m *Manager) MarkChatAsRead(instanceID, chatID string, messageIDs []string)
 	// If no message IDs provided, we need at least one
 	// Use a placeholder that whatsmeow might accept or return error
 	if len(msgIDs) == 0 {
-		return fmt.Errorf("at least one messageId is required to mark chat as read")
+		return fmt.Errorf("%w: at least one messageId is required to mark chat as read", ErrInvalidInput)
 	}
 
 	log.Info().
@@ -970,19 +1621,80 @@ func (m *Manager) MarkChatAsRead(instanceID, chatID string, messageIDs []string)
 		Msg("Marking messages as read")
 
 	// Mark as read
-	return client.MarkRead(context.Background(), msgIDs, time.Now(), chatJID, types.EmptyJID)
+	if err := m.markRead(inst, msgIDs, chatJID, types.EmptyJID); err != nil {
+		return err
+	}
+	m.markChatRead(instanceID, chatJID.String())
+	return nil
 }
 
-// Disconnect disconnects an instance
-func (m *Manager) Disconnect(instanceID string) error {
-	m.mu.RLock()
-	inst, ok := m.instances[instanceID]
-	m.mu.RUnlock()
+// markRead marks messages as read. With read receipts turned off, read-self
+// receipts are sent instead: linked devices see the chat as read, the sender
+// gets no blue ticks.
+func (m *Manager) markRead(inst *Instance, ids []types.MessageID, chat, sender types.JID) error {
+	inst.mu.RLock()
+	hideReceipts := inst.HideReadReceipts
+	inst.mu.RUnlock()
+
+	if hideReceipts {
+		return inst.Client.MarkRead(context.Background(), ids, time.Now(), chat, sender, types.ReceiptTypeReadSelf)
+	}
+	return inst.Client.MarkRead(context.Background(), ids, time.Now(), chat, sender)
+}
+
+// MarkChatAsUnread flags a chat as unread on all linked devices through an app
+// state patch. The last stored message of the chat anchors the patch, as the
+// phone expects.
+func (m *Manager) MarkChatAsUnread(instanceID, chatID string) error {
+	inst, chatJID, err := m.resolveRecipient(instanceID, chatID)
+	if err != nil {
+		return err
+	}
+
+	lastTimestamp, lastKey := m.lastMessageKey(instanceID, chatJID)
+
+	log.Info().Str("instanceId", instanceID).Str("chatJID", chatJID.String()).Msg("Marking chat as unread")
+
+	patch := appstate.BuildMarkChatAsRead(chatJID, false, lastTimestamp, lastKey)
+	if err := inst.Client.SendAppState(context.Background(), patch); err != nil {
+		return fmt.Errorf("failed to mark chat as unread: %w", err)
+	}
+	m.markChatUnread(instanceID, chatJID.String())
+	return nil
+}
+
+// lastMessageKey returns the timestamp and key of the last stored message of a
+// chat, which chat-level app state patches use as anchor. Both are zero when
+// nothing is stored.
+func (m *Manager) lastMessageKey(instanceID string, chatJID types.JID) (time.Time, *waCommon.MessageKey) {
+	msgs, _ := m.GetChatMessages(instanceID, chatJID.String(), 1)
+	if len(msgs) == 0 {
+		return time.Time{}, nil
+	}
+
+	last := msgs[0]
+	key := &waCommon.MessageKey{
+		RemoteJID: proto.String(chatJID.String()),
+		FromMe:    proto.Bool(last.FromMe),
+		ID:        proto.String(last.ID),
+	}
+	if last.IsGroup && !last.FromMe {
+		key.Participant = proto.String(last.From)
+	}
+	return time.Unix(last.Timestamp, 0), key
+}
+
+// Disconnect disconnects an instance
+func (m *Manager) Disconnect(instanceID string) error {
+	m.mu.RLock()
+	inst, ok := m.instances[instanceID]
+	m.mu.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("instance %s not found", instanceID)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
 	}
 
+	m.stopQRIdleTimer(inst)
 	inst.Client.Disconnect()
 
 	inst.mu.Lock()
@@ -999,7 +1711,10 @@ func (m *Manager) Logout(instanceID string) error {
 	m.mu.Unlock()
 
 	if !ok {
-		return fmt.Errorf("instance %s not found", instanceID)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	if m.isDeleted(instanceID) {
+		return fmt.Errorf("%w: %s", ErrInstanceDeleted, instanceID)
 	}
 
 	err := inst.Client.Logout(context.Background())
@@ -1009,10 +1724,8 @@ func (m *Manager) Logout(instanceID string) error {
 
 	inst.Client.Disconnect()
 
-	// Remove from memory
-	m.mu.Lock()
-	delete(m.instances, instanceID)
-	m.mu.Unlock()
+	// Keep history for the grace period instead of wiping it right away
+	m.softDelete(inst)
 
 	return nil
 }
@@ -1043,6 +1756,9 @@ func (m *Manager) GetInstance(instanceID string) (*Instance, bool) {
 func (m *Manager) GetStatus(instanceID string) (string, map[string]string) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
+		if m.isDeleted(instanceID) {
+			return "deleted", nil
+		}
 		return "not_found", nil
 	}
 
@@ -1068,120 +1784,9 @@ func (m *Manager) GetQRCode(instanceID string) (string, string) {
 	return inst.QRCode, inst.QRCodeBase64
 }
 
-// LinkPreview holds Open Graph metadata for a URL
-type LinkPreview struct {
-	URL         string
-	Title       string
-	Description string
-	SiteName    string
-	ImageURL    string
-	Thumbnail   []byte
-}
-
-// urlRegex matches http/https URLs
-var urlRegex = regexp.MustCompile(`https?://[^\s<>"']+`)
-
-// extractFirstURL finds the first URL in text
-func extractFirstURL(text string) string {
-	match := urlRegex.FindString(text)
-	return match
-}
-
-// fetchLinkPreview fetches Open Graph metadata from a URL
-func fetchLinkPreview(targetURL string) (*LinkPreview, error) {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", targetURL, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsApp/2.23; +http://www.whatsapp.com)")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("status %d", resp.StatusCode)
-	}
-
-	// Read body (limit to 1MB)
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
-	if err != nil {
-		return nil, err
-	}
-
-	htmlStr := string(body)
-
-	preview := &LinkPreview{
-		URL: targetURL,
-	}
-
-	// Extract Open Graph tags
-	preview.Title = extractMetaContent(htmlStr, "og:title")
-	if preview.Title == "" {
-		preview.Title = extractHTMLTitle(htmlStr)
-	}
-	preview.Description = extractMetaContent(htmlStr, "og:description")
-	if preview.Description == "" {
-		preview.Description = extractMetaContent(htmlStr, "description")
-	}
-	preview.SiteName = extractMetaContent(htmlStr, "og:site_name")
-	preview.ImageURL = extractMetaContent(htmlStr, "og:image")
-
-	// Make image URL absolute if relative
-	if preview.ImageURL != "" && !strings.HasPrefix(preview.ImageURL, "http") {
-		baseURL, _ := url.Parse(targetURL)
-		imgURL, _ := url.Parse(preview.ImageURL)
-		preview.ImageURL = baseURL.ResolveReference(imgURL).String()
-	}
-
-	// Download thumbnail if available
-	if preview.ImageURL != "" {
-		preview.Thumbnail = downloadThumbnail(preview.ImageURL)
-	}
-
-	return preview, nil
-}
-
-// extractMetaContent extracts content from <meta property="name" content="value"> or <meta name="name" content="value">
-func extractMetaContent(html, name string) string {
-	// Try property="name"
-	pattern := regexp.MustCompile(`<meta[^>]+(?:property|name)=["']` + regexp.QuoteMeta(name) + `["'][^>]+content=["']([^"']*)["']`)
-	match := pattern.FindStringSubmatch(html)
-	if len(match) > 1 {
-		return match[1]
-	}
-
-	// Try content first
-	pattern2 := regexp.MustCompile(`<meta[^>]+content=["']([^"']*)["'][^>]+(?:property|name)=["']` + regexp.QuoteMeta(name) + `["']`)
-	match2 := pattern2.FindStringSubmatch(html)
-	if len(match2) > 1 {
-		return match2[1]
-	}
-
-	return ""
-}
-
-// extractHTMLTitle extracts <title> content
-func extractHTMLTitle(html string) string {
-	pattern := regexp.MustCompile(`<title[^>]*>([^<]*)</title>`)
-	match := pattern.FindStringSubmatch(html)
-	if len(match) > 1 {
-		return strings.TrimSpace(match[1])
-	}
-	return ""
-}
-
 // downloadThumbnail downloads and returns image bytes (limited size)
-func downloadThumbnail(imageURL string) []byte {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+func downloadThumbnail(fetcher *previewFetcher, imageURL string) []byte {
+	client := fetcher.client(5 * time.Second)
 
 	req, err := http.NewRequest("GET", imageURL, nil)
 	if err != nil {
@@ -1208,11 +1813,15 @@ func downloadThumbnail(imageURL string) []byte {
 	return data
 }
 
-// SendTextMessage sends a text message (with automatic link preview if URL detected)
-func (m *Manager) SendTextMessage(instanceID, to, text string) (string, error) {
+// SendTextMessage sends a text message, previewing its first URL as preview
+// selects
+func (m *Manager) SendTextMessage(ctx context.Context, instanceID, to, text string, preview PreviewOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendTextMessage", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return "", fmt.Errorf("instance %s not found", instanceID)
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
 	}
 
 	inst.mu.RLock()
@@ -1220,15 +1829,17 @@ func (m *Manager) SendTextMessage(instanceID, to, text string) (string, error) {
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return "", fmt.Errorf("instance not connected (status: %s)", status)
+		return "", fmt.Errorf("%w (status: %s)", ErrNotConnected, status)
 	}
 
-	// Parse recipient JID
-	// Ensure the number is just digits
-	to = strings.TrimPrefix(to, "+")
+	// Parse recipient number
+	to, err := m.normalizePhone(to)
+	if err != nil {
+		return "", err
+	}
 
 	// First, check if the user is on WhatsApp to get the correct JID
-	users, err := inst.Client.IsOnWhatsApp(context.Background(), []string{to})
+	users, err := tracedIsOnWhatsApp(ctx, inst.Client, []string{to})
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Str("to", to).Msg("Failed to check if user is on WhatsApp")
 		return "", fmt.Errorf("failed to check if user is on WhatsApp: %w", err)
@@ -1237,68 +1848,26 @@ func (m *Manager) SendTextMessage(instanceID, to, text string) (string, error) {
 	// IsOnWhatsApp returns a list of contacts. If the number is not registered, it might return a contact with VerifiedName nil or similar,
 	// but usually checking if JID is present is enough.
 	if len(users) == 0 {
-		return "", fmt.Errorf("user %s not on WhatsApp", to)
+		return "", fmt.Errorf("user %s %w", to, ErrNotOnWhatsApp)
 	}
 
 	if users[0].JID.User == "" {
-		return "", fmt.Errorf("received empty JID for user %s", to)
+		return "", fmt.Errorf("%w: received empty JID for user %s", ErrNotOnWhatsApp, to)
 	}
 
 	// Use the correct JID returned by server
 	jid := users[0].JID
+	m.rememberCanonicalJID(jid)
+	m.learnLIDs(inst.Client, jid)
 
-	// Build message - check for URLs to generate preview
-	var msg *waE2E.Message
-
-	foundURL := extractFirstURL(text)
-	if foundURL != "" {
-		log.Debug().Str("instanceId", instanceID).Str("url", foundURL).Msg("URL detected, fetching link preview")
-
-		// Try to fetch link preview (don't fail if it doesn't work)
-		preview, err := fetchLinkPreview(foundURL)
-		if err != nil {
-			log.Warn().Err(err).Str("url", foundURL).Msg("Failed to fetch link preview, sending as plain text")
-			// Fall back to plain text
-			msg = &waE2E.Message{
-				Conversation: proto.String(text),
-			}
-		} else {
-			log.Info().Str("instanceId", instanceID).Str("title", preview.Title).Str("url", foundURL).Msg("Link preview fetched successfully")
-
-			// Build ExtendedTextMessage with preview
-			extMsg := &waE2E.ExtendedTextMessage{
-				Text:        proto.String(text),
-				MatchedText: proto.String(foundURL),
-				PreviewType: waE2E.ExtendedTextMessage_VIDEO.Enum(), // Use VIDEO type for rich preview
-			}
-
-			if preview.Title != "" {
-				extMsg.Title = proto.String(preview.Title)
-			}
-			if preview.Description != "" {
-				extMsg.Description = proto.String(preview.Description)
-			}
-			if len(preview.Thumbnail) > 0 {
-				extMsg.JPEGThumbnail = preview.Thumbnail
-			}
-
-			msg = &waE2E.Message{
-				ExtendedTextMessage: extMsg,
-			}
-		}
-	} else {
-		// No URL, send as plain conversation
-		msg = &waE2E.Message{
-			Conversation: proto.String(text),
-		}
-	}
+	msg := m.textMessage(ctx, inst, text, preview)
 
 	log.Debug().Str("instanceId", instanceID).Str("jid", jid.String()).Msg("Attempting to send message via whatsmeow")
 
-	resp, err := inst.Client.SendMessage(context.Background(), jid, msg)
+	resp, err := m.sendTracked(ctx, instanceID, inst.Client, jid, msg)
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Str("jid", jid.String()).Msg("Whatsmeow SendMessage failed")
-		return "", fmt.Errorf("whatsmeow send error: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	// Clear presence (stop typing) immediately after sending
@@ -1314,7 +1883,7 @@ func (m *Manager) SendTextMessage(instanceID, to, text string) (string, error) {
 func (m *Manager) SendPresence(instanceID, to, presence string) error {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return fmt.Errorf("instance %s not found", instanceID)
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
 	}
 
 	inst.mu.RLock()
@@ -1322,11 +1891,14 @@ func (m *Manager) SendPresence(instanceID, to, presence string) error {
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return fmt.Errorf("instance not connected")
+		return ErrNotConnected
 	}
 
 	// Clean number
-	to = strings.TrimPrefix(to, "+")
+	to, err := m.normalizePhone(to)
+	if err != nil {
+		return err
+	}
 
 	// Start verification
 	users, err := inst.Client.IsOnWhatsApp(context.Background(), []string{to})
@@ -1334,10 +1906,12 @@ func (m *Manager) SendPresence(instanceID, to, presence string) error {
 		return fmt.Errorf("failed to check user: %w", err)
 	}
 	if len(users) == 0 {
-		return fmt.Errorf("user %s not on WhatsApp", to)
+		return fmt.Errorf("user %s %w", to, ErrNotOnWhatsApp)
 	}
 
 	jid := users[0].JID
+	m.rememberCanonicalJID(jid)
+	m.learnLIDs(inst.Client, jid)
 
 	// logic above specifically sends chat presence (typing...),
 	// standard presence (online) is handled differently but usually automatic.
@@ -1363,122 +1937,192 @@ func (m *Manager) SendPresence(instanceID, to, presence string) error {
 
 	err = inst.Client.SendChatPresence(context.Background(), jid, p, mp)
 	if err != nil {
-		return fmt.Errorf("failed to send presence: %w", err)
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	return nil
 }
 
+// MediaOptions holds optional attributes of a media message
+type MediaOptions struct {
+	FileName    string // Document name; derived from the URL when empty
+	Mimetype    string // Overrides the detected mime type
+	GIFPlayback bool   // Play a video as a looping GIF
+	ViewOnce    bool   // Image, video or audio that can only be opened once
+
+	// Image compression of this send, over the server setting (0 = server setting)
+	MaxDimension int
+	Quality      int
+}
+
 // SendMediaMessage sends a media message (image, video, audio, document)
-func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType string) (string, error) {
-	inst, ok := m.GetInstance(instanceID)
-	if !ok {
-		return "", fmt.Errorf("instance %s not found", instanceID)
+func (m *Manager) SendMediaMessage(ctx context.Context, instanceID, to, mediaUrl, caption, mediaType string, opts MediaOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendMediaMessage", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, jid, err := m.mediaRecipient(ctx, instanceID, to)
+	if err != nil {
+		return "", err
 	}
+	return m.sendMediaURL(ctx, inst, jid, mediaUrl, caption, mediaType, opts)
+}
 
-	// Clean number and verify
-	to = strings.TrimPrefix(to, "+")
-	users, err := inst.Client.IsOnWhatsApp(context.Background(), []string{to})
-	if err != nil || len(users) == 0 {
-		return "", fmt.Errorf("user %s not on WhatsApp", to)
+// sendMediaURL downloads media from a URL, uploads it and sends it to jid
+func (m *Manager) sendMediaURL(ctx context.Context, inst *Instance, jid types.JID, mediaUrl, caption, mediaType string, opts MediaOptions) (string, error) {
+	instanceID := inst.ID
+	transport, err := m.proxyTransport(inst)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+	}
+	_, loadSpan := tracing.Start(ctx, "loadMedia", attribute.String("url.full", mediaUrl))
+	data, mimeType, fileName, err := loadMedia(transport, mediaUrl)
+	tracing.RecordError(loadSpan, err)
+	loadSpan.End()
+	if err != nil {
+		return "", err
+	}
+	if opts.Mimetype != "" {
+		mimeType = opts.Mimetype
+	}
+	if opts.FileName == "" {
+		opts.FileName = fileName
 	}
-	jid := users[0].JID
 
-	var data []byte
-	var mimeType string
+	log.Info().Str("instanceId", instanceID).Str("mediaType", mediaType).Str("mimeType", mimeType).Msg("Uploading media")
 
-	if strings.HasPrefix(mediaUrl, "data:") {
-		// Handle Data URI
-		parts := strings.SplitN(mediaUrl, ",", 2)
-		if len(parts) != 2 {
-			return "", fmt.Errorf("invalid data URI")
-		}
-		// Extract mime
-		meta := strings.SplitN(parts[0], ";", 2)
-		if len(meta) > 0 {
-			mimeType = strings.TrimPrefix(meta[0], "data:")
-		}
+	mediaType, appMedia := resolveMediaType(mediaType, mimeType)
+	if opts.ViewOnce && mediaType == "document" {
+		return "", fmt.Errorf("%w: viewOnce is only supported for image, video and audio", ErrInvalidInput)
+	}
 
-		// Decode
-		var decodeErr error
-		if strings.Contains(parts[0], ";base64") {
-			data, decodeErr = base64.StdEncoding.DecodeString(parts[1])
-		} else {
-			// URL encoded
-			return "", fmt.Errorf("url-encoded data URIs not supported yet")
-		}
-		if decodeErr != nil {
-			return "", fmt.Errorf("failed to decode data URI: %w", decodeErr)
+	// Images sent as documents keep their original quality
+	if mediaType == "image" {
+		compression := m.imageCompressionFor(opts)
+		if err := compression.validate(); err != nil {
+			return "", err
 		}
-	} else {
-		// Handle URL
-		req, err := http.NewRequest("GET", mediaUrl, nil)
+		originalSize := len(data)
+		data, mimeType, err = compressImage(data, mimeType, compression)
 		if err != nil {
-			return "", fmt.Errorf("failed to create request: %w", err)
+			return "", fmt.Errorf("%w: %w", ErrInvalidInput, err)
 		}
-
-		// Add User-Agent to avoid 403 Forbidden on some servers
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-
-		transport := &http.Transport{
-			DisableKeepAlives: true,
-		}
-		client := &http.Client{
-			Timeout:   30 * time.Second,
-			Transport: transport,
+		if len(data) != originalSize {
+			log.Debug().Str("instanceId", instanceID).Int("from", originalSize).Int("to", len(data)).Msg("Compressed image")
 		}
+	}
 
-		resp, err := client.Do(req)
+	// Upload to WhatsApp
+	uploaded, err := tracedUpload(ctx, inst.Client, data, appMedia)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMediaUploadFailed, err)
+	}
+	m.countUsage(instanceID, statMediaBytesSent, int64(uploaded.FileLength))
+
+	var thumbnail []byte
+	if mediaType != "audio" {
+		thumbnail, err = mediaThumbnail(data, mimeType)
 		if err != nil {
-			return "", fmt.Errorf("failed to download media: %w", err)
+			log.Warn().Err(err).Str("instanceId", instanceID).Str("mediaType", mediaType).Msg("Failed to generate media thumbnail")
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != 200 {
-			return "", fmt.Errorf("failed to download media, status: %d", resp.StatusCode)
-		}
+	return m.sendUploadedMedia(ctx, inst, jid, uploaded, mimeType, caption, mediaType, opts, thumbnail)
+}
 
-		data, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to read media body: %w", err)
-		}
-		mimeType = http.DetectContentType(data)
+// SendMediaReader sends media read from r, streaming it through a temporary
+// file instead of holding it in memory. The mime type is sniffed when not given.
+func (m *Manager) SendMediaReader(ctx context.Context, instanceID, to string, r io.Reader, caption, mediaType string, opts MediaOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendMediaReader", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, jid, err := m.mediaRecipient(ctx, instanceID, to)
+	if err != nil {
+		return "", err
 	}
 
-	log.Info().Str("instanceId", instanceID).Str("mediaType", mediaType).Str("mimeType", mimeType).Msg("Uploading media")
+	mimeType := opts.Mimetype
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		br := bufio.NewReaderSize(r, 512)
+		head, _ := br.Peek(512)
+		mimeType = http.DetectContentType(head)
+		r = br
+	}
 
-	// Determine upload type based on mediaType or mimeType
-	var appMedia whatsmeow.MediaType
+	log.Info().Str("instanceId", instanceID).Str("mediaType", mediaType).Str("mimeType", mimeType).Msg("Uploading streamed media")
+
+	mediaType, appMedia := resolveMediaType(mediaType, mimeType)
+	if opts.ViewOnce && mediaType == "document" {
+		return "", fmt.Errorf("%w: viewOnce is only supported for image, video and audio", ErrInvalidInput)
+	}
+
+	uploaded, err := tracedUploadReader(ctx, inst.Client, r, appMedia)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMediaUploadFailed, err)
+	}
+	m.countUsage(instanceID, statMediaBytesSent, int64(uploaded.FileLength))
+
+	// Streamed media isn't kept around, so it goes without compression or a thumbnail
+	return m.sendUploadedMedia(ctx, inst, jid, uploaded, mimeType, caption, mediaType, opts, nil)
+}
+
+// mediaRecipient checks the instance is connected and resolves the recipient of a media message
+func (m *Manager) mediaRecipient(ctx context.Context, instanceID, to string) (*Instance, types.JID, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, types.JID{}, fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+
+	inst.mu.RLock()
+	status := inst.Status
+	inst.mu.RUnlock()
+
+	if status != "connected" {
+		return nil, types.JID{}, fmt.Errorf("%w (status: %s)", ErrNotConnected, status)
+	}
+
+	// Clean number and verify
+	to, err := m.normalizePhone(to)
+	if err != nil {
+		return nil, types.JID{}, err
+	}
+	users, err := tracedIsOnWhatsApp(ctx, inst.Client, []string{to})
+	if err != nil || len(users) == 0 {
+		return nil, types.JID{}, fmt.Errorf("user %s %w", to, ErrNotOnWhatsApp)
+	}
+	jid := users[0].JID
+	m.rememberCanonicalJID(jid)
+	m.learnLIDs(inst.Client, jid)
+
+	return inst, jid, nil
+}
+
+// resolveMediaType determines the message and upload type from the requested
+// mediaType, inferring it from the mime type when not given
+func resolveMediaType(mediaType, mimeType string) (string, whatsmeow.MediaType) {
 	switch mediaType {
 	case "image":
-		appMedia = whatsmeow.MediaImage
+		return mediaType, whatsmeow.MediaImage
 	case "video":
-		appMedia = whatsmeow.MediaVideo
+		return mediaType, whatsmeow.MediaVideo
 	case "audio":
-		appMedia = whatsmeow.MediaAudio
-	default:
-		// Infer from mime
-		if strings.HasPrefix(mimeType, "image/") {
-			appMedia = whatsmeow.MediaImage
-			mediaType = "image"
-		} else if strings.HasPrefix(mimeType, "video/") {
-			appMedia = whatsmeow.MediaVideo
-			mediaType = "video"
-		} else if strings.HasPrefix(mimeType, "audio/") {
-			appMedia = whatsmeow.MediaAudio
-			mediaType = "audio"
-		} else {
-			appMedia = whatsmeow.MediaDocument
-			mediaType = "document"
-		}
+		return mediaType, whatsmeow.MediaAudio
+	case "document":
+		return mediaType, whatsmeow.MediaDocument
 	}
 
-	// Upload to WhatsApp
-	uploaded, err := inst.Client.Upload(context.Background(), data, appMedia)
-	if err != nil {
-		return "", fmt.Errorf("failed to upload media: %w", err)
+	// Infer from mime
+	if strings.HasPrefix(mimeType, "image/") {
+		return "image", whatsmeow.MediaImage
+	} else if strings.HasPrefix(mimeType, "video/") {
+		return "video", whatsmeow.MediaVideo
+	} else if strings.HasPrefix(mimeType, "audio/") {
+		return "audio", whatsmeow.MediaAudio
 	}
+	return "document", whatsmeow.MediaDocument
+}
 
+// sendUploadedMedia sends a message referencing media already uploaded to WhatsApp
+func (m *Manager) sendUploadedMedia(ctx context.Context, inst *Instance, jid types.JID, uploaded whatsmeow.UploadResponse, mimeType, caption, mediaType string, opts MediaOptions, thumbnail []byte) (string, error) {
 	msg := &waE2E.Message{}
 
 	switch mediaType {
@@ -1491,7 +2135,9 @@ func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType
 			Mimetype:      proto.String(mimeType),
 			FileEncSHA256: uploaded.FileEncSHA256,
 			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uint64(len(data))),
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			JPEGThumbnail: thumbnail,
+			ViewOnce:      proto.Bool(opts.ViewOnce),
 		}
 	case "video":
 		msg.VideoMessage = &waE2E.VideoMessage{
@@ -1502,7 +2148,10 @@ func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType
 			Mimetype:      proto.String(mimeType),
 			FileEncSHA256: uploaded.FileEncSHA256,
 			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uint64(len(data))),
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			JPEGThumbnail: thumbnail,
+			GifPlayback:   proto.Bool(opts.GIFPlayback),
+			ViewOnce:      proto.Bool(opts.ViewOnce),
 		}
 	case "audio":
 		msg.AudioMessage = &waE2E.AudioMessage{
@@ -1512,8 +2161,9 @@ func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType
 			Mimetype:      proto.String(mimeType),
 			FileEncSHA256: uploaded.FileEncSHA256,
 			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uint64(len(data))),
+			FileLength:    proto.Uint64(uploaded.FileLength),
 			PTT:           proto.Bool(true),
+			ViewOnce:      proto.Bool(opts.ViewOnce),
 		}
 	case "document":
 		msg.DocumentMessage = &waE2E.DocumentMessage{
@@ -1524,16 +2174,25 @@ func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType
 			Mimetype:      proto.String(mimeType),
 			FileEncSHA256: uploaded.FileEncSHA256,
 			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uint64(len(data))),
-			FileName:      proto.String("file"), // TODO: Parse filename from URL
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			FileName:      proto.String(documentFileName(opts.FileName, mimeType)),
+			JPEGThumbnail: thumbnail,
+		}
+		if config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail)); err == nil {
+			msg.DocumentMessage.ThumbnailWidth = proto.Uint32(uint32(config.Width))
+			msg.DocumentMessage.ThumbnailHeight = proto.Uint32(uint32(config.Height))
 		}
 	default:
-		return "", fmt.Errorf("unsupported media type: %s", mediaType)
+		return "", fmt.Errorf("%w: unsupported media type: %s", ErrInvalidInput, mediaType)
+	}
+
+	if opts.ViewOnce {
+		msg = &waE2E.Message{ViewOnceMessage: &waE2E.FutureProofMessage{Message: msg}}
 	}
 
-	sentResp, err := inst.Client.SendMessage(context.Background(), jid, msg)
+	sentResp, err := m.sendTracked(ctx, inst.ID, inst.Client, jid, msg)
 	if err != nil {
-		return "", fmt.Errorf("failed to send media message: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	// Clear presence (stop typing/recording) immediately after sending
@@ -1544,11 +2203,103 @@ func (m *Manager) SendMediaMessage(instanceID, to, mediaUrl, caption, mediaType
 	return sentResp.ID, nil
 }
 
+// documentFileName returns the name shown for a document, falling back to
+// "file" with an extension matching the mime type
+func documentFileName(fileName, mimeType string) string {
+	if fileName != "" {
+		return fileName
+	}
+	exts, err := mime.ExtensionsByType(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if err != nil || len(exts) == 0 {
+		return "file"
+	}
+	return "file" + exts[0]
+}
+
+// loadMedia reads media from a data URI or downloads it from a URL. The file
+// name comes from the Content-Disposition header or the last segment of the URL path.
+func loadMedia(transport *http.Transport, mediaUrl string) (data []byte, mimeType, fileName string, err error) {
+	if strings.HasPrefix(mediaUrl, "data:") {
+		// Handle Data URI
+		parts := strings.SplitN(mediaUrl, ",", 2)
+		if len(parts) != 2 {
+			return nil, "", "", fmt.Errorf("%w: invalid data URI", ErrInvalidInput)
+		}
+		// Extract mime
+		meta := strings.SplitN(parts[0], ";", 2)
+		if len(meta) > 0 {
+			mimeType = strings.TrimPrefix(meta[0], "data:")
+		}
+
+		// Decode
+		var decodeErr error
+		if strings.Contains(parts[0], ";base64") {
+			data, decodeErr = base64.StdEncoding.DecodeString(parts[1])
+		} else {
+			// URL encoded
+			return nil, "", "", fmt.Errorf("%w: url-encoded data URIs not supported yet", ErrInvalidInput)
+		}
+		if decodeErr != nil {
+			return nil, "", "", fmt.Errorf("%w: failed to decode data URI: %w", ErrInvalidInput, decodeErr)
+		}
+	} else {
+		// Handle URL
+		req, err := http.NewRequest("GET", mediaUrl, nil)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+
+		// Add User-Agent to avoid 403 Forbidden on some servers
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+		transport.DisableKeepAlives = true
+		client := &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			return nil, "", "", fmt.Errorf("%w: status %d", ErrMediaDownloadFailed, resp.StatusCode)
+		}
+
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+		}
+		mimeType = http.DetectContentType(data)
+
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			fileName = path.Base(params["filename"])
+		} else if base := path.Base(req.URL.Path); base != "." && base != "/" && strings.Contains(base, ".") {
+			fileName = base
+		}
+	}
+
+	return data, mimeType, fileName, nil
+}
+
+// Location is a location message. With a name or address it shows as a
+// place (venue), optionally with a link and a preview image.
+type Location struct {
+	Latitude     float64
+	Longitude    float64
+	Name         string
+	Address      string
+	URL          string // Link of the place, such as its page on a maps site
+	ThumbnailURL string // JPEG shown as the place's preview
+}
+
 // SendLocationMessage sends a location message
-func (m *Manager) SendLocationMessage(instanceID, to string, latitude, longitude float64, description string) (string, error) {
+func (m *Manager) SendLocationMessage(instanceID, to string, loc Location) (string, error) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return "", fmt.Errorf("instance not found")
+		return "", ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1556,13 +2307,14 @@ func (m *Manager) SendLocationMessage(instanceID, to string, latitude, longitude
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return "", fmt.Errorf("instance not connected")
+		return "", ErrNotConnected
 	}
 
 	// Clean phone number
-	to = strings.TrimPrefix(to, "+")
-	to = strings.ReplaceAll(to, " ", "")
-	to = strings.ReplaceAll(to, "-", "")
+	to, err := m.normalizeRecipient(to)
+	if err != nil {
+		return "", err
+	}
 
 	// Ensure it has @s.whatsapp.net suffix
 	if !strings.Contains(to, "@") {
@@ -1571,28 +2323,43 @@ func (m *Manager) SendLocationMessage(instanceID, to string, latitude, longitude
 
 	jid, err := types.ParseJID(to)
 	if err != nil {
-		return "", fmt.Errorf("invalid JID: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
-	msg := &waE2E.Message{
-		LocationMessage: &waE2E.LocationMessage{
-			DegreesLatitude:  proto.Float64(latitude),
-			DegreesLongitude: proto.Float64(longitude),
-			Name:             proto.String(description),
-			Address:          proto.String(description),
-		},
+	location := &waE2E.LocationMessage{
+		DegreesLatitude:  proto.Float64(loc.Latitude),
+		DegreesLongitude: proto.Float64(loc.Longitude),
+	}
+	if loc.Name != "" {
+		location.Name = proto.String(loc.Name)
+	}
+	if loc.Address != "" {
+		location.Address = proto.String(loc.Address)
+	}
+	if loc.URL != "" {
+		location.URL = proto.String(loc.URL)
+	}
+	if loc.ThumbnailURL != "" {
+		// The preview is optional; the place is sent without it on failure
+		if fetcher, err := m.previewFetcher(inst); err == nil {
+			location.JPEGThumbnail = downloadThumbnail(fetcher, loc.ThumbnailURL)
+		}
+		if location.JPEGThumbnail == nil {
+			log.Warn().Str("instanceId", instanceID).Str("url", loc.ThumbnailURL).Msg("Failed to fetch location thumbnail, sending without it")
+		}
 	}
+	msg := &waE2E.Message{LocationMessage: location}
 
 	log.Info().
 		Str("instanceId", instanceID).
 		Str("to", to).
-		Float64("lat", latitude).
-		Float64("long", longitude).
+		Float64("lat", loc.Latitude).
+		Float64("long", loc.Longitude).
 		Msg("Sending location message")
 
-	sentResp, err := inst.Client.SendMessage(context.Background(), jid, msg)
+	sentResp, err := m.sendTracked(context.Background(), instanceID, inst.Client, jid, msg)
 	if err != nil {
-		return "", fmt.Errorf("failed to send location: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	return sentResp.ID, nil
@@ -1602,7 +2369,7 @@ func (m *Manager) SendLocationMessage(instanceID, to string, latitude, longitude
 func (m *Manager) SendPollMessage(instanceID, to, question string, options []string, selectableCount int) (string, error) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return "", fmt.Errorf("instance not found")
+		return "", ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1610,13 +2377,14 @@ func (m *Manager) SendPollMessage(instanceID, to, question string, options []str
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return "", fmt.Errorf("instance not connected")
+		return "", ErrNotConnected
 	}
 
 	// Clean phone number
-	to = strings.TrimPrefix(to, "+")
-	to = strings.ReplaceAll(to, " ", "")
-	to = strings.ReplaceAll(to, "-", "")
+	to, err := m.normalizeRecipient(to)
+	if err != nil {
+		return "", err
+	}
 
 	// Ensure it has @s.whatsapp.net suffix
 	if !strings.Contains(to, "@") {
@@ -1625,7 +2393,7 @@ func (m *Manager) SendPollMessage(instanceID, to, question string, options []str
 
 	jid, err := types.ParseJID(to)
 	if err != nil {
-		return "", fmt.Errorf("invalid JID: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	// Resolve user devices first to ensure LID is available
@@ -1645,19 +2413,29 @@ func (m *Manager) SendPollMessage(instanceID, to, question string, options []str
 		Int("options", len(options)).
 		Msg("Sending poll message")
 
-	sentResp, err := inst.Client.SendMessage(context.Background(), jid, pollMsg)
+	sentResp, err := m.sendTracked(context.Background(), instanceID, inst.Client, jid, pollMsg)
 	if err != nil {
-		return "", fmt.Errorf("failed to send poll: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
 	return sentResp.ID, nil
 }
 
-// EditMessage edits a previously sent message
-func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (string, error) {
+// EditMessage edits a previously sent message: its text, or with
+// EditMediaCaption the caption of media. The media type is taken from the
+// message store, mediaType is only used for messages missing from it.
+func (m *Manager) EditMessage(instanceID, chatID, messageID, newText, mode, mediaType string) (string, error) {
+	if stored, ok := m.findStoredMessage(instanceID, messageID); ok {
+		mediaType = stored.Type
+	}
+	content, err := editContent(mode, mediaType, newText)
+	if err != nil {
+		return "", err
+	}
+
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return "", fmt.Errorf("instance not found")
+		return "", ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1665,13 +2443,14 @@ func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (st
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return "", fmt.Errorf("instance not connected")
+		return "", ErrNotConnected
 	}
 
 	// Clean phone number / chat ID
-	chatID = strings.TrimPrefix(chatID, "+")
-	chatID = strings.ReplaceAll(chatID, " ", "")
-	chatID = strings.ReplaceAll(chatID, "-", "")
+	chatID, err = m.normalizeRecipient(chatID)
+	if err != nil {
+		return "", err
+	}
 
 	if !strings.Contains(chatID, "@") {
 		chatID = chatID + "@s.whatsapp.net"
@@ -1679,7 +2458,7 @@ func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (st
 
 	chatJID, err := types.ParseJID(chatID)
 	if err != nil {
-		return "", fmt.Errorf("invalid chat JID: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	// Use IsOnWhatsApp to resolve LID - this queries the server and populates PN→LID mapping
@@ -1689,6 +2468,8 @@ func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (st
 	} else if len(isOnWA) > 0 && isOnWA[0].IsIn {
 		// Use the resolved JID from the server
 		chatJID = isOnWA[0].JID
+		m.rememberCanonicalJID(chatJID)
+		m.learnLIDs(inst.Client, chatJID)
 		log.Info().Str("resolvedJID", chatJID.String()).Msg("Using resolved WhatsApp JID for edit")
 	}
 
@@ -1700,9 +2481,7 @@ func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (st
 		Str("newText", newText).
 		Msg("Building edit message")
 
-	editMsg := inst.Client.BuildEdit(chatJID, messageID, &waE2E.Message{
-		Conversation: proto.String(newText),
-	})
+	editMsg := inst.Client.BuildEdit(chatJID, messageID, content)
 
 	log.Info().
 		Str("instanceId", instanceID).
@@ -1718,17 +2497,20 @@ func (m *Manager) EditMessage(instanceID, chatID, messageID, newText string) (st
 			Str("chatJID", chatJID.String()).
 			Str("messageId", messageID).
 			Msg("Failed to send edited message")
-		return "", fmt.Errorf("failed to edit message: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
+	m.editStoredMessage(instanceID, chatJID.String(), messageID, newText, time.Now().Unix())
 	return sentResp.ID, nil
 }
 
-// ReactToMessage sends a reaction to a message
-func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string) error {
+// ReactToMessage sends a reaction to a message. participant is the sender of
+// the message in groups; without it the sender is taken from the message
+// store, and messages missing from it are assumed to be our own.
+func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction, participant string) error {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return fmt.Errorf("instance not found")
+		return ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1736,13 +2518,14 @@ func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string)
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return fmt.Errorf("instance not connected")
+		return ErrNotConnected
 	}
 
 	// Clean phone number / chat ID
-	chatID = strings.TrimPrefix(chatID, "+")
-	chatID = strings.ReplaceAll(chatID, " ", "")
-	chatID = strings.ReplaceAll(chatID, "-", "")
+	chatID, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
 
 	if !strings.Contains(chatID, "@") {
 		chatID = chatID + "@s.whatsapp.net"
@@ -1750,7 +2533,7 @@ func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string)
 
 	chatJID, err := types.ParseJID(chatID)
 	if err != nil {
-		return fmt.Errorf("invalid chat JID: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	// Use IsOnWhatsApp to resolve LID - this queries the server and populates PN→LID mapping
@@ -1760,6 +2543,8 @@ func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string)
 	} else if len(isOnWA) > 0 && isOnWA[0].IsIn {
 		// Use the resolved JID from the server
 		chatJID = isOnWA[0].JID
+		m.rememberCanonicalJID(chatJID)
+		m.learnLIDs(inst.Client, chatJID)
 		log.Info().Str("resolvedJID", chatJID.String()).Msg("Using resolved WhatsApp JID for reaction")
 	}
 
@@ -1770,8 +2555,30 @@ func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string)
 		Str("reaction", reaction).
 		Msg("Building and sending reaction")
 
+	// The key of a message from someone else names its sender
+	sender := types.EmptyJID
+	if participant != "" {
+		participant, err = m.normalizeRecipient(participant)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(participant, "@") {
+			participant = participant + "@s.whatsapp.net"
+		}
+		if sender, err = types.ParseJID(participant); err != nil {
+			return fmt.Errorf("%w: participant: %w", ErrInvalidJID, err)
+		}
+	} else if stored, ok := m.findStoredMessage(instanceID, messageID); ok && !stored.FromMe {
+		sender = chatJID
+		if stored.IsGroup {
+			if senderJID, err := types.ParseJID(stored.From); err == nil {
+				sender = senderJID
+			}
+		}
+	}
+
 	// Build reaction using whatsmeow's method
-	reactionMsg := inst.Client.BuildReaction(chatJID, types.EmptyJID, messageID, reaction)
+	reactionMsg := inst.Client.BuildReaction(chatJID, sender, messageID, reaction)
 	_, err = inst.Client.SendMessage(context.Background(), chatJID, reactionMsg)
 	if err != nil {
 		log.Error().
@@ -1781,17 +2588,20 @@ func (m *Manager) ReactToMessage(instanceID, chatID, messageID, reaction string)
 			Str("messageId", messageID).
 			Str("reaction", reaction).
 			Msg("Failed to send reaction")
-		return fmt.Errorf("failed to send reaction: %w", err)
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
+	m.recordOwnReaction(inst, chatJID.String(), messageID, reaction)
 	return nil
 }
 
-// DeleteMessage deletes a message (revoke)
-func (m *Manager) DeleteMessage(instanceID, chatID, messageID string, forEveryone bool) error {
+// DeleteMessage deletes a message. forEveryone revokes it for all participants;
+// otherwise it is only removed from our own devices and the message store.
+// fromMe is only used for messages missing from the store.
+func (m *Manager) DeleteMessage(instanceID, chatID, messageID string, forEveryone, fromMe bool) error {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return fmt.Errorf("instance not found")
+		return ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1799,13 +2609,14 @@ func (m *Manager) DeleteMessage(instanceID, chatID, messageID string, forEveryon
 	inst.mu.RUnlock()
 
 	if status != "connected" {
-		return fmt.Errorf("instance not connected")
+		return ErrNotConnected
 	}
 
 	// Clean phone number / chat ID
-	chatID = strings.TrimPrefix(chatID, "+")
-	chatID = strings.ReplaceAll(chatID, " ", "")
-	chatID = strings.ReplaceAll(chatID, "-", "")
+	chatID, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
 
 	if !strings.Contains(chatID, "@") {
 		chatID = chatID + "@s.whatsapp.net"
@@ -1813,7 +2624,7 @@ func (m *Manager) DeleteMessage(instanceID, chatID, messageID string, forEveryon
 
 	chatJID, err := types.ParseJID(chatID)
 	if err != nil {
-		return fmt.Errorf("invalid chat JID: %w", err)
+		return fmt.Errorf("%w: %w", ErrInvalidJID, err)
 	}
 
 	// Use IsOnWhatsApp to resolve LID - this queries the server and populates PN→LID mapping
@@ -1831,72 +2642,86 @@ func (m *Manager) DeleteMessage(instanceID, chatID, messageID string, forEveryon
 		Bool("forEveryone", forEveryone).
 		Msg("Deleting message")
 
-	if forEveryone {
-		// Revoke for everyone
-		revokeMsg := inst.Client.BuildRevoke(chatJID, types.EmptyJID, messageID)
-		_, err = inst.Client.SendMessage(context.Background(), chatJID, revokeMsg)
-	} else {
-		// Delete for me only - uses a different method
-		_, err = inst.Client.SendMessage(context.Background(), chatJID, inst.Client.BuildRevoke(chatJID, inst.Client.Store.ID.ToNonAD(), messageID))
+	if !forEveryone {
+		// Delete for me only: an app state patch for our devices, the recipient keeps the message
+		sender, timestamp := chatJID, time.Now().Unix()
+		if stored, ok := m.findStoredMessage(instanceID, messageID); ok {
+			fromMe, timestamp = stored.FromMe, stored.Timestamp
+			if stored.IsGroup && !stored.FromMe {
+				if senderJID, err := types.ParseJID(stored.From); err == nil {
+					sender = senderJID
+				}
+			}
+		}
+
+		patch := buildDeleteForMe(chatJID, sender, types.MessageID(messageID), fromMe, timestamp)
+		if err := inst.Client.SendAppState(context.Background(), patch); err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+		m.removeStoredMessage(instanceID, chatJID.String(), messageID)
+		return nil
 	}
 
-	if err != nil {
-		return fmt.Errorf("failed to delete message: %w", err)
+	// Revoke for everyone
+	revokeMsg := inst.Client.BuildRevoke(chatJID, types.EmptyJID, messageID)
+	if _, err = inst.Client.SendMessage(context.Background(), chatJID, revokeMsg); err != nil {
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
 	}
 
+	m.revokeStoredMessage(instanceID, chatJID.String(), messageID, time.Now().Unix())
 	return nil
 }
 
-// Subscribe to events for an instance
-func (m *Manager) Subscribe(instanceID string) chan Event {
-	m.eventSubsMu.Lock()
-	defer m.eventSubsMu.Unlock()
-
-	ch := make(chan Event, 100)
-	m.eventSubs[instanceID] = append(m.eventSubs[instanceID], ch)
-	return ch
-}
-
-// Unsubscribe from events
-func (m *Manager) Unsubscribe(instanceID string, ch chan Event) {
-	m.eventSubsMu.Lock()
-	defer m.eventSubsMu.Unlock()
-
-	subs := m.eventSubs[instanceID]
-	for i, sub := range subs {
-		if sub == ch {
-			m.eventSubs[instanceID] = append(subs[:i], subs[i+1:]...)
-			close(ch)
-			break
-		}
-	}
-}
-
 // publishEvent publishes event to all subscribers
 func (m *Manager) publishEvent(evt Event) {
 	if evt.Timestamp == 0 {
 		evt.Timestamp = time.Now().Unix()
 	}
 
-	m.eventSubsMu.RLock()
-	subs := m.eventSubs[evt.InstanceID]
-	m.eventSubsMu.RUnlock()
+	// Without the lock a later event could reach subscribers, or start the
+	// webhook cursor, ahead of an earlier one and a resume past it skip that one
+	lock := m.publishLock(evt.InstanceID)
+	lock.Lock()
 
-	for _, ch := range subs {
-		select {
-		case ch <- evt:
-		default:
-			// Channel full, skip
-		}
+	// Persist to journal first so subscribers see the sequence number
+	if seq, err := m.journal.Append(evt); err != nil {
+		log.Warn().Err(err).Str("instanceId", evt.InstanceID).Str("type", evt.Type).Msg("Failed to persist event")
+	} else {
+		evt.ID = seq
+	}
+
+	m.notifySubscribers(evt, evt.InstanceID, AllInstances)
+	m.enqueueWebhook(evt)
+	lock.Unlock()
+
+	if m.cluster != nil {
+		m.cluster.Publish(evt)
+	}
+}
+
+// publishLock returns the lock ordering the events of an instance
+func (m *Manager) publishLock(instanceID string) *sync.Mutex {
+	m.publishMu.Lock()
+	defer m.publishMu.Unlock()
+
+	lock, ok := m.publishLocks[instanceID]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.publishLocks[instanceID] = lock
 	}
+	return lock
 }
 
 // ChatInfo represents a chat/conversation
 type ChatInfo struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	IsGroup  bool   `json:"isGroup"`
-	PushName string `json:"pushName,omitempty"`
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	IsGroup      bool             `json:"isGroup"`
+	PushName     string           `json:"pushName,omitempty"`
+	LastMessage  *ChatLastMessage `json:"lastMessage,omitempty"`
+	LastActivity int64            `json:"lastActivity"`
+	UnreadCount  int              `json:"unreadCount"`
+	MarkedUnread bool             `json:"markedUnread,omitempty"`
 }
 
 // ContactInfo represents a contact
@@ -1907,12 +2732,31 @@ type ContactInfo struct {
 	Phone    string `json:"phone,omitempty"`
 }
 
-// GroupInfo represents a group
+// GroupInfo represents a group. Listings only fill in the basics; the
+// remaining fields come from GetGroupInfo.
 type GroupInfo struct {
-	JID          string   `json:"jid"`
-	Name         string   `json:"name"`
-	Description  string   `json:"description,omitempty"`
-	Participants []string `json:"participants,omitempty"`
+	JID          string             `json:"jid"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description,omitempty"`
+	Participants []GroupParticipant `json:"participants,omitempty"`
+
+	Owner                string `json:"owner,omitempty"`
+	CreatedAt            int64  `json:"createdAt,omitempty"`
+	ParticipantCount     int    `json:"participantCount,omitempty"`
+	IsAnnounce           bool   `json:"isAnnounce,omitempty"`           // Only admins can send messages
+	IsLocked             bool   `json:"isLocked,omitempty"`             // Only admins can edit group info
+	JoinApprovalRequired bool   `json:"joinApprovalRequired,omitempty"` // Admins approve join requests
+	MemberAddMode        string `json:"memberAddMode,omitempty"`        // Who may add members: admins or all
+	EphemeralTimer       uint32 `json:"ephemeralTimer"`                 // Disappearing messages timer in seconds (0 = off)
+	InviteCode           string `json:"inviteCode,omitempty"`           // Only available to admins
+}
+
+// GroupParticipant represents a member of a group
+type GroupParticipant struct {
+	JID          string `json:"jid"`
+	Phone        string `json:"phone,omitempty"`
+	IsAdmin      bool   `json:"isAdmin"`
+	IsSuperAdmin bool   `json:"isSuperAdmin"` // Group creator
 }
 
 // CheckNumberResult represents number check result
@@ -1920,13 +2764,14 @@ type CheckNumberResult struct {
 	Number       string `json:"number"`
 	IsOnWhatsApp bool   `json:"isOnWhatsApp"`
 	JID          string `json:"jid,omitempty"`
+	Error        string `json:"error,omitempty"` // Set in bulk results for numbers that couldn't be parsed
 }
 
 // GetContacts gets all contacts for an instance
 func (m *Manager) GetContacts(instanceID string) ([]ContactInfo, error) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return nil, fmt.Errorf("instance not found")
+		return nil, ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -1935,7 +2780,7 @@ func (m *Manager) GetContacts(instanceID string) ([]ContactInfo, error) {
 	inst.mu.RUnlock()
 
 	if status != "connected" || client == nil {
-		return nil, fmt.Errorf("instance not connected")
+		return nil, ErrNotConnected
 	}
 
 	contacts := make([]ContactInfo, 0)
@@ -1960,59 +2805,11 @@ func (m *Manager) GetContacts(instanceID string) ([]ContactInfo, error) {
 	return contacts, nil
 }
 
-// GetChats gets all chats/conversations for an instance
-func (m *Manager) GetChats(instanceID string) ([]ChatInfo, error) {
-	inst, ok := m.GetInstance(instanceID)
-	if !ok {
-		return nil, fmt.Errorf("instance not found")
-	}
-
-	inst.mu.RLock()
-	status := inst.Status
-	client := inst.Client
-	inst.mu.RUnlock()
-
-	if status != "connected" || client == nil {
-		return nil, fmt.Errorf("instance not connected")
-	}
-
-	chats := make([]ChatInfo, 0)
-
-	// Get contacts from the store - these represent recent chats
-	if client.Store != nil && client.Store.Contacts != nil {
-		allContacts, err := client.Store.Contacts.GetAllContacts(context.Background())
-		if err != nil {
-			log.Warn().Err(err).Msg("Failed to get contacts from store")
-		} else {
-			for jid, contact := range allContacts {
-				isGroup := jid.Server == "g.us"
-				name := contact.FullName
-				if name == "" {
-					name = contact.PushName
-				}
-				if name == "" {
-					name = jid.User
-				}
-
-				chats = append(chats, ChatInfo{
-					ID:       jid.String(),
-					Name:     name,
-					IsGroup:  isGroup,
-					PushName: contact.PushName,
-				})
-			}
-		}
-	}
-
-	log.Info().Int("count", len(chats)).Str("instanceId", instanceID).Msg("Got chats")
-	return chats, nil
-}
-
 // GetGroups gets all groups for an instance
 func (m *Manager) GetGroups(instanceID string) ([]GroupInfo, error) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return nil, fmt.Errorf("instance not found")
+		return nil, ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -2021,7 +2818,7 @@ func (m *Manager) GetGroups(instanceID string) ([]GroupInfo, error) {
 	inst.mu.RUnlock()
 
 	if status != "connected" || client == nil {
-		return nil, fmt.Errorf("instance not connected")
+		return nil, ErrNotConnected
 	}
 
 	groups := make([]GroupInfo, 0)
@@ -2044,47 +2841,97 @@ func (m *Manager) GetGroups(instanceID string) ([]GroupInfo, error) {
 }
 
 // CheckNumber checks if a number is on WhatsApp
-func (m *Manager) CheckNumber(instanceID, number string) (*CheckNumberResult, error) {
-	inst, ok := m.GetInstance(instanceID)
-	if !ok {
-		return nil, fmt.Errorf("instance not found")
+func (m *Manager) CheckNumber(ctx context.Context, instanceID, number string) (*CheckNumberResult, error) {
+	results, err := m.CheckNumbers(ctx, instanceID, []string{number})
+	if err != nil {
+		return nil, err
 	}
+	if results[0].Error != "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPhoneNumber, number)
+	}
+	return &results[0], nil
+}
 
-	inst.mu.RLock()
-	status := inst.Status
-	client := inst.Client
-	inst.mu.RUnlock()
+// Most numbers checked by one CheckNumbers call
+const maxCheckNumbers = 500
 
-	if status != "connected" || client == nil {
-		return nil, fmt.Errorf("instance not connected")
+// CheckNumbers checks several numbers with a single IsOnWhatsApp query.
+// Results follow the order of numbers.
+func (m *Manager) CheckNumbers(ctx context.Context, instanceID string, numbers []string) ([]CheckNumberResult, error) {
+	ctx, span := tracing.Start(ctx, "Manager.CheckNumbers", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%w: at least 1 number is required", ErrInvalidInput)
+	}
+	if len(numbers) > maxCheckNumbers {
+		return nil, fmt.Errorf("%w: at most %d numbers per request", ErrInvalidInput, maxCheckNumbers)
 	}
 
-	// Clean phone number
-	number = strings.TrimPrefix(number, "+")
-	number = strings.ReplaceAll(number, " ", "")
-	number = strings.ReplaceAll(number, "-", "")
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Normalize phone numbers; unparseable ones are reported without a query
+	results := make([]CheckNumberResult, len(numbers))
+	query := make([]string, 0, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	for i, number := range numbers {
+		normalized, err := m.normalizePhone(number)
+		if err != nil {
+			results[i] = CheckNumberResult{Number: number, Error: err.Error()}
+			continue
+		}
+		results[i] = CheckNumberResult{Number: normalized}
+		if !seen[normalized] {
+			seen[normalized] = true
+			query = append(query, normalized)
+		}
+	}
+	if len(query) == 0 {
+		return results, nil
+	}
 
-	result, err := client.IsOnWhatsApp(context.Background(), []string{number})
+	found, err := tracedIsOnWhatsApp(ctx, client, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check number: %w", err)
 	}
 
-	if len(result) == 0 {
-		return &CheckNumberResult{
-			Number:       number,
-			IsOnWhatsApp: false,
-		}, nil
+	byQuery := make(map[string]types.IsOnWhatsAppResponse, len(found))
+	registered := make([]types.JID, 0, len(found))
+	for _, r := range found {
+		byQuery[strings.TrimPrefix(r.Query, "+")] = r
+		if r.IsIn {
+			m.rememberCanonicalJID(r.JID)
+			registered = append(registered, r.JID)
+		}
+	}
+	m.learnLIDs(client, registered...)
+
+	// A single answer belongs to the single query, however it was echoed
+	if len(query) == 1 && len(found) == 1 {
+		byQuery[query[0]] = found[0]
 	}
 
-	return &CheckNumberResult{
-		Number:       number,
-		IsOnWhatsApp: result[0].IsIn,
-		JID:          result[0].JID.String(),
-	}, nil
+	for i := range results {
+		if r, ok := byQuery[results[i].Number]; ok && results[i].Error == "" {
+			results[i].IsOnWhatsApp = r.IsIn
+			results[i].JID = r.JID.String()
+		}
+	}
+	return results, nil
 }
 
-// storeMessage stores a message in memory for later retrieval
-func (m *Manager) storeMessage(instanceID, chatID string, msg MessageData) {
+// Maximum number of messages kept in memory per chat
+const maxStoredMessagesPerChat = 500
+
+// storeMessage stores a message in memory for later retrieval, in timestamp
+// order. A message already stored under the same ID in the chat (seen both in
+// history sync and live) is updated instead; false is returned then.
+func (m *Manager) storeMessage(instanceID, chatID string, msg MessageData) bool {
+	chatID = m.canonicalChatID(chatID)
+
 	m.messagesMu.Lock()
 	defer m.messagesMu.Unlock()
 
@@ -2092,17 +2939,66 @@ func (m *Manager) storeMessage(instanceID, chatID string, msg MessageData) {
 		m.messages[instanceID] = make(map[string][]MessageData)
 	}
 
-	// Limit to last 500 messages per chat to avoid memory issues
 	msgs := m.messages[instanceID][chatID]
-	msgs = append(msgs, msg)
-	if len(msgs) > 500 {
-		msgs = msgs[len(msgs)-500:]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID == msg.ID {
+			msgs[i] = mergeStoredMessage(msgs[i], msg)
+			return false
+		}
+	}
+
+	// History arrives after live messages, so insert by timestamp
+	pos := len(msgs)
+	for pos > 0 && msgs[pos-1].Timestamp > msg.Timestamp {
+		pos--
+	}
+	msgs = slices.Insert(msgs, pos, msg)
+
+	// Limit messages per chat to avoid memory issues
+	if len(msgs) > maxStoredMessagesPerChat {
+		msgs = msgs[len(msgs)-maxStoredMessagesPerChat:]
 	}
 	m.messages[instanceID][chatID] = msgs
+	return true
+}
+
+// mergeStoredMessage combines two copies of the same message: the new copy
+// wins, but media already downloaded, the star and sender details of the
+// stored copy are kept
+func mergeStoredMessage(stored, msg MessageData) MessageData {
+	if stored.Revoked {
+		return stored // Another copy must not bring back deleted content
+	}
+	if msg.MediaBase64 == "" && stored.MediaBase64 != "" {
+		msg.MediaBase64 = stored.MediaBase64
+		msg.MediaPending = false
+	}
+	if msg.media == nil {
+		msg.media = stored.media
+	}
+	if msg.Thumbnail == nil {
+		msg.Thumbnail = stored.Thumbnail
+	}
+	if msg.PushName == "" {
+		msg.PushName = stored.PushName
+	}
+	if msg.ResolvedPhone == "" {
+		msg.ResolvedPhone = stored.ResolvedPhone
+	}
+	if msg.Reactions == nil {
+		msg.Reactions = stored.Reactions
+	}
+	if msg.Tags == nil {
+		msg.Tags = stored.Tags
+	}
+	msg.Starred = msg.Starred || stored.Starred
+	return msg
 }
 
 // GetChatMessages returns stored messages for a specific chat
 func (m *Manager) GetChatMessages(instanceID, chatID string, limit int) ([]MessageData, error) {
+	chatID = m.canonicalChatID(chatID)
+
 	m.messagesMu.RLock()
 	defer m.messagesMu.RUnlock()
 
@@ -2120,7 +3016,55 @@ func (m *Manager) GetChatMessages(instanceID, chatID string, limit int) ([]Messa
 		msgs = msgs[len(msgs)-limit:]
 	}
 
-	return msgs, nil
+	// A copy, as the stored slice changes once the lock is released
+	return slices.Clone(msgs), nil
+}
+
+// findStoredMessage looks up a stored message by ID across all chats of an instance
+func (m *Manager) findStoredMessage(instanceID, messageID string) (MessageData, bool) {
+	m.messagesMu.RLock()
+	defer m.messagesMu.RUnlock()
+
+	for _, msgs := range m.messages[instanceID] {
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if msgs[i].ID == messageID {
+				return msgs[i], true
+			}
+		}
+	}
+
+	return MessageData{}, false
+}
+
+// MessageLookup is a message looked up by ID: its stored content and, for
+// messages sent by the instance, its delivery timeline
+type MessageLookup struct {
+	Message *MessageData   `json:"message,omitempty"`
+	Status  *MessageStatus `json:"status,omitempty"`
+}
+
+// GetMessage looks up a message by ID in the message store and the delivery
+// journal. Either part may be missing, but not both.
+func (m *Manager) GetMessage(ctx context.Context, instanceID, messageID string) (*MessageLookup, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	var lookup MessageLookup
+	if msg, ok := m.findStoredMessage(instanceID, messageID); ok {
+		lookup.Message = &msg
+	}
+
+	status, err := m.messageStatuses.MessageStatus(ctx, instanceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	lookup.Status = status
+
+	if lookup.Message == nil && lookup.Status == nil {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	return &lookup, nil
 }
 
 // GetAllStoredChats returns list of chats that have stored messages
@@ -2148,6 +3092,7 @@ func (m *Manager) SetRejectCalls(instanceID string, value bool) {
 	inst.mu.Lock()
 	inst.RejectCalls = value
 	inst.mu.Unlock()
+	m.saveSettings(inst)
 	log.Info().Str("instanceId", instanceID).Bool("rejectCalls", value).Msg("Updated reject calls setting")
 }
 
@@ -2160,6 +3105,7 @@ func (m *Manager) SetAlwaysOnline(instanceID string, value bool) {
 	inst.mu.Lock()
 	inst.AlwaysOnline = value
 	inst.mu.Unlock()
+	m.saveSettings(inst)
 	log.Info().Str("instanceId", instanceID).Bool("alwaysOnline", value).Msg("Updated always online setting")
 
 	// If enabled and connected, send presence
@@ -2177,6 +3123,7 @@ func (m *Manager) SetIgnoreGroups(instanceID string, value bool) {
 	inst.mu.Lock()
 	inst.IgnoreGroups = value
 	inst.mu.Unlock()
+	m.saveSettings(inst)
 	log.Info().Str("instanceId", instanceID).Bool("ignoreGroups", value).Msg("Updated ignore groups setting")
 }
 
@@ -2189,9 +3136,23 @@ func (m *Manager) SetReadMessages(instanceID string, value bool) {
 	inst.mu.Lock()
 	inst.ReadMessages = value
 	inst.mu.Unlock()
+	m.saveSettings(inst)
 	log.Info().Str("instanceId", instanceID).Bool("readMessages", value).Msg("Updated read messages setting")
 }
 
+// SetSendReadReceipts sets whether marking messages read sends blue ticks to the sender
+func (m *Manager) SetSendReadReceipts(instanceID string, value bool) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return
+	}
+	inst.mu.Lock()
+	inst.HideReadReceipts = !value
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("sendReadReceipts", value).Msg("Updated send read receipts setting")
+}
+
 // SetSkipVideoDownload sets the skip video download setting for an instance
 func (m *Manager) SetSkipVideoDownload(instanceID string, value bool) {
 	inst, ok := m.GetInstance(instanceID)
@@ -2201,23 +3162,56 @@ func (m *Manager) SetSkipVideoDownload(instanceID string, value bool) {
 	inst.mu.Lock()
 	inst.SkipVideoDownload = value
 	inst.mu.Unlock()
+	m.saveSettings(inst)
 	log.Info().Str("instanceId", instanceID).Bool("skipVideoDownload", value).Msg("Updated skip video download setting")
 }
 
-// GetSettings returns the current settings for an instance
-func (m *Manager) GetSettings(instanceID string) map[string]bool {
+// SetSkipViewOnceMedia sets whether the media of incoming view-once messages is discarded
+func (m *Manager) SetSkipViewOnceMedia(instanceID string, value bool) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return
+	}
+	inst.mu.Lock()
+	inst.SkipViewOnceMedia = value
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("skipViewOnceMedia", value).Msg("Updated skip view-once media setting")
+}
+
+// GetSettings returns the current settings for an instance, with secrets redacted
+func (m *Manager) GetSettings(instanceID string) map[string]interface{} {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return map[string]bool{}
+		return map[string]interface{}{}
 	}
 	inst.mu.RLock()
 	defer inst.mu.RUnlock()
-	return map[string]bool{
-		"rejectCalls":       inst.RejectCalls,
-		"alwaysOnline":      inst.AlwaysOnline,
-		"ignoreGroups":      inst.IgnoreGroups,
-		"readMessages":      inst.ReadMessages,
-		"skipVideoDownload": inst.SkipVideoDownload,
+	identity, _ := inst.Identity.normalize()
+	return map[string]interface{}{
+		"rejectCalls":                 inst.RejectCalls,
+		"alwaysOnline":                inst.AlwaysOnline,
+		"ignoreGroups":                inst.IgnoreGroups,
+		"readMessages":                inst.ReadMessages,
+		"sendReadReceipts":            !inst.HideReadReceipts,
+		"skipVideoDownload":           inst.SkipVideoDownload,
+		"skipViewOnceMedia":           inst.SkipViewOnceMedia,
+		"mediaPolicy":                 inst.MediaPolicy,
+		"device":                      identity,
+		"webhookUrl":                  inst.WebhookURL,
+		"webhookSecret":               redactSecret(inst.WebhookSecret),
+		"webhookEvents":               inst.WebhookEvents,
+		"webhookFormat":               inst.WebhookFormat,
+		"filterRules":                 redactedFilterRules(inst.FilterRules),
+		"awayMessage":                 inst.AwayMessage,
+		"aiAgent":                     inst.AIAgent.redacted(),
+		"typebot":                     inst.Typebot.redacted(),
+		"dialogflow":                  inst.Dialogflow.redacted(),
+		"callFollowUpMessage":         inst.CallFollowUpMessage,
+		"callFollowUpCooldownMinutes": int(inst.CallFollowUpCooldown.Minutes()),
+		"ownerNumber":                 inst.OwnerNumber,
+		"paused":                      inst.Paused,
+		"clientLogLevel":              inst.ClientLogLevel,
 	}
 }
 
@@ -2225,7 +3219,7 @@ func (m *Manager) GetSettings(instanceID string) map[string]bool {
 func (m *Manager) SetProxy(instanceID string, host, port, username, password, protocol string) error {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return fmt.Errorf("instance not found")
+		return ErrInstanceNotFound
 	}
 
 	inst.mu.Lock()
@@ -2238,6 +3232,8 @@ func (m *Manager) SetProxy(instanceID string, host, port, username, password, pr
 	status := inst.Status
 	inst.mu.Unlock()
 
+	m.saveProxy(instanceID, ProxyConfig{Host: host, Port: port, Username: username, Password: password, Protocol: protocol})
+
 	// Build proxy URL
 	proxyURL := m.buildProxyURL(host, port, username, password, protocol)
 
@@ -2293,28 +3289,12 @@ func (m *Manager) buildProxyURL(host, port, username, password, protocol string)
 func (m *Manager) CheckProxyIP(instanceID string) (string, error) {
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return "", fmt.Errorf("instance not found")
+		return "", ErrInstanceNotFound
 	}
 
-	// Get proxy settings
-	inst.mu.RLock()
-	host := inst.ProxyHost
-	port := inst.ProxyPort
-	username := inst.ProxyUsername
-	password := inst.ProxyPassword
-	protocol := inst.ProxyProtocol
-	inst.mu.RUnlock()
-
-	transport := &http.Transport{}
-
-	// Configure proxy if set
-	if host != "" && port != "" {
-		proxyURLStr := m.buildProxyURL(host, port, username, password, protocol)
-		parsedURL, err := url.Parse(proxyURLStr)
-		if err != nil {
-			return "", fmt.Errorf("invalid proxy URL: %w", err)
-		}
-		transport.Proxy = http.ProxyURL(parsedURL)
+	transport, err := m.proxyTransport(inst)
+	if err != nil {
+		return "", err
 	}
 
 	client := &http.Client{
@@ -2366,10 +3346,13 @@ type DownloadMediaRequest struct {
 }
 
 // DownloadMedia downloads media from a WhatsApp message
-func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaRequest) ([]byte, string, error) {
+func (m *Manager) DownloadMedia(ctx context.Context, instanceID string, mediaInfo DownloadMediaRequest) ([]byte, string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.DownloadMedia", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
 	inst, ok := m.GetInstance(instanceID)
 	if !ok {
-		return nil, "", fmt.Errorf("instance not found")
+		return nil, "", ErrInstanceNotFound
 	}
 
 	inst.mu.RLock()
@@ -2378,7 +3361,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 	inst.mu.RUnlock()
 
 	if status != "connected" || client == nil {
-		return nil, "", fmt.Errorf("instance not connected")
+		return nil, "", ErrNotConnected
 	}
 
 	// Determine media type for whatsmeow
@@ -2421,7 +3404,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 
 	switch mediaType {
 	case whatsmeow.MediaImage:
-		data, err = client.Download(context.Background(), &waE2E.ImageMessage{
+		data, err = tracedDownload(ctx, client, &waE2E.ImageMessage{
 			URL:           proto.String(mediaInfo.URL),
 			DirectPath:    proto.String(mediaInfo.DirectPath),
 			MediaKey:      mediaInfo.MediaKey,
@@ -2431,7 +3414,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 			Mimetype:      proto.String(mediaInfo.Mimetype),
 		})
 	case whatsmeow.MediaVideo:
-		data, err = client.Download(context.Background(), &waE2E.VideoMessage{
+		data, err = tracedDownload(ctx, client, &waE2E.VideoMessage{
 			URL:           proto.String(mediaInfo.URL),
 			DirectPath:    proto.String(mediaInfo.DirectPath),
 			MediaKey:      mediaInfo.MediaKey,
@@ -2441,7 +3424,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 			Mimetype:      proto.String(mediaInfo.Mimetype),
 		})
 	case whatsmeow.MediaAudio:
-		data, err = client.Download(context.Background(), &waE2E.AudioMessage{
+		data, err = tracedDownload(ctx, client, &waE2E.AudioMessage{
 			URL:           proto.String(mediaInfo.URL),
 			DirectPath:    proto.String(mediaInfo.DirectPath),
 			MediaKey:      mediaInfo.MediaKey,
@@ -2451,7 +3434,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 			Mimetype:      proto.String(mediaInfo.Mimetype),
 		})
 	default: // MediaDocument
-		data, err = client.Download(context.Background(), &waE2E.DocumentMessage{
+		data, err = tracedDownload(ctx, client, &waE2E.DocumentMessage{
 			URL:           proto.String(mediaInfo.URL),
 			DirectPath:    proto.String(mediaInfo.DirectPath),
 			MediaKey:      mediaInfo.MediaKey,
@@ -2464,7 +3447,7 @@ func (m *Manager) DownloadMedia(instanceID string, mediaInfo DownloadMediaReques
 
 	if err != nil {
 		log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to download media")
-		return nil, "", fmt.Errorf("failed to download media: %w", err)
+		return nil, "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
 	}
 
 	log.Info().
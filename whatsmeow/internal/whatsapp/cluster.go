@@ -0,0 +1,48 @@
+package whatsapp
+
+import "github.com/rs/zerolog/log"
+
+// Cluster coordinates instances across several API nodes. Each instance is
+// owned by the node that created or restored it, and events published on one
+// node are relayed to the subscribers of the others.
+type Cluster interface {
+	// Claim records this node as the owner of an instance. It fails with
+	// ErrClusterUnavailable when Redis can't be reached or another node owns it.
+	Claim(instanceID string) error
+	// Release gives up ownership of an instance
+	Release(instanceID string)
+	// Publish relays an event to the other nodes
+	Publish(evt Event)
+}
+
+// SetCluster enables cluster mode and claims the instances already loaded
+func (m *Manager) SetCluster(c Cluster) {
+	m.mu.Lock()
+	m.cluster = c
+	ids := make([]string, 0, len(m.instances))
+	for id := range m.instances {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		if err := c.Claim(id); err != nil {
+			log.Error().Err(err).Str("instanceId", id).Msg("Failed to claim instance")
+		}
+	}
+}
+
+// DeliverRemoteEvent hands an event published by another node to the local
+// firehose subscribers. Per-instance streams are proxied to the owning node,
+// which also journaled the event and queued its webhooks.
+func (m *Manager) DeliverRemoteEvent(evt Event) {
+	m.notifySubscribers(evt, AllInstances)
+}
+
+// claimInstance records ownership of an instance when clustering is enabled
+func (m *Manager) claimInstance(instanceID string) error {
+	if m.cluster == nil {
+		return nil
+	}
+	return m.cluster.Claim(instanceID)
+}
@@ -0,0 +1,189 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Prefix of owner commands sent over WhatsApp
+const ownerCommandPrefix = "!"
+
+const ownerCommandHelp = `Comandos disponíveis:
+!status - estado da instância
+!stats - estatísticas
+!pause - pausa o envio de eventos de mensagens
+!resume - retoma o envio de eventos
+!help - esta ajuda`
+
+// SetOwnerNumber sets the number allowed to send owner commands ("" disables them)
+func (m *Manager) SetOwnerNumber(instanceID, number string) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+
+	if number = strings.TrimSpace(number); number != "" {
+		normalized, err := m.normalizePhone(number)
+		if err != nil {
+			return err
+		}
+		number = normalized
+	}
+
+	inst.mu.Lock()
+	inst.OwnerNumber = number
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", number != "").Msg("Updated owner command number")
+	return nil
+}
+
+// SetPaused pauses or resumes forwarding of message events
+func (m *Manager) SetPaused(instanceID string, paused bool) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return
+	}
+	inst.mu.Lock()
+	inst.Paused = paused
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Bool("paused", paused).Msg("Updated paused setting")
+}
+
+// sameNumber compares phone numbers, treating Brazilian 9-digit variants as equal
+func sameNumber(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return a == b || brazilianVariant(a) == b
+}
+
+// isOwnerMessage reports whether a message was sent by the configured owner.
+// Our own messages only count in the self chat, so outgoing replies to
+// customers that happen to start with the prefix aren't treated as commands.
+func isOwnerMessage(owner string, msg *events.Message) bool {
+	if owner == "" || msg.Info.IsGroup {
+		return false
+	}
+	if msg.Info.IsFromMe {
+		return sameNumber(owner, msg.Info.Chat.User) && sameNumber(owner, msg.Info.Sender.User)
+	}
+	if sameNumber(owner, msg.Info.Sender.User) {
+		return true
+	}
+	alt := msg.Info.SenderAlt
+	return alt.Server == types.DefaultUserServer && sameNumber(owner, alt.User)
+}
+
+// handleOwnerCommand interprets owner commands and answers them in the chat.
+// Returns true if the message was a command and shouldn't be processed further.
+func (m *Manager) handleOwnerCommand(inst *Instance, msg *events.Message) bool {
+	inst.mu.RLock()
+	owner := inst.OwnerNumber
+	inst.mu.RUnlock()
+
+	if !isOwnerMessage(owner, msg) {
+		return false
+	}
+
+	text := msg.Message.GetConversation()
+	if text == "" {
+		text = msg.Message.GetExtendedTextMessage().GetText()
+	}
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, ownerCommandPrefix) {
+		return false
+	}
+
+	command := strings.ToLower(strings.Fields(strings.TrimPrefix(text, ownerCommandPrefix) + " ")[0])
+	var reply string
+	switch command {
+	case "status":
+		reply = m.ownerStatusReply(inst)
+	case "stats":
+		reply = m.ownerStatsReply(inst)
+	case "pause":
+		m.SetPaused(inst.ID, true)
+		reply = "⏸️ Eventos de mensagens pausados. Envie !resume para retomar."
+	case "resume":
+		m.SetPaused(inst.ID, false)
+		reply = "▶️ Eventos de mensagens retomados."
+	case "help":
+		reply = ownerCommandHelp
+	default:
+		reply = fmt.Sprintf("Comando desconhecido: %s\n\n%s", command, ownerCommandHelp)
+	}
+
+	log.Info().Str("instanceId", inst.ID).Str("command", command).Msg("Owner command received")
+
+	go func() {
+		_, err := inst.Client.SendMessage(context.Background(), msg.Info.Chat.ToNonAD(), &waE2E.Message{
+			Conversation: proto.String(reply),
+		})
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("command", command).Msg("Failed to answer owner command")
+		}
+	}()
+
+	m.publishEvent(Event{
+		Type:       "owner_command",
+		InstanceID: inst.ID,
+		Data: map[string]string{
+			"command": command,
+			"from":    msg.Info.Sender.String(),
+		},
+	})
+	return true
+}
+
+func (m *Manager) ownerStatusReply(inst *Instance) string {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+
+	paused := "não"
+	if inst.Paused {
+		paused = "sim"
+	}
+	uptime := "-"
+	if !inst.connectedAt.IsZero() {
+		uptime = time.Since(inst.connectedAt).Truncate(time.Second).String()
+	}
+	return fmt.Sprintf("📱 Instância: %s\nStatus: %s\nNúmero: %s\nConectado há: %s\nPausado: %s",
+		inst.ID, inst.Status, inst.WANumber, uptime, paused)
+}
+
+func (m *Manager) ownerStatsReply(inst *Instance) string {
+	m.messagesMu.RLock()
+	chats := len(m.messages[inst.ID])
+	messages := 0
+	for _, msgs := range m.messages[inst.ID] {
+		messages += len(msgs)
+	}
+	m.messagesMu.RUnlock()
+
+	m.calls.mu.Lock()
+	calls := len(m.calls.entries[inst.ID])
+	missed := 0
+	for _, entry := range m.calls.entries[inst.ID] {
+		if entry.Outcome == CallMissed || entry.Outcome == CallRejected {
+			missed++
+		}
+	}
+	m.calls.mu.Unlock()
+
+	m.eventSubsMu.RLock()
+	subscribers := len(m.eventSubs[inst.ID])
+	m.eventSubsMu.RUnlock()
+
+	return fmt.Sprintf("📊 Estatísticas\nChats: %d\nMensagens armazenadas: %d\nChamadas: %d (perdidas/rejeitadas: %d)\nConsumidores de eventos: %d",
+		chats, messages, calls, missed, subscribers)
+}
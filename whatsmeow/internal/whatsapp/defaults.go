@@ -0,0 +1,244 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ProxyConfig represents a proxy server
+type ProxyConfig struct {
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Protocol string `json:"protocol,omitempty"` // http, https, socks4, socks5
+}
+
+// InstanceDefaults holds settings applied automatically to newly created instances
+type InstanceDefaults struct {
+	RejectCalls                 bool   `json:"rejectCalls"`
+	AlwaysOnline                bool   `json:"alwaysOnline"`
+	IgnoreGroups                bool   `json:"ignoreGroups"`
+	ReadMessages                bool   `json:"readMessages"`
+	SendReadReceipts            *bool  `json:"sendReadReceipts,omitempty"` // nil = true
+	SkipVideoDownload           bool   `json:"skipVideoDownload"`
+	SkipViewOnceMedia           bool   `json:"skipViewOnceMedia"`
+	CallFollowUpMessage         string `json:"callFollowUpMessage,omitempty"`
+	CallFollowUpCooldownMinutes int    `json:"callFollowUpCooldownMinutes,omitempty"`
+
+	// How new instances download incoming media
+	MediaPolicy MediaPolicy `json:"mediaPolicy"`
+
+	// Identity new instances pair with (deviceName, platformType, os)
+	Device DeviceIdentity `json:"device"`
+
+	// Webhook of new instances; {instanceId} is replaced with the instance ID
+	WebhookURL    string   `json:"webhookUrl,omitempty"`
+	WebhookEvents []string `json:"webhookEvents,omitempty"`
+	WebhookFormat string   `json:"webhookFormat,omitempty"`
+
+	// Filter rules new instances start with
+	FilterRules []FilterRule `json:"filterRules,omitempty"`
+
+	// Away message new instances start with
+	AwayMessage AwayMessage `json:"awayMessage"`
+
+	// AI agent new instances start with
+	AIAgent AIAgent `json:"aiAgent"`
+
+	// Typebot flow new instances run
+	Typebot TypebotConfig `json:"typebot"`
+
+	// Dialogflow agent new instances connect to
+	Dialogflow DialogflowConfig `json:"dialogflow"`
+
+	// New instances get the least used proxy of the pool
+	ProxyPool []ProxyConfig `json:"proxyPool,omitempty"`
+}
+
+// loadDefaults loads instance defaults from file
+func (m *Manager) loadDefaults() {
+	data, err := os.ReadFile(m.defaultsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load instance defaults")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.defaults); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal instance defaults")
+	}
+}
+
+// GetDefaults returns the settings applied to new instances, with secrets redacted
+func (m *Manager) GetDefaults() InstanceDefaults {
+	return m.currentDefaults().redacted()
+}
+
+// currentDefaults returns a copy of the settings applied to new instances
+func (m *Manager) currentDefaults() InstanceDefaults {
+	m.defaultsMu.RLock()
+	defer m.defaultsMu.RUnlock()
+
+	defaults := m.defaults
+	defaults.ProxyPool = append([]ProxyConfig(nil), m.defaults.ProxyPool...)
+	return defaults
+}
+
+// SetDefaults replaces and persists the settings applied to new instances.
+// Secrets sent back as RedactedSecret keep their stored value.
+func (m *Manager) SetDefaults(defaults InstanceDefaults) error {
+	defaults.keepSecrets(m.currentDefaults())
+	for i, p := range defaults.ProxyPool {
+		if p.Host == "" || p.Port == "" {
+			return fmt.Errorf("%w: proxyPool[%d] requires host and port", ErrInvalidInput, i)
+		}
+	}
+	if err := defaults.MediaPolicy.validate(); err != nil {
+		return err
+	}
+	if _, err := defaults.Device.normalize(); err != nil {
+		return err
+	}
+	if defaults.WebhookURL != "" {
+		if err := validateWebhookURL(defaults.WebhookURL); err != nil {
+			return err
+		}
+	}
+	if err := validateWebhookFormat(defaults.WebhookFormat); err != nil {
+		return err
+	}
+	if _, err := m.compileFilterRules(defaults.FilterRules); err != nil {
+		return err
+	}
+	if _, err := m.compileAwayMessage(defaults.AwayMessage); err != nil {
+		return err
+	}
+	if err := defaults.AIAgent.validate(); err != nil {
+		return err
+	}
+	if err := defaults.Typebot.validate(); err != nil {
+		return err
+	}
+	if err := defaults.Dialogflow.validate(); err != nil {
+		return err
+	}
+	if defaults.CallFollowUpCooldownMinutes < 0 {
+		return fmt.Errorf("%w: callFollowUpCooldownMinutes must be >= 0", ErrInvalidInput)
+	}
+
+	data, err := json.MarshalIndent(defaults, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal instance defaults: %w", err)
+	}
+
+	m.defaultsMu.Lock()
+	defer m.defaultsMu.Unlock()
+
+	if err := os.WriteFile(m.defaultsFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to save instance defaults: %w", err)
+	}
+	m.defaults = defaults
+
+	log.Info().Int("proxyPool", len(defaults.ProxyPool)).Msg("Updated instance defaults")
+	return nil
+}
+
+// applyDefaults applies the configured defaults to a newly created instance,
+// unless settings were saved for its ID. Must be called with m.mu held, before
+// the instance is connected.
+func (m *Manager) applyDefaults(inst *Instance) {
+	defaults := m.currentDefaults()
+	if !m.applyStoredSettings(inst) {
+		m.applyDefaultSettings(inst, defaults)
+		m.saveSettings(inst)
+	}
+
+	// A proxy saved for this instance ID wins over the pool
+	if m.applyStoredProxy(inst) || len(defaults.ProxyPool) == 0 {
+		return
+	}
+
+	proxy := m.leastUsedProxy(defaults.ProxyPool)
+
+	inst.mu.Lock()
+	inst.ProxyHost = proxy.Host
+	inst.ProxyPort = proxy.Port
+	inst.ProxyUsername = proxy.Username
+	inst.ProxyPassword = proxy.Password
+	inst.ProxyProtocol = proxy.Protocol
+	inst.mu.Unlock()
+	m.saveProxy(inst.ID, proxy)
+
+	proxyURL := m.buildProxyURL(proxy.Host, proxy.Port, proxy.Username, proxy.Password, proxy.Protocol)
+	if err := inst.Client.SetProxyAddress(proxyURL); err != nil {
+		log.Error().Err(err).Str("instanceId", inst.ID).Msg("Failed to apply default proxy")
+		return
+	}
+
+	log.Info().Str("instanceId", inst.ID).Str("proxy", proxy.Host+":"+proxy.Port).Msg("Assigned proxy from default pool")
+}
+
+// applyDefaultSettings gives a new instance the default settings
+func (m *Manager) applyDefaultSettings(inst *Instance, defaults InstanceDefaults) {
+	inst.mu.Lock()
+	inst.RejectCalls = defaults.RejectCalls
+	inst.AlwaysOnline = defaults.AlwaysOnline
+	inst.IgnoreGroups = defaults.IgnoreGroups
+	inst.ReadMessages = defaults.ReadMessages
+	inst.HideReadReceipts = defaults.SendReadReceipts != nil && !*defaults.SendReadReceipts
+	inst.SkipVideoDownload = defaults.SkipVideoDownload
+	inst.SkipViewOnceMedia = defaults.SkipViewOnceMedia
+	inst.MediaPolicy = defaults.MediaPolicy
+	if defaults.MediaPolicy.isZero() {
+		inst.MediaPolicy = m.fallbackMediaPolicy
+	}
+	inst.Identity = defaults.Device
+	if defaults.WebhookURL != "" {
+		inst.WebhookURL = strings.ReplaceAll(defaults.WebhookURL, "{instanceId}", inst.ID)
+		inst.WebhookSecret = newWebhookSecret()
+	}
+	inst.WebhookEvents = defaults.WebhookEvents
+	inst.WebhookFormat = defaults.WebhookFormat
+	if rules, err := m.compileFilterRules(defaults.FilterRules); err == nil {
+		inst.FilterRules = rules
+	} else {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Ignoring invalid default filter rules")
+	}
+	if away, err := m.compileAwayMessage(defaults.AwayMessage); err == nil {
+		inst.AwayMessage = away
+	} else {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Ignoring invalid default away message")
+	}
+	inst.AIAgent = defaults.AIAgent
+	inst.Typebot = defaults.Typebot
+	inst.Dialogflow = defaults.Dialogflow
+	inst.CallFollowUpMessage = defaults.CallFollowUpMessage
+	inst.CallFollowUpCooldown = time.Duration(defaults.CallFollowUpCooldownMinutes) * time.Minute
+	inst.mu.Unlock()
+}
+
+// leastUsedProxy picks the pool entry used by the fewest instances.
+// Must be called with m.mu held.
+func (m *Manager) leastUsedProxy(pool []ProxyConfig) ProxyConfig {
+	usage := make(map[string]int)
+	for _, inst := range m.instances {
+		inst.mu.RLock()
+		usage[inst.ProxyHost+":"+inst.ProxyPort]++
+		inst.mu.RUnlock()
+	}
+
+	best := pool[0]
+	for _, p := range pool[1:] {
+		if usage[p.Host+":"+p.Port] < usage[best.Host+":"+best.Port] {
+			best = p
+		}
+	}
+	return best
+}
@@ -0,0 +1,59 @@
+package whatsapp
+
+import (
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/proto/waSyncAction"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// buildDeleteForMe builds the app state patch that deletes a message on our
+// own devices only. whatsmeow has no builder for it; the index mirrors the
+// star patch.
+func buildDeleteForMe(chat, sender types.JID, messageID types.MessageID, fromMe bool, timestamp int64) appstate.PatchInfo {
+	isFromMe := "0"
+	if fromMe {
+		isFromMe = "1"
+	}
+	senderJID := sender.String()
+	if fromMe || chat.User == sender.User {
+		senderJID = "0"
+	}
+	return appstate.PatchInfo{
+		Type: appstate.WAPatchRegularHigh,
+		Mutations: []appstate.MutationInfo{{
+			Index:   []string{appstate.IndexDeleteMessageForMe, chat.String(), messageID, isFromMe, senderJID},
+			Version: 3,
+			Value: &waSyncAction.SyncActionValue{
+				DeleteMessageForMeAction: &waSyncAction.DeleteMessageForMeAction{
+					DeleteMedia:      proto.Bool(true),
+					MessageTimestamp: proto.Int64(timestamp),
+				},
+			},
+		}},
+	}
+}
+
+// handleDeleteForMe drops a message deleted for me on another device
+func (m *Manager) handleDeleteForMe(inst *Instance, evt *events.DeleteForMe) {
+	m.removeStoredMessage(inst.ID, evt.ChatJID.String(), evt.MessageID)
+}
+
+// removeStoredMessage removes a message from the store, returning it
+func (m *Manager) removeStoredMessage(instanceID, chatID, messageID string) (MessageData, bool) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID == messageID {
+			removed := msgs[i]
+			m.messages[instanceID][chatID] = append(msgs[:i:i], msgs[i+1:]...)
+			return removed, true
+		}
+	}
+	return MessageData{}, false
+}
@@ -0,0 +1,144 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Delivery statuses of a sent message, in the order they normally happen
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
+	MessagePlayed    = "played"
+)
+
+// Delivery timelines are pruned from the journal this long after a message's
+// first status
+const messageStatusRetention = 7 * 24 * time.Hour
+
+// MessageStatusEntry is one step of a message's delivery timeline
+type MessageStatusEntry struct {
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// MessageStatus is the delivery state of a message sent by an instance
+type MessageStatus struct {
+	MessageID string               `json:"messageId"`
+	Chat      string               `json:"chat"`
+	Status    string               `json:"status"` // Latest status reached
+	Timeline  []MessageStatusEntry `json:"timeline"`
+}
+
+// statusRank orders statuses so out of order receipts don't move a message back
+var statusRank = map[string]int{
+	MessageSent:      0,
+	MessageDelivered: 1,
+	MessageRead:      2,
+	MessagePlayed:    3,
+}
+
+// sendTracked sends a message within the daily quota of the instance's tenant
+// and tracks it once sent
+func (m *Manager) sendTracked(ctx context.Context, instanceID string, client *whatsmeow.Client, to types.JID, msg *waE2E.Message) (whatsmeow.SendResponse, error) {
+	release, err := m.reserveTenantSend(instanceID)
+	if err != nil {
+		return whatsmeow.SendResponse{}, err
+	}
+	resp, err := tracedSendMessage(ctx, client, to, msg)
+	if err != nil {
+		release()
+		return resp, err
+	}
+	m.trackSent(instanceID, to, resp.ID, resp.Timestamp)
+	return resp, nil
+}
+
+// trackSent starts the delivery timeline of a message the instance just sent
+// and counts it in the usage stats
+func (m *Manager) trackSent(instanceID string, chat types.JID, messageID string, sentAt time.Time) {
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	if _, err := m.messageStatuses.RecordMessageStatus(instanceID, messageID, chat.ToNonAD().String(), MessageSent, sentAt.Unix()); err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("messageId", messageID).Msg("Failed to track sent message")
+	}
+	m.countUsage(instanceID, statMessagesSent, 1)
+}
+
+// GetMessageStatus returns the delivery timeline of a message sent by the instance
+func (m *Manager) GetMessageStatus(ctx context.Context, instanceID, messageID string) (*MessageStatus, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	status, err := m.messageStatuses.MessageStatus(ctx, instanceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, fmt.Errorf("%w: %s is not a tracked sent message", ErrMessageNotFound, messageID)
+	}
+	return status, nil
+}
+
+// handleDeliveryReceipt advances the timeline of our sent messages from a
+// recipient's receipt and publishes message_delivered/message_read/message_played
+func (m *Manager) handleDeliveryReceipt(inst *Instance, evt *events.Receipt) {
+	if evt.IsFromMe {
+		return // Our other devices reading the chat, not the recipient
+	}
+
+	var status string
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		status = MessageDelivered
+	case types.ReceiptTypeRead:
+		status = MessageRead
+	case types.ReceiptTypePlayed:
+		status = MessagePlayed
+	default:
+		return
+	}
+
+	for _, messageID := range evt.MessageIDs {
+		// Only messages sent through the API have a timeline
+		tracked, err := m.messageStatuses.MessageStatus(context.Background(), inst.ID, messageID)
+		if err != nil || tracked == nil {
+			continue
+		}
+
+		recorded, err := m.messageStatuses.RecordMessageStatus(inst.ID, messageID, tracked.Chat, status, evt.Timestamp.Unix())
+		if err != nil {
+			log.Warn().Err(err).Str("instanceId", inst.ID).Str("messageId", messageID).Msg("Failed to record delivery receipt")
+			continue
+		}
+		if !recorded {
+			continue
+		}
+		if status != MessagePlayed {
+			if err := m.campaignStore.AdvanceCampaignRecipient(inst.ID, messageID, status); err != nil {
+				log.Warn().Err(err).Str("instanceId", inst.ID).Str("messageId", messageID).Msg("Failed to update campaign recipient")
+			}
+		}
+
+		m.publishEvent(Event{
+			Type:       "message_" + status,
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"messageId": messageID,
+				"chat":      tracked.Chat,
+				"from":      evt.Sender.String(),
+				"timestamp": evt.Timestamp.Unix(),
+			},
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waCompanionReg "go.mau.fi/whatsmeow/proto/waCompanionReg"
+	waWa6 "go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// Identity used when an instance doesn't configure its own
+const (
+	defaultDeviceOS       = "Mac OS"
+	defaultDevicePlatform = "CHROME"
+)
+
+// DeviceIdentity controls how the linked device of an instance appears in
+// WhatsApp > Linked Devices. It's sent while pairing, so changes only apply
+// to the next pairing.
+type DeviceIdentity struct {
+	DeviceName   string `json:"deviceName,omitempty"`   // Label of the linked device; defaults to the OS
+	PlatformType string `json:"platformType,omitempty"` // Browser/platform icon: CHROME, FIREFOX, SAFARI, EDGE, DESKTOP...
+	OS           string `json:"os,omitempty"`
+}
+
+// pairClientTypes maps platform types to the client types accepted by pairing codes
+var pairClientTypes = map[waCompanionReg.DeviceProps_PlatformType]whatsmeow.PairClientType{
+	waCompanionReg.DeviceProps_CHROME:  whatsmeow.PairClientChrome,
+	waCompanionReg.DeviceProps_FIREFOX: whatsmeow.PairClientFirefox,
+	waCompanionReg.DeviceProps_IE:      whatsmeow.PairClientIE,
+	waCompanionReg.DeviceProps_OPERA:   whatsmeow.PairClientOpera,
+	waCompanionReg.DeviceProps_SAFARI:  whatsmeow.PairClientSafari,
+	waCompanionReg.DeviceProps_EDGE:    whatsmeow.PairClientEdge,
+	waCompanionReg.DeviceProps_DESKTOP: whatsmeow.PairClientElectron,
+	waCompanionReg.DeviceProps_UWP:     whatsmeow.PairClientUWP,
+}
+
+// normalize validates the identity and fills in the defaults
+func (d DeviceIdentity) normalize() (DeviceIdentity, error) {
+	d.DeviceName = strings.TrimSpace(d.DeviceName)
+	d.OS = strings.TrimSpace(d.OS)
+	d.PlatformType = strings.ToUpper(strings.TrimSpace(d.PlatformType))
+	if d.OS == "" {
+		d.OS = defaultDeviceOS
+	}
+	if d.PlatformType == "" {
+		d.PlatformType = defaultDevicePlatform
+	}
+	if _, ok := waCompanionReg.DeviceProps_PlatformType_value[d.PlatformType]; !ok {
+		return d, fmt.Errorf("%w: unknown platformType %s", ErrInvalidInput, d.PlatformType)
+	}
+	return d, nil
+}
+
+func (d DeviceIdentity) platform() waCompanionReg.DeviceProps_PlatformType {
+	return waCompanionReg.DeviceProps_PlatformType(waCompanionReg.DeviceProps_PlatformType_value[d.PlatformType])
+}
+
+// label is the name WhatsApp shows for the linked device
+func (d DeviceIdentity) label() string {
+	if d.DeviceName != "" {
+		return d.DeviceName
+	}
+	return d.OS
+}
+
+// pairClient returns the client type and display name sent with pairing codes
+func (d DeviceIdentity) pairClient() (whatsmeow.PairClientType, string) {
+	clientType, ok := pairClientTypes[d.platform()]
+	if !ok {
+		clientType = whatsmeow.PairClientOtherWebClient
+	}
+	if d.DeviceName != "" {
+		return clientType, d.DeviceName
+	}
+	browser := strings.ToUpper(d.PlatformType[:1]) + strings.ToLower(d.PlatformType[1:])
+	return clientType, fmt.Sprintf("%s (%s)", browser, d.OS)
+}
+
+// installDeviceIdentity makes the client register with the instance's identity
+// instead of the process-wide store.DeviceProps
+func (m *Manager) installDeviceIdentity(inst *Instance) {
+	device := inst.Device
+	inst.Client.GetClientPayload = func() *waWa6.ClientPayload {
+		payload := device.GetClientPayload()
+		pairing := payload.GetDevicePairingData()
+		if pairing == nil {
+			return payload // Already paired; the identity isn't sent on login
+		}
+
+		inst.mu.RLock()
+		identity := inst.Identity
+		inst.mu.RUnlock()
+		identity, _ = identity.normalize()
+
+		props := proto.Clone(store.DeviceProps).(*waCompanionReg.DeviceProps)
+		props.Os = proto.String(identity.label())
+		props.PlatformType = identity.platform().Enum()
+		data, err := proto.Marshal(props)
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Msg("Failed to encode device identity")
+			return payload
+		}
+		pairing.DeviceProps = data
+		return payload
+	}
+}
+
+// SetDeviceIdentity changes how the instance's linked device appears in WhatsApp.
+// Already paired instances keep their current identity until paired again.
+func (m *Manager) SetDeviceIdentity(instanceID string, identity DeviceIdentity) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	identity, err := identity.normalize()
+	if err != nil {
+		return err
+	}
+
+	inst.mu.Lock()
+	inst.Identity = identity
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Str("deviceName", identity.DeviceName).Str("platformType", identity.PlatformType).Str("os", identity.OS).Msg("Updated device identity")
+	return nil
+}
@@ -0,0 +1,530 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Dialogflow connector defaults
+const (
+	defaultDialogflowSession  = 30 * time.Minute
+	defaultDialogflowLanguage = "pt-BR"
+	dialogflowScope           = "https://www.googleapis.com/auth/cloud-platform"
+	googleTokenURLPrefix      = "https://oauth2.googleapis.com/" // Only accepted token_uri
+)
+
+// DialogflowConfig connects an instance to a Dialogflow CX agent: each chat
+// gets its own session, incoming text (and optionally voice notes) goes to
+// detectIntent and the fulfillment messages are sent back
+type DialogflowConfig struct {
+	Enabled      bool   `json:"enabled"`
+	ProjectID    string `json:"projectId,omitempty"`
+	Location     string `json:"location,omitempty"` // Agent region (default global)
+	AgentID      string `json:"agentId,omitempty"`
+	LanguageCode string `json:"languageCode,omitempty"` // Default pt-BR
+
+	// Service account key JSON with access to the agent
+	Credentials string `json:"credentials,omitempty"`
+
+	Audio          bool `json:"audio,omitempty"`          // Voice notes are sent as audio for Dialogflow to transcribe
+	SessionMinutes int  `json:"sessionMinutes,omitempty"` // Idle sessions start over (default 30)
+	Groups         bool `json:"groups,omitempty"`         // Also runs in groups
+}
+
+// DialogflowSession is the Dialogflow session of a chat
+type DialogflowSession struct {
+	Chat       string                 `json:"chat"`
+	SessionID  string                 `json:"sessionId"`
+	Page       string                 `json:"page,omitempty"`       // Current page of the flow
+	Parameters map[string]interface{} `json:"parameters,omitempty"` // Session parameters after the last turn
+	StartedAt  int64                  `json:"startedAt"`
+	LastActive int64                  `json:"lastActive"`
+}
+
+// dialogflowSession is a chat session; mu keeps the turns of a chat in order
+type dialogflowSession struct {
+	mu   sync.Mutex
+	info DialogflowSession
+}
+
+// serviceAccount is the part of a Google service account key used to sign
+// token requests
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// googleToken is a cached OAuth access token
+type googleToken struct {
+	value   string
+	expires time.Time
+}
+
+// dialogflowSessions holds the Dialogflow sessions and access tokens of each instance
+type dialogflowSessions struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]*dialogflowSession // instanceID -> chat -> session
+	tokens   map[string]googleToken                   // instanceID -> access token
+	client   *http.Client
+}
+
+func newDialogflowSessions() *dialogflowSessions {
+	return &dialogflowSessions{
+		sessions: make(map[string]map[string]*dialogflowSession),
+		tokens:   make(map[string]googleToken),
+		client:   newDialogflowClient(),
+	}
+}
+
+// newDialogflowClient returns the client of the Dialogflow and token APIs,
+// dialing only public addresses like webhooks
+func newDialogflowClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = publicDialer().DialContext
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// Agent regions, e.g. us-central1; the location becomes part of the API host
+var dialogflowLocation = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// parseServiceAccount decodes a service account key and its private key
+func parseServiceAccount(credentials string) (*serviceAccount, error) {
+	var account serviceAccount
+	if err := json.Unmarshal([]byte(credentials), &account); err != nil {
+		return nil, fmt.Errorf("%w: dialogflow.credentials must be a service account key JSON", ErrInvalidInput)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("%w: dialogflow.credentials requires client_email and private_key", ErrInvalidInput)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURLPrefix + "token"
+	}
+	// The signed assertion is posted there, so only Google's endpoint is accepted
+	if !strings.HasPrefix(account.TokenURI, googleTokenURLPrefix) {
+		return nil, fmt.Errorf("%w: dialogflow.credentials token_uri must be under %s", ErrInvalidInput, googleTokenURLPrefix)
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("%w: dialogflow.credentials private_key is not PEM", ErrInvalidInput)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dialogflow.credentials private_key: %v", ErrInvalidInput, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: dialogflow.credentials private_key must be RSA", ErrInvalidInput)
+	}
+	account.key = key
+	return &account, nil
+}
+
+// validate checks an enabled config points to an agent with usable credentials
+func (c DialogflowConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ProjectID == "" || c.AgentID == "" {
+		return fmt.Errorf("%w: dialogflow.projectId and dialogflow.agentId are required", ErrInvalidInput)
+	}
+	if c.Location != "" && !dialogflowLocation.MatchString(c.Location) {
+		return fmt.Errorf("%w: dialogflow.location must be a region such as us-central1", ErrInvalidInput)
+	}
+	if c.SessionMinutes < 0 {
+		return fmt.Errorf("%w: dialogflow.sessionMinutes must be >= 0", ErrInvalidInput)
+	}
+	_, err := parseServiceAccount(c.Credentials)
+	return err
+}
+
+// SetDialogflow configures the Dialogflow connector of an instance. Sessions
+// and tokens of the previous agent are dropped.
+func (m *Manager) SetDialogflow(instanceID string, config DialogflowConfig) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	inst.mu.RLock()
+	config.Credentials = keepSecret(config.Credentials, inst.Dialogflow.Credentials)
+	inst.mu.RUnlock()
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	inst.mu.Lock()
+	inst.Dialogflow = config
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	m.dropDialogflowSessions(instanceID)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", config.Enabled).Str("agentId", config.AgentID).Msg("Updated Dialogflow connector")
+	return nil
+}
+
+// GetDialogflowSessions lists the Dialogflow sessions of an instance, most
+// recent first
+func (m *Manager) GetDialogflowSessions(instanceID string) ([]DialogflowSession, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	m.dialogflow.mu.Lock()
+	sessions := make([]*dialogflowSession, 0, len(m.dialogflow.sessions[instanceID]))
+	for _, session := range m.dialogflow.sessions[instanceID] {
+		sessions = append(sessions, session)
+	}
+	m.dialogflow.mu.Unlock()
+
+	list := make([]DialogflowSession, 0, len(sessions))
+	for _, session := range sessions {
+		session.mu.Lock()
+		if session.info.SessionID != "" {
+			list = append(list, session.info)
+		}
+		session.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastActive > list[j].LastActive })
+	return list, nil
+}
+
+// EndDialogflowSession ends the Dialogflow session of a chat, if any; its next
+// message starts a new one
+func (m *Manager) EndDialogflowSession(instanceID, chatID string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+	to, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(to, "@") {
+		to = to + "@s.whatsapp.net"
+	}
+	chatID = m.canonicalChatID(to)
+
+	m.dialogflow.mu.Lock()
+	delete(m.dialogflow.sessions[instanceID], chatID)
+	m.dialogflow.mu.Unlock()
+	log.Info().Str("instanceId", instanceID).Str("chat", chatID).Msg("Ended Dialogflow session")
+	return nil
+}
+
+// dropDialogflowSessions forgets every session and token of an instance
+func (m *Manager) dropDialogflowSessions(instanceID string) {
+	m.dialogflow.mu.Lock()
+	delete(m.dialogflow.sessions, instanceID)
+	delete(m.dialogflow.tokens, instanceID)
+	m.dialogflow.mu.Unlock()
+}
+
+// dialogflowQuery is what a chat turn sends to detectIntent
+type dialogflowQuery struct {
+	text  string
+	audio []byte // OGG Opus voice note
+}
+
+// maybeDialogflow sends a message to the Dialogflow session of its chat.
+// Reports whether the connector handles the chat.
+func (m *Manager) maybeDialogflow(inst *Instance, chat types.JID, msg MessageData) bool {
+	inst.mu.RLock()
+	config := inst.Dialogflow
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if !config.Enabled || client == nil || msg.FromMe || msg.IsGroup && !config.Groups {
+		return false
+	}
+	text := msg.Body
+	if text == "" {
+		text = msg.Caption
+	}
+	if text == "" && msg.Interactive != nil {
+		text = msg.Interactive.DisplayText
+	}
+	voice := config.Audio && msg.Type == "audio"
+	if text == "" && !voice {
+		return false
+	}
+	chatID := m.canonicalChatID(chat.String())
+
+	m.dialogflow.mu.Lock()
+	if m.dialogflow.sessions[inst.ID] == nil {
+		m.dialogflow.sessions[inst.ID] = make(map[string]*dialogflowSession)
+	}
+	session := m.dialogflow.sessions[inst.ID][chatID]
+	if session == nil {
+		session = &dialogflowSession{info: DialogflowSession{Chat: chatID}}
+		m.dialogflow.sessions[inst.ID][chatID] = session
+	}
+	m.dialogflow.mu.Unlock()
+
+	go func() {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+
+		query := dialogflowQuery{text: text}
+		if voice {
+			media, err := m.FetchMessageMedia(context.Background(), inst.ID, msg.ID)
+			if err != nil {
+				log.Error().Err(err).Str("instanceId", inst.ID).Str("messageId", msg.ID).Msg("Failed to fetch voice note for Dialogflow")
+				return
+			}
+			query.audio = media.Data
+		}
+
+		idle := time.Duration(config.SessionMinutes) * time.Minute
+		if idle <= 0 {
+			idle = defaultDialogflowSession
+		}
+		now := time.Now()
+		if session.info.SessionID == "" || now.Sub(time.Unix(session.info.LastActive, 0)) > idle {
+			id := make([]byte, 16)
+			rand.Read(id)
+			session.info = DialogflowSession{Chat: chatID, SessionID: hex.EncodeToString(id), StartedAt: now.Unix()}
+		}
+
+		number := msg.ResolvedPhone
+		if number == "" {
+			number = jidUser(msg.From)
+		}
+		result, err := m.detectIntent(inst.ID, config, session.info.SessionID, query, map[string]interface{}{
+			"remoteJid": chatID,
+			"number":    number,
+			"pushName":  msg.PushName,
+		})
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("Dialogflow detectIntent failed")
+			return
+		}
+		session.info.LastActive = now.Unix()
+		session.info.Page = result.CurrentPage.DisplayName
+		session.info.Parameters = result.Parameters
+
+		ended := false
+		for _, response := range result.ResponseMessages {
+			var err error
+			switch {
+			case response.Text != nil:
+				if reply := strings.Join(response.Text.Text, "\n"); reply != "" {
+					_, err = m.sendPlainText(inst, chat.ToNonAD(), reply)
+				}
+			case response.Payload.MediaURL != "":
+				_, err = m.sendMediaURL(context.Background(), inst, chat.ToNonAD(), response.Payload.MediaURL, response.Payload.Caption, response.Payload.MediaType, MediaOptions{})
+			case response.EndInteraction != nil:
+				ended = true
+			}
+			if err != nil {
+				log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("Failed to send Dialogflow response")
+			}
+		}
+
+		m.publishEvent(Event{
+			Type:       "dialogflow_intent",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"chat":           chatID,
+				"messageId":      msg.ID,
+				"sessionId":      session.info.SessionID,
+				"intent":         result.Match.Intent.DisplayName,
+				"confidence":     result.Match.Confidence,
+				"page":           result.CurrentPage.DisplayName,
+				"parameters":     result.Parameters,
+				"transcript":     result.Transcript,
+				"endInteraction": ended,
+			},
+		})
+
+		if ended {
+			m.dialogflow.mu.Lock()
+			if m.dialogflow.sessions[inst.ID][chatID] == session {
+				delete(m.dialogflow.sessions[inst.ID], chatID)
+			}
+			m.dialogflow.mu.Unlock()
+			session.info.SessionID = ""
+		}
+	}()
+	return true
+}
+
+// dialogflowResult is the part of a detectIntent queryResult the connector uses
+type dialogflowResult struct {
+	Transcript       string                 `json:"transcript"`
+	Parameters       map[string]interface{} `json:"parameters"`
+	ResponseMessages []struct {
+		Text *struct {
+			Text []string `json:"text"`
+		} `json:"text"`
+		// Custom payloads with mediaUrl send media
+		Payload struct {
+			MediaURL  string `json:"mediaUrl"`
+			MediaType string `json:"mediaType"`
+			Caption   string `json:"caption"`
+		} `json:"payload"`
+		EndInteraction *struct{} `json:"endInteraction"`
+	} `json:"responseMessages"`
+	Match struct {
+		Intent struct {
+			DisplayName string `json:"displayName"`
+		} `json:"intent"`
+		Confidence float64 `json:"confidence"`
+	} `json:"match"`
+	CurrentPage struct {
+		DisplayName string `json:"displayName"`
+	} `json:"currentPage"`
+}
+
+// detectIntent runs one turn of a session. The chat parameters are set on
+// the session so flows can use them.
+func (m *Manager) detectIntent(instanceID string, config DialogflowConfig, sessionID string, query dialogflowQuery, parameters map[string]interface{}) (*dialogflowResult, error) {
+	token, err := m.dialogflowToken(instanceID, config)
+	if err != nil {
+		return nil, err
+	}
+
+	location := config.Location
+	if location == "" {
+		location = "global"
+	}
+	host := "dialogflow.googleapis.com"
+	if location != "global" {
+		host = location + "-dialogflow.googleapis.com"
+	}
+	language := config.LanguageCode
+	if language == "" {
+		language = defaultDialogflowLanguage
+	}
+
+	queryInput := map[string]interface{}{"languageCode": language}
+	if query.audio != nil {
+		queryInput["audio"] = map[string]interface{}{
+			"config": map[string]interface{}{
+				"audioEncoding":   "AUDIO_ENCODING_OGG_OPUS",
+				"sampleRateHertz": 16000,
+			},
+			"audio": base64.StdEncoding.EncodeToString(query.audio),
+		}
+	} else {
+		queryInput["text"] = map[string]string{"text": query.text}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"queryInput":  queryInput,
+		"queryParams": map[string]interface{}{"parameters": parameters},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("https://%s/v3/projects/%s/locations/%s/agents/%s/sessions/%s:detectIntent",
+		host, url.PathEscape(config.ProjectID), url.PathEscape(location), url.PathEscape(config.AgentID), sessionID)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := m.dialogflow.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("dialogflow returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var response struct {
+		QueryResult dialogflowResult `json:"queryResult"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid dialogflow response: %w", err)
+	}
+	return &response.QueryResult, nil
+}
+
+// dialogflowToken returns an access token for the service account of an
+// instance, exchanging a signed JWT for a new one when the cached one expires
+func (m *Manager) dialogflowToken(instanceID string, config DialogflowConfig) (string, error) {
+	m.dialogflow.mu.Lock()
+	cached, ok := m.dialogflow.tokens[instanceID]
+	m.dialogflow.mu.Unlock()
+	if ok && time.Until(cached.expires) > time.Minute {
+		return cached.value, nil
+	}
+
+	account, err := parseServiceAccount(config.Credentials)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": dialogflowScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+
+	resp, err := m.dialogflow.client.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("token endpoint returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response")
+	}
+
+	m.dialogflow.mu.Lock()
+	m.dialogflow.tokens[instanceID] = googleToken{
+		value:   token.AccessToken,
+		expires: now.Add(time.Duration(token.ExpiresIn) * time.Second),
+	}
+	m.dialogflow.mu.Unlock()
+	return token.AccessToken, nil
+}
@@ -0,0 +1,49 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// SetDisappearingTimer sets the disappearing messages timer of a chat: off,
+// 24h, 7d or 90d, the durations the WhatsApp apps offer. Returns the timer
+// in seconds. Groups require the instance to be an admin when only admins
+// may edit the group info.
+func (m *Manager) SetDisappearingTimer(instanceID, chatID, timer string) (uint32, error) {
+	duration, ok := whatsmeow.ParseDisappearingTimerString(timer)
+	if !ok {
+		return 0, fmt.Errorf("%w: timer must be off, 24h, 7d or 90d", ErrInvalidInput)
+	}
+
+	inst, chatJID, err := m.resolveRecipient(instanceID, chatID)
+	if err != nil {
+		return 0, err
+	}
+
+	switch chatJID.Server {
+	case types.DefaultUserServer, types.HiddenUserServer:
+		err = inst.Client.SetDisappearingTimer(context.Background(), chatJID, duration, time.Time{})
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrSendFailed, err)
+		}
+	case types.GroupServer:
+		err = inst.Client.SetDisappearingTimer(context.Background(), chatJID, duration, time.Time{})
+		if errors.Is(err, whatsmeow.ErrInvalidDisappearingTimer) {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		} else if err != nil {
+			return 0, groupSettingError(chatJID, "disappearing timer", err)
+		}
+	default:
+		return 0, fmt.Errorf("%w: disappearing messages can't be set in %s", ErrInvalidJID, chatJID)
+	}
+
+	seconds := uint32(duration.Seconds())
+	log.Info().Str("instanceId", instanceID).Str("chat", chatJID.String()).Uint32("timer", seconds).Msg("Set disappearing timer")
+	return seconds, nil
+}
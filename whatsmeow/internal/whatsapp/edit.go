@@ -0,0 +1,116 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Edit modes of EditMessage
+const (
+	EditText         = "text"         // The message is text (default)
+	EditMediaCaption = "mediaCaption" // The caption of an image, video or document
+)
+
+// MessageEdit is an earlier version of an edited message
+type MessageEdit struct {
+	Body     string `json:"body"`
+	EditedAt int64  `json:"editedAt"` // When this version was replaced
+}
+
+// handleEdit applies an edit to the stored message and publishes message_edited
+func (m *Manager) handleEdit(inst *Instance, evt *events.Message, protocol *waE2E.ProtocolMessage, paused bool) {
+	messageID := protocol.GetKey().GetID()
+	chat := m.canonicalChatID(evt.Info.Chat.String())
+	newText := editedText(protocol.GetEditedMessage())
+
+	oldText, _ := m.editStoredMessage(inst.ID, chat, messageID, newText, evt.Info.Timestamp.Unix())
+
+	if paused {
+		return
+	}
+	m.publishEvent(Event{
+		Type:       "message_edited",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"messageId": messageID,
+			"chat":      chat,
+			"sender":    evt.Info.Sender.String(),
+			"fromMe":    evt.Info.IsFromMe,
+			"oldText":   oldText,
+			"newText":   newText,
+			"timestamp": evt.Info.Timestamp.Unix(),
+		},
+	})
+}
+
+// editedText returns the text of the new version of an edited message: the
+// text itself or the caption of media
+func editedText(msg *waE2E.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	}
+	return ""
+}
+
+// editContent builds the new version of an edited message. Captions are sent
+// in a message of the same media type as the original.
+func editContent(mode, mediaType, text string) (*waE2E.Message, error) {
+	switch mode {
+	case "", EditText:
+		return &waE2E.Message{Conversation: proto.String(text)}, nil
+	case EditMediaCaption:
+		switch mediaType {
+		case "image":
+			return &waE2E.Message{ImageMessage: &waE2E.ImageMessage{Caption: proto.String(text)}}, nil
+		case "video":
+			return &waE2E.Message{VideoMessage: &waE2E.VideoMessage{Caption: proto.String(text)}}, nil
+		case "document":
+			return &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{Caption: proto.String(text)}}, nil
+		case "":
+			return nil, fmt.Errorf("%w: mediaType is required for messages missing from the store", ErrInvalidInput)
+		}
+		return nil, fmt.Errorf("%w: captions of %s messages can't be edited", ErrInvalidInput, mediaType)
+	}
+	return nil, fmt.Errorf("%w: edit mode must be %s or %s", ErrInvalidInput, EditText, EditMediaCaption)
+}
+
+// editStoredMessage replaces the body of a stored message, moving the old one
+// to its edit history. Returns the old body, or false when the message isn't
+// stored (or was revoked).
+func (m *Manager) editStoredMessage(instanceID, chatID, messageID, newText string, editedAt int64) (string, bool) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID != messageID {
+			continue
+		}
+		if msgs[i].Revoked {
+			return "", false
+		}
+		oldText := msgs[i].Body
+		msgs[i].Edits = append(msgs[i].Edits, MessageEdit{Body: oldText, EditedAt: editedAt})
+		msgs[i].Body = newText
+		switch msgs[i].Type {
+		case "image", "video", "document":
+			msgs[i].Caption = newText
+		}
+		return oldText, true
+	}
+	return "", false
+}
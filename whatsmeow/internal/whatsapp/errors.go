@@ -0,0 +1,32 @@
+package whatsapp
+
+import "errors"
+
+// Sentinel errors returned (wrapped) by Manager methods, so callers can map
+// failures to machine-readable codes with errors.Is
+var (
+	ErrInstanceNotFound    = errors.New("instance not found")
+	ErrInstanceExists      = errors.New("instance already exists")
+	ErrInstanceDeleted     = errors.New("instance deleted")
+	ErrNotConnected        = errors.New("instance not connected")
+	ErrAlreadyConnected    = errors.New("already connected")
+	ErrNotOnWhatsApp       = errors.New("not on WhatsApp")
+	ErrInvalidJID          = errors.New("invalid JID")
+	ErrInvalidPhoneNumber  = errors.New("invalid phone number")
+	ErrInvalidInput        = errors.New("invalid input")
+	ErrMediaNotFound       = errors.New("media not found")
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrNotGroupAdmin       = errors.New("not a group admin")
+	ErrMediaDownloadFailed = errors.New("media download failed")
+	ErrMediaUploadFailed   = errors.New("media upload failed")
+	ErrSendFailed          = errors.New("failed to send message")
+	ErrInvalidMediaToken   = errors.New("invalid media token")
+	ErrAutoReplyNotFound   = errors.New("auto-reply not found")
+	ErrCampaignNotFound    = errors.New("campaign not found")
+	ErrCampaignFinished    = errors.New("campaign finished")
+	ErrContactNotFound     = errors.New("contact not found")
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrClusterUnavailable  = errors.New("cluster unavailable")
+)
@@ -0,0 +1,42 @@
+package whatsapp
+
+import "strings"
+
+// EventFilter selects the event types a consumer receives. A nil filter
+// allows every event.
+type EventFilter map[string]bool
+
+// NewEventFilter builds a filter from event types; "call*" matches every type
+// starting with "call". No types means no filtering.
+func NewEventFilter(types []string) EventFilter {
+	var filter EventFilter
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if filter == nil {
+			filter = make(EventFilter)
+		}
+		filter[t] = true
+	}
+	return filter
+}
+
+// ParseEventFilter builds a filter from a comma separated list (?events=message,qr)
+func ParseEventFilter(list string) EventFilter {
+	return NewEventFilter(strings.Split(list, ","))
+}
+
+// Allows reports whether events of a type pass the filter
+func (f EventFilter) Allows(eventType string) bool {
+	if f == nil || f[eventType] {
+		return true
+	}
+	for t := range f {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
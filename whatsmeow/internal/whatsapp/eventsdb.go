@@ -0,0 +1,60 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// openEventsDB opens (or creates) events.db. It holds the event journal and,
+// in tables of their own, the other stores kept in SQLite, which share its
+// connection.
+func openEventsDB(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=5000", dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events database: %w", err)
+	}
+	// SQLite only supports one writer at a time
+	db.SetMaxOpenConns(1)
+
+	for _, store := range []struct{ name, schema string }{
+		{"event journal", eventJournalSchema},
+		{"message status", messageStatusSchema},
+		{"LID map", lidMapSchema},
+		{"campaign", campaignSchema},
+		{"usage", usageSchema},
+		{"address book", addressBookSchema},
+		{"audit log", auditLogSchema},
+	} {
+		if _, err := db.Exec(store.schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create %s schema: %w", store.name, err)
+		}
+	}
+
+	// Journals created before filter rules routed events lack the column
+	if err := addColumn(db, "events", "webhook_url", "TEXT"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateMessageStatus(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// addColumn adds a column to a table unless it's already there
+func addColumn(db *sql.DB, table, column, definition string) error {
+	var exists bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Webhook payload formats
+const (
+	WebhookFormatNative    = "native"    // Events as published on the WebSocket
+	WebhookFormatEvolution = "evolution" // Evolution API event names and payload shapes
+)
+
+// Evolution API webhook event of each event type; other types aren't
+// delivered to webhooks in that format
+var evolutionEvents = map[string]string{
+	"qr":                "qrcode.updated",
+	"ready":             "connection.update",
+	"disconnected":      "connection.update",
+	"logged_out":        "connection.update",
+	"message":           "messages.upsert",
+	"message_sent":      "send.message",
+	"message_delivered": "messages.update",
+	"message_read":      "messages.update",
+	"message_played":    "messages.update",
+}
+
+// Evolution API (Baileys) message status of each delivery receipt
+var evolutionStatuses = map[string]string{
+	"message_delivered": "DELIVERY_ACK",
+	"message_read":      "READ",
+	"message_played":    "PLAYED",
+}
+
+// SetWebhookFormat selects the payload format of an instance's webhook:
+// native ("" too) or evolution
+func (m *Manager) SetWebhookFormat(instanceID, format string) error {
+	if err := validateWebhookFormat(format); err != nil {
+		return err
+	}
+
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+
+	inst.mu.Lock()
+	inst.WebhookFormat = format
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+
+	log.Info().Str("instanceId", instanceID).Str("format", format).Msg("Updated webhook format")
+	return nil
+}
+
+// EvolutionEventTypes returns the event types behind Evolution API webhook
+// event names such as MESSAGES_UPSERT; names without an equivalent are ignored
+func EvolutionEventTypes(names []string) []string {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[strings.ToLower(strings.ReplaceAll(name, "_", "."))] = true
+	}
+
+	eventTypes := make([]string, 0)
+	for eventType, name := range evolutionEvents {
+		if wanted[name] {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	sort.Strings(eventTypes)
+	return eventTypes
+}
+
+// EvolutionEventNames returns the Evolution API webhook event names of
+// event types, the reverse of EvolutionEventTypes. No event types stands for
+// every event, so every name is returned.
+func EvolutionEventNames(eventTypes []string) []string {
+	if len(eventTypes) == 0 {
+		for eventType := range evolutionEvents {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, eventType := range eventTypes {
+		name, ok := evolutionEvents[eventType]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, strings.ToUpper(strings.ReplaceAll(name, ".", "_")))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validateWebhookFormat(format string) error {
+	switch format {
+	case "", WebhookFormatNative, WebhookFormatEvolution:
+		return nil
+	default:
+		return fmt.Errorf("%w: webhookFormat must be native or evolution", ErrInvalidInput)
+	}
+}
+
+// evolutionWebhookBody encodes an event as an Evolution API webhook
+// delivery. Returns false for events that format has no equivalent for.
+func (m *Manager) evolutionWebhookBody(evt Event, webhookURL string) ([]byte, bool) {
+	name, ok := evolutionEvents[evt.Type]
+	if !ok {
+		return nil, false
+	}
+
+	// Events read back from the journal carry their data as raw JSON
+	raw, err := json.Marshal(evt.Data)
+	if err != nil {
+		return nil, false
+	}
+
+	sender := ""
+	if inst, ok := m.GetInstance(evt.InstanceID); ok {
+		inst.mu.RLock()
+		if inst.WANumber != "" {
+			sender = inst.WANumber + "@s.whatsapp.net"
+		}
+		inst.mu.RUnlock()
+	}
+
+	var data interface{}
+	switch evt.Type {
+	case "qr":
+		var qr struct {
+			QR       string `json:"qr"`
+			QRBase64 string `json:"qrBase64"`
+		}
+		json.Unmarshal(raw, &qr)
+		data = map[string]interface{}{
+			"qrcode": map[string]interface{}{
+				"instance":    evt.InstanceID,
+				"pairingCode": nil,
+				"code":        qr.QR,
+				"base64":      qr.QRBase64,
+			},
+		}
+
+	case "ready":
+		var ready struct {
+			Number string `json:"number"`
+			Name   string `json:"name"`
+		}
+		json.Unmarshal(raw, &ready)
+		data = map[string]interface{}{
+			"instance":     evt.InstanceID,
+			"state":        "open",
+			"statusReason": 200,
+			"wuid":         ready.Number + "@s.whatsapp.net",
+			"profileName":  ready.Name,
+		}
+
+	case "disconnected", "logged_out":
+		// Baileys disconnect reasons: 401 logged out, 428 connection closed
+		reason := 428
+		if evt.Type == "logged_out" {
+			reason = 401
+		}
+		data = map[string]interface{}{
+			"instance":     evt.InstanceID,
+			"state":        "close",
+			"statusReason": reason,
+		}
+
+	case "message":
+		var msg MessageData
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, false
+		}
+		data = EvolutionMessage(evt.InstanceID, msg)
+
+	case "message_sent":
+		var sent struct {
+			MessageID string `json:"messageId"`
+			To        string `json:"to"`
+		}
+		json.Unmarshal(raw, &sent)
+		data = map[string]interface{}{
+			"key":              EvolutionKey(sent.To, true, sent.MessageID, ""),
+			"status":           "PENDING",
+			"messageTimestamp": evt.Timestamp,
+			"instanceId":       evt.InstanceID,
+		}
+
+	default: // Delivery receipts
+		var receipt struct {
+			MessageID string `json:"messageId"`
+			Chat      string `json:"chat"`
+			From      string `json:"from"`
+		}
+		json.Unmarshal(raw, &receipt)
+		data = map[string]interface{}{
+			"keyId":       receipt.MessageID,
+			"remoteJid":   receipt.Chat,
+			"fromMe":      true,
+			"participant": receipt.From,
+			"status":      evolutionStatuses[evt.Type],
+			"instanceId":  evt.InstanceID,
+		}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"event":       name,
+		"instance":    evt.InstanceID,
+		"data":        data,
+		"destination": webhookURL,
+		"date_time":   time.Unix(evt.Timestamp, 0).UTC().Format(time.RFC3339),
+		"sender":      sender,
+	})
+	if err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+// EvolutionKey builds the Baileys message key Evolution API clients use to
+// reference a message; a bare phone number is turned into its JID
+func EvolutionKey(remoteJID string, fromMe bool, id, participant string) map[string]interface{} {
+	if remoteJID != "" && !strings.Contains(remoteJID, "@") {
+		remoteJID += "@s.whatsapp.net"
+	}
+	key := map[string]interface{}{
+		"remoteJid": remoteJID,
+		"fromMe":    fromMe,
+		"id":        id,
+	}
+	if participant != "" {
+		key["participant"] = participant
+	}
+	return key
+}
+
+// EvolutionMessage converts a message to the shape of an Evolution API
+// messages.upsert payload
+func EvolutionMessage(instanceID string, msg MessageData) map[string]interface{} {
+	participant := ""
+	if msg.IsGroup {
+		participant = msg.From
+	}
+
+	messageType := "conversation"
+	content := map[string]interface{}{}
+	media := func(kind string) {
+		messageType = kind + "Message"
+		fields := map[string]interface{}{"mimetype": msg.Mimetype}
+		if msg.Caption != "" {
+			fields["caption"] = msg.Caption
+		}
+		if msg.FileName != "" {
+			fields["fileName"] = msg.FileName
+		}
+		if msg.FileLength != 0 {
+			fields["fileLength"] = msg.FileLength
+		}
+		if msg.ViewOnce {
+			fields["viewOnce"] = true
+		}
+		content[messageType] = fields
+	}
+
+	switch msg.Type {
+	case "image", "video", "audio", "document", "sticker":
+		media(msg.Type)
+	case "location", "live_location":
+		messageType = "locationMessage"
+		if msg.Type == "live_location" {
+			messageType = "liveLocationMessage"
+		}
+		location := map[string]interface{}{}
+		if msg.Location != nil {
+			location["degreesLatitude"] = msg.Location.Latitude
+			location["degreesLongitude"] = msg.Location.Longitude
+			location["name"] = msg.Location.Name
+			location["address"] = msg.Location.Address
+		}
+		content[messageType] = location
+	case "poll":
+		messageType = "pollCreationMessage"
+		poll := map[string]interface{}{}
+		if msg.Poll != nil {
+			options := make([]map[string]string, len(msg.Poll.Options))
+			for i, option := range msg.Poll.Options {
+				options[i] = map[string]string{"optionName": option}
+			}
+			poll["name"] = msg.Poll.Question
+			poll["options"] = options
+			poll["selectableOptionsCount"] = msg.Poll.SelectableCount
+		}
+		content[messageType] = poll
+	default:
+		// Text, and replies to buttons and lists, carry their text in the body
+		content["conversation"] = msg.Body
+	}
+	if msg.MediaBase64 != "" {
+		content["base64"] = msg.MediaBase64
+	}
+	if msg.MediaURL != "" {
+		content["mediaUrl"] = msg.MediaURL
+	}
+
+	return map[string]interface{}{
+		"key":              EvolutionKey(msg.To, msg.FromMe, msg.ID, participant),
+		"pushName":         msg.PushName,
+		"message":          content,
+		"messageType":      messageType,
+		"messageTimestamp": msg.Timestamp,
+		"instanceId":       instanceID,
+		"source":           "unknown",
+	}
+}
@@ -0,0 +1,208 @@
+package whatsapp
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chat export formats
+const (
+	ExportJSON = "json"
+	ExportCSV  = "csv"
+	ExportTXT  = "txt"
+)
+
+// ChatExport is a rendered chat history ready to be downloaded
+type ChatExport struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// exportedMessage is a stored message as written in JSON/CSV exports.
+// Media content is never inlined; with media bundling it's a file in the ZIP.
+type exportedMessage struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	From      string `json:"from"`
+	PushName  string `json:"pushName,omitempty"`
+	FromMe    bool   `json:"fromMe"`
+	Type      string `json:"type"`
+	Body      string `json:"body"`
+	Caption   string `json:"caption,omitempty"`
+	FileName  string `json:"fileName,omitempty"`
+	Mimetype  string `json:"mimetype,omitempty"`
+	MediaFile string `json:"mediaFile,omitempty"`
+}
+
+// exportFile is an entry of a chat export archive
+type exportFile struct {
+	name string
+	data []byte
+}
+
+// ExportChat renders the stored history of a chat as JSON, CSV or WhatsApp-style
+// TXT. With includeMedia the transcript and the downloaded media are bundled in a ZIP.
+func (m *Manager) ExportChat(instanceID, chatID, format string, includeMedia bool) (*ChatExport, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	switch format {
+	case ExportJSON, ExportCSV, ExportTXT:
+	default:
+		return nil, fmt.Errorf("%w: unsupported export format: %s", ErrInvalidInput, format)
+	}
+
+	msgs, err := m.GetChatMessages(instanceID, chatID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Name media files up front so the transcript can reference them
+	records := make([]exportedMessage, len(msgs))
+	var media []exportFile
+	for i, msg := range msgs {
+		records[i] = exportedMessage{
+			ID:        msg.ID,
+			Timestamp: msg.Timestamp,
+			From:      msg.From,
+			PushName:  msg.PushName,
+			FromMe:    msg.FromMe,
+			Type:      msg.Type,
+			Body:      msg.Body,
+			Caption:   msg.Caption,
+			FileName:  msg.FileName,
+			Mimetype:  msg.Mimetype,
+		}
+		if !includeMedia || msg.MediaBase64 == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(msg.MediaBase64)
+		if err != nil {
+			continue
+		}
+		name := "media/" + msg.ID + mediaExtension(msg)
+		records[i].MediaFile = name
+		media = append(media, exportFile{name, data})
+	}
+
+	var transcript bytes.Buffer
+	var contentType string
+	switch format {
+	case ExportJSON:
+		contentType = "application/json"
+		enc := json.NewEncoder(&transcript)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(records); err != nil {
+			return nil, fmt.Errorf("failed to encode chat export: %w", err)
+		}
+	case ExportCSV:
+		contentType = "text/csv; charset=utf-8"
+		if err := writeExportCSV(&transcript, records); err != nil {
+			return nil, fmt.Errorf("failed to encode chat export: %w", err)
+		}
+	case ExportTXT:
+		contentType = "text/plain; charset=utf-8"
+		writeExportTXT(&transcript, records)
+	}
+
+	baseName := "chat-" + strings.SplitN(m.canonicalChatID(chatID), "@", 2)[0]
+	if !includeMedia {
+		return &ChatExport{
+			FileName:    baseName + "." + format,
+			ContentType: contentType,
+			Data:        transcript.Bytes(),
+		}, nil
+	}
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	files := append([]exportFile{{baseName + "." + format, transcript.Bytes()}}, media...)
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create export archive: %w", err)
+		}
+		if _, err := f.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write export archive: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write export archive: %w", err)
+	}
+
+	return &ChatExport{
+		FileName:    baseName + ".zip",
+		ContentType: "application/zip",
+		Data:        archive.Bytes(),
+	}, nil
+}
+
+// mediaExtension picks a file extension for exported media
+func mediaExtension(msg MessageData) string {
+	if i := strings.LastIndex(msg.FileName, "."); i >= 0 {
+		return msg.FileName[i:]
+	}
+	mimetype := strings.TrimSpace(strings.SplitN(msg.Mimetype, ";", 2)[0])
+	if exts, err := mime.ExtensionsByType(mimetype); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+func writeExportCSV(buf *bytes.Buffer, records []exportedMessage) error {
+	w := csv.NewWriter(buf)
+	w.Write([]string{"id", "timestamp", "from", "pushName", "fromMe", "type", "body", "fileName", "mediaFile"})
+	for _, r := range records {
+		w.Write([]string{
+			r.ID,
+			time.Unix(r.Timestamp, 0).Format(time.RFC3339),
+			r.From,
+			r.PushName,
+			strconv.FormatBool(r.FromMe),
+			r.Type,
+			r.Body,
+			r.FileName,
+			r.MediaFile,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeExportTXT writes the transcript in the layout of WhatsApp's own
+// "Export chat" feature: "dd/mm/yyyy hh:mm - Name: message"
+func writeExportTXT(buf *bytes.Buffer, records []exportedMessage) {
+	for _, r := range records {
+		sender := r.PushName
+		if r.FromMe {
+			sender = "Você"
+		} else if sender == "" {
+			sender = strings.SplitN(r.From, "@", 2)[0]
+		}
+
+		text := r.Body
+		switch {
+		case r.MediaFile != "":
+			attachment := fmt.Sprintf("%s (arquivo anexado)", strings.TrimPrefix(r.MediaFile, "media/"))
+			if text != "" {
+				text = attachment + "\n" + text
+			} else {
+				text = attachment
+			}
+		case text == "" && r.Type != "text":
+			text = "<Mídia oculta>"
+		}
+
+		fmt.Fprintf(buf, "%s - %s: %s\n", time.Unix(r.Timestamp, 0).Format("02/01/2006 15:04"), sender, text)
+	}
+}
@@ -0,0 +1,216 @@
+package whatsapp
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Actions of a message filter rule
+const (
+	FilterDrop    = "drop"    // The message is neither stored nor published
+	FilterTag     = "tag"     // The rule's tags are added to the message
+	FilterRead    = "read"    // The message is marked as read
+	FilterWebhook = "webhook" // The message event goes to the rule's webhook instead of the instance one
+)
+
+// FilterRule matches incoming messages and applies an action to them. Every
+// condition set must match; a rule without conditions matches all messages.
+type FilterRule struct {
+	Name    string   `json:"name,omitempty"`
+	Chats   []string `json:"chats,omitempty"`   // Chat JIDs or numbers
+	Senders []string `json:"senders,omitempty"` // Sender JIDs or numbers
+	Types   []string `json:"types,omitempty"`   // Message types (text, image, audio...); reactions aren't filtered
+	IsGroup *bool    `json:"isGroup,omitempty"`
+	FromMe  *bool    `json:"fromMe,omitempty"`
+	Body    string   `json:"body,omitempty"` // Regular expression matched against the text or caption
+
+	Action        string   `json:"action"`                  // drop, tag, read or webhook
+	Tags          []string `json:"tags,omitempty"`          // For tag
+	WebhookURL    string   `json:"webhookUrl,omitempty"`    // For webhook
+	WebhookSecret string   `json:"webhookSecret,omitempty"` // Signs the events routed to webhookUrl; unsigned without one
+
+	body *regexp.Regexp
+}
+
+// filterResult is what the matching rules decided for a message
+type filterResult struct {
+	drop       bool
+	read       bool
+	tags       []string
+	webhookURL string // First webhook a rule routed the message to
+}
+
+// compileFilterRules validates rules and compiles their body expressions.
+// Chats and senders are normalized so they compare with stored messages.
+func (m *Manager) compileFilterRules(rules []FilterRule) ([]FilterRule, error) {
+	compiled := make([]FilterRule, len(rules))
+	for i, rule := range rules {
+		switch rule.Action {
+		case FilterDrop, FilterRead:
+		case FilterTag:
+			if len(rule.Tags) == 0 {
+				return nil, fmt.Errorf("%w: filterRules[%d] tag action requires tags", ErrInvalidInput, i)
+			}
+		case FilterWebhook:
+			if err := validateWebhookURL(rule.WebhookURL); err != nil {
+				return nil, fmt.Errorf("%w: filterRules[%d] webhookUrl is invalid", ErrInvalidInput, i)
+			}
+		default:
+			return nil, fmt.Errorf("%w: filterRules[%d] action must be drop, tag, read or webhook", ErrInvalidInput, i)
+		}
+
+		if rule.Body != "" {
+			re, err := regexp.Compile(rule.Body)
+			if err != nil {
+				return nil, fmt.Errorf("%w: filterRules[%d] body: %v", ErrInvalidInput, i, err)
+			}
+			rule.body = re
+		}
+
+		chats := make([]string, 0, len(rule.Chats))
+		for _, chat := range rule.Chats {
+			jid, err := m.normalizeRecipient(chat)
+			if err != nil {
+				return nil, fmt.Errorf("%w: filterRules[%d] chat %s", ErrInvalidInput, i, chat)
+			}
+			if !strings.Contains(jid, "@") {
+				jid = jid + "@s.whatsapp.net"
+			}
+			chats = append(chats, jid)
+		}
+		rule.Chats = chats
+
+		senders := make([]string, 0, len(rule.Senders))
+		for _, sender := range rule.Senders {
+			user, err := m.normalizeRecipient(sender)
+			if err != nil {
+				return nil, fmt.Errorf("%w: filterRules[%d] sender %s", ErrInvalidInput, i, sender)
+			}
+			senders = append(senders, jidUser(user))
+		}
+		rule.Senders = senders
+
+		compiled[i] = rule
+	}
+	return compiled, nil
+}
+
+// SetFilterRules replaces the filter rules of an instance, evaluated in order
+// for every incoming message before it's stored or published
+func (m *Manager) SetFilterRules(instanceID string, rules []FilterRule) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	inst.mu.RLock()
+	keepFilterRuleSecrets(rules, inst.FilterRules)
+	inst.mu.RUnlock()
+	compiled, err := m.compileFilterRules(rules)
+	if err != nil {
+		return err
+	}
+
+	inst.mu.Lock()
+	inst.FilterRules = compiled
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	log.Info().Str("instanceId", instanceID).Int("rules", len(compiled)).Msg("Updated filter rules")
+	return nil
+}
+
+// filterMessage applies the filter rules of an instance to a message. Every
+// matching rule applies; a drop ends the evaluation.
+func (m *Manager) filterMessage(inst *Instance, msg MessageData) filterResult {
+	inst.mu.RLock()
+	rules := inst.FilterRules
+	inst.mu.RUnlock()
+
+	var result filterResult
+	for _, rule := range rules {
+		if !m.filterMatches(rule, msg) {
+			continue
+		}
+		switch rule.Action {
+		case FilterDrop:
+			result.drop = true
+			return result
+		case FilterTag:
+			for _, tag := range rule.Tags {
+				if !slices.Contains(result.tags, tag) {
+					result.tags = append(result.tags, tag)
+				}
+			}
+		case FilterRead:
+			result.read = true
+		case FilterWebhook:
+			if result.webhookURL == "" {
+				result.webhookURL = rule.WebhookURL
+			}
+		}
+	}
+	return result
+}
+
+// filterMatches reports whether a message meets every condition of a rule
+func (m *Manager) filterMatches(rule FilterRule, msg MessageData) bool {
+	if rule.IsGroup != nil && *rule.IsGroup != msg.IsGroup {
+		return false
+	}
+	if rule.FromMe != nil && *rule.FromMe != msg.FromMe {
+		return false
+	}
+	if len(rule.Types) > 0 && !slices.Contains(rule.Types, msg.Type) {
+		return false
+	}
+	if len(rule.Chats) > 0 {
+		matched := false
+		for _, chat := range rule.Chats {
+			if m.canonicalChatID(chat) == msg.To {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rule.Senders) > 0 {
+		sender := jidUser(msg.From)
+		if !slices.Contains(rule.Senders, sender) && (msg.ResolvedPhone == "" || !slices.Contains(rule.Senders, msg.ResolvedPhone)) {
+			return false
+		}
+	}
+	if rule.body != nil {
+		text := msg.Body
+		if text == "" {
+			text = msg.Caption
+		}
+		if !rule.body.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterWebhookSecret returns the secret of the rule routing events to a
+// webhook ("" when no rule does anymore)
+func filterWebhookSecret(rules []FilterRule, webhookURL string) string {
+	for _, rule := range rules {
+		if rule.Action == FilterWebhook && rule.WebhookURL == webhookURL {
+			return rule.WebhookSecret
+		}
+	}
+	return ""
+}
+
+// jidUser returns the user part of a JID without the device, or s itself
+// when it's a bare number
+func jidUser(s string) string {
+	user, _, _ := strings.Cut(s, "@")
+	user, _, _ = strings.Cut(user, ":")
+	return user
+}
@@ -0,0 +1,168 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// parseGroupJID parses a group JID, accepting the bare ID without @g.us
+func parseGroupJID(group string) (types.JID, error) {
+	if !strings.Contains(group, "@") {
+		group = group + "@" + types.GroupServer
+	}
+	jid, err := types.ParseJID(group)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	if jid.Server != types.GroupServer {
+		return types.JID{}, fmt.Errorf("%w: %s is not a group", ErrInvalidJID, group)
+	}
+	return jid, nil
+}
+
+// GetGroupInfo gets the full info of a group, including its participants
+func (m *Manager) GetGroupInfo(instanceID, group string) (*GroupInfo, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(group)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := client.GetGroupInfo(context.Background(), jid)
+	if errors.Is(err, whatsmeow.ErrGroupNotFound) || errors.Is(err, whatsmeow.ErrNotInGroup) {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, jid)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get group info: %w", err)
+	}
+
+	result := formatGroupInfo(info)
+
+	// Only admins may read the invite link
+	if link, err := client.GetGroupInviteLink(context.Background(), jid, false); err == nil {
+		result.InviteCode = strings.TrimPrefix(link, whatsmeow.InviteLinkPrefix)
+	} else {
+		log.Debug().Err(err).Str("instanceId", instanceID).Str("group", jid.String()).Msg("Invite link not available")
+	}
+
+	return result, nil
+}
+
+// formatGroupInfo converts whatsmeow group info to the API representation
+func formatGroupInfo(info *types.GroupInfo) *GroupInfo {
+	result := &GroupInfo{
+		JID:                  info.JID.String(),
+		Name:                 info.Name,
+		Description:          info.Topic,
+		Participants:         make([]GroupParticipant, 0, len(info.Participants)),
+		ParticipantCount:     len(info.Participants),
+		IsAnnounce:           info.IsAnnounce,
+		IsLocked:             info.IsLocked,
+		JoinApprovalRequired: info.IsJoinApprovalRequired,
+	}
+	if !info.OwnerJID.IsEmpty() {
+		result.Owner = info.OwnerJID.String()
+	}
+	if !info.GroupCreated.IsZero() {
+		result.CreatedAt = info.GroupCreated.Unix()
+	}
+	switch info.MemberAddMode {
+	case types.GroupMemberAddModeAdmin:
+		result.MemberAddMode = GroupMemberAddAdmins
+	case types.GroupMemberAddModeAllMember:
+		result.MemberAddMode = GroupMemberAddAll
+	}
+	if info.IsEphemeral {
+		result.EphemeralTimer = info.DisappearingTimer
+	}
+
+	for _, p := range info.Participants {
+		participant := GroupParticipant{
+			JID:          p.JID.String(),
+			IsAdmin:      p.IsAdmin || p.IsSuperAdmin,
+			IsSuperAdmin: p.IsSuperAdmin,
+		}
+		if !p.PhoneNumber.IsEmpty() {
+			participant.Phone = p.PhoneNumber.User
+		} else if p.JID.Server == types.DefaultUserServer {
+			participant.Phone = p.JID.User
+		}
+		result.Participants = append(result.Participants, participant)
+	}
+	return result
+}
+
+// Who may add members to a group
+const (
+	GroupMemberAddAdmins = "admins"
+	GroupMemberAddAll    = "all"
+)
+
+// GroupSettings are group settings to change; nil fields are left as they are
+type GroupSettings struct {
+	JoinApprovalRequired *bool   // Admins approve join requests
+	MemberAddMode        *string // admins or all
+}
+
+// SetGroupSettings changes the join approval and member add mode of a group,
+// which requires the instance to be an admin, and returns the updated group
+func (m *Manager) SetGroupSettings(instanceID, group string, settings GroupSettings) (*GroupInfo, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(group)
+	if err != nil {
+		return nil, err
+	}
+
+	var addMode types.GroupMemberAddMode
+	if settings.MemberAddMode != nil {
+		switch *settings.MemberAddMode {
+		case GroupMemberAddAdmins:
+			addMode = types.GroupMemberAddModeAdmin
+		case GroupMemberAddAll:
+			addMode = types.GroupMemberAddModeAllMember
+		default:
+			return nil, fmt.Errorf("%w: memberAddMode must be admins or all", ErrInvalidInput)
+		}
+	}
+
+	if settings.JoinApprovalRequired != nil {
+		err := client.SetGroupJoinApprovalMode(context.Background(), jid, *settings.JoinApprovalRequired)
+		if err != nil {
+			return nil, groupSettingError(jid, "join approval", err)
+		}
+	}
+	if addMode != "" {
+		if err := client.SetGroupMemberAddMode(context.Background(), jid, addMode); err != nil {
+			return nil, groupSettingError(jid, "member add mode", err)
+		}
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("group", jid.String()).Msg("Updated group settings")
+	return m.GetGroupInfo(instanceID, jid.String())
+}
+
+// groupSettingError maps the error of a group setting change to the group
+// sentinel errors
+func groupSettingError(jid types.JID, setting string, err error) error {
+	switch {
+	case errors.Is(err, whatsmeow.ErrIQNotFound):
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, jid)
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAuthorized):
+		return fmt.Errorf("%w: %s", ErrNotGroupAdmin, jid)
+	default:
+		return fmt.Errorf("failed to set group %s: %w", setting, err)
+	}
+}
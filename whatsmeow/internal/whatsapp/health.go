@@ -0,0 +1,131 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Longest a readiness check may take before it counts as failed
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck is the outcome of one readiness check
+type ReadinessCheck struct {
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// InstanceHealth is the connection state and last activity of an instance
+type InstanceHealth struct {
+	InstanceID     string `json:"instanceId"`
+	Status         string `json:"status"`
+	Connected      bool   `json:"connected"`
+	ConnectedSince int64  `json:"connectedSince,omitempty"`
+	LastActivityAt int64  `json:"lastActivityAt,omitempty"` // Unix time of the last journaled event
+	EventsDropped  uint64 `json:"eventsDropped"`            // Events that didn't fit in a subscriber's channel
+}
+
+// Ready checks that the databases answer and that the manager locks used by
+// event handling aren't held up, each within readinessTimeout
+func (m *Manager) Ready(ctx context.Context) (bool, map[string]ReadinessCheck) {
+	checks := map[string]ReadinessCheck{
+		"sessionStore": runReadinessCheck(ctx, func(ctx context.Context) error {
+			_, err := m.container.GetAllDevices(ctx)
+			return err
+		}),
+		"eventJournal": runReadinessCheck(ctx, m.eventsDB.PingContext),
+		"eventLoop":    runReadinessCheck(ctx, m.eventLoopResponsive),
+	}
+
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	return ready, checks
+}
+
+// runReadinessCheck runs a check with readinessTimeout
+func runReadinessCheck(ctx context.Context, check func(ctx context.Context) error) ReadinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := ReadinessCheck{OK: err == nil, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// eventLoopResponsive takes the locks every incoming event goes through; a
+// handler stuck while holding one of them blocks all instances. Only one probe
+// waits on the locks at a time, so probes timing out against a stuck lock
+// don't pile up goroutines.
+func (m *Manager) eventLoopResponsive(ctx context.Context) error {
+	m.eventLoopProbeMu.Lock()
+	done := m.eventLoopProbe
+	if done == nil {
+		done = make(chan struct{})
+		m.eventLoopProbe = done
+		go func() {
+			m.mu.RLock()
+			m.mu.RUnlock()
+			m.eventSubsMu.RLock()
+			m.eventSubsMu.RUnlock()
+			m.messagesMu.RLock()
+			m.messagesMu.RUnlock()
+
+			m.eventLoopProbeMu.Lock()
+			m.eventLoopProbe = nil
+			m.eventLoopProbeMu.Unlock()
+			close(done)
+		}()
+	}
+	m.eventLoopProbeMu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event handling locks held for over %s", readinessTimeout)
+	}
+}
+
+// InstancesHealth returns the connection state and last activity of every
+// instance, sorted by ID
+func (m *Manager) InstancesHealth() ([]InstanceHealth, error) {
+	m.mu.RLock()
+	instances := make([]*Instance, 0, len(m.instances))
+	for _, inst := range m.instances {
+		instances = append(instances, inst)
+	}
+	m.mu.RUnlock()
+
+	lastEvents, err := m.journal.LastEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]InstanceHealth, 0, len(instances))
+	for _, inst := range instances {
+		inst.mu.RLock()
+		health := InstanceHealth{
+			InstanceID:     inst.ID,
+			Status:         inst.Status,
+			Connected:      inst.Status == "connected",
+			LastActivityAt: lastEvents[inst.ID].Timestamp,
+			EventsDropped:  m.instanceEventDrops(inst.ID),
+		}
+		if health.Connected && !inst.connectedAt.IsZero() {
+			health.ConnectedSince = inst.connectedAt.Unix()
+		}
+		inst.mu.RUnlock()
+		result = append(result, health)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].InstanceID < result[j].InstanceID })
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package whatsapp
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+)
+
+// Quality of recompressed images when only a max dimension is set
+const defaultImageQuality = 80
+
+// ImageCompression downscales and recompresses images before they're
+// uploaded. Zero values leave images as they are.
+type ImageCompression struct {
+	MaxDimension int `json:"maxDimension,omitempty"` // Longest edge in pixels (0 = keep the size)
+	Quality      int `json:"quality,omitempty"`      // JPEG quality 1-100 (0 = keep the encoding unless resized)
+}
+
+// isZero reports whether images are sent untouched
+func (c ImageCompression) isZero() bool {
+	return c.MaxDimension == 0 && c.Quality == 0
+}
+
+// validate checks the dimension and quality ranges
+func (c ImageCompression) validate() error {
+	if c.MaxDimension < 0 {
+		return fmt.Errorf("%w: maxDimension must be >= 0", ErrInvalidInput)
+	}
+	if c.Quality < 0 || c.Quality > 100 {
+		return fmt.Errorf("%w: quality must be between 1 and 100", ErrInvalidInput)
+	}
+	return nil
+}
+
+// SetImageCompression sets how outbound images are compressed when a send
+// doesn't say
+func (m *Manager) SetImageCompression(compression ImageCompression) error {
+	if err := compression.validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.imageCompression = compression
+	m.mu.Unlock()
+	return nil
+}
+
+// imageCompressionFor returns the compression of a send, falling back to the
+// server setting for the values it leaves out
+func (m *Manager) imageCompressionFor(opts MediaOptions) ImageCompression {
+	m.mu.RLock()
+	compression := m.imageCompression
+	m.mu.RUnlock()
+
+	if opts.MaxDimension > 0 {
+		compression.MaxDimension = opts.MaxDimension
+	}
+	if opts.Quality > 0 {
+		compression.Quality = opts.Quality
+	}
+	return compression
+}
+
+// compressImage downscales and re-encodes an image as JPEG, upright by its EXIF
+// orientation. The original is returned when compression is off, the format
+// isn't a still image or the result wouldn't be smaller.
+func compressImage(data []byte, mimeType string, c ImageCompression) ([]byte, string, error) {
+	if c.isZero() {
+		return data, mimeType, nil
+	}
+	// GIFs may be animated and would lose their frames
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp":
+	default:
+		return data, mimeType, nil
+	}
+
+	src, err := decodeImage(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := src.Bounds()
+	resize := c.MaxDimension > 0 && (bounds.Dx() > c.MaxDimension || bounds.Dy() > c.MaxDimension)
+	if !resize && c.Quality == 0 {
+		return data, mimeType, nil
+	}
+
+	size := max(bounds.Dx(), bounds.Dy())
+	if resize {
+		size = c.MaxDimension
+	}
+	quality := c.Quality
+	if quality == 0 {
+		quality = defaultImageQuality
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(src, size), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	if !resize && buf.Len() >= len(data) {
+		return data, mimeType, nil
+	}
+
+	return buf.Bytes(), "image/jpeg", nil
+}
@@ -0,0 +1,239 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Maximum number of quick reply buttons WhatsApp renders in a buttons message
+const maxButtons = 3
+
+// Button represents a quick reply button
+type Button struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// ListRow represents a selectable row of a list message
+type ListRow struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// ListSection groups rows of a list message under a title
+type ListSection struct {
+	Title string    `json:"title"`
+	Rows  []ListRow `json:"rows"`
+}
+
+// InteractiveResponse represents a button or list reply received from a contact
+type InteractiveResponse struct {
+	Type        string `json:"type"` // button, list, template_button, native_flow
+	SelectedID  string `json:"selectedId,omitempty"`
+	DisplayText string `json:"displayText,omitempty"`
+	Description string `json:"description,omitempty"`
+	Params      string `json:"params,omitempty"` // Raw JSON params of native flow replies
+	QuotedID    string `json:"quotedId,omitempty"`
+}
+
+// resolveRecipient checks the instance is connected and parses the destination JID
+func (m *Manager) resolveRecipient(instanceID, to string) (*Instance, types.JID, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, types.JID{}, ErrInstanceNotFound
+	}
+
+	inst.mu.RLock()
+	status := inst.Status
+	inst.mu.RUnlock()
+
+	if status != "connected" {
+		return nil, types.JID{}, ErrNotConnected
+	}
+
+	// Clean phone number
+	to, err := m.normalizeRecipient(to)
+	if err != nil {
+		return nil, types.JID{}, err
+	}
+
+	// Ensure it has @s.whatsapp.net suffix
+	if !strings.Contains(to, "@") {
+		to = to + "@s.whatsapp.net"
+	}
+
+	jid, err := types.ParseJID(to)
+	if err != nil {
+		return nil, types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	return inst, jid, nil
+}
+
+// SendButtonsMessage sends a text message with up to 3 quick reply buttons
+func (m *Manager) SendButtonsMessage(instanceID, to, text, footer, header string, buttons []Button) (string, error) {
+	if len(buttons) == 0 || len(buttons) > maxButtons {
+		return "", fmt.Errorf("%w: between 1 and %d buttons are required", ErrInvalidInput, maxButtons)
+	}
+
+	inst, jid, err := m.resolveRecipient(instanceID, to)
+	if err != nil {
+		return "", err
+	}
+
+	msgButtons := make([]*waE2E.ButtonsMessage_Button, 0, len(buttons))
+	for i, b := range buttons {
+		if b.Text == "" {
+			return "", fmt.Errorf("%w: buttons[%d] requires text", ErrInvalidInput, i)
+		}
+		id := b.ID
+		if id == "" {
+			id = fmt.Sprintf("btn_%d", i+1)
+		}
+		msgButtons = append(msgButtons, &waE2E.ButtonsMessage_Button{
+			ButtonID:   proto.String(id),
+			ButtonText: &waE2E.ButtonsMessage_Button_ButtonText{DisplayText: proto.String(b.Text)},
+			Type:       waE2E.ButtonsMessage_Button_RESPONSE.Enum(),
+		})
+	}
+
+	buttonsMsg := &waE2E.ButtonsMessage{
+		ContentText: proto.String(text),
+		Buttons:     msgButtons,
+		HeaderType:  waE2E.ButtonsMessage_EMPTY.Enum(),
+	}
+	if footer != "" {
+		buttonsMsg.FooterText = proto.String(footer)
+	}
+	if header != "" {
+		buttonsMsg.HeaderType = waE2E.ButtonsMessage_TEXT.Enum()
+		buttonsMsg.Header = &waE2E.ButtonsMessage_Text{Text: header}
+	}
+
+	log.Info().
+		Str("instanceId", instanceID).
+		Str("to", jid.String()).
+		Int("buttons", len(buttons)).
+		Msg("Sending buttons message")
+
+	resp, err := m.sendTracked(context.Background(), instanceID, inst.Client, jid, &waE2E.Message{ButtonsMessage: buttonsMsg})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return resp.ID, nil
+}
+
+// SendListMessage sends a single-select list message
+func (m *Manager) SendListMessage(instanceID, to, title, description, buttonText, footer string, sections []ListSection) (string, error) {
+	if len(sections) == 0 {
+		return "", fmt.Errorf("%w: at least one section is required", ErrInvalidInput)
+	}
+
+	inst, jid, err := m.resolveRecipient(instanceID, to)
+	if err != nil {
+		return "", err
+	}
+
+	msgSections := make([]*waE2E.ListMessage_Section, 0, len(sections))
+	rowCount := 0
+	for i, s := range sections {
+		if len(s.Rows) == 0 {
+			return "", fmt.Errorf("%w: sections[%d] requires at least one row", ErrInvalidInput, i)
+		}
+		rows := make([]*waE2E.ListMessage_Row, 0, len(s.Rows))
+		for j, r := range s.Rows {
+			if r.Title == "" {
+				return "", fmt.Errorf("%w: sections[%d].rows[%d] requires title", ErrInvalidInput, i, j)
+			}
+			rowCount++
+			id := r.ID
+			if id == "" {
+				id = fmt.Sprintf("row_%d", rowCount)
+			}
+			row := &waE2E.ListMessage_Row{
+				RowID: proto.String(id),
+				Title: proto.String(r.Title),
+			}
+			if r.Description != "" {
+				row.Description = proto.String(r.Description)
+			}
+			rows = append(rows, row)
+		}
+		msgSections = append(msgSections, &waE2E.ListMessage_Section{
+			Title: proto.String(s.Title),
+			Rows:  rows,
+		})
+	}
+
+	listMsg := &waE2E.ListMessage{
+		Title:       proto.String(title),
+		Description: proto.String(description),
+		ButtonText:  proto.String(buttonText),
+		ListType:    waE2E.ListMessage_SINGLE_SELECT.Enum(),
+		Sections:    msgSections,
+	}
+	if footer != "" {
+		listMsg.FooterText = proto.String(footer)
+	}
+
+	log.Info().
+		Str("instanceId", instanceID).
+		Str("to", jid.String()).
+		Int("sections", len(sections)).
+		Int("rows", rowCount).
+		Msg("Sending list message")
+
+	resp, err := m.sendTracked(context.Background(), instanceID, inst.Client, jid, &waE2E.Message{ListMessage: listMsg})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return resp.ID, nil
+}
+
+// parseInteractiveResponse extracts a button or list reply from an incoming message
+func parseInteractiveResponse(msg *waE2E.Message) *InteractiveResponse {
+	if r := msg.GetButtonsResponseMessage(); r != nil {
+		return &InteractiveResponse{
+			Type:        "button",
+			SelectedID:  r.GetSelectedButtonID(),
+			DisplayText: r.GetSelectedDisplayText(),
+			QuotedID:    r.GetContextInfo().GetStanzaID(),
+		}
+	}
+	if r := msg.GetListResponseMessage(); r != nil {
+		return &InteractiveResponse{
+			Type:        "list",
+			SelectedID:  r.GetSingleSelectReply().GetSelectedRowID(),
+			DisplayText: r.GetTitle(),
+			Description: r.GetDescription(),
+			QuotedID:    r.GetContextInfo().GetStanzaID(),
+		}
+	}
+	if r := msg.GetTemplateButtonReplyMessage(); r != nil {
+		return &InteractiveResponse{
+			Type:        "template_button",
+			SelectedID:  r.GetSelectedID(),
+			DisplayText: r.GetSelectedDisplayText(),
+			QuotedID:    r.GetContextInfo().GetStanzaID(),
+		}
+	}
+	if r := msg.GetInteractiveResponseMessage(); r != nil {
+		flow := r.GetNativeFlowResponseMessage()
+		return &InteractiveResponse{
+			Type:        "native_flow",
+			SelectedID:  flow.GetName(),
+			DisplayText: r.GetBody().GetText(),
+			Params:      flow.GetParamsJSON(),
+			QuotedID:    r.GetContextInfo().GetStanzaID(),
+		}
+	}
+	return nil
+}
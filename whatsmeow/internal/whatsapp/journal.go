@@ -0,0 +1,148 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Events older than this are pruned from the journal
+const eventRetention = 24 * time.Hour
+
+// EventJournal persists published events with a monotonically increasing
+// sequence number so consumers can fetch what they missed
+type EventJournal struct {
+	db *sql.DB
+}
+
+const eventJournalSchema = `
+	CREATE TABLE IF NOT EXISTS events (
+		seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+		instance_id TEXT    NOT NULL,
+		type        TEXT    NOT NULL,
+		data        TEXT,
+		timestamp   INTEGER NOT NULL,
+		webhook_url TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_events_instance_seq ON events (instance_id, seq);
+`
+
+// NewEventJournal keeps the journal in the events table of db and starts
+// pruning events older than eventRetention
+func NewEventJournal(db *sql.DB) *EventJournal {
+	j := &EventJournal{db: db}
+	go j.pruneLoop()
+	return j
+}
+
+// Append stores an event and returns its sequence number
+func (j *EventJournal) Append(evt Event) (int64, error) {
+	data, err := json.Marshal(evt.Data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	res, err := j.db.Exec(
+		`INSERT INTO events (instance_id, type, data, timestamp, webhook_url) VALUES (?, ?, ?, ?, ?)`,
+		evt.InstanceID, evt.Type, string(data), evt.Timestamp, sql.NullString{String: evt.webhookURL, Valid: evt.webhookURL != ""},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to append event: %w", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// After returns up to limit events for an instance with a sequence number greater than cursor
+func (j *EventJournal) After(ctx context.Context, instanceID string, cursor int64, limit int) ([]Event, error) {
+	rows, err := j.db.QueryContext(ctx,
+		`SELECT seq, type, data, timestamp, webhook_url FROM events WHERE instance_id = ? AND seq > ? ORDER BY seq LIMIT ?`,
+		instanceID, cursor, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var evt Event
+		var data, webhookURL sql.NullString
+		if err := rows.Scan(&evt.ID, &evt.Type, &data, &evt.Timestamp, &webhookURL); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		evt.InstanceID = instanceID
+		evt.webhookURL = webhookURL.String
+		if data.Valid && data.String != "" && data.String != "null" {
+			// Decoded rather than kept raw, so MessagePack streams encode it as a
+			// structure instead of a string of JSON
+			if err := json.Unmarshal([]byte(data.String), &evt.Data); err != nil {
+				return nil, fmt.Errorf("failed to decode event %d: %w", evt.ID, err)
+			}
+		}
+		events = append(events, evt)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteInstance removes all events of an instance
+func (j *EventJournal) DeleteInstance(instanceID string) error {
+	if _, err := j.db.Exec(`DELETE FROM events WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance events: %w", err)
+	}
+	return nil
+}
+
+// pruneLoop periodically removes events older than eventRetention
+func (j *EventJournal) pruneLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-eventRetention).Unix()
+		res, err := j.db.Exec(`DELETE FROM events WHERE timestamp < ?`, cutoff)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to prune event journal")
+			continue
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Info().Int64("pruned", n).Msg("Pruned old events from journal")
+		}
+	}
+}
+
+// EventsAfter returns up to limit journaled events of an instance after cursor
+func (m *Manager) EventsAfter(ctx context.Context, instanceID string, cursor int64, limit int) ([]Event, error) {
+	return m.journal.After(ctx, instanceID, cursor, limit)
+}
+
+// PollEvents returns journaled events after cursor, waiting up to timeout for
+// new ones to arrive when none are pending (HTTP long polling)
+func (m *Manager) PollEvents(ctx context.Context, instanceID string, cursor int64, limit int, timeout time.Duration) ([]Event, error) {
+	// Subscribe before querying so nothing published in between is missed
+	ch := m.Subscribe(instanceID)
+	defer m.Unsubscribe(instanceID, ch)
+
+	events, err := m.journal.After(ctx, instanceID, cursor, limit)
+	if err != nil || len(events) > 0 || timeout <= 0 {
+		return events, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		// Read back from the journal so ordering and payloads match the cursor
+		return m.journal.After(ctx, instanceID, cursor, limit)
+	case <-timer.C:
+		return events, nil
+	case <-ctx.Done():
+		return events, nil
+	}
+}
@@ -0,0 +1,75 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Events published concurrently reach subscribers in sequence order, so a
+// consumer resuming after the last ID it saw gets every later event
+func TestPublishConcurrentResume(t *testing.T) {
+	m, err := NewManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Shutdown(context.Background())
+
+	ch := m.SubscribeStream("inst", "test")
+
+	// Events that don't fit the subscriber's buffer are skipped (stream
+	// consumers read those back from the journal); the ones delivered must
+	// still be in order
+	var received []int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for evt := range ch {
+			received = append(received, evt.ID)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for p := 0; p < 16; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				m.publishEvent(Event{Type: "test", InstanceID: "inst", Data: fmt.Sprintf("%d-%d", p, i)})
+			}
+		}()
+	}
+	wg.Wait()
+	m.Unsubscribe("inst", ch)
+	<-done
+
+	if len(received) == 0 {
+		t.Fatal("no events were delivered")
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i] <= received[i-1] {
+			t.Fatalf("event %d delivered after %d", received[i], received[i-1])
+		}
+	}
+
+	// Resuming after any delivered event returns every later one
+	for _, cut := range []int{0, len(received) / 2, len(received) - 1} {
+		missed, err := m.EventsAfter(context.Background(), "inst", received[cut], 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumed := make(map[int64]bool, len(missed))
+		for _, evt := range missed {
+			if evt.ID <= received[cut] {
+				t.Fatalf("resuming after %d returned event %d", received[cut], evt.ID)
+			}
+			resumed[evt.ID] = true
+		}
+		for _, id := range received[cut+1:] {
+			if !resumed[id] {
+				t.Fatalf("resuming after %d skipped event %d", received[cut], id)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package whatsapp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Timeout of the user info query that looks up the LIDs of checked numbers
+const lidLookupTimeout = 15 * time.Second
+
+// lidMap is the persistent LID <-> phone number mapping, learned from
+// messages, receipts and number checks. It backs up Store.LIDs, which only
+// knows the pairs whatsmeow happened to see for one device.
+type lidMap struct {
+	mu    sync.RWMutex
+	toPN  map[string]string // LID user -> phone number user
+	toLID map[string]string // phone number user -> LID user
+	store *lidStore
+}
+
+// newLIDMap loads the mapping stored in events.db
+func newLIDMap(store *lidStore) *lidMap {
+	l := &lidMap{
+		toPN:  make(map[string]string),
+		toLID: make(map[string]string),
+		store: store,
+	}
+	err := store.LIDMappings(func(lid, pn string) {
+		l.toPN[lid] = pn
+		l.toLID[pn] = lid
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load LID mappings")
+	}
+	return l
+}
+
+// put records that lid and pn are the same user, in either order
+func (l *lidMap) put(first, second types.JID) {
+	lid, pn := first, second
+	if lid.Server != types.HiddenUserServer {
+		lid, pn = pn, lid
+	}
+	if lid.Server != types.HiddenUserServer || pn.Server != types.DefaultUserServer || lid.User == "" || pn.User == "" {
+		return
+	}
+
+	l.mu.Lock()
+	if l.toPN[lid.User] == pn.User && l.toLID[pn.User] == lid.User {
+		l.mu.Unlock()
+		return
+	}
+	if oldLID, ok := l.toLID[pn.User]; ok {
+		delete(l.toPN, oldLID)
+	}
+	if oldPN, ok := l.toPN[lid.User]; ok {
+		delete(l.toLID, oldPN)
+	}
+	l.toPN[lid.User] = pn.User
+	l.toLID[pn.User] = lid.User
+	l.mu.Unlock()
+
+	if err := l.store.PutLIDMapping(lid.User, pn.User); err != nil {
+		log.Warn().Err(err).Str("lid", lid.User).Msg("Failed to persist LID mapping")
+		return
+	}
+	log.Debug().Str("lid", lid.User).Str("phone", pn.User).Msg("Learned LID mapping")
+}
+
+// phone returns the phone number user behind a LID user
+func (l *lidMap) phone(lid string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.toPN[lid]
+}
+
+// lid returns the LID user of a phone number user
+func (l *lidMap) lid(pn string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.toLID[pn]
+}
+
+// learnFromSource records the alternate addresses carried by a message or
+// receipt
+func (m *Manager) learnFromSource(src types.MessageSource) {
+	if !src.SenderAlt.IsEmpty() {
+		m.lids.put(src.Sender.ToNonAD(), src.SenderAlt.ToNonAD())
+	}
+	if !src.RecipientAlt.IsEmpty() && !src.IsGroup {
+		m.lids.put(src.Chat.ToNonAD(), src.RecipientAlt.ToNonAD())
+	}
+}
+
+// learnLIDs looks up the LIDs of numbers confirmed by IsOnWhatsApp, unless
+// they're already known. Runs in the background so sends aren't delayed.
+func (m *Manager) learnLIDs(client *whatsmeow.Client, pns ...types.JID) {
+	unknown := make([]types.JID, 0, len(pns))
+	for _, pn := range pns {
+		if pn.Server == types.DefaultUserServer && m.lids.lid(pn.User) == "" {
+			unknown = append(unknown, pn)
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), lidLookupTimeout)
+		defer cancel()
+
+		users, err := client.GetUserInfo(ctx, unknown)
+		if err != nil {
+			log.Debug().Err(err).Int("numbers", len(unknown)).Msg("Failed to look up LIDs")
+			return
+		}
+		for pn, info := range users {
+			if !info.LID.IsEmpty() {
+				m.lids.put(info.LID, pn)
+			}
+		}
+	}()
+}
+
+// resolveLID returns the phone number user behind a LID, from Store.LIDs or
+// the persistent mapping
+func (m *Manager) resolveLID(client *whatsmeow.Client, lid types.JID) string {
+	if client != nil && client.Store != nil && client.Store.LIDs != nil {
+		pnJID, err := client.Store.LIDs.GetPNForLID(context.Background(), lid)
+		if err == nil && pnJID.User != "" {
+			m.lids.put(lid, pnJID)
+			return pnJID.User
+		}
+	}
+	return m.lids.phone(lid.User)
+}
+
+// lidForPhone returns the LID user of a phone number, from Store.LIDs or the
+// persistent mapping
+func (m *Manager) lidForPhone(client *whatsmeow.Client, pn types.JID) string {
+	if client != nil && client.Store != nil && client.Store.LIDs != nil {
+		lidJID, err := client.Store.LIDs.GetLIDForPN(context.Background(), pn)
+		if err == nil && lidJID.User != "" {
+			m.lids.put(lidJID, pn)
+			return lidJID.User
+		}
+	}
+	return m.lids.lid(pn.User)
+}
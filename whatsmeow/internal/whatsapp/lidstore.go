@@ -0,0 +1,56 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const lidMapSchema = `
+	CREATE TABLE IF NOT EXISTS lid_map (
+		lid        TEXT    PRIMARY KEY,
+		pn         TEXT    NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_lid_map_pn ON lid_map (pn);
+`
+
+// lidStore keeps the LID <-> phone number mapping in events.db
+type lidStore struct {
+	db *sql.DB
+}
+
+// PutLIDMapping records the phone number user behind a LID user
+func (s *lidStore) PutLIDMapping(lid, pn string) error {
+	// A phone number has one LID; drop a stale pairing before the new one
+	if _, err := s.db.Exec(`DELETE FROM lid_map WHERE pn = ? AND lid <> ?`, pn, lid); err != nil {
+		return fmt.Errorf("failed to store LID mapping: %w", err)
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO lid_map (lid, pn, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT (lid) DO UPDATE SET pn = excluded.pn, updated_at = excluded.updated_at`,
+		lid, pn, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store LID mapping: %w", err)
+	}
+	return nil
+}
+
+// LIDMappings calls fn for every stored LID mapping
+func (s *lidStore) LIDMappings(fn func(lid, pn string)) error {
+	rows, err := s.db.Query(`SELECT lid, pn FROM lid_map`)
+	if err != nil {
+		return fmt.Errorf("failed to query LID mappings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lid, pn string
+		if err := rows.Scan(&lid, &pn); err != nil {
+			return fmt.Errorf("failed to scan LID mapping: %w", err)
+		}
+		fn(lid, pn)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,259 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+	"whatsmeow-service/internal/tracing"
+)
+
+// Longest edge of the thumbnail embedded in link previews
+const linkPreviewThumbnailSize = 160
+
+// LinkPreview holds Open Graph metadata for a URL
+type LinkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	SiteName    string
+	ImageURL    string
+	Video       bool // The page is a video (og:video or an og:type of video.*)
+	Thumbnail   []byte
+}
+
+// PreviewOptions controls the link preview of a text message. By default the
+// first URL of the text is previewed with the metadata of its page; a custom
+// title, description or image is used instead of fetching the page.
+type PreviewOptions struct {
+	Disabled    bool   `json:"disabled,omitempty"` // Send without a preview
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+}
+
+// custom reports whether the preview is given instead of fetched
+func (o PreviewOptions) custom() bool {
+	return o.Title != "" || o.Description != "" || o.ImageURL != ""
+}
+
+// urlRegex matches http/https URLs
+var urlRegex = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// extractFirstURL finds the first URL in text
+func extractFirstURL(text string) string {
+	match := urlRegex.FindString(text)
+	return match
+}
+
+// fetchLinkPreview fetches Open Graph metadata from a URL
+func fetchLinkPreview(fetcher *previewFetcher, targetURL string) (*LinkPreview, error) {
+	client := fetcher.client(10 * time.Second)
+
+	req, err := http.NewRequest("GET", targetURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsApp/2.23; +http://www.whatsapp.com)")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// Read at most 1MB; the metadata is in the head
+	meta, title := parseHTMLHead(io.LimitReader(resp.Body, 1024*1024))
+
+	preview := &LinkPreview{
+		URL:         targetURL,
+		Title:       firstNonEmpty(meta["og:title"], meta["twitter:title"], title),
+		Description: firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    meta["og:site_name"],
+		ImageURL:    firstNonEmpty(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"]),
+		Video:       meta["og:video"] != "" || meta["og:video:url"] != "" || strings.HasPrefix(meta["og:type"], "video"),
+	}
+
+	// Make the image URL absolute, relative to the page after redirects
+	if preview.ImageURL != "" {
+		if imgURL, err := resp.Request.URL.Parse(preview.ImageURL); err == nil {
+			preview.ImageURL = imgURL.String()
+		}
+		preview.Thumbnail = previewThumbnail(fetcher, preview.ImageURL)
+	}
+
+	return preview, nil
+}
+
+// parseHTMLHead reads the <meta> tags and <title> of an HTML document, up to
+// its body. Meta tags are keyed by their lowercased property or name; the
+// first of each wins.
+func parseHTMLHead(r io.Reader) (map[string]string, string) {
+	meta := make(map[string]string)
+	var title strings.Builder
+	inTitle, seenTitle := false, false
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return meta, strings.TrimSpace(title.String())
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			switch token.DataAtom {
+			case atom.Meta:
+				var key, content string
+				for _, attr := range token.Attr {
+					switch attr.Key {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(attr.Val))
+						}
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				if _, ok := meta[key]; key != "" && content != "" && !ok {
+					meta[key] = content
+				}
+			case atom.Title:
+				inTitle = tt == html.StartTagToken && !seenTitle
+				seenTitle = true
+			case atom.Body:
+				return meta, strings.TrimSpace(title.String())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return meta, strings.TrimSpace(title.String())
+			}
+
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		}
+	}
+}
+
+// previewThumbnail downloads an image and scales it down to a link preview
+// thumbnail. Returns nil when the image can't be fetched or decoded.
+func previewThumbnail(fetcher *previewFetcher, imageURL string) []byte {
+	data := downloadThumbnail(fetcher, imageURL)
+	if data == nil {
+		return nil
+	}
+	thumb, err := resizeToJPEG(data, linkPreviewThumbnailSize)
+	if err != nil {
+		log.Debug().Err(err).Str("url", imageURL).Msg("Failed to build link preview thumbnail")
+		return nil
+	}
+	return thumb
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// textMessage builds a text message, with a preview of its first URL unless
+// opts disables it. A page that can't be fetched is sent as plain text.
+func (m *Manager) textMessage(ctx context.Context, inst *Instance, text string, opts PreviewOptions) *waE2E.Message {
+	plain := &waE2E.Message{Conversation: proto.String(text)}
+
+	foundURL := extractFirstURL(text)
+	if foundURL == "" || opts.Disabled {
+		return plain
+	}
+
+	fetcher, err := m.previewFetcher(inst)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Failed to build proxy transport, sending without link preview")
+		return plain
+	}
+
+	var preview *LinkPreview
+	if opts.custom() {
+		preview = &LinkPreview{
+			URL:         foundURL,
+			Title:       opts.Title,
+			Description: opts.Description,
+			ImageURL:    opts.ImageURL,
+		}
+		if opts.ImageURL != "" {
+			preview.Thumbnail = previewThumbnail(fetcher, opts.ImageURL)
+		}
+	} else {
+		log.Debug().Str("instanceId", inst.ID).Str("url", foundURL).Msg("URL detected, fetching link preview")
+
+		_, previewSpan := tracing.Start(ctx, "fetchLinkPreview", attribute.String("url.full", foundURL))
+		preview, err = fetchLinkPreview(fetcher, foundURL)
+		tracing.RecordError(previewSpan, err)
+		previewSpan.End()
+		if err != nil {
+			log.Warn().Err(err).Str("url", foundURL).Msg("Failed to fetch link preview, sending as plain text")
+			return plain
+		}
+		log.Info().Str("instanceId", inst.ID).Str("title", preview.Title).Str("url", foundURL).Msg("Link preview fetched successfully")
+	}
+
+	return linkPreviewMessage(text, foundURL, preview)
+}
+
+// linkPreviewMessage builds an extended text message previewing matchedURL.
+// Pages are previewed as links, with a thumbnail when there is one; videos
+// as videos, so the app offers to play them.
+func linkPreviewMessage(text, matchedURL string, preview *LinkPreview) *waE2E.Message {
+	extMsg := &waE2E.ExtendedTextMessage{
+		Text:        proto.String(text),
+		MatchedText: proto.String(matchedURL),
+		PreviewType: waE2E.ExtendedTextMessage_NONE.Enum(),
+	}
+	if preview.Video {
+		extMsg.PreviewType = waE2E.ExtendedTextMessage_VIDEO.Enum()
+	}
+
+	if preview.Title != "" {
+		extMsg.Title = proto.String(preview.Title)
+	}
+	if preview.Description != "" {
+		extMsg.Description = proto.String(preview.Description)
+	}
+	if len(preview.Thumbnail) > 0 {
+		extMsg.JPEGThumbnail = preview.Thumbnail
+		if config, err := jpeg.DecodeConfig(bytes.NewReader(preview.Thumbnail)); err == nil {
+			extMsg.ThumbnailWidth = proto.Uint32(uint32(config.Width))
+			extMsg.ThumbnailHeight = proto.Uint32(uint32(config.Height))
+		}
+	}
+
+	return &waE2E.Message{ExtendedTextMessage: extMsg}
+}
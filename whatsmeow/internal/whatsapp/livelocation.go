@@ -0,0 +1,296 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Live location sharing limits, matching the durations offered by the app
+const (
+	DefaultLiveLocationDuration = 15 * time.Minute
+	MaxLiveLocationDuration     = 8 * time.Hour
+)
+
+// LocationInfo is the position carried by a static or live location message
+type LocationInfo struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Live      bool    `json:"live,omitempty"`
+	Accuracy  uint32  `json:"accuracy,omitempty"` // Meters
+	Speed     float32 `json:"speed,omitempty"`    // Meters per second
+	Heading   uint32  `json:"heading,omitempty"`  // Degrees clockwise from magnetic north
+	Sequence  int64   `json:"sequence,omitempty"`
+}
+
+// LiveLocationUpdate is a new position sent during a live location session
+type LiveLocationUpdate struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  uint32  `json:"accuracy,omitempty"`
+	Speed     float32 `json:"speed,omitempty"`
+	Heading   uint32  `json:"heading,omitempty"`
+}
+
+// LiveLocationSession is a live location broadcast started by an instance
+type LiveLocationSession struct {
+	ID        string `json:"sessionId"`
+	To        string `json:"to"`
+	Caption   string `json:"caption,omitempty"`
+	Sequence  int64  `json:"sequence"`
+	StartedAt int64  `json:"startedAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+	MessageID string `json:"messageId"` // Message that started the session
+
+	jid   types.JID
+	last  LiveLocationUpdate // Latest position sent, repeated by the final update
+	timer *time.Timer
+}
+
+// liveLocations holds active live location sessions per instance
+type liveLocations struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]*LiveLocationSession // instanceID -> sessionID -> session
+}
+
+func newLiveLocations() *liveLocations {
+	return &liveLocations{
+		sessions: make(map[string]map[string]*LiveLocationSession),
+	}
+}
+
+// liveLocationMessage builds the message sent for a position of a session
+func liveLocationMessage(session *LiveLocationSession, update LiveLocationUpdate) *waE2E.Message {
+	return &waE2E.Message{
+		LiveLocationMessage: &waE2E.LiveLocationMessage{
+			DegreesLatitude:                   proto.Float64(update.Latitude),
+			DegreesLongitude:                  proto.Float64(update.Longitude),
+			AccuracyInMeters:                  proto.Uint32(update.Accuracy),
+			SpeedInMps:                        proto.Float32(update.Speed),
+			DegreesClockwiseFromMagneticNorth: proto.Uint32(update.Heading),
+			Caption:                           proto.String(session.Caption),
+			SequenceNumber:                    proto.Int64(session.Sequence),
+			TimeOffset:                        proto.Uint32(uint32(time.Now().Unix() - session.StartedAt)),
+		},
+	}
+}
+
+// StartLiveLocation starts sharing a live location with a contact. The session
+// ends automatically after duration unless stopped earlier.
+func (m *Manager) StartLiveLocation(instanceID, to, caption string, duration time.Duration, update LiveLocationUpdate) (*LiveLocationSession, error) {
+	if duration <= 0 {
+		duration = DefaultLiveLocationDuration
+	}
+	if duration > MaxLiveLocationDuration {
+		return nil, fmt.Errorf("%w: live location can be shared for at most %s", ErrInvalidInput, MaxLiveLocationDuration)
+	}
+
+	inst, jid, err := m.resolveRecipient(instanceID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	now := time.Now()
+	session := &LiveLocationSession{
+		ID:        hex.EncodeToString(id),
+		To:        jid.String(),
+		Caption:   caption,
+		Sequence:  1,
+		StartedAt: now.Unix(),
+		ExpiresAt: now.Add(duration).Unix(),
+		jid:       jid,
+		last:      update,
+	}
+
+	resp, err := m.sendTracked(context.Background(), instanceID, inst.Client, jid, liveLocationMessage(session, update))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+	session.MessageID = resp.ID
+
+	m.liveLocations.mu.Lock()
+	if m.liveLocations.sessions[instanceID] == nil {
+		m.liveLocations.sessions[instanceID] = make(map[string]*LiveLocationSession)
+	}
+	m.liveLocations.sessions[instanceID][session.ID] = session
+	session.timer = time.AfterFunc(duration, func() {
+		m.endLiveLocation(instanceID, session.ID, "expired")
+	})
+	result := *session
+	m.liveLocations.mu.Unlock()
+
+	log.Info().Str("instanceId", instanceID).Str("sessionId", session.ID).Str("to", session.To).Dur("duration", duration).Msg("Started live location")
+	return &result, nil
+}
+
+// UpdateLiveLocation sends a new position for an active session
+func (m *Manager) UpdateLiveLocation(instanceID, sessionID string, update LiveLocationUpdate) (*LiveLocationSession, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.liveLocations.mu.Lock()
+	session, ok := m.liveLocations.sessions[instanceID][sessionID]
+	if !ok {
+		m.liveLocations.mu.Unlock()
+		return nil, fmt.Errorf("%w: no active live location session %s", ErrInvalidInput, sessionID)
+	}
+	session.Sequence++
+	session.last = update
+	msg := liveLocationMessage(session, update)
+	jid := session.jid
+	result := *session
+	m.liveLocations.mu.Unlock()
+
+	if _, err := client.SendMessage(context.Background(), jid, msg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return &result, nil
+}
+
+// StopLiveLocation ends a session with a final update at the latest position,
+// so contacts see the share end now rather than when it would have expired.
+// No further updates are sent for it. While the instance is disconnected the
+// session just ends.
+func (m *Manager) StopLiveLocation(instanceID, sessionID string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+
+	if client, err := m.connectedClient(instanceID); err == nil {
+		m.liveLocations.mu.Lock()
+		session, ok := m.liveLocations.sessions[instanceID][sessionID]
+		if !ok {
+			m.liveLocations.mu.Unlock()
+			return fmt.Errorf("%w: no active live location session %s", ErrInvalidInput, sessionID)
+		}
+		session.Sequence++
+		msg := liveLocationMessage(session, session.last)
+		jid := session.jid
+		m.liveLocations.mu.Unlock()
+
+		if _, err := client.SendMessage(context.Background(), jid, msg); err != nil {
+			return fmt.Errorf("%w: %w", ErrSendFailed, err)
+		}
+	}
+
+	if !m.endLiveLocation(instanceID, sessionID, "stopped") {
+		return fmt.Errorf("%w: no active live location session %s", ErrInvalidInput, sessionID)
+	}
+	return nil
+}
+
+// GetLiveLocations lists the active live location sessions of an instance
+func (m *Manager) GetLiveLocations(instanceID string) ([]LiveLocationSession, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	m.liveLocations.mu.Lock()
+	defer m.liveLocations.mu.Unlock()
+
+	result := make([]LiveLocationSession, 0, len(m.liveLocations.sessions[instanceID]))
+	for _, session := range m.liveLocations.sessions[instanceID] {
+		result = append(result, *session)
+	}
+	return result, nil
+}
+
+// endLiveLocation removes a session and publishes live_location_stopped
+func (m *Manager) endLiveLocation(instanceID, sessionID, reason string) bool {
+	m.liveLocations.mu.Lock()
+	session, ok := m.liveLocations.sessions[instanceID][sessionID]
+	if ok {
+		session.timer.Stop()
+		delete(m.liveLocations.sessions[instanceID], sessionID)
+	}
+	m.liveLocations.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("sessionId", sessionID).Str("reason", reason).Msg("Live location ended")
+	m.publishEvent(Event{
+		Type:       "live_location_stopped",
+		InstanceID: instanceID,
+		Data: map[string]interface{}{
+			"sessionId": sessionID,
+			"to":        session.To,
+			"reason":    reason,
+			"sequence":  session.Sequence,
+			"live":      false,
+		},
+	})
+	return true
+}
+
+// dropLiveLocations ends all sessions of an instance without events
+func (m *Manager) dropLiveLocations(instanceID string) {
+	m.liveLocations.mu.Lock()
+	defer m.liveLocations.mu.Unlock()
+
+	for _, session := range m.liveLocations.sessions[instanceID] {
+		session.timer.Stop()
+	}
+	delete(m.liveLocations.sessions, instanceID)
+}
+
+// parseLocation extracts the position of a static or live location message
+func parseLocation(msg *waE2E.Message) *LocationInfo {
+	if loc := msg.GetLocationMessage(); loc != nil {
+		return &LocationInfo{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Name:      loc.GetName(),
+			Address:   loc.GetAddress(),
+			URL:       loc.GetURL(),
+			Live:      loc.GetIsLive(),
+		}
+	}
+	if live := msg.GetLiveLocationMessage(); live != nil {
+		return &LocationInfo{
+			Latitude:  live.GetDegreesLatitude(),
+			Longitude: live.GetDegreesLongitude(),
+			Name:      live.GetCaption(),
+			Live:      true,
+			Accuracy:  live.GetAccuracyInMeters(),
+			Speed:     live.GetSpeedInMps(),
+			Heading:   live.GetDegreesClockwiseFromMagneticNorth(),
+			Sequence:  live.GetSequenceNumber(),
+		}
+	}
+	return nil
+}
+
+// publishLiveLocation forwards a contact's live location update
+func (m *Manager) publishLiveLocation(inst *Instance, evt *events.Message, location *LocationInfo) {
+	m.publishEvent(Event{
+		Type:       "live_location",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"messageId": evt.Info.ID,
+			"chat":      m.canonicalChatID(evt.Info.Chat.String()),
+			"sender":    evt.Info.Sender.String(),
+			"fromMe":    evt.Info.IsFromMe,
+			"location":  location,
+			"timestamp": evt.Info.Timestamp.Unix(),
+		},
+	})
+}
@@ -0,0 +1,244 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Media download modes of an instance
+const (
+	MediaEager = "eager" // Download in the background on arrival and publish media_ready
+	MediaLazy  = "lazy"  // Download only when fetched from /message/{instanceId}/{messageId}/media
+	MediaOff   = "off"   // Never download
+)
+
+// Why the media of a message was left out, in MessageData.MediaSkipped
+const (
+	MediaSkippedDisabled = "disabled" // The media policy mode is off
+	MediaSkippedType     = "type"     // The type isn't in the media policy types
+	MediaSkippedSize     = "size"     // Larger than the media policy limit of its type
+)
+
+// mediaTypes are the message types that carry downloadable media
+var mediaTypes = map[string]bool{
+	"image":    true,
+	"video":    true,
+	"audio":    true,
+	"document": true,
+	"sticker":  true,
+}
+
+// MediaPolicy controls how the media of incoming messages is downloaded
+type MediaPolicy struct {
+	Mode     string   `json:"mode"`               // eager (default), lazy or off
+	MaxBytes int64    `json:"maxBytes,omitempty"` // Larger media is left for on-demand fetch (0 = no limit)
+	Types    []string `json:"types,omitempty"`    // Media types downloaded at all (empty = all)
+
+	// Largest media of each type handled at all, e.g. {"video": 16777216}.
+	// Larger media is never downloaded, stored or forwarded; its message
+	// carries only the metadata.
+	Limits map[string]int64 `json:"limits,omitempty"`
+
+	// Background downloads running at once for the instance (default 2)
+	Concurrency int `json:"concurrency,omitempty"`
+}
+
+// isZero reports whether nothing in the policy is set
+func (p MediaPolicy) isZero() bool {
+	return p.Mode == "" && p.MaxBytes == 0 && len(p.Types) == 0 && len(p.Limits) == 0 && p.Concurrency == 0
+}
+
+// SetFallbackMediaPolicy sets the media policy of new instances when the
+// instance defaults don't set one
+func (m *Manager) SetFallbackMediaPolicy(policy MediaPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	m.fallbackMediaPolicy = policy
+	return nil
+}
+
+// validate checks the mode and the type allowlist
+func (p MediaPolicy) validate() error {
+	switch p.Mode {
+	case "", MediaEager, MediaLazy, MediaOff:
+	default:
+		return fmt.Errorf("%w: media policy mode must be eager, lazy or off", ErrInvalidInput)
+	}
+	if p.Concurrency < 0 {
+		return fmt.Errorf("%w: media policy concurrency must be >= 0", ErrInvalidInput)
+	}
+	if p.MaxBytes < 0 {
+		return fmt.Errorf("%w: media policy maxBytes must be >= 0", ErrInvalidInput)
+	}
+	for _, t := range p.Types {
+		if !mediaTypes[t] {
+			return fmt.Errorf("%w: unknown media type %s", ErrInvalidInput, t)
+		}
+	}
+	for t, limit := range p.Limits {
+		if !mediaTypes[t] {
+			return fmt.Errorf("%w: unknown media type %s", ErrInvalidInput, t)
+		}
+		if limit < 0 {
+			return fmt.Errorf("%w: media policy limit of %s must be >= 0", ErrInvalidInput, t)
+		}
+	}
+	return nil
+}
+
+// skipReason returns why media of a type and size is left out of its
+// message, or "" if it may be downloaded
+func (p MediaPolicy) skipReason(mediaType string, size uint64) string {
+	switch {
+	case p.Mode == MediaOff:
+		return MediaSkippedDisabled
+	case !p.allows(mediaType):
+		return MediaSkippedType
+	case p.Limits[mediaType] > 0 && size > uint64(p.Limits[mediaType]):
+		return MediaSkippedSize
+	}
+	return ""
+}
+
+// allows reports whether media of a type may be downloaded at all
+func (p MediaPolicy) allows(mediaType string) bool {
+	if p.Mode == MediaOff {
+		return false
+	}
+	if len(p.Types) == 0 {
+		return true
+	}
+	for _, t := range p.Types {
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// eager reports whether media is downloaded together with its message
+func (p MediaPolicy) eager(mediaType string, size uint64) bool {
+	if p.Mode != "" && p.Mode != MediaEager {
+		return false
+	}
+	if p.MaxBytes > 0 && size > uint64(p.MaxBytes) {
+		return false
+	}
+	return p.allows(mediaType)
+}
+
+// SetMediaPolicy sets how the instance downloads incoming media
+func (m *Manager) SetMediaPolicy(instanceID string, policy MediaPolicy) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+
+	inst.mu.Lock()
+	inst.MediaPolicy = policy
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	m.resetMediaConcurrency(instanceID)
+	log.Info().Str("instanceId", instanceID).Str("mode", policy.Mode).Int64("maxBytes", policy.MaxBytes).Strs("types", policy.Types).Interface("limits", policy.Limits).Msg("Updated media policy")
+	return nil
+}
+
+// mediaSkipReason returns why the media of an incoming message is left out
+// under the instance's media policy, or "" if it is kept
+func (m *Manager) mediaSkipReason(inst *Instance, mediaType string, size uint64) string {
+	if inst == nil {
+		return ""
+	}
+	inst.mu.RLock()
+	policy := inst.MediaPolicy
+	inst.mu.RUnlock()
+
+	reason := policy.skipReason(mediaType, size)
+	if reason != "" {
+		log.Debug().Str("instanceId", inst.ID).Str("type", mediaType).Uint64("bytes", size).Str("reason", reason).Msg("Leaving out media (media policy)")
+	}
+	return reason
+}
+
+// downloadsEagerly reports whether the media of an incoming message is
+// downloaded in the background as soon as it arrives
+func (m *Manager) downloadsEagerly(inst *Instance, mediaType string, size uint64) bool {
+	inst.mu.RLock()
+	policy := inst.MediaPolicy
+	skipVideo := inst.SkipVideoDownload
+	inst.mu.RUnlock()
+
+	if mediaType == "video" && skipVideo {
+		log.Info().Str("instanceId", inst.ID).Uint64("bytes", size).Msg("Skipping video download (SkipVideoDownload enabled)")
+		return false
+	}
+	return policy.eager(mediaType, size)
+}
+
+// MessageMedia is the content of a stored message's media
+type MessageMedia struct {
+	Data     []byte
+	Mimetype string
+	FileName string
+}
+
+// FetchMessageMedia returns the media of a stored message, downloading it from
+// WhatsApp when it wasn't downloaded with the message
+func (m *Manager) FetchMessageMedia(ctx context.Context, instanceID, messageID string) (*MessageMedia, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	msg, ok := m.findStoredMessage(instanceID, messageID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	if !mediaTypes[msg.Type] {
+		return nil, fmt.Errorf("%w: %s message has no media", ErrMediaNotFound, msg.Type)
+	}
+	if msg.MediaSkipped != "" {
+		return nil, fmt.Errorf("%w: media of %s was left out by the media policy (%s)", ErrMediaNotFound, messageID, msg.MediaSkipped)
+	}
+
+	result := &MessageMedia{Mimetype: msg.Mimetype, FileName: msg.FileName}
+	if msg.MediaBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(msg.MediaBase64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode stored media: %w", err)
+		}
+		result.Data = data
+		return result, nil
+	}
+
+	inst.mu.RLock()
+	policy := inst.MediaPolicy
+	inst.mu.RUnlock()
+	if !policy.allows(msg.Type) {
+		return nil, fmt.Errorf("%w: %s downloads are disabled by the media policy", ErrInvalidInput, msg.Type)
+	}
+	if policy.skipReason(msg.Type, msg.FileLength) == MediaSkippedSize {
+		return nil, fmt.Errorf("%w: %s is larger than the media policy limit of %s", ErrInvalidInput, messageID, msg.Type)
+	}
+	if msg.media == nil {
+		return nil, fmt.Errorf("%w: media of %s is not available", ErrMediaNotFound, messageID)
+	}
+
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := tracedDownload(ctx, client, msg.media)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+	}
+	result.Data = data
+	return result, nil
+}
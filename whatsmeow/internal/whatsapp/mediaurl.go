@@ -0,0 +1,120 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMediaURLTTL is how long a media URL in a message event stays valid
+const DefaultMediaURLTTL = time.Hour
+
+// mediaURLs signs the URLs that let event consumers fetch the media of a
+// message on demand without API access
+type mediaURLs struct {
+	baseURL string // Public URL of the service ("" = relative URLs)
+	key     []byte
+	ttl     time.Duration
+}
+
+// mediaToken is the signed part of a media URL
+type mediaToken struct {
+	InstanceID string `json:"i"`
+	MessageID  string `json:"m"`
+	Expires    int64  `json:"e"`
+}
+
+func newMediaURLs() *mediaURLs {
+	// A random key invalidates URLs on restart; SetMediaURLs makes them stable
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &mediaURLs{key: key, ttl: DefaultMediaURLTTL}
+}
+
+// SetMediaURLs configures media URLs: the public URL of the service they
+// start with, the signing key (nodes of a cluster must share it; nil keeps
+// the random one) and how long they stay valid
+func (m *Manager) SetMediaURLs(baseURL string, key []byte, ttl time.Duration) {
+	m.mediaURLs.baseURL = strings.TrimSuffix(baseURL, "/")
+	if len(key) > 0 {
+		m.mediaURLs.key = key
+	}
+	if ttl > 0 {
+		m.mediaURLs.ttl = ttl
+	}
+}
+
+// mediaURL returns a signed URL to /media/fetch for the media of a message,
+// or "" when the message has nothing to download
+func (m *Manager) mediaURL(instanceID string, msg MessageData) string {
+	if msg.media == nil {
+		return ""
+	}
+
+	payload, _ := json.Marshal(mediaToken{
+		InstanceID: instanceID,
+		MessageID:  msg.ID,
+		Expires:    time.Now().Add(m.mediaURLs.ttl).Unix(),
+	})
+	token := base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(m.mediaURLs.sign(payload))
+	return m.mediaURLs.baseURL + "/v1/media/fetch/" + token
+}
+
+func (u *mediaURLs) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, u.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// parseMediaToken decodes a media token without checking its signature
+func parseMediaToken(token string) (t mediaToken, payload, sig []byte, err error) {
+	malformed := fmt.Errorf("%w: malformed media token", ErrInvalidMediaToken)
+
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return t, nil, nil, malformed
+	}
+	if payload, err = base64.RawURLEncoding.DecodeString(encodedPayload); err != nil {
+		return t, nil, nil, malformed
+	}
+	if sig, err = base64.RawURLEncoding.DecodeString(encodedSig); err != nil {
+		return t, nil, nil, malformed
+	}
+	if err = json.Unmarshal(payload, &t); err != nil {
+		return t, nil, nil, malformed
+	}
+	return t, payload, sig, nil
+}
+
+// MediaTokenInstance returns the instance a media token refers to, without
+// verifying it, so requests can be routed to the node that owns the instance
+func MediaTokenInstance(token string) string {
+	t, _, _, err := parseMediaToken(token)
+	if err != nil {
+		return ""
+	}
+	return t.InstanceID
+}
+
+// FetchMediaByToken verifies a media token and returns the media it grants
+// access to, downloading it from WhatsApp if needed
+func (m *Manager) FetchMediaByToken(ctx context.Context, token string) (*MessageMedia, error) {
+	t, payload, sig, err := parseMediaToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(sig, m.mediaURLs.sign(payload)) != 1 {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidMediaToken)
+	}
+	if time.Now().Unix() > t.Expires {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidMediaToken)
+	}
+	return m.FetchMessageMedia(ctx, t.InstanceID, t.MessageID)
+}
@@ -0,0 +1,169 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Background media download limits
+const (
+	defaultMediaWorkers             = 8
+	defaultInstanceMediaConcurrency = 2
+	maxPendingMediaDownloads        = 1000
+	mediaDownloadTimeout            = 2 * time.Minute
+)
+
+// mediaDownloads bounds background media downloads, both overall and per instance
+type mediaDownloads struct {
+	mu          sync.Mutex
+	workers     chan struct{}            // Download slots shared by all instances
+	perInstance map[string]chan struct{} // instanceID -> download slots of the instance
+	pending     int                      // Downloads queued or running
+}
+
+func newMediaDownloads(workers int) *mediaDownloads {
+	return &mediaDownloads{
+		workers:     make(chan struct{}, workers),
+		perInstance: make(map[string]chan struct{}),
+	}
+}
+
+// SetMediaWorkers sets how many media downloads run at once across all
+// instances. Must be called before instances connect.
+func (m *Manager) SetMediaWorkers(workers int) {
+	if workers <= 0 {
+		workers = defaultMediaWorkers
+	}
+	m.mediaDownloads.mu.Lock()
+	m.mediaDownloads.workers = make(chan struct{}, workers)
+	m.mediaDownloads.mu.Unlock()
+}
+
+// instanceSlots returns the download slots of an instance, sized by its media policy
+func (m *Manager) instanceSlots(inst *Instance) chan struct{} {
+	m.mediaDownloads.mu.Lock()
+	defer m.mediaDownloads.mu.Unlock()
+
+	slots, ok := m.mediaDownloads.perInstance[inst.ID]
+	if !ok {
+		inst.mu.RLock()
+		concurrency := inst.MediaPolicy.Concurrency
+		inst.mu.RUnlock()
+		if concurrency <= 0 {
+			concurrency = defaultInstanceMediaConcurrency
+		}
+		slots = make(chan struct{}, concurrency)
+		m.mediaDownloads.perInstance[inst.ID] = slots
+	}
+	return slots
+}
+
+// resetMediaConcurrency makes the next downloads of an instance use its current
+// concurrency; downloads already running keep their slots
+func (m *Manager) resetMediaConcurrency(instanceID string) {
+	m.mediaDownloads.mu.Lock()
+	delete(m.mediaDownloads.perInstance, instanceID)
+	m.mediaDownloads.mu.Unlock()
+}
+
+// queueMediaDownload downloads the media of a message in the background when
+// it's pending, then stores it and publishes media_ready (or media_failed)
+func (m *Manager) queueMediaDownload(inst *Instance, msg MessageData) {
+	if !msg.MediaPending || msg.media == nil {
+		return
+	}
+
+	m.mediaDownloads.mu.Lock()
+	if m.mediaDownloads.pending >= maxPendingMediaDownloads {
+		m.mediaDownloads.mu.Unlock()
+		// The media can still be fetched on demand
+		log.Warn().Str("instanceId", inst.ID).Str("messageId", msg.ID).Msg("Media download queue full, skipping download")
+		m.publishMediaFailed(inst.ID, msg, "download queue full")
+		return
+	}
+	m.mediaDownloads.pending++
+	workers := m.mediaDownloads.workers
+	m.mediaDownloads.mu.Unlock()
+
+	slots := m.instanceSlots(inst)
+
+	go func() {
+		defer func() {
+			m.mediaDownloads.mu.Lock()
+			m.mediaDownloads.pending--
+			m.mediaDownloads.mu.Unlock()
+		}()
+
+		// Take the instance slot first so a busy instance doesn't hold shared workers
+		slots <- struct{}{}
+		defer func() { <-slots }()
+		workers <- struct{}{}
+		defer func() { <-workers }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mediaDownloadTimeout)
+		defer cancel()
+
+		start := time.Now()
+		data, err := tracedDownload(ctx, inst.Client, msg.media)
+		if err != nil {
+			log.Warn().Err(err).Str("instanceId", inst.ID).Str("messageId", msg.ID).Str("type", msg.Type).Msg("Failed to download media")
+			m.publishMediaFailed(inst.ID, msg, err.Error())
+			return
+		}
+
+		mediaBase64 := base64.StdEncoding.EncodeToString(data)
+		m.setStoredMedia(inst.ID, msg.To, msg.ID, mediaBase64)
+		log.Info().Str("instanceId", inst.ID).Str("messageId", msg.ID).Str("type", msg.Type).Int("bytes", len(data)).Dur("took", time.Since(start)).Msg("Media downloaded successfully")
+
+		m.publishEvent(Event{
+			Type:       "media_ready",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"messageId":   msg.ID,
+				"chat":        msg.To,
+				"type":        msg.Type,
+				"mimetype":    msg.Mimetype,
+				"fileName":    msg.FileName,
+				"fileLength":  len(data),
+				"mediaBase64": mediaBase64,
+			},
+		})
+	}()
+}
+
+func (m *Manager) publishMediaFailed(instanceID string, msg MessageData, reason string) {
+	m.publishEvent(Event{
+		Type:       "media_failed",
+		InstanceID: instanceID,
+		Data: map[string]interface{}{
+			"messageId": msg.ID,
+			"chat":      msg.To,
+			"type":      msg.Type,
+			"error":     reason,
+		},
+	})
+}
+
+// setStoredMedia fills in the downloaded media of a stored message
+func (m *Manager) setStoredMedia(instanceID, chatID, messageID, mediaBase64 string) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID == messageID {
+			if msgs[i].Revoked {
+				return
+			}
+			msgs[i].MediaBase64 = mediaBase64
+			msgs[i].MediaPending = false
+			return
+		}
+	}
+}
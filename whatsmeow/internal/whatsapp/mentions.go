@@ -0,0 +1,151 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+	"whatsmeow-service/internal/tracing"
+)
+
+// Default largest group a mention of all participants is sent to
+const defaultMentionAllLimit = 256
+
+// MentionOptions selects the participants a group message mentions. Mentions
+// notify the participants even when the text doesn't contain their @number.
+type MentionOptions struct {
+	Mentions []string // Numbers or JIDs of participants
+	All      bool     // Every participant (@everyone)
+	Admins   bool     // The group's admins
+}
+
+// SetMentionAllLimit sets the largest group a mention of all participants
+// may be sent to
+func (m *Manager) SetMentionAllLimit(limit int) {
+	m.mu.Lock()
+	m.mentionAllLimit = limit
+	m.mu.Unlock()
+}
+
+// SendGroupTextMessage sends a text message to a group, mentioning the
+// participants opts selects
+func (m *Manager) SendGroupTextMessage(ctx context.Context, instanceID, group, text string, opts MentionOptions, preview PreviewOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendGroupTextMessage", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	jid, err := parseGroupJID(group)
+	if err != nil {
+		return "", err
+	}
+
+	mentioned, err := m.groupMentions(ctx, client, jid, opts)
+	if err != nil {
+		return "", err
+	}
+
+	msg := m.textMessage(ctx, inst, text, preview)
+	if len(mentioned) > 0 {
+		if msg.ExtendedTextMessage == nil {
+			msg = &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{Text: proto.String(text)}}
+		}
+		msg.ExtendedTextMessage.ContextInfo = &waE2E.ContextInfo{MentionedJID: mentioned}
+	}
+
+	resp, err := m.sendTracked(ctx, instanceID, client, jid, msg)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("group", jid.String()).Msg("Failed to send group message")
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("msgId", resp.ID).Int("mentions", len(mentioned)).Msg("Group message sent successfully")
+	return resp.ID, nil
+}
+
+// groupMentions resolves the JIDs a group message mentions. Participants are
+// only fetched when all or the admins are mentioned; mentionAll fails for
+// groups larger than the mention limit.
+func (m *Manager) groupMentions(ctx context.Context, client *whatsmeow.Client, group types.JID, opts MentionOptions) ([]string, error) {
+	seen := make(map[string]bool)
+	mentioned := make([]string, 0)
+	add := func(jid types.JID) {
+		if jid.IsEmpty() || seen[jid.String()] {
+			return
+		}
+		seen[jid.String()] = true
+		mentioned = append(mentioned, jid.String())
+	}
+
+	for _, mention := range opts.Mentions {
+		jid, err := m.mentionJID(mention)
+		if err != nil {
+			return nil, err
+		}
+		add(jid)
+	}
+
+	if !opts.All && !opts.Admins {
+		return mentioned, nil
+	}
+
+	info, err := client.GetGroupInfo(ctx, group)
+	if errors.Is(err, whatsmeow.ErrGroupNotFound) || errors.Is(err, whatsmeow.ErrNotInGroup) {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, group)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get group info: %w", err)
+	}
+
+	if opts.All {
+		m.mu.RLock()
+		limit := m.mentionAllLimit
+		m.mu.RUnlock()
+		if len(info.Participants) > limit {
+			return nil, fmt.Errorf("%w: group has %d participants, more than the mention limit of %d", ErrInvalidInput, len(info.Participants), limit)
+		}
+	}
+
+	// Skip the own account, which mentioning would only notify on other devices
+	ownPN, ownLID := client.Store.GetJID().ToNonAD(), client.Store.GetLID().ToNonAD()
+	for _, p := range info.Participants {
+		if jid := p.JID.ToNonAD(); jid == ownPN || jid == ownLID {
+			continue
+		}
+		if opts.All || p.IsAdmin || p.IsSuperAdmin {
+			add(p.JID)
+		}
+	}
+	return mentioned, nil
+}
+
+// mentionJID parses a mentioned participant, given as a JID or a number
+func (m *Manager) mentionJID(mention string) (types.JID, error) {
+	if strings.Contains(mention, "@") {
+		jid, err := types.ParseJID(strings.TrimSpace(mention))
+		if err != nil {
+			return types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+		}
+		return jid, nil
+	}
+
+	number, err := m.normalizePhone(mention)
+	if err != nil {
+		return types.JID{}, err
+	}
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
@@ -0,0 +1,124 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const messageStatusSchema = `
+	CREATE TABLE IF NOT EXISTS message_status (
+		instance_id TEXT    NOT NULL,
+		message_id  TEXT    NOT NULL,
+		chat        TEXT    NOT NULL,
+		status      TEXT    NOT NULL,
+		timestamp   INTEGER NOT NULL,
+		not_after   INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (instance_id, message_id, status)
+	);
+`
+
+// messageStatusStore keeps the delivery timelines of sent messages in events.db
+type messageStatusStore struct {
+	db *sql.DB
+}
+
+// newMessageStatusStore starts pruning expired timelines
+func newMessageStatusStore(db *sql.DB) *messageStatusStore {
+	s := &messageStatusStore{db: db}
+	go s.pruneLoop()
+	return s
+}
+
+// migrateMessageStatus gives statuses recorded before timelines expired as a
+// whole the not_after of their message, and indexes it for pruning
+func migrateMessageStatus(db *sql.DB) error {
+	if err := addColumn(db, "message_status", "not_after", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := db.Exec(`
+		UPDATE message_status SET not_after = ? + (
+			SELECT MIN(first.timestamp) FROM message_status first
+			 WHERE first.instance_id = message_status.instance_id AND first.message_id = message_status.message_id
+		) WHERE not_after = 0;
+		DROP INDEX IF EXISTS idx_message_status_timestamp;
+		CREATE INDEX IF NOT EXISTS idx_message_status_not_after ON message_status (not_after);
+	`, int64(messageStatusRetention.Seconds()))
+	if err != nil {
+		return fmt.Errorf("failed to migrate message statuses: %w", err)
+	}
+	return nil
+}
+
+// RecordMessageStatus stores a status of a message. Only the first time each
+// status is reached is kept (group messages get receipts from every member).
+// Every status of a message shares the expiry set by the first one. Returns
+// false if the status was already recorded.
+func (s *messageStatusStore) RecordMessageStatus(instanceID, messageID, chat, status string, timestamp int64) (bool, error) {
+	res, err := s.db.Exec(
+		`INSERT OR IGNORE INTO message_status (instance_id, message_id, chat, status, timestamp, not_after)
+		 VALUES (?, ?, ?, ?, ?, COALESCE(
+			(SELECT MIN(not_after) FROM message_status WHERE instance_id = ? AND message_id = ?), ?))`,
+		instanceID, messageID, chat, status, timestamp,
+		instanceID, messageID, timestamp+int64(messageStatusRetention.Seconds()),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record message status: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// MessageStatus returns the delivery timeline of a message, or nil if it isn't tracked
+func (s *messageStatusStore) MessageStatus(ctx context.Context, instanceID, messageID string) (*MessageStatus, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT chat, status, timestamp FROM message_status WHERE instance_id = ? AND message_id = ? ORDER BY timestamp`,
+		instanceID, messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query message status: %w", err)
+	}
+	defer rows.Close()
+
+	var result *MessageStatus
+	for rows.Next() {
+		var chat string
+		var entry MessageStatusEntry
+		if err := rows.Scan(&chat, &entry.Status, &entry.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan message status: %w", err)
+		}
+		if result == nil {
+			result = &MessageStatus{MessageID: messageID, Chat: chat, Status: entry.Status}
+		}
+		if statusRank[entry.Status] > statusRank[result.Status] {
+			result.Status = entry.Status
+		}
+		result.Timeline = append(result.Timeline, entry)
+	}
+
+	return result, rows.Err()
+}
+
+// deleteInstance removes the delivery timelines of an instance
+func (s *messageStatusStore) deleteInstance(instanceID string) error {
+	if _, err := s.db.Exec(`DELETE FROM message_status WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance message statuses: %w", err)
+	}
+	return nil
+}
+
+// pruneLoop periodically removes expired timelines. Timelines expire as a
+// whole, so a late read receipt doesn't outlive its sent status.
+func (s *messageStatusStore) pruneLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := s.db.Exec(`DELETE FROM message_status WHERE not_after < ?`, time.Now().Unix()); err != nil {
+			log.Warn().Err(err).Msg("Failed to prune message statuses")
+		}
+	}
+}
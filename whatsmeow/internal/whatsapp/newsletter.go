@@ -0,0 +1,322 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Default and maximum number of channel posts fetched at once
+const (
+	DefaultNewsletterMessages = 50
+	MaxNewsletterMessages     = 100
+)
+
+// NewsletterInfo represents a WhatsApp channel
+type NewsletterInfo struct {
+	JID         string `json:"jid"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InviteCode  string `json:"inviteCode,omitempty"`
+	Subscribers int    `json:"subscribers"`
+	Verified    bool   `json:"verified"`
+	State       string `json:"state,omitempty"`
+	Role        string `json:"role,omitempty"` // subscriber, guest, admin, owner
+	Muted       bool   `json:"muted"`
+}
+
+// NewsletterPost represents a message posted in a WhatsApp channel
+type NewsletterPost struct {
+	ServerID   int            `json:"serverId"`
+	ID         string         `json:"id"`
+	Newsletter string         `json:"newsletter"`
+	Type       string         `json:"type"`
+	Body       string         `json:"body,omitempty"`
+	Timestamp  int64          `json:"timestamp"`
+	Views      int            `json:"views,omitempty"`
+	Reactions  map[string]int `json:"reactions,omitempty"`
+}
+
+// connectedClient returns the client of a connected instance
+func (m *Manager) connectedClient(instanceID string) (*whatsmeow.Client, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	inst.mu.RLock()
+	status := inst.Status
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if status != "connected" || client == nil {
+		return nil, ErrNotConnected
+	}
+	return client, nil
+}
+
+// parseNewsletterJID accepts a channel JID (123@newsletter) or a bare channel ID
+func parseNewsletterJID(newsletter string) (types.JID, error) {
+	if !strings.Contains(newsletter, "@") {
+		newsletter = newsletter + "@" + types.NewsletterServer
+	}
+	jid, err := types.ParseJID(newsletter)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	if jid.Server != types.NewsletterServer {
+		return types.JID{}, fmt.Errorf("%w: %s is not a channel", ErrInvalidJID, newsletter)
+	}
+	return jid, nil
+}
+
+// resolveNewsletter resolves a channel JID or invite link (whatsapp.com/channel/CODE)
+func (m *Manager) resolveNewsletter(client *whatsmeow.Client, newsletter string) (types.JID, error) {
+	if idx := strings.Index(newsletter, "whatsapp.com/channel/"); idx >= 0 {
+		code := strings.Trim(newsletter[idx+len("whatsapp.com/channel/"):], "/")
+		meta, err := client.GetNewsletterInfoWithInvite(context.Background(), code)
+		if err != nil {
+			return types.JID{}, fmt.Errorf("failed to resolve channel invite: %w", err)
+		}
+		return meta.ID, nil
+	}
+	return parseNewsletterJID(newsletter)
+}
+
+func formatNewsletterInfo(meta *types.NewsletterMetadata) NewsletterInfo {
+	info := NewsletterInfo{
+		JID:         meta.ID.String(),
+		Name:        meta.ThreadMeta.Name.Text,
+		Description: meta.ThreadMeta.Description.Text,
+		InviteCode:  meta.ThreadMeta.InviteCode,
+		Subscribers: meta.ThreadMeta.SubscriberCount,
+		Verified:    meta.ThreadMeta.VerificationState == types.NewsletterVerificationStateVerified,
+		State:       string(meta.State.Type),
+	}
+	if meta.ViewerMeta != nil {
+		info.Role = string(meta.ViewerMeta.Role)
+		info.Muted = meta.ViewerMeta.Mute == types.NewsletterMuteOn
+	}
+	return info
+}
+
+func formatNewsletterPost(jid types.JID, msg *types.NewsletterMessage) NewsletterPost {
+	post := NewsletterPost{
+		ServerID:   int(msg.MessageServerID),
+		ID:         msg.MessageID,
+		Newsletter: jid.String(),
+		Type:       msg.Type,
+		Timestamp:  msg.Timestamp.Unix(),
+		Views:      msg.ViewsCount,
+		Reactions:  msg.ReactionCounts,
+	}
+	if msg.Message != nil {
+		post.Body = newsletterPostText(msg.Message)
+	}
+	return post
+}
+
+// newsletterPostText extracts the text or caption of a channel post
+func newsletterPostText(msg *waE2E.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	}
+	return ""
+}
+
+// GetNewsletters lists the channels followed by the instance
+func (m *Manager) GetNewsletters(instanceID string) ([]NewsletterInfo, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed, err := client.GetSubscribedNewsletters(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channels: %w", err)
+	}
+
+	result := make([]NewsletterInfo, 0, len(subscribed))
+	for _, meta := range subscribed {
+		result = append(result, formatNewsletterInfo(meta))
+	}
+	return result, nil
+}
+
+// FollowNewsletter follows a channel by JID or invite link
+func (m *Manager) FollowNewsletter(instanceID, newsletter string) (*NewsletterInfo, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := m.resolveNewsletter(client, newsletter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.FollowNewsletter(context.Background(), jid); err != nil {
+		return nil, fmt.Errorf("failed to follow channel: %w", err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("newsletter", jid.String()).Msg("Followed channel")
+
+	meta, err := client.GetNewsletterInfo(context.Background(), jid)
+	if err != nil {
+		log.Warn().Err(err).Str("newsletter", jid.String()).Msg("Failed to get channel info after follow")
+		return &NewsletterInfo{JID: jid.String()}, nil
+	}
+	info := formatNewsletterInfo(meta)
+	return &info, nil
+}
+
+// UnfollowNewsletter stops following a channel
+func (m *Manager) UnfollowNewsletter(instanceID, newsletter string) error {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return err
+	}
+
+	jid, err := m.resolveNewsletter(client, newsletter)
+	if err != nil {
+		return err
+	}
+
+	if err := client.UnfollowNewsletter(context.Background(), jid); err != nil {
+		return fmt.Errorf("failed to unfollow channel: %w", err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("newsletter", jid.String()).Msg("Unfollowed channel")
+	return nil
+}
+
+// GetNewsletterMessages fetches posts of a channel, newest last. Use before
+// (a post serverId) to page backwards.
+func (m *Manager) GetNewsletterMessages(instanceID, newsletter string, count, before int) ([]NewsletterPost, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	jid, err := parseNewsletterJID(newsletter)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := client.GetNewsletterMessages(context.Background(), jid, &whatsmeow.GetNewsletterMessagesParams{
+		Count:  count,
+		Before: types.MessageServerID(before),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel messages: %w", err)
+	}
+
+	posts := make([]NewsletterPost, 0, len(msgs))
+	for _, msg := range msgs {
+		posts = append(posts, formatNewsletterPost(jid, msg))
+	}
+	return posts, nil
+}
+
+// PublishNewsletterPost publishes a text post in a channel owned or administered by the instance
+func (m *Manager) PublishNewsletterPost(instanceID, newsletter, text string) (string, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	jid, err := parseNewsletterJID(newsletter)
+	if err != nil {
+		return "", err
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("newsletter", jid.String()).Msg("Publishing channel post")
+
+	resp, err := client.SendMessage(context.Background(), jid, &waE2E.Message{
+		Conversation: proto.String(text),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return resp.ID, nil
+}
+
+// handleNewsletterEvent publishes channel related events to subscribers.
+// Returns false if evt isn't a channel event.
+func (m *Manager) handleNewsletterEvent(inst *Instance, evt interface{}) bool {
+	switch v := evt.(type) {
+	case *events.NewsletterJoin:
+		m.publishEvent(Event{
+			Type:       "newsletter_join",
+			InstanceID: inst.ID,
+			Data:       formatNewsletterInfo(&v.NewsletterMetadata),
+		})
+	case *events.NewsletterLeave:
+		m.publishEvent(Event{
+			Type:       "newsletter_leave",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"newsletter": v.ID.String(),
+				"role":       string(v.Role),
+			},
+		})
+	case *events.NewsletterMuteChange:
+		m.publishEvent(Event{
+			Type:       "newsletter_mute",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"newsletter": v.ID.String(),
+				"muted":      v.Mute == types.NewsletterMuteOn,
+			},
+		})
+	case *events.NewsletterLiveUpdate:
+		posts := make([]NewsletterPost, 0, len(v.Messages))
+		for _, msg := range v.Messages {
+			posts = append(posts, formatNewsletterPost(v.JID, msg))
+		}
+		m.publishEvent(Event{
+			Type:       "newsletter_update",
+			InstanceID: inst.ID,
+			Data: map[string]interface{}{
+				"newsletter": v.JID.String(),
+				"posts":      posts,
+			},
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// publishNewsletterMessage publishes a post received from a followed channel
+func (m *Manager) publishNewsletterMessage(inst *Instance, msg *events.Message) {
+	post := NewsletterPost{
+		ServerID:   int(msg.Info.ServerID),
+		ID:         msg.Info.ID,
+		Newsletter: msg.Info.Chat.String(),
+		Type:       msg.Info.Type,
+		Body:       newsletterPostText(msg.Message),
+		Timestamp:  msg.Info.Timestamp.Unix(),
+	}
+
+	m.publishEvent(Event{
+		Type:       "newsletter_message",
+		InstanceID: inst.ID,
+		Data:       post,
+	})
+}
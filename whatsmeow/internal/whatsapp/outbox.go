@@ -0,0 +1,231 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// QueuedMessage is a send request held while the instance is disconnected.
+// It is delivered on reconnect, or dropped with a message_expired event once
+// NotAfter passes.
+type QueuedMessage struct {
+	ID          string          `json:"queueId"`
+	InstanceID  string          `json:"instanceId"`
+	To          string          `json:"to"`
+	Type        string          `json:"type"` // text or media
+	Text        string          `json:"text,omitempty"`
+	Preview     *PreviewOptions `json:"preview,omitempty"`  // Link preview of a text
+	MediaURL    string          `json:"mediaUrl,omitempty"` // Placeholders are filled when sent, see Variables
+	Caption     string          `json:"caption,omitempty"`
+	MediaType   string          `json:"mediaType,omitempty"`
+	FileName    string          `json:"fileName,omitempty"`
+	Mimetype    string          `json:"mimetype,omitempty"`
+	GIFPlayback bool            `json:"gifPlayback,omitempty"`
+	ViewOnce    bool            `json:"viewOnce,omitempty"`
+	QueuedAt    int64           `json:"queuedAt"`
+	NotAfter    int64           `json:"notAfter"`
+
+	// Image compression of a media send
+	MaxDimension int `json:"maxDimension,omitempty"`
+	Quality      int `json:"quality,omitempty"`
+
+	// Values of the {{var}} placeholders of MediaURL, rendered only with
+	// variables or template set
+	Variables map[string]string `json:"variables,omitempty"`
+	Template  bool              `json:"template,omitempty"`
+
+	timer *time.Timer
+}
+
+// outbox holds queued messages per instance
+type outbox struct {
+	mu       sync.Mutex
+	pending  map[string][]*QueuedMessage // instanceID -> messages, oldest first
+	flushing int                         // Flushes sending right now
+}
+
+func newOutbox() *outbox {
+	return &outbox{
+		pending: make(map[string][]*QueuedMessage),
+	}
+}
+
+// remove takes a message out of the queue (caller must hold mu)
+func (o *outbox) remove(instanceID, id string) *QueuedMessage {
+	msgs := o.pending[instanceID]
+	for i, msg := range msgs {
+		if msg.ID == id {
+			o.pending[instanceID] = append(msgs[:i:i], msgs[i+1:]...)
+			return msg
+		}
+	}
+	return nil
+}
+
+func newQueueID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// QueueMessage holds a message until the instance reconnects or its deadline passes
+func (m *Manager) QueueMessage(msg QueuedMessage) (*QueuedMessage, error) {
+	if _, ok := m.GetInstance(msg.InstanceID); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInstanceNotFound, msg.InstanceID)
+	}
+
+	now := time.Now()
+	deadline := time.Unix(msg.NotAfter, 0)
+	if !deadline.After(now) {
+		return nil, fmt.Errorf("%w: delivery deadline already passed", ErrInvalidInput)
+	}
+
+	msg.ID = newQueueID()
+	msg.QueuedAt = now.Unix()
+	queued := &msg
+
+	m.outbox.mu.Lock()
+	m.outbox.pending[msg.InstanceID] = append(m.outbox.pending[msg.InstanceID], queued)
+	m.armExpiry(queued, now)
+	m.outbox.mu.Unlock()
+
+	log.Info().
+		Str("instanceId", msg.InstanceID).
+		Str("queueId", msg.ID).
+		Time("notAfter", deadline).
+		Msg("Instance not connected, message queued until deadline")
+
+	result := *queued
+	return &result, nil
+}
+
+// armExpiry starts the timer that expires a queued message at its deadline
+// (caller must hold outbox.mu)
+func (m *Manager) armExpiry(msg *QueuedMessage, now time.Time) {
+	instanceID, id := msg.InstanceID, msg.ID
+	msg.timer = time.AfterFunc(time.Unix(msg.NotAfter, 0).Sub(now), func() {
+		m.expireQueued(instanceID, id)
+	})
+}
+
+// dropOutbox discards all queued messages of an instance without events
+func (m *Manager) dropOutbox(instanceID string) {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+
+	for _, msg := range m.outbox.pending[instanceID] {
+		msg.timer.Stop()
+	}
+	delete(m.outbox.pending, instanceID)
+}
+
+// expireQueued drops a message whose deadline passed before it could be sent
+func (m *Manager) expireQueued(instanceID, id string) {
+	m.outbox.mu.Lock()
+	msg := m.outbox.remove(instanceID, id)
+	m.outbox.mu.Unlock()
+
+	if msg == nil {
+		return // Already delivered
+	}
+
+	log.Warn().Str("instanceId", instanceID).Str("queueId", id).Str("to", msg.To).Msg("Queued message expired")
+	m.publishEvent(Event{
+		Type:       "message_expired",
+		InstanceID: instanceID,
+		Data:       *msg,
+	})
+}
+
+// flushOutbox sends the queued messages of an instance that just connected
+func (m *Manager) flushOutbox(instanceID string) {
+	m.outbox.mu.Lock()
+	msgs := m.outbox.pending[instanceID]
+	delete(m.outbox.pending, instanceID)
+	m.outbox.flushing++
+	m.outbox.mu.Unlock()
+
+	defer func() {
+		m.outbox.mu.Lock()
+		m.outbox.flushing--
+		m.outbox.mu.Unlock()
+	}()
+
+	for i, msg := range msgs {
+		if time.Now().Unix() >= msg.NotAfter {
+			msg.timer.Stop()
+			m.publishEvent(Event{Type: "message_expired", InstanceID: instanceID, Data: *msg})
+			continue
+		}
+
+		var messageID string
+		var err error
+		if msg.Type == "media" {
+			mediaURL := msg.MediaURL
+			if len(msg.Variables) > 0 || msg.Template {
+				mediaURL = m.RenderMediaURL(instanceID, msg.To, mediaURL, msg.Variables)
+			}
+			messageID, err = m.SendMediaMessage(context.Background(), instanceID, msg.To, mediaURL, msg.Caption, msg.MediaType, MediaOptions{
+				FileName:     msg.FileName,
+				Mimetype:     msg.Mimetype,
+				GIFPlayback:  msg.GIFPlayback,
+				ViewOnce:     msg.ViewOnce,
+				MaxDimension: msg.MaxDimension,
+				Quality:      msg.Quality,
+			})
+		} else {
+			var preview PreviewOptions
+			if msg.Preview != nil {
+				preview = *msg.Preview
+			}
+			messageID, err = m.SendTextMessage(context.Background(), instanceID, msg.To, msg.Text, preview)
+		}
+
+		if errors.Is(err, ErrNotConnected) {
+			// Dropped again; put the rest back and wait for the next connect.
+			// Timers that fired during the flush found nothing to expire, so
+			// every deadline is armed again.
+			now := time.Now()
+			m.outbox.mu.Lock()
+			for _, queued := range msgs[i:] {
+				queued.timer.Stop()
+				m.armExpiry(queued, now)
+			}
+			m.outbox.pending[instanceID] = append(msgs[i:], m.outbox.pending[instanceID]...)
+			m.outbox.mu.Unlock()
+			return
+		}
+
+		msg.timer.Stop()
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", instanceID).Str("queueId", msg.ID).Msg("Failed to send queued message")
+			m.publishEvent(Event{
+				Type:       "message_failed",
+				InstanceID: instanceID,
+				Data: map[string]interface{}{
+					"queueId": msg.ID,
+					"to":      msg.To,
+					"error":   err.Error(),
+				},
+			})
+			continue
+		}
+
+		m.publishEvent(Event{
+			Type:       "message_sent",
+			InstanceID: instanceID,
+			Data: map[string]interface{}{
+				"queueId":   msg.ID,
+				"messageId": messageID,
+				"to":        msg.To,
+			},
+		})
+	}
+}
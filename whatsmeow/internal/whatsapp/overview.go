@@ -0,0 +1,196 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Thresholds past which an instance is flagged in the overview
+const (
+	overviewOutboxStale    = 5 * time.Minute // Oldest queued message of a connected instance
+	overviewWebhookBacklog = 1000            // Journaled events not yet delivered
+)
+
+// Warnings raised for an instance in the overview
+const (
+	WarningDisconnected   = "disconnected"
+	WarningOutboxStale    = "outbox_stale"
+	WarningWebhookFailing = "webhook_failing"
+	WarningWebhookBacklog = "webhook_backlog"
+)
+
+// InstanceOverview is the health of one instance
+type InstanceOverview struct {
+	InstanceID     string         `json:"instanceId"`
+	Status         string         `json:"status"`
+	WANumber       string         `json:"waNumber,omitempty"`
+	ConnectedSince int64          `json:"connectedSince,omitempty"`
+	LastEventAt    int64          `json:"lastEventAt,omitempty"` // Unix time of the last journaled event
+	LastEventType  string         `json:"lastEventType,omitempty"`
+	OutboxQueued   int            `json:"outboxQueued"`
+	OutboxOldest   int64          `json:"outboxOldest,omitempty"` // Unix time the oldest queued message was queued
+	WebhookEnabled bool           `json:"webhookEnabled"`
+	WebhookBacklog int64          `json:"webhookBacklog"` // Journaled events the webhook worker hasn't delivered
+	Webhook        *WebhookHealth `json:"webhook,omitempty"`
+	Warnings       []string       `json:"warnings,omitempty"`
+}
+
+// AdminOverview summarizes every instance for operators
+type AdminOverview struct {
+	GeneratedAt int64              `json:"generatedAt"`
+	Instances   int                `json:"instances"`
+	Statuses    map[string]int     `json:"statuses"` // status -> instances
+	Deleted     int                `json:"deleted"`  // Logged out instances awaiting purge
+	Warnings    int                `json:"warnings"` // Instances with at least one warning
+	Storage     map[string]int64   `json:"storage"`  // Database file -> size in bytes
+	Events      EventDropStats     `json:"events"`   // Events dropped from full subscriber channels
+	Items       []InstanceOverview `json:"items"`
+}
+
+// Overview reports connection states, queue depths, webhook health, last
+// event times and database sizes of every instance, flagged ones first
+func (m *Manager) Overview() (*AdminOverview, error) {
+	m.mu.RLock()
+	instances := make([]*Instance, 0, len(m.instances))
+	for _, inst := range m.instances {
+		instances = append(instances, inst)
+	}
+	m.mu.RUnlock()
+
+	lastEvents, err := m.journal.LastEvents()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	overview := &AdminOverview{
+		GeneratedAt: now.Unix(),
+		Instances:   len(instances),
+		Statuses:    make(map[string]int),
+		Deleted:     len(m.GetDeletedInstances()),
+		Storage:     m.storageSizes(),
+		Events:      m.EventDropStats(),
+		Items:       make([]InstanceOverview, 0, len(instances)),
+	}
+
+	for _, inst := range instances {
+		inst.mu.RLock()
+		item := InstanceOverview{
+			InstanceID:     inst.ID,
+			Status:         inst.Status,
+			WANumber:       inst.WANumber,
+			WebhookEnabled: inst.WebhookURL != "",
+		}
+		if inst.Status == "connected" && !inst.connectedAt.IsZero() {
+			item.ConnectedSince = inst.connectedAt.Unix()
+		}
+		inst.mu.RUnlock()
+
+		last := lastEvents[inst.ID]
+		item.LastEventAt, item.LastEventType = last.Timestamp, last.Type
+
+		m.outbox.mu.Lock()
+		item.OutboxQueued = len(m.outbox.pending[inst.ID])
+		if item.OutboxQueued > 0 {
+			item.OutboxOldest = m.outbox.pending[inst.ID][0].QueuedAt
+		}
+		m.outbox.mu.Unlock()
+
+		health, cursor, running := m.webhookHealth(inst.ID)
+		item.Webhook = health
+		if running && last.ID > cursor {
+			// Sequence numbers are shared by every instance, so the gap isn't a count
+			if item.WebhookBacklog, err = m.journal.CountAfter(inst.ID, cursor); err != nil {
+				return nil, err
+			}
+		}
+
+		if item.Status != "connected" {
+			item.Warnings = append(item.Warnings, WarningDisconnected)
+		} else if item.OutboxQueued > 0 && now.Sub(time.Unix(item.OutboxOldest, 0)) > overviewOutboxStale {
+			item.Warnings = append(item.Warnings, WarningOutboxStale)
+		}
+		if health != nil && health.LastErrorAt > health.LastSuccess {
+			item.Warnings = append(item.Warnings, WarningWebhookFailing)
+		}
+		if item.WebhookBacklog > overviewWebhookBacklog {
+			item.Warnings = append(item.Warnings, WarningWebhookBacklog)
+		}
+
+		overview.Statuses[item.Status]++
+		if len(item.Warnings) > 0 {
+			overview.Warnings++
+		}
+		overview.Items = append(overview.Items, item)
+	}
+
+	sort.Slice(overview.Items, func(i, j int) bool {
+		a, b := overview.Items[i], overview.Items[j]
+		if len(a.Warnings) != len(b.Warnings) {
+			return len(a.Warnings) > len(b.Warnings)
+		}
+		return a.InstanceID < b.InstanceID
+	})
+	return overview, nil
+}
+
+// storageSizes returns the size of each database file in the data directory,
+// including SQLite write-ahead logs
+func (m *Manager) storageSizes() map[string]int64 {
+	sizes := make(map[string]int64)
+	for _, pattern := range []string{"*.db", "*.db-wal", "*.db.enc"} {
+		matches, err := filepath.Glob(filepath.Join(m.dataDir, pattern))
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("Failed to stat database file")
+				continue
+			}
+			sizes[filepath.Base(path)] = info.Size()
+		}
+	}
+	return sizes
+}
+
+// LastEvents returns the most recent journaled event of each instance, without data
+func (j *EventJournal) LastEvents() (map[string]Event, error) {
+	rows, err := j.db.Query(
+		`SELECT e.instance_id, e.seq, e.type, e.timestamp FROM events e
+		 JOIN (SELECT MAX(seq) AS seq FROM events GROUP BY instance_id) last ON e.seq = last.seq`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make(map[string]Event)
+	for rows.Next() {
+		var evt Event
+		if err := rows.Scan(&evt.InstanceID, &evt.ID, &evt.Type, &evt.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan last event: %w", err)
+		}
+		events[evt.InstanceID] = evt
+	}
+	return events, rows.Err()
+}
+
+// CountAfter returns how many events of an instance were journaled after cursor
+func (j *EventJournal) CountAfter(instanceID string, cursor int64) (int64, error) {
+	var count int64
+	err := j.db.QueryRow(
+		`SELECT COUNT(*) FROM events WHERE instance_id = ? AND seq > ?`,
+		instanceID, cursor,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count events: %w", err)
+	}
+	return count, nil
+}
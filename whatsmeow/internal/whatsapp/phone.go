@@ -0,0 +1,77 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+	"github.com/rs/zerolog/log"
+)
+
+// SetDefaultRegion sets the ISO 3166 region (e.g. BR) assumed for numbers
+// given without a country code. Empty requires the country code.
+func (m *Manager) SetDefaultRegion(region string) error {
+	region = strings.ToUpper(region)
+	if region != "" && !phonenumbers.GetSupportedRegions()[region] {
+		return fmt.Errorf("%w: unknown region %q", ErrInvalidInput, region)
+	}
+	m.phoneRegion = region
+	log.Info().Str("region", region).Msg("Updated default phone number region")
+	return nil
+}
+
+// normalizePhone parses a phone number written in any common format
+// ("+55 (11) 91234-5678", "5511912345678", or a national number when a
+// default region is set) into the digits WhatsApp uses as the user part.
+func (m *Manager) normalizePhone(number string) (string, error) {
+	original := number
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return "", fmt.Errorf("%w: number is required", ErrInvalidPhoneNumber)
+	}
+
+	var candidates []*phonenumbers.PhoneNumber
+	if !strings.HasPrefix(number, "+") && m.phoneRegion != "" {
+		// National format of the default region
+		if parsed, err := phonenumbers.Parse(number, m.phoneRegion); err == nil {
+			candidates = append(candidates, parsed)
+		}
+	}
+	// International format, with or without the leading +
+	if parsed, err := phonenumbers.Parse("+"+strings.TrimPrefix(number, "+"), ""); err == nil {
+		candidates = append(candidates, parsed)
+	}
+
+	for _, parsed := range candidates {
+		if validPhone(parsed) {
+			return strings.TrimPrefix(phonenumbers.Format(parsed, phonenumbers.E164), "+"), nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidPhoneNumber, original)
+}
+
+// validPhone reports whether a parsed number can belong to a WhatsApp
+// account. Brazilian mobiles registered before the extra 9 are still known
+// to WhatsApp without it, so either form is accepted.
+func validPhone(number *phonenumbers.PhoneNumber) bool {
+	if phonenumbers.IsValidNumber(number) {
+		return true
+	}
+
+	user := strings.TrimPrefix(phonenumbers.Format(number, phonenumbers.E164), "+")
+	variant := brazilianVariant(user)
+	if variant == "" {
+		return false
+	}
+	parsed, err := phonenumbers.Parse("+"+variant, "")
+	return err == nil && phonenumbers.IsValidNumber(parsed)
+}
+
+// normalizeRecipient normalizes a recipient given as a phone number and
+// leaves JIDs (anything with an @) untouched
+func (m *Manager) normalizeRecipient(to string) (string, error) {
+	if strings.Contains(to, "@") {
+		return strings.TrimSpace(to), nil
+	}
+	return m.normalizePhone(to)
+}
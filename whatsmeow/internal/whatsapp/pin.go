@@ -0,0 +1,93 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// How long a pin lasts, as offered by the WhatsApp apps
+var pinDurations = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// DefaultPinDuration is used when no duration is given
+const DefaultPinDuration = "7d"
+
+// PinMessage pins (or unpins) a message in a chat for all participants.
+// duration is one of 24h, 7d or 30d and is ignored when unpinning.
+func (m *Manager) PinMessage(instanceID, chatID, messageID string, pin bool, duration string) error {
+	if duration == "" {
+		duration = DefaultPinDuration
+	}
+	pinDuration, ok := pinDurations[duration]
+	if !ok && pin {
+		return fmt.Errorf("%w: duration must be 24h, 7d or 30d", ErrInvalidInput)
+	}
+
+	inst, chatJID, err := m.resolveRecipient(instanceID, chatID)
+	if err != nil {
+		return err
+	}
+
+	// The key must name the original sender of messages we didn't send
+	sender := types.EmptyJID
+	if stored, ok := m.findStoredMessage(instanceID, messageID); ok && !stored.FromMe {
+		if sender, err = types.ParseJID(stored.From); err != nil {
+			sender = chatJID
+		}
+	}
+
+	pinType := waE2E.PinInChatMessage_PIN_FOR_ALL
+	if !pin {
+		pinType = waE2E.PinInChatMessage_UNPIN_FOR_ALL
+	}
+	msg := &waE2E.Message{
+		PinInChatMessage: &waE2E.PinInChatMessage{
+			Key:               inst.Client.BuildMessageKey(chatJID, sender, types.MessageID(messageID)),
+			Type:              pinType.Enum(),
+			SenderTimestampMS: proto.Int64(time.Now().UnixMilli()),
+		},
+	}
+	if pin {
+		msg.MessageContextInfo = &waE2E.MessageContextInfo{
+			MessageAddOnDurationInSecs: proto.Uint32(uint32(pinDuration.Seconds())),
+		}
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("chatJID", chatJID.String()).Str("messageId", messageID).Bool("pin", pin).Str("duration", duration).Msg("Pinning message")
+
+	if _, err := inst.Client.SendMessage(context.Background(), chatJID, msg); err != nil {
+		return fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+	return nil
+}
+
+// publishPin publishes a message being pinned or unpinned in a chat
+func (m *Manager) publishPin(inst *Instance, evt *events.Message, pin *waE2E.PinInChatMessage) {
+	data := map[string]interface{}{
+		"messageId": pin.GetKey().GetID(),
+		"chat":      m.canonicalChatID(evt.Info.Chat.String()),
+		"sender":    evt.Info.Sender.String(),
+		"fromMe":    evt.Info.IsFromMe,
+		"pinned":    pin.GetType() == waE2E.PinInChatMessage_PIN_FOR_ALL,
+		"timestamp": evt.Info.Timestamp.Unix(),
+	}
+	if seconds := evt.Message.GetMessageContextInfo().GetMessageAddOnDurationInSecs(); seconds > 0 {
+		data["durationSeconds"] = seconds
+	}
+
+	m.publishEvent(Event{
+		Type:       "message_pin",
+		InstanceID: inst.ID,
+		Data:       data,
+	})
+}
@@ -0,0 +1,167 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// PollInfo is the content of a received poll
+type PollInfo struct {
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	SelectableCount int      `json:"selectableCount"` // 0 = any number of options
+}
+
+// parsePoll extracts a poll creation message, whichever version it was sent as
+func parsePoll(msg *waE2E.Message) *PollInfo {
+	poll := msg.GetPollCreationMessage()
+	if poll == nil {
+		poll = msg.GetPollCreationMessageV2()
+	}
+	if poll == nil {
+		poll = msg.GetPollCreationMessageV3()
+	}
+	if poll == nil {
+		poll = msg.GetPollCreationMessageV5()
+	}
+	if poll == nil {
+		return nil
+	}
+
+	info := &PollInfo{
+		Question:        poll.GetName(),
+		Options:         make([]string, 0, len(poll.GetOptions())),
+		SelectableCount: int(poll.GetSelectableOptionsCount()),
+	}
+	for _, option := range poll.GetOptions() {
+		info.Options = append(info.Options, option.GetOptionName())
+	}
+	return info
+}
+
+// VotePoll votes on a poll received by the instance. An empty option list
+// removes the instance's vote.
+func (m *Manager) VotePoll(instanceID, pollMessageID string, options []string) (string, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return "", ErrInstanceNotFound
+	}
+
+	stored, ok := m.findStoredMessage(instanceID, pollMessageID)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMessageNotFound, pollMessageID)
+	}
+	if stored.Poll == nil {
+		return "", fmt.Errorf("%w: message %s is not a poll", ErrInvalidInput, pollMessageID)
+	}
+	if err := stored.Poll.validateVote(options); err != nil {
+		return "", err
+	}
+
+	_, chatJID, err := m.resolveRecipient(instanceID, stored.To)
+	if err != nil {
+		return "", err
+	}
+	senderJID, err := types.ParseJID(stored.From)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+
+	pollInfo := &types.MessageInfo{
+		MessageSource: types.MessageSource{
+			Chat:     chatJID,
+			Sender:   senderJID,
+			IsFromMe: stored.FromMe,
+			IsGroup:  stored.IsGroup,
+		},
+		ID: stored.ID,
+	}
+	voteMsg, err := inst.Client.BuildPollVote(context.Background(), pollInfo, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to build poll vote: %w", err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("chatJID", chatJID.String()).Str("pollId", pollMessageID).Strs("options", options).Msg("Voting on poll")
+
+	resp, err := inst.Client.SendMessage(context.Background(), chatJID, voteMsg)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+	return resp.ID, nil
+}
+
+// validateVote checks the chosen options exist and respect the selection limit
+func (p *PollInfo) validateVote(options []string) error {
+	if p.SelectableCount > 0 && len(options) > p.SelectableCount {
+		return fmt.Errorf("%w: poll allows at most %d options", ErrInvalidInput, p.SelectableCount)
+	}
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if seen[option] {
+			return fmt.Errorf("%w: option %q chosen twice", ErrInvalidInput, option)
+		}
+		seen[option] = true
+		found := false
+		for _, existing := range p.Options {
+			if existing == option {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %q is not an option of the poll", ErrInvalidInput, option)
+		}
+	}
+	return nil
+}
+
+// publishPollVote decrypts a vote on a poll and publishes it. Option names are
+// resolved when the poll is in the store; otherwise only the hashes are sent.
+func (m *Manager) publishPollVote(inst *Instance, evt *events.Message) {
+	vote, err := inst.Client.DecryptPollVote(context.Background(), evt)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Str("messageId", evt.Info.ID).Msg("Failed to decrypt poll vote")
+		return
+	}
+
+	pollID := evt.Message.GetPollUpdateMessage().GetPollCreationMessageKey().GetID()
+	hashes := make([]string, 0, len(vote.GetSelectedOptions()))
+	for _, hash := range vote.GetSelectedOptions() {
+		hashes = append(hashes, hex.EncodeToString(hash))
+	}
+
+	data := map[string]interface{}{
+		"pollId":               pollID,
+		"chat":                 m.canonicalChatID(evt.Info.Chat.String()),
+		"voter":                evt.Info.Sender.String(),
+		"fromMe":               evt.Info.IsFromMe,
+		"selectedOptionHashes": hashes,
+		"timestamp":            evt.Info.Timestamp.Unix(),
+	}
+	if stored, ok := m.findStoredMessage(inst.ID, pollID); ok && stored.Poll != nil {
+		byHash := make(map[string]string, len(stored.Poll.Options))
+		for i, hash := range whatsmeow.HashPollOptions(stored.Poll.Options) {
+			byHash[hex.EncodeToString(hash)] = stored.Poll.Options[i]
+		}
+		selected := make([]string, 0, len(hashes))
+		for _, hash := range hashes {
+			if name, ok := byHash[hash]; ok {
+				selected = append(selected, name)
+			}
+		}
+		data["selectedOptions"] = selected
+	}
+
+	m.publishEvent(Event{
+		Type:       "poll_vote",
+		InstanceID: inst.ID,
+		Data:       data,
+	})
+}
@@ -0,0 +1,173 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ContactPresence represents the last known online state of a contact
+type ContactPresence struct {
+	JID          string `json:"jid"`
+	Online       bool   `json:"online"`
+	LastSeen     int64  `json:"lastSeen,omitempty"` // Zero if the contact hides it
+	UpdatedAt    int64  `json:"updatedAt,omitempty"`
+	Subscribed   bool   `json:"subscribed"`
+	SubscribedAt int64  `json:"subscribedAt,omitempty"`
+}
+
+// presenceStore holds the last known presence of contacts per instance
+type presenceStore struct {
+	mu      sync.RWMutex
+	entries map[string]map[string]*ContactPresence // instanceID -> JID -> presence
+}
+
+func newPresenceStore() *presenceStore {
+	return &presenceStore{
+		entries: make(map[string]map[string]*ContactPresence),
+	}
+}
+
+// entry returns the presence entry for a JID, creating it (caller must hold mu)
+func (p *presenceStore) entry(instanceID, jid string) *ContactPresence {
+	if p.entries[instanceID] == nil {
+		p.entries[instanceID] = make(map[string]*ContactPresence)
+	}
+	entry, ok := p.entries[instanceID][jid]
+	if !ok {
+		entry = &ContactPresence{JID: jid}
+		p.entries[instanceID][jid] = entry
+	}
+	return entry
+}
+
+// presenceJID parses a number or JID into the key used by the presence store
+func (m *Manager) presenceJID(number string) (types.JID, error) {
+	number, err := m.normalizeRecipient(number)
+	if err != nil {
+		return types.JID{}, err
+	}
+
+	if !strings.Contains(number, "@") {
+		number = number + "@s.whatsapp.net"
+	}
+
+	jid, err := types.ParseJID(m.canonicalChatID(number))
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+	return jid.ToNonAD(), nil
+}
+
+// SubscribePresence asks WhatsApp to send online/offline updates of the given
+// contacts. The instance marks itself online first, as the servers only
+// deliver presence to online clients.
+func (m *Manager) SubscribePresence(instanceID string, numbers []string) ([]string, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	jids := make([]types.JID, 0, len(numbers))
+	for _, number := range numbers {
+		jid, err := m.presenceJID(number)
+		if err != nil {
+			return nil, err
+		}
+		jids = append(jids, jid)
+	}
+
+	if err := client.SendPresence(context.Background(), types.PresenceAvailable); err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Msg("Failed to mark online before presence subscription")
+	}
+
+	subscribed := make([]string, 0, len(jids))
+	for _, jid := range jids {
+		if err := client.SubscribePresence(context.Background(), jid); err != nil {
+			return subscribed, fmt.Errorf("failed to subscribe to presence of %s: %w", jid, err)
+		}
+
+		m.presence.mu.Lock()
+		entry := m.presence.entry(instanceID, jid.String())
+		entry.Subscribed = true
+		entry.SubscribedAt = time.Now().Unix()
+		m.presence.mu.Unlock()
+
+		subscribed = append(subscribed, jid.String())
+	}
+
+	log.Info().Str("instanceId", instanceID).Int("contacts", len(subscribed)).Msg("Subscribed to contact presence")
+	return subscribed, nil
+}
+
+// GetContactPresence returns the last known presence of a contact
+func (m *Manager) GetContactPresence(instanceID, number string) (*ContactPresence, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	jid, err := m.presenceJID(number)
+	if err != nil {
+		return nil, err
+	}
+
+	m.presence.mu.RLock()
+	defer m.presence.mu.RUnlock()
+
+	entry, ok := m.presence.entries[instanceID][jid.String()]
+	if !ok {
+		// Nothing received yet
+		return &ContactPresence{JID: jid.String()}, nil
+	}
+	result := *entry
+	return &result, nil
+}
+
+// handlePresence records a presence update and forwards it to subscribers
+func (m *Manager) handlePresence(inst *Instance, evt *events.Presence) {
+	jid := evt.From.ToNonAD().String()
+	now := time.Now().Unix()
+
+	m.presence.mu.Lock()
+	entry := m.presence.entry(inst.ID, jid)
+	entry.Online = !evt.Unavailable
+	entry.UpdatedAt = now
+	if !evt.LastSeen.IsZero() {
+		entry.LastSeen = evt.LastSeen.Unix()
+	} else if !evt.Unavailable {
+		entry.LastSeen = now
+	}
+	snapshot := *entry
+	m.presence.mu.Unlock()
+
+	m.publishEvent(Event{
+		Type:       "presence",
+		InstanceID: inst.ID,
+		Data:       snapshot,
+	})
+}
+
+// handleChatPresence forwards typing/recording notifications of a chat
+func (m *Manager) handleChatPresence(inst *Instance, evt *events.ChatPresence) {
+	state := string(evt.State)
+	if evt.State == types.ChatPresenceComposing && evt.Media == types.ChatPresenceMediaAudio {
+		state = "recording"
+	}
+
+	m.publishEvent(Event{
+		Type:       "chat_presence",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"chat":    m.canonicalChatID(evt.Chat.String()),
+			"sender":  evt.Sender.String(),
+			"isGroup": evt.IsGroup,
+			"state":   state, // composing, recording or paused
+		},
+	})
+}
@@ -0,0 +1,185 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Default most redirects followed when fetching a link preview
+const defaultPreviewRedirects = 3
+
+// errPreviewBlocked is returned for URLs the preview policy doesn't allow
+var errPreviewBlocked = errors.New("blocked by the preview policy")
+
+// Non-public ranges the net.IP predicates don't cover
+var reservedNets = []*net.IPNet{
+	mustCIDR("0.0.0.0/8"),     // "This" network
+	mustCIDR("100.64.0.0/10"), // Carrier-grade NAT, also used for cloud metadata
+	mustCIDR("192.0.0.0/24"),  // IETF protocol assignments
+	mustCIDR("198.18.0.0/15"), // Benchmarking
+}
+
+func mustCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// PreviewPolicy restricts the URLs link previews and location thumbnails are
+// fetched from. Those URLs come from whoever writes the message, so by default
+// only public addresses are fetched.
+type PreviewPolicy struct {
+	AllowPrivate bool     // Also fetch loopback, private, link-local and other non-public addresses
+	DenyDomains  []string // Domains never fetched, with their subdomains
+	MaxRedirects int      // Redirects followed per fetch
+}
+
+// SetPreviewPolicy sets the policy link preview fetches follow
+func (m *Manager) SetPreviewPolicy(policy PreviewPolicy) {
+	domains := make([]string, 0, len(policy.DenyDomains))
+	for _, domain := range policy.DenyDomains {
+		if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	policy.DenyDomains = domains
+
+	m.mu.Lock()
+	m.previewPolicy = policy
+	m.mu.Unlock()
+}
+
+// check returns an error if the policy doesn't allow fetching u
+func (p PreviewPolicy) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: unsupported scheme %q", errPreviewBlocked, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, domain := range p.DenyDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return fmt.Errorf("%w: %s is denied", errPreviewBlocked, host)
+		}
+	}
+
+	if p.AllowPrivate {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s is not public", errPreviewBlocked, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return fmt.Errorf("%w: %s is not public", errPreviewBlocked, host)
+	}
+	return nil
+}
+
+// publicIP reports whether an address is reachable on the public internet
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// previewFetcher fetches preview pages and images through an instance's
+// proxy, checking every request and redirect against the preview policy
+type previewFetcher struct {
+	transport http.RoundTripper
+	policy    PreviewPolicy
+}
+
+// validatePublicURL rejects URLs of a field that aren't http(s) or name a
+// loopback, private or metadata address. Hostnames are checked again by
+// publicDialer when they're dialed.
+func validatePublicURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %s must be an http(s) URL", ErrInvalidInput, field)
+	}
+	if err := (PreviewPolicy{}).check(u); err != nil {
+		return fmt.Errorf("%w: %s must be a public address", ErrInvalidInput, field)
+	}
+	return nil
+}
+
+// publicDialer returns a dialer that refuses non-public addresses. The check
+// runs on the address actually dialed, after name resolution, so hostnames
+// that resolve to private addresses are refused too.
+func publicDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s is not public", errPreviewBlocked, host)
+			}
+			return nil
+		},
+	}
+}
+
+// previewFetcher returns the fetcher of an instance's link previews. Without
+// an instance proxy, fetches connect directly (ignoring any proxy from the
+// environment) through publicDialer; through a proxy, which resolves
+// hostnames itself, only literal addresses can be checked.
+func (m *Manager) previewFetcher(inst *Instance) (*previewFetcher, error) {
+	proxyURL := m.instanceProxyURL(inst)
+	transport, err := transportFor(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	policy := m.previewPolicy
+	m.mu.RUnlock()
+
+	if !policy.AllowPrivate && proxyURL == "" {
+		transport.Proxy = nil
+		transport.DialContext = publicDialer().DialContext
+	}
+
+	return &previewFetcher{transport: transport, policy: policy}, nil
+}
+
+// RoundTrip checks a request against the policy before sending it
+func (f *previewFetcher) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := f.policy.check(req.URL); err != nil {
+		return nil, err
+	}
+	return f.transport.RoundTrip(req)
+}
+
+// client returns an HTTP client fetching through f, following at most the
+// policy's redirects
+func (f *previewFetcher) client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: f,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > f.policy.MaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", errPreviewBlocked, f.policy.MaxRedirects)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package whatsapp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A hostname that resolves to loopback passes the URL check, so it must be
+// refused when dialed
+func TestPreviewFetcherRefusesHostnameResolvingToLoopback(t *testing.T) {
+	addrs, err := net.LookupHost("localhost")
+	if err != nil || len(addrs) == 0 {
+		t.Skip("localhost doesn't resolve here")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("preview fetch reached a loopback server")
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	m := &Manager{previewPolicy: PreviewPolicy{MaxRedirects: defaultPreviewRedirects}}
+	fetcher, err := m.previewFetcher(&Instance{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Straight to the transport, past the URL check that rejects "localhost" by name
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost:"+port+"/", nil)
+	resp, err := fetcher.transport.RoundTrip(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the dial to loopback to be refused")
+	}
+	if !errors.Is(err, errPreviewBlocked) {
+		t.Fatalf("expected errPreviewBlocked, got %v", err)
+	}
+}
+
+func TestPreviewFetcherIgnoresEnvironmentProxy(t *testing.T) {
+	m := &Manager{}
+	fetcher, err := m.previewFetcher(&Instance{ID: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetcher.transport.(*http.Transport).Proxy != nil {
+		t.Fatal("expected direct connections without an instance proxy")
+	}
+}
+
+func TestPreviewPolicyCheck(t *testing.T) {
+	policy := PreviewPolicy{DenyDomains: []string{"example.com"}}
+	for _, raw := range []string{
+		"http://127.0.0.1/",
+		"http://10.0.0.1/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::1]/",
+		"http://localhost/",
+		"http://a.example.com/",
+		"file:///etc/passwd",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, raw, nil)
+		if err := policy.check(req.URL); !errors.Is(err, errPreviewBlocked) {
+			t.Errorf("%s: expected errPreviewBlocked, got %v", raw, err)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://93.184.215.14/", nil)
+	if err := policy.check(req.URL); err != nil {
+		t.Errorf("public address blocked: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// loadProxies loads the per-instance proxy settings from file
+func (m *Manager) loadProxies() {
+	data, err := os.ReadFile(m.proxiesFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load instance proxies")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.proxies); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal instance proxies")
+	}
+}
+
+// saveProxy records the proxy of an instance so it survives restarts. An
+// empty host removes it.
+func (m *Manager) saveProxy(instanceID string, proxy ProxyConfig) {
+	m.proxiesMu.Lock()
+	defer m.proxiesMu.Unlock()
+
+	if proxy.Host == "" {
+		if _, ok := m.proxies[instanceID]; !ok {
+			return
+		}
+		delete(m.proxies, instanceID)
+	} else {
+		m.proxies[instanceID] = proxy
+	}
+
+	data, err := json.MarshalIndent(m.proxies, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal instance proxies")
+		return
+	}
+
+	// Holds proxy credentials
+	if err := os.WriteFile(m.proxiesFile, data, 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to save instance proxies")
+	}
+}
+
+// applyStoredProxy configures the client of an instance with its saved
+// proxy. Must be called before the client connects. Reports whether the
+// instance had one.
+func (m *Manager) applyStoredProxy(inst *Instance) bool {
+	m.proxiesMu.Lock()
+	proxy, ok := m.proxies[inst.ID]
+	m.proxiesMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	inst.mu.Lock()
+	inst.ProxyHost = proxy.Host
+	inst.ProxyPort = proxy.Port
+	inst.ProxyUsername = proxy.Username
+	inst.ProxyPassword = proxy.Password
+	inst.ProxyProtocol = proxy.Protocol
+	inst.mu.Unlock()
+
+	proxyURL := m.buildProxyURL(proxy.Host, proxy.Port, proxy.Username, proxy.Password, proxy.Protocol)
+	if err := inst.Client.SetProxyAddress(proxyURL); err != nil {
+		log.Error().Err(err).Str("instanceId", inst.ID).Msg("Failed to apply saved proxy")
+		return true
+	}
+
+	log.Info().Str("instanceId", inst.ID).Str("proxy", proxy.Host+":"+proxy.Port).Msg("Applied saved proxy")
+	return true
+}
+
+// instanceProxyURL returns the proxy URL of an instance, "" without a proxy
+func (m *Manager) instanceProxyURL(inst *Instance) string {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return m.buildProxyURL(inst.ProxyHost, inst.ProxyPort, inst.ProxyUsername, inst.ProxyPassword, inst.ProxyProtocol)
+}
+
+// proxyTransport returns an HTTP transport going through the proxy of an
+// instance, so requests made on its behalf (media and link preview fetches)
+// exit from the same IP as its WhatsApp connection
+func (m *Manager) proxyTransport(inst *Instance) (*http.Transport, error) {
+	return transportFor(m.instanceProxyURL(inst))
+}
+
+// publicTransport returns an HTTP transport for requests an instance makes to
+// URLs set through the API (AI agent, Typebot, Dialogflow). Without a proxy it
+// dials only public addresses; through one, which resolves hostnames itself,
+// the URL was checked when it was configured.
+func (m *Manager) publicTransport(inst *Instance) (*http.Transport, error) {
+	proxyURL := m.instanceProxyURL(inst)
+	transport, err := transportFor(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == "" {
+		transport.Proxy = nil
+		transport.DialContext = publicDialer().DialContext
+	}
+	return transport, nil
+}
+
+// transportFor returns an HTTP transport going through a proxy URL, or the
+// default transport settings for ""
+func transportFor(proxyURL string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL == "" {
+		return transport, nil
+	}
+
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	transport.Proxy = http.ProxyURL(parsed)
+	return transport, nil
+}
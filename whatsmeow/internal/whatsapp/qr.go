@@ -0,0 +1,221 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/skip2/go-qrcode"
+)
+
+// WhatsApp gives the first QR code a longer lifetime than the following ones
+const (
+	firstQRTimeout = 60 * time.Second
+	nextQRTimeout  = 20 * time.Second
+)
+
+// Default time an instance may show QR codes without pairing before it's reset
+const defaultQRIdleTimeout = 5 * time.Minute
+
+// startQRRotation cycles through the QR codes sent by the server, updating the
+// instance and publishing a qr event each time the current code expires. The
+// rotation only touches the instance while its generation is current, so a
+// pairing or disconnect that stops it mid-step isn't overwritten.
+func (m *Manager) startQRRotation(inst *Instance, codes []string) {
+	stop := make(chan struct{})
+	inst.mu.Lock()
+	if inst.qrStop != nil {
+		close(inst.qrStop)
+	}
+	inst.qrStop = stop
+	inst.qrGen++
+	gen := inst.qrGen
+	inst.mu.Unlock()
+
+	go func() {
+		for i, code := range codes {
+			timeout := nextQRTimeout
+			if i == 0 {
+				timeout = firstQRTimeout
+			}
+
+			if !m.setQRCode(inst, code, gen) {
+				return
+			}
+
+			log.Info().Str("instanceId", inst.ID).Int("index", i).Dur("expiresIn", timeout).Msg("QR code generated")
+			inst.mu.RLock()
+			qrBase64, current := inst.QRCodeBase64, inst.qrGen == gen
+			inst.mu.RUnlock()
+			if !current {
+				return
+			}
+
+			m.publishEvent(Event{
+				Type:       "qr",
+				InstanceID: inst.ID,
+				Data: map[string]interface{}{
+					"qr":        code,
+					"qrBase64":  qrBase64,
+					"index":     i,
+					"remaining": len(codes) - i - 1,
+					"expiresIn": int(timeout.Seconds()),
+				},
+			})
+
+			select {
+			case <-time.After(timeout):
+			case <-stop:
+				return
+			}
+		}
+
+		// Ran out of codes without pairing
+		inst.mu.Lock()
+		if inst.qrGen != gen {
+			inst.mu.Unlock()
+			return
+		}
+		inst.qrStop = nil
+		inst.QRCode = ""
+		inst.QRCodeBase64 = ""
+		inst.qrPNG = nil
+		inst.mu.Unlock()
+
+		log.Warn().Str("instanceId", inst.ID).Msg("QR codes expired without pairing")
+		m.publishEvent(Event{
+			Type:       "qr_timeout",
+			InstanceID: inst.ID,
+			Data:       nil,
+		})
+	}()
+}
+
+// stopQRRotation stops the QR rotation goroutine if one is running
+func (m *Manager) stopQRRotation(inst *Instance) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if inst.qrStop != nil {
+		close(inst.qrStop)
+		inst.qrStop = nil
+	}
+	inst.qrGen++
+}
+
+// setQRCode stores the current QR code and its rendered PNG, unless the
+// rotation of generation gen was stopped. Reports whether it was stored.
+func (m *Manager) setQRCode(inst *Instance, code string, gen uint64) bool {
+	png, err := qrcode.Encode(code, qrcode.Medium, 256)
+	if err != nil {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Failed to encode QR code")
+	}
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if inst.qrGen != gen {
+		return false
+	}
+	inst.Status = "qr"
+	inst.QRCode = code
+	inst.qrPNG = png
+	if err == nil {
+		inst.QRCodeBase64 = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+	return true
+}
+
+// GetQRCodePNG returns the current QR code as PNG bytes
+func (m *Manager) GetQRCodePNG(instanceID string) []byte {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil
+	}
+
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+
+	return inst.qrPNG
+}
+
+// SetQRIdleTimeout sets how long an instance may wait in the QR state before
+// it's disconnected and reset to idle (0 disables the timeout)
+func (m *Manager) SetQRIdleTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	m.qrIdleTimeout = timeout
+	m.mu.Unlock()
+}
+
+// startQRIdleTimer arms the QR timeout when an instance starts showing QR
+// codes. New batches of codes don't extend it.
+func (m *Manager) startQRIdleTimer(inst *Instance) {
+	m.mu.RLock()
+	timeout := m.qrIdleTimeout
+	m.mu.RUnlock()
+	if timeout <= 0 {
+		return
+	}
+
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if inst.qrIdle != nil {
+		return
+	}
+	inst.qrIdle = time.AfterFunc(timeout, func() {
+		m.expireQR(inst, timeout)
+	})
+}
+
+// stopQRIdleTimer disarms the QR timeout
+func (m *Manager) stopQRIdleTimer(inst *Instance) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if inst.qrIdle != nil {
+		inst.qrIdle.Stop()
+		inst.qrIdle = nil
+	}
+}
+
+// expireQR resets an instance that was never paired: the socket is closed,
+// the QR code cleared and the unused device removed from the store
+func (m *Manager) expireQR(inst *Instance, timeout time.Duration) {
+	inst.mu.Lock()
+	inst.qrIdle = nil
+	status := inst.Status
+	inst.mu.Unlock()
+
+	if status != "qr" || inst.Client.IsLoggedIn() {
+		return
+	}
+
+	m.stopQRRotation(inst)
+	inst.Client.Disconnect()
+
+	// Unpaired devices normally only live in memory, but drop any row left behind
+	if inst.Device.ID != nil {
+		if err := inst.Device.Delete(context.Background()); err != nil {
+			log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Failed to delete unused device")
+		}
+	}
+
+	inst.mu.Lock()
+	inst.Status = "idle"
+	inst.QRCode = ""
+	inst.QRCodeBase64 = ""
+	inst.qrPNG = nil
+	inst.mu.Unlock()
+
+	log.Warn().Str("instanceId", inst.ID).Dur("timeout", timeout).Msg("Instance not paired in time, reset to idle")
+	m.publishEvent(Event{
+		Type:       "instance_idle",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"reason":  "qr_timeout",
+			"timeout": int(timeout.Seconds()),
+		},
+	})
+}
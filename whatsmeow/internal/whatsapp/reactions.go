@@ -0,0 +1,105 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// MessageReaction is the current reaction of one user to a message
+type MessageReaction struct {
+	Sender    string `json:"sender"`
+	Emoji     string `json:"emoji"`
+	FromMe    bool   `json:"fromMe"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// handleReaction records a reaction on the message it targets and publishes
+// message_reaction. An empty emoji removes the sender's reaction.
+func (m *Manager) handleReaction(inst *Instance, evt *events.Message, reaction *waE2E.ReactionMessage, paused bool) {
+	messageID := reaction.GetKey().GetID()
+	chat := m.canonicalChatID(evt.Info.Chat.String())
+	sender := evt.Info.Sender.ToNonAD().String()
+
+	m.setStoredReaction(inst.ID, chat, messageID, MessageReaction{
+		Sender:    sender,
+		Emoji:     reaction.GetText(),
+		FromMe:    evt.Info.IsFromMe,
+		Timestamp: evt.Info.Timestamp.Unix(),
+	})
+
+	if paused {
+		return
+	}
+	m.publishEvent(Event{
+		Type:       "message_reaction",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"messageId": messageID,
+			"chat":      chat,
+			"sender":    sender,
+			"fromMe":    evt.Info.IsFromMe,
+			"emoji":     reaction.GetText(),
+			"removed":   reaction.GetText() == "",
+			"timestamp": evt.Info.Timestamp.Unix(),
+		},
+	})
+}
+
+// setStoredReaction replaces the reaction of a sender on a stored message.
+// Reactions to messages that aren't stored are dropped.
+func (m *Manager) setStoredReaction(instanceID, chatID, messageID string, reaction MessageReaction) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID != messageID {
+			continue
+		}
+		reactions := make([]MessageReaction, 0, len(msgs[i].Reactions)+1)
+		for _, r := range msgs[i].Reactions {
+			if r.Sender != reaction.Sender {
+				reactions = append(reactions, r)
+			}
+		}
+		if reaction.Emoji != "" {
+			reactions = append(reactions, reaction)
+		}
+		msgs[i].Reactions = reactions
+		return
+	}
+}
+
+// recordOwnReaction stores a reaction sent through the API
+func (m *Manager) recordOwnReaction(inst *Instance, chatID, messageID, emoji string) {
+	if inst.Client == nil || inst.Client.Store.ID == nil {
+		return
+	}
+	m.setStoredReaction(inst.ID, chatID, messageID, MessageReaction{
+		Sender:    inst.Client.Store.ID.ToNonAD().String(),
+		Emoji:     emoji,
+		FromMe:    true,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// GetMessageReactions returns the current reactions to a stored message
+func (m *Manager) GetMessageReactions(instanceID, messageID string) ([]MessageReaction, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	msg, ok := m.findStoredMessage(instanceID, messageID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	if msg.Reactions == nil {
+		return []MessageReaction{}, nil
+	}
+	return msg.Reactions, nil
+}
@@ -0,0 +1,64 @@
+package whatsapp
+
+import (
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleRevoke turns a message deleted for everyone into a tombstone in the
+// store and publishes message_revoked
+func (m *Manager) handleRevoke(inst *Instance, evt *events.Message, protocol *waE2E.ProtocolMessage, paused bool) {
+	messageID := protocol.GetKey().GetID()
+	chat := m.canonicalChatID(evt.Info.Chat.String())
+
+	found := m.revokeStoredMessage(inst.ID, chat, messageID, evt.Info.Timestamp.Unix())
+	log.Debug().Str("instanceId", inst.ID).Str("chat", chat).Str("messageId", messageID).Bool("stored", found).Msg("Message revoked")
+
+	if paused {
+		return
+	}
+	m.publishEvent(Event{
+		Type:       "message_revoked",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"messageId": messageID,
+			"chat":      chat,
+			"sender":    evt.Info.Sender.String(),
+			"fromMe":    evt.Info.IsFromMe,
+			"timestamp": evt.Info.Timestamp.Unix(),
+		},
+	})
+}
+
+// revokeStoredMessage drops the content of a stored message, keeping it as a
+// revoked tombstone. Returns false when the message isn't stored.
+func (m *Manager) revokeStoredMessage(instanceID, chatID, messageID string, revokedAt int64) bool {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID != messageID {
+			continue
+		}
+		stored := msgs[i]
+		msgs[i] = MessageData{
+			ID:            stored.ID,
+			From:          stored.From,
+			To:            stored.To,
+			Type:          stored.Type,
+			Timestamp:     stored.Timestamp,
+			FromMe:        stored.FromMe,
+			IsGroup:       stored.IsGroup,
+			PushName:      stored.PushName,
+			ResolvedPhone: stored.ResolvedPhone,
+			Revoked:       true,
+			RevokedAt:     revokedAt,
+		}
+		return true
+	}
+	return false
+}
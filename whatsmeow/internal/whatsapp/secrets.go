@@ -0,0 +1,96 @@
+package whatsapp
+
+// RedactedSecret replaces secrets in the settings and defaults the API
+// returns. Sending it back in an update keeps the stored secret.
+const RedactedSecret = "***"
+
+// redactSecret hides a secret, keeping whether one is set
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedSecret
+}
+
+// keepSecret returns the stored secret when an update sends the redacted one back
+func keepSecret(sent, stored string) string {
+	if sent == RedactedSecret {
+		return stored
+	}
+	return sent
+}
+
+func (a AIAgent) redacted() AIAgent {
+	a.APIKey = redactSecret(a.APIKey)
+	return a
+}
+
+func (t TypebotConfig) redacted() TypebotConfig {
+	t.APIKey = redactSecret(t.APIKey)
+	return t
+}
+
+func (d DialogflowConfig) redacted() DialogflowConfig {
+	d.Credentials = redactSecret(d.Credentials)
+	return d
+}
+
+// redactedFilterRules returns a copy of filter rules with their webhook secrets hidden
+func redactedFilterRules(rules []FilterRule) []FilterRule {
+	if rules == nil {
+		return nil
+	}
+	out := make([]FilterRule, len(rules))
+	for i, rule := range rules {
+		rule.WebhookSecret = redactSecret(rule.WebhookSecret)
+		out[i] = rule
+	}
+	return out
+}
+
+// keepFilterRuleSecrets restores the webhook secrets of rules sent back
+// redacted, matched by webhook URL
+func keepFilterRuleSecrets(rules, stored []FilterRule) {
+	for i, rule := range rules {
+		if rule.WebhookSecret == RedactedSecret {
+			rules[i].WebhookSecret = filterWebhookSecret(stored, rule.WebhookURL)
+		}
+	}
+}
+
+// redacted returns the defaults with the agent keys, service account
+// credentials, filter rule webhook secrets and proxy passwords hidden
+func (d InstanceDefaults) redacted() InstanceDefaults {
+	d.AIAgent = d.AIAgent.redacted()
+	d.Typebot = d.Typebot.redacted()
+	d.Dialogflow = d.Dialogflow.redacted()
+	d.FilterRules = redactedFilterRules(d.FilterRules)
+	pool := make([]ProxyConfig, len(d.ProxyPool))
+	for i, p := range d.ProxyPool {
+		p.Password = redactSecret(p.Password)
+		pool[i] = p
+	}
+	d.ProxyPool = pool
+	return d
+}
+
+// keepSecrets restores the secrets an update of the defaults sent back redacted.
+// Proxy passwords are matched by host, port and username.
+func (d *InstanceDefaults) keepSecrets(stored InstanceDefaults) {
+	d.AIAgent.APIKey = keepSecret(d.AIAgent.APIKey, stored.AIAgent.APIKey)
+	d.Typebot.APIKey = keepSecret(d.Typebot.APIKey, stored.Typebot.APIKey)
+	d.Dialogflow.Credentials = keepSecret(d.Dialogflow.Credentials, stored.Dialogflow.Credentials)
+	keepFilterRuleSecrets(d.FilterRules, stored.FilterRules)
+	for i, p := range d.ProxyPool {
+		if p.Password != RedactedSecret {
+			continue
+		}
+		d.ProxyPool[i].Password = ""
+		for _, old := range stored.ProxyPool {
+			if old.Host == p.Host && old.Port == p.Port && old.Username == p.Username {
+				d.ProxyPool[i].Password = old.Password
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+	"whatsmeow-service/internal/tracing"
+)
+
+// parseChatJID parses a fully-qualified chat JID: a user (@s.whatsapp.net
+// or @lid), a group (@g.us) or a channel (@newsletter)
+func parseChatJID(chat string) (types.JID, error) {
+	chat = strings.TrimSpace(chat)
+	if !strings.Contains(chat, "@") {
+		return types.JID{}, fmt.Errorf("%w: %q has no server, use a full JID such as 5511999999999@s.whatsapp.net", ErrInvalidJID, chat)
+	}
+	jid, err := types.ParseJID(chat)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%w: %w", ErrInvalidJID, err)
+	}
+
+	switch jid.Server {
+	case types.DefaultUserServer, types.HiddenUserServer, types.GroupServer, types.NewsletterServer:
+		return jid.ToNonAD(), nil
+	default:
+		return types.JID{}, fmt.Errorf("%w: can't send to %s", ErrInvalidJID, chat)
+	}
+}
+
+// jidRecipient resolves the connected instance and chat JID of a send to a
+// raw JID. Unlike phone number sends, the JID is used as is, without checking
+// whether it is on WhatsApp.
+func (m *Manager) jidRecipient(instanceID, chat string) (*Instance, types.JID, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, types.JID{}, fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+
+	inst.mu.RLock()
+	status := inst.Status
+	inst.mu.RUnlock()
+
+	if status != "connected" {
+		return nil, types.JID{}, fmt.Errorf("%w (status: %s)", ErrNotConnected, status)
+	}
+
+	jid, err := parseChatJID(chat)
+	if err != nil {
+		return nil, types.JID{}, err
+	}
+	return inst, jid, nil
+}
+
+// SendTextToJID sends a text message to a chat given by its JID
+func (m *Manager) SendTextToJID(ctx context.Context, instanceID, chat, text string, preview PreviewOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendTextToJID", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, jid, err := m.jidRecipient(instanceID, chat)
+	if err != nil {
+		return "", err
+	}
+
+	// Channels only take plain text posts
+	msg := &waE2E.Message{Conversation: proto.String(text)}
+	if jid.Server != types.NewsletterServer {
+		msg = m.textMessage(ctx, inst, text, preview)
+	}
+
+	resp, err := m.sendTracked(ctx, instanceID, inst.Client, jid, msg)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("jid", jid.String()).Msg("Failed to send message to JID")
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("jid", jid.String()).Str("msgId", resp.ID).Msg("Message sent successfully")
+	return resp.ID, nil
+}
+
+// SendMediaToJID sends a media message to a chat given by its JID. Channels
+// aren't supported, as their media is uploaded differently.
+func (m *Manager) SendMediaToJID(ctx context.Context, instanceID, chat, mediaURL, caption, mediaType string, opts MediaOptions) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendMediaToJID", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, jid, err := m.jidRecipient(instanceID, chat)
+	if err != nil {
+		return "", err
+	}
+	if jid.Server == types.NewsletterServer {
+		return "", fmt.Errorf("%w: media can't be sent to channels", ErrInvalidInput)
+	}
+
+	return m.sendMediaURL(ctx, inst, jid, mediaURL, caption, mediaType, opts)
+}
@@ -0,0 +1,323 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+)
+
+// Header of the encrypted session database file
+const sessionFileMagic = "WMENC1"
+
+// Longest time a commit stays in memory only before the database is written
+const sessionFlushDelay = time.Second
+
+// ParseSessionKey decodes a 32-byte session encryption key given as 64 hex
+// characters or as base64
+func ParseSessionKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("session key must be 32 bytes, hex or base64 encoded")
+}
+
+// encryptedSessionDB keeps the session database in memory and writes it to
+// disk encrypted with AES-256-GCM, so device keys and Signal sessions are
+// never stored in plaintext. Commits are batched: the database is written at
+// most flushDelay after the first commit not on disk yet, in the background,
+// and on Close. A crash loses at most that window of changes.
+type encryptedSessionDB struct {
+	db   *sql.DB
+	path string
+	aead cipher.AEAD
+
+	// Last serialized state, used to seed connections the pool reopens
+	snapshot   []byte
+	snapshotMu sync.Mutex
+
+	// Set by the commit hook until the commit is on disk
+	dirty atomic.Bool
+
+	// Pending background write, started by the first unsaved commit
+	flushDelay time.Duration
+	flushTimer *time.Timer
+	closed     bool
+	timerMu    sync.Mutex
+
+	// Keeps writes of the file in order
+	writeMu sync.Mutex
+}
+
+// openEncryptedSessionDB opens the encrypted database at path. A plaintext
+// database at plainPath is imported and removed once the encrypted copy is
+// written.
+func openEncryptedSessionDB(path, plainPath string, key []byte) (*encryptedSessionDB, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encryptedSessionDB{
+		path:       path,
+		aead:       aead,
+		flushDelay: sessionFlushDelay,
+	}
+
+	migrate := false
+	if data, err := os.ReadFile(path); err == nil {
+		if e.snapshot, err = e.decrypt(data); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read session database: %w", err)
+	} else if _, err := os.Stat(plainPath); err == nil {
+		if e.snapshot, err = serializeFile(plainPath); err != nil {
+			return nil, fmt.Errorf("failed to import plaintext session database: %w", err)
+		}
+		migrate = true
+	}
+
+	// A single long-lived connection holds the in-memory database
+	e.db = sql.OpenDB(sessionConnector{e})
+	e.db.SetMaxOpenConns(1)
+	e.db.SetMaxIdleConns(1)
+	e.db.SetConnMaxLifetime(0)
+	e.db.SetConnMaxIdleTime(0)
+
+	if migrate {
+		e.dirty.Store(true)
+		if err := e.flush(); err != nil {
+			e.db.Close()
+			return nil, err
+		}
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			os.Remove(plainPath + suffix)
+		}
+		log.Info().Str("path", path).Msg("Encrypted existing session database")
+	}
+
+	return e, nil
+}
+
+// sessionConnector opens in-memory connections seeded with the last snapshot
+type sessionConnector struct {
+	e *encryptedSessionDB
+}
+
+func (c sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.Driver().Open("file::memory:?_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	conn := dc.(*sqlite3.SQLiteConn)
+
+	c.e.snapshotMu.Lock()
+	snapshot := c.e.snapshot
+	c.e.snapshotMu.Unlock()
+	if len(snapshot) > 0 {
+		if err := restoreSnapshot(conn, snapshot); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	// The hook runs inside the commit, so the database is serialized later
+	conn.RegisterCommitHook(func() int {
+		c.e.dirty.Store(true)
+		c.e.scheduleFlush()
+		return 0
+	})
+	return conn, nil
+}
+
+func (c sessionConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// restoreSnapshot loads a serialized database into conn. Deserialized
+// databases can't grow, so the snapshot is copied in with the backup API.
+func restoreSnapshot(conn *sqlite3.SQLiteConn, snapshot []byte) error {
+	dc, err := (&sqlite3.SQLiteDriver{}).Open(":memory:")
+	if err != nil {
+		return err
+	}
+	src := dc.(*sqlite3.SQLiteConn)
+	defer src.Close()
+
+	if err := src.Deserialize(snapshot, "main"); err != nil {
+		return err
+	}
+	return copyDatabase(conn, src)
+}
+
+// serializeFile reads a plaintext SQLite database into memory
+func serializeFile(path string) ([]byte, error) {
+	dc, err := (&sqlite3.SQLiteDriver{}).Open(fmt.Sprintf("file:%s?_foreign_keys=on", path))
+	if err != nil {
+		return nil, err
+	}
+	src := dc.(*sqlite3.SQLiteConn)
+	defer src.Close()
+
+	return src.Serialize("main")
+}
+
+// copyDatabase copies the main database of src into dst
+func copyDatabase(dst, src *sqlite3.SQLiteConn) error {
+	backup, err := dst.Backup("main", src, "main")
+	if err != nil {
+		return err
+	}
+	if _, err := backup.Step(-1); err != nil {
+		backup.Finish()
+		return err
+	}
+	return backup.Finish()
+}
+
+// scheduleFlush writes the database in the background after flushDelay,
+// unless a write is already pending
+func (e *encryptedSessionDB) scheduleFlush() {
+	e.timerMu.Lock()
+	defer e.timerMu.Unlock()
+	if e.flushTimer != nil || e.closed {
+		return
+	}
+
+	e.flushTimer = time.AfterFunc(e.flushDelay, func() {
+		e.timerMu.Lock()
+		e.flushTimer = nil
+		e.timerMu.Unlock()
+
+		if err := e.flush(); err != nil {
+			log.Error().Err(err).Msg("Failed to save session database")
+			// Still dirty; tried again after another delay
+			e.scheduleFlush()
+		}
+	})
+}
+
+// flush writes the database to disk when a commit happened since the last
+// write. The connection is held only to serialize the database; encrypting
+// and syncing the file don't block other statements.
+func (e *encryptedSessionDB) flush() error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	if !e.dirty.Load() {
+		return nil
+	}
+
+	conn, err := e.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	var data []byte
+	err = conn.Raw(func(dc any) error {
+		// Commits from here on are left for the next write
+		e.dirty.Store(false)
+		var err error
+		data, err = dc.(*sqlite3.SQLiteConn).Serialize("main")
+		return err
+	})
+	conn.Close()
+	if err != nil {
+		e.dirty.Store(true)
+		return fmt.Errorf("failed to serialize session database: %w", err)
+	}
+
+	if err := e.write(data); err != nil {
+		e.dirty.Store(true)
+		return err
+	}
+
+	e.snapshotMu.Lock()
+	e.snapshot = data
+	e.snapshotMu.Unlock()
+	return nil
+}
+
+// write encrypts data and atomically replaces the database file
+func (e *encryptedSessionDB) write(data []byte) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	out := make([]byte, 0, len(sessionFileMagic)+len(nonce)+len(data)+e.aead.Overhead())
+	out = append(out, sessionFileMagic...)
+	out = append(out, nonce...)
+	out = e.aead.Seal(out, nonce, data, []byte(sessionFileMagic))
+
+	tmp := e.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write session database: %w", err)
+	}
+	if _, err := f.Write(out); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write session database: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write session database: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write session database: %w", err)
+	}
+	if err := os.Rename(tmp, e.path); err != nil {
+		return fmt.Errorf("failed to write session database: %w", err)
+	}
+	return nil
+}
+
+// decrypt opens the contents of an encrypted database file
+func (e *encryptedSessionDB) decrypt(data []byte) ([]byte, error) {
+	header := len(sessionFileMagic) + e.aead.NonceSize()
+	if len(data) < header || string(data[:len(sessionFileMagic)]) != sessionFileMagic {
+		return nil, errors.New("session database is not an encrypted database file")
+	}
+
+	nonce := data[len(sessionFileMagic):header]
+	plain, err := e.aead.Open(nil, nonce, data[header:], []byte(sessionFileMagic))
+	if err != nil {
+		return nil, errors.New("failed to decrypt session database (wrong key or corrupted file)")
+	}
+	return plain, nil
+}
+
+// Close stops background writes and writes changes not saved yet. The
+// database itself is closed by the session store container.
+func (e *encryptedSessionDB) Close() error {
+	e.timerMu.Lock()
+	e.closed = true
+	if e.flushTimer != nil {
+		e.flushTimer.Stop()
+		e.flushTimer = nil
+	}
+	e.timerMu.Unlock()
+
+	return e.flush()
+}
@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testSessionKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+// openTestSessionDB opens the encrypted database of dir, failing the test on error
+func openTestSessionDB(t *testing.T, dir string, key []byte) *encryptedSessionDB {
+	t.Helper()
+	e, err := openEncryptedSessionDB(filepath.Join(dir, "whatsmeow.db.enc"), filepath.Join(dir, "whatsmeow.db"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func closeTestSessionDB(t *testing.T, e *encryptedSessionDB) {
+	t.Helper()
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	e.db.Close()
+}
+
+func TestEncryptedSessionDBRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	e := openTestSessionDB(t, dir, testSessionKey(1))
+	if _, err := e.db.Exec(`CREATE TABLE sessions (id TEXT PRIMARY KEY, data BLOB)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.db.Exec(`INSERT INTO sessions VALUES ('alice', x'cafe')`); err != nil {
+		t.Fatal(err)
+	}
+	closeTestSessionDB(t, e)
+
+	raw, err := os.ReadFile(filepath.Join(dir, "whatsmeow.db.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("alice")) {
+		t.Fatal("session database was written in plaintext")
+	}
+
+	e = openTestSessionDB(t, dir, testSessionKey(1))
+	var data []byte
+	if err := e.db.QueryRow(`SELECT data FROM sessions WHERE id = 'alice'`).Scan(&data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xca, 0xfe}) {
+		t.Fatalf("expected cafe, got %x", data)
+	}
+	closeTestSessionDB(t, e)
+
+	if _, err := openEncryptedSessionDB(filepath.Join(dir, "whatsmeow.db.enc"), filepath.Join(dir, "whatsmeow.db"), testSessionKey(2)); err == nil {
+		t.Fatal("expected opening with the wrong key to fail")
+	}
+}
+
+// Commits reach the disk within the flush delay, without waiting for Close
+func TestEncryptedSessionDBFlushesInBackground(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "whatsmeow.db.enc")
+
+	e := openTestSessionDB(t, dir, testSessionKey(1))
+	defer closeTestSessionDB(t, e)
+	e.flushDelay = 200 * time.Millisecond
+
+	if _, err := e.db.Exec(`CREATE TABLE sessions (id TEXT PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("expected the commit to be batched, not written through")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil && !e.dirty.Load() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("commit was not written within the flush delay")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The file written in the background opens on its own
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.decrypt(data); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,190 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InstanceSettings are the settings of an instance saved in settings.json, so
+// they're applied again when the service restarts
+type InstanceSettings struct {
+	RejectCalls       bool `json:"rejectCalls,omitempty"`
+	AlwaysOnline      bool `json:"alwaysOnline,omitempty"`
+	IgnoreGroups      bool `json:"ignoreGroups,omitempty"`
+	SyncHistory       bool `json:"syncHistory,omitempty"`
+	ReadMessages      bool `json:"readMessages,omitempty"`
+	HideReadReceipts  bool `json:"hideReadReceipts,omitempty"`
+	SkipVideoDownload bool `json:"skipVideoDownload,omitempty"`
+	SkipViewOnceMedia bool `json:"skipViewOnceMedia,omitempty"`
+
+	MediaPolicy MediaPolicy    `json:"mediaPolicy"`
+	Identity    DeviceIdentity `json:"identity"`
+
+	WebhookURL    string   `json:"webhookUrl,omitempty"`
+	WebhookSecret string   `json:"webhookSecret,omitempty"`
+	WebhookEvents []string `json:"webhookEvents,omitempty"`
+	WebhookFormat string   `json:"webhookFormat,omitempty"`
+
+	FilterRules []FilterRule     `json:"filterRules,omitempty"`
+	AwayMessage AwayMessage      `json:"awayMessage"`
+	AIAgent     AIAgent          `json:"aiAgent"`
+	Typebot     TypebotConfig    `json:"typebot"`
+	Dialogflow  DialogflowConfig `json:"dialogflow"`
+
+	CallFollowUpMessage  string        `json:"callFollowUpMessage,omitempty"`
+	CallFollowUpCooldown time.Duration `json:"callFollowUpCooldown,omitempty"`
+
+	ClientLogLevel string `json:"clientLogLevel,omitempty"`
+	OwnerNumber    string `json:"ownerNumber,omitempty"`
+	Paused         bool   `json:"paused,omitempty"`
+}
+
+// settings returns the settings of an instance. Must be called with inst.mu held.
+func (inst *Instance) settings() InstanceSettings {
+	return InstanceSettings{
+		RejectCalls:          inst.RejectCalls,
+		AlwaysOnline:         inst.AlwaysOnline,
+		IgnoreGroups:         inst.IgnoreGroups,
+		SyncHistory:          inst.SyncHistory,
+		ReadMessages:         inst.ReadMessages,
+		HideReadReceipts:     inst.HideReadReceipts,
+		SkipVideoDownload:    inst.SkipVideoDownload,
+		SkipViewOnceMedia:    inst.SkipViewOnceMedia,
+		MediaPolicy:          inst.MediaPolicy,
+		Identity:             inst.Identity,
+		WebhookURL:           inst.WebhookURL,
+		WebhookSecret:        inst.WebhookSecret,
+		WebhookEvents:        inst.WebhookEvents,
+		WebhookFormat:        inst.WebhookFormat,
+		FilterRules:          inst.FilterRules,
+		AwayMessage:          inst.AwayMessage,
+		AIAgent:              inst.AIAgent,
+		Typebot:              inst.Typebot,
+		Dialogflow:           inst.Dialogflow,
+		CallFollowUpMessage:  inst.CallFollowUpMessage,
+		CallFollowUpCooldown: inst.CallFollowUpCooldown,
+		ClientLogLevel:       inst.ClientLogLevel,
+		OwnerNumber:          inst.OwnerNumber,
+		Paused:               inst.Paused,
+	}
+}
+
+// setSettings replaces the settings of an instance. Must be called with inst.mu held.
+func (inst *Instance) setSettings(s InstanceSettings) {
+	inst.RejectCalls = s.RejectCalls
+	inst.AlwaysOnline = s.AlwaysOnline
+	inst.IgnoreGroups = s.IgnoreGroups
+	inst.SyncHistory = s.SyncHistory
+	inst.ReadMessages = s.ReadMessages
+	inst.HideReadReceipts = s.HideReadReceipts
+	inst.SkipVideoDownload = s.SkipVideoDownload
+	inst.SkipViewOnceMedia = s.SkipViewOnceMedia
+	inst.MediaPolicy = s.MediaPolicy
+	inst.Identity = s.Identity
+	inst.WebhookURL = s.WebhookURL
+	inst.WebhookSecret = s.WebhookSecret
+	inst.WebhookEvents = s.WebhookEvents
+	inst.WebhookFormat = s.WebhookFormat
+	inst.FilterRules = s.FilterRules
+	inst.AwayMessage = s.AwayMessage
+	inst.AIAgent = s.AIAgent
+	inst.Typebot = s.Typebot
+	inst.Dialogflow = s.Dialogflow
+	inst.CallFollowUpMessage = s.CallFollowUpMessage
+	inst.CallFollowUpCooldown = s.CallFollowUpCooldown
+	inst.OwnerNumber = s.OwnerNumber
+	inst.Paused = s.Paused
+
+	inst.ClientLogLevel = s.ClientLogLevel
+	level := clientLogLevelUnset
+	if parsed, err := parseLogLevel(s.ClientLogLevel); s.ClientLogLevel != "" && err == nil {
+		level = int32(parsed)
+	}
+	inst.clientLog.level.Store(level)
+}
+
+// loadSettings loads the saved settings of instances from file
+func (m *Manager) loadSettings() {
+	data, err := os.ReadFile(m.settingsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load instance settings")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.settings); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal instance settings")
+	}
+}
+
+// saveSettings records the current settings of an instance so they survive restarts
+func (m *Manager) saveSettings(inst *Instance) {
+	inst.mu.RLock()
+	settings := inst.settings()
+	inst.mu.RUnlock()
+
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+	m.settings[inst.ID] = settings
+	m.writeSettings()
+}
+
+// forgetSettings removes the saved settings of a purged instance
+func (m *Manager) forgetSettings(instanceID string) {
+	m.settingsMu.Lock()
+	defer m.settingsMu.Unlock()
+
+	if _, ok := m.settings[instanceID]; !ok {
+		return
+	}
+	delete(m.settings, instanceID)
+	m.writeSettings()
+}
+
+// writeSettings persists the saved settings. Must be called with settingsMu held.
+func (m *Manager) writeSettings() {
+	data, err := json.MarshalIndent(m.settings, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal instance settings")
+		return
+	}
+
+	// Holds webhook secrets and agent credentials
+	if err := os.WriteFile(m.settingsFile, data, 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to save instance settings")
+	}
+}
+
+// applyStoredSettings gives an instance its saved settings. Filter rules and
+// the away message are compiled again, since their matchers aren't saved.
+// Reports whether the instance had any.
+func (m *Manager) applyStoredSettings(inst *Instance) bool {
+	m.settingsMu.Lock()
+	settings, ok := m.settings[inst.ID]
+	m.settingsMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	if rules, err := m.compileFilterRules(settings.FilterRules); err == nil {
+		settings.FilterRules = rules
+	} else {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Ignoring invalid saved filter rules")
+		settings.FilterRules = nil
+	}
+	if away, err := m.compileAwayMessage(settings.AwayMessage); err == nil {
+		settings.AwayMessage = away
+	} else {
+		log.Warn().Err(err).Str("instanceId", inst.ID).Msg("Ignoring invalid saved away message")
+		settings.AwayMessage = AwayMessage{}
+	}
+
+	inst.mu.Lock()
+	inst.setSettings(settings)
+	inst.mu.Unlock()
+	return true
+}
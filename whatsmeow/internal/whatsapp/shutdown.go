@@ -0,0 +1,135 @@
+package whatsapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// How often Shutdown checks whether background work has finished
+const shutdownPollInterval = 100 * time.Millisecond
+
+// Shutdown stops the manager gracefully: queued sends finish, instances are
+// disconnected and marked as such, and pending webhook deliveries and media
+// downloads get until ctx expires to complete before the event journal and
+// the session store are closed.
+func (m *Manager) Shutdown(ctx context.Context) {
+	// Outbox flushes started by a reconnect are in-flight sends
+	if !m.waitUntil(ctx, m.sendsDone) {
+		log.Warn().Msg("Shutdown timeout reached with queued messages still being sent")
+	}
+
+	m.mu.RLock()
+	instances := make([]*Instance, 0, len(m.instances))
+	for _, inst := range m.instances {
+		instances = append(instances, inst)
+	}
+	m.mu.RUnlock()
+
+	for _, inst := range instances {
+		m.stopQRIdleTimer(inst)
+		m.stopQRRotation(inst)
+		inst.Client.Disconnect()
+
+		inst.mu.Lock()
+		wasConnected := inst.Status == "connected"
+		inst.Status = "disconnected"
+		inst.mu.Unlock()
+
+		if wasConnected {
+			m.publishEvent(Event{
+				Type:       "disconnected",
+				InstanceID: inst.ID,
+				Data:       map[string]interface{}{"reason": "shutdown"},
+			})
+		}
+	}
+
+	// Messages held for disconnected instances only live in memory
+	m.outbox.mu.Lock()
+	queued := 0
+	for _, msgs := range m.outbox.pending {
+		queued += len(msgs)
+	}
+	m.outbox.mu.Unlock()
+	if queued > 0 {
+		log.Warn().Int("messages", queued).Msg("Discarding queued messages on shutdown")
+	}
+
+	m.wakeAllWebhookWorkers()
+	if !m.waitUntil(ctx, func() bool { return m.webhooksDrained(ctx) && m.mediaDownloadsDone() }) {
+		log.Warn().Msg("Shutdown timeout reached with webhook deliveries or media downloads pending")
+	}
+
+	if err := m.eventsDB.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close events database")
+	}
+	if m.sessionDB != nil {
+		if err := m.sessionDB.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to save encrypted session store")
+		}
+	}
+	if err := m.container.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close session store")
+	}
+	log.Info().Int("instances", len(instances)).Msg("Manager stopped")
+}
+
+// waitUntil polls done until it reports true or ctx expires
+func (m *Manager) waitUntil(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// sendsDone reports whether no outbox flush is running
+func (m *Manager) sendsDone() bool {
+	m.outbox.mu.Lock()
+	defer m.outbox.mu.Unlock()
+	return m.outbox.flushing == 0
+}
+
+// mediaDownloadsDone reports whether no background media download is queued or running
+func (m *Manager) mediaDownloadsDone() bool {
+	m.mediaDownloads.mu.Lock()
+	defer m.mediaDownloads.mu.Unlock()
+	return m.mediaDownloads.pending == 0
+}
+
+// wakeAllWebhookWorkers makes every webhook worker catch up with the journal,
+// including events it skipped waking up for
+func (m *Manager) wakeAllWebhookWorkers() {
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+
+	for instanceID := range m.webhooks.wake {
+		m.wakeWebhookWorker(instanceID)
+	}
+}
+
+// webhooksDrained reports whether every webhook worker has handled all journaled events
+func (m *Manager) webhooksDrained(ctx context.Context) bool {
+	m.webhooks.mu.Lock()
+	cursors := make(map[string]int64, len(m.webhooks.cursors))
+	for instanceID, cursor := range m.webhooks.cursors {
+		cursors[instanceID] = cursor
+	}
+	m.webhooks.mu.Unlock()
+
+	for instanceID, cursor := range cursors {
+		events, err := m.journal.After(ctx, instanceID, cursor, 1)
+		if err != nil || len(events) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,280 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Default time a logged out instance is kept before being purged
+const defaultDeleteGrace = 7 * 24 * time.Hour
+
+// DeletedInstance represents a logged out instance awaiting purge
+type DeletedInstance struct {
+	InstanceID string `json:"instanceId"`
+	WANumber   string `json:"waNumber,omitempty"`
+	WAName     string `json:"waName,omitempty"`
+	DeletedAt  int64  `json:"deletedAt"`
+	PurgeAt    int64  `json:"purgeAt"`
+}
+
+// SetDeleteGracePeriod sets how long logged out instances are kept before purge
+func (m *Manager) SetDeleteGracePeriod(grace time.Duration) {
+	m.deletedMu.Lock()
+	m.deleteGrace = grace
+	m.deletedMu.Unlock()
+}
+
+// loadDeleted loads soft-deleted instances from file
+func (m *Manager) loadDeleted() {
+	data, err := os.ReadFile(m.deletedFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load deleted instances")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.deleted); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal deleted instances")
+	}
+}
+
+// saveDeleted saves soft-deleted instances to file (caller must hold deletedMu)
+func (m *Manager) saveDeleted() {
+	data, err := json.MarshalIndent(m.deleted, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal deleted instances")
+		return
+	}
+
+	if err := os.WriteFile(m.deletedFile, data, 0644); err != nil {
+		log.Error().Err(err).Msg("Failed to save deleted instances")
+	}
+}
+
+// isDeleted reports whether an instance is soft-deleted
+func (m *Manager) isDeleted(instanceID string) bool {
+	m.deletedMu.Lock()
+	defer m.deletedMu.Unlock()
+	_, ok := m.deleted[instanceID]
+	return ok
+}
+
+// softDelete marks a logged out instance as deleted until the grace period
+// ends. Its settings, proxy and events are kept on disk; messages and media
+// are only kept in memory, so a restart loses them.
+func (m *Manager) softDelete(inst *Instance) {
+	inst.mu.Lock()
+	entry := &DeletedInstance{
+		InstanceID: inst.ID,
+		WANumber:   inst.WANumber,
+		WAName:     inst.WAName,
+	}
+	inst.Status = "deleted"
+	inst.WANumber = ""
+	inst.WAName = ""
+	inst.mu.Unlock()
+
+	now := time.Now()
+	m.deletedMu.Lock()
+	entry.DeletedAt = now.Unix()
+	entry.PurgeAt = now.Add(m.deleteGrace).Unix()
+	m.deleted[inst.ID] = entry
+	m.saveDeleted()
+	m.deletedMu.Unlock()
+
+	log.Warn().Str("instanceId", inst.ID).Time("purgeAt", time.Unix(entry.PurgeAt, 0)).Msg("Instance soft-deleted")
+	m.publishEvent(Event{
+		Type:       "instance_deleted",
+		InstanceID: inst.ID,
+		Data:       *entry,
+	})
+}
+
+// GetDeletedInstances lists soft-deleted instances
+func (m *Manager) GetDeletedInstances() []DeletedInstance {
+	m.deletedMu.Lock()
+	defer m.deletedMu.Unlock()
+
+	result := make([]DeletedInstance, 0, len(m.deleted))
+	for _, entry := range m.deleted {
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// RestoreInstance undoes a soft delete, with the instance's settings, proxy
+// and events, and its messages unless the service restarted since. The
+// WhatsApp session itself was logged out, so the instance must be paired again.
+func (m *Manager) RestoreInstance(instanceID string) error {
+	if !m.isDeleted(instanceID) {
+		return fmt.Errorf("%w: %s is not deleted", ErrInstanceNotFound, instanceID)
+	}
+	if err := m.claimInstance(instanceID); err != nil {
+		return err
+	}
+
+	m.deletedMu.Lock()
+	if _, ok := m.deleted[instanceID]; !ok {
+		m.deletedMu.Unlock()
+		return fmt.Errorf("%w: %s is not deleted", ErrInstanceNotFound, instanceID)
+	}
+	delete(m.deleted, instanceID)
+	m.saveDeleted()
+	m.deletedMu.Unlock()
+
+	m.mu.Lock()
+	old, hadOld := m.instances[instanceID]
+	fresh := m.newInstance(instanceID)
+	if hadOld {
+		copySettings(old, fresh)
+
+		if fresh.ProxyHost != "" {
+			proxyURL := m.buildProxyURL(fresh.ProxyHost, fresh.ProxyPort, fresh.ProxyUsername, fresh.ProxyPassword, fresh.ProxyProtocol)
+			if err := fresh.Client.SetProxyAddress(proxyURL); err != nil {
+				log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to restore proxy")
+			}
+		}
+	} else {
+		// Deleted before a restart; the saved settings and proxy are applied
+		m.applyDefaults(fresh)
+	}
+	m.instances[instanceID] = fresh
+	m.mu.Unlock()
+
+	log.Info().Str("instanceId", instanceID).Msg("Instance restored")
+	m.publishEvent(Event{
+		Type:       "instance_restored",
+		InstanceID: instanceID,
+	})
+	return nil
+}
+
+// PurgeInstance permanently removes a soft-deleted instance and its history
+func (m *Manager) PurgeInstance(instanceID string) error {
+	m.deletedMu.Lock()
+	if _, ok := m.deleted[instanceID]; !ok {
+		m.deletedMu.Unlock()
+		return fmt.Errorf("%w: %s is not deleted", ErrInstanceNotFound, instanceID)
+	}
+	delete(m.deleted, instanceID)
+	m.saveDeleted()
+	m.deletedMu.Unlock()
+
+	m.mu.Lock()
+	delete(m.instances, instanceID)
+	if _, ok := m.mapping[instanceID]; ok {
+		delete(m.mapping, instanceID)
+		m.saveMapping()
+	}
+	m.mu.Unlock()
+
+	if m.cluster != nil {
+		m.cluster.Release(instanceID)
+	}
+
+	m.messagesMu.Lock()
+	delete(m.messages, instanceID)
+	delete(m.chatStates, instanceID)
+	m.messagesMu.Unlock()
+
+	m.saveProxy(instanceID, ProxyConfig{})
+	m.forgetSettings(instanceID)
+	m.RevokeInstanceToken(instanceID)
+	m.releaseInstance(instanceID)
+
+	m.eventDrops.mu.Lock()
+	delete(m.eventDrops.byInstance, instanceID)
+	m.eventDrops.mu.Unlock()
+
+	m.thumbnailsMu.Lock()
+	for key := range m.thumbnails {
+		if strings.HasPrefix(key, instanceID+"/") {
+			delete(m.thumbnails, key)
+		}
+	}
+	m.thumbnailsMu.Unlock()
+
+	m.calls.mu.Lock()
+	delete(m.calls.entries, instanceID)
+	delete(m.calls.lastFollowUp, instanceID)
+	m.calls.mu.Unlock()
+
+	m.presence.mu.Lock()
+	delete(m.presence.entries, instanceID)
+	m.presence.mu.Unlock()
+
+	m.dropOutbox(instanceID)
+	m.dropLiveLocations(instanceID)
+	m.dropWebhooks(instanceID)
+	m.dropAutoReplies(instanceID)
+	m.dropTypebotSessions(instanceID)
+	m.dropDialogflowSessions(instanceID)
+	m.dropCampaigns(instanceID)
+	m.resetMediaConcurrency(instanceID)
+
+	// The audit log keeps its entries until they expire
+	for _, purge := range []func(string) error{
+		m.journal.DeleteInstance,
+		m.messageStatuses.deleteInstance,
+		m.usageStore.deleteInstance,
+		m.addressBookStore.deleteInstance,
+		m.campaignStore.deleteInstance,
+	} {
+		if err := purge(instanceID); err != nil {
+			log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to purge instance data")
+		}
+	}
+
+	log.Warn().Str("instanceId", instanceID).Msg("Instance purged")
+	return nil
+}
+
+// DeleteInstance logs an instance out, if it's paired, and removes it with
+// its history right away instead of after the grace period
+func (m *Manager) DeleteInstance(instanceID string) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+
+	if !m.isDeleted(instanceID) {
+		if inst.Client.Store.ID != nil {
+			if err := inst.Client.Logout(context.Background()); err != nil {
+				return fmt.Errorf("failed to logout: %w", err)
+			}
+		}
+		inst.Client.Disconnect()
+		m.softDelete(inst)
+	}
+	return m.PurgeInstance(instanceID)
+}
+
+// purgeLoop periodically purges instances whose grace period has ended
+func (m *Manager) purgeLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now().Unix()
+
+		m.deletedMu.Lock()
+		expired := make([]string, 0)
+		for id, entry := range m.deleted {
+			if entry.PurgeAt <= now {
+				expired = append(expired, id)
+			}
+		}
+		m.deletedMu.Unlock()
+
+		for _, id := range expired {
+			m.PurgeInstance(id)
+		}
+	}
+}
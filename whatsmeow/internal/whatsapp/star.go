@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/appstate"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// StarMessage stars or unstars a message on all linked devices. Messages not in
+// the store are assumed to be incoming 1:1 messages unless fromMe is set.
+func (m *Manager) StarMessage(instanceID, chatID, messageID string, fromMe, starred bool) error {
+	inst, chatJID, err := m.resolveRecipient(instanceID, chatID)
+	if err != nil {
+		return err
+	}
+
+	sender := chatJID
+	if stored, ok := m.findStoredMessage(instanceID, messageID); ok {
+		fromMe = stored.FromMe
+		if stored.IsGroup && !stored.FromMe {
+			if senderJID, err := types.ParseJID(stored.From); err == nil {
+				sender = senderJID
+			}
+		}
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("chatJID", chatJID.String()).Str("messageId", messageID).Bool("starred", starred).Msg("Starring message")
+
+	patch := appstate.BuildStar(chatJID, sender, types.MessageID(messageID), fromMe, starred)
+	if err := inst.Client.SendAppState(context.Background(), patch); err != nil {
+		return fmt.Errorf("failed to star message: %w", err)
+	}
+
+	m.setStoredStarred(instanceID, chatJID.String(), messageID, starred)
+	return nil
+}
+
+// handleStar records a star change made on another device and publishes it
+func (m *Manager) handleStar(inst *Instance, evt *events.Star) {
+	starred := evt.Action.GetStarred()
+	m.setStoredStarred(inst.ID, evt.ChatJID.String(), evt.MessageID, starred)
+
+	if evt.FromFullSync {
+		return
+	}
+	m.publishEvent(Event{
+		Type:       "message_star",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"messageId": evt.MessageID,
+			"chat":      evt.ChatJID.String(),
+			"fromMe":    evt.IsFromMe,
+			"starred":   starred,
+			"timestamp": evt.Timestamp.Unix(),
+		},
+	})
+}
+
+// setStoredStarred updates the starred flag of a stored message
+func (m *Manager) setStoredStarred(instanceID, chatID, messageID string, starred bool) {
+	chatID = m.canonicalChatID(chatID)
+
+	m.messagesMu.Lock()
+	defer m.messagesMu.Unlock()
+
+	msgs := m.messages[instanceID][chatID]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].ID == messageID {
+			msgs[i].Starred = starred
+			return
+		}
+	}
+}
+
+// GetStarredMessages returns the starred stored messages of an instance, newest
+// first, optionally limited to one chat
+func (m *Manager) GetStarredMessages(instanceID, chatID string) ([]MessageData, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	if chatID != "" {
+		chatID = m.canonicalChatID(chatID)
+	}
+
+	m.messagesMu.RLock()
+	defer m.messagesMu.RUnlock()
+
+	starred := make([]MessageData, 0)
+	for chat, msgs := range m.messages[instanceID] {
+		if chatID != "" && chat != chatID {
+			continue
+		}
+		for _, msg := range msgs {
+			if msg.Starred {
+				starred = append(starred, msg)
+			}
+		}
+	}
+
+	sort.Slice(starred, func(i, j int) bool {
+		return starred[i].Timestamp > starred[j].Timestamp
+	})
+	return starred, nil
+}
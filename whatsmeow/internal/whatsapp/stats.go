@@ -0,0 +1,133 @@
+package whatsapp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Usage counters kept per instance and day
+const (
+	statMessagesSent       = "messages_sent"
+	statMessagesReceived   = "messages_received"
+	statMediaBytesSent     = "media_bytes_sent"
+	statMediaBytesReceived = "media_bytes_received"
+	statCallsRejected      = "calls_rejected"
+	statReconnects         = "reconnects"
+)
+
+// Longest daily history returned by the stats endpoint
+const maxStatsDays = 366
+
+// UsageCounters are the usage counters of an instance over a period
+type UsageCounters struct {
+	MessagesSent       int64 `json:"messagesSent"`
+	MessagesReceived   int64 `json:"messagesReceived"`
+	MediaBytesSent     int64 `json:"mediaBytesSent"`
+	MediaBytesReceived int64 `json:"mediaBytesReceived"`
+	CallsRejected      int64 `json:"callsRejected"`
+	Reconnects         int64 `json:"reconnects"`
+}
+
+// DailyUsage is the usage of an instance on a day (server time)
+type DailyUsage struct {
+	Day string `json:"day"` // YYYY-MM-DD
+	UsageCounters
+}
+
+// InstanceStats is the usage of an instance for billing and monitoring
+type InstanceStats struct {
+	InstanceID     string        `json:"instanceId"`
+	Status         string        `json:"status"`
+	ConnectedSince int64         `json:"connectedSince,omitempty"` // Unix time of the current connection
+	UptimeSeconds  int64         `json:"uptimeSeconds"`            // Length of the current connection
+	Today          UsageCounters `json:"today"`
+	Month          UsageCounters `json:"month"`
+	Total          UsageCounters `json:"total"`
+	Days           []DailyUsage  `json:"days,omitempty"` // Most recent first, when requested
+}
+
+// add sets a counter by its stored name
+func (c *UsageCounters) add(counter string, value int64) {
+	switch counter {
+	case statMessagesSent:
+		c.MessagesSent += value
+	case statMessagesReceived:
+		c.MessagesReceived += value
+	case statMediaBytesSent:
+		c.MediaBytesSent += value
+	case statMediaBytesReceived:
+		c.MediaBytesReceived += value
+	case statCallsRejected:
+		c.CallsRejected += value
+	case statReconnects:
+		c.Reconnects += value
+	}
+}
+
+// countUsage adds to a usage counter of an instance for today
+func (m *Manager) countUsage(instanceID, counter string, value int64) {
+	if value <= 0 {
+		return
+	}
+	if err := m.usageStore.AddUsage(instanceID, time.Now().Format(time.DateOnly), counter, value); err != nil {
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("counter", counter).Msg("Failed to count usage")
+	}
+}
+
+// GetInstanceStats returns the usage counters of an instance for today, this
+// month and all time, plus the daily history of the last days (0 for none)
+func (m *Manager) GetInstanceStats(instanceID string, days int) (*InstanceStats, error) {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return nil, ErrInstanceNotFound
+	}
+	if days < 0 || days > maxStatsDays {
+		return nil, fmt.Errorf("%w: days must be between 0 and %d", ErrInvalidInput, maxStatsDays)
+	}
+
+	inst.mu.RLock()
+	stats := &InstanceStats{InstanceID: instanceID, Status: inst.Status}
+	if inst.Status == "connected" && !inst.connectedAt.IsZero() {
+		stats.ConnectedSince = inst.connectedAt.Unix()
+		stats.UptimeSeconds = int64(time.Since(inst.connectedAt).Seconds())
+	}
+	inst.mu.RUnlock()
+
+	usage, err := m.usageStore.Usage(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	today := now.Format(time.DateOnly)
+	month := now.Format("2006-01")
+	oldest := now.AddDate(0, 0, 1-days).Format(time.DateOnly)
+	byDay := make(map[string]*DailyUsage)
+	for _, row := range usage {
+		stats.Total.add(row.counter, row.value)
+		if row.day[:7] == month {
+			stats.Month.add(row.counter, row.value)
+		}
+		if row.day == today {
+			stats.Today.add(row.counter, row.value)
+		}
+		if days > 0 && row.day >= oldest {
+			if byDay[row.day] == nil {
+				byDay[row.day] = &DailyUsage{Day: row.day}
+			}
+			byDay[row.day].add(row.counter, row.value)
+		}
+	}
+
+	for i := 0; i < days; i++ {
+		day := now.AddDate(0, 0, -i).Format(time.DateOnly)
+		if usage, ok := byDay[day]; ok {
+			stats.Days = append(stats.Days, *usage)
+		} else {
+			stats.Days = append(stats.Days, DailyUsage{Day: day})
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,156 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// StatusPost describes a status (story) to publish
+type StatusPost struct {
+	Type            string // text, image or video
+	Text            string // Text of a text status
+	MediaURL        string // URL or data URI of an image/video status
+	Caption         string
+	BackgroundColor string // #RRGGBB or #AARRGGBB, text statuses only
+	TextColor       string // #RRGGBB or #AARRGGBB, text statuses only
+	Font            int    // WhatsApp font index, text statuses only
+}
+
+// parseARGB converts #RRGGBB or #AARRGGBB into an ARGB value
+func parseARGB(color string) (uint32, error) {
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) == 6 {
+		hex = "ff" + hex
+	}
+	if len(hex) != 8 {
+		return 0, fmt.Errorf("%w: invalid color %q (use #RRGGBB)", ErrInvalidInput, color)
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid color %q (use #RRGGBB)", ErrInvalidInput, color)
+	}
+	return uint32(value), nil
+}
+
+// SendStatus publishes a status to status@broadcast. Recipients are resolved
+// by whatsmeow from the account's status privacy settings.
+func (m *Manager) SendStatus(instanceID string, post StatusPost) (string, error) {
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	var msg *waE2E.Message
+	switch post.Type {
+	case "text":
+		if post.Text == "" {
+			return "", fmt.Errorf("%w: text is required for text statuses", ErrInvalidInput)
+		}
+		extMsg := &waE2E.ExtendedTextMessage{
+			Text: proto.String(post.Text),
+			Font: waE2E.ExtendedTextMessage_FontType(post.Font).Enum(),
+		}
+		if post.BackgroundColor != "" {
+			argb, err := parseARGB(post.BackgroundColor)
+			if err != nil {
+				return "", err
+			}
+			extMsg.BackgroundArgb = proto.Uint32(argb)
+		}
+		if post.TextColor != "" {
+			argb, err := parseARGB(post.TextColor)
+			if err != nil {
+				return "", err
+			}
+			extMsg.TextArgb = proto.Uint32(argb)
+		}
+		msg = &waE2E.Message{ExtendedTextMessage: extMsg}
+
+	case "image", "video":
+		if post.MediaURL == "" {
+			return "", fmt.Errorf("%w: mediaUrl is required for %s statuses", ErrInvalidInput, post.Type)
+		}
+		inst, ok := m.GetInstance(instanceID)
+		if !ok {
+			return "", ErrInstanceNotFound
+		}
+		transport, err := m.proxyTransport(inst)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+		}
+		data, mimeType, _, err := loadMedia(transport, post.MediaURL)
+		if err != nil {
+			return "", err
+		}
+
+		appMedia := whatsmeow.MediaImage
+		if post.Type == "video" {
+			appMedia = whatsmeow.MediaVideo
+		}
+		uploaded, err := client.Upload(context.Background(), data, appMedia)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrMediaUploadFailed, err)
+		}
+		m.countUsage(instanceID, statMediaBytesSent, int64(uploaded.FileLength))
+
+		if post.Type == "image" {
+			msg = &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+				Caption:       proto.String(post.Caption),
+				URL:           proto.String(uploaded.URL),
+				DirectPath:    proto.String(uploaded.DirectPath),
+				MediaKey:      uploaded.MediaKey,
+				Mimetype:      proto.String(mimeType),
+				FileEncSHA256: uploaded.FileEncSHA256,
+				FileSHA256:    uploaded.FileSHA256,
+				FileLength:    proto.Uint64(uint64(len(data))),
+			}}
+		} else {
+			msg = &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+				Caption:       proto.String(post.Caption),
+				URL:           proto.String(uploaded.URL),
+				DirectPath:    proto.String(uploaded.DirectPath),
+				MediaKey:      uploaded.MediaKey,
+				Mimetype:      proto.String(mimeType),
+				FileEncSHA256: uploaded.FileEncSHA256,
+				FileSHA256:    uploaded.FileSHA256,
+				FileLength:    proto.Uint64(uint64(len(data))),
+			}}
+		}
+
+	default:
+		return "", fmt.Errorf("%w: unsupported status type: %s", ErrInvalidInput, post.Type)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("type", post.Type).Msg("Publishing status")
+
+	resp, err := client.SendMessage(context.Background(), types.StatusBroadcastJID, msg)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return resp.ID, nil
+}
+
+// publishStatusUpdate publishes a status posted by a contact
+func (m *Manager) publishStatusUpdate(inst *Instance, msg *events.Message) {
+	msgData := m.formatMessage(inst.ID, msg)
+
+	m.publishEvent(Event{
+		Type:       "status_update",
+		InstanceID: inst.ID,
+		Data:       msgData,
+	})
+	if !msgData.FromMe {
+		m.countUsage(inst.ID, statMediaBytesReceived, int64(msgData.FileLength))
+	}
+	m.queueMediaDownload(inst, msgData)
+}
@@ -0,0 +1,335 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"golang.org/x/image/webp"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+	"whatsmeow-service/internal/tracing"
+)
+
+// Stickers are square WebP images of this many pixels per side
+const stickerSize = 512
+
+// Longest an animated sticker converted from a GIF or video plays
+const maxStickerSeconds = 8
+
+// StickerMetadata is the pack shown when a recipient opens a sticker
+type StickerMetadata struct {
+	PackID    string   `json:"packId,omitempty"`
+	PackName  string   `json:"packName,omitempty"`
+	Publisher string   `json:"packPublisher,omitempty"`
+	Emojis    []string `json:"emojis,omitempty"`
+}
+
+// GetSticker returns a received or sent sticker as WebP, or as PNG when format
+// is "png". Animated stickers are only available as WebP.
+func (m *Manager) GetSticker(ctx context.Context, instanceID, messageID, format string) (*MessageMedia, error) {
+	switch format {
+	case "", "webp", "png":
+	default:
+		return nil, fmt.Errorf("%w: format must be webp or png", ErrInvalidInput)
+	}
+
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+	msg, ok := m.findStoredMessage(instanceID, messageID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	if msg.Type != "sticker" {
+		return nil, fmt.Errorf("%w: %s is a %s message, not a sticker", ErrInvalidInput, messageID, msg.Type)
+	}
+
+	media, err := m.FetchMessageMedia(ctx, instanceID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if format != "png" {
+		return &MessageMedia{Data: media.Data, Mimetype: "image/webp", FileName: messageID + ".webp"}, nil
+	}
+
+	if webpAnimated(media.Data) {
+		return nil, fmt.Errorf("%w: animated stickers are only available as webp", ErrInvalidInput)
+	}
+	img, err := webp.Decode(bytes.NewReader(media.Data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sticker: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode sticker: %w", err)
+	}
+	return &MessageMedia{Data: buf.Bytes(), Mimetype: "image/png", FileName: messageID + ".png"}, nil
+}
+
+// SendSticker converts an image, GIF or video to a WebP sticker with the pack
+// metadata set and sends it. Animated WebP is sent as it is, with only its
+// metadata replaced.
+func (m *Manager) SendSticker(ctx context.Context, instanceID, to, mediaURL string, meta StickerMetadata) (string, error) {
+	ctx, span := tracing.Start(ctx, "Manager.SendSticker", attribute.String("whatsapp.instance_id", instanceID))
+	defer span.End()
+
+	inst, jid, err := m.mediaRecipient(ctx, instanceID, to)
+	if err != nil {
+		return "", err
+	}
+	transport, err := m.proxyTransport(inst)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMediaDownloadFailed, err)
+	}
+	data, mimeType, _, err := loadMedia(transport, mediaURL)
+	if err != nil {
+		return "", err
+	}
+
+	sticker, animated, err := convertSticker(data, mimeType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	sticker, err = setStickerMetadata(sticker, meta)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	log.Info().Str("instanceId", instanceID).Str("mimeType", mimeType).Bool("animated", animated).Msg("Uploading sticker")
+
+	uploaded, err := tracedUpload(ctx, inst.Client, sticker, whatsmeow.MediaImage)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrMediaUploadFailed, err)
+	}
+	m.countUsage(instanceID, statMediaBytesSent, int64(uploaded.FileLength))
+
+	msg := &waE2E.Message{StickerMessage: &waE2E.StickerMessage{
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		Mimetype:      proto.String("image/webp"),
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+		Width:         proto.Uint32(stickerSize),
+		Height:        proto.Uint32(stickerSize),
+		IsAnimated:    proto.Bool(animated),
+	}}
+
+	sentResp, err := m.sendTracked(ctx, inst.ID, inst.Client, jid, msg)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+
+	return sentResp.ID, nil
+}
+
+// convertSticker turns media into a 512x512 WebP with ffmpeg, padding it with
+// transparency to keep its aspect ratio. GIFs and videos become animated
+// stickers. Animated WebP, which ffmpeg can't decode, is returned unchanged.
+func convertSticker(data []byte, mimeType string) ([]byte, bool, error) {
+	if webpAnimated(data) {
+		return data, true, nil
+	}
+
+	animated := mimeType == "image/gif" || strings.HasPrefix(mimeType, "video/")
+	if !animated && !strings.HasPrefix(mimeType, "image/") {
+		return nil, false, fmt.Errorf("stickers can only be made from images, GIFs and videos, not %s", mimeType)
+	}
+
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, false, fmt.Errorf("ffmpeg is required to convert stickers")
+	}
+
+	in, err := os.CreateTemp("", "whatsmeow-sticker-*")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(in.Name())
+	_, err = in.Write(data)
+	in.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	out := in.Name() + ".webp"
+	defer os.Remove(out)
+
+	filter := fmt.Sprintf("scale=%[1]d:%[1]d:force_original_aspect_ratio=decrease,format=rgba,pad=%[1]d:%[1]d:(ow-iw)/2:(oh-ih)/2:color=0x00000000", stickerSize)
+	args := []string{"-v", "error", "-y", "-i", in.Name(), "-an"}
+	if animated {
+		args = append(args, "-t", fmt.Sprint(maxStickerSeconds), "-vf", "fps=15,"+filter, "-c:v", "libwebp_anim", "-loop", "0", "-q:v", "60")
+	} else {
+		args = append(args, "-vf", filter, "-frames:v", "1", "-c:v", "libwebp", "-q:v", "80")
+	}
+	args = append(args, "-f", "webp", out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, false, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	sticker, err := os.ReadFile(out)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read converted sticker: %w", err)
+	}
+	return sticker, animated, nil
+}
+
+// WebP chunk layout (https://developers.google.com/speed/webp/docs/riff_container)
+const (
+	webpFlagAnimation = 0x02
+	webpFlagEXIF      = 0x08
+	webpFlagAlpha     = 0x10
+)
+
+// webpChunk is one chunk of a WebP RIFF container
+type webpChunk struct {
+	fourCC string
+	data   []byte
+}
+
+// parseWebP splits a WebP file into its chunks
+func parseWebP(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP image")
+	}
+
+	var chunks []webpChunk
+	for rest := data[12:]; len(rest) >= 8; {
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size > len(rest)-8 {
+			return nil, fmt.Errorf("truncated WebP chunk %q", rest[0:4])
+		}
+		chunks = append(chunks, webpChunk{fourCC: string(rest[0:4]), data: rest[8 : 8+size]})
+		// Chunks are padded to an even size
+		next := 8 + size + size%2
+		if next > len(rest) {
+			break
+		}
+		rest = rest[next:]
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("empty WebP image")
+	}
+	return chunks, nil
+}
+
+// webpAnimated reports whether data is an animated WebP
+func webpAnimated(data []byte) bool {
+	chunks, err := parseWebP(data)
+	if err != nil || chunks[0].fourCC != "VP8X" || len(chunks[0].data) < 1 {
+		return false
+	}
+	return chunks[0].data[0]&webpFlagAnimation != 0
+}
+
+// setStickerMetadata replaces the EXIF of a WebP sticker with the pack
+// metadata WhatsApp reads, converting a simple WebP to the extended format
+func setStickerMetadata(sticker []byte, meta StickerMetadata) ([]byte, error) {
+	chunks, err := parseWebP(sticker)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunks[0].fourCC != "VP8X" {
+		config, err := webp.DecodeConfig(bytes.NewReader(sticker))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WebP size: %w", err)
+		}
+		header := make([]byte, 10)
+		if chunks[0].fourCC == "VP8L" {
+			header[0] = webpFlagAlpha
+		}
+		putUint24(header[4:7], uint32(config.Width-1))
+		putUint24(header[7:10], uint32(config.Height-1))
+		chunks = append([]webpChunk{{fourCC: "VP8X", data: header}}, chunks...)
+	}
+
+	exif, err := stickerEXIF(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	for i, chunk := range chunks {
+		if chunk.fourCC == "EXIF" {
+			continue
+		}
+		if i == 0 {
+			header := bytes.Clone(chunk.data)
+			header[0] |= webpFlagEXIF
+			chunk.data = header
+		}
+		writeWebPChunk(&body, chunk)
+	}
+	writeWebPChunk(&body, webpChunk{fourCC: "EXIF", data: exif})
+
+	out := make([]byte, 8, 8+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(body.Len()))
+	return append(out, body.Bytes()...), nil
+}
+
+// stickerEXIF builds the EXIF block with the pack metadata as JSON in the
+// 0x5741 tag, laid out the way WhatsApp writes it
+func stickerEXIF(meta StickerMetadata) ([]byte, error) {
+	emojis := meta.Emojis
+	if emojis == nil {
+		emojis = []string{}
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"sticker-pack-id":        meta.PackID,
+		"sticker-pack-name":      meta.PackName,
+		"sticker-pack-publisher": meta.Publisher,
+		"emojis":                 emojis,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exif := []byte{
+		0x49, 0x49, 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, // Little-endian TIFF header, IFD at offset 8
+		0x01, 0x00, // One entry
+		0x41, 0x57, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x16, 0x00, 0x00, 0x00, // Tag 0x5741, undefined, length, value at offset 22
+	}
+	binary.LittleEndian.PutUint32(exif[14:18], uint32(len(payload)))
+	return append(exif, payload...), nil
+}
+
+// writeWebPChunk appends a chunk with its header and padding
+func writeWebPChunk(buf *bytes.Buffer, chunk webpChunk) {
+	var header [8]byte
+	copy(header[0:4], chunk.fourCC)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(chunk.data)))
+	buf.Write(header[:])
+	buf.Write(chunk.data)
+	if len(chunk.data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// putUint24 writes v as a 24-bit little-endian integer
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,258 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// AllInstances subscribes to the events of every instance
+const AllInstances = "*"
+
+// What happens when a stream subscriber's channel is full
+const (
+	// Skip the event for that subscriber; per-instance streams read it back
+	// from the journal (the firehose has no journal to catch up from)
+	OverflowJournal = "journal"
+	// Discard the oldest queued event to make room for the new one
+	OverflowDropOldest = "drop-oldest"
+	// Close the subscription; the consumer's connection is ended
+	OverflowDisconnect = "disconnect"
+)
+
+// Buffered events per subscriber
+const subscriberBuffer = 100
+
+// Minimum interval between lag warnings logged for one subscriber
+const lagWarnInterval = 30 * time.Second
+
+// eventSubscriber is a channel receiving the events of an instance (or all)
+type eventSubscriber struct {
+	ch     chan Event
+	name   string // Who is consuming, for logs ("" for internal waiters)
+	stream bool   // Consumed by an API stream, subject to the overflow policy
+
+	dropped  atomic.Uint64
+	lastWarn atomic.Int64 // Unix nanoseconds of the last lag warning
+	closed   bool         // Guarded by eventSubsMu
+}
+
+// EventDropStats counts events that didn't fit in a subscriber's channel
+type EventDropStats struct {
+	Policy       string            `json:"policy"`
+	Dropped      uint64            `json:"eventsDropped"`
+	Disconnected uint64            `json:"slowConsumersDisconnected"`
+	ByInstance   map[string]uint64 `json:"byInstance,omitempty"` // Instance of the dropped events
+}
+
+// eventDrops holds the drop counters of every instance
+type eventDrops struct {
+	mu           sync.Mutex
+	total        uint64
+	disconnected uint64
+	byInstance   map[string]uint64
+}
+
+func newEventDrops() *eventDrops {
+	return &eventDrops{byInstance: make(map[string]uint64)}
+}
+
+// SetEventOverflowPolicy selects what happens when an event stream consumer
+// falls behind: journal (default), drop-oldest or disconnect
+func (m *Manager) SetEventOverflowPolicy(policy string) error {
+	switch policy {
+	case OverflowJournal, OverflowDropOldest, OverflowDisconnect:
+	default:
+		return fmt.Errorf("%w: event overflow policy must be journal, drop-oldest or disconnect", ErrInvalidInput)
+	}
+	m.eventSubsMu.Lock()
+	m.eventOverflow = policy
+	m.eventSubsMu.Unlock()
+	return nil
+}
+
+// EventOverflowPolicy returns what happens when a stream consumer falls behind
+func (m *Manager) EventOverflowPolicy() string {
+	m.eventSubsMu.RLock()
+	defer m.eventSubsMu.RUnlock()
+	return m.eventOverflow
+}
+
+// Subscribe to events for an instance, or for all of them with AllInstances.
+// Meant for internal waiters that only need to know something happened;
+// events that don't fit are skipped.
+func (m *Manager) Subscribe(instanceID string) chan Event {
+	return m.subscribe(instanceID, "", false)
+}
+
+// SubscribeStream subscribes an API stream consumer, described by name in
+// logs. When it falls behind the overflow policy applies, and with the
+// disconnect policy the channel is closed.
+func (m *Manager) SubscribeStream(instanceID, name string) chan Event {
+	return m.subscribe(instanceID, name, true)
+}
+
+func (m *Manager) subscribe(instanceID, name string, stream bool) chan Event {
+	m.eventSubsMu.Lock()
+	defer m.eventSubsMu.Unlock()
+
+	sub := &eventSubscriber{ch: make(chan Event, subscriberBuffer), name: name, stream: stream}
+	m.eventSubs[instanceID] = append(m.eventSubs[instanceID], sub)
+	return sub.ch
+}
+
+// Unsubscribe from events
+func (m *Manager) Unsubscribe(instanceID string, ch chan Event) {
+	m.eventSubsMu.Lock()
+	defer m.eventSubsMu.Unlock()
+
+	subs := m.eventSubs[instanceID]
+	for i, sub := range subs {
+		if sub.ch == ch {
+			m.eventSubs[instanceID] = append(subs[:i], subs[i+1:]...)
+			if !sub.closed {
+				sub.closed = true
+				close(ch)
+			}
+			break
+		}
+	}
+}
+
+// notifySubscribers hands an event to the subscribers of each key, applying
+// the overflow policy to those that are full
+func (m *Manager) notifySubscribers(evt Event, keys ...string) {
+	var slow []*eventSubscriber
+	var slowKeys []string
+
+	// Channels are only closed under the write lock, so sending under the
+	// read lock never hits a closed channel
+	m.eventSubsMu.RLock()
+	policy := m.eventOverflow
+	for _, key := range keys {
+		for _, sub := range m.eventSubs[key] {
+			if m.offerEvent(sub, evt, policy) {
+				continue
+			}
+			if sub.stream && policy == OverflowDisconnect {
+				slow = append(slow, sub)
+				slowKeys = append(slowKeys, key)
+			}
+		}
+	}
+	m.eventSubsMu.RUnlock()
+
+	for i, sub := range slow {
+		m.disconnectSubscriber(slowKeys[i], sub)
+	}
+}
+
+// offerEvent sends an event without blocking and reports whether it was queued
+func (m *Manager) offerEvent(sub *eventSubscriber, evt Event, policy string) bool {
+	select {
+	case sub.ch <- evt:
+		return true
+	default:
+	}
+	if !sub.stream {
+		return true // Internal waiters already have a wake-up pending
+	}
+
+	if policy == OverflowDropOldest {
+		// Make room by discarding the oldest event, unless the consumer
+		// emptied the channel in the meantime
+		select {
+		case <-sub.ch:
+		default:
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+		}
+	}
+
+	m.countDroppedEvent(sub, evt, policy)
+	return policy != OverflowDisconnect
+}
+
+// countDroppedEvent updates the drop counters and warns about the lagging
+// subscriber, at most once per lagWarnInterval
+func (m *Manager) countDroppedEvent(sub *eventSubscriber, evt Event, policy string) {
+	dropped := sub.dropped.Add(1)
+
+	m.eventDrops.mu.Lock()
+	m.eventDrops.total++
+	m.eventDrops.byInstance[evt.InstanceID]++
+	m.eventDrops.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	last := sub.lastWarn.Load()
+	if now-last < int64(lagWarnInterval) || !sub.lastWarn.CompareAndSwap(last, now) {
+		return
+	}
+	log.Warn().
+		Str("subscriber", sub.name).
+		Str("instanceId", evt.InstanceID).
+		Str("policy", policy).
+		Uint64("dropped", dropped).
+		Msg("Event subscriber is lagging, channel full")
+}
+
+// disconnectSubscriber removes a slow stream subscriber and closes its
+// channel, which ends the consumer's connection
+func (m *Manager) disconnectSubscriber(key string, sub *eventSubscriber) {
+	m.eventSubsMu.Lock()
+	subs := m.eventSubs[key]
+	for i, s := range subs {
+		if s == sub {
+			m.eventSubs[key] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if sub.closed {
+		m.eventSubsMu.Unlock()
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+	m.eventSubsMu.Unlock()
+
+	m.eventDrops.mu.Lock()
+	m.eventDrops.disconnected++
+	m.eventDrops.mu.Unlock()
+
+	log.Warn().Str("subscriber", sub.name).Str("instanceId", key).Uint64("dropped", sub.dropped.Load()).Msg("Disconnected slow event subscriber")
+}
+
+// EventDropStats returns the events dropped from full subscriber channels
+// since the service started
+func (m *Manager) EventDropStats() EventDropStats {
+	m.eventSubsMu.RLock()
+	policy := m.eventOverflow
+	m.eventSubsMu.RUnlock()
+
+	m.eventDrops.mu.Lock()
+	defer m.eventDrops.mu.Unlock()
+
+	stats := EventDropStats{
+		Policy:       policy,
+		Dropped:      m.eventDrops.total,
+		Disconnected: m.eventDrops.disconnected,
+		ByInstance:   make(map[string]uint64, len(m.eventDrops.byInstance)),
+	}
+	for instanceID, dropped := range m.eventDrops.byInstance {
+		stats.ByInstance[instanceID] = dropped
+	}
+	return stats
+}
+
+// instanceEventDrops returns the events of an instance dropped from full
+// subscriber channels
+func (m *Manager) instanceEventDrops(instanceID string) uint64 {
+	m.eventDrops.mu.Lock()
+	defer m.eventDrops.mu.Unlock()
+	return m.eventDrops.byInstance[instanceID]
+}
@@ -0,0 +1,124 @@
+package whatsapp
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// templateVar matches {{name}} placeholders, optionally with a fallback: {{name|cliente}}
+var templateVar = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*(?:\|([^}]*))?\}\}`)
+
+// RenderTemplate fills the {{var}} placeholders of a text or caption sent to
+// a recipient. Values come from vars first, then from the address book entry
+// of the recipient; name, firstName, pushName and number fall back to the
+// contact store. Placeholders left without a value take their fallback or
+// are removed.
+func (m *Manager) RenderTemplate(instanceID, to, text string, vars map[string]string) string {
+	return m.renderTemplate(instanceID, to, text, vars, nil)
+}
+
+// RenderMediaURL fills the {{var}} placeholders of a media URL sent to a
+// recipient like RenderTemplate, escaping each value so it stays within its
+// path segment or query parameter (e.g. https://certs.example.com/{{number}}.pdf)
+func (m *Manager) RenderMediaURL(instanceID, to, mediaURL string, vars map[string]string) string {
+	return m.renderTemplate(instanceID, to, mediaURL, vars, escapeURLValue)
+}
+
+// escapeURLValue escapes a value for both URL paths and queries
+func escapeURLValue(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// renderTemplate fills the placeholders of text, passing each value through
+// escape when set
+func (m *Manager) renderTemplate(instanceID, to, text string, vars map[string]string, escape func(string) string) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	if escape == nil {
+		escape = func(value string) string { return value }
+	}
+
+	var contact map[string]string
+	return templateVar.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := templateVar.FindStringSubmatch(placeholder)
+		name, fallback := match[1], strings.TrimSpace(match[2])
+
+		if value, ok := vars[name]; ok && value != "" {
+			return escape(value)
+		}
+		if contact == nil {
+			contact = m.templateContact(instanceID, to)
+		}
+		if value := contact[name]; value != "" {
+			return escape(value)
+		}
+		return escape(fallback)
+	})
+}
+
+// templateContact returns the variables of a recipient: the fields of its
+// address book entry and the built-in ones, from the address book or the
+// contact store of the instance
+func (m *Manager) templateContact(instanceID, to string) map[string]string {
+	vars := make(map[string]string)
+
+	to, err := m.normalizeRecipient(to)
+	if err != nil {
+		return vars
+	}
+	if !strings.Contains(to, "@") {
+		to = to + "@s.whatsapp.net"
+	}
+	jid, err := types.ParseJID(to)
+	if err != nil {
+		return vars
+	}
+	if jid.Server == types.DefaultUserServer {
+		vars["number"] = jid.User
+	} else if phone := m.lids.phone(jid.User); phone != "" {
+		vars["number"] = phone
+	}
+
+	if entry := m.addressBookEntry(instanceID, vars["number"]); entry != nil {
+		for key, value := range entry.Fields {
+			vars[key] = value
+		}
+		if entry.Name != "" {
+			vars["name"] = entry.Name
+		}
+	}
+
+	// The contact store fills what the address book doesn't
+	var contact types.ContactInfo
+	if inst, ok := m.GetInstance(instanceID); ok {
+		inst.mu.RLock()
+		client := inst.Client
+		inst.mu.RUnlock()
+		if client != nil && client.Store != nil && client.Store.Contacts != nil {
+			if info, err := client.Store.Contacts.GetContact(context.Background(), jid); err == nil && info.Found {
+				contact = info
+			}
+		}
+	}
+
+	vars["pushName"] = contact.PushName
+	for _, name := range []string{vars["name"], contact.FullName, contact.PushName, contact.BusinessName} {
+		if name != "" {
+			vars["name"] = name
+			break
+		}
+	}
+	if vars["firstName"] == "" {
+		if contact.FirstName != "" && contact.FullName == vars["name"] {
+			vars["firstName"] = contact.FirstName
+		} else if fields := strings.Fields(vars["name"]); len(fields) > 0 {
+			vars["firstName"] = fields[0]
+		}
+	}
+	return vars
+}
@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	manager, err := NewManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Shutdown(context.Background())
+
+	_, _, _, err = manager.addressBookStore.PutAddressBook("test", []AddressBookEntry{{
+		Phone:  "5511999999999",
+		Name:   "Maria Silva",
+		Fields: map[string]string{"city": "Campinas", "plan": ""},
+	}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		to   string
+		text string
+		vars map[string]string
+		want string
+	}{
+		{"no placeholders", "5511999999999", "Olá!", nil, "Olá!"},
+		{"variable", "5511999999999", "Pedido {{order}} enviado", map[string]string{"order": "42"}, "Pedido 42 enviado"},
+		{"spaces inside the braces", "5511999999999", "Pedido {{ order }}", map[string]string{"order": "42"}, "Pedido 42"},
+		{"variables win over the address book", "5511999999999", "Oi {{name}}", map[string]string{"name": "Dona Maria"}, "Oi Dona Maria"},
+		{"empty variable falls back to the address book", "5511999999999", "Oi {{name}}", map[string]string{"name": ""}, "Oi Maria Silva"},
+		{"address book field", "5511999999999", "Loja de {{city}}", nil, "Loja de Campinas"},
+		{"first name from the address book name", "5511999999999", "Oi {{firstName}}", nil, "Oi Maria"},
+		{"number", "5511999999999@s.whatsapp.net", "Seu número: {{number}}", nil, "Seu número: 5511999999999"},
+		{"missing variable is removed", "5511999999999", "Oi {{nickname}}!", nil, "Oi !"},
+		{"missing variable takes its fallback", "5511999999999", "Oi {{nickname|cliente}}!", nil, "Oi cliente!"},
+		{"fallback is trimmed", "5511999999999", "Oi {{nickname| cliente }}!", nil, "Oi cliente!"},
+		{"empty address book field takes the fallback", "5511999999999", "Plano {{plan|básico}}", nil, "Plano básico"},
+		{"fallback unused when set", "5511999999999", "Oi {{name|cliente}}", nil, "Oi Maria Silva"},
+		{"unknown recipient", "5521888888888", "Oi {{name|cliente}}, {{city}}", nil, "Oi cliente, "},
+		{"unclosed placeholder is kept", "5511999999999", "Oi {{name", nil, "Oi {{name"},
+	}
+
+	for _, tc := range cases {
+		if got := manager.RenderTemplate("test", tc.to, tc.text, tc.vars); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRenderMediaURL(t *testing.T) {
+	manager, err := NewManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Shutdown(context.Background())
+
+	got := manager.RenderMediaURL("test", "5511999999999", "https://certs.example.com/{{name}}.pdf?plan={{plan|a&b}}",
+		map[string]string{"name": "Maria Silva/../admin"})
+	want := "https://certs.example.com/Maria%20Silva%2F..%2Fadmin.pdf?plan=a%26b"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,451 @@
+package whatsapp
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Tenant IDs are used in paths and file names
+var tenantIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Tenant groups instances under API keys that only reach those instances,
+// with quotas on how many instances it may own and messages it may send
+type Tenant struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name,omitempty"`
+	MaxInstances   int         `json:"maxInstances,omitempty"`   // 0 = unlimited
+	MessagesPerDay int         `json:"messagesPerDay,omitempty"` // Sent per server day, 0 = unlimited
+	Instances      []string    `json:"instances"`
+	Keys           []TenantKey `json:"keys"`
+	CreatedAt      int64       `json:"createdAt"`
+}
+
+// TenantKey is an API key of a tenant. Only its hash is stored.
+type TenantKey struct {
+	ID        string `json:"id"` // Start of the hash, to tell keys apart
+	Hash      string `json:"hash,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// TenantUsage is how much of its quotas a tenant uses
+type TenantUsage struct {
+	TenantID       string   `json:"tenantId"`
+	Instances      []string `json:"instances"`
+	InstanceCount  int      `json:"instanceCount"`
+	MaxInstances   int      `json:"maxInstances,omitempty"`
+	MessagesToday  int64    `json:"messagesToday"`
+	MessagesPerDay int      `json:"messagesPerDay,omitempty"`
+	Day            string   `json:"day"` // YYYY-MM-DD, server time
+}
+
+// public returns a copy of the tenant without key hashes
+func (t *Tenant) public() Tenant {
+	out := *t
+	out.Instances = slices.Clone(t.Instances)
+	out.Keys = make([]TenantKey, len(t.Keys))
+	for i, key := range t.Keys {
+		out.Keys[i] = TenantKey{ID: key.ID, CreatedAt: key.CreatedAt}
+	}
+	return out
+}
+
+// loadTenants loads tenants from file and indexes their instances
+func (m *Manager) loadTenants() {
+	data, err := os.ReadFile(m.tenantsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load tenants")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.tenants); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal tenants")
+		return
+	}
+	for _, tenant := range m.tenants {
+		for _, instanceID := range tenant.Instances {
+			m.instanceTenants[instanceID] = tenant.ID
+		}
+	}
+}
+
+// saveTenants persists the tenants. Must be called with tenantsMu held.
+func (m *Manager) saveTenants() {
+	data, err := json.MarshalIndent(m.tenants, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal tenants")
+		return
+	}
+
+	if err := os.WriteFile(m.tenantsFile, data, 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to save tenants")
+	}
+}
+
+// validateQuotas checks the quotas of a tenant
+func (t Tenant) validateQuotas() error {
+	if t.MaxInstances < 0 || t.MessagesPerDay < 0 {
+		return fmt.Errorf("%w: maxInstances and messagesPerDay must be >= 0", ErrInvalidInput)
+	}
+	return nil
+}
+
+// CreateTenant creates a tenant with its first API key. The key is returned
+// just once.
+func (m *Manager) CreateTenant(tenant Tenant) (Tenant, string, error) {
+	if !tenantIDPattern.MatchString(tenant.ID) {
+		return Tenant{}, "", fmt.Errorf("%w: id must be 1-64 letters, digits, - or _", ErrInvalidInput)
+	}
+	if err := tenant.validateQuotas(); err != nil {
+		return Tenant{}, "", err
+	}
+
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	if _, ok := m.tenants[tenant.ID]; ok {
+		return Tenant{}, "", fmt.Errorf("%w: tenant %s already exists", ErrInvalidInput, tenant.ID)
+	}
+	created := &Tenant{
+		ID:             tenant.ID,
+		Name:           tenant.Name,
+		MaxInstances:   tenant.MaxInstances,
+		MessagesPerDay: tenant.MessagesPerDay,
+		Instances:      []string{},
+		CreatedAt:      time.Now().Unix(),
+	}
+	key, err := addTenantKey(created)
+	if err != nil {
+		return Tenant{}, "", err
+	}
+	m.tenants[created.ID] = created
+	m.saveTenants()
+
+	log.Info().Str("tenantId", created.ID).Int("maxInstances", created.MaxInstances).Int("messagesPerDay", created.MessagesPerDay).Msg("Created tenant")
+	return created.public(), key, nil
+}
+
+// addTenantKey generates an API key for a tenant and stores its hash
+func addTenantKey(tenant *Tenant) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	key := "tk_" + hex.EncodeToString(raw)
+	hash := hashToken(key)
+	tenant.Keys = append(tenant.Keys, TenantKey{ID: hash[:12], Hash: hash, CreatedAt: time.Now().Unix()})
+	return key, nil
+}
+
+// Tenants returns every tenant
+func (m *Manager) Tenants() []Tenant {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenants := make([]Tenant, 0, len(m.tenants))
+	for _, tenant := range m.tenants {
+		tenants = append(tenants, tenant.public())
+	}
+	slices.SortFunc(tenants, func(a, b Tenant) int { return strings.Compare(a.ID, b.ID) })
+	return tenants
+}
+
+// GetTenant returns a tenant
+func (m *Manager) GetTenant(tenantID string) (Tenant, error) {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	return tenant.public(), nil
+}
+
+// UpdateTenant replaces the name and quotas of a tenant. Lowering a quota
+// below the current usage keeps what exists and blocks further growth.
+func (m *Manager) UpdateTenant(tenantID string, update Tenant) (Tenant, error) {
+	if err := update.validateQuotas(); err != nil {
+		return Tenant{}, err
+	}
+
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	tenant.Name = update.Name
+	tenant.MaxInstances = update.MaxInstances
+	tenant.MessagesPerDay = update.MessagesPerDay
+	m.saveTenants()
+
+	log.Info().Str("tenantId", tenantID).Int("maxInstances", tenant.MaxInstances).Int("messagesPerDay", tenant.MessagesPerDay).Msg("Updated tenant")
+	return tenant.public(), nil
+}
+
+// DeleteTenant removes a tenant and its keys. Its instances are kept and
+// can then only be reached with the admin or instance token.
+func (m *Manager) DeleteTenant(tenantID string) error {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	for _, instanceID := range tenant.Instances {
+		delete(m.instanceTenants, instanceID)
+	}
+	delete(m.tenants, tenantID)
+	delete(m.tenantSends, tenantID)
+	m.saveTenants()
+
+	log.Info().Str("tenantId", tenantID).Int("instances", len(tenant.Instances)).Msg("Deleted tenant")
+	return nil
+}
+
+// IssueTenantKey adds an API key to a tenant. The key is returned just once.
+func (m *Manager) IssueTenantKey(tenantID string) (TenantKey, string, error) {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return TenantKey{}, "", fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	key, err := addTenantKey(tenant)
+	if err != nil {
+		return TenantKey{}, "", err
+	}
+	m.saveTenants()
+
+	issued := tenant.Keys[len(tenant.Keys)-1]
+	log.Info().Str("tenantId", tenantID).Str("keyId", issued.ID).Msg("Issued tenant API key")
+	return TenantKey{ID: issued.ID, CreatedAt: issued.CreatedAt}, key, nil
+}
+
+// RevokeTenantKey removes an API key of a tenant
+func (m *Manager) RevokeTenantKey(tenantID, keyID string) error {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	i := slices.IndexFunc(tenant.Keys, func(key TenantKey) bool { return key.ID == keyID })
+	if i < 0 {
+		return fmt.Errorf("%w: key %s of tenant %s", ErrTenantNotFound, keyID, tenantID)
+	}
+	tenant.Keys = slices.Delete(tenant.Keys, i, i+1)
+	m.saveTenants()
+
+	log.Info().Str("tenantId", tenantID).Str("keyId", keyID).Msg("Revoked tenant API key")
+	return nil
+}
+
+// TenantForKey returns the ID of the tenant an API key belongs to
+func (m *Manager) TenantForKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	hash := []byte(hashToken(key))
+
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	for _, tenant := range m.tenants {
+		for _, stored := range tenant.Keys {
+			if subtle.ConstantTimeCompare(hash, []byte(stored.Hash)) == 1 {
+				return tenant.ID, true
+			}
+		}
+	}
+	return "", false
+}
+
+// InstanceTenant returns the tenant that owns an instance, or "" for none
+func (m *Manager) InstanceTenant(instanceID string) string {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+	return m.instanceTenants[instanceID]
+}
+
+// ClaimInstance makes a tenant the owner of an instance it is about to
+// create, within its instance quota. Claiming an instance the tenant already
+// owns does nothing.
+func (m *Manager) ClaimInstance(tenantID, instanceID string) error {
+	return m.claimInstanceFor(tenantID, instanceID, false)
+}
+
+// claimInstanceFor claims an instance for a tenant; fresh fails with
+// ErrInstanceExists when the name is taken, even by the tenant itself, so a
+// failed create can release the claim without taking another request's
+func (m *Manager) claimInstanceFor(tenantID, instanceID string, fresh bool) error {
+	// Looked up before taking tenantsMu, which is never held while taking m.mu
+	// or deletedMu
+	_, exists := m.GetInstance(instanceID)
+	deleted := m.isDeleted(instanceID)
+
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[tenantID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTenantNotFound, tenantID)
+	}
+	owner := m.instanceTenants[instanceID]
+	switch {
+	case fresh && (owner != "" || exists):
+		return fmt.Errorf("%w: %s", ErrInstanceExists, instanceID)
+	case owner == tenantID:
+		return nil
+	case owner != "":
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	if exists || deleted {
+		// Instances created without a tenant stay with the admin
+		return fmt.Errorf("%w: %s", ErrInstanceNotFound, instanceID)
+	}
+	if tenant.MaxInstances > 0 && len(tenant.Instances) >= tenant.MaxInstances {
+		return fmt.Errorf("%w: tenant %s may own at most %d instances", ErrQuotaExceeded, tenantID, tenant.MaxInstances)
+	}
+
+	tenant.Instances = append(tenant.Instances, instanceID)
+	m.instanceTenants[instanceID] = tenantID
+	m.saveTenants()
+
+	log.Info().Str("tenantId", tenantID).Str("instanceId", instanceID).Msg("Instance claimed by tenant")
+	return nil
+}
+
+// ReleaseClaim gives back a tenant's claim on an instance that was never
+// created, such as when connecting it failed, so it doesn't count against the
+// tenant's quota
+func (m *Manager) ReleaseClaim(tenantID, instanceID string) {
+	if _, exists := m.GetInstance(instanceID); exists || m.isDeleted(instanceID) {
+		return
+	}
+	if m.InstanceTenant(instanceID) != tenantID {
+		return
+	}
+	m.releaseInstance(instanceID)
+}
+
+// releaseInstance removes an instance from its tenant, once it's purged or
+// when creating it failed
+func (m *Manager) releaseInstance(instanceID string) {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenantID, ok := m.instanceTenants[instanceID]
+	if !ok {
+		return
+	}
+	delete(m.instanceTenants, instanceID)
+	if tenant, ok := m.tenants[tenantID]; ok {
+		tenant.Instances = slices.DeleteFunc(tenant.Instances, func(id string) bool { return id == instanceID })
+	}
+	m.saveTenants()
+}
+
+// tenantSendCount is how many messages a tenant sent on a day, kept in memory
+// so concurrent sends reserve from the quota instead of all passing the check
+type tenantSendCount struct {
+	day  string
+	sent int64
+}
+
+// reserveTenantSend takes one message from the daily quota of the tenant
+// owning an instance, returning ErrQuotaExceeded once it's used up. The
+// returned release gives the message back when the send fails.
+func (m *Manager) reserveTenantSend(instanceID string) (func(), error) {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	tenant, ok := m.tenants[m.instanceTenants[instanceID]]
+	if !ok || tenant.MessagesPerDay == 0 {
+		return func() {}, nil
+	}
+
+	day := time.Now().Format(time.DateOnly)
+	count, ok := m.tenantSends[tenant.ID]
+	if !ok || count.day != day {
+		sent, err := m.usageStore.UsageOn(day, statMessagesSent, tenant.Instances)
+		if err != nil {
+			// Don't block sends on a journal failure
+			log.Warn().Err(err).Str("tenantId", tenant.ID).Msg("Failed to read tenant usage")
+			return func() {}, nil
+		}
+		count = &tenantSendCount{day: day, sent: sent}
+		m.tenantSends[tenant.ID] = count
+	}
+	if count.sent >= int64(tenant.MessagesPerDay) {
+		return nil, fmt.Errorf("%w: tenant %s sent its %d messages for today", ErrQuotaExceeded, tenant.ID, tenant.MessagesPerDay)
+	}
+	count.sent++
+
+	return func() {
+		m.tenantsMu.Lock()
+		defer m.tenantsMu.Unlock()
+		if count.day == day && count.sent > 0 {
+			count.sent--
+		}
+	}, nil
+}
+
+// countTenantSend adds a message sent from the phone to the daily count of the
+// tenant owning an instance
+func (m *Manager) countTenantSend(instanceID string) {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+
+	count, ok := m.tenantSends[m.instanceTenants[instanceID]]
+	if ok && count.day == time.Now().Format(time.DateOnly) {
+		count.sent++
+	}
+}
+
+// HasTenants reports whether any tenant exists
+func (m *Manager) HasTenants() bool {
+	m.tenantsMu.Lock()
+	defer m.tenantsMu.Unlock()
+	return len(m.tenants) > 0
+}
+
+// GetTenantUsage reports the instances and today's messages of a tenant
+// against its quotas
+func (m *Manager) GetTenantUsage(tenantID string) (*TenantUsage, error) {
+	tenant, err := m.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	day := time.Now().Format(time.DateOnly)
+	sent, err := m.usageStore.UsageOn(day, statMessagesSent, tenant.Instances)
+	if err != nil {
+		return nil, err
+	}
+	return &TenantUsage{
+		TenantID:       tenant.ID,
+		Instances:      tenant.Instances,
+		InstanceCount:  len(tenant.Instances),
+		MaxInstances:   tenant.MaxInstances,
+		MessagesToday:  sent,
+		MessagesPerDay: tenant.MessagesPerDay,
+		Day:            day,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	// Register decoders for the formats WhatsApp media arrives in
+	_ "image/gif"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Thumbnail sizes are the length of the longest edge in pixels
+const (
+	DefaultThumbnailSize = 256
+	MaxThumbnailSize     = 1024
+	maxCachedThumbnails  = 500
+)
+
+// GetThumbnail returns a JPEG thumbnail for a stored image or video message,
+// generating and caching it on first request
+func (m *Manager) GetThumbnail(instanceID, mediaID string, size int) ([]byte, error) {
+	if size <= 0 {
+		size = DefaultThumbnailSize
+	}
+	if size > MaxThumbnailSize {
+		size = MaxThumbnailSize
+	}
+
+	cacheKey := fmt.Sprintf("%s/%s/%d", instanceID, mediaID, size)
+	m.thumbnailsMu.Lock()
+	cached, ok := m.thumbnails[cacheKey]
+	m.thumbnailsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	msg, ok := m.findStoredMessage(instanceID, mediaID)
+	if !ok {
+		return nil, ErrMediaNotFound
+	}
+
+	// Prefer the full image when we have it, fall back to the embedded preview
+	var source []byte
+	if (msg.Type == "image" || msg.Type == "sticker") && msg.MediaBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(msg.MediaBase64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode stored media: %w", err)
+		}
+		source = data
+	} else if len(msg.Thumbnail) > 0 {
+		source = msg.Thumbnail
+	} else {
+		return nil, fmt.Errorf("%w: no thumbnail available for %s message", ErrMediaNotFound, msg.Type)
+	}
+
+	thumb, err := resizeToJPEG(source, size)
+	if err != nil {
+		return nil, err
+	}
+
+	m.thumbnailsMu.Lock()
+	if len(m.thumbnails) >= maxCachedThumbnails {
+		// Evict an arbitrary entry to keep memory bounded
+		for k := range m.thumbnails {
+			delete(m.thumbnails, k)
+			break
+		}
+	}
+	m.thumbnails[cacheKey] = thumb
+	m.thumbnailsMu.Unlock()
+
+	return thumb, nil
+}
+
+// resizeToJPEG scales an image so its longest edge is at most size pixels
+func resizeToJPEG(data []byte, size int) ([]byte, error) {
+	src, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(src, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// scaleImage returns src scaled so its longest edge is at most size pixels,
+// drawn over white so transparent areas don't turn black as JPEG
+func scaleImage(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Never upscale
+	if width > size || height > size {
+		if width >= height {
+			height = height * size / width
+			width = size
+		} else {
+			width = width * size / height
+			height = size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// Longest edge of the preview embedded in sent images, videos and documents
+const mediaThumbnailSize = 100
+
+// mediaThumbnail returns the JPEG preview embedded in a sent message, or nil
+// when the media isn't an image or video
+func mediaThumbnail(data []byte, mimeType string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return resizeToJPEG(data, mediaThumbnailSize)
+	case strings.HasPrefix(mimeType, "video/"):
+		return videoThumbnail(data)
+	}
+	return nil, nil
+}
+
+// videoThumbnail extracts the first frame of a video as a small JPEG with
+// ffmpeg. Returns nil without error when ffmpeg isn't installed.
+func videoThumbnail(data []byte) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, nil
+	}
+
+	tmp, err := os.CreateTemp("", "whatsmeow-video-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-v", "error", "-i", tmp.Name(), "-frames:v", "1", "-f", "image2pipe", "-vcodec", "mjpeg", "-")
+	cmd.Stderr = &stderr
+	frame, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return resizeToJPEG(frame, mediaThumbnailSize)
+}
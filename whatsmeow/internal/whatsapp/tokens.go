@@ -0,0 +1,122 @@
+package whatsapp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// loadTokens loads the API token hashes of instances from file
+func (m *Manager) loadTokens() {
+	data, err := os.ReadFile(m.tokensFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error().Err(err).Msg("Failed to load instance tokens")
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &m.tokens); err != nil {
+		log.Error().Err(err).Msg("Failed to unmarshal instance tokens")
+	}
+}
+
+// saveTokens persists the token hashes. Must be called with tokensMu held.
+func (m *Manager) saveTokens() {
+	data, err := json.MarshalIndent(m.tokens, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal instance tokens")
+		return
+	}
+
+	if err := os.WriteFile(m.tokensFile, data, 0600); err != nil {
+		log.Error().Err(err).Msg("Failed to save instance tokens")
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueInstanceToken creates a new API token for an instance, replacing its
+// previous one. Only a hash is stored, so the token is returned just once.
+func (m *Manager) IssueInstanceToken(instanceID string) (string, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return "", ErrInstanceNotFound
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	m.storeInstanceToken(instanceID, token)
+	log.Info().Str("instanceId", instanceID).Msg("Issued instance API token")
+	return token, nil
+}
+
+// Shortest API token accepted from clients
+const minInstanceTokenLength = 16
+
+// SetInstanceToken makes a token chosen by the client the API token of an
+// instance, replacing its previous one
+func (m *Manager) SetInstanceToken(instanceID, token string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+	if len(token) < minInstanceTokenLength {
+		return fmt.Errorf("%w: token must have at least %d characters", ErrInvalidInput, minInstanceTokenLength)
+	}
+
+	m.storeInstanceToken(instanceID, token)
+	log.Info().Str("instanceId", instanceID).Msg("Set instance API token")
+	return nil
+}
+
+func (m *Manager) storeInstanceToken(instanceID, token string) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	m.tokens[instanceID] = hashToken(token)
+	m.saveTokens()
+}
+
+// RevokeInstanceToken removes the API token of an instance
+func (m *Manager) RevokeInstanceToken(instanceID string) {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+
+	if _, ok := m.tokens[instanceID]; !ok {
+		return
+	}
+	delete(m.tokens, instanceID)
+	m.saveTokens()
+	log.Info().Str("instanceId", instanceID).Msg("Revoked instance API token")
+}
+
+// HasInstanceToken reports whether an instance has an API token
+func (m *Manager) HasInstanceToken(instanceID string) bool {
+	m.tokensMu.Lock()
+	defer m.tokensMu.Unlock()
+	_, ok := m.tokens[instanceID]
+	return ok
+}
+
+// CheckInstanceToken reports whether token is the API token of the instance
+func (m *Manager) CheckInstanceToken(instanceID, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	m.tokensMu.Lock()
+	stored, ok := m.tokens[instanceID]
+	m.tokensMu.Unlock()
+	return ok && subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(stored)) == 1
+}
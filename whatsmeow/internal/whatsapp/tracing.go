@@ -0,0 +1,67 @@
+package whatsapp
+
+import (
+	"context"
+	"io"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"whatsmeow-service/internal/tracing"
+)
+
+// Calls into whatsmeow wrapped in client spans, so the latency of a send can
+// be broken down into lookup, upload and send
+
+func tracedIsOnWhatsApp(ctx context.Context, client *whatsmeow.Client, numbers []string) ([]types.IsOnWhatsAppResponse, error) {
+	ctx, span := tracing.StartKind(ctx, "whatsmeow.IsOnWhatsApp", trace.SpanKindClient, attribute.Int("whatsapp.numbers", len(numbers)))
+	defer span.End()
+
+	users, err := client.IsOnWhatsApp(ctx, numbers)
+	tracing.RecordError(span, err)
+	return users, err
+}
+
+func tracedSendMessage(ctx context.Context, client *whatsmeow.Client, to types.JID, msg *waE2E.Message) (whatsmeow.SendResponse, error) {
+	ctx, span := tracing.StartKind(ctx, "whatsmeow.SendMessage", trace.SpanKindClient, attribute.String("whatsapp.chat", to.String()))
+	defer span.End()
+
+	resp, err := client.SendMessage(ctx, to, msg)
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.String("whatsapp.message_id", resp.ID))
+	return resp, err
+}
+
+func tracedUpload(ctx context.Context, client *whatsmeow.Client, data []byte, appMedia whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	ctx, span := tracing.StartKind(ctx, "whatsmeow.Upload", trace.SpanKindClient,
+		attribute.String("whatsapp.media_type", string(appMedia)),
+		attribute.Int("whatsapp.media_bytes", len(data)),
+	)
+	defer span.End()
+
+	uploaded, err := client.Upload(ctx, data, appMedia)
+	tracing.RecordError(span, err)
+	return uploaded, err
+}
+
+func tracedUploadReader(ctx context.Context, client *whatsmeow.Client, r io.Reader, appMedia whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	ctx, span := tracing.StartKind(ctx, "whatsmeow.Upload", trace.SpanKindClient, attribute.String("whatsapp.media_type", string(appMedia)))
+	defer span.End()
+
+	uploaded, err := client.UploadReader(ctx, r, nil, appMedia)
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.Int64("whatsapp.media_bytes", int64(uploaded.FileLength)))
+	return uploaded, err
+}
+
+func tracedDownload(ctx context.Context, client *whatsmeow.Client, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	ctx, span := tracing.StartKind(ctx, "whatsmeow.Download", trace.SpanKindClient)
+	defer span.End()
+
+	data, err := client.Download(ctx, msg)
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.Int("whatsapp.media_bytes", len(data)))
+	return data, err
+}
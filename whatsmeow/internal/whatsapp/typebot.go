@@ -0,0 +1,538 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waE2E "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Idle time after which a chat starts a new Typebot session
+const defaultTypebotSession = 30 * time.Minute
+
+// How often idle Typebot sessions are forgotten
+const typebotSweepInterval = 5 * time.Minute
+
+// Texts of the options message when the config doesn't set them
+const (
+	defaultTypebotChoicePrompt = "Escolha uma opção"
+	defaultTypebotListButton   = "Opções"
+)
+
+// TypebotConfig forwards incoming messages to a Typebot flow, one session per
+// chat, and relays the bubbles it returns. Any flow engine implementing the
+// Typebot v1 chat API (startChat / continueChat) works.
+type TypebotConfig struct {
+	Enabled        bool   `json:"enabled"`
+	BaseURL        string `json:"baseUrl,omitempty"`        // Typebot viewer URL, e.g. https://typebot.io
+	PublicID       string `json:"publicId,omitempty"`       // Public ID of the typebot
+	APIKey         string `json:"apiKey,omitempty"`         // Sent as a bearer token when set
+	SessionMinutes int    `json:"sessionMinutes,omitempty"` // Idle sessions restart the flow (default 30)
+	ResetKeyword   string `json:"resetKeyword,omitempty"`   // Ends the session of the chat, e.g. "#sair"
+	Groups         bool   `json:"groups,omitempty"`         // Also runs in groups
+	ChoicePrompt   string `json:"choicePrompt,omitempty"`   // Text above the options of a choice (default "Escolha uma opção")
+	ListButton     string `json:"listButton,omitempty"`     // Button opening a list of more than 3 options (default "Opções")
+}
+
+// TypebotSession is the flow session of a chat
+type TypebotSession struct {
+	Chat       string `json:"chat"`
+	SessionID  string `json:"sessionId"`
+	StartedAt  int64  `json:"startedAt"`
+	LastActive int64  `json:"lastActive"`
+}
+
+// typebotSession is a chat session; mu keeps the turns of a chat in order
+type typebotSession struct {
+	mu      sync.Mutex
+	info    TypebotSession
+	choices []typebotChoice // Options of the pending choice input
+	turns   int             // Messages waiting for their turn, under typebotSessions.mu
+}
+
+// typebotSessions holds the flow sessions of each instance
+type typebotSessions struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]*typebotSession // instanceID -> chat -> session
+	client   *http.Client
+}
+
+func newTypebotSessions() *typebotSessions {
+	return &typebotSessions{
+		sessions: make(map[string]map[string]*typebotSession),
+		client:   newTypebotClient(),
+	}
+}
+
+// newTypebotClient returns the client of the Typebot API. The bot's replies
+// are sent into chats, so it dials only public addresses, like webhooks.
+func newTypebotClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = publicDialer().DialContext
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}
+
+// typebotReply is the part of a startChat / continueChat response relayed to the chat
+type typebotReply struct {
+	SessionID string           `json:"sessionId"`
+	Messages  []typebotMessage `json:"messages"`
+	Input     *typebotInput    `json:"input"`
+}
+
+type typebotMessage struct {
+	Type    string `json:"type"` // text, image, video, audio, embed
+	Content struct {
+		Markdown string `json:"markdown"`
+		URL      string `json:"url"`
+	} `json:"content"`
+}
+
+type typebotInput struct {
+	Type  string          `json:"type"`
+	Items []typebotChoice `json:"items"`
+}
+
+type typebotChoice struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+}
+
+// validate checks an enabled config points to a typebot
+func (c TypebotConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if err := validatePublicURL("typebot.baseUrl", c.BaseURL); err != nil {
+		return err
+	}
+	if c.PublicID == "" {
+		return fmt.Errorf("%w: typebot.publicId is required", ErrInvalidInput)
+	}
+	if c.SessionMinutes < 0 {
+		return fmt.Errorf("%w: typebot.sessionMinutes must be >= 0", ErrInvalidInput)
+	}
+	return nil
+}
+
+// SetTypebot configures the Typebot integration of an instance. Sessions of
+// the previous flow are dropped.
+func (m *Manager) SetTypebot(instanceID string, config TypebotConfig) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	if err := config.validate(); err != nil {
+		return err
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+
+	inst.mu.Lock()
+	config.APIKey = keepSecret(config.APIKey, inst.Typebot.APIKey)
+	inst.Typebot = config
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+	m.dropTypebotSessions(instanceID)
+	log.Info().Str("instanceId", instanceID).Bool("enabled", config.Enabled).Str("publicId", config.PublicID).Msg("Updated Typebot integration")
+	return nil
+}
+
+// GetTypebotSessions lists the flow sessions of an instance, most recent first
+func (m *Manager) GetTypebotSessions(instanceID string) ([]TypebotSession, error) {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return nil, ErrInstanceNotFound
+	}
+
+	m.typebots.mu.Lock()
+	sessions := make([]*typebotSession, 0, len(m.typebots.sessions[instanceID]))
+	for _, session := range m.typebots.sessions[instanceID] {
+		sessions = append(sessions, session)
+	}
+	m.typebots.mu.Unlock()
+
+	list := make([]TypebotSession, 0, len(sessions))
+	for _, session := range sessions {
+		session.mu.Lock()
+		if session.info.SessionID != "" {
+			list = append(list, session.info)
+		}
+		session.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastActive > list[j].LastActive })
+	return list, nil
+}
+
+// EndTypebotSession ends the flow session of a chat, if any; its next message
+// starts the flow again
+func (m *Manager) EndTypebotSession(instanceID, chatID string) error {
+	if _, ok := m.GetInstance(instanceID); !ok {
+		return ErrInstanceNotFound
+	}
+	to, err := m.normalizeRecipient(chatID)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(to, "@") {
+		to = to + "@s.whatsapp.net"
+	}
+	chatID = m.canonicalChatID(to)
+
+	m.typebots.mu.Lock()
+	delete(m.typebots.sessions[instanceID], chatID)
+	m.typebots.mu.Unlock()
+	log.Info().Str("instanceId", instanceID).Str("chat", chatID).Msg("Ended Typebot session")
+	return nil
+}
+
+// typebotSweepLoop periodically forgets sessions idle for longer than their
+// instance's session time, so chats that never come back don't pile up
+func (m *Manager) typebotSweepLoop() {
+	ticker := time.NewTicker(typebotSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.sweepTypebotSessions(time.Now())
+	}
+}
+
+// sweepTypebotSessions ends the sessions idle since before their instance's
+// session time. Sessions with a message waiting for its turn are kept.
+func (m *Manager) sweepTypebotSessions(now time.Time) {
+	m.typebots.mu.Lock()
+	instanceIDs := make([]string, 0, len(m.typebots.sessions))
+	for instanceID := range m.typebots.sessions {
+		instanceIDs = append(instanceIDs, instanceID)
+	}
+	m.typebots.mu.Unlock()
+
+	for _, instanceID := range instanceIDs {
+		inst, ok := m.GetInstance(instanceID)
+		if !ok {
+			m.dropTypebotSessions(instanceID)
+			continue
+		}
+		inst.mu.RLock()
+		idle := time.Duration(inst.Typebot.SessionMinutes) * time.Minute
+		inst.mu.RUnlock()
+		if idle <= 0 {
+			idle = defaultTypebotSession
+		}
+
+		var expired []TypebotSession
+		m.typebots.mu.Lock()
+		for chatID, session := range m.typebots.sessions[instanceID] {
+			if session.turns > 0 || !session.mu.TryLock() {
+				continue
+			}
+			if now.Sub(time.Unix(session.info.LastActive, 0)) > idle {
+				delete(m.typebots.sessions[instanceID], chatID)
+				if session.info.SessionID != "" {
+					expired = append(expired, session.info)
+				}
+				session.info.SessionID = ""
+				session.choices = nil
+			}
+			session.mu.Unlock()
+		}
+		m.typebots.mu.Unlock()
+
+		for _, info := range expired {
+			m.publishTypebotSession(inst, info, "expired")
+		}
+	}
+}
+
+// dropTypebotSessions forgets every session of an instance
+func (m *Manager) dropTypebotSessions(instanceID string) {
+	m.typebots.mu.Lock()
+	delete(m.typebots.sessions, instanceID)
+	m.typebots.mu.Unlock()
+}
+
+// maybeTypebot forwards a message to the Typebot session of its chat. Reports
+// whether the integration handles the chat.
+func (m *Manager) maybeTypebot(inst *Instance, chat types.JID, msg MessageData) bool {
+	inst.mu.RLock()
+	config := inst.Typebot
+	client := inst.Client
+	inst.mu.RUnlock()
+
+	if !config.Enabled || client == nil || msg.FromMe || msg.IsGroup && !config.Groups {
+		return false
+	}
+	// Stickers, audio and other messages without text have nothing to answer
+	// the flow with, so they're left to the away message
+	if msg.Interactive == nil && msg.Body == "" && msg.Caption == "" {
+		return false
+	}
+	chatID := m.canonicalChatID(chat.String())
+
+	m.typebots.mu.Lock()
+	if m.typebots.sessions[inst.ID] == nil {
+		m.typebots.sessions[inst.ID] = make(map[string]*typebotSession)
+	}
+	session := m.typebots.sessions[inst.ID][chatID]
+	if session == nil {
+		session = &typebotSession{info: TypebotSession{Chat: chatID}}
+		m.typebots.sessions[inst.ID][chatID] = session
+	}
+	session.turns++
+	m.typebots.mu.Unlock()
+
+	go func() {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		defer func() {
+			m.typebots.mu.Lock()
+			session.turns--
+			m.typebots.mu.Unlock()
+		}()
+
+		answer := typebotAnswer(msg, session.choices)
+		if answer == "" {
+			return
+		}
+		if config.ResetKeyword != "" && strings.EqualFold(strings.TrimSpace(answer), config.ResetKeyword) {
+			m.endTypebotSession(inst, session, "reset")
+			return
+		}
+
+		idle := time.Duration(config.SessionMinutes) * time.Minute
+		if idle <= 0 {
+			idle = defaultTypebotSession
+		}
+		now := time.Now()
+		if session.info.SessionID != "" && now.Sub(time.Unix(session.info.LastActive, 0)) > idle {
+			session.info.SessionID = ""
+		}
+
+		var reply *typebotReply
+		var err error
+		if session.info.SessionID != "" {
+			reply, err = m.continueTypebot(config, session.info.SessionID, answer)
+			if err == errTypebotSessionGone {
+				session.info.SessionID = ""
+			}
+		}
+		if session.info.SessionID == "" {
+			reply, err = m.startTypebot(config, chatID, msg, answer)
+			if err == nil {
+				session.info.SessionID = reply.SessionID
+				session.info.StartedAt = now.Unix()
+				m.publishTypebotSession(inst, session.info, "started")
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", chatID).Msg("Typebot request failed")
+			return
+		}
+		session.info.LastActive = now.Unix()
+
+		m.relayTypebot(inst, config, chat.ToNonAD(), reply)
+
+		session.choices = nil
+		if reply.Input == nil {
+			m.endTypebotSession(inst, session, "completed")
+		} else if reply.Input.Type == "choice input" {
+			session.choices = reply.Input.Items
+		}
+	}()
+	return true
+}
+
+// typebotAnswer returns what the contact answered: the chosen option of a
+// button, list or numbered reply, or the text of the message
+func typebotAnswer(msg MessageData, choices []typebotChoice) string {
+	if msg.Interactive != nil {
+		for _, choice := range choices {
+			if choice.ID == msg.Interactive.SelectedID {
+				return choice.Content
+			}
+		}
+		return msg.Interactive.DisplayText
+	}
+	text := msg.Body
+	if text == "" {
+		text = msg.Caption
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(text)); err == nil && n >= 1 && n <= len(choices) {
+		return choices[n-1].Content
+	}
+	return text
+}
+
+// endTypebotSession forgets the session of a chat (caller holds session.mu)
+func (m *Manager) endTypebotSession(inst *Instance, session *typebotSession, reason string) {
+	m.typebots.mu.Lock()
+	if m.typebots.sessions[inst.ID][session.info.Chat] == session {
+		delete(m.typebots.sessions[inst.ID], session.info.Chat)
+	}
+	m.typebots.mu.Unlock()
+
+	if session.info.SessionID != "" {
+		m.publishTypebotSession(inst, session.info, reason)
+	}
+	session.info.SessionID = ""
+	session.choices = nil
+}
+
+func (m *Manager) publishTypebotSession(inst *Instance, info TypebotSession, status string) {
+	m.publishEvent(Event{
+		Type:       "typebot_session",
+		InstanceID: inst.ID,
+		Data: map[string]interface{}{
+			"chat":      info.Chat,
+			"sessionId": info.SessionID,
+			"status":    status,
+		},
+	})
+}
+
+// errTypebotSessionGone means the flow engine no longer knows the session
+var errTypebotSessionGone = errors.New("typebot session expired")
+
+// startTypebot starts a flow session with the first message of the chat
+func (m *Manager) startTypebot(config TypebotConfig, chatID string, msg MessageData, text string) (*typebotReply, error) {
+	number := msg.ResolvedPhone
+	if number == "" {
+		number = jidUser(msg.From)
+	}
+	return m.callTypebot(config, "/api/v1/typebots/"+url.PathEscape(config.PublicID)+"/startChat", map[string]interface{}{
+		"message":                 map[string]string{"type": "text", "text": text},
+		"textBubbleContentFormat": "markdown",
+		"prefilledVariables": map[string]string{
+			"remoteJid": chatID,
+			"number":    number,
+			"pushName":  msg.PushName,
+		},
+	})
+}
+
+// continueTypebot sends the next answer of the chat to its session
+func (m *Manager) continueTypebot(config TypebotConfig, sessionID, text string) (*typebotReply, error) {
+	return m.callTypebot(config, "/api/v1/sessions/"+url.PathEscape(sessionID)+"/continueChat", map[string]interface{}{
+		"message":                 map[string]string{"type": "text", "text": text},
+		"textBubbleContentFormat": "markdown",
+	})
+}
+
+func (m *Manager) callTypebot(config TypebotConfig, path string, payload interface{}) (*typebotReply, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, config.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+
+	resp, err := m.typebots.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(path, "/sessions/") {
+		return nil, errTypebotSessionGone
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("typebot returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var reply typebotReply
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid typebot response: %w", err)
+	}
+	return &reply, nil
+}
+
+// relayTypebot sends the bubbles of a flow turn to the chat in order, ending
+// with the options of a choice input: buttons for up to 3, a list otherwise
+func (m *Manager) relayTypebot(inst *Instance, config TypebotConfig, jid types.JID, reply *typebotReply) {
+	for _, bubble := range reply.Messages {
+		var err error
+		switch bubble.Type {
+		case "text":
+			text := strings.ReplaceAll(bubble.Content.Markdown, "**", "*")
+			if text == "" {
+				continue
+			}
+			_, err = m.sendPlainText(inst, jid, text)
+		case "image", "video", "audio":
+			if bubble.Content.URL == "" {
+				continue
+			}
+			_, err = m.sendMediaURL(context.Background(), inst, jid, bubble.Content.URL, "", bubble.Type, MediaOptions{})
+		case "embed":
+			if bubble.Content.URL != "" {
+				_, err = m.sendPlainText(inst, jid, bubble.Content.URL)
+			}
+		default:
+			log.Debug().Str("instanceId", inst.ID).Str("type", bubble.Type).Msg("Skipping unsupported Typebot bubble")
+		}
+		if err != nil {
+			log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", jid.String()).Str("type", bubble.Type).Msg("Failed to relay Typebot bubble")
+		}
+	}
+
+	if reply.Input == nil || reply.Input.Type != "choice input" || len(reply.Input.Items) == 0 {
+		return
+	}
+	prompt := config.ChoicePrompt
+	if prompt == "" {
+		prompt = defaultTypebotChoicePrompt
+	}
+	listButton := config.ListButton
+	if listButton == "" {
+		listButton = defaultTypebotListButton
+	}
+
+	var err error
+	items := reply.Input.Items
+	if len(items) <= maxButtons {
+		buttons := make([]Button, len(items))
+		for i, item := range items {
+			buttons[i] = Button{ID: item.ID, Text: item.Content}
+		}
+		_, err = m.SendButtonsMessage(inst.ID, jid.String(), prompt, "", "", buttons)
+	} else {
+		rows := make([]ListRow, len(items))
+		for i, item := range items {
+			rows[i] = ListRow{ID: item.ID, Title: item.Content}
+		}
+		_, err = m.SendListMessage(inst.ID, jid.String(), "", prompt, listButton, "", []ListSection{{Rows: rows}})
+	}
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", inst.ID).Str("chat", jid.String()).Msg("Failed to relay Typebot choices")
+	}
+}
+
+// sendPlainText sends a text message to a JID as is
+func (m *Manager) sendPlainText(inst *Instance, jid types.JID, text string) (string, error) {
+	resp, err := m.sendTracked(context.Background(), inst.ID, inst.Client, jid, &waE2E.Message{
+		Conversation: proto.String(text),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSendFailed, err)
+	}
+	return resp.ID, nil
+}
@@ -0,0 +1,90 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const usageSchema = `
+	CREATE TABLE IF NOT EXISTS usage_stats (
+		instance_id TEXT    NOT NULL,
+		day         TEXT    NOT NULL,
+		counter     TEXT    NOT NULL,
+		value       INTEGER NOT NULL,
+		PRIMARY KEY (instance_id, day, counter)
+	);
+`
+
+// usageStore keeps the daily usage counters of instances in events.db
+type usageStore struct {
+	db *sql.DB
+}
+
+// usageRow is a stored daily counter
+type usageRow struct {
+	day     string
+	counter string
+	value   int64
+}
+
+// AddUsage adds to a daily usage counter of an instance
+func (s *usageStore) AddUsage(instanceID, day, counter string, value int64) error {
+	_, err := s.db.Exec(
+		`INSERT INTO usage_stats (instance_id, day, counter, value) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (instance_id, day, counter) DO UPDATE SET value = value + excluded.value`,
+		instanceID, day, counter, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store usage: %w", err)
+	}
+	return nil
+}
+
+// Usage returns every daily usage counter of an instance
+func (s *usageStore) Usage(instanceID string) ([]usageRow, error) {
+	rows, err := s.db.Query(`SELECT day, counter, value FROM usage_stats WHERE instance_id = ?`, instanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []usageRow
+	for rows.Next() {
+		var row usageRow
+		if err := rows.Scan(&row.day, &row.counter, &row.value); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		usage = append(usage, row)
+	}
+	return usage, rows.Err()
+}
+
+// UsageOn sums a usage counter of some instances on a day
+func (s *usageStore) UsageOn(day, counter string, instanceIDs []string) (int64, error) {
+	if len(instanceIDs) == 0 {
+		return 0, nil
+	}
+	args := []interface{}{day, counter}
+	for _, id := range instanceIDs {
+		args = append(args, id)
+	}
+
+	var total int64
+	err := s.db.QueryRow(
+		`SELECT COALESCE(SUM(value), 0) FROM usage_stats WHERE day = ? AND counter = ? AND instance_id IN (?`+strings.Repeat(", ?", len(instanceIDs)-1)+`)`,
+		args...,
+	).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query usage: %w", err)
+	}
+	return total, nil
+}
+
+// deleteInstance removes the usage counters of an instance
+func (s *usageStore) deleteInstance(instanceID string) error {
+	if _, err := s.db.Exec(`DELETE FROM usage_stats WHERE instance_id = ?`, instanceID); err != nil {
+		return fmt.Errorf("failed to delete instance usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Most JIDs looked up by one GetUserInfo call
+const maxUserInfoJIDs = 100
+
+// UserInfo is the public profile of a WhatsApp user
+type UserInfo struct {
+	JID          string   `json:"jid"`
+	LID          string   `json:"lid,omitempty"`
+	Status       string   `json:"status,omitempty"`       // "About" text
+	VerifiedName string   `json:"verifiedName,omitempty"` // Business accounts only
+	PictureID    string   `json:"pictureId,omitempty"`
+	Devices      []string `json:"devices,omitempty"`
+	Error        string   `json:"error,omitempty"` // Set for JIDs that couldn't be parsed or weren't found
+}
+
+// GetUserInfo fetches the status text, verified business name, devices and
+// profile picture ID of several users (numbers or JIDs) in one query.
+// Results follow the order of jids.
+func (m *Manager) GetUserInfo(instanceID string, jids []string) ([]UserInfo, error) {
+	if len(jids) == 0 {
+		return nil, fmt.Errorf("%w: at least 1 jid is required", ErrInvalidInput)
+	}
+	if len(jids) > maxUserInfoJIDs {
+		return nil, fmt.Errorf("%w: at most %d jids per request", ErrInvalidInput, maxUserInfoJIDs)
+	}
+
+	client, err := m.connectedClient(instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]UserInfo, len(jids))
+	parsed := make([]types.JID, len(jids))
+	query := make([]types.JID, 0, len(jids))
+	seen := make(map[types.JID]bool, len(jids))
+	for i, jidStr := range jids {
+		results[i] = UserInfo{JID: jidStr}
+
+		recipient, err := m.normalizeRecipient(jidStr)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if !strings.Contains(recipient, "@") {
+			recipient += "@" + types.DefaultUserServer
+		}
+		jid, err := types.ParseJID(recipient)
+		if err != nil {
+			results[i].Error = fmt.Errorf("%w: %w", ErrInvalidJID, err).Error()
+			continue
+		}
+
+		jid = jid.ToNonAD()
+		parsed[i] = jid
+		results[i].JID = jid.String()
+		if !seen[jid] {
+			seen[jid] = true
+			query = append(query, jid)
+		}
+	}
+	if len(query) == 0 {
+		return results, nil
+	}
+
+	users, err := client.GetUserInfo(context.Background(), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		info, ok := users[parsed[i]]
+		if !ok {
+			results[i].Error = ErrNotOnWhatsApp.Error()
+			continue
+		}
+
+		results[i].Status = info.Status
+		results[i].PictureID = info.PictureID
+		if info.VerifiedName != nil && info.VerifiedName.Details != nil {
+			results[i].VerifiedName = info.VerifiedName.Details.GetVerifiedName()
+		}
+		if !info.LID.IsEmpty() {
+			results[i].LID = info.LID.String()
+			m.lids.put(info.LID, parsed[i])
+		}
+		for _, device := range info.Devices {
+			results[i].Devices = append(results[i].Devices, device.String())
+		}
+	}
+	return results, nil
+}
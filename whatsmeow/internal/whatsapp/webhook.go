@@ -0,0 +1,406 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Webhook delivery limits
+const (
+	webhookBatchSize = 100
+	webhookTimeout   = 10 * time.Second
+)
+
+// Delay before each retry of a failed delivery
+var webhookRetryDelays = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+
+// Headers sent with every webhook delivery
+const (
+	WebhookSignatureHeader = "X-Signature"
+	WebhookTimestampHeader = "X-Timestamp"
+)
+
+// webhooks delivers events to the webhook URL of each instance, in order.
+// Workers read from the event journal, so nothing is lost when they fall behind.
+type webhooks struct {
+	mu      sync.Mutex
+	wake    map[string]chan struct{}  // instanceID -> signals new events to the worker
+	cursors map[string]int64          // instanceID -> last delivered event ID
+	health  map[string]*WebhookHealth // instanceID -> delivery counters since startup
+	client  *http.Client
+	retries []time.Duration // Delay before each retry of a failed delivery
+}
+
+// WebhookHealth counts the webhook deliveries of an instance since startup
+type WebhookHealth struct {
+	Delivered   int64   `json:"delivered"`
+	Failed      int64   `json:"failed"`  // Events given up on after every retry
+	Retries     int64   `json:"retries"` // Failed attempts that were retried
+	FailureRate float64 `json:"failureRate"`
+	LastError   string  `json:"lastError,omitempty"`
+	LastErrorAt int64   `json:"lastErrorAt,omitempty"`
+	LastSuccess int64   `json:"lastSuccessAt,omitempty"`
+}
+
+func newWebhooks() *webhooks {
+	return &webhooks{
+		wake:    make(map[string]chan struct{}),
+		cursors: make(map[string]int64),
+		health:  make(map[string]*WebhookHealth),
+		client:  newWebhookClient(),
+		retries: webhookRetryDelays,
+	}
+}
+
+// newWebhookClient returns the client webhooks are delivered with. It connects
+// directly through publicDialer, so webhooks (and their redirects) never reach
+// loopback, private or metadata addresses, even by a hostname resolving to one.
+func newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = publicDialer().DialContext
+	return &http.Client{Transport: transport, Timeout: webhookTimeout}
+}
+
+// SetWebhookDelivery sets the timeout of each webhook delivery and the delay
+// before each retry (nil keeps the current delays). Must be called before
+// events are delivered.
+func (m *Manager) SetWebhookDelivery(timeout time.Duration, retryDelays []time.Duration) {
+	if timeout > 0 {
+		m.webhooks.client.Timeout = timeout
+	}
+	if retryDelays != nil {
+		m.webhooks.retries = retryDelays
+	}
+}
+
+// SignWebhook computes the X-Signature of a delivery: HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the instance secret, hex encoded
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newWebhookSecret generates a random signing secret
+func newWebhookSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
+
+// SetWebhook configures where the events of an instance are delivered ("" disables
+// webhooks). Without a secret a random one is generated.
+func (m *Manager) SetWebhook(instanceID, webhookURL, secret string) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+
+	webhookURL = strings.TrimSpace(webhookURL)
+	if webhookURL != "" {
+		if err := validateWebhookURL(webhookURL); err != nil {
+			return err
+		}
+	}
+
+	inst.mu.Lock()
+	inst.WebhookURL = webhookURL
+	secret = keepSecret(secret, inst.WebhookSecret)
+	if secret != "" {
+		inst.WebhookSecret = secret
+	} else if inst.WebhookSecret == "" && webhookURL != "" {
+		inst.WebhookSecret = newWebhookSecret()
+	}
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+
+	log.Info().Str("instanceId", instanceID).Bool("enabled", webhookURL != "").Msg("Updated webhook setting")
+	return nil
+}
+
+// WebhookSecret returns the signing secret of an instance's webhook
+func (m *Manager) WebhookSecret(instanceID string) string {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ""
+	}
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return inst.WebhookSecret
+}
+
+// SetWebhookEvents limits the event types delivered to the webhook ("call*"
+// matches every type starting with "call"); no types delivers every event
+func (m *Manager) SetWebhookEvents(instanceID string, eventTypes []string) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+
+	inst.mu.Lock()
+	inst.WebhookEvents = eventTypes
+	inst.mu.Unlock()
+	m.saveSettings(inst)
+
+	log.Info().Str("instanceId", instanceID).Strs("events", eventTypes).Msg("Updated webhook events")
+	return nil
+}
+
+// validateWebhookURL rejects webhook URLs that aren't http(s) or name a
+// loopback, private or metadata address. Hostnames are checked again when
+// dialed, after they're resolved.
+func validateWebhookURL(raw string) error {
+	return validatePublicURL("webhookUrl", raw)
+}
+
+// enqueueWebhook wakes the delivery worker of the event's instance, starting
+// it at this event if it isn't running yet
+func (m *Manager) enqueueWebhook(evt Event) {
+	if evt.ID == 0 {
+		return // Not journaled, so the worker can't read it
+	}
+	inst, ok := m.GetInstance(evt.InstanceID)
+	if !ok {
+		return
+	}
+	inst.mu.RLock()
+	enabled := evt.webhookURL != "" || inst.WebhookURL != "" && NewEventFilter(inst.WebhookEvents).Allows(evt.Type)
+	inst.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+	if _, ok := m.webhooks.cursors[evt.InstanceID]; !ok {
+		m.webhooks.cursors[evt.InstanceID] = evt.ID - 1
+	}
+	m.wakeWebhookWorker(evt.InstanceID)
+}
+
+// wakeWebhookWorker signals (and if needed starts) the worker of an instance (caller must hold webhooks.mu)
+func (m *Manager) wakeWebhookWorker(instanceID string) {
+	wake, ok := m.webhooks.wake[instanceID]
+	if !ok {
+		wake = make(chan struct{}, 1)
+		m.webhooks.wake[instanceID] = wake
+		go m.runWebhookWorker(instanceID, wake)
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+		// Already signaled
+	}
+}
+
+// ReplayWebhook redelivers the journaled events published after lastEventID
+func (m *Manager) ReplayWebhook(instanceID string, lastEventID int64) error {
+	inst, ok := m.GetInstance(instanceID)
+	if !ok {
+		return ErrInstanceNotFound
+	}
+	inst.mu.RLock()
+	enabled := inst.WebhookURL != ""
+	inst.mu.RUnlock()
+	if !enabled {
+		return fmt.Errorf("%w: instance has no webhookUrl", ErrInvalidInput)
+	}
+
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+	m.webhooks.cursors[instanceID] = lastEventID
+	m.wakeWebhookWorker(instanceID)
+
+	log.Info().Str("instanceId", instanceID).Int64("lastEventId", lastEventID).Msg("Replaying webhook events")
+	return nil
+}
+
+// runWebhookWorker delivers journaled events after the instance cursor each
+// time it's woken, until the wake channel is closed
+func (m *Manager) runWebhookWorker(instanceID string, wake chan struct{}) {
+	for range wake {
+		for {
+			m.webhooks.mu.Lock()
+			cursor := m.webhooks.cursors[instanceID]
+			m.webhooks.mu.Unlock()
+
+			events, err := m.journal.After(context.Background(), instanceID, cursor, webhookBatchSize)
+			if err != nil {
+				log.Error().Err(err).Str("instanceId", instanceID).Msg("Failed to read events for webhook")
+				break
+			}
+			if len(events) == 0 {
+				break
+			}
+
+			for _, evt := range events {
+				m.deliverWithRetry(instanceID, evt)
+
+				m.webhooks.mu.Lock()
+				// A replay may have moved the cursor meanwhile; don't undo it
+				if m.webhooks.cursors[instanceID] == cursor {
+					m.webhooks.cursors[instanceID] = evt.ID
+				}
+				cursor = m.webhooks.cursors[instanceID]
+				m.webhooks.mu.Unlock()
+				if cursor != evt.ID {
+					break
+				}
+			}
+		}
+	}
+}
+
+// deliverWithRetry posts an event, retrying failures with backoff before giving
+// up. Events routed by a filter rule go to the rule's webhook, unfiltered and
+// signed with the rule's secret.
+func (m *Manager) deliverWithRetry(instanceID string, evt Event) {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		log.Error().Err(err).Str("instanceId", instanceID).Str("type", evt.Type).Msg("Failed to encode webhook event")
+		return
+	}
+
+	// Journaled with the event, so the route survives restarts and replays
+	route := evt.webhookURL
+
+	for attempt := 0; ; attempt++ {
+		// Settings are read per attempt so a changed URL, secret or format applies right away
+		inst, ok := m.GetInstance(instanceID)
+		if !ok {
+			return
+		}
+		inst.mu.RLock()
+		webhookURL, secret, format := inst.WebhookURL, inst.WebhookSecret, inst.WebhookFormat
+		filter := NewEventFilter(inst.WebhookEvents)
+		if route != "" {
+			webhookURL, secret = route, filterWebhookSecret(inst.FilterRules, route)
+		}
+		inst.mu.RUnlock()
+		if route == "" && (webhookURL == "" || !filter.Allows(evt.Type)) {
+			// Events the webhook isn't subscribed to are skipped
+			return
+		}
+		payload := body
+		if format == WebhookFormatEvolution {
+			// Evolution API payloads name their destination, so they're encoded per attempt
+			var ok bool
+			if payload, ok = m.evolutionWebhookBody(evt, webhookURL); !ok {
+				return
+			}
+		}
+
+		err = m.deliverWebhook(webhookURL, secret, evt, payload)
+		m.recordWebhookAttempt(instanceID, err, attempt >= len(m.webhooks.retries))
+		if err == nil {
+			return
+		}
+		if attempt >= len(m.webhooks.retries) {
+			log.Error().Err(err).Str("instanceId", instanceID).Str("type", evt.Type).Int64("eventId", evt.ID).Msg("Webhook delivery failed, giving up")
+			return
+		}
+		log.Warn().Err(err).Str("instanceId", instanceID).Str("type", evt.Type).Int("attempt", attempt+1).Msg("Webhook delivery failed, retrying")
+		time.Sleep(m.webhooks.retries[attempt])
+	}
+}
+
+// recordWebhookAttempt counts a delivery attempt of an instance
+func (m *Manager) recordWebhookAttempt(instanceID string, err error, final bool) {
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+
+	health, ok := m.webhooks.health[instanceID]
+	if !ok {
+		health = &WebhookHealth{}
+		m.webhooks.health[instanceID] = health
+	}
+	switch {
+	case err == nil:
+		health.Delivered++
+		health.LastSuccess = time.Now().Unix()
+	case final:
+		health.Failed++
+	default:
+		health.Retries++
+	}
+	if err != nil {
+		health.LastError = err.Error()
+		health.LastErrorAt = time.Now().Unix()
+	}
+}
+
+// webhookHealth returns the delivery counters of an instance (nil before the
+// first attempt) and the last event its worker delivered, if one is running
+func (m *Manager) webhookHealth(instanceID string) (*WebhookHealth, int64, bool) {
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+
+	cursor, running := m.webhooks.cursors[instanceID]
+	health, ok := m.webhooks.health[instanceID]
+	if !ok {
+		return nil, cursor, running
+	}
+	result := *health
+	if attempts := result.Delivered + result.Failed + result.Retries; attempts > 0 {
+		result.FailureRate = float64(result.Failed+result.Retries) / float64(attempts)
+	}
+	return &result, cursor, running
+}
+
+// deliverWebhook posts one signed event. The signature covers the timestamp so
+// receivers can reject replayed deliveries.
+func (m *Manager) deliverWebhook(webhookURL, secret string, evt Event, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", evt.Type)
+	req.Header.Set("X-Event-Id", strconv.FormatInt(evt.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+	}
+
+	resp, err := m.webhooks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dropWebhooks stops the delivery worker of an instance
+func (m *Manager) dropWebhooks(instanceID string) {
+	m.webhooks.mu.Lock()
+	defer m.webhooks.mu.Unlock()
+
+	if wake, ok := m.webhooks.wake[instanceID]; ok {
+		close(wake)
+		delete(m.webhooks.wake, instanceID)
+	}
+	delete(m.webhooks.cursors, instanceID)
+	delete(m.webhooks.health, instanceID)
+}
@@ -2,124 +2,256 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	waProto "go.mau.fi/whatsmeow/proto/waCompanionReg"
 	"go.mau.fi/whatsmeow/store"
 	"google.golang.org/protobuf/proto"
 
 	"whatsmeow-service/internal/api"
+	"whatsmeow-service/internal/cluster"
+	"whatsmeow-service/internal/config"
+	"whatsmeow-service/internal/logging"
+	"whatsmeow-service/internal/tracing"
 	"whatsmeow-service/internal/whatsapp"
 )
 
+//go:generate go run . -openapi openapi.json
+
 func main() {
-	// Setup logger
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	openAPIOut := flag.String("openapi", "", "write the OpenAPI document to this file and exit")
+	configPath := flag.String("config", os.Getenv("WHATSMEOW_CONFIG"), "YAML config file (environment variables override it)")
+	flag.Parse()
 
-	// Get port from env or default
-	port := os.Getenv("WHATSMEOW_PORT")
-	if port == "" {
-		port = "8081"
+	// Generate the OpenAPI document from the route table and Go types
+	if *openAPIOut != "" {
+		if err := writeOpenAPISpec(*openAPIOut); err != nil {
+			log.Fatal().Err(err).Msg("Failed to write OpenAPI document")
+		}
+		return
 	}
 
-	// Get data directory from env or default
-	dataDir := os.Getenv("WHATSMEOW_DATA_DIR")
-	if dataDir == "" {
-		dataDir = "./data"
+	// Load and validate the configuration before touching any data
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	// Setup logger
+	err = logging.Setup(os.Stderr, logging.Config{
+		Level:        cfg.Log.Level,
+		Format:       cfg.Log.Format,
+		RedactPhones: cfg.Log.RedactPhones,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+	if *configPath != "" {
+		log.Info().Str("path", *configPath).Msg("Loaded config file")
 	}
 
 	// Create data directory if not exists
+	dataDir := cfg.Storage.DataDir
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
 		log.Fatal().Err(err).Msg("Failed to create data directory")
 	}
 
 	// Configure device identity as Chrome browser on macOS
-	// This makes WhatsApp show "Chrome" instead of "Outros" in connected devices
+	// This makes WhatsApp show "Chrome" instead of "Outros" in connected devices.
+	// Instances can override it with their own device identity when pairing.
 	store.DeviceProps.Os = proto.String("Mac OS")
 	store.DeviceProps.PlatformType = waProto.DeviceProps_CHROME.Enum()
 	store.DeviceProps.RequireFullSync = proto.Bool(false)
 
 	log.Info().Msg("Configured device identity as Chrome on Mac OS")
 
+	// Key encrypting the session database at rest, given directly or as a
+	// file mounted by a secret manager/KMS
+	var sessionKey []byte
+	keyValue, keySource := cfg.Storage.DBKey, "storage.dbKey"
+	if keyFile := cfg.Storage.DBKeyFile; keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to read storage.dbKeyFile")
+		}
+		keyValue, keySource = string(data), "storage.dbKeyFile"
+	}
+	if keyValue != "" {
+		key, err := whatsapp.ParseSessionKey(keyValue)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid " + keySource)
+		}
+		sessionKey = key
+		log.Info().Msg("Session database is encrypted at rest")
+	}
+
 	// Initialize WhatsApp manager
-	manager, err := whatsapp.NewManager(dataDir)
+	manager, err := whatsapp.NewManager(dataDir, sessionKey)
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to initialize WhatsApp manager")
 	}
 
+	// Instance lifecycle: purge grace period and QR code scan timeout
+	manager.SetDeleteGracePeriod(time.Duration(cfg.Instances.DeleteGrace))
+	manager.SetQRIdleTimeout(time.Duration(cfg.Instances.QRTimeout))
+
+	// Media downloads running at once across all instances, and the media
+	// policy of new instances when /admin/defaults doesn't set one
+	manager.SetMediaWorkers(cfg.Media.Workers)
+	err = manager.SetFallbackMediaPolicy(whatsapp.MediaPolicy{
+		Mode:        cfg.Media.Policy.Mode,
+		MaxBytes:    cfg.Media.Policy.MaxBytes,
+		Types:       cfg.Media.Policy.Types,
+		Limits:      cfg.Media.Policy.Limits,
+		Concurrency: cfg.Media.Policy.Concurrency,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid media.policy")
+	}
+
+	// Downscaling and recompression of sent images when the request doesn't say
+	err = manager.SetImageCompression(whatsapp.ImageCompression{
+		MaxDimension: cfg.Media.Compression.MaxDimension,
+		Quality:      cfg.Media.Compression.Quality,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid media.compression")
+	}
+
+	// Level of the whatsmeow client logs of each instance (settings can override it per instance)
+	if err := manager.SetClientLogLevel(cfg.Log.ClientLevel); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log.clientLevel")
+	}
+
+	// Country assumed for phone numbers sent without a country code
+	if region := cfg.Instances.DefaultRegion; region != "" {
+		if err := manager.SetDefaultRegion(region); err != nil {
+			log.Fatal().Err(err).Msg("Invalid instances.defaultRegion")
+		}
+	}
+
+	// Largest group a message may mention every participant of
+	manager.SetMentionAllLimit(cfg.Instances.MentionAllLimit)
+
+	// Addresses and domains link previews may be fetched from
+	manager.SetPreviewPolicy(whatsapp.PreviewPolicy{
+		AllowPrivate: cfg.Previews.AllowPrivate,
+		DenyDomains:  cfg.Previews.DenyDomains,
+		MaxRedirects: cfg.Previews.MaxRedirects,
+	})
+
+	// What happens to WebSocket and SSE consumers that fall behind
+	if err := manager.SetEventOverflowPolicy(cfg.Events.OverflowPolicy); err != nil {
+		log.Fatal().Err(err).Msg("Invalid events.overflowPolicy")
+	}
+
+	// Webhook delivery timeout and retries
+	retryDelays := make([]time.Duration, len(cfg.Webhooks.RetryDelays))
+	for i, d := range cfg.Webhooks.RetryDelays {
+		retryDelays[i] = time.Duration(d)
+	}
+	manager.SetWebhookDelivery(time.Duration(cfg.Webhooks.Timeout), retryDelays)
+
+	// Signed media URLs included in message events
+	manager.SetMediaURLs(cfg.Server.PublicURL, []byte(cfg.Media.URLKey), time.Duration(cfg.Media.URLTTL))
+
 	// Initialize API handlers
 	handlers := api.NewHandlers(manager)
 
+	// Token for admin-only routes such as the /ws/all firehose
+	handlers.SetAdminToken(cfg.Auth.AdminToken)
+	handlers.SetClusterSecret(cfg.Cluster.Secret)
+
+	// Templates of Cloud API template messages
+	handlers.SetCloudTemplates(cfg.Compat.CloudTemplates)
+
 	// Setup router
-	router := mux.NewRouter()
+	router := newRouter(handlers, cfg.Compat.Evolution)
 
-	// Health check
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy","service":"whatsmeow"}`))
-	}).Methods("GET")
+	// Optional OpenTelemetry tracing, exported to an OTLP/HTTP collector
+	tracingEnabled, err := tracing.Init(tracing.Config{
+		Endpoint:       cfg.Tracing.Endpoint,
+		TracesEndpoint: cfg.Tracing.TracesEndpoint,
+		Headers:        cfg.Tracing.Headers,
+		ServiceName:    cfg.Tracing.ServiceName,
+		SampleRatio:    cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+	if tracingEnabled {
+		router.Use(api.TraceRequests)
+		log.Info().Msg("Tracing enabled")
+	}
 
-	// Instance routes
-	router.HandleFunc("/instance/{id}/connect", handlers.ConnectInstance).Methods("POST")
-	router.HandleFunc("/instance/{id}/connect-code", handlers.ConnectWithCode).Methods("POST")
-	router.HandleFunc("/instance/{id}/disconnect", handlers.DisconnectInstance).Methods("POST")
-	router.HandleFunc("/instance/{id}/logout", handlers.LogoutInstance).Methods("POST")
-	router.HandleFunc("/instance/{id}/status", handlers.GetInstanceStatus).Methods("GET")
-	router.HandleFunc("/instance/{id}/settings", handlers.SetSettings).Methods("POST")
-	router.HandleFunc("/instance/{id}/proxy", handlers.SetProxy).Methods("POST")
-	router.HandleFunc("/instance/{id}/proxy/check", handlers.CheckProxyIP).Methods("GET")
-	router.HandleFunc("/instance/{id}/qr", handlers.GetQRCode).Methods("GET")
+	// Requests per API token and instance, for each route class
+	router.Use(handlers.RateLimitRequests(map[string]api.RateLimit{
+		api.RouteClassSend:  {RPS: cfg.RateLimit.SendRPS, Burst: cfg.RateLimit.SendBurst},
+		api.RouteClassWrite: {RPS: cfg.RateLimit.WriteRPS, Burst: cfg.RateLimit.WriteBurst},
+		api.RouteClassRead:  {RPS: cfg.RateLimit.ReadRPS, Burst: cfg.RateLimit.ReadBurst},
+	}))
 
-	// Message routes
-	router.HandleFunc("/message/text", handlers.SendTextMessage).Methods("POST")
-	router.HandleFunc("/message/media", handlers.SendMediaMessage).Methods("POST")
-	router.HandleFunc("/message/presence", handlers.SendPresence).Methods("POST")
-	router.HandleFunc("/message/location", handlers.SendLocationMessage).Methods("POST")
-	router.HandleFunc("/message/poll", handlers.SendPollMessage).Methods("POST")
-	router.HandleFunc("/message/edit", handlers.EditMessage).Methods("POST")
-	router.HandleFunc("/message/react", handlers.ReactToMessage).Methods("POST")
-	router.HandleFunc("/message/read", handlers.MarkChatAsRead).Methods("POST")
-	router.HandleFunc("/message/delete", handlers.DeleteMessage).Methods("POST")
-	router.HandleFunc("/message/download", handlers.DownloadMedia).Methods("POST")
+	// Optional clustering: instances are owned by one node, requests for
+	// them are proxied there and events are relayed to every node
+	var redisCluster *cluster.Redis
+	if redisURL := cfg.Cluster.RedisURL; redisURL != "" {
+		nodeID := cfg.Cluster.NodeID
+		if nodeID == "" {
+			if nodeID, err = os.Hostname(); err != nil {
+				log.Fatal().Err(err).Msg("Failed to determine node ID, set cluster.nodeId")
+			}
+		}
 
-	// Contact routes
-	router.HandleFunc("/contacts/{instanceId}", handlers.GetContacts).Methods("GET")
-	router.HandleFunc("/contacts/{instanceId}/check", handlers.CheckNumber).Methods("POST")
-	router.HandleFunc("/contacts/{instanceId}/resolve/{jid}", handlers.GetContactInfo).Methods("GET")
+		if cfg.Media.URLKey == "" {
+			log.Warn().Msg("media.urlKey is not set, media URLs only work on the node that issued them")
+		}
 
-	// Chat routes
-	router.HandleFunc("/chats/{instanceId}", handlers.GetChats).Methods("GET")
-	router.HandleFunc("/chats/{instanceId}/messages", handlers.GetChatMessages).Methods("POST")
+		redisCluster, err = cluster.NewRedis(redisURL, nodeID, cfg.Cluster.NodeURL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to join cluster")
+		}
+		manager.SetCluster(redisCluster)
+		redisCluster.Subscribe(manager.DeliverRemoteEvent)
+		router.Use(api.ClusterProxy(redisCluster, cfg.Cluster.Secret))
+	}
 
-	// Group routes
-	router.HandleFunc("/groups/{instanceId}", handlers.GetGroups).Methods("GET")
+	// Audit trail of state-changing calls, recorded by the node that handles them
+	router.Use(handlers.AuditRequests)
 
-	// WebSocket for events
-	router.HandleFunc("/ws/{instanceId}", handlers.WebSocketHandler).Methods("GET")
+	// Tenant API keys only reach their own instances, within the tenant's quotas
+	router.Use(handlers.TenantScope)
 
-	// CORS middleware
-	corsRouter := corsMiddleware(router)
+	// Browser origins allowed to call the API, including WebSocket upgrades
+	corsConfig := api.CORSConfig{
+		AllowedOrigins:   cfg.Server.CORS.AllowedOrigins,
+		AllowCredentials: cfg.Server.CORS.AllowCredentials,
+		MaxAge:           time.Duration(cfg.Server.CORS.MaxAge),
+	}
+	handlers.SetCORS(corsConfig)
+	corsRouter := api.CORS(corsConfig)(api.VersionedPaths(router))
 
 	// Create server
 	server := &http.Server{
-		Addr:         ":" + port,
+		Addr:         ":" + strconv.Itoa(cfg.Server.Port),
 		Handler:      corsRouter,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
 	}
+	server.RegisterOnShutdown(handlers.CloseStreams)
 
 	// Start server in goroutine
 	go func() {
-		log.Info().Str("port", port).Msg("🚀 Whatsmeow service started")
+		log.Info().Int("port", cfg.Server.Port).Msg("🚀 Whatsmeow service started")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("Server failed")
 		}
@@ -133,30 +265,226 @@ func main() {
 	log.Info().Msg("Shutting down server...")
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout))
 	defer cancel()
 
-	// Disconnect all WhatsApp clients
-	manager.DisconnectAll()
-
+	// Stop accepting requests and let in-flight ones (sends included) finish
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal().Err(err).Msg("Server forced to shutdown")
+		log.Error().Err(err).Msg("Server forced to shutdown")
+	}
+
+	// Disconnect the WhatsApp clients, then flush webhooks and close the stores
+	manager.Shutdown(ctx)
+
+	// Export the spans still buffered
+	tracing.Shutdown(ctx)
+
+	// Hand the instances of this node over to the rest of the cluster
+	if redisCluster != nil {
+		if err := redisCluster.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to leave cluster")
+		}
 	}
 
 	log.Info().Msg("Server stopped")
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Instance-Token")
+// newRouter registers all HTTP routes, with the Evolution API compatible
+// ones when evolutionCompat is set
+func newRouter(handlers *api.Handlers, evolutionCompat bool) *mux.Router {
+	router := mux.NewRouter()
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
+	// Health check
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"healthy","service":"whatsmeow"}`))
+	}).Methods("GET")
+	router.HandleFunc("/health/ready", handlers.HealthReady).Methods("GET")
+	router.HandleFunc("/health/instances", handlers.HealthInstances).Methods("GET")
 
-		next.ServeHTTP(w, r)
-	})
+	// API routes, versioned by path prefix (see api.VersionedPaths)
+	v1 := router.PathPrefix("/v1").Subrouter()
+
+	// Admin routes
+	v1.HandleFunc("/admin/defaults", handlers.RequireAdmin(handlers.GetDefaults)).Methods("GET")
+	v1.HandleFunc("/admin/defaults", handlers.RequireAdmin(handlers.SetDefaults)).Methods("POST")
+	v1.HandleFunc("/admin/deleted-instances", handlers.RequireAdmin(handlers.GetDeletedInstances)).Methods("GET")
+	v1.HandleFunc("/admin/overview", handlers.RequireAdmin(handlers.GetOverview)).Methods("GET")
+	v1.HandleFunc("/admin/audit", handlers.RequireAdmin(handlers.GetAuditLog)).Methods("GET")
+	v1.HandleFunc("/admin/tenants", handlers.RequireAdmin(handlers.ListTenants)).Methods("GET")
+	v1.HandleFunc("/admin/tenants", handlers.RequireAdmin(handlers.CreateTenant)).Methods("POST")
+	v1.HandleFunc("/admin/tenants/{tenantId}", handlers.RequireAdmin(handlers.GetTenant)).Methods("GET")
+	v1.HandleFunc("/admin/tenants/{tenantId}", handlers.RequireAdmin(handlers.UpdateTenant)).Methods("PUT")
+	v1.HandleFunc("/admin/tenants/{tenantId}", handlers.RequireAdmin(handlers.DeleteTenant)).Methods("DELETE")
+	v1.HandleFunc("/admin/tenants/{tenantId}/usage", handlers.RequireAdmin(handlers.GetTenantUsage)).Methods("GET")
+	v1.HandleFunc("/admin/tenants/{tenantId}/keys", handlers.RequireAdmin(handlers.IssueTenantKey)).Methods("POST")
+	v1.HandleFunc("/admin/tenants/{tenantId}/keys/{keyId}", handlers.RequireAdmin(handlers.RevokeTenantKey)).Methods("DELETE")
+	v1.HandleFunc("/tenant/usage", handlers.GetOwnTenantUsage).Methods("GET")
+
+	// Instance routes
+	v1.HandleFunc("/instance/{id}/connect", handlers.ConnectInstance).Methods("POST")
+	v1.HandleFunc("/instance/{id}/connect-code", handlers.ConnectWithCode).Methods("POST")
+	v1.HandleFunc("/instance/{id}/disconnect", handlers.DisconnectInstance).Methods("POST")
+	v1.HandleFunc("/instance/{id}/logout", handlers.LogoutInstance).Methods("POST")
+	v1.HandleFunc("/instance/{id}/restore", handlers.RestoreInstance).Methods("POST")
+	v1.HandleFunc("/instance/{id}/purge", handlers.PurgeInstance).Methods("POST")
+	v1.HandleFunc("/instance/{id}/token", handlers.RequireAdmin(handlers.IssueInstanceToken)).Methods("POST")
+	v1.HandleFunc("/instance/{id}/token", handlers.RequireAdmin(handlers.RevokeInstanceToken)).Methods("DELETE")
+	v1.HandleFunc("/instance/{id}/status", handlers.GetInstanceStatus).Methods("GET")
+	v1.HandleFunc("/instance/{id}/stats", handlers.GetInstanceStats).Methods("GET")
+	v1.HandleFunc("/instance/{id}/settings", handlers.SetSettings).Methods("POST")
+	v1.HandleFunc("/instance/{id}/proxy", handlers.SetProxy).Methods("POST")
+	v1.HandleFunc("/instance/{id}/proxy/check", handlers.CheckProxyIP).Methods("GET")
+	v1.HandleFunc("/instance/{id}/qr", handlers.GetQRCode).Methods("GET")
+	v1.HandleFunc("/instance/{id}/qr.png", handlers.GetQRCodePNG).Methods("GET")
+	v1.HandleFunc("/instance/{id}/business-profile", handlers.GetBusinessProfile).Methods("GET")
+	v1.HandleFunc("/instance/{id}/business-profile", handlers.UpdateBusinessProfile).Methods("POST")
+
+	// Message routes
+	v1.HandleFunc("/message/text", handlers.SendTextMessage).Methods("POST")
+	v1.HandleFunc("/message/media", handlers.SendMediaMessage).Methods("POST")
+	v1.HandleFunc("/message/send", handlers.SendToJID).Methods("POST")
+	v1.HandleFunc("/message/sticker", handlers.SendSticker).Methods("POST")
+	v1.HandleFunc("/message/presence", handlers.SendPresence).Methods("POST")
+	v1.HandleFunc("/message/location", handlers.SendLocationMessage).Methods("POST")
+	v1.HandleFunc("/message/live-location", handlers.StartLiveLocation).Methods("POST")
+	v1.HandleFunc("/message/live-location/update", handlers.UpdateLiveLocation).Methods("POST")
+	v1.HandleFunc("/message/live-location/stop", handlers.StopLiveLocation).Methods("POST")
+	v1.HandleFunc("/message/{instanceId}/live-location", handlers.GetLiveLocations).Methods("GET")
+	v1.HandleFunc("/message/poll", handlers.SendPollMessage).Methods("POST")
+	v1.HandleFunc("/message/poll/vote", handlers.VotePoll).Methods("POST")
+	v1.HandleFunc("/message/buttons", handlers.SendButtonsMessage).Methods("POST")
+	v1.HandleFunc("/message/list", handlers.SendListMessage).Methods("POST")
+	v1.HandleFunc("/message/status", handlers.SendStatus).Methods("POST")
+	v1.HandleFunc("/message/edit", handlers.EditMessage).Methods("POST")
+	v1.HandleFunc("/message/react", handlers.ReactToMessage).Methods("POST")
+	v1.HandleFunc("/message/read", handlers.MarkChatAsRead).Methods("POST")
+	v1.HandleFunc("/message/unread", handlers.MarkChatAsUnread).Methods("POST")
+	v1.HandleFunc("/message/star", handlers.StarMessage).Methods("POST")
+	v1.HandleFunc("/message/pin", handlers.PinMessage).Methods("POST")
+	v1.HandleFunc("/message/{instanceId}/starred", handlers.GetStarredMessages).Methods("GET")
+	v1.HandleFunc("/message/delete", handlers.DeleteMessage).Methods("POST")
+	v1.HandleFunc("/message/download", handlers.DownloadMedia).Methods("POST")
+	v1.HandleFunc("/message/{instanceId}/{messageId}", handlers.GetMessage).Methods("GET")
+	v1.HandleFunc("/message/{instanceId}/{messageId}/status", handlers.GetMessageStatus).Methods("GET")
+	v1.HandleFunc("/message/{instanceId}/{messageId}/media", handlers.GetMessageMedia).Methods("GET")
+	v1.HandleFunc("/message/{instanceId}/{messageId}/sticker", handlers.GetSticker).Methods("GET")
+	v1.HandleFunc("/message/{instanceId}/{messageId}/reactions", handlers.GetMessageReactions).Methods("GET")
+
+	// Contact routes
+	v1.HandleFunc("/contacts/{instanceId}", handlers.GetContacts).Methods("GET")
+	v1.HandleFunc("/contacts/{instanceId}/check", handlers.CheckNumber).Methods("POST")
+	v1.HandleFunc("/contacts/{instanceId}/export", handlers.ExportContacts).Methods("GET")
+	v1.HandleFunc("/contacts/{instanceId}/import", handlers.ImportContacts).Methods("POST")
+	v1.HandleFunc("/contacts/{instanceId}/addressbook", handlers.GetAddressBook).Methods("GET")
+	v1.HandleFunc("/contacts/{instanceId}/addressbook/{phone}", handlers.DeleteAddressBookEntry).Methods("DELETE")
+	v1.HandleFunc("/contacts/{instanceId}/resolve", handlers.ResolveContacts).Methods("POST")
+	v1.HandleFunc("/contacts/{instanceId}/info", handlers.GetUserInfo).Methods("POST")
+	v1.HandleFunc("/contacts/{instanceId}/resolve/{jid}", handlers.GetContactInfo).Methods("GET")
+	v1.HandleFunc("/contacts/{instanceId}/presence/subscribe", handlers.SubscribePresence).Methods("POST")
+	v1.HandleFunc("/contacts/{instanceId}/presence/{jid}", handlers.GetContactPresence).Methods("GET")
+
+	// Chat routes
+	v1.HandleFunc("/chats/{instanceId}", handlers.GetChats).Methods("GET")
+	v1.HandleFunc("/chats/{instanceId}/messages", handlers.GetChatMessages).Methods("POST")
+	v1.HandleFunc("/chats/{instanceId}/export", handlers.ExportChat).Methods("GET")
+	v1.HandleFunc("/chats/{instanceId}/{jid}", handlers.ClearChat).Methods("DELETE")
+	v1.HandleFunc("/chats/{instanceId}/{jid}/ai", handlers.SetChatAI).Methods("POST")
+	v1.HandleFunc("/chats/{instanceId}/{jid}/disappearing", handlers.SetDisappearingTimer).Methods("PUT")
+
+	// Stored media routes
+	v1.HandleFunc("/media/fetch/{mediaToken}", handlers.FetchMedia).Methods("GET")
+	v1.HandleFunc("/media/{instanceId}/{mediaId}/thumbnail", handlers.GetMediaThumbnail).Methods("GET")
+
+	// Call routes
+	v1.HandleFunc("/calls/{instanceId}", handlers.GetCallLog).Methods("GET")
+	v1.HandleFunc("/calls/{instanceId}/reject", handlers.RejectCall).Methods("POST")
+
+	// Typebot routes
+	v1.HandleFunc("/typebot/{instanceId}/sessions", handlers.GetTypebotSessions).Methods("GET")
+	v1.HandleFunc("/typebot/{instanceId}/sessions/{jid}", handlers.EndTypebotSession).Methods("DELETE")
+
+	// Dialogflow routes
+	v1.HandleFunc("/dialogflow/{instanceId}/sessions", handlers.GetDialogflowSessions).Methods("GET")
+	v1.HandleFunc("/dialogflow/{instanceId}/sessions/{jid}", handlers.EndDialogflowSession).Methods("DELETE")
+
+	// Auto-reply routes
+	v1.HandleFunc("/autoreply/{instanceId}", handlers.GetAutoReplies).Methods("GET")
+	v1.HandleFunc("/autoreply/{instanceId}", handlers.CreateAutoReply).Methods("POST")
+	v1.HandleFunc("/autoreply/{instanceId}/{ruleId}", handlers.UpdateAutoReply).Methods("PUT")
+	v1.HandleFunc("/autoreply/{instanceId}/{ruleId}", handlers.DeleteAutoReply).Methods("DELETE")
+
+	// Campaign routes
+	v1.HandleFunc("/campaigns/{instanceId}", handlers.GetCampaigns).Methods("GET")
+	v1.HandleFunc("/campaigns/{instanceId}", handlers.CreateCampaign).Methods("POST")
+	v1.HandleFunc("/campaigns/{instanceId}/{campaignId}", handlers.GetCampaign).Methods("GET")
+	v1.HandleFunc("/campaigns/{instanceId}/{campaignId}/recipients", handlers.GetCampaignRecipients).Methods("GET")
+	v1.HandleFunc("/campaigns/{instanceId}/{campaignId}/pause", handlers.PauseCampaign).Methods("POST")
+	v1.HandleFunc("/campaigns/{instanceId}/{campaignId}/resume", handlers.ResumeCampaign).Methods("POST")
+	v1.HandleFunc("/campaigns/{instanceId}/{campaignId}/cancel", handlers.CancelCampaign).Methods("POST")
+
+	// Group routes
+	v1.HandleFunc("/groups/{instanceId}", handlers.GetGroups).Methods("GET")
+	v1.HandleFunc("/groups/{instanceId}/{jid}", handlers.GetGroupInfo).Methods("GET")
+	v1.HandleFunc("/groups/{instanceId}/{jid}/settings", handlers.SetGroupSettings).Methods("PUT")
+
+	// Channel (newsletter) routes
+	v1.HandleFunc("/newsletters/{instanceId}", handlers.GetNewsletters).Methods("GET")
+	v1.HandleFunc("/newsletters/{instanceId}/follow", handlers.FollowNewsletter).Methods("POST")
+	v1.HandleFunc("/newsletters/{instanceId}/unfollow", handlers.UnfollowNewsletter).Methods("POST")
+	v1.HandleFunc("/newsletters/{instanceId}/{jid}/messages", handlers.GetNewsletterMessages).Methods("GET")
+	v1.HandleFunc("/newsletters/{instanceId}/{jid}/post", handlers.PublishNewsletterPost).Methods("POST")
+
+	// WebSocket for events (/ws/all must be registered before /ws/{instanceId})
+	v1.HandleFunc("/ws/all", handlers.RequireAdmin(handlers.WebSocketAll)).Methods("GET")
+	v1.HandleFunc("/ws/{instanceId}", handlers.RequireInstanceToken(handlers.WebSocketHandler)).Methods("GET")
+
+	// Long polling for events (alternative to WebSocket)
+	v1.HandleFunc("/events/{instanceId}/poll", handlers.RequireInstanceToken(handlers.PollEvents)).Methods("GET")
+
+	// Server-Sent Events (alternative to WebSocket behind proxies)
+	v1.HandleFunc("/events/{instanceId}/sse", handlers.RequireInstanceToken(handlers.StreamEvents)).Methods("GET")
+	v1.HandleFunc("/events/{instanceId}/webhook/replay", handlers.RequireInstanceToken(handlers.ReplayWebhook)).Methods("POST")
+
+	// GraphQL (read-only queries; subscriptions over WebSocket)
+	v1.HandleFunc("/graphql", handlers.GraphQL).Methods("GET", "POST")
+
+	// WhatsApp Cloud API compatible send, with the instance ID as phone number ID
+	v1.HandleFunc("/{instanceId}/messages", handlers.CloudSendMessage).Methods("POST")
+
+	// Evolution API compatible routes, for integrations migrating from it
+	if evolutionCompat {
+		evolution := v1.PathPrefix("/evolution").Subrouter()
+		evolution.Use(handlers.EvolutionAuth)
+		evolution.HandleFunc("/instance/create", handlers.EvolutionCreateInstance).Methods("POST")
+		evolution.HandleFunc("/instance/connect/{instanceName}", handlers.EvolutionConnect).Methods("GET")
+		evolution.HandleFunc("/instance/connectionState/{instanceName}", handlers.EvolutionConnectionState).Methods("GET")
+		evolution.HandleFunc("/instance/fetchInstances", handlers.EvolutionFetchInstances).Methods("GET")
+		evolution.HandleFunc("/instance/logout/{instanceName}", handlers.EvolutionLogout).Methods("DELETE")
+		evolution.HandleFunc("/instance/delete/{instanceName}", handlers.EvolutionDeleteInstance).Methods("DELETE")
+		evolution.HandleFunc("/message/sendText/{instanceName}", handlers.EvolutionSendText).Methods("POST")
+		evolution.HandleFunc("/message/sendMedia/{instanceName}", handlers.EvolutionSendMedia).Methods("POST")
+		evolution.HandleFunc("/message/sendWhatsAppAudio/{instanceName}", handlers.EvolutionSendAudio).Methods("POST")
+		evolution.HandleFunc("/message/sendLocation/{instanceName}", handlers.EvolutionSendLocation).Methods("POST")
+		evolution.HandleFunc("/message/sendReaction/{instanceName}", handlers.EvolutionSendReaction).Methods("POST")
+		evolution.HandleFunc("/chat/whatsappNumbers/{instanceName}", handlers.EvolutionWhatsAppNumbers).Methods("POST")
+		evolution.HandleFunc("/webhook/set/{instanceName}", handlers.EvolutionSetWebhook).Methods("POST")
+		evolution.HandleFunc("/webhook/find/{instanceName}", handlers.EvolutionFindWebhook).Methods("GET")
+	}
+
+	// API documentation
+	router.HandleFunc("/docs", handlers.SwaggerUI).Methods("GET")
+	router.HandleFunc("/docs/openapi.json", handlers.OpenAPISpec(router)).Methods("GET")
+
+	return router
+}
+
+// writeOpenAPISpec writes the OpenAPI document without starting the service
+func writeOpenAPISpec(path string) error {
+	router := newRouter(api.NewHandlers(nil), true)
+	data, err := json.MarshalIndent(api.BuildOpenAPISpec(router), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
 }